@@ -5,11 +5,15 @@ package agentfs
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -21,6 +25,12 @@ type AgentFS struct {
 	ownsDB bool // true if we opened the DB and should close it
 	path   string
 
+	readOnly bool   // See AgentFSOptions.ReadOnly
+	tempDir  string // Removed on Close (see OpenForensic)
+
+	replica Replica    // See AgentFSOptions.Sync
+	syncMu  sync.Mutex // One replica sync at a time
+
 	// FS provides filesystem operations
 	FS *Filesystem
 
@@ -29,6 +39,24 @@ type AgentFS struct {
 
 	// Tools provides tool call tracking operations
 	Tools *ToolCalls
+
+	// Mailbox exchanges messages with other agents
+	Mailbox *Mailbox
+
+	opts           AgentFSOptions // As opened, for Fork
+	checkpoints    checkpointState
+	checkpointOpts CheckpointOptions
+	policy         *policyCache
+	sinks          sinkRegistry
+	life           *lifecycle
+	events         *eventBus
+	closeOnce      sync.Once
+	closeErr       error
+
+	// Background workers
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
 }
 
 // ErrSchemaVersionMismatch is returned when a database was created with an
@@ -42,6 +70,11 @@ func (e *ErrSchemaVersionMismatch) Error() string {
 	return fmt.Sprintf("schema version mismatch: found %q, expected %q", e.Found, e.Expected)
 }
 
+// DefaultBusyTimeout is how long a statement waits for the write lock held
+// by another connection, such as a background worker's, before failing
+// with SQLITE_BUSY.
+const DefaultBusyTimeout = 5 * time.Second
+
 // validIDPattern matches valid agent IDs (alphanumeric, hyphens, underscores)
 var validIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
@@ -51,6 +84,13 @@ var validIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 // If opts.ID is provided (without Path), the database is stored at ~/.agentfs/{id}.db
 // At least one of Path or ID must be provided.
 func Open(ctx context.Context, opts AgentFSOptions) (*AgentFS, error) {
+	if isRemoteURL(opts.Path) {
+		return openRemote(ctx, opts)
+	}
+	if opts.Sync.URL != "" {
+		return openReplica(ctx, opts)
+	}
+
 	dbPath, err := resolveDBPath(opts)
 	if err != nil {
 		return nil, err
@@ -62,36 +102,42 @@ func Open(ctx context.Context, opts AgentFSOptions) (*AgentFS, error) {
 		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
-	// Open database
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	// Databases written by a newer SDK are opened read-only
+	if !opts.ReadOnly {
+		newer, err := newerSchema(ctx, dbPath, opts.TablePrefix)
+		if err != nil {
+			return nil, err
+		}
+		opts.ReadOnly = newer
 	}
 
-	// Configure connection pool
-	if opts.Pool.MaxOpenConns > 0 {
-		db.SetMaxOpenConns(opts.Pool.MaxOpenConns)
-	}
-	if opts.Pool.MaxIdleConns > 0 {
-		db.SetMaxIdleConns(opts.Pool.MaxIdleConns)
+	// Open database. Every connection of the pool waits out the others'
+	// writes rather than failing, and write transactions take the lock when
+	// they begin, since one taken later can fail outright
+	dsn := dbPath + "?_txlock=immediate&"
+	if opts.ReadOnly {
+		dsn = "file:" + dbPath + "?mode=ro&"
 	}
-	if opts.Pool.ConnMaxLifetime > 0 {
-		db.SetConnMaxLifetime(opts.Pool.ConnMaxLifetime)
-	}
-	if opts.Pool.ConnMaxIdleTime > 0 {
-		db.SetConnMaxIdleTime(opts.Pool.ConnMaxIdleTime)
+	dsn += fmt.Sprintf("_pragma=busy_timeout(%d)", DefaultBusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Enable WAL mode for better concurrency
-	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode=WAL"); err != nil {
+	configurePool(db, opts.Pool)
+
+	if err := setupPragmas(ctx, db, opts); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+		return nil, err
 	}
 
-	// Enable foreign keys
-	if _, err := db.ExecContext(ctx, "PRAGMA foreign_keys=ON"); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	if opts.TablePrefix != "" {
+		pdb, err := withTablePrefix(db, opts.TablePrefix, true)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db = pdb
 	}
 
 	afs, err := initAgentFS(ctx, db, dbPath, true, opts)
@@ -103,36 +149,144 @@ func Open(ctx context.Context, opts AgentFSOptions) (*AgentFS, error) {
 	return afs, nil
 }
 
+// New creates an AgentFS on a database the caller opened and manages, e.g.
+// through a custom driver, an instrumented wrapper, or a libSQL embedded
+// replica. Unlike OpenWith it takes the full AgentFSOptions.
+//
+// The caller retains ownership of db: Close on the returned AgentFS does
+// not close it. opts.Path, if set, names the database file for the features
+// that keep files next to it (the mailbox directory and external storage);
+// opts.ID and opts.Pool are ignored. New enables WAL mode and foreign keys
+// unless they already are, or opts.SkipPragmas is set. A database written
+// by a newer SDK fails with *ErrSchemaVersionMismatch, since New cannot
+// reopen it read-only.
+//
+// Example:
+//
+//	db, err := sql.Open("libsql", "file:/var/lib/app/agent.db")
+//	if err != nil {
+//	    return err
+//	}
+//	afs, err := agentfs.New(ctx, db, agentfs.AgentFSOptions{Path: "/var/lib/app/agent.db"})
+func New(ctx context.Context, db *sql.DB, opts AgentFSOptions) (*AgentFS, error) {
+	if db == nil {
+		return nil, errors.New("agentfs: nil database")
+	}
+	if !opts.SkipPragmas {
+		if err := setupPragmas(ctx, db, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// The prefixed database borrows connections from db, so it is ours to
+	// close even though db is not
+	ownsDB := false
+	if opts.TablePrefix != "" {
+		pdb, err := withTablePrefix(db, opts.TablePrefix, false)
+		if err != nil {
+			return nil, err
+		}
+		db, ownsDB = pdb, true
+	}
+
+	afs, err := initAgentFS(ctx, db, opts.Path, ownsDB, opts)
+	if err != nil {
+		if ownsDB {
+			db.Close()
+		}
+		return nil, err
+	}
+
+	return afs, nil
+}
+
+// configurePool applies the connection pool options to db.
+func configurePool(db *sql.DB, pool PoolOptions) {
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+}
+
+// setupPragmas enables WAL mode and foreign keys on db. Settings that are
+// already in effect are left alone, so it is safe on databases the caller
+// configured.
+func setupPragmas(ctx context.Context, db *sql.DB, opts AgentFSOptions) error {
+	// Enable WAL mode for better concurrency. Leave the journal mode as the
+	// writers set it, or as libSQL keeps a remote database or replica
+	if !opts.ReadOnly && !isRemoteURL(opts.Path) && opts.Sync.URL == "" {
+		var mode string
+		err := db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode)
+		if err == nil && !strings.EqualFold(mode, "wal") {
+			_, err = db.ExecContext(ctx, "PRAGMA journal_mode=WAL")
+		}
+		if err != nil {
+			if opts.VerifyOnOpen != VerifyNone && isCorruptError(err) {
+				return &ErrCorrupt{Check: "open", Problems: []string{err.Error()}}
+			}
+			return fmt.Errorf("failed to enable WAL mode: %w", err)
+		}
+	}
+
+	// Enable foreign keys
+	var fk int
+	err := db.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&fk)
+	if err == nil && fk == 0 {
+		_, err = db.ExecContext(ctx, "PRAGMA foreign_keys=ON")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enable foreign keys: %w", err)
+	}
+	return nil
+}
+
 // OpenWith creates an AgentFS using an existing *sql.DB connection.
 //
 // The caller retains ownership of the database connection. Calling Close()
 // on the returned AgentFS will not close the underlying database.
 //
 // OpenWithOptions can be used to configure cache and chunk size. Pool options
-// are ignored since the connection is externally managed.
+// are ignored since the connection is externally managed. Unlike New,
+// OpenWith leaves the pragmas of db as they are.
 func OpenWith(ctx context.Context, db *sql.DB, opts ...OpenWithOption) (*AgentFS, error) {
 	o := openWithOptions{}
 	for _, opt := range opts {
 		opt(&o)
 	}
 
-	afsOpts := AgentFSOptions{
-		ChunkSize: o.chunkSize,
-	}
-
-	afs, err := initAgentFS(ctx, db, "", false, afsOpts)
-	if err != nil {
-		return nil, err
-	}
-
-	return afs, nil
+	return New(ctx, db, AgentFSOptions{
+		ChunkSize:    o.chunkSize,
+		Checkpoint:   o.checkpoint,
+		VerifyOnOpen: o.verify,
+		External:     o.external,
+		Clock:        o.clock,
+		IDGenerator:  o.ids,
+		Mailbox:      o.mailbox,
+		TablePrefix:  o.tablePrefix,
+		SkipPragmas:  true,
+	})
 }
 
 // OpenWithOption configures optional settings for OpenWith.
 type OpenWithOption func(*openWithOptions)
 
 type openWithOptions struct {
-	chunkSize int
+	chunkSize   int
+	checkpoint  CheckpointOptions
+	verify      VerifyMode
+	external    ExternalStorageOptions
+	clock       Clock
+	ids         IDGenerator
+	mailbox     MailboxOptions
+	tablePrefix string
 }
 
 // WithChunkSize sets the chunk size for file data storage.
@@ -142,8 +296,85 @@ func WithChunkSize(size int) OpenWithOption {
 	}
 }
 
+// WithCheckpoint configures automatic WAL checkpointing.
+// Size-based checkpoints are not available because the database path is unknown.
+func WithCheckpoint(opts CheckpointOptions) OpenWithOption {
+	return func(o *openWithOptions) {
+		o.checkpoint = opts
+	}
+}
+
+// WithVerifyOnOpen runs an integrity check before the database is used.
+func WithVerifyOnOpen(mode VerifyMode) OpenWithOption {
+	return func(o *openWithOptions) {
+		o.verify = mode
+	}
+}
+
+// WithExternalStorage stores the data of large files outside the database.
+// Dir must be set since the database path is unknown.
+func WithExternalStorage(opts ExternalStorageOptions) OpenWithOption {
+	return func(o *openWithOptions) {
+		o.external = opts
+	}
+}
+
+// WithClock sets the clock used for recorded timestamps.
+func WithClock(clock Clock) OpenWithOption {
+	return func(o *openWithOptions) {
+		o.clock = clock
+	}
+}
+
+// WithIDGenerator sets the generator used for otherwise random IDs.
+func WithIDGenerator(ids IDGenerator) OpenWithOption {
+	return func(o *openWithOptions) {
+		o.ids = ids
+	}
+}
+
+// WithMailbox configures the agent mailbox. Without MailboxOptions.DB or
+// Dir, the provided database is shared by all agents sending to each other.
+func WithMailbox(opts MailboxOptions) OpenWithOption {
+	return func(o *openWithOptions) {
+		o.mailbox = opts
+	}
+}
+
+// WithTablePrefix adds prefix to the names of the tables AgentFS creates in
+// db, so it can share the database with the application's own tables (see
+// AgentFSOptions.TablePrefix). DB then returns a database that adds the
+// prefix to AgentFS table names in the queries it runs.
+//
+// Example:
+//
+//	afs, err := agentfs.OpenWith(ctx, appDB, agentfs.WithTablePrefix("agentfs_"))
+func WithTablePrefix(prefix string) OpenWithOption {
+	return func(o *openWithOptions) {
+		o.tablePrefix = prefix
+	}
+}
+
 // initAgentFS contains the shared initialization logic for Open and OpenWith.
 func initAgentFS(ctx context.Context, db *sql.DB, dbPath string, ownsDB bool, opts AgentFSOptions) (*AgentFS, error) {
+	// Check for corruption before touching the schema
+	if err := verifyDB(ctx, db, opts.VerifyOnOpen); err != nil {
+		return nil, err
+	}
+
+	// Refuse to migrate a schema this SDK does not know
+	foundVersion, err := storedSchemaVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ReadOnly {
+		return openReadOnly(ctx, db, dbPath, ownsDB, opts, foundVersion)
+	}
+	if foundVersion != "" && compareSchemaVersions(foundVersion, schemaVersion) > 0 {
+		return nil, &ErrSchemaVersionMismatch{Found: foundVersion, Expected: schemaVersion}
+	}
+	created := foundVersion == ""
+
 	// Initialize schema
 	if err := initSchema(ctx, db); err != nil {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
@@ -154,19 +385,39 @@ func initAgentFS(ctx context.Context, db *sql.DB, dbPath string, ownsDB bool, op
 	for _, stmt := range nsecMigrations() {
 		db.ExecContext(ctx, stmt) // Ignore errors (column may already exist)
 	}
+	for _, stmt := range extChunkMigrations() {
+		db.ExecContext(ctx, stmt) // Ignore errors (column may already exist)
+	}
+	for _, stmt := range versionMigrations() {
+		db.ExecContext(ctx, stmt) // Ignore errors (column may already exist)
+	}
+	for _, stmt := range toolCallMigrations() {
+		db.ExecContext(ctx, stmt) // Ignore errors (column may already exist)
+	}
+	for _, stmt := range recordIDMigrations() {
+		db.ExecContext(ctx, stmt) // Ignore errors (column may already exist)
+	}
+	if err := migrateToolCallHistory(ctx, db); err != nil {
+		return nil, fmt.Errorf("failed to migrate tool call history: %w", err)
+	}
+	for _, stmt := range kvMigrations() {
+		db.ExecContext(ctx, stmt) // Ignore errors (column may already exist)
+	}
 
 	// Initialize and validate schema version
 	if _, err := db.ExecContext(ctx, initSchemaVersion, schemaVersion); err != nil {
 		return nil, fmt.Errorf("failed to initialize schema_version: %w", err)
 	}
 
-	var foundVersion string
 	if err := db.QueryRowContext(ctx, getSchemaVersion).Scan(&foundVersion); err != nil {
 		return nil, fmt.Errorf("failed to read schema_version: %w", err)
 	}
 	if foundVersion != schemaVersion {
 		return nil, &ErrSchemaVersionMismatch{Found: foundVersion, Expected: schemaVersion}
 	}
+	if err := recordFeatures(ctx, db); err != nil {
+		return nil, err
+	}
 
 	// Determine chunk size
 	chunkSize := opts.ChunkSize
@@ -180,10 +431,77 @@ func initAgentFS(ctx context.Context, db *sql.DB, dbPath string, ownsDB bool, op
 	}
 
 	// Initialize root inode
-	if _, err := db.ExecContext(ctx, initRootInode, DefaultDirMode); err != nil {
+	now := opts.clock().Now().Unix()
+	if _, err := db.ExecContext(ctx, initRootInode, DefaultDirMode, now, now, now); err != nil {
 		return nil, fmt.Errorf("failed to initialize root inode: %w", err)
 	}
 
+	afs, err := newAgentFS(ctx, db, dbPath, ownsDB, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if afs.readOnly {
+		return afs, nil
+	}
+	afs.FS.changes = newFSChangeLog()
+
+	// Recover tool calls left running by processes that have since exited
+	if _, err := afs.Tools.recoverOrphans(ctx); err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted tool calls: %w", err)
+	}
+	if created && opts.Template.FS != nil {
+		if err := afs.applyTemplate(ctx, opts.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	afs.startCheckpointer(opts.Checkpoint)
+	afs.startOptimizer(opts.Optimize.Interval)
+	afs.startPolicyEnforcer()
+	afs.startKVReaper()
+	afs.startFSChangeLog()
+
+	return afs, nil
+}
+
+// clock returns the configured clock, or the system clock.
+func (opts AgentFSOptions) clock() Clock {
+	if opts.Clock == nil {
+		return systemClock{}
+	}
+	return opts.Clock
+}
+
+// newAgentFS sets up the subsystems over an initialized database without
+// writing to it.
+func newAgentFS(ctx context.Context, db *sql.DB, dbPath string, ownsDB bool, opts AgentFSOptions) (*AgentFS, error) {
+	clock := opts.clock()
+	ids := opts.IDGenerator
+	if ids == nil {
+		ids = randomIDs{}
+	}
+	uids, err := newRecordIDs(opts.IDStrategy, clock, opts.IDGenerator)
+	if err != nil {
+		return nil, err
+	}
+	// Tool call owners identify the process unless IDs are injected
+	var owner string
+	if opts.IDGenerator != nil {
+		owner = opts.IDGenerator.NewID()
+	} else {
+		host, _ := os.Hostname()
+		owner = fmt.Sprintf("%s/%d/%s", host, os.Getpid(), randomIDs{}.NewID())
+	}
+
+	paths := opts.Paths
+	if paths.MaxLength <= 0 {
+		paths.MaxLength = DefaultMaxPathLength
+	}
+	if paths.MaxDepth <= 0 {
+		paths.MaxDepth = DefaultMaxPathDepth
+	}
+
 	// Read actual chunk size from database (may differ if database already existed)
 	var chunkSizeStr string
 	if err := db.QueryRowContext(ctx, getChunkSize).Scan(&chunkSizeStr); err != nil {
@@ -194,35 +512,146 @@ func initAgentFS(ctx context.Context, db *sql.DB, dbPath string, ownsDB bool, op
 		return nil, fmt.Errorf("invalid chunk_size value: %w", err)
 	}
 
+	if opts.ChunkSizeFor != nil && !opts.FileChunkSizes {
+		return nil, fmt.Errorf("ChunkSizeFor needs FileChunkSizes: files in chunks of their own size are readable by this SDK only")
+	}
+
+	blobs, err := openBlobStore(ctx, db, dbPath, opts.External)
+	if err != nil {
+		return nil, err
+	}
+
 	afs := &AgentFS{
 		db:     db,
 		ownsDB: ownsDB,
 		path:   dbPath,
+		stop:   make(chan struct{}),
+		life:   &lifecycle{},
+		events: newEventBus(clock),
+		policy: &policyCache{},
+		opts:   opts,
+
+		readOnly: opts.ReadOnly,
+
+		checkpointOpts: opts.Checkpoint,
 	}
 
 	// Initialize subsystems
 	afs.FS = &Filesystem{
-		db:        db,
-		chunkSize: actualChunkSize,
+		db:           db,
+		conn:         db,
+		chunkSize:    actualChunkSize,
+		chunkSizeFor: opts.ChunkSizeFor,
+		fileChunks:   opts.FileChunkSizes,
+		blobs:        blobs,
+		life:         afs.life,
+		gate:         &writeGate{},
+		clock:        clock,
+		ids:          ids,
+		paths:        paths,
+		events:       afs.events,
+		previews:     newPreviewers(),
+		policy:       afs.policy,
+		strict:       opts.Strict,
+		handles: newHandleTable(opts.Handles, func(kind EventKind, p string) {
+			afs.events.publish(Event{Kind: kind, Path: p})
+		}),
+	}
+	if opts.Coalesce.Interval > 0 && !opts.ReadOnly {
+		afs.FS.coalesce = newCoalescer(afs.FS, opts.Coalesce)
+	}
+	if !opts.ReadOnly {
+		afs.FS.optimizer = newOptimizer(db, afs.FS.gate, opts.Optimize)
+	}
+	if opts.LookupFilter {
+		if err := afs.FS.loadLookupFilter(ctx); err != nil {
+			return nil, err
+		}
+	}
+	afs.KV = &KVStore{db: db, conn: db, life: afs.life, events: afs.events, clock: clock}
+	if opts.KVCache.MaxEntries > 0 {
+		if opts.Pool.MaxOpenConns == 1 {
+			return nil, fmt.Errorf("KVCache needs a connection of its own: MaxOpenConns must be at least 2")
+		}
+		if afs.KV.cache, err = newKVCache(ctx, db, opts.KVCache); err != nil {
+			return nil, err
+		}
 	}
-	afs.KV = &KVStore{db: db}
-	afs.Tools = &ToolCalls{db: db}
+	afs.Tools = newToolCalls(db, afs.life, clock, owner, opts.Tools, afs.goBackground)
+	afs.Tools.events = afs.events
+	afs.Tools.fs = afs.FS
+	afs.Tools.uids = uids
+	afs.Mailbox = newMailbox(db, dbPath, opts, afs.life, clock)
 
 	return afs, nil
 }
 
 // Close closes the AgentFS instance.
+//
+// Close writes the content held by write coalescing, rejects new operations
+// with ErrClosed, waits for in-flight operations to finish, stops background
+// workers, refreshes the planner statistics (see Optimize), runs the final
+// checkpoint if CheckpointOptions.OnClose is set, syncs an embedded replica
+// (see AgentFSOptions.Sync), and then releases the connection.
 // If the database was opened by Open, the connection is closed.
 // If the database was provided via OpenWith, the connection is not closed.
+// Calling Close more than once returns the result of the first call.
 func (a *AgentFS) Close() error {
+	return a.CloseWithTimeout(0)
+}
+
+// CloseWithTimeout is like Close but waits at most timeout for in-flight
+// operations. If they do not finish in time, the instance is closed anyway
+// and an *ErrCloseTimeout is returned. A timeout <= 0 waits indefinitely.
+func (a *AgentFS) CloseWithTimeout(timeout time.Duration) error {
+	a.closeOnce.Do(func() {
+		a.closeErr = a.close(timeout)
+	})
+	return a.closeErr
+}
+
+// close performs the shutdown sequence for CloseWithTimeout.
+func (a *AgentFS) close(timeout time.Duration) error {
+	syncErr := a.FS.coalesce.close()
+	drainErr := a.life.shutdown(timeout)
+
+	a.stopBackground()
+	a.FS.handles.closeAll()
+	a.events.close()
+	cacheErr := a.KV.cache.close()
+
+	var optimizeErr error
+	if !a.opts.Optimize.SkipOnClose && drainErr == nil {
+		optimizeErr = a.FS.optimizer.run(context.Background())
+	}
+
+	var checkpointErr error
+	if a.checkpointOpts.OnClose && drainErr == nil && !a.readOnly {
+		_, checkpointErr = a.Checkpoint(context.Background(), a.checkpointOpts.Mode)
+	}
+
+	var replicaErr error
+	if a.replica != nil && drainErr == nil && !a.readOnly {
+		replicaErr = a.sync(context.Background())
+	}
+
+	var closeErr error
 	if a.ownsDB {
-		return a.db.Close()
+		closeErr = a.db.Close()
 	}
-	return nil
+	if a.replica != nil {
+		closeErr = errors.Join(closeErr, a.replica.Close())
+	}
+	if a.tempDir != "" {
+		closeErr = errors.Join(closeErr, os.RemoveAll(a.tempDir))
+	}
+
+	return errors.Join(syncErr, drainErr, optimizeErr, checkpointErr, replicaErr, cacheErr, closeErr)
 }
 
 // Path returns the path to the underlying database file.
-// Returns an empty string if the AgentFS was created via OpenWith.
+// Returns an empty string if the AgentFS was created via OpenWith or opened
+// on a remote database.
 func (a *AgentFS) Path() string {
 	return a.path
 }
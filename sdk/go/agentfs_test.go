@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -588,6 +589,32 @@ func TestFileHandle(t *testing.T) {
 			t.Errorf("Content = %q, want %q", buf[:n], "Hello")
 		}
 	})
+
+	t.Run("truncate to zero", func(t *testing.T) {
+		_, f, err := fs.Create(ctx, "/empty.txt", 0o644)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		defer f.Close()
+
+		f.Pwrite(ctx, []byte("Hello, World!"), 0)
+		if err := f.Truncate(ctx, 0); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+
+		// The first chunk goes too, not just the ones after it
+		var chunks int
+		afs.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM fs_data WHERE ino = ?", f.ino).Scan(&chunks)
+		if chunks != 0 {
+			t.Errorf("%d chunks left after truncating to zero", chunks)
+		}
+		f.Pwrite(ctx, []byte("Hi"), 4)
+		buf := make([]byte, 10)
+		n, _ := f.Pread(ctx, buf, 0)
+		if string(buf[:n]) != "\x00\x00\x00\x00Hi" {
+			t.Errorf("Content = %q, want zeros then %q", buf[:n], "Hi")
+		}
+	})
 }
 
 func TestLargeFile(t *testing.T) {
@@ -1119,12 +1146,13 @@ func TestOpenClosesDBOnError(t *testing.T) {
 	}
 	afs.Close()
 
-	// Tamper with the schema version
+	// Tamper with the schema version; an older one this SDK cannot
+	// migrate fails, where a newer one would open read-only
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		t.Fatalf("sql.Open failed: %v", err)
 	}
-	_, err = db.ExecContext(ctx, "UPDATE fs_config SET value = '9.9' WHERE key = 'schema_version'")
+	_, err = db.ExecContext(ctx, "UPDATE fs_config SET value = '0.1' WHERE key = 'schema_version'")
 	if err != nil {
 		t.Fatalf("UPDATE failed: %v", err)
 	}
@@ -2421,3 +2449,200 @@ func setupTestDB(t *testing.T) *AgentFS {
 
 	return afs
 }
+
+func TestCreateExclusive(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	t.Run("creates new file", func(t *testing.T) {
+		stats, f, err := fs.CreateExclusive(ctx, "/out/report.md", 0o600)
+		if err != nil {
+			t.Fatalf("CreateExclusive failed: %v", err)
+		}
+		defer f.Close()
+		if !stats.IsRegularFile() || stats.Permissions() != 0o600 {
+			t.Errorf("stats = %+v", stats)
+		}
+	})
+
+	t.Run("existing file is not clobbered", func(t *testing.T) {
+		if err := fs.WriteFile(ctx, "/keep.txt", []byte("original"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		_, _, err := fs.CreateExclusive(ctx, "/keep.txt", 0o644)
+		if !IsExist(err) {
+			t.Fatalf("err = %v, want EEXIST", err)
+		}
+		data, _ := fs.ReadFile(ctx, "/keep.txt")
+		if string(data) != "original" {
+			t.Errorf("content = %q, want %q", data, "original")
+		}
+	})
+
+	t.Run("open with O_EXCL", func(t *testing.T) {
+		if _, err := fs.Open(ctx, "/excl.txt", O_CREATE|O_EXCL|O_RDWR); err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if _, err := fs.Open(ctx, "/excl.txt", O_CREATE|O_EXCL|O_RDWR); !IsExist(err) {
+			t.Errorf("second Open err = %v, want EEXIST", err)
+		}
+	})
+}
+
+func TestCreateExclusiveRace(t *testing.T) {
+	ctx := context.Background()
+	afs, err := Open(ctx, AgentFSOptions{
+		Path: filepath.Join(t.TempDir(), "test.db"),
+		Pool: PoolOptions{MaxOpenConns: 1},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	const workers = 8
+	results := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			_, _, err := afs.FS.CreateExclusive(ctx, "/elected.txt", 0o644)
+			results <- err
+		}()
+	}
+
+	winners := 0
+	for i := 0; i < workers; i++ {
+		err := <-results
+		switch {
+		case err == nil:
+			winners++
+		case !IsExist(err):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if winners != 1 {
+		t.Errorf("winners = %d, want 1", winners)
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	p := filepath.Join(t.TempDir(), "app.db")
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+
+	afs, err := New(ctx, db, AgentFSOptions{Path: p, ChunkSize: 1024})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if afs.Path() != p || afs.DB() != db || afs.FS.ChunkSize() != 1024 {
+		t.Errorf("Path = %q, ChunkSize = %d", afs.Path(), afs.FS.ChunkSize())
+	}
+	var mode string
+	if err := db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q, %v; want wal", mode, err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/a.txt", []byte("hi"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	afs.Close()
+
+	// Setting up the pragmas again is a no-op
+	afs, err = New(ctx, db, AgentFSOptions{Path: p})
+	if err != nil {
+		t.Fatalf("second New failed: %v", err)
+	}
+	if data, err := afs.FS.ReadFile(ctx, "/a.txt"); err != nil || string(data) != "hi" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	afs.Close()
+
+	if _, err := New(ctx, nil, AgentFSOptions{}); err == nil {
+		t.Error("New with a nil database succeeded")
+	}
+}
+
+func TestNewSkipPragmas(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+
+	afs, err := New(ctx, db, AgentFSOptions{SkipPragmas: true})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer afs.Close()
+	var mode string
+	if err := db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil || mode != "delete" {
+		t.Errorf("journal_mode = %q, %v; want delete", mode, err)
+	}
+}
+
+func TestReaddirPage(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	const count = 2500
+	tx, err := afs.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	tx.FS.Mkdir(ctx, "/big", 0o755)
+	for i := 0; i < count; i++ {
+		if err := tx.FS.WriteFile(ctx, fmt.Sprintf("/big/f%05d", i), nil, 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	var names []string
+	var pages int
+	opts := ReaddirOptions{}
+	for {
+		page, err := afs.FS.ReaddirPage(ctx, "/big", opts)
+		if err != nil {
+			t.Fatalf("ReaddirPage failed: %v", err)
+		}
+		pages++
+		for _, e := range page.Entries {
+			names = append(names, e.Name)
+		}
+		if page.Next == "" {
+			break
+		}
+		opts.After = page.Next
+	}
+	if pages != 3 || len(names) != count {
+		t.Fatalf("read %d entries in %d pages, want %d in 3", len(names), pages, count)
+	}
+	for i, name := range names {
+		if want := fmt.Sprintf("f%05d", i); name != want {
+			t.Fatalf("entry %d = %q, want %q", i, name, want)
+		}
+	}
+
+	page, err := afs.FS.ReaddirPage(ctx, "/big", ReaddirOptions{After: "f00009", Limit: 2})
+	if err != nil {
+		t.Fatalf("ReaddirPage failed: %v", err)
+	}
+	if len(page.Entries) != 2 || page.Entries[0].Name != "f00010" || page.Next != "f00011" || page.Entries[0].Stats == nil {
+		t.Errorf("ReaddirPage(After: f00009, Limit: 2) = %+v", page)
+	}
+
+	if _, err := afs.FS.ReaddirPage(ctx, "/big/f00000", ReaddirOptions{}); err == nil {
+		t.Error("ReaddirPage of a file succeeded")
+	}
+	if err := afs.FS.Rmdir(ctx, "/big"); err == nil {
+		t.Error("Rmdir of a non-empty directory succeeded")
+	}
+}
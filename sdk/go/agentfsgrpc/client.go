@@ -0,0 +1,326 @@
+package agentfsgrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// Client calls the AgentFS service of a Server for one agent. Errors the
+// server returns are *Error.
+//
+// Example:
+//
+//	c := agentfsgrpc.NewClient("https://agentfsd:8443", "worker-7", nil)
+//	if _, err := c.WriteFile(ctx, "/out/report.md", strings.NewReader(report), 0o644); err != nil {
+//	    return err
+//	}
+//	r, err := c.ReadFile(ctx, "/data/input.csv")
+type Client struct {
+	baseURL string
+	agent   string
+	hc      *http.Client
+}
+
+// NewClient returns a client of the server at baseURL, acting on the
+// database of agent. hc defaults to http.DefaultClient, which speaks
+// HTTP/2 to TLS servers.
+func NewClient(baseURL, agent string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), agent: agent, hc: hc}
+}
+
+// call starts the method with the request messages in body.
+func (c *Client) call(ctx context.Context, method string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+servicePath+method, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if c.agent != "" {
+		req.Header.Set(AgentHeader, c.agent)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &Error{CodeUnavailable, fmt.Sprintf("HTTP status %s", resp.Status)}
+	}
+	// A call failing before any message may end with its headers
+	if err := statusFrom(resp.Header); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// statusFrom returns the error of the status in h, if any.
+func statusFrom(h http.Header) error {
+	v := h.Get("Grpc-Status")
+	if v == "" || v == "0" {
+		return nil
+	}
+	code, err := strconv.Atoi(v)
+	if err != nil {
+		return &Error{CodeUnknown, "invalid status " + v}
+	}
+	msg, err := url.PathUnescape(h.Get("Grpc-Message"))
+	if err != nil {
+		msg = h.Get("Grpc-Message")
+	}
+	return &Error{Code(code), msg}
+}
+
+// finish reads the rest of resp and returns the status of the call.
+func finish(resp *http.Response) error {
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.Trailer.Get("Grpc-Status") == "" {
+		return &Error{CodeUnknown, "missing status"}
+	}
+	return statusFrom(resp.Trailer)
+}
+
+// unary calls a method taking and returning one message.
+func (c *Client) unary(ctx context.Context, method string, req, resp message) error {
+	var body bytes.Buffer
+	writeFrame(&body, req)
+	r, err := c.call(ctx, method, &body)
+	if err != nil {
+		return err
+	}
+	frameErr := readFrame(r.Body, resp)
+	if err := finish(r); err != nil {
+		return err
+	}
+	if frameErr != nil {
+		return fmt.Errorf("agentfsgrpc: %s: %w", method, frameErr)
+	}
+	return nil
+}
+
+// Stat returns the stats of the file at p, following symlinks.
+func (c *Client) Stat(ctx context.Context, p string) (*agentfs.Stats, error) {
+	var resp statsMsg
+	if err := c.unary(ctx, "Stat", &pathRequest{path: p}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Stats, nil
+}
+
+// Readdir returns the entries of the directory at p with their stats.
+func (c *Client) Readdir(ctx context.Context, p string) ([]agentfs.DirEntry, error) {
+	var resp readdirResponse
+	if err := c.unary(ctx, "Readdir", &pathRequest{path: p}, &resp); err != nil {
+		return nil, err
+	}
+	entries := make([]agentfs.DirEntry, len(resp.entries))
+	for i, e := range resp.entries {
+		stats := e.stats.Stats
+		entries[i] = agentfs.DirEntry{Name: e.name, Stats: &stats}
+	}
+	return entries, nil
+}
+
+// ReadFile streams the content of the file at p. The caller must close the
+// reader; reading it returns the call's error, if any, in place of io.EOF.
+func (c *Client) ReadFile(ctx context.Context, p string) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	writeFrame(&body, &pathRequest{path: p})
+	resp, err := c.call(ctx, "ReadFile", &body)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkReader{resp: resp}, nil
+}
+
+// ReadRange streams length bytes of the file at p from offset, or the rest
+// of the file if length is 0, like ReadFile.
+func (c *Client) ReadRange(ctx context.Context, p string, offset, length int64) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	writeFrame(&body, &readRangeRequest{path: p, offset: offset, length: length})
+	resp, err := c.call(ctx, "ReadRange", &body)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkReader{resp: resp}, nil
+}
+
+// Checksums returns the block checksums of the file at p, as
+// agentfs.Filesystem.Checksums does; blockSize 0 means 1 MiB.
+func (c *Client) Checksums(ctx context.Context, p string, blockSize int64) ([]agentfs.BlockChecksum, error) {
+	var resp checksumsResponse
+	if err := c.unary(ctx, "Checksums", &checksumsRequest{path: p, blockSize: blockSize}, &resp); err != nil {
+		return nil, err
+	}
+	sums := make([]agentfs.BlockChecksum, len(resp.blocks))
+	for i, b := range resp.blocks {
+		sums[i] = b.BlockChecksum
+	}
+	return sums, nil
+}
+
+// WriteBlock writes data at offset in the file at p, creating it if needed,
+// after the server has verified it against the hex-encoded SHA-256
+// checksum; an empty checksum skips verification. A mismatch fails with
+// CodeDataLoss and writes nothing, so the block can be sent again. data
+// must fit in one message of 4 MiB.
+func (c *Client) WriteBlock(ctx context.Context, p string, offset int64, data []byte, checksum string) error {
+	return c.unary(ctx, "WriteBlock", &writeBlockRequest{path: p, offset: offset, data: data, sha256: checksum}, &empty{})
+}
+
+// chunkReader reads the messages of a ReadFile or ReadRange call.
+type chunkReader struct {
+	resp *http.Response
+	buf  []byte
+	err  error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		var c chunk
+		if err := readFrame(r.resp.Body, &c); err == io.EOF {
+			r.err = finish(r.resp)
+			if r.err == nil {
+				r.err = io.EOF
+			}
+		} else if err != nil {
+			r.err = err
+		}
+		r.buf = c.data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	return r.resp.Body.Close()
+}
+
+// WriteFile streams the content of src into the file at p, creating it and
+// missing parents, or replacing it, and returns the bytes written. mode 0
+// means 0644.
+func (c *Client) WriteFile(ctx context.Context, p string, src io.Reader, mode int64) (int64, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		err := writeFrame(pw, &writeRequest{path: p, mode: mode})
+		buf := make([]byte, readChunkSize)
+		for err == nil {
+			var n int
+			n, err = src.Read(buf)
+			if n > 0 {
+				if werr := writeFrame(pw, &writeRequest{data: buf[:n]}); werr != nil {
+					err = werr
+				}
+			}
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		pw.CloseWithError(err)
+	}()
+
+	resp, err := c.call(ctx, "WriteFile", pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		return 0, err
+	}
+	var out writeResponse
+	frameErr := readFrame(resp.Body, &out)
+	if err := finish(resp); err != nil {
+		return 0, err
+	}
+	if frameErr != nil {
+		return 0, fmt.Errorf("agentfsgrpc: WriteFile: %w", frameErr)
+	}
+	return out.size, nil
+}
+
+// Mkdir creates the directory at p; mode 0 means 0755. With parents, it
+// also creates missing parents and succeeds if the directory exists.
+func (c *Client) Mkdir(ctx context.Context, p string, mode int64, parents bool) error {
+	return c.unary(ctx, "Mkdir", &mkdirRequest{path: p, mode: mode, parents: parents}, &empty{})
+}
+
+// Remove removes the file or empty directory at p.
+func (c *Client) Remove(ctx context.Context, p string) error {
+	return c.unary(ctx, "Remove", &pathRequest{path: p}, &empty{})
+}
+
+// Rename moves the file or directory at oldPath to newPath.
+func (c *Client) Rename(ctx context.Context, oldPath, newPath string) error {
+	return c.unary(ctx, "Rename", &renameRequest{oldPath: oldPath, newPath: newPath}, &empty{})
+}
+
+// KVGet returns the JSON value of key.
+func (c *Client) KVGet(ctx context.Context, key string) (json.RawMessage, error) {
+	var resp kvValue
+	if err := c.unary(ctx, "KVGet", &kvMsg{key: key}, &resp); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(resp.json), nil
+}
+
+// KVSet stores value, encoded as JSON, at key.
+func (c *Client) KVSet(ctx context.Context, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	return c.unary(ctx, "KVSet", &kvMsg{key: key, value: string(data)}, &empty{})
+}
+
+// KVDelete removes key.
+func (c *Client) KVDelete(ctx context.Context, key string) error {
+	return c.unary(ctx, "KVDelete", &kvMsg{key: key}, &empty{})
+}
+
+// RecordToolCall records a completed tool call, like ToolCalls.Record;
+// parameters and result are encoded as JSON unless nil.
+func (c *Client) RecordToolCall(ctx context.Context, name string, parameters, result any, errMsg *string, startedAt, completedAt int64) (*agentfs.ToolCall, error) {
+	req := toolCallMsg{agentfs.ToolCall{Name: name, Error: errMsg, StartedAt: startedAt, CompletedAt: completedAt}}
+	var err error
+	if parameters != nil {
+		if req.Parameters, err = json.Marshal(parameters); err != nil {
+			return nil, fmt.Errorf("failed to marshal parameters: %w", err)
+		}
+	}
+	if result != nil {
+		if req.Result, err = json.Marshal(result); err != nil {
+			return nil, fmt.Errorf("failed to marshal result: %w", err)
+		}
+	}
+	var resp toolCallMsg
+	if err := c.unary(ctx, "RecordToolCall", &req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.ToolCall, nil
+}
+
+// GetToolCall returns the tool call id.
+func (c *Client) GetToolCall(ctx context.Context, id int64) (*agentfs.ToolCall, error) {
+	var resp toolCallMsg
+	if err := c.unary(ctx, "GetToolCall", &toolCallRequest{id: id}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.ToolCall, nil
+}
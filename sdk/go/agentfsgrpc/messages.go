@@ -0,0 +1,461 @@
+package agentfsgrpc
+
+import (
+	"encoding/json"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// The messages of agentfs.proto.
+
+type empty struct{}
+
+func (*empty) marshal(*encoder) {}
+
+func (*empty) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+	}
+	return d.err
+}
+
+type pathRequest struct {
+	path string
+}
+
+func (m *pathRequest) marshal(e *encoder) {
+	e.string(1, m.path)
+}
+
+func (m *pathRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		if d.field == 1 {
+			m.path = d.string()
+		}
+	}
+	return d.err
+}
+
+// statsMsg is the Stats message.
+type statsMsg struct {
+	agentfs.Stats
+}
+
+// fields lists the fields of s by number.
+func (m *statsMsg) fields() []*int64 {
+	s := &m.Stats
+	return []*int64{nil, &s.Ino, &s.Mode, &s.Nlink, &s.UID, &s.GID, &s.Size,
+		&s.Atime, &s.Mtime, &s.Ctime, &s.Rdev, &s.AtimeNsec, &s.MtimeNsec, &s.CtimeNsec}
+}
+
+func (m *statsMsg) marshal(e *encoder) {
+	for i, f := range m.fields() {
+		if f != nil {
+			e.int64(i, *f)
+		}
+	}
+}
+
+func (m *statsMsg) unmarshal(b []byte) error {
+	fields := m.fields()
+	d := decoder{b: b}
+	for d.next() {
+		if d.field > 0 && d.field < len(fields) {
+			*fields[d.field] = d.int64()
+		}
+	}
+	return d.err
+}
+
+type dirEntry struct {
+	name  string
+	stats statsMsg
+}
+
+func (m *dirEntry) marshal(e *encoder) {
+	e.string(1, m.name)
+	e.message(2, &m.stats)
+}
+
+func (m *dirEntry) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.name = d.string()
+		case 2:
+			d.message(&m.stats)
+		}
+	}
+	return d.err
+}
+
+type readdirResponse struct {
+	entries []dirEntry
+}
+
+func (m *readdirResponse) marshal(e *encoder) {
+	for i := range m.entries {
+		e.message(1, &m.entries[i])
+	}
+}
+
+func (m *readdirResponse) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		if d.field == 1 {
+			var entry dirEntry
+			d.message(&entry)
+			m.entries = append(m.entries, entry)
+		}
+	}
+	return d.err
+}
+
+type chunk struct {
+	data []byte
+}
+
+func (m *chunk) marshal(e *encoder) {
+	e.bytes(1, m.data)
+}
+
+func (m *chunk) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		if d.field == 1 {
+			m.data = d.bytes()
+		}
+	}
+	return d.err
+}
+
+type writeRequest struct {
+	path string
+	mode int64
+	data []byte
+}
+
+func (m *writeRequest) marshal(e *encoder) {
+	e.string(1, m.path)
+	e.int64(2, m.mode)
+	e.bytes(3, m.data)
+}
+
+func (m *writeRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.path = d.string()
+		case 2:
+			m.mode = d.int64()
+		case 3:
+			m.data = d.bytes()
+		}
+	}
+	return d.err
+}
+
+type writeResponse struct {
+	size int64
+}
+
+func (m *writeResponse) marshal(e *encoder) {
+	e.int64(1, m.size)
+}
+
+func (m *writeResponse) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		if d.field == 1 {
+			m.size = d.int64()
+		}
+	}
+	return d.err
+}
+
+type mkdirRequest struct {
+	path    string
+	mode    int64
+	parents bool
+}
+
+func (m *mkdirRequest) marshal(e *encoder) {
+	e.string(1, m.path)
+	e.int64(2, m.mode)
+	e.bool(3, m.parents)
+}
+
+func (m *mkdirRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.path = d.string()
+		case 2:
+			m.mode = d.int64()
+		case 3:
+			m.parents = d.bool()
+		}
+	}
+	return d.err
+}
+
+type renameRequest struct {
+	oldPath, newPath string
+}
+
+func (m *renameRequest) marshal(e *encoder) {
+	e.string(1, m.oldPath)
+	e.string(2, m.newPath)
+}
+
+func (m *renameRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.oldPath = d.string()
+		case 2:
+			m.newPath = d.string()
+		}
+	}
+	return d.err
+}
+
+type checksumsRequest struct {
+	path      string
+	blockSize int64
+}
+
+func (m *checksumsRequest) marshal(e *encoder) {
+	e.string(1, m.path)
+	e.int64(2, m.blockSize)
+}
+
+func (m *checksumsRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.path = d.string()
+		case 2:
+			m.blockSize = d.int64()
+		}
+	}
+	return d.err
+}
+
+// blockChecksum is the BlockChecksum message.
+type blockChecksum struct {
+	agentfs.BlockChecksum
+}
+
+func (m *blockChecksum) marshal(e *encoder) {
+	e.int64(1, m.Index)
+	e.int64(2, m.Offset)
+	e.int64(3, m.Size)
+	e.string(4, m.SHA256)
+}
+
+func (m *blockChecksum) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.Index = d.int64()
+		case 2:
+			m.Offset = d.int64()
+		case 3:
+			m.Size = d.int64()
+		case 4:
+			m.SHA256 = d.string()
+		}
+	}
+	return d.err
+}
+
+type checksumsResponse struct {
+	blocks []blockChecksum
+}
+
+func (m *checksumsResponse) marshal(e *encoder) {
+	for i := range m.blocks {
+		e.message(1, &m.blocks[i])
+	}
+}
+
+func (m *checksumsResponse) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		if d.field == 1 {
+			var block blockChecksum
+			d.message(&block)
+			m.blocks = append(m.blocks, block)
+		}
+	}
+	return d.err
+}
+
+type readRangeRequest struct {
+	path           string
+	offset, length int64
+}
+
+func (m *readRangeRequest) marshal(e *encoder) {
+	e.string(1, m.path)
+	e.int64(2, m.offset)
+	e.int64(3, m.length)
+}
+
+func (m *readRangeRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.path = d.string()
+		case 2:
+			m.offset = d.int64()
+		case 3:
+			m.length = d.int64()
+		}
+	}
+	return d.err
+}
+
+type writeBlockRequest struct {
+	path   string
+	offset int64
+	data   []byte
+	sha256 string
+}
+
+func (m *writeBlockRequest) marshal(e *encoder) {
+	e.string(1, m.path)
+	e.int64(2, m.offset)
+	e.bytes(3, m.data)
+	e.string(4, m.sha256)
+}
+
+func (m *writeBlockRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.path = d.string()
+		case 2:
+			m.offset = d.int64()
+		case 3:
+			m.data = d.bytes()
+		case 4:
+			m.sha256 = d.string()
+		}
+	}
+	return d.err
+}
+
+// kvMsg is the KVRequest and KVSetRequest messages; KVRequest has only the
+// key.
+type kvMsg struct {
+	key   string
+	value string
+}
+
+func (m *kvMsg) marshal(e *encoder) {
+	e.string(1, m.key)
+	e.string(2, m.value)
+}
+
+func (m *kvMsg) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.key = d.string()
+		case 2:
+			m.value = d.string()
+		}
+	}
+	return d.err
+}
+
+// kvValue is the KVValue message.
+type kvValue struct {
+	json string
+}
+
+func (m *kvValue) marshal(e *encoder) {
+	e.string(1, m.json)
+}
+
+func (m *kvValue) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		if d.field == 1 {
+			m.json = d.string()
+		}
+	}
+	return d.err
+}
+
+type toolCallRequest struct {
+	id int64
+}
+
+func (m *toolCallRequest) marshal(e *encoder) {
+	e.int64(1, m.id)
+}
+
+func (m *toolCallRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		if d.field == 1 {
+			m.id = d.int64()
+		}
+	}
+	return d.err
+}
+
+// toolCallMsg is the ToolCall message.
+type toolCallMsg struct {
+	agentfs.ToolCall
+}
+
+func (m *toolCallMsg) marshal(e *encoder) {
+	e.int64(1, m.ID)
+	e.string(2, m.Name)
+	e.bytes(3, m.Parameters)
+	e.bytes(4, m.Result)
+	e.optString(5, m.Error)
+	e.int64(6, m.StartedAt)
+	e.int64(7, m.CompletedAt)
+	e.int64(8, m.DurationMs)
+}
+
+func (m *toolCallMsg) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.field {
+		case 1:
+			m.ID = d.int64()
+		case 2:
+			m.Name = d.string()
+		case 3:
+			m.Parameters = json.RawMessage(d.bytes())
+		case 4:
+			m.Result = json.RawMessage(d.bytes())
+		case 5:
+			s := d.string()
+			m.Error = &s
+		case 6:
+			m.StartedAt = d.int64()
+		case 7:
+			m.CompletedAt = d.int64()
+		case 8:
+			m.DurationMs = d.int64()
+		}
+	}
+	return d.err
+}
@@ -0,0 +1,350 @@
+// Package agentfsgrpc serves an AgentFS over gRPC, so agents running in
+// containers can reach a central AgentFS daemon instead of sharing a SQLite
+// file. The service is defined in agentfs.proto; Server and Client speak it
+// with a small hand-written protobuf codec, keeping the SDK free of gRPC
+// dependencies, and interoperate with clients generated from the file.
+package agentfsgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// servicePath prefixes the path of every method.
+const servicePath = "/agentfs.v1.AgentFS/"
+
+// AgentHeader is the request metadata naming the agent a call is for.
+const AgentHeader = "agentfs-agent"
+
+// readChunkSize is the most data sent in one message of ReadFile.
+const readChunkSize = 64 << 10
+
+// Server serves the AgentFS service of agentfs.proto. It is an
+// http.Handler: gRPC runs over HTTP/2, which net/http serves over TLS, so
+// standard gRPC clients need ListenAndServeTLS (or a proxy speaking
+// cleartext HTTP/2 to them); Client also works over HTTP/1.1. The server
+// does no authentication of its own.
+//
+// Example:
+//
+//	srv := agentfsgrpc.NewMultiServer(func(ctx context.Context, agent string) (*agentfs.AgentFS, error) {
+//	    return agents.Get(ctx, agent) // Open and cache each agent's database
+//	})
+//	http.ListenAndServeTLS(":8443", "cert.pem", "key.pem", srv)
+type Server struct {
+	resolve func(ctx context.Context, agent string) (*agentfs.AgentFS, error)
+}
+
+// NewServer returns a Server for afs, whatever agent calls name.
+func NewServer(afs *agentfs.AgentFS) *Server {
+	return NewMultiServer(func(context.Context, string) (*agentfs.AgentFS, error) {
+		return afs, nil
+	})
+}
+
+// NewMultiServer returns a Server for many agents: resolve returns the
+// AgentFS of the agent named by a call's AgentHeader metadata, "" if it
+// has none. Errors it returns are sent to the caller.
+func NewMultiServer(resolve func(ctx context.Context, agent string) (*agentfs.AgentFS, error)) *Server {
+	return &Server{resolve: resolve}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	st := &Error{Code: CodeOK}
+	if err := s.serve(w, r); err != nil {
+		st = statusOf(err)
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(st.Code)))
+	if st.Message != "" {
+		w.Header().Set("Grpc-Message", url.PathEscape(st.Message))
+	}
+}
+
+// serve runs the method named by the path of r.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	method, ok := strings.CutPrefix(r.URL.Path, servicePath)
+	if !ok {
+		return &Error{CodeUnimplemented, "unknown service"}
+	}
+	afs, err := s.resolve(ctx, r.Header.Get(AgentHeader))
+	if err != nil {
+		return err
+	}
+
+	switch method {
+	case "Stat":
+		var req pathRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		stats, err := afs.FS.Stat(ctx, req.path)
+		if err != nil {
+			return err
+		}
+		return writeFrame(w, &statsMsg{*stats})
+	case "Readdir":
+		var req pathRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		entries, err := afs.FS.ReaddirPlus(ctx, req.path)
+		if err != nil {
+			return err
+		}
+		var resp readdirResponse
+		for _, e := range entries {
+			resp.entries = append(resp.entries, dirEntry{name: e.Name, stats: statsMsg{*e.Stats}})
+		}
+		return writeFrame(w, &resp)
+	case "ReadFile":
+		var req pathRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		return serveRead(ctx, w, afs, req.path, 0, 0)
+	case "Checksums":
+		var req checksumsRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		sums, err := afs.FS.Checksums(ctx, req.path, req.blockSize)
+		if err != nil {
+			return err
+		}
+		var resp checksumsResponse
+		for _, sum := range sums {
+			resp.blocks = append(resp.blocks, blockChecksum{sum})
+		}
+		return writeFrame(w, &resp)
+	case "ReadRange":
+		var req readRangeRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		if req.offset < 0 || req.length < 0 {
+			return &Error{CodeInvalidArgument, "negative offset or length"}
+		}
+		return serveRead(ctx, w, afs, req.path, req.offset, req.length)
+	case "WriteBlock":
+		var req writeBlockRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		if err := afs.FS.WriteBlock(ctx, req.path, req.offset, req.data, req.sha256); err != nil {
+			return err
+		}
+		return writeFrame(w, &empty{})
+	case "WriteFile":
+		return serveWrite(ctx, w, r.Body, afs)
+	case "Mkdir":
+		var req mkdirRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		if req.mode == 0 {
+			req.mode = 0o755
+		}
+		if req.parents {
+			err = afs.FS.MkdirAll(ctx, req.path, req.mode)
+		} else {
+			err = afs.FS.Mkdir(ctx, req.path, req.mode)
+		}
+		if err != nil {
+			return err
+		}
+		return writeFrame(w, &empty{})
+	case "Remove":
+		var req pathRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		stats, err := afs.FS.Lstat(ctx, req.path)
+		if err != nil {
+			return err
+		}
+		if stats.IsDir() {
+			err = afs.FS.Rmdir(ctx, req.path)
+		} else {
+			err = afs.FS.Unlink(ctx, req.path)
+		}
+		if err != nil {
+			return err
+		}
+		return writeFrame(w, &empty{})
+	case "Rename":
+		var req renameRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		if err := afs.FS.Rename(ctx, req.oldPath, req.newPath); err != nil {
+			return err
+		}
+		return writeFrame(w, &empty{})
+	case "KVGet":
+		var req kvMsg
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		value, err := afs.KV.GetRaw(ctx, req.key)
+		if err != nil {
+			return err
+		}
+		return writeFrame(w, &kvValue{json: string(value)})
+	case "KVSet":
+		var req kvMsg
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		if !json.Valid([]byte(req.value)) {
+			return &Error{CodeInvalidArgument, "value is not valid JSON"}
+		}
+		if err := afs.KV.Set(ctx, req.key, json.RawMessage(req.value)); err != nil {
+			return err
+		}
+		return writeFrame(w, &empty{})
+	case "KVDelete":
+		var req kvMsg
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		if err := afs.KV.Delete(ctx, req.key); err != nil {
+			return err
+		}
+		return writeFrame(w, &empty{})
+	case "RecordToolCall":
+		var req toolCallMsg
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		if req.Name == "" {
+			return &Error{CodeInvalidArgument, "tool call name is required"}
+		}
+		call, err := afs.Tools.Record(ctx, req.Name, rawOrNil(req.Parameters), rawOrNil(req.Result), req.Error, req.StartedAt, req.CompletedAt)
+		if err != nil {
+			return err
+		}
+		return writeFrame(w, &toolCallMsg{*call})
+	case "GetToolCall":
+		var req toolCallRequest
+		if err := readFrame(r.Body, &req); err != nil {
+			return requestError(err)
+		}
+		call, err := afs.Tools.Get(ctx, req.id)
+		if err != nil {
+			return err
+		}
+		return writeFrame(w, &toolCallMsg{*call})
+	default:
+		return &Error{CodeUnimplemented, "unknown method " + method}
+	}
+}
+
+// serveRead streams length bytes of the file at p from offset, or the rest
+// of the file if length is 0, in messages of up to readChunkSize.
+func serveRead(ctx context.Context, w http.ResponseWriter, afs *agentfs.AgentFS, p string, offset, length int64) error {
+	fr, err := afs.FS.OpenReader(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	if _, err := fr.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	var src io.Reader = fr
+	if length > 0 {
+		src = io.LimitReader(fr, length)
+	}
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, readChunkSize)
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if err := writeFrame(w, &chunk{data: buf[:n]}); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// serveWrite streams the messages of body into the file named by the
+// first. The file is replaced only once the stream ends cleanly; if it
+// breaks, the file keeps its previous content (see OpenStagedWriter).
+func serveWrite(ctx context.Context, w http.ResponseWriter, body io.Reader, afs *agentfs.AgentFS) error {
+	var req writeRequest
+	if err := readFrame(body, &req); err != nil {
+		return requestError(err)
+	}
+	if req.path == "" {
+		return &Error{CodeInvalidArgument, "the first message must name the file"}
+	}
+	p, mode := req.path, req.mode
+	fw, err := afs.FS.OpenStagedWriter(ctx, p)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := fw.Write(req.data); err != nil {
+			fw.Abort()
+			return err
+		}
+		req = writeRequest{}
+		if err := readFrame(body, &req); err == io.EOF {
+			break
+		} else if err != nil {
+			fw.Abort()
+			return err
+		}
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	if mode != 0 && mode != 0o644 {
+		if err := afs.FS.Chmod(ctx, p, mode); err != nil {
+			return err
+		}
+	}
+	return writeFrame(w, &writeResponse{size: fw.Written()})
+}
+
+// requestError reports a missing request message as invalid.
+func requestError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return &Error{CodeInvalidArgument, "missing request message"}
+	}
+	return err
+}
+
+// rawOrNil returns raw, or nil if it is empty, so Record stores no value.
+func rawOrNil(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return raw
+}
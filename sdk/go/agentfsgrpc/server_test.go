@@ -0,0 +1,189 @@
+package agentfsgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func setupTestDB(t *testing.T) *agentfs.AgentFS {
+	t.Helper()
+	afs, err := agentfs.Open(context.Background(), agentfs.AgentFSOptions{
+		Path: filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	return afs
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	srv := httptest.NewUnstartedServer(NewServer(afs))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	c := NewClient(srv.URL, "worker-1", srv.Client())
+
+	// Streaming writes and reads span several messages
+	data := strings.Repeat("0123456789", 20_000)
+	n, err := c.WriteFile(ctx, "/out/data.txt", strings.NewReader(data), 0o600)
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("WriteFile = %d, %v; want %d", n, err, len(data))
+	}
+	r, err := c.ReadFile(ctx, "/out/data.txt")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(got) != data {
+		t.Errorf("ReadFile returned %d bytes, %v; want %d", len(got), err, len(data))
+	}
+	if stats, err := c.Stat(ctx, "/out/data.txt"); err != nil || stats.Size != int64(len(data)) || stats.Mode&0o777 != 0o600 {
+		t.Errorf("Stat = %+v, %v", stats, err)
+	}
+
+	// Resumable transfer: read a range, then upload only the missing block
+	r, err = c.ReadRange(ctx, "/out/data.txt", 100_005, 10)
+	if err != nil {
+		t.Fatalf("ReadRange failed: %v", err)
+	}
+	got, err = io.ReadAll(r)
+	r.Close()
+	if err != nil || string(got) != "5678901234" {
+		t.Errorf("ReadRange = %q, %v", got, err)
+	}
+	block := []byte(data[:1000])
+	if err := c.WriteBlock(ctx, "/up.txt", 0, block, ""); err != nil {
+		t.Fatalf("WriteBlock failed: %v", err)
+	}
+	want, err := afs.FS.Checksums(ctx, "/out/data.txt", 1000)
+	if err != nil {
+		t.Fatalf("Checksums failed: %v", err)
+	}
+	have, err := c.Checksums(ctx, "/up.txt", 1000)
+	if err != nil || len(have) != 1 || have[0] != want[0] {
+		t.Fatalf("Checksums = %+v, %v; want %+v", have, err, want[:1])
+	}
+	var st *Error
+	if err := c.WriteBlock(ctx, "/up.txt", 1000, block, "00"); !errors.As(err, &st) || st.Code != CodeDataLoss {
+		t.Errorf("WriteBlock with a bad checksum = %v, want CodeDataLoss", err)
+	}
+	if err := c.WriteBlock(ctx, "/up.txt", 1000, []byte(data[1000:2000]), want[1].SHA256); err != nil {
+		t.Errorf("WriteBlock failed: %v", err)
+	}
+	if stats, err := c.Stat(ctx, "/up.txt"); err != nil || stats.Size != 2000 {
+		t.Errorf("Stat after WriteBlock = %+v, %v", stats, err)
+	}
+
+	if err := c.Mkdir(ctx, "/a/b", 0, true); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := c.Rename(ctx, "/out/data.txt", "/a/data.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	entries, err := c.Readdir(ctx, "/a")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Readdir = %+v, %v", entries, err)
+	}
+	if err := c.Remove(ctx, "/a/data.txt"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := c.Stat(ctx, "/a/data.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of a removed file = %v, want fs.ErrNotExist", err)
+	}
+	if err := c.Mkdir(ctx, "/a", 0, false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Mkdir of an existing directory = %v, want fs.ErrExist", err)
+	}
+
+	// KV
+	if err := c.KVSet(ctx, "config", map[string]bool{"debug": true}); err != nil {
+		t.Fatalf("KVSet failed: %v", err)
+	}
+	if v, err := c.KVGet(ctx, "config"); err != nil || string(v) != `{"debug":true}` {
+		t.Errorf("KVGet = %s, %v", v, err)
+	}
+	c.KVDelete(ctx, "config")
+	if _, err := c.KVGet(ctx, "config"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("KVGet of a deleted key = %v, want fs.ErrNotExist", err)
+	}
+
+	// Tool calls
+	call, err := c.RecordToolCall(ctx, "search", map[string]string{"q": "go"}, []string{"a"}, nil, 10, 11)
+	if err != nil {
+		t.Fatalf("RecordToolCall failed: %v", err)
+	}
+	got2, err := c.GetToolCall(ctx, call.ID)
+	if err != nil || got2.Name != "search" || string(got2.Parameters) != `{"q":"go"}` || got2.Error != nil {
+		t.Errorf("GetToolCall = %+v, %v", got2, err)
+	}
+}
+
+func TestMultiServer(t *testing.T) {
+	ctx := context.Background()
+	agents := map[string]*agentfs.AgentFS{"a": setupTestDB(t), "b": setupTestDB(t)}
+	for _, afs := range agents {
+		defer afs.Close()
+	}
+	srv := httptest.NewServer(NewMultiServer(func(ctx context.Context, agent string) (*agentfs.AgentFS, error) {
+		if afs, ok := agents[agent]; ok {
+			return afs, nil
+		}
+		return nil, &Error{CodeNotFound, "unknown agent " + agent}
+	}))
+	defer srv.Close()
+
+	if err := NewClient(srv.URL, "a", nil).KVSet(ctx, "owner", "a"); err != nil {
+		t.Fatalf("KVSet failed: %v", err)
+	}
+	if _, err := NewClient(srv.URL, "b", nil).KVGet(ctx, "owner"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("KVGet on another agent = %v, want fs.ErrNotExist", err)
+	}
+	var st *Error
+	if err := NewClient(srv.URL, "c", nil).KVSet(ctx, "owner", "c"); !errors.As(err, &st) || st.Message != "unknown agent c" {
+		t.Errorf("KVSet on an unknown agent = %v", err)
+	}
+}
+
+// brokenReader fails after its data, like a client cut off midway.
+type brokenReader struct{ io.Reader }
+
+func (r brokenReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		err = errors.New("connection lost")
+	}
+	return n, err
+}
+
+func TestServer_BrokenWrite(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	afs.FS.WriteFile(ctx, "/data.txt", []byte("old"), 0o644)
+
+	srv := httptest.NewUnstartedServer(NewServer(afs))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	c := NewClient(srv.URL, "", srv.Client())
+
+	src := brokenReader{strings.NewReader(strings.Repeat("new ", 100_000))}
+	if _, err := c.WriteFile(ctx, "/data.txt", src, 0); err == nil {
+		t.Fatal("WriteFile of a broken stream succeeded")
+	}
+	if got, err := afs.FS.ReadFile(ctx, "/data.txt"); err != nil || string(got) != "old" {
+		t.Errorf("ReadFile after a broken write = %d bytes, %v; want the old content", len(got), err)
+	}
+}
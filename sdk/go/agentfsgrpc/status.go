@@ -0,0 +1,119 @@
+package agentfsgrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// Code is a gRPC status code.
+type Code int
+
+// The status codes the service returns.
+const (
+	CodeOK                 Code = 0
+	CodeCanceled           Code = 1
+	CodeUnknown            Code = 2
+	CodeInvalidArgument    Code = 3
+	CodeNotFound           Code = 5
+	CodeAlreadyExists      Code = 6
+	CodePermissionDenied   Code = 7
+	CodeFailedPrecondition Code = 9
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnavailable        Code = 14
+	CodeDataLoss           Code = 15
+)
+
+// Error is a gRPC status other than OK. Errors with CodeNotFound and
+// CodeAlreadyExists match fs.ErrNotExist and fs.ErrExist under errors.Is.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("agentfsgrpc: status %d: %s", e.Code, e.Message)
+}
+
+// Is reports whether the status corresponds to target.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.Code == CodeNotFound
+	case fs.ErrExist:
+		return e.Code == CodeAlreadyExists
+	}
+	return false
+}
+
+// statusOf maps an AgentFS error to a gRPC status.
+func statusOf(err error) *Error {
+	var st *Error
+	if errors.As(err, &st) {
+		return st
+	}
+	var fsErr *agentfs.FSError
+	var mismatch *agentfs.ErrChecksumMismatch
+	msg := err.Error()
+	switch {
+	case errors.As(err, &mismatch):
+		return &Error{CodeDataLoss, msg}
+	case agentfs.IsNotExist(err), strings.HasPrefix(msg, "key not found:"), strings.HasPrefix(msg, "tool call not found:"):
+		return &Error{CodeNotFound, msg}
+	case agentfs.IsExist(err):
+		return &Error{CodeAlreadyExists, msg}
+	case agentfs.IsPermission(err), errors.Is(err, agentfs.ErrReserved):
+		return &Error{CodePermissionDenied, msg}
+	case errors.As(err, &fsErr):
+		return &Error{CodeFailedPrecondition, msg}
+	case errors.Is(err, agentfs.ErrClosed):
+		return &Error{CodeUnavailable, msg}
+	default:
+		return &Error{CodeInternal, msg}
+	}
+}
+
+// maxMessage is the largest message accepted, as in gRPC implementations.
+const maxMessage = 4 << 20
+
+// writeFrame writes m as a length-prefixed message.
+func writeFrame(w io.Writer, m message) error {
+	b := encode(m)
+	frame := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+	_, err := w.Write(append(frame, b...))
+	return err
+}
+
+// readFrame reads the next length-prefixed message into m. It returns
+// io.EOF at the end of the stream.
+func readFrame(r io.Reader, m message) error {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return &Error{CodeInvalidArgument, "truncated message"}
+		}
+		return err
+	}
+	if header[0] != 0 {
+		return &Error{CodeUnimplemented, "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxMessage {
+		return &Error{CodeInvalidArgument, fmt.Sprintf("message of %d bytes exceeds %d", size, maxMessage)}
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return &Error{CodeInvalidArgument, "truncated message"}
+	}
+	if err := m.unmarshal(b); err != nil {
+		return &Error{CodeInvalidArgument, err.Error()}
+	}
+	return nil
+}
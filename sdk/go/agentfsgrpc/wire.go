@@ -0,0 +1,179 @@
+package agentfsgrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protobuf wire types (see https://protobuf.dev/programming-guides/encoding/)
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// errTruncated is returned for a message that ends within a field.
+var errTruncated = errors.New("agentfsgrpc: truncated message")
+
+// encoder appends fields in the protobuf wire format. Fields holding their
+// zero value are omitted, as proto3 does, except optional ones.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) tag(field, wire int) {
+	e.b = binary.AppendUvarint(e.b, uint64(field)<<3|uint64(wire))
+}
+
+func (e *encoder) int64(field int, v int64) {
+	if v != 0 {
+		e.tag(field, wireVarint)
+		e.b = binary.AppendUvarint(e.b, uint64(v))
+	}
+}
+
+func (e *encoder) bool(field int, v bool) {
+	if v {
+		e.tag(field, wireVarint)
+		e.b = append(e.b, 1)
+	}
+}
+
+func (e *encoder) bytes(field int, v []byte) {
+	if len(v) > 0 {
+		e.tag(field, wireLen)
+		e.b = binary.AppendUvarint(e.b, uint64(len(v)))
+		e.b = append(e.b, v...)
+	}
+}
+
+func (e *encoder) string(field int, v string) {
+	if v != "" {
+		e.tag(field, wireLen)
+		e.b = binary.AppendUvarint(e.b, uint64(len(v)))
+		e.b = append(e.b, v...)
+	}
+}
+
+// optString encodes an optional field, which is present even when empty.
+func (e *encoder) optString(field int, v *string) {
+	if v != nil {
+		e.tag(field, wireLen)
+		e.b = binary.AppendUvarint(e.b, uint64(len(*v)))
+		e.b = append(e.b, *v...)
+	}
+}
+
+// message encodes a nested message, which is present even when empty.
+func (e *encoder) message(field int, m message) {
+	var sub encoder
+	m.marshal(&sub)
+	e.tag(field, wireLen)
+	e.b = binary.AppendUvarint(e.b, uint64(len(sub.b)))
+	e.b = append(e.b, sub.b...)
+}
+
+// decoder reads the fields of a message in turn:
+//
+//	d := decoder{b: data}
+//	for d.next() {
+//	    switch d.field {
+//	    case 1:
+//	        m.path = d.string()
+//	    }
+//	}
+//	return d.err
+//
+// Fields of unknown numbers are skipped.
+type decoder struct {
+	b     []byte
+	field int
+	wire  int
+	val   uint64 // Of a varint field
+	data  []byte // Of a length-delimited field
+	err   error
+}
+
+// next reads the next field, reporting false at the end of the message or
+// on an error.
+func (d *decoder) next() bool {
+	if d.err != nil || len(d.b) == 0 {
+		return false
+	}
+	key, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errTruncated
+		return false
+	}
+	d.b = d.b[n:]
+	d.field, d.wire = int(key>>3), int(key&7)
+	switch d.wire {
+	case wireVarint:
+		d.val, n = binary.Uvarint(d.b)
+		if n <= 0 {
+			d.err = errTruncated
+			return false
+		}
+		d.b = d.b[n:]
+	case wireI64, wireI32:
+		size := 8
+		if d.wire == wireI32 {
+			size = 4
+		}
+		if len(d.b) < size {
+			d.err = errTruncated
+			return false
+		}
+		d.b = d.b[size:]
+	case wireLen:
+		size, n := binary.Uvarint(d.b)
+		if n <= 0 || uint64(len(d.b)-n) < size {
+			d.err = errTruncated
+			return false
+		}
+		d.data = d.b[n : n+int(size)]
+		d.b = d.b[n+int(size):]
+	default:
+		d.err = fmt.Errorf("agentfsgrpc: unsupported wire type %d", d.wire)
+		return false
+	}
+	return true
+}
+
+func (d *decoder) int64() int64 {
+	return int64(d.val)
+}
+
+func (d *decoder) bool() bool {
+	return d.val != 0
+}
+
+func (d *decoder) string() string {
+	return string(d.data)
+}
+
+func (d *decoder) bytes() []byte {
+	return append([]byte(nil), d.data...)
+}
+
+// message decodes a nested message into m.
+func (d *decoder) message(m message) {
+	if err := m.unmarshal(d.data); err != nil && d.err == nil {
+		d.err = err
+	}
+}
+
+// message is a protobuf message of agentfs.proto.
+type message interface {
+	marshal(e *encoder)
+	unmarshal(b []byte) error
+}
+
+// encode returns m in the wire format.
+func encode(m message) []byte {
+	var e encoder
+	m.marshal(&e)
+	return e.b
+}
@@ -0,0 +1,79 @@
+package agentfsgrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func TestWire_RoundTrip(t *testing.T) {
+	errMsg := ""
+	call := toolCallMsg{agentfs.ToolCall{
+		ID: 7, Name: "search", Parameters: json.RawMessage(`{"q":"go"}`),
+		Error: &errMsg, StartedAt: -1, CompletedAt: 1 << 40,
+	}}
+	var gotCall toolCallMsg
+	if err := gotCall.unmarshal(encode(&call)); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotCall, call) {
+		t.Errorf("ToolCall = %+v, want %+v", gotCall, call)
+	}
+
+	dir := readdirResponse{entries: []dirEntry{
+		{name: "a.txt", stats: statsMsg{agentfs.Stats{Ino: 2, Mode: agentfs.S_IFREG | 0o644, Size: 3}}},
+		{name: "empty"},
+	}}
+	var gotDir readdirResponse
+	if err := gotDir.unmarshal(encode(&dir)); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotDir, dir) {
+		t.Errorf("ReaddirResponse = %+v, want %+v", gotDir, dir)
+	}
+}
+
+func TestWire_SkipsUnknownFields(t *testing.T) {
+	var e encoder
+	e.string(1, "/a")
+	e.int64(9, 42)
+	e.bytes(10, []byte("x"))
+	e.b = append(e.b, 11<<3|wireI64, 1, 2, 3, 4, 5, 6, 7, 8)
+	var req pathRequest
+	if err := req.unmarshal(e.b); err != nil || req.path != "/a" {
+		t.Errorf("unmarshal = %q, %v; want /a", req.path, err)
+	}
+
+	if err := req.unmarshal(e.b[:len(e.b)-1]); !errors.Is(err, errTruncated) {
+		t.Errorf("unmarshal of a truncated message = %v, want errTruncated", err)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	writeFrame(&buf, &chunk{data: []byte("hello")})
+	writeFrame(&buf, &chunk{})
+
+	var c chunk
+	if err := readFrame(&buf, &c); err != nil || string(c.data) != "hello" {
+		t.Errorf("readFrame = %q, %v", c.data, err)
+	}
+	c = chunk{}
+	if err := readFrame(&buf, &c); err != nil || c.data != nil {
+		t.Errorf("readFrame of an empty message = %q, %v", c.data, err)
+	}
+	if err := readFrame(&buf, &c); err != io.EOF {
+		t.Errorf("readFrame at the end = %v, want io.EOF", err)
+	}
+
+	buf.Write([]byte{1, 0, 0, 0, 0})
+	var st *Error
+	if err := readFrame(&buf, &c); !errors.As(err, &st) || st.Code != CodeUnimplemented {
+		t.Errorf("readFrame of a compressed message = %v, want CodeUnimplemented", err)
+	}
+}
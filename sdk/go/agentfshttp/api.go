@@ -0,0 +1,385 @@
+package agentfshttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// MaxAPIUpload is the largest body accepted by APIHandler.
+var MaxAPIUpload int64 = 64 << 20
+
+// APIHandler serves the filesystem, KV store, and tool call history of afs
+// as a JSON/HTTP API, so agent runtimes in other languages can share the
+// database through a sidecar:
+//
+//	GET    /fs/{path}       file content, honoring Range headers, or a page
+//	                        of a directory as JSON (after, limit);
+//	                        ?stat=true for the stats as JSON;
+//	                        ?checksums=true for the block checksums as JSON
+//	                        (block_size, see agentfs.Filesystem.Checksums)
+//	PUT    /fs/{path}       write the body to the file (mode, default 644);
+//	                        with ?offset=N, write it as a block at offset N,
+//	                        verified against ?sha256= if given, to resume an
+//	                        upload; a path ending in / creates the directory
+//	DELETE /fs/{path}       remove a file or empty directory
+//	GET    /kv?prefix=      list entries as JSON
+//	GET    /kv/{key}        the JSON value
+//	PUT    /kv/{key}        set the JSON body as the value
+//	DELETE /kv/{key}        delete the key
+//	GET    /tools           a page of calls as JSON (name, status, since,
+//	                        until, limit, cursor, collapse_retries)
+//	POST   /tools           record the call in the JSON body
+//	GET    /tools/{id}      the call as JSON
+//	GET    /tools/{id}/output  the output appended while it ran
+//
+// Keys are path-escaped, so they may contain slashes as %2F. Writes return
+// 204 No Content, except POST /tools, which returns 201 Created with the
+// recorded call. The handler does no authentication: it grants full access
+// to afs, so Server does not mount it. Serve it on a loopback address or
+// behind an authenticating proxy.
+//
+// Example:
+//
+//	http.Handle("/api/", http.StripPrefix("/api", agentfshttp.APIHandler(afs)))
+//
+//	// curl -X PUT --data-binary @notes.md localhost:8080/api/fs/notes/plan.md
+//	// curl localhost:8080/api/kv/config
+func APIHandler(afs *agentfs.AgentFS) http.Handler {
+	a := &api{afs: afs}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fs/", a.serveFS)
+	mux.HandleFunc("/kv", a.serveKV)
+	mux.HandleFunc("/kv/", a.serveKV)
+	mux.HandleFunc("/tools", a.serveTools)
+	mux.HandleFunc("/tools/", a.serveToolCall)
+	return mux
+}
+
+// api serves the endpoints of APIHandler.
+type api struct {
+	afs *agentfs.AgentFS
+}
+
+func (a *api) serveFS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p := "/" + strings.TrimPrefix(r.URL.Path, "/fs/")
+	q := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		stats, err := a.afs.FS.Stat(ctx, p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if q.Get("stat") == "true" {
+			writeJSON(w, http.StatusOK, stats)
+			return
+		}
+		if q.Get("checksums") == "true" {
+			blockSize, err := intParam(q, "block_size")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			sums, err := a.afs.FS.Checksums(ctx, p, int64(blockSize))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, sums)
+			return
+		}
+		if stats.IsDir() {
+			opts := agentfs.ReaddirOptions{After: q.Get("after")}
+			if opts.Limit, err = intParam(q, "limit"); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			page, err := a.afs.FS.ReaddirPage(ctx, p, opts)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, page)
+			return
+		}
+		fr, err := a.afs.FS.OpenReader(ctx, p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer fr.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", fr.Stat().MtimeTime(), fr)
+	case http.MethodPut:
+		if strings.HasSuffix(p, "/") {
+			if err := a.afs.FS.MkdirAll(ctx, p, 0o755); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		mode := int64(0o644)
+		if v := q.Get("mode"); v != "" {
+			m, err := strconv.ParseInt(v, 8, 64)
+			if err != nil {
+				http.Error(w, "invalid mode", http.StatusBadRequest)
+				return
+			}
+			mode = m
+		}
+		data, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		if v := q.Get("offset"); v != "" {
+			offset, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid offset", http.StatusBadRequest)
+				return
+			}
+			err = a.afs.FS.WriteBlock(ctx, p, offset, data, q.Get("sha256"))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := a.afs.FS.WriteFile(ctx, p, data, mode); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		stats, err := a.afs.FS.Lstat(ctx, p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if stats.IsDir() {
+			err = a.afs.FS.Rmdir(ctx, p)
+		} else {
+			err = a.afs.FS.Unlink(ctx, p)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *api) serveKV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key, err := url.PathUnescape(strings.TrimPrefix(strings.TrimPrefix(r.URL.EscapedPath(), "/kv"), "/"))
+	if err != nil {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+	if key == "" {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		entries, err := a.afs.KV.List(ctx, r.URL.Query().Get("prefix"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if entries == nil {
+			entries = []agentfs.KVEntry{}
+		}
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		value, err := a.afs.KV.GetRaw(ctx, key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(value)
+	case http.MethodPut:
+		data, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		if !json.Valid(data) {
+			http.Error(w, "value is not valid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := a.afs.KV.Set(ctx, key, json.RawMessage(data)); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := a.afs.KV.Delete(ctx, key); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// apiToolCall is the body of POST /tools.
+type apiToolCall struct {
+	Name        string          `json:"name"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       *string         `json:"error,omitempty"`
+	StartedAt   int64           `json:"started_at"`
+	CompletedAt int64           `json:"completed_at"`
+}
+
+func (a *api) serveTools(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		q := r.URL.Query()
+		query := agentfs.ToolCallQuery{
+			ToolName: q.Get("name"),
+			Status:   q.Get("status"),
+			Cursor:   q.Get("cursor"),
+		}
+		var limit, since, until int
+		for name, dst := range map[string]*int{"limit": &limit, "since": &since, "until": &until} {
+			n, err := intParam(q, name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+		query.Limit, query.Since, query.Until = limit, int64(since), int64(until)
+		if v := q.Get("collapse_retries"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "invalid collapse_retries", http.StatusBadRequest)
+				return
+			}
+			query.CollapseRetries = b
+		}
+		page, err := a.afs.Tools.Query(ctx, query)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if page.Calls == nil {
+			page.Calls = []agentfs.ToolCall{}
+		}
+		writeJSON(w, http.StatusOK, page)
+	case http.MethodPost:
+		data, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		var body apiToolCall
+		if err := json.Unmarshal(data, &body); err != nil || body.Name == "" {
+			http.Error(w, "invalid tool call: name is required", http.StatusBadRequest)
+			return
+		}
+		call, err := a.afs.Tools.Record(ctx, body.Name, rawOrNil(body.Parameters), rawOrNil(body.Result), body.Error, body.StartedAt, body.CompletedAt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, call)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *api) serveToolCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rest, output := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/tools/"), "/output")
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid tool call ID", http.StatusBadRequest)
+		return
+	}
+	call, err := a.afs.Tools.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !output {
+		writeJSON(w, http.StatusOK, call)
+		return
+	}
+	data, err := a.afs.Tools.Output(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// writeJSON sends v as the JSON body of a response with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// readBody reads a request body of up to MaxAPIUpload bytes, or sends an
+// error and returns false.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxAPIUpload+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if int64(len(data)) > MaxAPIUpload {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return data, true
+}
+
+// intParam parses the query parameter name, which defaults to 0.
+func intParam(q url.Values, name string) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return n, nil
+}
+
+// rawOrNil returns raw, or nil if it is empty, so Record stores no value.
+func rawOrNil(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return raw
+}
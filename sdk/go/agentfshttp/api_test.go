@@ -0,0 +1,117 @@
+package agentfshttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func TestAPIHandler(t *testing.T) {
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	srv := httptest.NewServer(APIHandler(afs))
+	defer srv.Close()
+
+	do := func(method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	// Filesystem
+	if code, _ := do("PUT", "/fs/notes/plan.md", "# Plan"); code != http.StatusNoContent {
+		t.Errorf("PUT file = %d", code)
+	}
+	if code, body := do("GET", "/fs/notes/plan.md", ""); code != http.StatusOK || body != "# Plan" {
+		t.Errorf("GET file = %d %q", code, body)
+	}
+	code, body := do("GET", "/fs/notes", "")
+	var page agentfs.DirPage
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &page) != nil || len(page.Entries) != 1 || page.Entries[0].Name != "plan.md" {
+		t.Errorf("GET dir = %d %s", code, body)
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/fs/notes/plan.md", nil)
+	req.Header.Set("Range", "bytes=2-")
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Errorf("ranged GET failed: %v", err)
+	} else {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusPartialContent || string(data) != "Plan" {
+			t.Errorf("ranged GET = %d %q, want 206 \"Plan\"", resp.StatusCode, data)
+		}
+	}
+	if code, _ := do("DELETE", "/fs/notes/plan.md", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d", code)
+	}
+	if code, _ := do("GET", "/fs/notes/plan.md", ""); code != http.StatusNotFound {
+		t.Errorf("GET removed file = %d, want 404", code)
+	}
+
+	// Resumable upload: fetch the checksums, then send the missing block
+	if code, _ := do("PUT", "/fs/up.bin?offset=0", "abcd"); code != http.StatusNoContent {
+		t.Errorf("PUT block = %d", code)
+	}
+	var sums []agentfs.BlockChecksum
+	code, body = do("GET", "/fs/up.bin?checksums=true&block_size=4", "")
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &sums) != nil || len(sums) != 1 || sums[0].Size != 4 {
+		t.Errorf("GET checksums = %d %s", code, body)
+	}
+	sum := sha256.Sum256([]byte("efgh"))
+	if code, _ := do("PUT", "/fs/up.bin?offset=4&sha256=00", "efgh"); code != http.StatusUnprocessableEntity {
+		t.Errorf("PUT block with a bad checksum = %d, want 422", code)
+	}
+	if code, _ := do("PUT", "/fs/up.bin?offset=4&sha256="+hex.EncodeToString(sum[:]), "efgh"); code != http.StatusNoContent {
+		t.Errorf("PUT block = %d", code)
+	}
+	if code, body := do("GET", "/fs/up.bin", ""); code != http.StatusOK || body != "abcdefgh" {
+		t.Errorf("GET uploaded file = %d %q", code, body)
+	}
+
+	// KV
+	if code, _ := do("PUT", "/kv/app%2Fconfig", `{"debug":true}`); code != http.StatusNoContent {
+		t.Errorf("PUT key = %d", code)
+	}
+	if code, _ := do("PUT", "/kv/bad", `{`); code != http.StatusBadRequest {
+		t.Errorf("PUT invalid JSON = %d, want 400", code)
+	}
+	if code, body := do("GET", "/kv/app%2Fconfig", ""); code != http.StatusOK || body != `{"debug":true}` {
+		t.Errorf("GET key = %d %s", code, body)
+	}
+	if code, body := do("GET", "/kv?prefix=app/", ""); code != http.StatusOK || !strings.Contains(body, `"key":"app/config"`) {
+		t.Errorf("GET keys = %d %s", code, body)
+	}
+	if code, _ := do("GET", "/kv/missing", ""); code != http.StatusNotFound {
+		t.Errorf("GET missing key = %d, want 404", code)
+	}
+
+	// Tool calls
+	code, body = do("POST", "/tools", `{"name":"search","parameters":{"q":"go"},"result":["a"],"started_at":10,"completed_at":11}`)
+	var call agentfs.ToolCall
+	if code != http.StatusCreated || json.Unmarshal([]byte(body), &call) != nil || call.Name != "search" {
+		t.Fatalf("POST tool call = %d %s", code, body)
+	}
+	if code, body := do("GET", "/tools?name=search", ""); code != http.StatusOK || !strings.Contains(body, `"parameters":{"q":"go"}`) {
+		t.Errorf("GET tool calls = %d %s", code, body)
+	}
+	if code, _ := do("GET", "/tools/999", ""); code != http.StatusNotFound {
+		t.Errorf("GET missing tool call = %d, want 404", code)
+	}
+	if code, _ := do("POST", "/tools", `{}`); code != http.StatusBadRequest {
+		t.Errorf("POST without name = %d, want 400", code)
+	}
+}
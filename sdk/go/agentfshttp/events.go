@@ -0,0 +1,94 @@
+// Package agentfshttp serves AgentFS over HTTP.
+package agentfshttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// KeepAliveInterval is how often an idle event stream sends a comment line
+// so proxies do not close the connection.
+var KeepAliveInterval = 15 * time.Second
+
+// EventsHandler streams the AgentFS change feed as server-sent events.
+//
+// Each event is sent with its sequence number as the SSE id, its kind as the
+// SSE event name, and the JSON-encoded agentfs.Event as data. Query
+// parameters filter the stream:
+//
+//	kind    event kinds, comma-separated or repeated (e.g. kind=file.written,tool_call.completed)
+//	prefix  only paths or keys starting with this prefix
+//	buffer  per-client buffer size (default agentfs.DefaultEventBuffer)
+//
+// Example:
+//
+//	http.Handle("/events", agentfshttp.EventsHandler(afs))
+//
+//	// curl -N 'localhost:8080/events?kind=file.written&prefix=/output'
+func EventsHandler(afs *agentfs.AgentFS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		filter := agentfs.EventFilter{PathPrefix: r.URL.Query().Get("prefix")}
+		for _, v := range r.URL.Query()["kind"] {
+			for _, k := range strings.Split(v, ",") {
+				if k = strings.TrimSpace(k); k != "" {
+					filter.Kinds = append(filter.Kinds, agentfs.EventKind(k))
+				}
+			}
+		}
+
+		var buffer int
+		if v := r.URL.Query().Get("buffer"); v != "" {
+			if _, err := fmt.Sscanf(v, "%d", &buffer); err != nil {
+				http.Error(w, "invalid buffer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		events, cancel := afs.Subscribe(filter, buffer)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(KeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					return
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Kind, data); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
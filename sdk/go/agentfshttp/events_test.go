@@ -0,0 +1,79 @@
+package agentfshttp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func setupTestDB(t *testing.T) *agentfs.AgentFS {
+	t.Helper()
+	afs, err := agentfs.Open(context.Background(), agentfs.AgentFSOptions{
+		Path: filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	return afs
+}
+
+func TestEventsHandler(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	srv := httptest.NewServer(EventsHandler(afs))
+	defer srv.Close()
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, srv.URL+"?kind=file.written&prefix=/out", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// Filtered out by prefix and kind
+	if err := afs.FS.WriteFile(ctx, "/tmp/x", []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/out/report.md", []byte("done"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("event lines = %q, want id/event/data", lines)
+	}
+	if lines[1] != "event: file.written" {
+		t.Errorf("event line = %q", lines[1])
+	}
+
+	var e agentfs.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &e); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if e.Path != "/out/report.md" {
+		t.Errorf("event path = %q, want /out/report.md", e.Path)
+	}
+}
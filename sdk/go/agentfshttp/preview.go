@@ -0,0 +1,50 @@
+package agentfshttp
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// PreviewHandler serves previews of files (see agentfs.Filesystem.Preview).
+// Query parameters:
+//
+//	path  the file to preview
+//	size  the preview size (default agentfs.DefaultPreviewSize)
+//
+// Files without a previewer get 415 Unsupported Media Type.
+//
+// Example:
+//
+//	http.Handle("/preview", agentfshttp.PreviewHandler(afs))
+//
+//	// <img src="/preview?path=/charts/revenue.png&size=128">
+func PreviewHandler(afs *agentfs.AgentFS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var size int
+		if v := q.Get("size"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "invalid size", http.StatusBadRequest)
+				return
+			}
+			size = n
+		}
+
+		pv, err := afs.FS.Preview(r.Context(), q.Get("path"), size)
+		if errors.Is(err, agentfs.ErrNoPreview) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", pv.ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(pv.Data)))
+		w.Write(pv.Data)
+	})
+}
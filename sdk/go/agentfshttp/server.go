@@ -0,0 +1,95 @@
+package agentfshttp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// Server serves an AgentFS over HTTP: share links at /share (see
+// CreateShareLink), and, once EnablePrivate is called, the change feed at
+// /events (see EventsHandler), live file tails at /tail (see TailHandler),
+// and previews of any file at /preview (see PreviewHandler).
+//
+// Example:
+//
+//	srv := agentfshttp.NewServer(afs, secret, "https://agents.example.com")
+//	http.ListenAndServe(":8080", srv)
+type Server struct {
+	afs     *agentfs.AgentFS
+	secret  []byte
+	baseURL string
+	mux     *http.ServeMux
+	now     func() time.Time
+}
+
+// NewServer returns a Server for afs. secret signs share links and must be
+// kept private; baseURL is the externally visible URL of the server, used
+// to build the links.
+func NewServer(afs *agentfs.AgentFS, secret []byte, baseURL string) *Server {
+	s := &Server{afs: afs, secret: secret, baseURL: baseURL, mux: http.NewServeMux(), now: time.Now}
+	s.mux.HandleFunc("/share", s.serveShare)
+	return s
+}
+
+// EnablePrivate mounts the routes that expose the whole agent rather than
+// one shared path: /events, which streams every path, KV key, and tool
+// call, /tail, which streams any file, and /preview, which previews any
+// file. Requests to them are served only if authorize returns true, and
+// get 401 Unauthorized otherwise. It must be called at most once, before
+// the server is used.
+//
+// Example:
+//
+//	srv.EnablePrivate(func(r *http.Request) bool {
+//	    return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1
+//	})
+func (s *Server) EnablePrivate(authorize func(r *http.Request) bool) {
+	s.mux.Handle("/events", requireAuth(authorize, EventsHandler(s.afs)))
+	s.mux.Handle("/tail", requireAuth(authorize, TailHandler(s.afs)))
+	s.mux.Handle("/preview", requireAuth(authorize, PreviewHandler(s.afs)))
+}
+
+// requireAuth serves h only to requests authorize accepts.
+func requireAuth(authorize func(r *http.Request) bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorize(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeError maps an AgentFS error to an HTTP status.
+func writeError(w http.ResponseWriter, err error) {
+	var fsErr *agentfs.FSError
+	var mismatch *agentfs.ErrChecksumMismatch
+	switch {
+	case agentfs.IsNotExist(err), isNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.As(err, &mismatch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case agentfs.IsPermission(err), errors.Is(err, agentfs.ErrReserved):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.As(err, &fsErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// isNotFound reports whether err is the error of a missing KV key or tool
+// call, which have no sentinel of their own.
+func isNotFound(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "key not found:") || strings.HasPrefix(msg, "tool call not found:")
+}
@@ -0,0 +1,129 @@
+package agentfshttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+// ShareScope is the access granted by a share link.
+type ShareScope string
+
+const (
+	// ShareRead allows downloading a file or listing a directory.
+	ShareRead ShareScope = "read"
+	// ShareWrite additionally allows replacing the file with PUT.
+	ShareWrite ShareScope = "write"
+)
+
+// MaxShareUpload is the largest body accepted by a PUT to a write link.
+var MaxShareUpload int64 = 64 << 20
+
+// CreateShareLink returns a signed URL granting scope access to the single
+// file or directory at p until ttl has passed. A GET of the link returns
+// the file content, or a JSON listing for a directory; a PUT to a write
+// link replaces the file. The link cannot reach any other path, and it
+// stops working when it expires or the server secret changes.
+//
+// Example:
+//
+//	link, err := srv.CreateShareLink("/reports/q3.md", 24*time.Hour, agentfshttp.ShareRead)
+func (s *Server) CreateShareLink(p string, ttl time.Duration, scope ShareScope) (string, error) {
+	if scope != ShareRead && scope != ShareWrite {
+		return "", fmt.Errorf("unknown share scope %q", scope)
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("share link TTL must be positive")
+	}
+	if len(s.secret) == 0 {
+		return "", fmt.Errorf("share links require a server secret")
+	}
+	p = path.Clean("/" + p)
+	exp := strconv.FormatInt(s.now().Add(ttl).Unix(), 10)
+
+	q := url.Values{}
+	q.Set("path", p)
+	q.Set("scope", string(scope))
+	q.Set("exp", exp)
+	q.Set("sig", s.sign(p, scope, exp))
+	return s.baseURL + "/share?" + q.Encode(), nil
+}
+
+// sign returns the signature of a share link.
+func (s *Server) sign(p string, scope ShareScope, exp string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s", scope, exp, p)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// serveShare serves a request to a share link.
+func (s *Server) serveShare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p, scope, exp := q.Get("path"), ShareScope(q.Get("scope")), q.Get("exp")
+	if len(s.secret) == 0 || !hmac.Equal([]byte(q.Get("sig")), []byte(s.sign(p, scope, exp))) {
+		http.Error(w, "invalid share link", http.StatusForbidden)
+		return
+	}
+	if expires, err := strconv.ParseInt(exp, 10, 64); err != nil || s.now().Unix() >= expires {
+		http.Error(w, "share link expired", http.StatusGone)
+		return
+	}
+
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		stats, err := s.afs.FS.Stat(ctx, p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if stats.IsDir() {
+			entries, err := s.afs.FS.ReaddirPlus(ctx, p)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(entries)
+			return
+		}
+		data, err := s.afs.FS.ReadFile(ctx, p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	case http.MethodPut:
+		if scope != ShareWrite {
+			http.Error(w, "share link is read-only", http.StatusForbidden)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(r.Body, MaxShareUpload+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(data)) > MaxShareUpload {
+			http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := s.afs.FS.WriteFile(ctx, p, data, 0o644); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,122 @@
+package agentfshttp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func TestShareLinks(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	afs.FS.WriteFile(ctx, "/out/report.md", []byte("# Report"), 0o644)
+	afs.FS.WriteFile(ctx, "/secret.txt", []byte("hidden"), 0o644)
+
+	srv := NewServer(afs, []byte("test-secret"), "")
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+	srv.baseURL = hs.URL
+
+	get := func(link string) (int, string) {
+		resp, err := http.Get(link)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	link, err := srv.CreateShareLink("/out/report.md", time.Hour, ShareRead)
+	if err != nil {
+		t.Fatalf("CreateShareLink failed: %v", err)
+	}
+	if code, body := get(link); code != http.StatusOK || body != "# Report" {
+		t.Errorf("GET file link = %d %q", code, body)
+	}
+
+	// The signature covers the path
+	if code, _ := get(strings.Replace(link, "report.md", "..%2F..%2Fsecret.txt", 1)); code != http.StatusForbidden {
+		t.Errorf("GET tampered link = %d, want 403", code)
+	}
+
+	dirLink, _ := srv.CreateShareLink("/out", time.Hour, ShareRead)
+	code, body := get(dirLink)
+	var entries []agentfs.DirEntry
+	if err := json.Unmarshal([]byte(body), &entries); code != http.StatusOK || err != nil || len(entries) != 1 || entries[0].Name != "report.md" {
+		t.Errorf("GET directory link = %d %q", code, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, link, strings.NewReader("changed"))
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("PUT to read link = %v, %v", resp, err)
+	}
+	writeLink, _ := srv.CreateShareLink("/inbox/notes.txt", time.Hour, ShareWrite)
+	req, _ = http.NewRequest(http.MethodPut, writeLink, strings.NewReader("from a human"))
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT to write link = %v, %v", resp, err)
+	}
+	if data, _ := afs.FS.ReadFile(ctx, "/inbox/notes.txt"); string(data) != "from a human" {
+		t.Errorf("uploaded content = %q", data)
+	}
+
+	srv.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if code, _ := get(link); code != http.StatusGone {
+		t.Errorf("GET expired link = %d, want 410", code)
+	}
+
+	if _, err := srv.CreateShareLink("/out", time.Hour, "admin"); err == nil {
+		t.Error("CreateShareLink accepted an unknown scope")
+	}
+}
+
+func TestServerPrivateRoutes(t *testing.T) {
+	afs := setupTestDB(t)
+	defer afs.Close()
+	afs.FS.WriteFile(context.Background(), "/f.txt", []byte("line\n"), 0o644)
+	srv := NewServer(afs, []byte("test-secret"), "")
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+
+	routes := []string{"/events", "/tail?path=/f.txt", "/preview?path=/"}
+	status := func(route, token string) int {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, hs.URL+route, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", route, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Not mounted until enabled
+	for _, route := range routes {
+		if code := status(route, ""); code != http.StatusNotFound {
+			t.Errorf("GET %s = %d before EnablePrivate, want 404", route, code)
+		}
+	}
+
+	srv.EnablePrivate(func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer s3cret" })
+	for _, route := range routes {
+		if code := status(route, "wrong"); code != http.StatusUnauthorized {
+			t.Errorf("GET %s with a wrong token = %d, want 401", route, code)
+		}
+		if code := status(route, "s3cret"); code == http.StatusUnauthorized || code == http.StatusNotFound {
+			t.Errorf("GET %s with the token = %d", route, code)
+		}
+	}
+}
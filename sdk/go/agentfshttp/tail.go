@@ -0,0 +1,94 @@
+package agentfshttp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// TailHandler streams the lines appended to a file as server-sent events,
+// like tail -F (see agentfs.Filesystem.TailFollow). Each line is sent as a
+// "line" event whose id counts the lines sent on this stream. Query
+// parameters:
+//
+//	path        the file to follow
+//	from_start  "true" to send the lines already in the file first
+//	poll        how often to check for appends by other processes (e.g. 1s)
+//
+// Example:
+//
+//	http.Handle("/tail", agentfshttp.TailHandler(afs))
+//
+//	// curl -N 'localhost:8080/tail?path=/runs/42/agent.log'
+func TailHandler(afs *agentfs.AgentFS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		q := r.URL.Query()
+		opts := &agentfs.TailOptions{}
+		if v := q.Get("from_start"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "invalid from_start", http.StatusBadRequest)
+				return
+			}
+			opts.FromStart = b
+		}
+		if v := q.Get("poll"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				http.Error(w, "invalid poll", http.StatusBadRequest)
+				return
+			}
+			opts.PollInterval = d
+		}
+
+		tail, err := afs.FS.TailFollow(r.Context(), q.Get("path"), opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(KeepAliveInterval)
+		defer keepAlive.Stop()
+
+		var id int64
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case line, ok := <-tail.Lines:
+				if !ok {
+					if err := tail.Err(); err != nil {
+						fmt.Fprintf(w, "event: error\ndata: %s\n\n", err)
+						flusher.Flush()
+					}
+					return
+				}
+				id++
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: line\ndata: %s\n\n", id, line); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package agentfshttp
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailHandler(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/runs/1/agent.log", []byte("started\n"), 0o644)
+
+	srv := httptest.NewServer(TailHandler(afs))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?path=/runs/1/missing.log")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing file status = %d, want 404", resp.StatusCode)
+	}
+
+	// A missed line fails the test instead of hanging it
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, srv.URL+"?path=/runs/1/agent.log&from_start=true", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	log, err := afs.FS.OpenLog(ctx, "/runs/1/agent.log")
+	if err != nil {
+		t.Fatalf("OpenLog failed: %v", err)
+	}
+	log.WriteLine("step 1")
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var data []string
+	for len(data) < 2 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if strings.Join(data, "|") != "started|step 1" {
+		t.Errorf("data = %q, want started, step 1", data)
+	}
+}
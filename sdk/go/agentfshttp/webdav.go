@@ -0,0 +1,339 @@
+package agentfshttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// DefaultWebDAVLockTimeout is the longest a WebDAV lock is held without a
+// refresh unless WebDAVOptions.MaxLockTimeout is set.
+const DefaultWebDAVLockTimeout = time.Hour
+
+// WebDAVOptions configures WebDAVHandler.
+type WebDAVOptions struct {
+	// Prefix is the path the handler is mounted at, such as "/dav". It is
+	// stripped from request paths and added to the hrefs of responses.
+	// Default: "" (mounted at the root).
+	Prefix string
+
+	// ReadOnly rejects every method that changes the filesystem or takes
+	// a lock with 403 Forbidden. Default: false.
+	ReadOnly bool
+
+	// MaxLockTimeout caps the timeout clients ask for on a lock, and is
+	// the timeout of locks asked for without one.
+	// Default: DefaultWebDAVLockTimeout.
+	MaxLockTimeout time.Duration
+}
+
+// davWriteMethods are the methods WebDAVOptions.ReadOnly rejects.
+var davWriteMethods = map[string]bool{
+	http.MethodPut: true, http.MethodDelete: true, "MKCOL": true, "COPY": true,
+	"MOVE": true, "PROPPATCH": true, "LOCK": true, "UNLOCK": true,
+}
+
+// WebDAVHandler serves the filesystem of afs over WebDAV (RFC 4918, class
+// 1 and 2), so the agent workspace can be mounted with the built-in
+// clients of macOS (Finder, Connect to Server) and Windows (Map Network
+// Drive), or with davfs2 and rclone, without FUSE.
+//
+// The protocol is handled by webdav.Handler of golang.org/x/net/webdav,
+// over a webdav.FileSystem backed by afs and an in-memory lock system:
+//
+//   - GET and HEAD stream files, with byte ranges and conditional requests.
+//   - PUT streams the body into the file, which is replaced only once the
+//     body is complete. A Content-Range header writes the body at that
+//     offset instead, updating only the chunks it spans.
+//   - PROPFIND reports the file stats as live properties at Depth 0 or 1;
+//     Depth infinity is refused, as a walk of a large tree would be.
+//   - PROPPATCH stores dead properties as custom metadata (see
+//     Filesystem.SetMeta) under keys of the form "dav:{namespace}name",
+//     so they follow the file across renames and are copied with it.
+//   - DELETE of a directory runs in one transaction; COPY and MOVE are
+//     made of one statement or transaction per file.
+//   - LOCK and UNLOCK take exclusive write locks, which are enforced on
+//     every write made through the handler. Shared locks are refused.
+//
+// Files and directories are created with modes 0644 and 0755. Locks are
+// held in the memory of the handler: they guard WebDAV clients against
+// each other, not against other writers of the database. The handler does
+// no authentication; serve it on a loopback address or behind an
+// authenticating proxy.
+//
+// Example:
+//
+//	http.Handle("/dav/", agentfshttp.WebDAVHandler(afs, &agentfshttp.WebDAVOptions{Prefix: "/dav"}))
+//
+//	// macOS: Finder > Go > Connect to Server > http://localhost:8080/dav/
+func WebDAVHandler(afs *agentfs.AgentFS, opts *WebDAVOptions) http.Handler {
+	d := &davHandler{afs: afs}
+	if opts != nil {
+		d.opts = *opts
+	}
+	d.opts.Prefix = strings.TrimSuffix(d.opts.Prefix, "/")
+	if d.opts.MaxLockTimeout <= 0 {
+		d.opts.MaxLockTimeout = DefaultWebDAVLockTimeout
+	}
+	d.h = &webdav.Handler{
+		Prefix:     d.opts.Prefix,
+		FileSystem: davFS{afs: afs},
+		LockSystem: webdav.NewMemLS(),
+	}
+	return d
+}
+
+// davHandler applies WebDAVOptions and the limits of WebDAVHandler to
+// requests before handing them to webdav.Handler.
+type davHandler struct {
+	h    *webdav.Handler
+	afs  *agentfs.AgentFS
+	opts WebDAVOptions
+}
+
+// ServeHTTP implements http.Handler.
+func (d *davHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, ok := d.resourcePath(r.URL.Path)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if d.opts.ReadOnly && davWriteMethods[r.Method] {
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		if d.opts.ReadOnly {
+			w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+			w.Header().Set("DAV", "1")
+			w.Header().Set("MS-Author-Via", "DAV")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	case "PROPFIND":
+		if depth := r.Header.Get("Depth"); depth != "0" && depth != "1" {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+
+				`<D:error xmlns:D="DAV:"><D:propfind-finite-depth/></D:error>`)
+			return
+		}
+	case http.MethodDelete:
+		if p == "/" {
+			http.Error(w, "cannot delete the root", http.StatusForbidden)
+			return
+		}
+	case http.MethodPut:
+		if r.Header.Get("Content-Range") != "" {
+			d.servePutRange(w, r, p)
+			return
+		}
+		if !d.parentExists(r.Context(), p) {
+			http.Error(w, "parent collection does not exist", http.StatusConflict)
+			return
+		}
+		// Lets the file abort the write if the body is cut off midway
+		body := &davBody{ReadCloser: r.Body}
+		r = r.WithContext(context.WithValue(r.Context(), davBodyKey{}, body))
+		r.Body = body
+	case "MOVE":
+		// RFC 4918 defaults Overwrite to T; webdav.Handler defaults to F
+		if r.Header.Get("Overwrite") == "" {
+			r.Header.Set("Overwrite", "T")
+		}
+	case "LOCK":
+		r.Header.Set("Timeout", fmt.Sprintf("Second-%d", d.lockTimeout(r.Header.Get("Timeout"))/time.Second))
+	}
+	d.h.ServeHTTP(w, r)
+}
+
+// resourcePath returns the filesystem path of the request path u, which
+// must be below the prefix.
+func (d *davHandler) resourcePath(u string) (string, bool) {
+	rest, ok := strings.CutPrefix(u, d.opts.Prefix)
+	if !ok || (rest != "" && rest[0] != '/') {
+		return "", false
+	}
+	return path.Clean("/" + rest), true
+}
+
+// lockTimeout returns the timeout asked for by the Timeout header h, such
+// as "Second-600" or "Infinite", capped at the maximum.
+func (d *davHandler) lockTimeout(h string) time.Duration {
+	for _, v := range strings.Split(h, ",") {
+		v = strings.TrimSpace(v)
+		if secs, ok := strings.CutPrefix(v, "Second-"); ok {
+			if n, err := strconv.ParseInt(secs, 10, 64); err == nil && n > 0 {
+				return min(time.Duration(n)*time.Second, d.opts.MaxLockTimeout)
+			}
+		}
+	}
+	return d.opts.MaxLockTimeout
+}
+
+// parentExists reports whether the parent of p is a directory.
+func (d *davHandler) parentExists(ctx context.Context, p string) bool {
+	stats, err := d.afs.FS.Stat(ctx, path.Dir(p))
+	return err == nil && stats.IsDir()
+}
+
+// servePutRange writes the body of a PUT with a Content-Range header at
+// the offset of the range, which webdav.Handler would write over the
+// whole file.
+func (d *davHandler) servePutRange(w http.ResponseWriter, r *http.Request, p string) {
+	ctx := r.Context()
+	offset, length, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	release, ok := d.confirmLock(p, r.Header.Get("If"))
+	if !ok {
+		http.Error(w, "locked", http.StatusLocked)
+		return
+	}
+	defer release()
+
+	stats, err := d.afs.FS.Stat(ctx, p)
+	created := agentfs.IsNotExist(err)
+	if err != nil && !created {
+		writeError(w, err)
+		return
+	}
+	if !created && stats.IsDir() {
+		http.Error(w, "is a collection", http.StatusMethodNotAllowed)
+		return
+	}
+	if created && !d.parentExists(ctx, p) {
+		http.Error(w, "parent collection does not exist", http.StatusConflict)
+		return
+	}
+	f, err := d.afs.FS.Open(ctx, p, agentfs.O_WRONLY|agentfs.O_CREATE)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	_, err = io.Copy(io.NewOffsetWriter(f.WithContext(ctx), offset), io.LimitReader(r.Body, length))
+	f.Close()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if stats, err := d.afs.FS.Stat(ctx, p); err == nil {
+		w.Header().Set("ETag", etag(stats))
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// confirmLock claims p for a write the way webdav.Handler does: with a
+// lock token submitted in the If header ifHdr, or, without one, with a
+// temporary lock that fails if another client holds a lock on p.
+func (d *davHandler) confirmLock(p, ifHdr string) (release func(), ok bool) {
+	ls, now := d.h.LockSystem, time.Now()
+	tokens := submittedTokens(ifHdr)
+	if len(tokens) == 0 {
+		token, err := ls.Create(now, webdav.LockDetails{Root: p, Duration: -1, ZeroDepth: true})
+		if err != nil {
+			return nil, false
+		}
+		return func() { ls.Unlock(time.Now(), token) }, true
+	}
+	for _, token := range tokens {
+		if release, err := ls.Confirm(now, p, "", webdav.Condition{Token: token}); err == nil {
+			return release, true
+		}
+	}
+	return nil, false
+}
+
+// davBody records the error that cut off the body of a PUT.
+type davBody struct {
+	io.ReadCloser
+	err error
+}
+
+type davBodyKey struct{}
+
+func (b *davBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// bodyError returns the error that cut off the body of the PUT served
+// with ctx, if any.
+func bodyError(ctx context.Context) error {
+	if b, ok := ctx.Value(davBodyKey{}).(*davBody); ok {
+		return b.err
+	}
+	return nil
+}
+
+// submittedTokens returns the lock tokens in the If header h. Tokens
+// negated with Not are left out; entity tags and resource tags are
+// ignored, so a token applies to whichever resource it locks.
+func submittedTokens(h string) []string {
+	var tokens []string
+	inList := false
+	for i := 0; i < len(h); i++ {
+		switch h[i] {
+		case '(':
+			inList = true
+		case ')':
+			inList = false
+		case '[':
+			end := strings.IndexByte(h[i:], ']')
+			if end < 0 {
+				return tokens
+			}
+			i += end
+		case '<':
+			end := strings.IndexByte(h[i:], '>')
+			if end < 0 {
+				return tokens
+			}
+			before := strings.ToLower(strings.TrimRight(h[:i], " \t"))
+			if inList && !strings.HasSuffix(before, "not") {
+				tokens = append(tokens, h[i+1:i+end])
+			}
+			i += end
+		}
+	}
+	return tokens
+}
+
+// parseContentRange returns the offset and length of a PUT's Content-Range
+// header, "bytes first-last/total" with total possibly "*".
+func parseContentRange(h string) (offset, length int64, err error) {
+	spec, ok := strings.CutPrefix(h, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	span, _, _ := strings.Cut(spec, "/")
+	first, last, ok := strings.Cut(span, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	return start, end - start + 1, nil
+}
@@ -0,0 +1,420 @@
+package agentfshttp
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// davMetaPrefix starts the metadata keys holding dead properties, which
+// continue with the property name as "{namespace}local".
+const davMetaPrefix = "dav:"
+
+// davUmask clears the group and other write bits of the modes
+// webdav.Handler creates files (0666) and directories (0777) with.
+const davUmask = 0o022
+
+// davFS implements webdav.FileSystem over the filesystem of an AgentFS.
+type davFS struct {
+	afs *agentfs.AgentFS
+}
+
+// Mkdir implements webdav.FileSystem.
+func (d davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return osError("mkdir", name, d.afs.FS.Mkdir(ctx, name, int64(perm.Perm()&^davUmask)))
+}
+
+// OpenFile implements webdav.FileSystem. A file opened with O_TRUNC, or
+// created, is written to a staging area and replaces the file on Close;
+// otherwise it is opened for reading, and for its dead properties.
+func (d davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	fs := d.afs.FS
+	stats, err := fs.Stat(ctx, name)
+	created := agentfs.IsNotExist(err) && flag&os.O_CREATE != 0
+	if err != nil && !created {
+		return nil, osError("open", name, err)
+	}
+	f := &davFile{afs: d.afs, ctx: ctx, name: name, stats: stats}
+	if flag&os.O_TRUNC == 0 && !created {
+		return f, nil
+	}
+	if !created && stats.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	if created {
+		parent, err := fs.Stat(ctx, path.Dir(name))
+		if err != nil || !parent.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		f.mode = int64(perm.Perm() &^ davUmask)
+	}
+	if f.w, err = fs.OpenStagedWriter(ctx, name); err != nil {
+		return nil, osError("open", name, err)
+	}
+	return f, nil
+}
+
+// RemoveAll implements webdav.FileSystem, in one transaction.
+func (d davFS) RemoveAll(ctx context.Context, name string) error {
+	if path.Clean(name) == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	return osError("remove", name, inTx(ctx, d.afs, func(fs *agentfs.Filesystem) error {
+		return removeTree(ctx, fs, name)
+	}))
+}
+
+// Rename implements webdav.FileSystem.
+func (d davFS) Rename(ctx context.Context, oldName, newName string) error {
+	return osError("rename", oldName, d.afs.FS.Rename(ctx, oldName, newName))
+}
+
+// Stat implements webdav.FileSystem.
+func (d davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	stats, err := d.afs.FS.Stat(ctx, name)
+	if err != nil {
+		return nil, osError("stat", name, err)
+	}
+	return davFileInfo{name: name, stats: stats}, nil
+}
+
+// davFile implements webdav.File, and webdav.DeadPropsHolder with the
+// custom metadata of the file.
+type davFile struct {
+	afs   *agentfs.AgentFS
+	ctx   context.Context
+	name  string
+	stats *agentfs.Stats // nil for a file being created
+
+	r       *agentfs.FileReader
+	entries []agentfs.DirEntry
+	listed  bool
+
+	w       *agentfs.FileWriter
+	mode    int64 // of a file being created
+	failed  error
+	patches []webdav.Proppatch // applied once w is committed
+}
+
+func (f *davFile) reader() (*agentfs.FileReader, error) {
+	if f.w != nil || f.stats.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: f.name, Err: errors.New("not open for reading")}
+	}
+	if f.r == nil {
+		r, err := f.afs.FS.OpenReader(f.ctx, f.name)
+		if err != nil {
+			return nil, osError("read", f.name, err)
+		}
+		f.r = r
+	}
+	return f.r, nil
+}
+
+// Read implements io.Reader.
+func (f *davFile) Read(p []byte) (int, error) {
+	r, err := f.reader()
+	if err != nil {
+		return 0, err
+	}
+	return r.Read(p)
+}
+
+// Seek implements io.Seeker.
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	r, err := f.reader()
+	if err != nil {
+		return 0, err
+	}
+	return r.Seek(offset, whence)
+}
+
+// Write implements io.Writer.
+func (f *davFile) Write(p []byte) (int, error) {
+	if f.w == nil {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrPermission}
+	}
+	n, err := f.w.Write(p)
+	if err != nil {
+		f.failed = err
+	}
+	return n, err
+}
+
+// Readdir implements http.File.
+func (f *davFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if f.stats == nil || !f.stats.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	if !f.listed {
+		entries, err := f.afs.FS.ReaddirPlus(f.ctx, f.name)
+		if err != nil {
+			return nil, osError("readdir", f.name, err)
+		}
+		f.entries, f.listed = entries, true
+	}
+	n := len(f.entries)
+	if count > 0 && count < n {
+		n = count
+	}
+	if count > 0 && n == 0 {
+		return nil, io.EOF
+	}
+	infos := make([]fs.FileInfo, n)
+	for i, e := range f.entries[:n] {
+		infos[i] = davFileInfo{name: path.Join(f.name, e.Name), stats: e.Stats}
+	}
+	f.entries = f.entries[n:]
+	return infos, nil
+}
+
+// Stat implements http.File.
+func (f *davFile) Stat() (fs.FileInfo, error) {
+	if f.w != nil {
+		return davFileInfo{name: f.name, written: f.w.Written(), fs: f.afs.FS}, nil
+	}
+	return davFileInfo{name: f.name, stats: f.stats}, nil
+}
+
+// Close implements io.Closer. A file being written replaces the file at
+// its path, unless a write or the body of the request failed.
+func (f *davFile) Close() error {
+	if f.r != nil {
+		f.r.Close()
+	}
+	if f.w == nil {
+		return nil
+	}
+	if err := errors.Join(f.failed, bodyError(f.ctx)); err != nil {
+		f.w.Abort()
+		return err
+	}
+	if err := f.w.Close(); err != nil {
+		return osError("close", f.name, err)
+	}
+	if f.mode != 0 {
+		if err := f.afs.FS.Chmod(f.ctx, f.name, f.mode); err != nil {
+			return osError("chmod", f.name, err)
+		}
+	}
+	if len(f.patches) > 0 {
+		if _, err := f.patch(f.patches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeadProps implements webdav.DeadPropsHolder.
+func (f *davFile) DeadProps() (map[xml.Name]webdav.Property, error) {
+	if f.stats == nil {
+		return nil, nil
+	}
+	meta, err := f.afs.FS.Meta(f.ctx, f.name)
+	if err != nil {
+		return nil, osError("meta", f.name, err)
+	}
+	props := make(map[xml.Name]webdav.Property)
+	for key, value := range meta {
+		name, ok := strings.CutPrefix(key, davMetaPrefix+"{")
+		if !ok {
+			continue
+		}
+		space, local, ok := strings.Cut(name, "}")
+		if !ok {
+			continue
+		}
+		n := xml.Name{Space: space, Local: local}
+		props[n] = webdav.Property{XMLName: n, InnerXML: []byte(value)}
+	}
+	return props, nil
+}
+
+// Patch implements webdav.DeadPropsHolder. The patches of a file being
+// written are applied when it is closed.
+func (f *davFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	if f.w != nil {
+		f.patches = append(f.patches, patches...)
+		return okPropstat(patches), nil
+	}
+	return f.patch(patches)
+}
+
+// patch applies patches to the metadata of the file in one transaction.
+func (f *davFile) patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	err := inTx(f.ctx, f.afs, func(fs *agentfs.Filesystem) error {
+		for _, patch := range patches {
+			for _, prop := range patch.Props {
+				key := metaKey(prop.XMLName)
+				var err error
+				if patch.Remove {
+					err = fs.DeleteMeta(f.ctx, f.name, key)
+				} else {
+					err = fs.SetMeta(f.ctx, f.name, key, string(prop.InnerXML))
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, osError("proppatch", f.name, err)
+	}
+	return okPropstat(patches), nil
+}
+
+// okPropstat reports every property of patches as changed.
+func okPropstat(patches []webdav.Proppatch) []webdav.Propstat {
+	stat := webdav.Propstat{Status: http.StatusOK}
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			stat.Props = append(stat.Props, webdav.Property{XMLName: prop.XMLName})
+		}
+	}
+	return []webdav.Propstat{stat}
+}
+
+// metaKey returns the metadata key holding the dead property name.
+func metaKey(name xml.Name) string {
+	return davMetaPrefix + "{" + name.Space + "}" + name.Local
+}
+
+// davFileInfo implements fs.FileInfo, webdav.ETager, and
+// webdav.ContentTyper over the stats of a file.
+type davFileInfo struct {
+	name  string
+	stats *agentfs.Stats
+
+	// A file being written has no stats yet: its size is what has been
+	// written, and its entity tag is looked up in fs once it is closed.
+	written int64
+	fs      *agentfs.Filesystem
+}
+
+func (fi davFileInfo) Name() string { return path.Base(fi.name) }
+func (fi davFileInfo) Sys() any     { return fi.stats }
+
+func (fi davFileInfo) Size() int64 {
+	if fi.stats == nil {
+		return fi.written
+	}
+	return fi.stats.Size
+}
+
+func (fi davFileInfo) Mode() fs.FileMode {
+	if fi.stats == nil {
+		return 0o644
+	}
+	mode := fs.FileMode(fi.stats.Mode & 0o777)
+	switch {
+	case fi.stats.IsDir():
+		mode |= fs.ModeDir
+	case fi.stats.IsSymlink():
+		mode |= fs.ModeSymlink
+	}
+	return mode
+}
+
+func (fi davFileInfo) ModTime() time.Time {
+	if fi.stats == nil {
+		return time.Now()
+	}
+	return fi.stats.MtimeTime()
+}
+
+func (fi davFileInfo) IsDir() bool { return fi.stats != nil && fi.stats.IsDir() }
+
+// ETag implements webdav.ETager.
+func (fi davFileInfo) ETag(ctx context.Context) (string, error) {
+	stats := fi.stats
+	if stats == nil {
+		var err error
+		if stats, err = fi.fs.Stat(ctx, fi.name); err != nil {
+			return "", osError("stat", fi.name, err)
+		}
+	}
+	return etag(stats), nil
+}
+
+// ContentType implements webdav.ContentTyper.
+func (fi davFileInfo) ContentType(ctx context.Context) (string, error) {
+	return contentType(fi.name), nil
+}
+
+// osError returns err as the error of package os that webdav.Handler
+// maps to the same status as writeError would.
+func osError(op, name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case agentfs.IsNotExist(err):
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	case agentfs.IsExist(err):
+		return &os.PathError{Op: op, Path: name, Err: os.ErrExist}
+	case agentfs.IsPermission(err):
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return err
+}
+
+// inTx runs fn on the filesystem of a transaction of afs, committing it if
+// fn succeeds.
+func inTx(ctx context.Context, afs *agentfs.AgentFS, fn func(fs *agentfs.Filesystem) error) error {
+	tx, err := afs.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx.FS); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// removeTree removes p and, if it is a directory, everything below it.
+func removeTree(ctx context.Context, fs *agentfs.Filesystem, p string) error {
+	stats, err := fs.Lstat(ctx, p)
+	if err != nil {
+		return err
+	}
+	if !stats.IsDir() {
+		return fs.Unlink(ctx, p)
+	}
+	entries, err := fs.ReaddirPlus(ctx, p)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := removeTree(ctx, fs, path.Join(p, e.Name)); err != nil {
+			return err
+		}
+	}
+	return fs.Rmdir(ctx, p)
+}
+
+// etag returns an entity tag that changes with the content of a file.
+func etag(s *agentfs.Stats) string {
+	return fmt.Sprintf(`"%x-%x"`, s.MtimeTime().UnixNano(), s.Size)
+}
+
+// contentType guesses the media type of p from its extension.
+func contentType(p string) string {
+	if t := mime.TypeByExtension(path.Ext(p)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
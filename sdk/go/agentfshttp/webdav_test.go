@@ -0,0 +1,220 @@
+package agentfshttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebDAVHandler(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/dav/", WebDAVHandler(afs, &WebDAVOptions{Prefix: "/dav"}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, body string, header ...string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	if resp, _ := do("OPTIONS", "/dav/", ""); resp.Header.Get("DAV") != "1, 2" {
+		t.Errorf("OPTIONS DAV header = %q", resp.Header.Get("DAV"))
+	}
+	if resp, _ := do("MKCOL", "/dav/notes", ""); resp.StatusCode != http.StatusCreated {
+		t.Errorf("MKCOL = %d", resp.StatusCode)
+	}
+	if resp, _ := do("PUT", "/dav/missing/plan.md", "x"); resp.StatusCode != http.StatusConflict {
+		t.Errorf("PUT without parent = %d, want 409", resp.StatusCode)
+	}
+	if resp, _ := do("PUT", "/dav/notes/plan%20v1.md", "# Plan\nstep one\n"); resp.StatusCode != http.StatusCreated {
+		t.Errorf("PUT = %d", resp.StatusCode)
+	}
+
+	// Range reads and writes
+	if resp, body := do("GET", "/dav/notes/plan%20v1.md", "", "Range", "bytes=2-5"); resp.StatusCode != http.StatusPartialContent || body != "Plan" {
+		t.Errorf("GET range = %d %q", resp.StatusCode, body)
+	}
+	if resp, _ := do("PUT", "/dav/notes/plan%20v1.md", "PLAN", "Content-Range", "bytes 2-5/*"); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("PUT range = %d", resp.StatusCode)
+	}
+	if data, _ := afs.FS.ReadFile(ctx, "/notes/plan v1.md"); string(data) != "# PLAN\nstep one\n" {
+		t.Errorf("content after range PUT = %q", data)
+	}
+
+	// Properties
+	resp, body := do("PROPFIND", "/dav/notes", "", "Depth", "1")
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "<D:href>/dav/notes/plan%20v1.md</D:href>") || !strings.Contains(body, "<D:getcontentlength>16</D:getcontentlength>") || !strings.Contains(body, `<D:collection xmlns:D="DAV:"/>`) {
+		t.Errorf("PROPFIND = %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := do("PROPFIND", "/dav/", "", "Depth", "infinity"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("PROPFIND Depth infinity = %d, want 403", resp.StatusCode)
+	}
+	patch := `<?xml version="1.0"?><D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:example"><D:set><D:prop><Z:reviewer>ana</Z:reviewer></D:prop></D:set></D:propertyupdate>`
+	if resp, body := do("PROPPATCH", "/dav/notes/plan%20v1.md", patch); resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "200 OK") {
+		t.Errorf("PROPPATCH = %d %s", resp.StatusCode, body)
+	}
+	find := `<?xml version="1.0"?><D:propfind xmlns:D="DAV:" xmlns:Z="urn:example"><D:prop><Z:reviewer/><Z:missing/><D:getetag/></D:prop></D:propfind>`
+	_, body = do("PROPFIND", "/dav/notes/plan%20v1.md", find, "Depth", "0")
+	if !strings.Contains(body, `<reviewer xmlns="urn:example">ana</reviewer>`) || !strings.Contains(body, "404 Not Found") || !strings.Contains(body, "<D:getetag>") {
+		t.Errorf("PROPFIND of named props = %s", body)
+	}
+	patch = `<D:propertyupdate xmlns:D="DAV:"><D:set><D:prop><D:getetag>x</D:getetag></D:prop></D:set></D:propertyupdate>`
+	if _, body := do("PROPPATCH", "/dav/notes/plan%20v1.md", patch); !strings.Contains(body, "403 Forbidden") {
+		t.Errorf("PROPPATCH of a live property = %s", body)
+	}
+
+	// Locks
+	lockinfo := `<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype><D:owner>ana</D:owner></D:lockinfo>`
+	resp, body = do("LOCK", "/dav/notes", lockinfo, "Timeout", "Second-600")
+	token := resp.Header.Get("Lock-Token")
+	if resp.StatusCode != http.StatusOK || token == "" || !strings.Contains(body, "<D:timeout>Second-600</D:timeout>") {
+		t.Fatalf("LOCK = %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := do("LOCK", "/dav/notes/plan%20v1.md", lockinfo); resp.StatusCode != http.StatusLocked {
+		t.Errorf("conflicting LOCK = %d, want 423", resp.StatusCode)
+	}
+	if resp, _ := do("PUT", "/dav/notes/plan%20v1.md", "overwritten"); resp.StatusCode != http.StatusLocked {
+		t.Errorf("PUT without the lock token = %d, want 423", resp.StatusCode)
+	}
+	if resp, _ := do("DELETE", "/dav/", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("DELETE of the root = %d, want 403", resp.StatusCode)
+	}
+	if resp, _ := do("PUT", "/dav/notes/todo.txt", "a", "If", "("+token+")"); resp.StatusCode != http.StatusCreated {
+		t.Errorf("PUT with the lock token = %d", resp.StatusCode)
+	}
+	if resp, _ := do("UNLOCK", "/dav/notes", "", "Lock-Token", token); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("UNLOCK = %d", resp.StatusCode)
+	}
+
+	// Copy, move, delete
+	if resp, _ := do("COPY", "/dav/notes", "", "Destination", srv.URL+"/dav/backup"); resp.StatusCode != http.StatusCreated {
+		t.Errorf("COPY = %d", resp.StatusCode)
+	}
+	if v, ok, _ := afs.FS.GetMeta(ctx, "/backup/plan v1.md", "dav:{urn:example}reviewer"); !ok || v != "ana" {
+		t.Errorf("copied dead property = %q, %v", v, ok)
+	}
+	if resp, _ := do("MOVE", "/dav/backup/todo.txt", "", "Destination", "/dav/notes/plan%20v1.md", "Overwrite", "F"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("MOVE onto an existing file without Overwrite = %d, want 412", resp.StatusCode)
+	}
+	if resp, _ := do("MOVE", "/dav/backup/todo.txt", "", "Destination", "/dav/notes/plan%20v1.md"); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("MOVE = %d", resp.StatusCode)
+	}
+	if data, _ := afs.FS.ReadFile(ctx, "/notes/plan v1.md"); string(data) != "a" {
+		t.Errorf("content after MOVE = %q", data)
+	}
+	if resp, _ := do("DELETE", "/dav/backup", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d", resp.StatusCode)
+	}
+	if _, err := afs.FS.Stat(ctx, "/backup"); err == nil {
+		t.Error("deleted directory still exists")
+	}
+}
+
+func TestWebDAVHandler_ReadOnly(t *testing.T) {
+	h := WebDAVHandler(nil, &WebDAVOptions{ReadOnly: true})
+	for _, method := range []string{"PUT", "DELETE", "MKCOL", "LOCK"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "/file.txt", nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s on a read-only handler = %d, want 403", method, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("OPTIONS", "/", nil))
+	if rec.Header().Get("DAV") != "1" {
+		t.Errorf("read-only DAV header = %q, want 1", rec.Header().Get("DAV"))
+	}
+}
+
+// brokenBody fails after its data, like a client cut off midway.
+type brokenBody struct{ io.Reader }
+
+func (b brokenBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestWebDAVHandler_BrokenPut(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	afs.FS.WriteFile(ctx, "/data.txt", []byte("old"), 0o644)
+
+	h := WebDAVHandler(afs, nil)
+	body := brokenBody{strings.NewReader(strings.Repeat("new ", 100_000))}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/data.txt", body))
+	if rec.Code < 400 {
+		t.Errorf("PUT of a broken body = %d, want an error", rec.Code)
+	}
+	if got, err := afs.FS.ReadFile(ctx, "/data.txt"); err != nil || string(got) != "old" {
+		t.Errorf("ReadFile after a broken PUT = %d bytes, %v; want the old content", len(got), err)
+	}
+}
+
+func TestSubmittedTokens(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"(<urn:uuid:a>)", []string{"urn:uuid:a"}},
+		{`<http://host/dav/f> (<urn:uuid:a> ["etag"]) (Not <urn:uuid:b>)`, []string{"urn:uuid:a"}},
+		{"(<urn:uuid:a>) (<urn:uuid:b>)", []string{"urn:uuid:a", "urn:uuid:b"}},
+	} {
+		if got := submittedTokens(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("submittedTokens(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	if off, n, err := parseContentRange("bytes 100-199/*"); err != nil || off != 100 || n != 100 {
+		t.Errorf("parseContentRange = %d, %d, %v", off, n, err)
+	}
+	for _, h := range []string{"bytes */100", "items 0-1/2", "bytes 5-1/10"} {
+		if _, _, err := parseContentRange(h); err == nil {
+			t.Errorf("parseContentRange(%q) succeeded", h)
+		}
+	}
+}
+
+func TestWebDAVLockTimeout(t *testing.T) {
+	d := WebDAVHandler(nil, &WebDAVOptions{MaxLockTimeout: time.Hour}).(*davHandler)
+	for _, tt := range []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", time.Hour},
+		{"Infinite", time.Hour},
+		{"Second-600", 10 * time.Minute},
+		{"Infinite, Second-600", 10 * time.Minute},
+		{"Second-7200", time.Hour},
+	} {
+		if got := d.lockTimeout(tt.header); got != tt.want {
+			t.Errorf("lockTimeout(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
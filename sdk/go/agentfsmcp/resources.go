@@ -0,0 +1,172 @@
+package agentfsmcp
+
+import (
+	"context"
+	"encoding/base64"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// uriScheme prefixes the URI of a file resource: agentfs:///notes/plan.md.
+const uriScheme = "agentfs://"
+
+// resourcePage is the number of files listed per resources/list page.
+const resourcePage = 500
+
+// listResources returns a page of the regular files of the filesystem, in
+// lexical order, starting after the offset encoded in cursor.
+func (s *Server) listResources(ctx context.Context, cursor string) (any, error) {
+	offset := 0
+	if cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+			return nil, &rpcError{codeInvalidParams, "invalid cursor"}
+		}
+	}
+
+	var files []string
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := s.afs.FS.ReaddirPlus(ctx, dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			p := path.Join(dir, e.Name)
+			if e.Stats.IsDir() {
+				if err := walk(p); err != nil {
+					return err
+				}
+			} else if e.Stats.IsRegularFile() {
+				files = append(files, p)
+			}
+		}
+		return nil
+	}
+	if err := walk("/"); err != nil {
+		return nil, err
+	}
+
+	resources := []map[string]string{}
+	for i := offset; i < len(files) && i < offset+resourcePage; i++ {
+		resources = append(resources, map[string]string{
+			"uri":      uriScheme + files[i],
+			"name":     files[i],
+			"mimeType": mimeType(files[i]),
+		})
+	}
+	result := map[string]any{"resources": resources}
+	if offset+resourcePage < len(files) {
+		result["nextCursor"] = strconv.Itoa(offset + resourcePage)
+	}
+	return result, nil
+}
+
+// readResource returns the content of the file at uri, as text if it is
+// valid UTF-8 and base64 otherwise. A bare path is accepted as well.
+func (s *Server) readResource(ctx context.Context, uri string) (any, error) {
+	p := strings.TrimPrefix(uri, uriScheme)
+	if !strings.HasPrefix(p, "/") {
+		return nil, &rpcError{codeInvalidParams, "invalid resource URI " + uri}
+	}
+	data, err := s.afs.FS.ReadFile(ctx, p)
+	if agentfs.IsNotExist(err) {
+		return nil, &rpcError{codeNotFound, "resource not found: " + uri}
+	}
+	if err != nil {
+		return nil, err
+	}
+	content := map[string]string{"uri": uri, "mimeType": mimeType(p)}
+	if utf8.Valid(data) {
+		content["text"] = string(data)
+	} else {
+		content["blob"] = base64.StdEncoding.EncodeToString(data)
+	}
+	return map[string]any{"contents": []map[string]string{content}}, nil
+}
+
+// tailBatch is the most lines sent in one resource update notification.
+const tailBatch = 256
+
+// subscribe follows the file at uri like tail -F until the client
+// unsubscribes or the session ends. The lines appended to it are sent as
+// notifications/resources/updated messages whose params carry the uri and,
+// beyond the MCP fields, the new lines:
+//
+//	{"jsonrpc":"2.0","method":"notifications/resources/updated",
+//	 "params":{"uri":"agentfs:///runs/42/agent.log","lines":["step 3 done"]}}
+//
+// Subscribing again to the same uri restarts the tail.
+func (s *Server) subscribe(ctx context.Context, c *conn, uri string) (any, error) {
+	p := strings.TrimPrefix(uri, uriScheme)
+	if !strings.HasPrefix(p, "/") {
+		return nil, &rpcError{codeInvalidParams, "invalid resource URI " + uri}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	tail, err := s.afs.FS.TailFollow(ctx, p, nil)
+	if err != nil {
+		cancel()
+		if agentfs.IsNotExist(err) {
+			return nil, &rpcError{codeNotFound, "resource not found: " + uri}
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if old := c.subs[uri]; old != nil {
+		old()
+	}
+	c.subs[uri] = cancel
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer cancel()
+		for line := range tail.Lines {
+			lines := []string{line}
+		batch:
+			for len(lines) < tailBatch {
+				select {
+				case line, ok := <-tail.Lines:
+					if !ok {
+						break batch
+					}
+					lines = append(lines, line)
+				default:
+					break batch
+				}
+			}
+			err := c.send(notification{JSONRPC: "2.0", Method: "notifications/resources/updated",
+				Params: map[string]any{"uri": uri, "lines": lines}})
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return struct{}{}, nil
+}
+
+// unsubscribe stops the tail started by subscribe for uri, if any.
+func (s *Server) unsubscribe(c *conn, uri string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel := c.subs[uri]; cancel != nil {
+		cancel()
+		delete(c.subs, uri)
+	}
+	return struct{}{}, nil
+}
+
+// mimeType guesses the media type of the file at p from its extension.
+func mimeType(p string) string {
+	if t := mime.TypeByExtension(path.Ext(p)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
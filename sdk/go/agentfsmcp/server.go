@@ -0,0 +1,284 @@
+// Package agentfsmcp serves an AgentFS as a Model Context Protocol (MCP)
+// server, so LLM clients such as Claude Desktop can read and write the
+// agent workspace natively. The filesystem, KV store, and tool call log are
+// exposed as MCP tools, and files as MCP resources. Subscribing to a file
+// resource tails it: each batch of appended lines is pushed to the client
+// as a notifications/resources/updated message.
+//
+// The server speaks JSON-RPC 2.0 over the MCP stdio transport: one message
+// per line on a reader, answers per line on a writer. cmd/agentfs-mcp runs
+// it for a database:
+//
+//	{
+//	  "mcpServers": {
+//	    "agentfs": {"command": "agentfs-mcp", "args": ["--id", "my-agent"]}
+//	  }
+//	}
+package agentfsmcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// ProtocolVersion is the MCP revision the server implements.
+const ProtocolVersion = "2024-11-05"
+
+// maxMessage is the longest request line accepted.
+const maxMessage = 64 << 20
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternal       = -32603
+	codeNotFound       = -32002 // MCP: resource not found
+)
+
+// Options configures a Server.
+type Options struct {
+	// Tools lists the tools to expose by name (default: nil, all)
+	Tools []string
+	// ReadOnly leaves out the tools that modify the database
+	// (default: false)
+	ReadOnly bool
+	// Name is reported to clients as the server name
+	// (default: "agentfs")
+	Name string
+	// Version is reported to clients as the server version
+	// (default: "dev")
+	Version string
+}
+
+// Server serves one AgentFS to MCP clients.
+//
+// Example:
+//
+//	srv := agentfsmcp.NewServer(afs, agentfsmcp.Options{ReadOnly: true})
+//	if err := srv.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+//	    log.Fatal(err)
+//	}
+type Server struct {
+	afs   *agentfs.AgentFS
+	opts  Options
+	tools []*tool
+}
+
+// NewServer returns a server for afs. It fails if opts.Tools names an
+// unknown tool.
+func NewServer(afs *agentfs.AgentFS, opts Options) (*Server, error) {
+	if opts.Name == "" {
+		opts.Name = "agentfs"
+	}
+	if opts.Version == "" {
+		opts.Version = "dev"
+	}
+	enabled := make(map[string]bool, len(opts.Tools))
+	for _, name := range opts.Tools {
+		if findTool(name) == nil {
+			return nil, fmt.Errorf("unknown tool %q", name)
+		}
+		enabled[name] = true
+	}
+	s := &Server{afs: afs, opts: opts}
+	for _, t := range tools {
+		if (len(enabled) == 0 || enabled[t.name]) && !(opts.ReadOnly && t.writes) {
+			s.tools = append(s.tools, t)
+		}
+	}
+	return s, nil
+}
+
+// request is a JSON-RPC request or notification.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// notification is a JSON-RPC notification sent to the client.
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// conn is the state of one Serve call: the writer shared by responses
+// and notifications, and the resource subscriptions of the client.
+type conn struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	subs map[string]context.CancelFunc // By resource URI
+	wg   sync.WaitGroup
+}
+
+// send writes one message to the client.
+func (c *conn) send(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(v)
+}
+
+// Serve answers the requests read from r, one JSON-RPC message per line,
+// on w until r ends or ctx is canceled. Requests are handled in order;
+// notifications for subscribed resources are written to w between them.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &conn{enc: json.NewEncoder(w), subs: make(map[string]context.CancelFunc)}
+	defer c.wg.Wait()
+	defer cancel()
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), maxMessage)
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		resp := s.handle(ctx, c, sc.Bytes())
+		if resp == nil {
+			continue
+		}
+		if err := c.send(resp); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+	return sc.Err()
+}
+
+// handle answers one message, or returns nil for a notification.
+func (s *Server) handle(ctx context.Context, c *conn, msg []byte) *response {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+			Error: &rpcError{codeParseError, "parse error: " + err.Error()}}
+	}
+	if req.ID == nil {
+		// Notifications, such as notifications/initialized, need no answer
+		return nil
+	}
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{codeInvalidRequest, "invalid request"}
+		return resp
+	}
+
+	result, err := s.call(ctx, c, req.Method, req.Params)
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{codeInternal, err.Error()}
+		}
+		resp.Error = rerr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// call runs the method with its params.
+func (s *Server) call(ctx context.Context, c *conn, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities": map[string]any{
+				"tools":     map[string]any{},
+				"resources": map[string]any{"subscribe": true},
+			},
+			"serverInfo": map[string]string{"name": s.opts.Name, "version": s.opts.Version},
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		defs := make([]map[string]any, len(s.tools))
+		for i, t := range s.tools {
+			defs[i] = map[string]any{
+				"name":        t.name,
+				"description": t.description,
+				"inputSchema": json.RawMessage(t.schema),
+			}
+		}
+		return map[string]any{"tools": defs}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.callTool(ctx, p.Name, p.Arguments)
+	case "resources/list":
+		var p struct {
+			Cursor string `json:"cursor"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.listResources(ctx, p.Cursor)
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.readResource(ctx, p.URI)
+	case "resources/subscribe", "resources/unsubscribe":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if method == "resources/unsubscribe" {
+			return s.unsubscribe(c, p.URI)
+		}
+		return s.subscribe(ctx, c, p.URI)
+	default:
+		return nil, &rpcError{codeMethodNotFound, "unknown method " + method}
+	}
+}
+
+// decodeParams decodes the params of a request into v; missing params
+// leave v zero.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{codeInvalidParams, "invalid params: " + err.Error()}
+	}
+	return nil
+}
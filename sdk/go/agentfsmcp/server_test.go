@@ -0,0 +1,235 @@
+package agentfsmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// session sends the requests to s and returns the responses by ID; a null
+// ID is 0.
+func session(t *testing.T, s *Server, requests ...string) map[int]response {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, req := range requests {
+		in.WriteString(req + "\n")
+	}
+	if err := s.Serve(context.Background(), &in, &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	responses := make(map[int]response)
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp struct {
+			response
+			Result json.RawMessage `json:"result"`
+		}
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		var id int
+		json.Unmarshal(resp.ID, &id)
+		resp.response.Result = resp.Result
+		responses[id] = resp.response
+	}
+	return responses
+}
+
+// toolText returns the text and error flag of a tools/call result.
+func toolText(t *testing.T, resp response) (string, bool) {
+	t.Helper()
+	if resp.Error != nil {
+		t.Fatalf("tools/call failed: %v", resp.Error)
+	}
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil || len(result.Content) != 1 {
+		t.Fatalf("invalid tools/call result %s: %v", resp.Result, err)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestServer_Protocol(t *testing.T) {
+	s, err := NewServer(nil, Options{Tools: []string{"read_file", "kv_set"}, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	responses := session(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"bogus"}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"kv_set","arguments":{}}}`,
+	)
+
+	if got := string(responses[1].Result.(json.RawMessage)); !strings.Contains(got, `"protocolVersion":"2024-11-05"`) {
+		t.Errorf("initialize = %s", got)
+	}
+	var list struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	json.Unmarshal(responses[3].Result.(json.RawMessage), &list)
+	if len(list.Tools) != 1 || list.Tools[0].Name != "read_file" {
+		t.Errorf("tools/list = %+v, want only read_file", list.Tools)
+	}
+	if e := responses[4].Error; e == nil || e.Code != codeMethodNotFound {
+		t.Errorf("unknown method error = %v", e)
+	}
+	if e := responses[0].Error; e == nil || e.Code != codeParseError {
+		t.Errorf("parse error = %v", e)
+	}
+	if e := responses[6].Error; e == nil || e.Code != codeInvalidParams {
+		t.Errorf("disabled tool error = %v", e)
+	}
+	if len(responses) != 5 {
+		t.Errorf("got %d responses, want 5 (none to the notification)", len(responses))
+	}
+
+	if _, err := NewServer(nil, Options{Tools: []string{"format_disk"}}); err == nil {
+		t.Error("NewServer accepted an unknown tool")
+	}
+}
+
+func TestServer_Tools(t *testing.T) {
+	ctx := context.Background()
+	afs, err := agentfs.Open(ctx, agentfs.AgentFSOptions{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	if _, err := afs.Tools.Record(ctx, "web_search", map[string]string{"q": "go"}, "ok", nil, 1, 2); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	s, err := NewServer(afs, Options{})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	id := 0
+	call := func(name, args string) string {
+		id++
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q,"arguments":%s}}`, id, name, args)
+	}
+	responses := session(t, s,
+		call("write_file", `{"path":"/notes/plan.md","content":"# Plan"}`),
+		call("read_file", `{"path":"/notes/plan.md"}`),
+		call("readdir", `{"path":"/notes"}`),
+		call("read_file", `{"path":"/missing"}`),
+		call("kv_set", `{"key":"config","value":{"debug":true}}`),
+		call("kv_get", `{"key":"config"}`),
+		call("tool_calls", `{"name":"web_search"}`),
+		`{"jsonrpc":"2.0","id":8,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":9,"method":"resources/read","params":{"uri":"agentfs:///notes/plan.md"}}`,
+		`{"jsonrpc":"2.0","id":10,"method":"resources/read","params":{"uri":"agentfs:///missing"}}`,
+	)
+
+	if text, isErr := toolText(t, responses[1]); isErr {
+		t.Errorf("write_file failed: %s", text)
+	}
+	if text, _ := toolText(t, responses[2]); text != "# Plan" {
+		t.Errorf("read_file = %q", text)
+	}
+	if text, _ := toolText(t, responses[3]); !strings.Contains(text, `"name": "plan.md"`) || !strings.Contains(text, `"type": "file"`) {
+		t.Errorf("readdir = %s", text)
+	}
+	if _, isErr := toolText(t, responses[4]); !isErr {
+		t.Error("read_file of a missing file did not fail")
+	}
+	if text, _ := toolText(t, responses[6]); text != `{"debug":true}` {
+		t.Errorf("kv_get = %s", text)
+	}
+	if text, _ := toolText(t, responses[7]); !strings.Contains(text, `"name": "web_search"`) {
+		t.Errorf("tool_calls = %s", text)
+	}
+	if got := string(responses[8].Result.(json.RawMessage)); !strings.Contains(got, `"uri":"agentfs:///notes/plan.md"`) {
+		t.Errorf("resources/list = %s", got)
+	}
+	if got := string(responses[9].Result.(json.RawMessage)); !strings.Contains(got, `"text":"# Plan"`) {
+		t.Errorf("resources/read = %s", got)
+	}
+	if e := responses[10].Error; e == nil || e.Code != codeNotFound {
+		t.Errorf("resources/read of a missing file = %v", e)
+	}
+}
+
+func TestServer_Subscribe(t *testing.T) {
+	ctx := context.Background()
+	afs, err := agentfs.Open(ctx, agentfs.AgentFSOptions{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	log, err := afs.FS.OpenLog(ctx, "/agent.log")
+	if err != nil {
+		t.Fatalf("OpenLog failed: %v", err)
+	}
+	defer log.Close()
+	log.WriteLine("before")
+	log.Flush(ctx)
+
+	s, err := NewServer(afs, Options{})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, inR, outW)
+		outW.Close()
+	}()
+	dec := json.NewDecoder(outR)
+	next := func() map[string]json.RawMessage {
+		t.Helper()
+		var msg map[string]json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			t.Fatalf("invalid message: %v", err)
+		}
+		return msg
+	}
+
+	fmt.Fprintln(inW, `{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":"agentfs:///missing"}}`)
+	if msg := next(); !strings.Contains(string(msg["error"]), fmt.Sprint(codeNotFound)) {
+		t.Errorf("subscribe to a missing file = %s", msg["error"])
+	}
+	fmt.Fprintln(inW, `{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":"agentfs:///agent.log"}}`)
+	if msg := next(); msg["error"] != nil {
+		t.Fatalf("subscribe failed: %s", msg["error"])
+	}
+
+	log.WriteLine("step 1")
+	log.Flush(ctx)
+	msg := next()
+	var params struct {
+		URI   string   `json:"uri"`
+		Lines []string `json:"lines"`
+	}
+	json.Unmarshal(msg["params"], &params)
+	if string(msg["method"]) != `"notifications/resources/updated"` || params.URI != "agentfs:///agent.log" ||
+		len(params.Lines) != 1 || params.Lines[0] != "step 1" {
+		t.Errorf("notification = %s %s", msg["method"], msg["params"])
+	}
+
+	fmt.Fprintln(inW, `{"jsonrpc":"2.0","id":3,"method":"resources/unsubscribe","params":{"uri":"agentfs:///agent.log"}}`)
+	if msg := next(); msg["error"] != nil || string(msg["id"]) != "3" {
+		t.Fatalf("unsubscribe = %s %s", msg["id"], msg["error"])
+	}
+	inW.Close()
+	if err := <-served; err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+package agentfsmcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// tool is an MCP tool. call decodes its arguments and returns the text of
+// the result; its errors are reported to the model as a failed call.
+type tool struct {
+	name        string
+	description string
+	schema      string // JSON Schema of the arguments
+	writes      bool   // Modifies the database; left out by ReadOnly
+	call        func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error)
+}
+
+// findTool returns the tool named name, or nil.
+func findTool(name string) *tool {
+	for _, t := range tools {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// callTool runs the enabled tool name. Failures of the tool itself are
+// results with isError set, so the model sees them, as MCP requires.
+func (s *Server) callTool(ctx context.Context, name string, args json.RawMessage) (any, error) {
+	var t *tool
+	for _, enabled := range s.tools {
+		if enabled.name == name {
+			t = enabled
+		}
+	}
+	if t == nil {
+		return nil, &rpcError{codeInvalidParams, "unknown tool " + name}
+	}
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	text, err := t.call(ctx, s.afs, args)
+	isError := err != nil
+	if isError {
+		text = err.Error()
+	}
+	return map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}, nil
+}
+
+// pathArgs are the arguments of tools taking one path.
+type pathArgs struct {
+	Path string `json:"path"`
+}
+
+// kvArgs are the arguments of the KV tools.
+type kvArgs struct {
+	Key    string          `json:"key"`
+	Value  json.RawMessage `json:"value"`
+	Prefix string          `json:"prefix"`
+}
+
+// decodeArgs decodes the arguments of a tool call into v.
+func decodeArgs(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// indent returns v as indented JSON.
+func indent(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
+
+const pathSchema = `{"type":"object","properties":{"path":{"type":"string","description":"Absolute path in the agent filesystem"}},"required":["path"]}`
+
+const keySchema = `{"type":"object","properties":{"key":{"type":"string","description":"Key in the KV store"}},"required":["key"]}`
+
+// tools lists every tool, in the order clients see them.
+var tools = []*tool{
+	{
+		name:        "read_file",
+		description: "Read a file from the agent filesystem",
+		schema:      `{"type":"object","properties":{"path":{"type":"string","description":"Absolute path of the file"},"encoding":{"type":"string","enum":["utf8","base64"],"description":"Encoding of the returned content (default: utf8)"}},"required":["path"]}`,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a struct {
+				Path     string `json:"path"`
+				Encoding string `json:"encoding"`
+			}
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			data, err := afs.FS.ReadFile(ctx, a.Path)
+			if err != nil {
+				return "", err
+			}
+			if a.Encoding == "base64" {
+				return base64.StdEncoding.EncodeToString(data), nil
+			}
+			if !utf8.Valid(data) {
+				return "", fmt.Errorf("%s is not valid UTF-8; use encoding base64", a.Path)
+			}
+			return string(data), nil
+		},
+	},
+	{
+		name:        "write_file",
+		description: "Write a file in the agent filesystem, creating missing parent directories and replacing any existing content",
+		schema:      `{"type":"object","properties":{"path":{"type":"string","description":"Absolute path of the file"},"content":{"type":"string","description":"Content of the file"},"encoding":{"type":"string","enum":["utf8","base64"],"description":"Encoding of content (default: utf8)"}},"required":["path","content"]}`,
+		writes:      true,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a struct {
+				Path     string `json:"path"`
+				Content  string `json:"content"`
+				Encoding string `json:"encoding"`
+			}
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			data := []byte(a.Content)
+			if a.Encoding == "base64" {
+				var err error
+				if data, err = base64.StdEncoding.DecodeString(a.Content); err != nil {
+					return "", fmt.Errorf("invalid base64 content: %w", err)
+				}
+			}
+			if err := afs.FS.WriteFile(ctx, a.Path, data, 0o644); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Wrote %d bytes to %s", len(data), a.Path), nil
+		},
+	},
+	{
+		name:        "readdir",
+		description: "List a directory of the agent filesystem with the type and size of each entry",
+		schema:      pathSchema,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a pathArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			entries, err := afs.FS.ReaddirPlus(ctx, a.Path)
+			if err != nil {
+				return "", err
+			}
+			type entry struct {
+				Name string `json:"name"`
+				Type string `json:"type"`
+				Size int64  `json:"size"`
+			}
+			out := make([]entry, len(entries))
+			for i, e := range entries {
+				out[i] = entry{Name: e.Name, Type: fileType(e.Stats), Size: e.Stats.Size}
+			}
+			return indent(out)
+		},
+	},
+	{
+		name:        "stat",
+		description: "Get the metadata of a file or directory",
+		schema:      pathSchema,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a pathArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			stats, err := afs.FS.Stat(ctx, a.Path)
+			if err != nil {
+				return "", err
+			}
+			return indent(stats)
+		},
+	},
+	{
+		name:        "access",
+		description: "Test whether a path exists",
+		schema:      pathSchema,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a pathArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			_, err := afs.FS.Stat(ctx, a.Path)
+			if err != nil && !agentfs.IsNotExist(err) {
+				return "", err
+			}
+			return indent(map[string]bool{"exists": err == nil})
+		},
+	},
+	{
+		name:        "mkdir",
+		description: "Create a directory and any missing parents",
+		schema:      pathSchema,
+		writes:      true,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a pathArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			if err := afs.FS.MkdirAll(ctx, a.Path, 0o755); err != nil {
+				return "", err
+			}
+			return "Created directory " + a.Path, nil
+		},
+	},
+	{
+		name:        "remove",
+		description: "Remove a file or empty directory",
+		schema:      pathSchema,
+		writes:      true,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a pathArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			stats, err := afs.FS.Lstat(ctx, a.Path)
+			if err != nil {
+				return "", err
+			}
+			if stats.IsDir() {
+				err = afs.FS.Rmdir(ctx, a.Path)
+			} else {
+				err = afs.FS.Unlink(ctx, a.Path)
+			}
+			if err != nil {
+				return "", err
+			}
+			return "Removed " + a.Path, nil
+		},
+	},
+	{
+		name:        "rename",
+		description: "Move or rename a file or directory",
+		schema:      `{"type":"object","properties":{"from":{"type":"string","description":"Source path"},"to":{"type":"string","description":"Destination path"}},"required":["from","to"]}`,
+		writes:      true,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a struct {
+				From string `json:"from"`
+				To   string `json:"to"`
+			}
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			if err := afs.FS.Rename(ctx, a.From, a.To); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Renamed %s to %s", a.From, a.To), nil
+		},
+	},
+	{
+		name:        "kv_get",
+		description: "Get the JSON value of a key in the KV store",
+		schema:      keySchema,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a kvArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			value, err := afs.KV.GetRaw(ctx, a.Key)
+			if err != nil {
+				return "", err
+			}
+			return string(value), nil
+		},
+	},
+	{
+		name:        "kv_set",
+		description: "Set a key in the KV store to a JSON value",
+		schema:      `{"type":"object","properties":{"key":{"type":"string","description":"Key in the KV store"},"value":{"description":"Value to store (any JSON value)"}},"required":["key","value"]}`,
+		writes:      true,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a kvArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			if a.Value == nil {
+				return "", fmt.Errorf("value is required")
+			}
+			if err := afs.KV.Set(ctx, a.Key, a.Value); err != nil {
+				return "", err
+			}
+			return "Set " + a.Key, nil
+		},
+	},
+	{
+		name:        "kv_delete",
+		description: "Delete a key from the KV store",
+		schema:      keySchema,
+		writes:      true,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a kvArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			if err := afs.KV.Delete(ctx, a.Key); err != nil {
+				return "", err
+			}
+			return "Deleted " + a.Key, nil
+		},
+	},
+	{
+		name:        "kv_list",
+		description: "List the entries of the KV store, optionally under a key prefix",
+		schema:      `{"type":"object","properties":{"prefix":{"type":"string","description":"Key prefix (default: all keys)"}}}`,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a kvArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			entries, err := afs.KV.List(ctx, a.Prefix)
+			if err != nil {
+				return "", err
+			}
+			return indent(entries)
+		},
+	},
+	{
+		name:        "tool_calls",
+		description: "List recorded tool calls, newest first, optionally by tool name and status",
+		schema:      `{"type":"object","properties":{"name":{"type":"string","description":"Tool name"},"status":{"type":"string","enum":["success","error"],"description":"Outcome of the calls"},"since":{"type":"integer","description":"Earliest start time, Unix seconds"},"limit":{"type":"integer","description":"Page size (default: 100)"},"cursor":{"type":"string","description":"next_cursor of the previous page"}}}`,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a struct {
+				Name   string `json:"name"`
+				Status string `json:"status"`
+				Since  int64  `json:"since"`
+				Limit  int    `json:"limit"`
+				Cursor string `json:"cursor"`
+			}
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			page, err := afs.Tools.Query(ctx, agentfs.ToolCallQuery{
+				ToolName: a.Name, Status: a.Status, Since: a.Since, Limit: a.Limit, Cursor: a.Cursor,
+			})
+			if err != nil {
+				return "", err
+			}
+			return indent(page)
+		},
+	},
+	{
+		name:        "tool_call_get",
+		description: "Get a recorded tool call by ID",
+		schema:      `{"type":"object","properties":{"id":{"type":"integer","description":"Tool call ID"}},"required":["id"]}`,
+		call: func(ctx context.Context, afs *agentfs.AgentFS, args json.RawMessage) (string, error) {
+			var a struct {
+				ID int64 `json:"id"`
+			}
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			call, err := afs.Tools.Get(ctx, a.ID)
+			if err != nil {
+				return "", err
+			}
+			return indent(call)
+		},
+	},
+}
+
+// fileType names the type of a file for readdir.
+func fileType(s *agentfs.Stats) string {
+	switch {
+	case s.IsDir():
+		return "directory"
+	case s.IsSymlink():
+		return "symlink"
+	case s.IsRegularFile():
+		return "file"
+	default:
+		return "other"
+	}
+}
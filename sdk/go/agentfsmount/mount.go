@@ -0,0 +1,602 @@
+// Package agentfsmount mounts an AgentFS filesystem with FUSE, so ordinary
+// tools (grep, editors, shells, build systems) can work on agent files
+// directly:
+//
+//	srv, err := agentfsmount.Mount(afs, "/mnt/agent", nil)
+//	if err != nil {
+//		return err
+//	}
+//	srv.Wait() // until `fusermount -u /mnt/agent` or srv.Unmount()
+//
+// Every FUSE request is served by the matching Filesystem method: files
+// are read and written a chunk at a time through File.Pread and
+// File.Pwrite, inode numbers, modes, owners, and nanosecond timestamps
+// are those of the stats, hard links and special files are supported,
+// and custom metadata (see Filesystem.SetMeta) is exposed as "user."
+// extended attributes. Writes are committed as they are made, so other
+// readers of the database see them at once; the kernel caches attributes
+// and names for Options.Timeout, so changes made by other writers show
+// through the mount after at most that long.
+//
+// Mounting needs /dev/fuse and fusermount (fusermount3) on Linux, or
+// macFUSE on macOS. cmd/agentfs-mount mounts a database from the command
+// line.
+package agentfsmount
+
+import (
+	"context"
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// DefaultTimeout is how long the kernel caches attributes and names unless
+// Options.Timeout is set.
+const DefaultTimeout = time.Second
+
+// xattrPrefix is the namespace of the extended attributes holding custom
+// metadata: the metadata key k is the attribute "user.k".
+const xattrPrefix = "user."
+
+// statfsBlockSize is the block size Statfs reports usage in.
+const statfsBlockSize = 4096
+
+// renameNoReplace is the RENAME_NOREPLACE flag of FUSE renames.
+const renameNoReplace = 1
+
+// errNoAttr is the errno of a missing extended attribute.
+const errNoAttr = syscall.Errno(fuse.ENOATTR)
+
+// Options configures Mount.
+type Options struct {
+	// ReadOnly mounts the filesystem read-only, so the kernel refuses
+	// every change with EROFS. Default: false.
+	ReadOnly bool
+
+	// AllowOther lets users other than the one mounting access the
+	// mount. It needs user_allow_other in /etc/fuse.conf unless mounting
+	// as root. Default: false.
+	AllowOther bool
+
+	// Timeout is how long the kernel caches attributes and names before
+	// asking again. Longer timeouts make tree walks faster but delay
+	// seeing changes made by other writers of the database.
+	// Default: DefaultTimeout.
+	Timeout time.Duration
+
+	// Debug logs every FUSE request and reply. Default: false.
+	Debug bool
+}
+
+// A Server serves a mounted filesystem.
+type Server struct {
+	dir    string
+	server *fuse.Server
+}
+
+// Mount mounts the filesystem of afs at dir, which must be an existing
+// directory, and returns once the mount is ready. The mount is served in
+// the background until it is unmounted with Unmount or with fusermount -u;
+// afs must stay open until then.
+func Mount(afs *agentfs.AgentFS, dir string, opts *Options) (*Server, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	var mountOpts []string
+	if o.ReadOnly {
+		mountOpts = append(mountOpts, "ro")
+	}
+	server, err := fs.Mount(dir, &node{root: &root{afs: afs}}, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName:     "agentfs",
+			Name:       "agentfs",
+			AllowOther: o.AllowOther,
+			Options:    mountOpts,
+			Debug:      o.Debug,
+			// Mounts with mount(2) where permitted, as root, and with
+			// fusermount otherwise
+			DirectMount: true,
+		},
+		EntryTimeout:    &o.Timeout,
+		AttrTimeout:     &o.Timeout,
+		NegativeTimeout: &o.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{dir: dir, server: server}, nil
+}
+
+// Dir returns the directory the filesystem is mounted at.
+func (s *Server) Dir() string {
+	return s.dir
+}
+
+// Unmount unmounts the filesystem. It fails with EBUSY while files in the
+// mount are open.
+func (s *Server) Unmount() error {
+	return s.server.Unmount()
+}
+
+// Wait blocks until the filesystem is unmounted.
+func (s *Server) Wait() {
+	s.server.Wait()
+}
+
+// root holds what the nodes of a mount share.
+type root struct {
+	afs *agentfs.AgentFS
+}
+
+// node is a file, directory, symlink, or special file of the mount. It is
+// addressed by its path, which go-fuse tracks across renames.
+type node struct {
+	fs.Inode
+	root *root
+}
+
+var (
+	_ fs.NodeLookuper      = (*node)(nil)
+	_ fs.NodeGetattrer     = (*node)(nil)
+	_ fs.NodeSetattrer     = (*node)(nil)
+	_ fs.NodeReaddirer     = (*node)(nil)
+	_ fs.NodeOpener        = (*node)(nil)
+	_ fs.NodeCreater       = (*node)(nil)
+	_ fs.NodeMkdirer       = (*node)(nil)
+	_ fs.NodeMknoder       = (*node)(nil)
+	_ fs.NodeUnlinker      = (*node)(nil)
+	_ fs.NodeRmdirer       = (*node)(nil)
+	_ fs.NodeRenamer       = (*node)(nil)
+	_ fs.NodeSymlinker     = (*node)(nil)
+	_ fs.NodeReadlinker    = (*node)(nil)
+	_ fs.NodeLinker        = (*node)(nil)
+	_ fs.NodeStatfser      = (*node)(nil)
+	_ fs.NodeGetxattrer    = (*node)(nil)
+	_ fs.NodeSetxattrer    = (*node)(nil)
+	_ fs.NodeRemovexattrer = (*node)(nil)
+	_ fs.NodeListxattrer   = (*node)(nil)
+)
+
+func (n *node) fs() *agentfs.Filesystem {
+	return n.root.afs.FS
+}
+
+// path returns the path of n in the filesystem.
+func (n *node) path() string {
+	return "/" + n.Path(nil)
+}
+
+// child returns the path of the entry name of directory n.
+func (n *node) child(name string) string {
+	return path.Join(n.path(), name)
+}
+
+// newChild returns the inode of the entry of n with stats, filling out.
+func (n *node) newChild(ctx context.Context, stats *agentfs.Stats, out *fuse.EntryOut) *fs.Inode {
+	fillAttr(stats, &out.Attr)
+	child := &node{root: n.root}
+	return n.NewInode(ctx, child, fs.StableAttr{Mode: uint32(stats.Mode & agentfs.S_IFMT), Ino: uint64(stats.Ino)})
+}
+
+// lookup stats the entry name of n and returns its inode.
+func (n *node) lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	stats, err := n.fs().Lstat(ctx, n.child(name))
+	if err != nil {
+		return nil, errno(err)
+	}
+	return n.newChild(ctx, stats, out), 0
+}
+
+// Lookup implements fs.NodeLookuper.
+func (n *node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return n.lookup(ctx, name, out)
+}
+
+// Getattr implements fs.NodeGetattrer. An open file is stat'ed by inode,
+// so it keeps its attributes after it is unlinked.
+func (n *node) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	var stats *agentfs.Stats
+	var err error
+	if h, ok := fh.(*handle); ok {
+		stats, err = h.f.Stat(ctx)
+	} else {
+		stats, err = n.fs().Lstat(ctx, n.path())
+	}
+	if err != nil {
+		return errno(err)
+	}
+	fillAttr(stats, &out.Attr)
+	return 0
+}
+
+// Setattr implements fs.NodeSetattrer.
+func (n *node) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	p := n.path()
+	if mode, ok := in.GetMode(); ok {
+		if err := n.fs().Chmod(ctx, p, int64(mode&0o7777)); err != nil {
+			return errno(err)
+		}
+	}
+	uid, uok := in.GetUID()
+	gid, gok := in.GetGID()
+	if uok || gok {
+		stats, err := n.fs().Lstat(ctx, p)
+		if err != nil {
+			return errno(err)
+		}
+		if uok {
+			stats.UID = int64(uid)
+		}
+		if gok {
+			stats.GID = int64(gid)
+		}
+		if err := n.fs().Chown(ctx, p, stats.UID, stats.GID); err != nil {
+			return errno(err)
+		}
+	}
+	if size, ok := in.GetSize(); ok {
+		if err := n.truncate(ctx, fh, int64(size)); err != nil {
+			return errno(err)
+		}
+	}
+	atime, aok := in.GetATime()
+	mtime, mok := in.GetMTime()
+	if aok || mok {
+		if err := n.fs().Utimens(ctx, p, timeChange(atime, aok), timeChange(mtime, mok)); err != nil {
+			return errno(err)
+		}
+	}
+	return n.Getattr(ctx, fh, out)
+}
+
+// truncate sets the size of n, through the open file fh if there is one.
+func (n *node) truncate(ctx context.Context, fh fs.FileHandle, size int64) error {
+	if h, ok := fh.(*handle); ok {
+		return h.f.Truncate(ctx, size)
+	}
+	f, err := n.fs().Open(ctx, n.path(), agentfs.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Truncate(ctx, size)
+}
+
+// Readdir implements fs.NodeReaddirer.
+func (n *node) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries, err := n.fs().ReaddirPlus(ctx, n.path())
+	if err != nil {
+		return nil, errno(err)
+	}
+	list := make([]fuse.DirEntry, len(entries))
+	for i, e := range entries {
+		list[i] = fuse.DirEntry{Name: e.Name, Mode: uint32(e.Stats.Mode), Ino: uint64(e.Stats.Ino)}
+	}
+	return fs.NewListDirStream(list), 0
+}
+
+// Open implements fs.NodeOpener.
+func (n *node) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	f, err := n.fs().Open(ctx, n.path(), openFlags(flags))
+	if err != nil {
+		return nil, 0, errno(err)
+	}
+	return &handle{f: f, append: flags&syscall.O_APPEND != 0}, 0, 0
+}
+
+// Create implements fs.NodeCreater.
+func (n *node) Create(ctx context.Context, name string, flags, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	p := n.child(name)
+	_, f, err := n.fs().CreateExclusive(ctx, p, int64(mode&0o7777))
+	if agentfs.IsExist(err) && flags&syscall.O_EXCL == 0 {
+		// Created by another writer since the kernel looked it up
+		f, err = n.fs().Open(ctx, p, openFlags(flags))
+	}
+	if err != nil {
+		return nil, nil, 0, errno(err)
+	}
+	stats, err := f.Stat(ctx)
+	if err != nil {
+		f.Close()
+		return nil, nil, 0, errno(err)
+	}
+	return n.newChild(ctx, stats, out), &handle{f: f, append: flags&syscall.O_APPEND != 0}, 0, 0
+}
+
+// Mkdir implements fs.NodeMkdirer.
+func (n *node) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if err := n.fs().Mkdir(ctx, n.child(name), int64(mode&0o7777)); err != nil {
+		return nil, errno(err)
+	}
+	return n.lookup(ctx, name, out)
+}
+
+// Mknod implements fs.NodeMknoder.
+func (n *node) Mknod(ctx context.Context, name string, mode, dev uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if err := n.fs().Mknod(ctx, n.child(name), int64(mode), int64(dev)); err != nil {
+		return nil, errno(err)
+	}
+	return n.lookup(ctx, name, out)
+}
+
+// Unlink implements fs.NodeUnlinker.
+func (n *node) Unlink(ctx context.Context, name string) syscall.Errno {
+	return errno(n.fs().Unlink(ctx, n.child(name)))
+}
+
+// Rmdir implements fs.NodeRmdirer.
+func (n *node) Rmdir(ctx context.Context, name string) syscall.Errno {
+	return errno(n.fs().Rmdir(ctx, n.child(name)))
+}
+
+// Rename implements fs.NodeRenamer. RENAME_NOREPLACE is supported;
+// RENAME_EXCHANGE is not.
+func (n *node) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	dir, ok := newParent.(*node)
+	if !ok || dir.root != n.root {
+		return syscall.EXDEV
+	}
+	src, dst := n.child(name), dir.child(newName)
+	switch flags {
+	case 0:
+	case renameNoReplace:
+		if _, err := n.fs().Lstat(ctx, dst); err == nil {
+			return syscall.EEXIST
+		} else if !agentfs.IsNotExist(err) {
+			return errno(err)
+		}
+	default:
+		return syscall.EINVAL
+	}
+	return errno(n.fs().Rename(ctx, src, dst))
+}
+
+// Symlink implements fs.NodeSymlinker.
+func (n *node) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if err := n.fs().Symlink(ctx, target, n.child(name)); err != nil {
+		return nil, errno(err)
+	}
+	return n.lookup(ctx, name, out)
+}
+
+// Readlink implements fs.NodeReadlinker.
+func (n *node) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	target, err := n.fs().Readlink(ctx, n.path())
+	if err != nil {
+		return nil, errno(err)
+	}
+	return []byte(target), 0
+}
+
+// Link implements fs.NodeLinker.
+func (n *node) Link(ctx context.Context, target fs.InodeEmbedder, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	existing, ok := target.(*node)
+	if !ok || existing.root != n.root {
+		return nil, syscall.EXDEV
+	}
+	if err := n.fs().Link(ctx, existing.path(), n.child(name)); err != nil {
+		return nil, errno(err)
+	}
+	return n.lookup(ctx, name, out)
+}
+
+// Statfs implements fs.NodeStatfser. It reports the inodes and bytes the
+// filesystem uses, and the free space of the host filesystem holding the
+// database as what is left.
+func (n *node) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	stats, err := n.fs().Statfs(ctx)
+	if err != nil {
+		return errno(err)
+	}
+	var free uint64
+	if p := n.root.afs.Path(); p != "" && p != ":memory:" {
+		var host unix.Statfs_t
+		if unix.Statfs(filepath.Dir(p), &host) == nil {
+			free = uint64(host.Bavail) * uint64(host.Bsize) / statfsBlockSize
+		}
+	}
+	used := (uint64(stats.BytesUsed) + statfsBlockSize - 1) / statfsBlockSize
+	out.Bsize = statfsBlockSize
+	out.Frsize = statfsBlockSize
+	out.Blocks = used + free
+	out.Bfree = free
+	out.Bavail = free
+	out.Files = uint64(stats.Inodes)
+	out.NameLen = 255
+	return 0
+}
+
+// Getxattr implements fs.NodeGetxattrer.
+func (n *node) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	key, ok := strings.CutPrefix(attr, xattrPrefix)
+	if !ok {
+		return 0, errNoAttr
+	}
+	value, ok, err := n.fs().GetMeta(ctx, n.path(), key)
+	if err != nil {
+		return 0, errno(err)
+	}
+	if !ok {
+		return 0, errNoAttr
+	}
+	if len(dest) < len(value) {
+		return uint32(len(value)), syscall.ERANGE
+	}
+	return uint32(copy(dest, value)), 0
+}
+
+// Setxattr implements fs.NodeSetxattrer.
+func (n *node) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
+	key, ok := strings.CutPrefix(attr, xattrPrefix)
+	if !ok {
+		return syscall.ENOTSUP
+	}
+	if flags&(unix.XATTR_CREATE|unix.XATTR_REPLACE) != 0 {
+		_, exists, err := n.fs().GetMeta(ctx, n.path(), key)
+		switch {
+		case err != nil:
+			return errno(err)
+		case exists && flags&unix.XATTR_CREATE != 0:
+			return syscall.EEXIST
+		case !exists && flags&unix.XATTR_REPLACE != 0:
+			return errNoAttr
+		}
+	}
+	return errno(n.fs().SetMeta(ctx, n.path(), key, string(data)))
+}
+
+// Removexattr implements fs.NodeRemovexattrer.
+func (n *node) Removexattr(ctx context.Context, attr string) syscall.Errno {
+	key, ok := strings.CutPrefix(attr, xattrPrefix)
+	if !ok {
+		return errNoAttr
+	}
+	_, exists, err := n.fs().GetMeta(ctx, n.path(), key)
+	if err != nil {
+		return errno(err)
+	}
+	if !exists {
+		return errNoAttr
+	}
+	return errno(n.fs().DeleteMeta(ctx, n.path(), key))
+}
+
+// Listxattr implements fs.NodeListxattrer.
+func (n *node) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	meta, err := n.fs().Meta(ctx, n.path())
+	if err != nil {
+		return 0, errno(err)
+	}
+	var names []byte
+	for key := range meta {
+		names = append(names, xattrPrefix+key+"\x00"...)
+	}
+	if len(dest) < len(names) {
+		return uint32(len(names)), syscall.ERANGE
+	}
+	return uint32(copy(dest, names)), 0
+}
+
+// handle is an open file of the mount.
+type handle struct {
+	f      *agentfs.File
+	append bool
+}
+
+var (
+	_ fs.FileReader    = (*handle)(nil)
+	_ fs.FileWriter    = (*handle)(nil)
+	_ fs.FileFsyncer   = (*handle)(nil)
+	_ fs.FileReleaser  = (*handle)(nil)
+	_ fs.FileGetattrer = (*handle)(nil)
+)
+
+// Read implements fs.FileReader.
+func (h *handle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := h.f.Pread(ctx, dest, off)
+	if err != nil {
+		return nil, errno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+// Write implements fs.FileWriter. A file opened with O_APPEND is written
+// at its end, which may have moved since the kernel last saw its size.
+func (h *handle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	if h.append {
+		stats, err := h.f.Stat(ctx)
+		if err != nil {
+			return 0, errno(err)
+		}
+		off = stats.Size
+	}
+	n, err := h.f.Pwrite(ctx, data, off)
+	if err != nil {
+		return uint32(n), errno(err)
+	}
+	return uint32(n), 0
+}
+
+// Fsync implements fs.FileFsyncer.
+func (h *handle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return errno(h.f.Fsync(ctx))
+}
+
+// Release implements fs.FileReleaser.
+func (h *handle) Release(ctx context.Context) syscall.Errno {
+	return errno(h.f.Close())
+}
+
+// Getattr implements fs.FileGetattrer.
+func (h *handle) Getattr(ctx context.Context, out *fuse.AttrOut) syscall.Errno {
+	stats, err := h.f.Stat(ctx)
+	if err != nil {
+		return errno(err)
+	}
+	fillAttr(stats, &out.Attr)
+	return 0
+}
+
+// openFlags returns the flags of a FUSE open as flags of Filesystem.Open.
+// O_APPEND is left to the handle.
+func openFlags(flags uint32) int {
+	return int(flags) & (agentfs.O_WRONLY | agentfs.O_RDWR | agentfs.O_TRUNC)
+}
+
+// fillAttr fills out with stats.
+func fillAttr(stats *agentfs.Stats, out *fuse.Attr) {
+	out.Ino = uint64(stats.Ino)
+	out.Mode = uint32(stats.Mode)
+	out.Nlink = uint32(stats.Nlink)
+	out.Owner = fuse.Owner{Uid: uint32(stats.UID), Gid: uint32(stats.GID)}
+	out.Size = uint64(stats.Size)
+	out.Blocks = (uint64(stats.Size) + 511) / 512
+	out.Blksize = statfsBlockSize
+	out.Rdev = uint32(stats.Rdev)
+	out.Atime, out.Atimensec = uint64(stats.Atime), uint32(stats.AtimeNsec)
+	out.Mtime, out.Mtimensec = uint64(stats.Mtime), uint32(stats.MtimeNsec)
+	out.Ctime, out.Ctimensec = uint64(stats.Ctime), uint32(stats.CtimeNsec)
+}
+
+// timeChange returns t as the change of a timestamp, or TimeOmit if the
+// timestamp is not set.
+func timeChange(t time.Time, set bool) agentfs.TimeChange {
+	if !set {
+		return agentfs.TimeOmit()
+	}
+	return agentfs.TimeSet(t.Unix(), int64(t.Nanosecond()))
+}
+
+// errno returns the errno of err: the POSIX code of an FSError, or EIO.
+func errno(err error) syscall.Errno {
+	if err == nil {
+		return 0
+	}
+	var fsErr *agentfs.FSError
+	switch {
+	case errors.As(err, &fsErr) && fsErr.Code != 0:
+		return syscall.Errno(fsErr.Code)
+	case agentfs.IsNotExist(err):
+		return syscall.ENOENT
+	case agentfs.IsExist(err):
+		return syscall.EEXIST
+	case agentfs.IsPermission(err), errors.Is(err, agentfs.ErrReserved):
+		return syscall.EPERM
+	case errors.Is(err, context.Canceled):
+		return syscall.EINTR
+	}
+	return syscall.EIO
+}
@@ -0,0 +1,155 @@
+package agentfsmount
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// mount mounts a new database in a temporary directory, skipping the test
+// where FUSE is not available.
+func mount(t *testing.T, opts *Options) (*agentfs.AgentFS, string) {
+	t.Helper()
+	afs, err := agentfs.Open(context.Background(), agentfs.AgentFSOptions{
+		Path: filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	dir := t.TempDir()
+	srv, err := Mount(afs, dir, opts)
+	if err != nil {
+		afs.Close()
+		t.Skipf("FUSE is not available: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := srv.Unmount(); err != nil {
+			t.Errorf("Unmount failed: %v", err)
+		}
+		srv.Wait()
+		afs.Close()
+	})
+	return afs, dir
+}
+
+func TestMount(t *testing.T) {
+	ctx := context.Background()
+	afs, dir := mount(t, nil)
+	at := func(p string) string { return filepath.Join(dir, p) }
+
+	// Writes through the mount land in the database
+	if err := os.MkdirAll(at("src/pkg"), 0o750); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(at("src/pkg/main.go"), []byte("package main\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if data, err := afs.FS.ReadFile(ctx, "/src/pkg/main.go"); err != nil || string(data) != "package main\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if stats, _ := afs.FS.Stat(ctx, "/src/pkg"); stats == nil || stats.Mode&0o777 != 0o750 {
+		t.Errorf("mode of the directory = %v", stats)
+	}
+
+	// Writes to the database show through the mount
+	afs.FS.WriteFile(ctx, "/notes.txt", []byte("one\n"), 0o644)
+	f, err := os.OpenFile(at("notes.txt"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.WriteString("two\n")
+	f.Close()
+	if data, err := os.ReadFile(at("notes.txt")); err != nil || string(data) != "one\ntwo\n" {
+		t.Errorf("content after append = %q, %v", data, err)
+	}
+	if err := os.Truncate(at("notes.txt"), 3); err != nil {
+		t.Errorf("Truncate failed: %v", err)
+	}
+	if fi, err := os.Stat(at("notes.txt")); err != nil || fi.Size() != 3 {
+		t.Errorf("Stat after truncate = %v, %v", fi, err)
+	}
+
+	// Attributes map to the stats
+	if err := os.Chmod(at("notes.txt"), 0o600); err != nil {
+		t.Errorf("Chmod failed: %v", err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(at("notes.txt"), &st); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	stats, _ := afs.FS.Stat(ctx, "/notes.txt")
+	if st.Ino != uint64(stats.Ino) || st.Mode != uint32(stats.Mode) || st.Mode&0o777 != 0o600 {
+		t.Errorf("stat = ino %d mode %o, want ino %d mode %o", st.Ino, st.Mode, stats.Ino, stats.Mode)
+	}
+
+	// Links, renames, and removals
+	if err := os.Symlink("notes.txt", at("link")); err != nil {
+		t.Errorf("Symlink failed: %v", err)
+	}
+	if target, err := afs.FS.Readlink(ctx, "/link"); err != nil || target != "notes.txt" {
+		t.Errorf("Readlink = %q, %v", target, err)
+	}
+	if err := os.Link(at("notes.txt"), at("hard.txt")); err != nil {
+		t.Errorf("Link failed: %v", err)
+	}
+	if stats, _ := afs.FS.Stat(ctx, "/notes.txt"); stats == nil || stats.Nlink != 2 {
+		t.Errorf("Nlink after Link = %v", stats)
+	}
+	if err := os.Rename(at("hard.txt"), at("src/hard.txt")); err != nil {
+		t.Errorf("Rename failed: %v", err)
+	}
+	if err := unix.Renameat2(unix.AT_FDCWD, at("src/hard.txt"), unix.AT_FDCWD, at("notes.txt"), unix.RENAME_NOREPLACE); !errors.Is(err, unix.EEXIST) {
+		t.Errorf("Renameat2 with RENAME_NOREPLACE onto a file = %v, want EEXIST", err)
+	}
+	if err := os.Remove(at("src/hard.txt")); err != nil {
+		t.Errorf("Remove failed: %v", err)
+	}
+	if err := os.Remove(at("src")); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("Remove of a non-empty directory = %v, want ENOTEMPTY", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 3 {
+		t.Errorf("ReadDir = %v, %v", entries, err)
+	}
+
+	// Custom metadata is exposed as user. extended attributes
+	if err := unix.Setxattr(at("notes.txt"), "user.reviewer", []byte("ana"), 0); err != nil {
+		t.Fatalf("Setxattr failed: %v", err)
+	}
+	if v, ok, _ := afs.FS.GetMeta(ctx, "/notes.txt", "reviewer"); !ok || v != "ana" {
+		t.Errorf("GetMeta = %q, %v", v, ok)
+	}
+	buf := make([]byte, 16)
+	if n, err := unix.Getxattr(at("notes.txt"), "user.reviewer", buf); err != nil || string(buf[:n]) != "ana" {
+		t.Errorf("Getxattr = %q, %v", buf[:n], err)
+	}
+	if n, err := unix.Listxattr(at("notes.txt"), buf); err != nil || string(buf[:n]) != "user.reviewer\x00" {
+		t.Errorf("Listxattr = %q, %v", buf[:n], err)
+	}
+	if err := unix.Removexattr(at("notes.txt"), "user.reviewer"); err != nil {
+		t.Errorf("Removexattr failed: %v", err)
+	}
+	if _, err := unix.Getxattr(at("notes.txt"), "user.reviewer", buf); !errors.Is(err, unix.ENODATA) {
+		t.Errorf("Getxattr of a removed attribute = %v, want ENODATA", err)
+	}
+}
+
+func TestMount_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	afs, dir := mount(t, &Options{ReadOnly: true})
+	afs.FS.WriteFile(ctx, "/report.md", []byte("# Report\n"), 0o644)
+
+	if data, err := os.ReadFile(filepath.Join(dir, "report.md")); err != nil || string(data) != "# Report\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), nil, 0o644); !errors.Is(err, syscall.EROFS) {
+		t.Errorf("WriteFile on a read-only mount = %v, want EROFS", err)
+	}
+}
@@ -0,0 +1,612 @@
+// Package agentfssftp serves an AgentFS filesystem over SFTP, so operators
+// can browse and fetch agent artifacts with standard sftp and scp tooling
+// from remote machines.
+//
+// The server speaks SFTP version 3, the version OpenSSH implements, with
+// the posix-rename, hardlink, and fsync extensions of OpenSSH. It leaves
+// SSH itself to sshd: like OpenSSH's sftp-server, it reads requests from
+// the subsystem's stdin and answers on its stdout. cmd/agentfs-sftp-server
+// runs it for a database, set up in sshd_config as
+//
+//	Subsystem sftp /usr/local/bin/agentfs-sftp-server --id my-agent
+//
+// or, to keep the regular sftp-server for other users,
+//
+//	Match User agent-artifacts
+//	    ForceCommand /usr/local/bin/agentfs-sftp-server --id my-agent --read-only
+//
+// The database root is the root of the served tree; clients cannot reach
+// the host filesystem.
+package agentfssftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// Version is the SFTP protocol version the server speaks.
+const Version = 3
+
+// readdirBatch is the most entries answered to one SSH_FXP_READDIR.
+const readdirBatch = 100
+
+// extensions are the OpenSSH extensions the server announces.
+var extensions = [][2]string{
+	{"posix-rename@openssh.com", "1"},
+	{"hardlink@openssh.com", "1"},
+	{"fsync@openssh.com", "1"},
+}
+
+var (
+	// errReadOnly is returned for requests that would modify a read-only
+	// server.
+	errReadOnly = errors.New("agentfssftp: server is read-only")
+	// errBadHandle is returned for requests naming no open handle of the
+	// right kind.
+	errBadHandle = errors.New("agentfssftp: invalid handle")
+	// errUnsupported is returned for unknown requests and extensions.
+	errUnsupported = errors.New("agentfssftp: operation not supported")
+)
+
+// Options configures a Server.
+type Options struct {
+	// ReadOnly refuses requests that modify the filesystem (default: false)
+	ReadOnly bool
+}
+
+// Server serves one AgentFS to SFTP clients.
+//
+// Example:
+//
+//	srv := agentfssftp.NewServer(afs, agentfssftp.Options{ReadOnly: true})
+//	if err := srv.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+//	    log.Fatal(err)
+//	}
+type Server struct {
+	afs  *agentfs.AgentFS
+	opts Options
+}
+
+// NewServer returns a server for afs.
+func NewServer(afs *agentfs.AgentFS, opts Options) *Server {
+	return &Server{afs: afs, opts: opts}
+}
+
+// Serve answers the SFTP requests read from r on w until r ends or ctx is
+// canceled. Requests are handled in order. Handles the client left open
+// are closed when Serve returns.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	sess := &session{Server: s, handles: make(map[string]*handle)}
+	defer sess.closeAll()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		body, err := readPacket(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := writePacket(w, sess.handle(ctx, body)); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+}
+
+// handle is an open file or directory.
+type handle struct {
+	path     string
+	file     *agentfs.File // nil for directories
+	writable bool
+	append   bool
+	entries  []agentfs.DirEntry // Directory entries not yet read
+}
+
+// session is the state of one client: its open handles.
+type session struct {
+	*Server
+	handles    map[string]*handle
+	nextHandle uint64
+}
+
+// closeAll closes the handles left open.
+func (s *session) closeAll() {
+	for name, h := range s.handles {
+		if h.file != nil {
+			h.file.Close()
+		}
+		delete(s.handles, name)
+	}
+}
+
+// handle answers one packet.
+func (s *session) handle(ctx context.Context, body []byte) []byte {
+	d := &decoder{b: body}
+	typ := d.byte()
+	if typ == fxpInit {
+		// Clients speaking later versions fall back to ours
+		e := &encoder{}
+		e.byte(fxpVersion)
+		e.uint32(Version)
+		for _, ext := range extensions {
+			e.string(ext[0])
+			e.string(ext[1])
+		}
+		return e.b
+	}
+	id := d.uint32()
+	reply, err := s.dispatch(ctx, typ, id, d)
+	if err != nil {
+		return statusReply(id, err)
+	}
+	return reply
+}
+
+// dispatch runs the request of type typ, whose fields d holds.
+func (s *session) dispatch(ctx context.Context, typ byte, id uint32, d *decoder) ([]byte, error) {
+	switch typ {
+	case fxpOpen:
+		p, pflags, a := d.string(), d.uint32(), d.attrs()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.open(ctx, id, resolve(p), pflags, a)
+	case fxpClose:
+		name := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		h, ok := s.handles[name]
+		if !ok {
+			return nil, errBadHandle
+		}
+		delete(s.handles, name)
+		if h.file != nil {
+			if err := h.file.Close(); err != nil {
+				return nil, err
+			}
+		}
+		return statusReply(id, nil), nil
+	case fxpRead:
+		name, off, length := d.string(), d.uint64(), d.uint32()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.read(ctx, id, name, int64(off), int(min(length, maxRead)))
+	case fxpWrite:
+		name, off, data := d.string(), d.uint64(), d.bytes()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.write(ctx, id, name, int64(off), data)
+	case fxpLstat, fxpStat:
+		p := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		stat := s.afs.FS.Stat
+		if typ == fxpLstat {
+			stat = s.afs.FS.Lstat
+		}
+		stats, err := stat(ctx, resolve(p))
+		if err != nil {
+			return nil, err
+		}
+		return attrsReply(id, stats), nil
+	case fxpFstat:
+		name := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		h, ok := s.handles[name]
+		if !ok {
+			return nil, errBadHandle
+		}
+		var stats *agentfs.Stats
+		var err error
+		if h.file != nil {
+			stats, err = h.file.Stat(ctx)
+		} else {
+			stats, err = s.afs.FS.Stat(ctx, h.path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return attrsReply(id, stats), nil
+	case fxpSetstat, fxpFsetstat:
+		p, a := d.string(), d.attrs()
+		if d.err != nil {
+			return nil, d.err
+		}
+		if typ == fxpFsetstat {
+			h, ok := s.handles[p]
+			if !ok {
+				return nil, errBadHandle
+			}
+			p = h.path
+		} else {
+			p = resolve(p)
+		}
+		if s.opts.ReadOnly {
+			return nil, errReadOnly
+		}
+		if err := s.setstat(ctx, p, a); err != nil {
+			return nil, err
+		}
+		return statusReply(id, nil), nil
+	case fxpOpendir:
+		p := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		p = resolve(p)
+		entries, err := s.afs.FS.ReaddirPlus(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		return s.newHandle(id, &handle{path: p, entries: entries}), nil
+	case fxpReaddir:
+		name := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.readdir(id, name)
+	case fxpRemove, fxpRmdir:
+		p := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		if s.opts.ReadOnly {
+			return nil, errReadOnly
+		}
+		remove := s.afs.FS.Unlink
+		if typ == fxpRmdir {
+			remove = s.afs.FS.Rmdir
+		}
+		if err := remove(ctx, resolve(p)); err != nil {
+			return nil, err
+		}
+		return statusReply(id, nil), nil
+	case fxpMkdir:
+		p, a := d.string(), d.attrs()
+		if d.err != nil {
+			return nil, d.err
+		}
+		mode := int64(0o755)
+		if a.flags&attrPermissions != 0 {
+			mode = int64(a.permissions & 0o7777)
+		}
+		return s.modify(ctx, id, resolve(p), func() error {
+			return s.afs.FS.Mkdir(ctx, resolve(p), mode)
+		})
+	case fxpRealpath:
+		p := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		p = resolve(p)
+		e := newReply(fxpName, id)
+		e.uint32(1)
+		e.string(p)
+		e.string(p)
+		e.attrs(attrs{})
+		return e.b, nil
+	case fxpRename:
+		oldPath, newPath := d.string(), d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		// SFTP renames never replace the target; posix-rename does
+		return s.modify(ctx, id, resolve(newPath), func() error {
+			return s.afs.FS.MoveTree(ctx, resolve(oldPath), resolve(newPath))
+		})
+	case fxpReadlink:
+		p := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		target, err := s.afs.FS.Readlink(ctx, resolve(p))
+		if err != nil {
+			return nil, err
+		}
+		e := newReply(fxpName, id)
+		e.uint32(1)
+		e.string(target)
+		e.string(target)
+		e.attrs(attrs{})
+		return e.b, nil
+	case fxpSymlink:
+		// OpenSSH sends the target before the link path, the reverse of
+		// the draft; every common client follows it
+		target, linkPath := d.string(), d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.modify(ctx, id, resolve(linkPath), func() error {
+			return s.afs.FS.Symlink(ctx, target, resolve(linkPath))
+		})
+	case fxpExtended:
+		ext := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.extended(ctx, id, ext, d)
+	}
+	return nil, errUnsupported
+}
+
+// extended runs the request of the extension named ext.
+func (s *session) extended(ctx context.Context, id uint32, ext string, d *decoder) ([]byte, error) {
+	switch ext {
+	case "posix-rename@openssh.com":
+		oldPath, newPath := d.string(), d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.modify(ctx, id, resolve(newPath), func() error {
+			return s.afs.FS.Rename(ctx, resolve(oldPath), resolve(newPath))
+		})
+	case "hardlink@openssh.com":
+		oldPath, newPath := d.string(), d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		return s.modify(ctx, id, resolve(newPath), func() error {
+			return s.afs.FS.Link(ctx, resolve(oldPath), resolve(newPath))
+		})
+	case "fsync@openssh.com":
+		name := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		h, ok := s.handles[name]
+		if !ok || h.file == nil {
+			return nil, errBadHandle
+		}
+		if err := h.file.Fsync(ctx); err != nil {
+			return nil, err
+		}
+		return statusReply(id, nil), nil
+	}
+	return nil, errUnsupported
+}
+
+// modify runs fn, which creates an entry at p, unless the server is
+// read-only or the parent of p is missing.
+func (s *session) modify(ctx context.Context, id uint32, p string, fn func() error) ([]byte, error) {
+	if s.opts.ReadOnly {
+		return nil, errReadOnly
+	}
+	if err := s.checkParent(ctx, p); err != nil {
+		return nil, err
+	}
+	if err := fn(); err != nil {
+		return nil, err
+	}
+	return statusReply(id, nil), nil
+}
+
+// checkParent returns an error unless the parent of p is a directory.
+// AgentFS creates missing parents, which SFTP clients do not expect.
+func (s *session) checkParent(ctx context.Context, p string) error {
+	dir := path.Dir(p)
+	stats, err := s.afs.FS.Stat(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if !stats.IsDir() {
+		return agentfs.ErrNotDir("stat", dir)
+	}
+	return nil
+}
+
+func (s *session) open(ctx context.Context, id uint32, p string, pflags uint32, a attrs) ([]byte, error) {
+	writable := pflags&(openWrite|openAppend) != 0
+	if (writable || pflags&(openCreat|openTrunc) != 0) && s.opts.ReadOnly {
+		return nil, errReadOnly
+	}
+	flags := agentfs.O_RDONLY
+	switch {
+	case writable && pflags&openRead != 0:
+		flags = agentfs.O_RDWR
+	case writable:
+		flags = agentfs.O_WRONLY
+	}
+	created := false
+	if pflags&openCreat != 0 {
+		flags |= agentfs.O_CREATE
+		if pflags&openExcl != 0 {
+			flags |= agentfs.O_EXCL
+		}
+		if _, err := s.afs.FS.Lstat(ctx, p); agentfs.IsNotExist(err) {
+			if err := s.checkParent(ctx, p); err != nil {
+				return nil, err
+			}
+			created = true
+		}
+	}
+	if pflags&openTrunc != 0 {
+		flags |= agentfs.O_TRUNC
+	}
+
+	f, err := s.afs.FS.Open(ctx, p, flags)
+	if err != nil {
+		return nil, err
+	}
+	if created && a.flags&attrPermissions != 0 {
+		if err := s.afs.FS.Chmod(ctx, p, int64(a.permissions)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return s.newHandle(id, &handle{path: p, file: f, writable: writable, append: pflags&openAppend != 0}), nil
+}
+
+// newHandle registers h and returns the reply naming it.
+func (s *session) newHandle(id uint32, h *handle) []byte {
+	s.nextHandle++
+	name := strconv.FormatUint(s.nextHandle, 10)
+	s.handles[name] = h
+	e := newReply(fxpHandle, id)
+	e.string(name)
+	return e.b
+}
+
+// fileHandle returns the open file named name.
+func (s *session) fileHandle(name string) (*handle, error) {
+	h, ok := s.handles[name]
+	if !ok || h.file == nil {
+		return nil, errBadHandle
+	}
+	return h, nil
+}
+
+func (s *session) read(ctx context.Context, id uint32, name string, off int64, length int) ([]byte, error) {
+	h, err := s.fileHandle(name)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, length)
+	n, err := h.file.Pread(ctx, buf, off)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 && length > 0 {
+		return nil, io.EOF
+	}
+	e := newReply(fxpData, id)
+	e.bytes(buf[:n])
+	return e.b, nil
+}
+
+func (s *session) write(ctx context.Context, id uint32, name string, off int64, data []byte) ([]byte, error) {
+	h, err := s.fileHandle(name)
+	if err != nil {
+		return nil, err
+	}
+	if !h.writable {
+		return nil, agentfs.ErrPerm("write", h.path)
+	}
+	if h.append {
+		if off, err = h.file.Size(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := h.file.Pwrite(ctx, data, off); err != nil {
+		return nil, err
+	}
+	return statusReply(id, nil), nil
+}
+
+func (s *session) readdir(id uint32, name string) ([]byte, error) {
+	h, ok := s.handles[name]
+	if !ok || h.file != nil {
+		return nil, errBadHandle
+	}
+	if len(h.entries) == 0 {
+		return nil, io.EOF
+	}
+	batch := h.entries[:min(len(h.entries), readdirBatch)]
+	h.entries = h.entries[len(batch):]
+
+	now := time.Now()
+	e := newReply(fxpName, id)
+	e.uint32(uint32(len(batch)))
+	for _, entry := range batch {
+		e.string(entry.Name)
+		e.string(longname(entry.Name, entry.Stats, now))
+		e.attrs(attrsOf(entry.Stats))
+	}
+	return e.b, nil
+}
+
+// setstat applies the attributes in a to p in one transaction.
+func (s *session) setstat(ctx context.Context, p string, a attrs) error {
+	tx, err := s.afs.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	fs := tx.FS
+	if a.flags&attrSize != 0 {
+		f, err := fs.Open(ctx, p, agentfs.O_WRONLY)
+		if err != nil {
+			return err
+		}
+		err = f.Truncate(ctx, int64(a.size))
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if a.flags&attrUIDGID != 0 {
+		if err := fs.Chown(ctx, p, int64(a.uid), int64(a.gid)); err != nil {
+			return err
+		}
+	}
+	if a.flags&attrPermissions != 0 {
+		if err := fs.Chmod(ctx, p, int64(a.permissions)); err != nil {
+			return err
+		}
+	}
+	if a.flags&attrACModTime != 0 {
+		if err := fs.Utimes(ctx, p, int64(a.atime), int64(a.mtime)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// resolve returns the absolute path of a client path. Relative paths are
+// relative to the root, which is also the client's working directory.
+func resolve(p string) string {
+	return path.Join("/", p)
+}
+
+// newReply starts a reply of type typ to request id.
+func newReply(typ byte, id uint32) *encoder {
+	e := &encoder{}
+	e.byte(typ)
+	e.uint32(id)
+	return e
+}
+
+func attrsReply(id uint32, stats *agentfs.Stats) []byte {
+	e := newReply(fxpAttrs, id)
+	e.attrs(attrsOf(stats))
+	return e.b
+}
+
+// statusReply returns the SSH_FXP_STATUS reply for err; nil is success.
+func statusReply(id uint32, err error) []byte {
+	code, msg := uint32(fxOK), "Success"
+	switch {
+	case err == nil:
+	case err == io.EOF:
+		code, msg = fxEOF, "End of file"
+	case errors.Is(err, errShortPacket):
+		code, msg = fxBadMessage, err.Error()
+	case errors.Is(err, errUnsupported):
+		code, msg = fxOpUnsupported, err.Error()
+	case agentfs.IsNotExist(err):
+		code, msg = fxNoSuchFile, err.Error()
+	case errors.Is(err, errReadOnly), agentfs.IsPermission(err), errors.Is(err, agentfs.ErrReserved):
+		code, msg = fxPermissionDenied, err.Error()
+	default:
+		code, msg = fxFailure, err.Error()
+	}
+	e := newReply(fxpStatus, id)
+	e.uint32(code)
+	e.string(msg)
+	e.string("") // Language tag
+	return e.b
+}
@@ -0,0 +1,220 @@
+package agentfssftp
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// request returns a packet of type typ with fields, which are strings,
+// uint32s, uint64s, byte slices, or attrs.
+func request(typ byte, fields ...any) []byte {
+	e := &encoder{}
+	e.byte(typ)
+	for _, f := range fields {
+		switch v := f.(type) {
+		case string:
+			e.string(v)
+		case uint32:
+			e.uint32(v)
+		case int:
+			e.uint32(uint32(v))
+		case uint64:
+			e.uint64(v)
+		case []byte:
+			e.bytes(v)
+		case attrs:
+			e.attrs(v)
+		}
+	}
+	return e.b
+}
+
+// exchange sends the requests to s and returns decoders of the replies,
+// in order.
+func exchange(t *testing.T, s *Server, requests ...[]byte) []*decoder {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, req := range requests {
+		writePacket(&in, req)
+	}
+	if err := s.Serve(context.Background(), &in, &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	var replies []*decoder
+	for out.Len() > 0 {
+		body, err := readPacket(&out)
+		if err != nil {
+			t.Fatalf("invalid reply: %v", err)
+		}
+		replies = append(replies, &decoder{b: body})
+	}
+	if len(replies) != len(requests) {
+		t.Fatalf("got %d replies to %d requests", len(replies), len(requests))
+	}
+	return replies
+}
+
+// status returns the code of a status reply, or -1 for other replies.
+func status(d *decoder) int {
+	if d.byte() != fxpStatus {
+		return -1
+	}
+	d.uint32()
+	return int(d.uint32())
+}
+
+// names returns the file names of a name reply.
+func names(d *decoder) []string {
+	if d.byte() != fxpName {
+		return nil
+	}
+	d.uint32()
+	var list []string
+	for n := d.uint32(); n > 0 && d.err == nil; n-- {
+		list = append(list, d.string())
+		d.string()
+		d.attrs()
+	}
+	return list
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	afs, err := agentfs.Open(ctx, agentfs.AgentFSOptions{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	// Handles are numbered from 1 in the order they are opened
+	replies := exchange(t, NewServer(afs, Options{}),
+		request(fxpInit, Version),
+		request(fxpMkdir, 1, "out", attrs{flags: attrPermissions, permissions: 0o750}),
+		request(fxpOpen, 2, "out/report.txt", openWrite|openCreat|openTrunc, attrs{}),
+		request(fxpWrite, 3, "1", uint64(0), []byte("hello world")),
+		request(fxpClose, 4, "1"),
+		request(fxpOpen, 5, "missing/x.txt", openWrite|openCreat, attrs{}),
+		request(fxpStat, 6, "/out/report.txt"),
+		request(fxpOpen, 7, "/out/report.txt", openRead, attrs{}),
+		request(fxpRead, 8, "2", uint64(6), 100),
+		request(fxpRead, 9, "2", uint64(11), 100),
+		request(fxpWrite, 10, "2", uint64(0), []byte("x")),
+		request(fxpOpendir, 11, "/out"),
+		request(fxpReaddir, 12, "3"),
+		request(fxpReaddir, 13, "3"),
+		request(fxpSymlink, 14, "report.txt", "/out/latest"),
+		request(fxpReadlink, 15, "/out/latest"),
+		request(fxpRename, 16, "/out/report.txt", "/out/latest"),
+		request(fxpExtended, 17, "posix-rename@openssh.com", "/out/report.txt", "/out/final.txt"),
+		request(fxpRealpath, 18, "out/../."),
+		request(fxpSetstat, 19, "/out/final.txt", attrs{flags: attrSize, size: 5}),
+		request(fxpRemove, 20, "/out/latest"),
+		request(fxpRead, 21, "9", uint64(0), 1),
+	)
+
+	version := replies[0]
+	if version.byte() != fxpVersion || version.uint32() != Version || version.string() != "posix-rename@openssh.com" {
+		t.Errorf("bad version reply")
+	}
+	for _, i := range []int{1, 3, 4, 14, 17, 19, 20} {
+		if code := status(replies[i]); code != fxOK {
+			t.Errorf("request %d: status %d, want OK", i, code)
+		}
+	}
+	if code := status(replies[5]); code != fxNoSuchFile {
+		t.Errorf("open without parent: status %d, want no such file", code)
+	}
+	stat := replies[6]
+	if stat.byte() != fxpAttrs || stat.uint32() != 6 || stat.attrs().size != 11 {
+		t.Error("bad stat reply")
+	}
+	data := replies[8]
+	if data.byte() != fxpData || data.uint32() != 8 || data.string() != "world" {
+		t.Error("bad read reply")
+	}
+	if code := status(replies[9]); code != fxEOF {
+		t.Errorf("read at end: status %d, want EOF", code)
+	}
+	if code := status(replies[10]); code != fxPermissionDenied {
+		t.Errorf("write to a read handle: status %d, want permission denied", code)
+	}
+	if got := names(replies[12]); len(got) != 1 || got[0] != "report.txt" {
+		t.Errorf("readdir = %q", got)
+	}
+	if code := status(replies[13]); code != fxEOF {
+		t.Errorf("second readdir: status %d, want EOF", code)
+	}
+	if got := names(replies[15]); len(got) != 1 || got[0] != "report.txt" {
+		t.Errorf("readlink = %q", got)
+	}
+	if code := status(replies[16]); code != fxFailure {
+		t.Errorf("rename onto an existing file: status %d, want failure", code)
+	}
+	if got := names(replies[18]); len(got) != 1 || got[0] != "/" {
+		t.Errorf("realpath = %q", got)
+	}
+	if code := status(replies[21]); code != fxFailure {
+		t.Errorf("read of an unknown handle: status %d, want failure", code)
+	}
+
+	if data, err := afs.FS.ReadFile(ctx, "/out/final.txt"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if stats, err := afs.FS.Stat(ctx, "/out"); err != nil || stats.Permissions() != 0o750 {
+		t.Errorf("Stat(/out) = %+v, %v", stats, err)
+	}
+	if _, err := afs.FS.Lstat(ctx, "/out/latest"); !agentfs.IsNotExist(err) {
+		t.Errorf("removed symlink still exists: %v", err)
+	}
+}
+
+func TestServer_Protocol(t *testing.T) {
+	replies := exchange(t, NewServer(nil, Options{ReadOnly: true}),
+		request(fxpInit, 6),
+		request(fxpMkdir, 1, "/out", attrs{}),
+		request(fxpOpen, 2, "/f", openWrite|openCreat, attrs{}),
+		request(fxpExtended, 3, "statvfs@openssh.com", "/"),
+		request(99, 4),
+		request(fxpOpen, 5),
+	)
+	version := replies[0]
+	if version.byte() != fxpVersion || version.uint32() != Version {
+		t.Error("server did not answer with its own version")
+	}
+	for i, want := range map[int]int{1: fxPermissionDenied, 2: fxPermissionDenied, 3: fxOpUnsupported, 4: fxOpUnsupported, 5: fxBadMessage} {
+		if code := status(replies[i]); code != want {
+			t.Errorf("request %d: status %d, want %d", i, code, want)
+		}
+	}
+
+	var oversized bytes.Buffer
+	writePacket(&oversized, make([]byte, maxPacket+1))
+	if _, err := readPacket(&oversized); err == nil {
+		t.Error("readPacket accepted an oversized packet")
+	}
+}
+
+func TestLongname(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	stats := &agentfs.Stats{
+		Mode:  agentfs.S_IFREG | 0o640,
+		Nlink: 1,
+		Size:  1234,
+		Mtime: now.Add(-time.Hour).Unix(),
+	}
+	got := longname("report.txt", stats, now)
+	if !strings.HasPrefix(got, "-rw-r----- ") || !strings.HasSuffix(got, " 1234 Mar  1 11:00 report.txt") {
+		t.Errorf("longname = %q", got)
+	}
+	stats.Mode = agentfs.S_IFDIR | 0o755
+	stats.Mtime = now.AddDate(-1, 0, 0).Unix()
+	if got := longname("out", stats, now); !strings.HasPrefix(got, "drwxr-xr-x ") || !strings.HasSuffix(got, "Mar  1  2025 out") {
+		t.Errorf("longname of an old directory = %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+package agentfssftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// Packet types of SFTP version 3
+// (see https://datatracker.ietf.org/doc/html/draft-ietf-secsh-filexfer-02)
+const (
+	fxpInit          = 1
+	fxpVersion       = 2
+	fxpOpen          = 3
+	fxpClose         = 4
+	fxpRead          = 5
+	fxpWrite         = 6
+	fxpLstat         = 7
+	fxpFstat         = 8
+	fxpSetstat       = 9
+	fxpFsetstat      = 10
+	fxpOpendir       = 11
+	fxpReaddir       = 12
+	fxpRemove        = 13
+	fxpMkdir         = 14
+	fxpRmdir         = 15
+	fxpRealpath      = 16
+	fxpStat          = 17
+	fxpRename        = 18
+	fxpReadlink      = 19
+	fxpSymlink       = 20
+	fxpStatus        = 101
+	fxpHandle        = 102
+	fxpData          = 103
+	fxpName          = 104
+	fxpAttrs         = 105
+	fxpExtended      = 200
+	fxpExtendedReply = 201
+)
+
+// Status codes
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFailure          = 4
+	fxBadMessage       = 5
+	fxOpUnsupported    = 8
+)
+
+// Flags of the attributes present in an ATTRS structure
+const (
+	attrSize        = 0x00000001
+	attrUIDGID      = 0x00000002
+	attrPermissions = 0x00000004
+	attrACModTime   = 0x00000008
+	attrExtended    = 0x80000000
+)
+
+// Flags of SSH_FXP_OPEN
+const (
+	openRead   = 0x00000001
+	openWrite  = 0x00000002
+	openAppend = 0x00000004
+	openCreat  = 0x00000008
+	openTrunc  = 0x00000010
+	openExcl   = 0x00000020
+)
+
+// maxPacket is the largest packet accepted, as in OpenSSH.
+const maxPacket = 256 << 10
+
+// maxRead is the most data answered to one read; clients ask for less.
+const maxRead = maxPacket - 1024
+
+// errShortPacket is returned for a packet that ends within a field.
+var errShortPacket = errors.New("agentfssftp: packet too short")
+
+// attrs is an ATTRS structure. Only the attributes in flags are sent.
+type attrs struct {
+	flags        uint32
+	size         uint64
+	uid, gid     uint32
+	permissions  uint32
+	atime, mtime uint32
+}
+
+// attrsOf returns the attributes of stats.
+func attrsOf(stats *agentfs.Stats) attrs {
+	return attrs{
+		flags:       attrSize | attrUIDGID | attrPermissions | attrACModTime,
+		size:        uint64(stats.Size),
+		uid:         uint32(stats.UID),
+		gid:         uint32(stats.GID),
+		permissions: uint32(stats.Mode),
+		atime:       uint32(stats.Atime),
+		mtime:       uint32(stats.Mtime),
+	}
+}
+
+// encoder appends fields of a packet.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) byte(v byte) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.b = binary.BigEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, v)
+}
+
+func (e *encoder) string(v string) {
+	e.uint32(uint32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.uint32(uint32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *encoder) attrs(a attrs) {
+	e.uint32(a.flags &^ attrExtended)
+	if a.flags&attrSize != 0 {
+		e.uint64(a.size)
+	}
+	if a.flags&attrUIDGID != 0 {
+		e.uint32(a.uid)
+		e.uint32(a.gid)
+	}
+	if a.flags&attrPermissions != 0 {
+		e.uint32(a.permissions)
+	}
+	if a.flags&attrACModTime != 0 {
+		e.uint32(a.atime)
+		e.uint32(a.mtime)
+	}
+}
+
+// decoder reads the fields of a packet. After a field runs past the end,
+// err is set and every later field reads as zero.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.b) {
+		d.err = errShortPacket
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if v := d.take(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if v := d.take(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if v := d.take(8); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint32()
+	if n > uint32(len(d.b)) {
+		d.err = errShortPacket
+		return nil
+	}
+	return d.take(int(n))
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) attrs() attrs {
+	a := attrs{flags: d.uint32()}
+	if a.flags&attrSize != 0 {
+		a.size = d.uint64()
+	}
+	if a.flags&attrUIDGID != 0 {
+		a.uid, a.gid = d.uint32(), d.uint32()
+	}
+	if a.flags&attrPermissions != 0 {
+		a.permissions = d.uint32()
+	}
+	if a.flags&attrACModTime != 0 {
+		a.atime, a.mtime = d.uint32(), d.uint32()
+	}
+	if a.flags&attrExtended != 0 {
+		// No extended attributes are supported; skip them
+		for n := d.uint32(); n > 0 && d.err == nil; n-- {
+			d.bytes()
+			d.bytes()
+		}
+	}
+	return a
+}
+
+// readPacket reads the next packet and returns its body, starting with
+// the packet type. It returns io.EOF at the end of the stream.
+func readPacket(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errShortPacket
+		}
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size == 0 || size > maxPacket {
+		return nil, fmt.Errorf("agentfssftp: packet of %d bytes exceeds %d", size, maxPacket)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, errShortPacket
+	}
+	return b, nil
+}
+
+// writePacket writes body with its length prefix.
+func writePacket(w io.Writer, body []byte) error {
+	packet := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint32(packet, uint32(len(body)))
+	_, err := w.Write(append(packet, body...))
+	return err
+}
+
+// longname returns the line `ls -l` prints for an entry, which SFTP
+// clients show for directory listings.
+func longname(name string, stats *agentfs.Stats, now time.Time) string {
+	mtime := stats.MtimeTime()
+	stamp := mtime.Format("Jan _2 15:04")
+	if mtime.Before(now.AddDate(0, -6, 0)) || mtime.After(now.AddDate(0, 0, 1)) {
+		stamp = mtime.Format("Jan _2  2006")
+	}
+	return fmt.Sprintf("%s %4d %-8d %-8d %8d %s %s", modeString(stats), stats.Nlink, stats.UID, stats.GID, stats.Size, stamp, name)
+}
+
+// modeString returns the mode of stats as `ls -l` prints it.
+func modeString(stats *agentfs.Stats) string {
+	b := []byte("?rwxrwxrwx")
+	switch {
+	case stats.IsRegularFile():
+		b[0] = '-'
+	case stats.IsDir():
+		b[0] = 'd'
+	case stats.IsSymlink():
+		b[0] = 'l'
+	case stats.IsFIFO():
+		b[0] = 'p'
+	case stats.IsCharDevice():
+		b[0] = 'c'
+	case stats.IsBlockDevice():
+		b[0] = 'b'
+	case stats.IsSocket():
+		b[0] = 's'
+	}
+	for i := 0; i < 9; i++ {
+		if stats.Mode&(1<<(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
+	return string(b)
+}
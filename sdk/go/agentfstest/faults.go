@@ -0,0 +1,364 @@
+package agentfstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// ErrBusy matches injected FaultBusy errors with errors.Is.
+var ErrBusy = errors.New("database is locked")
+
+// FaultKind selects the error an injected fault returns.
+type FaultKind int
+
+const (
+	// FaultBusy returns an error matching ErrBusy, like SQLITE_BUSY when
+	// another connection holds the write lock.
+	FaultBusy FaultKind = iota
+	// FaultIO returns an error matching an EIO *agentfs.FSError.
+	FaultIO
+)
+
+// String returns the name of the kind.
+func (k FaultKind) String() string {
+	if k == FaultIO {
+		return "EIO"
+	}
+	return "SQLITE_BUSY"
+}
+
+// Fault schedules one injected error.
+type Fault struct {
+	// Op is the method to fail, e.g. "FS.WriteFile", "KV.Set" or
+	// "Tools.Start". Empty matches every method.
+	Op string `json:"op,omitempty"`
+	// Call is the 1-based call of Op to fail, counted per method (or across
+	// all methods when Op is empty). Zero fails every call.
+	Call int `json:"call,omitempty"`
+	// Kind is the error returned.
+	Kind FaultKind `json:"kind"`
+	// After applies the operation before returning the error, simulating a
+	// failure whose outcome the caller cannot observe.
+	After bool `json:"after,omitempty"`
+}
+
+// FaultPlan describes when a Flaky wrapper fails.
+type FaultPlan struct {
+	// Faults are scheduled failures; the first matching fault applies.
+	Faults []Fault `json:"faults,omitempty"`
+	// Rate fails this fraction of the calls not matched by Faults, with a
+	// kind drawn at random. Default: 0.
+	Rate float64 `json:"rate,omitempty"`
+	// Seed seeds the random failures so runs are repeatable.
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// FaultError is the error returned by an injected fault.
+type FaultError struct {
+	Op   string
+	Path string
+	Kind FaultKind
+}
+
+func (e *FaultError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s %s: injected %s", e.Op, e.Path, e.Kind)
+	}
+	return fmt.Sprintf("%s: injected %s", e.Op, e.Kind)
+}
+
+// Is reports whether the fault matches ErrBusy or an EIO *agentfs.FSError.
+func (e *FaultError) Is(target error) bool {
+	if e.Kind == FaultBusy {
+		return target == ErrBusy
+	}
+	var fsErr *agentfs.FSError
+	return errors.As(target, &fsErr) && fsErr.Code == agentfs.EIO
+}
+
+// FlakyAgentFS wraps an AgentFS and fails calls according to a FaultPlan.
+// Its FS, KV and Tools fields implement the SDK's mocking interfaces, so
+// code written against agentfs.FileSystem, agentfs.KVStoreInterface and
+// agentfs.ToolCallsInterface can be exercised unchanged. File handles
+// returned by FS.Open and FS.Create are not wrapped.
+type FlakyAgentFS struct {
+	FS    *FlakyFS
+	KV    *FlakyKV
+	Tools *FlakyTools
+
+	mu       sync.Mutex
+	plan     FaultPlan
+	rng      *rand.Rand
+	calls    map[string]int
+	total    int
+	injected []Fault
+}
+
+// Flaky wraps afs so its operations fail according to plan.
+//
+// Every injected fault is recorded with its method and call number;
+// passing Injected back as a plan replays the same failures:
+//
+//	flaky := agentfstest.Flaky(afs, agentfstest.FaultPlan{Rate: 0.1, Seed: 42})
+//	runAgent(ctx, flaky.FS, flaky.KV)
+//
+//	replay := agentfstest.Flaky(afs, agentfstest.FaultPlan{Faults: flaky.Injected()})
+func Flaky(afs *agentfs.AgentFS, plan FaultPlan) *FlakyAgentFS {
+	f := &FlakyAgentFS{
+		plan:  plan,
+		rng:   rand.New(rand.NewSource(plan.Seed)),
+		calls: map[string]int{},
+	}
+	f.FS = &FlakyFS{f: f, fs: afs.FS}
+	f.KV = &FlakyKV{f: f, kv: afs.KV}
+	f.Tools = &FlakyTools{f: f, tc: afs.Tools}
+	return f
+}
+
+// Injected returns the faults injected so far, each with its method and
+// per-method call number, in the order they occurred.
+func (f *FlakyAgentFS) Injected() []Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Fault(nil), f.injected...)
+}
+
+// Calls returns how many times op has been called.
+func (f *FlakyAgentFS) Calls(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// next counts a call of op and returns the fault to inject, if any.
+func (f *FlakyAgentFS) next(op string) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls[op]++
+	f.total++
+	n := f.calls[op]
+
+	var hit *Fault
+	for _, ft := range f.plan.Faults {
+		if ft.Op != "" && ft.Op != op {
+			continue
+		}
+		count := n
+		if ft.Op == "" {
+			count = f.total
+		}
+		if ft.Call == 0 || ft.Call == count {
+			ft := ft
+			hit = &ft
+			break
+		}
+	}
+	if hit == nil && f.plan.Rate > 0 && f.rng.Float64() < f.plan.Rate {
+		hit = &Fault{Kind: FaultKind(f.rng.Intn(2))}
+	}
+	if hit == nil {
+		return nil
+	}
+
+	hit.Op, hit.Call = op, n
+	f.injected = append(f.injected, *hit)
+	return hit
+}
+
+// call runs fn unless a fault is scheduled for op.
+func call[T any](f *FlakyAgentFS, op, path string, fn func() (T, error)) (T, error) {
+	var zero T
+	ft := f.next(op)
+	if ft != nil && !ft.After {
+		return zero, &FaultError{Op: op, Path: path, Kind: ft.Kind}
+	}
+	v, err := fn()
+	if err == nil && ft != nil {
+		return zero, &FaultError{Op: op, Path: path, Kind: ft.Kind}
+	}
+	return v, err
+}
+
+// call0 is call for methods that only return an error.
+func call0(f *FlakyAgentFS, op, path string, fn func() error) error {
+	_, err := call(f, op, path, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+// FlakyFS is the agentfs.FileSystem of a FlakyAgentFS.
+type FlakyFS struct {
+	f  *FlakyAgentFS
+	fs agentfs.FileSystem
+}
+
+func (w *FlakyFS) Stat(ctx context.Context, p string) (*agentfs.Stats, error) {
+	return call(w.f, "FS.Stat", p, func() (*agentfs.Stats, error) { return w.fs.Stat(ctx, p) })
+}
+
+func (w *FlakyFS) Lstat(ctx context.Context, p string) (*agentfs.Stats, error) {
+	return call(w.f, "FS.Lstat", p, func() (*agentfs.Stats, error) { return w.fs.Lstat(ctx, p) })
+}
+
+func (w *FlakyFS) Readdir(ctx context.Context, p string) ([]string, error) {
+	return call(w.f, "FS.Readdir", p, func() ([]string, error) { return w.fs.Readdir(ctx, p) })
+}
+
+func (w *FlakyFS) ReaddirPlus(ctx context.Context, p string) ([]agentfs.DirEntry, error) {
+	return call(w.f, "FS.ReaddirPlus", p, func() ([]agentfs.DirEntry, error) { return w.fs.ReaddirPlus(ctx, p) })
+}
+
+func (w *FlakyFS) Mkdir(ctx context.Context, p string, mode int64) error {
+	return call0(w.f, "FS.Mkdir", p, func() error { return w.fs.Mkdir(ctx, p, mode) })
+}
+
+func (w *FlakyFS) MkdirAll(ctx context.Context, p string, mode int64) error {
+	return call0(w.f, "FS.MkdirAll", p, func() error { return w.fs.MkdirAll(ctx, p, mode) })
+}
+
+func (w *FlakyFS) ReadFile(ctx context.Context, p string) ([]byte, error) {
+	return call(w.f, "FS.ReadFile", p, func() ([]byte, error) { return w.fs.ReadFile(ctx, p) })
+}
+
+func (w *FlakyFS) WriteFile(ctx context.Context, p string, data []byte, mode int64) error {
+	return call0(w.f, "FS.WriteFile", p, func() error { return w.fs.WriteFile(ctx, p, data, mode) })
+}
+
+func (w *FlakyFS) Unlink(ctx context.Context, p string) error {
+	return call0(w.f, "FS.Unlink", p, func() error { return w.fs.Unlink(ctx, p) })
+}
+
+func (w *FlakyFS) Rmdir(ctx context.Context, p string) error {
+	return call0(w.f, "FS.Rmdir", p, func() error { return w.fs.Rmdir(ctx, p) })
+}
+
+func (w *FlakyFS) Rename(ctx context.Context, oldPath, newPath string) error {
+	return call0(w.f, "FS.Rename", oldPath, func() error { return w.fs.Rename(ctx, oldPath, newPath) })
+}
+
+func (w *FlakyFS) Link(ctx context.Context, existingPath, newPath string) error {
+	return call0(w.f, "FS.Link", newPath, func() error { return w.fs.Link(ctx, existingPath, newPath) })
+}
+
+func (w *FlakyFS) Symlink(ctx context.Context, target, linkPath string) error {
+	return call0(w.f, "FS.Symlink", linkPath, func() error { return w.fs.Symlink(ctx, target, linkPath) })
+}
+
+func (w *FlakyFS) Readlink(ctx context.Context, p string) (string, error) {
+	return call(w.f, "FS.Readlink", p, func() (string, error) { return w.fs.Readlink(ctx, p) })
+}
+
+func (w *FlakyFS) Chmod(ctx context.Context, p string, mode int64) error {
+	return call0(w.f, "FS.Chmod", p, func() error { return w.fs.Chmod(ctx, p, mode) })
+}
+
+func (w *FlakyFS) Utimes(ctx context.Context, p string, atime, mtime int64) error {
+	return call0(w.f, "FS.Utimes", p, func() error { return w.fs.Utimes(ctx, p, atime, mtime) })
+}
+
+func (w *FlakyFS) Utimens(ctx context.Context, p string, atime, mtime agentfs.TimeChange) error {
+	return call0(w.f, "FS.Utimens", p, func() error { return w.fs.Utimens(ctx, p, atime, mtime) })
+}
+
+func (w *FlakyFS) Open(ctx context.Context, p string, flags int) (*agentfs.File, error) {
+	return call(w.f, "FS.Open", p, func() (*agentfs.File, error) { return w.fs.Open(ctx, p, flags) })
+}
+
+func (w *FlakyFS) Create(ctx context.Context, p string, mode int64) (*agentfs.Stats, *agentfs.File, error) {
+	type created struct {
+		stats *agentfs.Stats
+		file  *agentfs.File
+	}
+	c, err := call(w.f, "FS.Create", p, func() (created, error) {
+		stats, file, err := w.fs.Create(ctx, p, mode)
+		return created{stats, file}, err
+	})
+	return c.stats, c.file, err
+}
+
+func (w *FlakyFS) ChunkSize() int {
+	return w.fs.ChunkSize()
+}
+
+// FlakyKV is the agentfs.KVStoreInterface of a FlakyAgentFS.
+type FlakyKV struct {
+	f  *FlakyAgentFS
+	kv agentfs.KVStoreInterface
+}
+
+func (w *FlakyKV) Set(ctx context.Context, key string, value any) error {
+	return call0(w.f, "KV.Set", key, func() error { return w.kv.Set(ctx, key, value) })
+}
+
+func (w *FlakyKV) Get(ctx context.Context, key string, dest any) error {
+	return call0(w.f, "KV.Get", key, func() error { return w.kv.Get(ctx, key, dest) })
+}
+
+func (w *FlakyKV) GetRaw(ctx context.Context, key string) (json.RawMessage, error) {
+	return call(w.f, "KV.GetRaw", key, func() (json.RawMessage, error) { return w.kv.GetRaw(ctx, key) })
+}
+
+func (w *FlakyKV) Delete(ctx context.Context, key string) error {
+	return call0(w.f, "KV.Delete", key, func() error { return w.kv.Delete(ctx, key) })
+}
+
+func (w *FlakyKV) Has(ctx context.Context, key string) (bool, error) {
+	return call(w.f, "KV.Has", key, func() (bool, error) { return w.kv.Has(ctx, key) })
+}
+
+func (w *FlakyKV) Keys(ctx context.Context, prefix string) ([]string, error) {
+	return call(w.f, "KV.Keys", prefix, func() ([]string, error) { return w.kv.Keys(ctx, prefix) })
+}
+
+func (w *FlakyKV) List(ctx context.Context, prefix string) ([]agentfs.KVEntry, error) {
+	return call(w.f, "KV.List", prefix, func() ([]agentfs.KVEntry, error) { return w.kv.List(ctx, prefix) })
+}
+
+func (w *FlakyKV) Clear(ctx context.Context, prefix string) error {
+	return call0(w.f, "KV.Clear", prefix, func() error { return w.kv.Clear(ctx, prefix) })
+}
+
+// FlakyTools is the agentfs.ToolCallsInterface of a FlakyAgentFS.
+type FlakyTools struct {
+	f  *FlakyAgentFS
+	tc agentfs.ToolCallsInterface
+}
+
+func (w *FlakyTools) Start(ctx context.Context, name string, parameters any) (*agentfs.PendingCall, error) {
+	return call(w.f, "Tools.Start", name, func() (*agentfs.PendingCall, error) { return w.tc.Start(ctx, name, parameters) })
+}
+
+func (w *FlakyTools) Record(ctx context.Context, name string, parameters any, result any, errMsg *string, startedAt, completedAt int64) (*agentfs.ToolCall, error) {
+	return call(w.f, "Tools.Record", name, func() (*agentfs.ToolCall, error) {
+		return w.tc.Record(ctx, name, parameters, result, errMsg, startedAt, completedAt)
+	})
+}
+
+func (w *FlakyTools) Get(ctx context.Context, id int64) (*agentfs.ToolCall, error) {
+	return call(w.f, "Tools.Get", "", func() (*agentfs.ToolCall, error) { return w.tc.Get(ctx, id) })
+}
+
+func (w *FlakyTools) GetByName(ctx context.Context, name string, limit int) ([]agentfs.ToolCall, error) {
+	return call(w.f, "Tools.GetByName", name, func() ([]agentfs.ToolCall, error) { return w.tc.GetByName(ctx, name, limit) })
+}
+
+func (w *FlakyTools) GetRecent(ctx context.Context, since int64, limit int) ([]agentfs.ToolCall, error) {
+	return call(w.f, "Tools.GetRecent", "", func() ([]agentfs.ToolCall, error) { return w.tc.GetRecent(ctx, since, limit) })
+}
+
+func (w *FlakyTools) GetStats(ctx context.Context) ([]agentfs.ToolCallStats, error) {
+	return call(w.f, "Tools.GetStats", "", func() ([]agentfs.ToolCallStats, error) { return w.tc.GetStats(ctx) })
+}
+
+// Compile-time interface satisfaction checks.
+var (
+	_ agentfs.FileSystem         = (*FlakyFS)(nil)
+	_ agentfs.KVStoreInterface   = (*FlakyKV)(nil)
+	_ agentfs.ToolCallsInterface = (*FlakyTools)(nil)
+)
@@ -0,0 +1,72 @@
+package agentfstest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func TestFaultSchedule(t *testing.T) {
+	ctx := context.Background()
+	afs := openTestDB(t)
+	flaky := Flaky(afs, FaultPlan{Faults: []Fault{
+		{Op: "FS.WriteFile", Call: 2, Kind: FaultBusy},
+		{Op: "KV.Set", Call: 1, Kind: FaultIO, After: true},
+	}})
+
+	if err := flaky.FS.WriteFile(ctx, "/a.txt", []byte("1"), 0o644); err != nil {
+		t.Fatalf("first WriteFile failed: %v", err)
+	}
+	err := flaky.FS.WriteFile(ctx, "/a.txt", []byte("2"), 0o644)
+	if !errors.Is(err, ErrBusy) {
+		t.Fatalf("second WriteFile: err = %v, want ErrBusy", err)
+	}
+	if data, _ := afs.FS.ReadFile(ctx, "/a.txt"); string(data) != "1" {
+		t.Errorf("content = %q, want the failed write not applied", data)
+	}
+
+	err = flaky.KV.Set(ctx, "k", "v")
+	if !errors.Is(err, agentfs.NewFSError(agentfs.EIO, "", "", "")) {
+		t.Fatalf("KV.Set: err = %v, want EIO", err)
+	}
+	if ok, _ := afs.KV.Has(ctx, "k"); !ok {
+		t.Error("KV.Set with After fault was not applied")
+	}
+
+	want := []Fault{
+		{Op: "FS.WriteFile", Call: 2, Kind: FaultBusy},
+		{Op: "KV.Set", Call: 1, Kind: FaultIO, After: true},
+	}
+	if got := flaky.Injected(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Injected() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFaultRecordReplay(t *testing.T) {
+	ops := []string{"FS.Stat", "FS.WriteFile", "KV.Get", "FS.Stat", "KV.Set", "FS.ReadFile"}
+	run := func(f *FlakyAgentFS) []bool {
+		var failed []bool
+		for i := 0; i < 50; i++ {
+			failed = append(failed, f.next(ops[i%len(ops)]) != nil)
+		}
+		return failed
+	}
+
+	afs := &agentfs.AgentFS{}
+	recorded := Flaky(afs, FaultPlan{Rate: 0.3, Seed: 7})
+	first := run(recorded)
+	if len(recorded.Injected()) == 0 {
+		t.Fatal("no faults injected at rate 0.3")
+	}
+
+	replayed := Flaky(afs, FaultPlan{Faults: recorded.Injected()})
+	if second := run(replayed); !reflect.DeepEqual(first, second) {
+		t.Errorf("replay failed calls %v, want %v", second, first)
+	}
+	if !reflect.DeepEqual(replayed.Injected(), recorded.Injected()) {
+		t.Errorf("replay injected %+v, want %+v", replayed.Injected(), recorded.Injected())
+	}
+}
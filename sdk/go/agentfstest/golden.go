@@ -0,0 +1,278 @@
+package agentfstest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// maxDiffLines caps how many lines of a content diff are reported per file.
+const maxDiffLines = 40
+
+// entry is one node of a tree being compared.
+type entry struct {
+	kind string // "dir", "file", or "symlink"
+	data []byte // file content or symlink target
+}
+
+// AssertTreeEqual fails t unless the AgentFS filesystem matches goldenDir on
+// the host: the same directories, regular files with the same content, and
+// symlinks with the same targets. Modes and timestamps are not compared.
+// The failure message lists missing and unexpected paths and a line diff
+// for each text file whose content differs.
+//
+// Example:
+//
+//	runAgent(ctx, afs)
+//	agentfstest.AssertTreeEqual(t, afs, "testdata/want")
+func AssertTreeEqual(t testing.TB, afs *agentfs.AgentFS, goldenDir string) {
+	t.Helper()
+	ctx := context.Background()
+
+	want, err := hostTree(goldenDir)
+	if err != nil {
+		t.Fatalf("failed to read golden tree: %v", err)
+	}
+	got := map[string]entry{}
+	if err := agentTree(ctx, afs.FS, "/", got); err != nil {
+		t.Fatalf("failed to read agentfs tree: %v", err)
+	}
+
+	if diff := diffTrees(want, got); diff != "" {
+		t.Errorf("tree does not match %s:\n%s", goldenDir, diff)
+	}
+}
+
+// AssertKVEqual fails t unless the KV store holds exactly the keys of want
+// with equal values. Values are compared by their JSON encoding, so want
+// may use any type that marshals to the stored JSON.
+//
+// Example:
+//
+//	agentfstest.AssertKVEqual(t, afs, map[string]any{
+//	    "task:status": "done",
+//	    "task:steps":  3,
+//	})
+func AssertKVEqual(t testing.TB, afs *agentfs.AgentFS, want map[string]any) {
+	t.Helper()
+	ctx := context.Background()
+
+	keys, err := afs.KV.Keys(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to list keys: %v", err)
+	}
+	got := map[string]string{}
+	for _, k := range keys {
+		raw, err := afs.KV.GetRaw(ctx, k)
+		if err != nil {
+			t.Fatalf("failed to get %q: %v", k, err)
+		}
+		got[k], err = canonicalJSON(raw)
+		if err != nil {
+			t.Fatalf("failed to decode %q: %v", k, err)
+		}
+	}
+
+	var lines []string
+	for k, v := range want {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal want[%q]: %v", k, err)
+		}
+		w, _ := canonicalJSON(raw)
+		g, ok := got[k]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("missing key %q: want %s", k, w))
+		case g != w:
+			lines = append(lines, fmt.Sprintf("key %q:\n\t- %s\n\t+ %s", k, w, g))
+		}
+	}
+	for k, g := range got {
+		if _, ok := want[k]; !ok {
+			lines = append(lines, fmt.Sprintf("unexpected key %q: %s", k, g))
+		}
+	}
+
+	if len(lines) > 0 {
+		sort.Strings(lines)
+		t.Errorf("KV store does not match:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+// canonicalJSON re-encodes raw so that equal values compare equal
+// regardless of key order and whitespace.
+func canonicalJSON(raw []byte) (string, error) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	out, err := json.Marshal(v)
+	return string(out), err
+}
+
+// hostTree reads dir on the host, keyed by slash path relative to dir.
+func hostTree(dir string) (map[string]entry, error) {
+	tree := map[string]entry{}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		key := "/" + filepath.ToSlash(rel)
+
+		switch {
+		case d.Type()&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			tree[key] = entry{kind: "symlink", data: []byte(target)}
+		case d.IsDir():
+			tree[key] = entry{kind: "dir"}
+		default:
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			tree[key] = entry{kind: "file", data: data}
+		}
+		return nil
+	})
+	return tree, err
+}
+
+// agentTree reads the AgentFS directory p and everything below it into tree.
+func agentTree(ctx context.Context, fs *agentfs.Filesystem, p string, tree map[string]entry) error {
+	entries, err := fs.ReaddirPlus(ctx, p)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		child := path.Join(p, e.Name)
+		switch {
+		case e.Stats.IsSymlink():
+			target, err := fs.Readlink(ctx, child)
+			if err != nil {
+				return err
+			}
+			tree[child] = entry{kind: "symlink", data: []byte(target)}
+		case e.Stats.IsDir():
+			tree[child] = entry{kind: "dir"}
+			if err := agentTree(ctx, fs, child, tree); err != nil {
+				return err
+			}
+		default:
+			data, err := fs.ReadFile(ctx, child)
+			if err != nil {
+				return err
+			}
+			tree[child] = entry{kind: "file", data: data}
+		}
+	}
+	return nil
+}
+
+// diffTrees describes how got differs from want, or returns "" if they match.
+func diffTrees(want, got map[string]entry) string {
+	paths := make([]string, 0, len(want)+len(got))
+	for p := range want {
+		paths = append(paths, p)
+	}
+	for p := range got {
+		if _, ok := want[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, p := range paths {
+		w, inWant := want[p]
+		g, inGot := got[p]
+		switch {
+		case !inGot:
+			fmt.Fprintf(&b, "missing %s %s\n", w.kind, p)
+		case !inWant:
+			fmt.Fprintf(&b, "unexpected %s %s\n", g.kind, p)
+		case w.kind != g.kind:
+			fmt.Fprintf(&b, "%s: want %s, got %s\n", p, w.kind, g.kind)
+		case w.kind == "symlink" && !bytes.Equal(w.data, g.data):
+			fmt.Fprintf(&b, "%s: want symlink to %q, got %q\n", p, w.data, g.data)
+		case !bytes.Equal(w.data, g.data):
+			fmt.Fprintf(&b, "%s: content differs\n%s", p, contentDiff(w.data, g.data))
+		}
+	}
+	return b.String()
+}
+
+// contentDiff renders a line diff of two file contents, or a size summary
+// for binary data.
+func contentDiff(want, got []byte) string {
+	if !utf8.Valid(want) || !utf8.Valid(got) || bytes.IndexByte(want, 0) >= 0 || bytes.IndexByte(got, 0) >= 0 {
+		return fmt.Sprintf("\tbinary content: want %d bytes, got %d bytes\n", len(want), len(got))
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, l := range lineDiff(strings.Split(string(want), "\n"), strings.Split(string(got), "\n")) {
+		if n == maxDiffLines {
+			b.WriteString("\t...\n")
+			break
+		}
+		b.WriteString("\t" + l + "\n")
+		n++
+	}
+	return b.String()
+}
+
+// lineDiff returns the lines removed ("- ") and added ("+ ") to turn a into
+// b, using a longest common subsequence. Unchanged lines are omitted.
+func lineDiff(a, b []string) []string {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			out = append(out, "+ "+b[j])
+			j++
+		default:
+			out = append(out, "- "+a[i])
+			i++
+		}
+	}
+	return out
+}
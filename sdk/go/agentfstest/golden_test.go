@@ -0,0 +1,113 @@
+package agentfstest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder captures failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.TB.Fatalf(format, args...)
+}
+
+func TestLineDiff(t *testing.T) {
+	got := lineDiff([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	want := []string{"- b", "+ x", "+ d"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lineDiff = %q, want %q", got, want)
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	want := map[string]entry{
+		"/dir":      {kind: "dir"},
+		"/dir/a.go": {kind: "file", data: []byte("package a\n")},
+		"/gone":     {kind: "file"},
+		"/link":     {kind: "symlink", data: []byte("dir")},
+	}
+	got := map[string]entry{
+		"/dir":      {kind: "dir"},
+		"/dir/a.go": {kind: "file", data: []byte("package b\n")},
+		"/extra":    {kind: "dir"},
+		"/link":     {kind: "symlink", data: []byte("dir")},
+	}
+
+	diff := diffTrees(want, got)
+	for _, s := range []string{"/dir/a.go: content differs", "- package a", "+ package b", "missing file /gone", "unexpected dir /extra"} {
+		if !strings.Contains(diff, s) {
+			t.Errorf("diff missing %q:\n%s", s, diff)
+		}
+	}
+	if diffTrees(want, want) != "" {
+		t.Error("diff of identical trees is not empty")
+	}
+}
+
+func TestAssertTreeEqual(t *testing.T) {
+	ctx := context.Background()
+	afs := openTestDB(t)
+
+	golden := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(golden, "src"), 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(golden, "src", "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := afs.FS.WriteFile(ctx, "/src/main.go", []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	AssertTreeEqual(t, afs, golden)
+
+	if err := afs.FS.WriteFile(ctx, "/src/extra.go", nil, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	r := &recorder{TB: t}
+	AssertTreeEqual(r, afs, golden)
+	if len(r.failures) != 1 || !strings.Contains(r.failures[0], "unexpected file /src/extra.go") {
+		t.Errorf("failures = %q", r.failures)
+	}
+}
+
+func TestAssertKVEqual(t *testing.T) {
+	ctx := context.Background()
+	afs := openTestDB(t)
+
+	if err := afs.KV.Set(ctx, "status", "done"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := afs.KV.Set(ctx, "config", map[string]int{"b": 2, "a": 1}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	AssertKVEqual(t, afs, map[string]any{
+		"status": "done",
+		"config": map[string]any{"a": 1, "b": 2},
+	})
+
+	r := &recorder{TB: t}
+	AssertKVEqual(r, afs, map[string]any{"status": "running", "steps": 3})
+	if len(r.failures) != 1 {
+		t.Fatalf("failures = %q, want 1", r.failures)
+	}
+	for _, s := range []string{`key "status"`, `missing key "steps"`, `unexpected key "config"`} {
+		if !strings.Contains(r.failures[0], s) {
+			t.Errorf("failure missing %q:\n%s", s, r.failures[0])
+		}
+	}
+}
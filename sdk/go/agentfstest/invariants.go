@@ -0,0 +1,188 @@
+// Package agentfstest provides helpers for testing code built on AgentFS.
+package agentfstest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// maxViolations caps how many violations CheckInvariants reports.
+const maxViolations = 100
+
+// InvariantError is returned by CheckInvariants when the database is not
+// consistent.
+type InvariantError struct {
+	// Violations describes each broken invariant (at most 100).
+	Violations []string
+}
+
+func (e *InvariantError) Error() string {
+	msg := fmt.Sprintf("agentfs invariants violated (%d)", len(e.Violations))
+	if len(e.Violations) > 0 {
+		msg += ": " + strings.Join(e.Violations, "; ")
+	}
+	return msg
+}
+
+// invariant is a query returning one row per violation. The first column
+// identifies the offending row; the rest are included in the message.
+type invariant struct {
+	name  string
+	query string
+}
+
+// invariants are checked in order. Together they cover tree consistency
+// (every entry is reachable from the root through directories), link counts,
+// and size accounting of file data.
+var invariants = []invariant{
+	{"root is a directory", `
+		SELECT 1, 'missing or not a directory' WHERE NOT EXISTS (
+			SELECT 1 FROM fs_inode WHERE ino = 1 AND (mode & 61440) = 16384)`},
+	{"dentry parent is a directory", `
+		SELECT d.id, d.name FROM fs_dentry d
+		LEFT JOIN fs_inode p ON p.ino = d.parent_ino
+		WHERE p.ino IS NULL OR (p.mode & 61440) != 16384`},
+	{"dentry target exists", `
+		SELECT d.id, d.name FROM fs_dentry d
+		LEFT JOIN fs_inode i ON i.ino = d.ino
+		WHERE i.ino IS NULL`},
+	{"directory has one entry", `
+		SELECT d.ino, COUNT(*) FROM fs_dentry d
+		JOIN fs_inode i ON i.ino = d.ino
+		WHERE (i.mode & 61440) = 16384
+		GROUP BY d.ino HAVING COUNT(*) > 1`},
+	{"nlink matches entries", `
+		SELECT i.ino, i.nlink, COUNT(d.id) FROM fs_inode i
+		LEFT JOIN fs_dentry d ON d.ino = i.ino
+		WHERE i.ino != 1
+		GROUP BY i.ino HAVING i.nlink != COUNT(d.id)`},
+	{"inode reachable from root", `
+		WITH RECURSIVE reach(ino) AS (
+			SELECT 1
+			UNION
+			SELECT d.ino FROM fs_dentry d JOIN reach r ON d.parent_ino = r.ino
+		)
+		SELECT i.ino, i.mode FROM fs_inode i
+		WHERE i.ino NOT IN (SELECT ino FROM reach)`},
+	{"symlink has target", `
+		SELECT i.ino, i.mode FROM fs_inode i
+		LEFT JOIN fs_symlink s ON s.ino = i.ino
+		WHERE (i.mode & 61440) = 40960 AND s.ino IS NULL`},
+	{"symlink target belongs to symlink", `
+		SELECT s.ino, i.mode FROM fs_symlink s
+		LEFT JOIN fs_inode i ON i.ino = s.ino
+		WHERE i.ino IS NULL OR (i.mode & 61440) != 40960`},
+	{"data belongs to regular file", `
+		SELECT DISTINCT c.ino, i.mode FROM (
+			SELECT ino FROM fs_data UNION ALL SELECT ino FROM fs_data_ext
+		) c
+		LEFT JOIN fs_inode i ON i.ino = c.ino
+		WHERE i.ino IS NULL OR (i.mode & 61440) != 32768`},
+	{"chunk stored once", `
+		SELECT d.ino, d.chunk_index FROM fs_data d
+		JOIN fs_data_ext e ON e.ino = d.ino AND e.chunk_index = d.chunk_index`},
+	{"chunk within file size", `
+		SELECT c.ino, c.chunk_index, c.len, i.size FROM (
+			SELECT ino, chunk_index, length(data) AS len FROM fs_data
+			UNION ALL
+			SELECT ino, chunk_index, size FROM fs_data_ext
+		) c
+		JOIN fs_inode i ON i.ino = c.ino
+		LEFT JOIN fs_meta m ON m.ino = c.ino AND m.key = '` + agentfs.ChunkSizeMetaKey + `'
+		WHERE c.len > COALESCE(CAST(m.value AS INTEGER), :chunk)
+			OR c.chunk_index * COALESCE(CAST(m.value AS INTEGER), :chunk) + c.len > i.size`},
+	{"metadata belongs to inode", `
+		SELECT m.ino, m.key FROM fs_meta m
+		LEFT JOIN fs_inode i ON i.ino = m.ino
+		WHERE i.ino IS NULL`},
+}
+
+// CheckInvariants verifies that the filesystem stored in afs is internally
+// consistent and returns *InvariantError describing every violation found.
+//
+// It checks that every entry is reachable from the root through
+// directories, that link counts match directory entries, that symlinks and
+// file data belong to inodes of the right type, and that no chunk extends
+// past its file's size. It is meant to be called between steps of
+// random-operation tests, including tests of custom operations that write
+// to the database directly:
+//
+//	for i := 0; i < 1000; i++ {
+//	    applyRandomOp(ctx, afs, rng)
+//	    if err := agentfstest.CheckInvariants(ctx, afs); err != nil {
+//	        t.Fatalf("after op %d: %v", i, err)
+//	    }
+//	}
+func CheckInvariants(ctx context.Context, afs *agentfs.AgentFS) error {
+	db := afs.DB()
+
+	var chunk string
+	if err := db.QueryRowContext(ctx, `SELECT value FROM fs_config WHERE key = 'chunk_size'`).Scan(&chunk); err != nil {
+		return fmt.Errorf("failed to read chunk size: %w", err)
+	}
+	chunkSize, err := strconv.ParseInt(chunk, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid chunk size %q: %w", chunk, err)
+	}
+
+	var violations []string
+	for _, inv := range invariants {
+		query := strings.ReplaceAll(inv.query, ":chunk", strconv.FormatInt(chunkSize, 10))
+		found, err := queryViolations(ctx, db, query, maxViolations-len(violations))
+		if err != nil {
+			return fmt.Errorf("failed to check %q: %w", inv.name, err)
+		}
+		for _, v := range found {
+			violations = append(violations, inv.name+": "+v)
+		}
+		if len(violations) >= maxViolations {
+			break
+		}
+	}
+
+	if len(violations) > 0 {
+		return &InvariantError{Violations: violations}
+	}
+	return nil
+}
+
+// queryViolations runs query and formats up to limit rows as
+// space-separated values.
+func queryViolations(ctx context.Context, db *sql.DB, query string, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for rows.Next() && len(out) < limit {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		parts := make([]string, len(vals))
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			parts[i] = fmt.Sprint(v)
+		}
+		out = append(out, strings.Join(parts, " "))
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,112 @@
+package agentfstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func openTestDB(t *testing.T) *agentfs.AgentFS {
+	t.Helper()
+	afs, err := agentfs.Open(context.Background(), agentfs.AgentFSOptions{
+		Path:      filepath.Join(t.TempDir(), "test.db"),
+		ChunkSize: 64,
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { afs.Close() })
+	return afs
+}
+
+// randomOp applies one random filesystem operation. Errors are expected
+// (e.g. removing a missing file) and ignored; only invariants matter.
+func randomOp(ctx context.Context, fs *agentfs.Filesystem, rng *rand.Rand) string {
+	paths := []string{"/a", "/b", "/d", "/d/x", "/d/y", "/d/e", "/d/e/z"}
+	p := paths[rng.Intn(len(paths))]
+	q := paths[rng.Intn(len(paths))]
+
+	switch rng.Intn(9) {
+	case 0:
+		data := make([]byte, rng.Intn(300))
+		rng.Read(data)
+		fs.WriteFile(ctx, p, data, 0o644)
+		return fmt.Sprintf("WriteFile %s (%d bytes)", p, len(data))
+	case 1:
+		fs.Mkdir(ctx, p, 0o755)
+		return "Mkdir " + p
+	case 2:
+		fs.Unlink(ctx, p)
+		return "Unlink " + p
+	case 3:
+		fs.Rmdir(ctx, p)
+		return "Rmdir " + p
+	case 4:
+		fs.Rename(ctx, p, q)
+		return fmt.Sprintf("Rename %s %s", p, q)
+	case 5:
+		fs.Link(ctx, p, q)
+		return fmt.Sprintf("Link %s %s", p, q)
+	case 6:
+		fs.Symlink(ctx, q, p)
+		return fmt.Sprintf("Symlink %s -> %s", p, q)
+	case 7:
+		size := int64(rng.Intn(300))
+		if f, err := fs.Open(ctx, p, agentfs.O_RDWR); err == nil {
+			f.Truncate(ctx, size)
+			f.Close()
+		}
+		return fmt.Sprintf("Truncate %s %d", p, size)
+	default:
+		off := int64(rng.Intn(300))
+		data := make([]byte, rng.Intn(100))
+		if f, err := fs.Open(ctx, p, agentfs.O_RDWR); err == nil {
+			f.Pwrite(ctx, data, off)
+			f.Close()
+		}
+		return fmt.Sprintf("Pwrite %s %d+%d", p, off, len(data))
+	}
+}
+
+func TestCheckInvariantsRandomOps(t *testing.T) {
+	ctx := context.Background()
+	afs := openTestDB(t)
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		op := randomOp(ctx, afs.FS, rng)
+		if err := CheckInvariants(ctx, afs); err != nil {
+			t.Fatalf("after op %d (%s): %v", i, op, err)
+		}
+	}
+}
+
+func TestCheckInvariantsDetectsViolations(t *testing.T) {
+	ctx := context.Background()
+	afs := openTestDB(t)
+
+	if err := afs.FS.WriteFile(ctx, "/a.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := CheckInvariants(ctx, afs); err != nil {
+		t.Fatalf("CheckInvariants on a clean tree failed: %v", err)
+	}
+
+	if _, err := afs.DB().Exec("UPDATE fs_inode SET nlink = 3, size = 2 WHERE ino != 1"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+
+	err := CheckInvariants(ctx, afs)
+	var invErr *InvariantError
+	if !errors.As(err, &invErr) {
+		t.Fatalf("CheckInvariants: err = %v, want *InvariantError", err)
+	}
+	if len(invErr.Violations) != 2 {
+		t.Errorf("Violations = %q, want nlink and size violations", invErr.Violations)
+	}
+}
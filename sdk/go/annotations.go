@@ -0,0 +1,126 @@
+package agentfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Annotate labels the recorded tool call id with key = value, replacing any
+// earlier value of key. Annotations are stored apart from the call, which
+// stays immutable, and suit post-hoc data such as feedback scores, triage
+// status, or bug links. A nil value removes the annotation.
+//
+// Example:
+//
+//	err := afs.Tools.Annotate(ctx, call.ID, "feedback", 4)
+//	err = afs.Tools.Annotate(ctx, call.ID, "triage", "wontfix")
+func (tc *ToolCalls) Annotate(ctx context.Context, id int64, key string, value any) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if key == "" {
+		return fmt.Errorf("annotation key must not be empty")
+	}
+	if value == nil {
+		if _, err := tc.db.ExecContext(ctx, annotationDelete, id, key); err != nil {
+			return fmt.Errorf("failed to delete annotation: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize annotation: %w", err)
+	}
+	res, err := tc.db.ExecContext(ctx, annotationSet, id, key, string(data), tc.clock.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to set annotation: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("tool call not found: %d", id)
+	}
+	return nil
+}
+
+// Annotations returns the annotations of the tool call id as raw JSON values
+// keyed by name. A call without annotations yields an empty map.
+func (tc *ToolCalls) Annotations(ctx context.Context, id int64) (map[string]json.RawMessage, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return listAnnotations(ctx, tc.db, id)
+}
+
+// listAnnotations reads the annotations of the tool call id.
+func listAnnotations(ctx context.Context, db dbtx, id int64) (map[string]json.RawMessage, error) {
+	rows, err := db.QueryContext(ctx, annotationList, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := map[string]json.RawMessage{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		annotations[key] = json.RawMessage(value)
+	}
+	return annotations, rows.Err()
+}
+
+// Find returns the tool calls matching filter, most recent first.
+//
+// Example:
+//
+//	bad, err := afs.Tools.Find(ctx, agentfs.ToolCallFilter{
+//	    Name:        "web_search",
+//	    Annotations: map[string]any{"triage": "bug"},
+//	})
+func (tc *ToolCalls) Find(ctx context.Context, filter ToolCallFilter) ([]ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return findToolCalls(ctx, tc.db, filter)
+}
+
+// findToolCalls runs a ToolCallFilter against db.
+func findToolCalls(ctx context.Context, db dbtx, filter ToolCallFilter) ([]ToolCall, error) {
+	if filter.Limit == 0 {
+		filter.Limit = 100
+	}
+
+	// Values are compared as canonical JSON text; "" matches any value
+	want := make(map[string]string, len(filter.Annotations))
+	for key, value := range filter.Annotations {
+		if value == nil {
+			want[key] = ""
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize annotation filter: %w", err)
+		}
+		want[key] = string(data)
+	}
+	wantJSON, _ := json.Marshal(want)
+
+	rows, err := db.QueryContext(ctx, toolCallsFind, filter.Name, filter.Since, filter.Until, string(wantJSON), filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	return scanToolCalls(rows)
+}
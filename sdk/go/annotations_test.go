@@ -0,0 +1,69 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAnnotations(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	var ids []int64
+	for i, name := range []string{"search", "search", "shell"} {
+		call, err := afs.Tools.Record(ctx, name, nil, "ok", nil, int64(100+i), int64(101+i))
+		if err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		ids = append(ids, call.ID)
+	}
+
+	if err := afs.Tools.Annotate(ctx, ids[0], "triage", "bug"); err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	afs.Tools.Annotate(ctx, ids[0], "score", 4)
+	afs.Tools.Annotate(ctx, ids[1], "score", 2)
+	afs.Tools.Annotate(ctx, ids[2], "triage", "bug")
+
+	if err := afs.Tools.Annotate(ctx, 999, "triage", "bug"); err == nil {
+		t.Error("Annotate of a missing call succeeded")
+	}
+
+	annotations, err := afs.Tools.Annotations(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("Annotations failed: %v", err)
+	}
+	if len(annotations) != 2 || string(annotations["triage"]) != `"bug"` || string(annotations["score"]) != "4" {
+		t.Errorf("Annotations = %v", annotations)
+	}
+
+	for _, tc := range []struct {
+		filter ToolCallFilter
+		want   []int64
+	}{
+		{ToolCallFilter{Annotations: map[string]any{"triage": "bug"}}, []int64{ids[2], ids[0]}},
+		{ToolCallFilter{Name: "search", Annotations: map[string]any{"triage": "bug"}}, []int64{ids[0]}},
+		{ToolCallFilter{Annotations: map[string]any{"score": nil}}, []int64{ids[1], ids[0]}},
+		{ToolCallFilter{Annotations: map[string]any{"score": 2}}, []int64{ids[1]}},
+		{ToolCallFilter{Since: 101, Until: 102}, []int64{ids[1]}},
+	} {
+		calls, err := afs.Tools.Find(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		var got []int64
+		for _, c := range calls {
+			got = append(got, c.ID)
+		}
+		if len(got) != len(tc.want) || (len(got) > 0 && (got[0] != tc.want[0] || got[len(got)-1] != tc.want[len(tc.want)-1])) {
+			t.Errorf("Find(%+v) = %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+
+	// A nil value removes the annotation
+	afs.Tools.Annotate(ctx, ids[0], "triage", nil)
+	if annotations, _ := afs.Tools.Annotations(ctx, ids[0]); len(annotations) != 1 {
+		t.Errorf("Annotations after removal = %v", annotations)
+	}
+}
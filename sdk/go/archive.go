@@ -0,0 +1,223 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// archiveTablePrefix names the monthly archive tables, such as
+// tool_calls_archive_2026_01.
+const archiveTablePrefix = "tool_calls_archive_"
+
+// isArchiveName reports whether the identifier id names an archive table
+// or one of its indexes, which are created on demand rather than by the
+// schema.
+func isArchiveName(id string) bool {
+	return strings.HasPrefix(id, archiveTablePrefix) || strings.HasPrefix(id, "idx_"+archiveTablePrefix)
+}
+
+// ToolCallArchive describes a monthly table of archived tool calls.
+type ToolCallArchive struct {
+	Table string `json:"table"`
+	Month string `json:"month"` // "2006-01", by StartedAt in UTC
+	Calls int64  `json:"calls"`
+}
+
+// Archive moves the tool calls started before the given time out of the
+// hot tool_calls table into monthly archive tables, one per calendar month
+// (UTC) of StartedAt, and returns how many calls it moved. Archived calls
+// keep their IDs, annotations, usage, and other records, and every read
+// (Get, Query, Find, GetStats, Tree, ...) still sees them; only inserts
+// get cheaper, as the indexes of the hot table stay small.
+// Policy.ToolCallArchiveAfter does this in the background.
+//
+// Example:
+//
+//	// Keep the last week hot
+//	n, err := afs.Tools.Archive(ctx, time.Now().AddDate(0, 0, -7))
+func (tc *ToolCalls) Archive(ctx context.Context, before time.Time) (int64, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	var moved int64
+	err = tc.inTx(ctx, func(db dbtx) error {
+		moved, err = archiveToolCalls(ctx, db, before.Unix())
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive tool calls: %w", err)
+	}
+	return moved, nil
+}
+
+// archiveToolCalls moves the calls started before cutoff (Unix seconds)
+// into their monthly archives, in the transaction db.
+func archiveToolCalls(ctx context.Context, db dbtx, cutoff int64) (int64, error) {
+	rows, err := db.QueryContext(ctx, toolCallArchiveMonths, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var months []string
+	for rows.Next() {
+		var month string
+		if err := rows.Scan(&month); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		months = append(months, month)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(months) == 0 {
+		return 0, err
+	}
+
+	created := false
+	for _, month := range months {
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			return 0, err
+		}
+		table := archiveTablePrefix + strings.Replace(month, "-", "_", 1)
+		for _, q := range []string{toolCallArchiveCreate, toolCallArchiveNameIndex, toolCallArchiveStartedAtIndex, toolCallArchiveUIDIndex} {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf(q, table)); err != nil {
+				return 0, err
+			}
+		}
+		res, err := db.ExecContext(ctx, toolCallArchiveRegister, table, month)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			created = true
+		}
+		end := min(start.AddDate(0, 1, 0).Unix(), cutoff)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(toolCallArchiveMove, table), start.Unix(), end); err != nil {
+			return 0, err
+		}
+	}
+	res, err := db.ExecContext(ctx, toolCallArchiveRemove, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	moved, _ := res.RowsAffected()
+	if created {
+		return moved, rebuildToolCallHistory(ctx, db)
+	}
+	return moved, nil
+}
+
+// Archives lists the archive tables, oldest first.
+func (tc *ToolCalls) Archives(ctx context.Context) ([]ToolCallArchive, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	archives, err := listArchives(ctx, tc.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+	for i := range archives {
+		q := fmt.Sprintf(toolCallArchiveCount, archives[i].Table)
+		if err := tc.db.QueryRowContext(ctx, q).Scan(&archives[i].Calls); err != nil {
+			return nil, fmt.Errorf("failed to count archived calls: %w", err)
+		}
+	}
+	return archives, nil
+}
+
+// listArchives returns the registered archive tables, without counts.
+func listArchives(ctx context.Context, db dbtx) ([]ToolCallArchive, error) {
+	rows, err := db.QueryContext(ctx, toolCallArchiveList)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var archives []ToolCallArchive
+	for rows.Next() {
+		var a ToolCallArchive
+		if err := rows.Scan(&a.Table, &a.Month); err != nil {
+			return nil, err
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+// rebuildToolCallHistory recreates the tool_call_history view over
+// tool_calls and the registered archives.
+func rebuildToolCallHistory(ctx context.Context, db dbtx) error {
+	archives, err := listArchives(ctx, db)
+	if err != nil {
+		return err
+	}
+	branches := []string{fmt.Sprintf(toolCallHistorySelect, "tool_calls")}
+	for _, a := range archives {
+		branches = append(branches, fmt.Sprintf(toolCallHistorySelect, a.Table))
+	}
+	if _, err := db.ExecContext(ctx, toolCallHistoryDrop); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, toolCallHistoryCreate+strings.Join(branches, " UNION ALL "))
+	return err
+}
+
+// expireArchives removes the archived calls completed before cutoff, drops
+// the archives left empty, and returns how many calls it removed.
+func expireArchives(ctx context.Context, db dbtx, cutoff int64) (int64, error) {
+	return pruneArchives(ctx, db, toolCallArchiveExpire, cutoff)
+}
+
+// pruneArchives runs the delete q with arg on every archive, drops the
+// archives left empty, and returns how many calls it removed.
+func pruneArchives(ctx context.Context, db dbtx, q string, arg int64) (int64, error) {
+	archives, err := listArchives(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	var removed int64
+	var empty []string
+	for _, a := range archives {
+		res, err := db.ExecContext(ctx, fmt.Sprintf(q, a.Table), arg)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+		if n == 0 {
+			continue
+		}
+		var left int64
+		if err := db.QueryRowContext(ctx, fmt.Sprintf(toolCallArchiveCount, a.Table)).Scan(&left); err != nil {
+			return 0, err
+		}
+		if left == 0 {
+			empty = append(empty, a.Table)
+		}
+	}
+	if len(empty) == 0 {
+		return removed, nil
+	}
+
+	// Take the empty archives out of the view before dropping them
+	for _, table := range empty {
+		if _, err := db.ExecContext(ctx, toolCallArchiveUnregister, table); err != nil {
+			return 0, err
+		}
+	}
+	if err := rebuildToolCallHistory(ctx, db); err != nil {
+		return 0, err
+	}
+	for _, table := range empty {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(toolCallArchiveDrop, table)); err != nil {
+			return 0, err
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,117 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestToolCalls_Archive(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	jan := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC).Unix()
+	feb := time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC).Unix()
+	mar := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC).Unix()
+	old, _ := afs.Tools.Record(ctx, "search", map[string]string{"q": "jan"}, "ok", nil, jan, jan+1)
+	afs.Tools.Record(ctx, "fetch", nil, "ok", nil, feb, feb+1)
+	hot, _ := afs.Tools.Record(ctx, "search", nil, "ok", nil, mar, mar+1)
+	if err := afs.Tools.Annotate(ctx, old.ID, "label", "first"); err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+
+	n, err := afs.Tools.Archive(ctx, time.Unix(mar, 0))
+	if err != nil || n != 2 {
+		t.Fatalf("Archive = %d, %v; want 2", n, err)
+	}
+	archives, err := afs.Tools.Archives(ctx)
+	if err != nil || len(archives) != 2 || archives[0].Month != "2026-01" || archives[0].Calls != 1 || archives[1].Table != "tool_calls_archive_2026_02" {
+		t.Fatalf("Archives = %+v, %v", archives, err)
+	}
+	var hotRows int
+	afs.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tool_calls").Scan(&hotRows)
+	if hotRows != 1 {
+		t.Errorf("tool_calls has %d rows, want 1", hotRows)
+	}
+
+	// Reads see the archived calls
+	got, err := afs.Tools.Get(ctx, old.ID)
+	if err != nil || string(got.Parameters) != `{"q":"jan"}` {
+		t.Errorf("Get of an archived call = %+v, %v", got, err)
+	}
+	calls, err := afs.Tools.GetByName(ctx, "search", 10)
+	if err != nil || len(calls) != 2 || calls[0].ID != hot.ID || calls[1].ID != old.ID {
+		t.Errorf("GetByName = %+v, %v", calls, err)
+	}
+	page, err := afs.Tools.Query(ctx, ToolCallQuery{})
+	if err != nil || len(page.Calls) != 3 {
+		t.Errorf("Query returned %d calls, %v; want 3", len(page.Calls), err)
+	}
+	if found, err := afs.Tools.Find(ctx, ToolCallFilter{Annotations: map[string]any{"label": "first"}}); err != nil || len(found) != 1 {
+		t.Errorf("Find by annotation = %+v, %v", found, err)
+	}
+	if err := afs.Tools.Annotate(ctx, old.ID, "label", "again"); err != nil {
+		t.Errorf("Annotate of an archived call failed: %v", err)
+	}
+
+	// Archiving again adds to the existing tables; new calls get new IDs
+	next, _ := afs.Tools.Record(ctx, "fetch", nil, "ok", nil, feb+60, feb+61)
+	if next.ID <= hot.ID {
+		t.Errorf("new call ID %d reuses an archived ID", next.ID)
+	}
+	if n, err := afs.Tools.Archive(ctx, time.Unix(mar, 0)); err != nil || n != 1 {
+		t.Errorf("second Archive = %d, %v; want 1", n, err)
+	}
+	if archives, _ := afs.Tools.Archives(ctx); len(archives) != 2 || archives[1].Calls != 2 {
+		t.Errorf("Archives = %+v, want 2 calls in February", archives)
+	}
+}
+
+func TestEnforcePolicy_ArchiveAndExpire(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	afs, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "agent.db"), Clock: clock, TablePrefix: "agent_"})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	now := clock.Now().Unix()
+	old, _ := afs.Tools.Record(ctx, "search", nil, "ok", nil, now-40*86400, now-40*86400)
+	afs.Tools.Record(ctx, "search", nil, "ok", nil, now-10*86400, now-10*86400)
+	recent, _ := afs.Tools.Record(ctx, "search", nil, "ok", nil, now-60, now-60)
+
+	if err := afs.SetPolicy(ctx, Policy{ToolCallArchiveAfter: 7 * 24 * time.Hour}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	report, err := afs.EnforcePolicy(ctx)
+	if err != nil || report.ArchivedToolCalls != 2 {
+		t.Fatalf("EnforcePolicy = %+v, %v; want 2 archived calls", report, err)
+	}
+	// Archive tables share the table prefix
+	var n int
+	afs.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name LIKE 'agent_tool_calls_archive_%' AND type = 'table'").Scan(&n)
+	if n != 2 {
+		t.Errorf("found %d prefixed archive tables, want 2", n)
+	}
+
+	// Retention reaches into the archives and drops those left empty
+	if err := afs.SetPolicy(ctx, Policy{ToolCallRetention: 30 * 24 * time.Hour, ToolCallArchiveAfter: 7 * 24 * time.Hour}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	report, err = afs.EnforcePolicy(ctx)
+	if err != nil || report.ToolCalls != 1 {
+		t.Fatalf("EnforcePolicy = %+v, %v; want 1 expired call", report, err)
+	}
+	if _, err := afs.Tools.Get(ctx, old.ID); err == nil {
+		t.Error("expired archived call still present")
+	}
+	if archives, err := afs.Tools.Archives(ctx); err != nil || len(archives) != 1 || archives[0].Month != "2026-02" {
+		t.Errorf("Archives = %+v, %v; want only February", archives, err)
+	}
+	if calls, err := afs.Tools.GetRecent(ctx, 0, 10); err != nil || len(calls) != 2 || calls[0].ID != recent.ID {
+		t.Errorf("GetRecent = %+v, %v", calls, err)
+	}
+}
@@ -0,0 +1,143 @@
+package agentfs
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// EnsurePaths creates many directories and empty files in a single transaction.
+//
+// Paths ending in "/" are created as directories; all other paths are created
+// as empty regular files. Missing parent directories are created, and parents
+// shared between paths are resolved only once. Entries that already exist with
+// the requested type are left untouched. If an existing entry has the wrong
+// type (EISDIR or ENOTDIR), nothing is created.
+//
+// Example:
+//
+//	err := afs.FS.EnsurePaths(ctx, []string{
+//	    "/project/src/",
+//	    "/project/src/main.go",
+//	    "/project/README.md",
+//	})
+func (fs *Filesystem) EnsurePaths(ctx context.Context, paths []string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return fs.inTx(ctx, func(tfs *Filesystem) error {
+		dirs := map[string]int64{"/": RootIno}
+
+		for _, raw := range paths {
+			wantDir := strings.HasSuffix(raw, "/")
+			p, err := tfs.cleanPath(ctx, "ensure", raw)
+			if err != nil {
+				return err
+			}
+			if p == "/" {
+				continue
+			}
+
+			parentPath, name := path.Split(p)
+			parentPath = normalizePath(parentPath)
+
+			if err := validateName("ensure", name); err != nil {
+				return err
+			}
+
+			parentIno, err := tfs.ensureDir(ctx, parentPath, dirs)
+			if err != nil {
+				return err
+			}
+
+			ino, mode, err := tfs.lookupDentryWithMode(ctx, parentIno, name)
+			if err == nil {
+				isDir := (mode & S_IFMT) == S_IFDIR
+				if wantDir && !isDir {
+					return ErrNotDir("ensure", p)
+				}
+				if !wantDir && isDir {
+					return ErrIsDir("ensure", p)
+				}
+				if isDir {
+					dirs[p] = ino
+				}
+				continue
+			}
+			if !IsNotExist(err) {
+				return err
+			}
+
+			if wantDir {
+				ino, err := tfs.createNode(ctx, parentIno, name, DefaultDirMode, 0)
+				if err != nil {
+					return err
+				}
+				dirs[p] = ino
+				tfs.emit(EventDirCreated, p, "")
+			} else {
+				if _, err := tfs.createNode(ctx, parentIno, name, DefaultFileMode, 0); err != nil {
+					return err
+				}
+				tfs.emit(EventFileCreated, p, "")
+			}
+		}
+
+		return tfs.checkAll(ctx, "ensure", "/")
+	})
+}
+
+// ensureDir returns the inode of the directory at p, creating it and any
+// missing ancestors. Resolved directories are memoized in dirs.
+func (fs *Filesystem) ensureDir(ctx context.Context, p string, dirs map[string]int64) (int64, error) {
+	if ino, ok := dirs[p]; ok {
+		return ino, nil
+	}
+
+	parentPath, name := path.Split(p)
+	parentPath = normalizePath(parentPath)
+
+	if err := validateName("mkdir", name); err != nil {
+		return 0, err
+	}
+
+	parentIno, err := fs.ensureDir(ctx, parentPath, dirs)
+	if err != nil {
+		return 0, err
+	}
+
+	ino, mode, err := fs.lookupDentryWithMode(ctx, parentIno, name)
+	switch {
+	case err == nil && (mode&S_IFMT) == S_IFDIR:
+		// Existing directory
+	case err == nil && (mode&S_IFMT) == S_IFLNK:
+		// Follow symlinked directories like MkdirAll does
+		ino, err = fs.resolvePathFollow(ctx, p, true)
+		if err != nil {
+			return 0, err
+		}
+		stats, err := fs.statInode(ctx, ino)
+		if err != nil {
+			return 0, err
+		}
+		if !stats.IsDir() {
+			return 0, ErrNotDir("mkdir", p)
+		}
+	case err == nil:
+		return 0, ErrNotDir("mkdir", p)
+	case IsNotExist(err):
+		ino, err = fs.createNode(ctx, parentIno, name, DefaultDirMode, 0)
+		if err != nil {
+			return 0, err
+		}
+		fs.emit(EventDirCreated, p, "")
+	default:
+		return 0, err
+	}
+
+	dirs[p] = ino
+	return ino, nil
+}
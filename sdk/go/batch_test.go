@@ -0,0 +1,115 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEnsurePaths(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	t.Run("creates skeleton", func(t *testing.T) {
+		err := fs.EnsurePaths(ctx, []string{
+			"/project/src/",
+			"/project/src/main.go",
+			"/project/docs/guide/intro.md",
+			"/project/README.md",
+			"/project/src/main.go", // duplicate
+		})
+		if err != nil {
+			t.Fatalf("EnsurePaths failed: %v", err)
+		}
+
+		for _, p := range []string{"/project/src", "/project/docs/guide"} {
+			stats, err := fs.Stat(ctx, p)
+			if err != nil || !stats.IsDir() {
+				t.Errorf("%s: stats=%v err=%v, want directory", p, stats, err)
+			}
+		}
+		for _, p := range []string{"/project/src/main.go", "/project/docs/guide/intro.md", "/project/README.md"} {
+			stats, err := fs.Stat(ctx, p)
+			if err != nil || !stats.IsRegularFile() || stats.Size != 0 {
+				t.Errorf("%s: stats=%v err=%v, want empty file", p, stats, err)
+			}
+		}
+	})
+
+	t.Run("existing content untouched", func(t *testing.T) {
+		if err := fs.WriteFile(ctx, "/keep/data.txt", []byte("data"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if err := fs.EnsurePaths(ctx, []string{"/keep/", "/keep/data.txt"}); err != nil {
+			t.Fatalf("EnsurePaths failed: %v", err)
+		}
+		data, _ := fs.ReadFile(ctx, "/keep/data.txt")
+		if string(data) != "data" {
+			t.Errorf("content = %q, want %q", data, "data")
+		}
+	})
+
+	t.Run("type conflict rolls back", func(t *testing.T) {
+		err := fs.EnsurePaths(ctx, []string{"/rollback/a.txt", "/keep/data.txt/"})
+		if err == nil {
+			t.Fatal("expected ENOTDIR")
+		}
+		if _, err := fs.Stat(ctx, "/rollback"); !IsNotExist(err) {
+			t.Errorf("Stat(/rollback) err = %v, want ENOENT after rollback", err)
+		}
+	})
+}
+
+func TestMoveTree(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	if err := fs.EnsurePaths(ctx, []string{"/src/a/b/c.txt", "/src/d.txt", "/taken/"}); err != nil {
+		t.Fatalf("EnsurePaths failed: %v", err)
+	}
+	if err := fs.WriteFile(ctx, "/src/a/b/c.txt", []byte("deep"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	t.Run("moves subtree", func(t *testing.T) {
+		if err := fs.MoveTree(ctx, "/src", "/dst/moved"); err != nil {
+			t.Fatalf("MoveTree failed: %v", err)
+		}
+		data, err := fs.ReadFile(ctx, "/dst/moved/a/b/c.txt")
+		if err != nil || string(data) != "deep" {
+			t.Errorf("ReadFile = %q, %v", data, err)
+		}
+		if _, err := fs.Stat(ctx, "/src"); !IsNotExist(err) {
+			t.Errorf("Stat(/src) err = %v, want ENOENT", err)
+		}
+	})
+
+	t.Run("refuses to clobber", func(t *testing.T) {
+		if err := fs.MoveTree(ctx, "/dst/moved", "/taken"); !IsExist(err) {
+			t.Errorf("err = %v, want EEXIST", err)
+		}
+	})
+
+	t.Run("refuses own subtree", func(t *testing.T) {
+		err := fs.MoveTree(ctx, "/dst/moved", "/dst/moved/a/inner")
+		var fsErr *FSError
+		if !errors.As(err, &fsErr) || fsErr.Code != EINVAL {
+			t.Errorf("err = %v, want EINVAL", err)
+		}
+	})
+
+	t.Run("refuses own subtree via symlink", func(t *testing.T) {
+		if err := fs.Symlink(ctx, "/dst/moved/a", "/link"); err != nil {
+			t.Fatalf("Symlink failed: %v", err)
+		}
+		err := fs.MoveTree(ctx, "/dst/moved", "/link/inner")
+		var fsErr *FSError
+		if !errors.As(err, &fsErr) || fsErr.Code != EINVAL {
+			t.Errorf("err = %v, want EINVAL", err)
+		}
+	})
+}
@@ -558,6 +558,73 @@ func BenchmarkPathResolution(b *testing.B) {
 	}
 }
 
+// ============================================================================
+// Filesystem - Large Directories
+// ============================================================================
+
+// BenchmarkLargeDirectory measures operations in one directory of up to a
+// million entries; none should slow down as the directory grows. Run with
+// -bench LargeDirectory -benchtime 1000x; populating takes a while.
+func BenchmarkLargeDirectory(b *testing.B) {
+	counts := []int{10_000, 100_000, 1_000_000}
+
+	for _, count := range counts {
+		afs := setupBenchmarkDB(b)
+		ctx := context.Background()
+
+		// Populate in one transaction; per-file commits dominate otherwise
+		tx, err := afs.Begin(ctx)
+		if err != nil {
+			b.Fatalf("Begin failed: %v", err)
+		}
+		if err := tx.FS.Mkdir(ctx, "/big", 0o755); err != nil {
+			b.Fatalf("Mkdir failed: %v", err)
+		}
+		for i := 0; i < count; i++ {
+			if err := tx.FS.WriteFile(ctx, fmt.Sprintf("/big/f%07d", i), nil, 0o644); err != nil {
+				b.Fatalf("WriteFile failed: %v", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			b.Fatalf("Commit failed: %v", err)
+		}
+		last := fmt.Sprintf("f%07d", count-DefaultReaddirPageSize-1)
+
+		b.Run(fmt.Sprintf("%d_entries/Stat", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := afs.FS.Stat(ctx, fmt.Sprintf("/big/f%07d", i%count)); err != nil {
+					b.Fatalf("Stat failed: %v", err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%d_entries/CreateDelete", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := afs.FS.WriteFile(ctx, "/big/new", nil, 0o644); err != nil {
+					b.Fatalf("WriteFile failed: %v", err)
+				}
+				if err := afs.FS.Unlink(ctx, "/big/new"); err != nil {
+					b.Fatalf("Unlink failed: %v", err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%d_entries/ReaddirPageFirst", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := afs.FS.ReaddirPage(ctx, "/big", ReaddirOptions{}); err != nil {
+					b.Fatalf("ReaddirPage failed: %v", err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%d_entries/ReaddirPageLast", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := afs.FS.ReaddirPage(ctx, "/big", ReaddirOptions{After: last}); err != nil {
+					b.Fatalf("ReaddirPage failed: %v", err)
+				}
+			}
+		})
+		afs.Close()
+	}
+}
+
 // ============================================================================
 // KV Store
 // ============================================================================
@@ -0,0 +1,262 @@
+package agentfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/tursodatabase/agentfs/sdk/go/internal/cache"
+)
+
+// ErrChunkMismatch is returned when a chunk fetched from an external store
+// does not hash to the key it was stored under, e.g. because the store
+// returned another chunk or a damaged copy.
+var ErrChunkMismatch = errors.New("agentfs: chunk does not match its hash")
+
+// localTier is the name of the tier configured by
+// ExternalStorageOptions.Threshold and Dir.
+const localTier = "local"
+
+// blobStore routes chunks of large files to the configured storage tiers.
+// Chunks are keyed by the SHA-256 of their content, so identical chunks are
+// stored once per tier.
+type blobStore struct {
+	tiers  []StorageTier // sorted by MinSize, largest first
+	stores map[string]ChunkStore
+	cache  *cache.ChunkLRU // nil unless CacheSize is set
+	pins   pinSet          // Files whose chunks the cache keeps (see Pin)
+
+	// used is set once any chunk is stored externally, so databases that
+	// never use external storage skip the extra lookups.
+	used atomic.Bool
+}
+
+// openBlobStore returns the blob store for a database, or nil when external
+// storage is disabled and no chunks were stored externally before.
+func openBlobStore(ctx context.Context, db *sql.DB, dbPath string, opts ExternalStorageOptions) (*blobStore, error) {
+	rows, err := db.QueryContext(ctx, extChunkTiers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check external chunks: %w", err)
+	}
+	var inUse []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to check external chunks: %w", err)
+		}
+		inUse = append(inUse, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check external chunks: %w", err)
+	}
+
+	tiers := append([]StorageTier(nil), opts.Tiers...)
+	if opts.Threshold > 0 {
+		dir := opts.Dir
+		if dir == "" && dbPath != "" {
+			dir = dbPath + ".blobs"
+		}
+		if dir == "" {
+			return nil, fmt.Errorf("external storage requires a blob directory")
+		}
+		tiers = append(tiers, StorageTier{Name: localTier, MinSize: opts.Threshold, Store: NewDirStore(dir)})
+	}
+	if len(tiers) == 0 && len(inUse) == 0 {
+		return nil, nil
+	}
+
+	b := &blobStore{stores: make(map[string]ChunkStore)}
+	for _, tier := range tiers {
+		switch {
+		case tier.Name == "":
+			return nil, fmt.Errorf("invalid storage tier: empty name")
+		case tier.Store == nil:
+			return nil, fmt.Errorf("invalid storage tier %q: no store", tier.Name)
+		case tier.MinSize <= 0:
+			return nil, fmt.Errorf("invalid storage tier %q: MinSize must be positive", tier.Name)
+		}
+		if _, ok := b.stores[tier.Name]; ok {
+			return nil, fmt.Errorf("invalid storage tier %q: duplicate name", tier.Name)
+		}
+		b.stores[tier.Name] = tier.Store
+	}
+	sort.SliceStable(tiers, func(i, j int) bool { return tiers[i].MinSize > tiers[j].MinSize })
+	b.tiers = tiers
+
+	// Chunks written by an earlier Threshold/Dir configuration stay readable
+	// from the default directory without any options.
+	for _, name := range inUse {
+		if _, ok := b.stores[name]; !ok && name == localTier && dbPath != "" {
+			b.stores[name] = NewDirStore(dbPath + ".blobs")
+		}
+	}
+
+	if opts.CacheSize > 0 {
+		if b.cache, err = cache.NewChunkLRU(opts.CacheSize); err != nil {
+			return nil, err
+		}
+	}
+
+	b.used.Store(len(inUse) > 0)
+	return b, nil
+}
+
+// tierFor returns the tier for chunks of a file of the given size, or nil if
+// they are stored in SQLite.
+func (b *blobStore) tierFor(fileSize int64) *StorageTier {
+	if b == nil {
+		return nil
+	}
+	for i := range b.tiers {
+		if fileSize >= b.tiers[i].MinSize {
+			return &b.tiers[i]
+		}
+	}
+	return nil
+}
+
+// inUse reports whether any chunk may be stored externally.
+func (b *blobStore) inUse() bool {
+	return b != nil && b.used.Load()
+}
+
+// put stores data in tier and returns its key.
+func (b *blobStore) put(ctx context.Context, tier *StorageTier, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	if err := tier.Store.Put(ctx, key, data); err != nil {
+		return "", err
+	}
+	b.used.Store(true)
+	return key, nil
+}
+
+// get returns the chunk stored under key in the named tier, consulting the
+// read-through cache first. Chunks are checked against their key before
+// they are cached, so the cache only holds verified data. Chunks of pinned
+// files stay in the cache.
+func (b *blobStore) get(ctx context.Context, tier, key string, pin bool) ([]byte, error) {
+	if b.cache != nil {
+		if data, ok := b.cache.Get(key); ok {
+			return data, nil
+		}
+	}
+	store, ok := b.stores[tier]
+	if !ok {
+		return nil, fmt.Errorf("file data is stored in chunk store %q, which is not configured", tier)
+	}
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != key {
+		return nil, fmt.Errorf("%w: %s in chunk store %q", ErrChunkMismatch, key, tier)
+	}
+	if b.cache != nil {
+		if pin {
+			b.cache.AddPinned(key, data)
+		} else {
+			b.cache.Add(key, data)
+		}
+	}
+	return data, nil
+}
+
+// PruneBlobs removes chunks in the external storage tiers that are no
+// longer referenced by any file and were last modified more than
+// opts.MinAge ago. It returns the number of chunks removed.
+//
+// Chunks are not removed when files are deleted or overwritten, since a
+// rolled-back transaction may still need them; call PruneBlobs periodically
+// instead. Each tier must use its own store. Chunks that a fork, or the
+// database it was forked from, still references are kept (see Fork).
+func (a *AgentFS) PruneBlobs(ctx context.Context, opts *PruneOptions) (int, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	if opts == nil {
+		opts = &PruneOptions{}
+	}
+
+	b := a.FS.blobs
+	if b == nil {
+		return 0, nil
+	}
+
+	rows, err := a.db.QueryContext(ctx, extChunkHashes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list external chunks: %w", err)
+	}
+	referenced := make(map[string]map[string]bool)
+	for rows.Next() {
+		var tier, key string
+		if err := rows.Scan(&tier, &key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to list external chunks: %w", err)
+		}
+		if referenced[tier] == nil {
+			referenced[tier] = make(map[string]bool)
+		}
+		referenced[tier][key] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list external chunks: %w", err)
+	}
+	if err := a.addSharedReferences(ctx, referenced); err != nil {
+		return 0, err
+	}
+
+	tracker := newProgressTracker("prune", opts.Progress)
+	st, err := loadResume(ctx, a.db, a.FS.clock, opts.ResumeToken, "prune", "", tracker)
+	if err != nil {
+		return 0, err
+	}
+
+	// Visit tiers in a fixed order so the cursor "tier/key" can resume
+	names := make([]string, 0, len(b.stores))
+	for name := range b.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cutoff := time.Now().Add(-opts.MinAge)
+	removed := 0
+	for _, name := range names {
+		store := b.stores[name]
+		err := store.List(ctx, func(key string, modTime time.Time) error {
+			pos := name + "/" + key
+			if st.skip(pos) || referenced[name][key] || modTime.After(cutoff) {
+				return nil
+			}
+			if err := opts.Limiter.Wait(ctx, 0); err != nil {
+				return err
+			}
+			if err := store.Delete(ctx, key); err != nil {
+				return err
+			}
+			removed++
+			next := tracker.p
+			next.Items++
+			if err := st.save(ctx, a.db, pos, next); err != nil {
+				return err
+			}
+			return tracker.add(ctx, 1, 0, key)
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, st.finish(ctx, a.db)
+}
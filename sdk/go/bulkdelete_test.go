@@ -0,0 +1,80 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRemoveGlob(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	for _, p := range []string{"/tmp/a.log", "/tmp/b.log", "/tmp/keep.txt", "/tmp/sub/c.log"} {
+		if err := afs.FS.WriteFile(ctx, p, []byte("data"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	if err := afs.FS.Link(ctx, "/tmp/a.log", "/a-link"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	if err := afs.FS.Mkdir(ctx, "/tmp/dir.log", 0o755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	n, err := afs.FS.RemoveGlob(ctx, "/tmp/*.log")
+	if err != nil {
+		t.Fatalf("RemoveGlob failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("removed %d entries, want 2", n)
+	}
+
+	for p, want := range map[string]bool{
+		"/tmp/a.log": false, "/tmp/b.log": false, "/tmp/keep.txt": true,
+		"/tmp/sub/c.log": true, "/tmp/dir.log": true, "/a-link": true,
+	} {
+		if _, err := afs.FS.Stat(ctx, p); (err == nil) != want {
+			t.Errorf("Stat(%s): err = %v, want exists = %v", p, err, want)
+		}
+	}
+
+	// The hard link keeps the data of a.log alive
+	data, err := afs.FS.ReadFile(ctx, "/a-link")
+	if err != nil || string(data) != "data" {
+		t.Errorf("ReadFile(/a-link) = %q, %v", data, err)
+	}
+	if stats, _ := afs.FS.Stat(ctx, "/a-link"); stats.Nlink != 1 {
+		t.Errorf("Nlink = %d, want 1", stats.Nlink)
+	}
+
+	if n, err := afs.FS.RemoveGlob(ctx, "/missing/*"); err != nil || n != 0 {
+		t.Errorf("RemoveGlob(/missing/*) = %d, %v", n, err)
+	}
+	if _, err := afs.FS.RemoveGlob(ctx, "/tmp/[a"); !errors.Is(err, &FSError{Code: EINVAL}) {
+		t.Errorf("bad pattern: err = %v, want EINVAL", err)
+	}
+}
+
+func TestKVDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	for _, key := range []string{"run:1", "run:2", "run_x", "other"} {
+		afs.KV.Set(ctx, key, 1)
+	}
+
+	n, err := afs.KV.DeletePrefix(ctx, "run:")
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d keys, want 2", n)
+	}
+	keys, _ := afs.KV.Keys(ctx, "")
+	if len(keys) != 2 {
+		t.Errorf("keys = %v, want [other run_x]", keys)
+	}
+}
@@ -0,0 +1,183 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CheckpointMode selects the SQLite WAL checkpoint strategy.
+type CheckpointMode int
+
+const (
+	// CheckpointPassive copies as many frames as possible without waiting
+	// for readers or writers.
+	CheckpointPassive CheckpointMode = iota
+	// CheckpointFull waits for writers, then checkpoints the whole WAL.
+	CheckpointFull
+	// CheckpointRestart is like CheckpointFull and also waits for readers
+	// so the next writer starts at the beginning of the WAL.
+	CheckpointRestart
+	// CheckpointTruncate is like CheckpointRestart and also truncates the
+	// WAL file to zero bytes.
+	CheckpointTruncate
+)
+
+// String returns the PRAGMA argument for the mode.
+func (m CheckpointMode) String() string {
+	switch m {
+	case CheckpointFull:
+		return "FULL"
+	case CheckpointRestart:
+		return "RESTART"
+	case CheckpointTruncate:
+		return "TRUNCATE"
+	default:
+		return "PASSIVE"
+	}
+}
+
+// Default automatic checkpoint settings
+const (
+	DefaultCheckpointPollInterval = 10 * time.Second
+)
+
+// CheckpointResult reports the outcome of a single WAL checkpoint.
+type CheckpointResult struct {
+	Busy         bool  `json:"busy"`         // True if the checkpoint could not complete
+	LogFrames    int64 `json:"log_frames"`   // Frames in the WAL (-1 if not in WAL mode)
+	Checkpointed int64 `json:"checkpointed"` // Frames copied back into the database
+}
+
+// CheckpointMetrics reports cumulative checkpoint activity for an AgentFS instance.
+type CheckpointMetrics struct {
+	Checkpoints    int64     `json:"checkpoints"`     // Successful checkpoints
+	Failures       int64     `json:"failures"`        // Checkpoints that returned an error
+	BusyCount      int64     `json:"busy_count"`      // Checkpoints that could not complete
+	FramesWritten  int64     `json:"frames_written"`  // Total frames checkpointed
+	LastCheckpoint time.Time `json:"last_checkpoint"` // Time of the last successful checkpoint
+	LastWALSize    int64     `json:"last_wal_size"`   // WAL size in bytes at the last check
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+// checkpointState holds the checkpoint metrics guarded by a mutex.
+type checkpointState struct {
+	mu      sync.Mutex
+	metrics CheckpointMetrics
+}
+
+// Checkpoint runs a WAL checkpoint with the given mode.
+func (a *AgentFS) Checkpoint(ctx context.Context, mode CheckpointMode) (*CheckpointResult, error) {
+	var busy int64
+	var res CheckpointResult
+	query := fmt.Sprintf("PRAGMA wal_checkpoint(%s)", mode)
+	err := a.db.QueryRowContext(ctx, query).Scan(&busy, &res.LogFrames, &res.Checkpointed)
+
+	a.checkpoints.mu.Lock()
+	defer a.checkpoints.mu.Unlock()
+	m := &a.checkpoints.metrics
+	if err != nil {
+		m.Failures++
+		m.LastError = err.Error()
+		return nil, fmt.Errorf("checkpoint failed: %w", err)
+	}
+
+	res.Busy = busy != 0
+	if res.Busy {
+		m.BusyCount++
+	} else {
+		m.Checkpoints++
+		m.LastCheckpoint = time.Now()
+		m.LastError = ""
+	}
+	if res.Checkpointed > 0 {
+		m.FramesWritten += res.Checkpointed
+	}
+
+	return &res, nil
+}
+
+// CheckpointMetrics returns a snapshot of the checkpoint metrics.
+func (a *AgentFS) CheckpointMetrics() CheckpointMetrics {
+	a.checkpoints.mu.Lock()
+	defer a.checkpoints.mu.Unlock()
+	return a.checkpoints.metrics
+}
+
+// WALSize returns the current size of the WAL file in bytes.
+// Returns 0 if the database has no WAL file on disk (e.g. in-memory or OpenWith).
+func (a *AgentFS) WALSize() int64 {
+	if a.path == "" || a.path == ":memory:" {
+		return 0
+	}
+	info, err := os.Stat(a.path + "-wal")
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// startCheckpointer starts the automatic checkpoint worker if the policy is enabled.
+func (a *AgentFS) startCheckpointer(opts CheckpointOptions) {
+	if opts.Interval <= 0 && opts.MaxWALSize <= 0 {
+		return
+	}
+
+	poll := opts.PollInterval
+	if poll <= 0 {
+		poll = DefaultCheckpointPollInterval
+	}
+	if opts.Interval > 0 && opts.Interval < poll {
+		poll = opts.Interval
+	}
+
+	a.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+
+		last := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			walSize := a.WALSize()
+			a.checkpoints.mu.Lock()
+			a.checkpoints.metrics.LastWALSize = walSize
+			a.checkpoints.mu.Unlock()
+
+			due := opts.Interval > 0 && time.Since(last) >= opts.Interval
+			if opts.MaxWALSize > 0 && walSize >= opts.MaxWALSize {
+				due = true
+			}
+			if !due {
+				continue
+			}
+
+			// Errors are recorded in the metrics; the next tick retries.
+			a.Checkpoint(context.Background(), opts.Mode)
+			last = time.Now()
+		}
+	})
+}
+
+// goBackground runs fn in a goroutine that is stopped and awaited by Close.
+func (a *AgentFS) goBackground(fn func(stop <-chan struct{})) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		fn(a.stop)
+	}()
+}
+
+// stopBackground signals all background workers to stop and waits for them.
+func (a *AgentFS) stopBackground() {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+	})
+	a.wg.Wait()
+}
@@ -0,0 +1,114 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	for i := 0; i < 20; i++ {
+		if err := afs.FS.WriteFile(ctx, fmt.Sprintf("/f%d.txt", i), []byte("data"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	t.Run("truncate empties WAL", func(t *testing.T) {
+		res, err := afs.Checkpoint(ctx, CheckpointTruncate)
+		if err != nil {
+			t.Fatalf("Checkpoint failed: %v", err)
+		}
+		if res.Busy {
+			t.Error("Checkpoint reported busy with no concurrent activity")
+		}
+		if size := afs.WALSize(); size != 0 {
+			t.Errorf("WALSize() = %d after truncate, want 0", size)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		if _, err := afs.Checkpoint(ctx, CheckpointPassive); err != nil {
+			t.Fatalf("Checkpoint failed: %v", err)
+		}
+		m := afs.CheckpointMetrics()
+		if m.Checkpoints < 2 {
+			t.Errorf("Checkpoints = %d, want >= 2", m.Checkpoints)
+		}
+		if m.LastCheckpoint.IsZero() {
+			t.Error("LastCheckpoint not set")
+		}
+	})
+}
+
+func TestCheckpointMode_String(t *testing.T) {
+	tests := map[CheckpointMode]string{
+		CheckpointPassive:  "PASSIVE",
+		CheckpointFull:     "FULL",
+		CheckpointRestart:  "RESTART",
+		CheckpointTruncate: "TRUNCATE",
+	}
+	for mode, want := range tests {
+		if got := mode.String(); got != want {
+			t.Errorf("CheckpointMode(%d).String() = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestAutomaticCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	afs, err := Open(ctx, AgentFSOptions{
+		Path: dbPath,
+		Checkpoint: CheckpointOptions{
+			MaxWALSize:   1,
+			Mode:         CheckpointTruncate,
+			PollInterval: 10 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/big.bin", make([]byte, 64*1024), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for afs.CheckpointMetrics().Checkpoints == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("automatic checkpoint did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseStopsCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:       filepath.Join(t.TempDir(), "test.db"),
+		Checkpoint: CheckpointOptions{Interval: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- afs.Close() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+}
@@ -0,0 +1,97 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+)
+
+// fileChunk is one stored chunk of a file.
+type fileChunk struct {
+	index int64
+	data  []byte
+}
+
+// readChunks returns the stored chunks of ino with index in [start, end],
+// ordered by index. Missing chunks (sparse regions) are omitted.
+func (fs *Filesystem) readChunks(ctx context.Context, ino, start, end int64) ([]fileChunk, error) {
+	if !fs.blobs.inUse() {
+		rows, err := fs.db.QueryContext(ctx, queryChunkRange, ino, start, end)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var chunks []fileChunk
+		for rows.Next() {
+			var c fileChunk
+			if err := rows.Scan(&c.index, &c.data); err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, c)
+		}
+		return chunks, rows.Err()
+	}
+
+	pin := fs.blobs.pinned(ctx, fs.db, ino)
+	rows, err := fs.db.QueryContext(ctx, queryChunkRangeWithExt, ino, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []fileChunk
+	for rows.Next() {
+		var c fileChunk
+		var tier, key sql.NullString
+		if err := rows.Scan(&c.index, &c.data, &tier, &key); err != nil {
+			return nil, err
+		}
+		if key.Valid {
+			if c.data, err = fs.blobs.get(ctx, tier.String, key.String, pin); err != nil {
+				return nil, err
+			}
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// writeChunk stores one chunk of a file whose size after the write is
+// fileSize. Chunks of files at or above a storage tier's MinSize go to that
+// tier's store.
+func (fs *Filesystem) writeChunk(ctx context.Context, ino, index int64, data []byte, fileSize int64) error {
+	if tier := fs.blobs.tierFor(fileSize); tier != nil {
+		key, err := fs.blobs.put(ctx, tier, data)
+		if err != nil {
+			return err
+		}
+		if _, err := fs.db.ExecContext(ctx, extChunkInsert, ino, index, tier.Name, key, len(data)); err != nil {
+			return err
+		}
+		_, err = fs.db.ExecContext(ctx, deleteChunkAt, ino, index)
+		return err
+	}
+
+	if _, err := fs.db.ExecContext(ctx, insertChunk, ino, index, data); err != nil {
+		return err
+	}
+	if fs.blobs.inUse() {
+		if _, err := fs.db.ExecContext(ctx, extChunkDeleteAt, ino, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteChunks removes the chunks of ino with index >= from.
+func (fs *Filesystem) deleteChunks(ctx context.Context, ino, from int64) error {
+	if _, err := fs.db.ExecContext(ctx, deleteChunksFromIndex, ino, from); err != nil {
+		return err
+	}
+	if fs.blobs.inUse() {
+		if _, err := fs.db.ExecContext(ctx, extChunksDeleteFromIndex, ino, from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
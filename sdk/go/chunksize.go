@@ -0,0 +1,158 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ChunkSizeMetaKey is the metadata field holding the chunk size of a file
+// stored in chunks of other than the database's ChunkSize (see
+// SetChunkSize). Being metadata, it follows the file across renames and
+// hard links, is captured by snapshots, and goes away with the file.
+const ChunkSizeMetaKey = "sys:chunk_size"
+
+// chunkSizeOf returns the chunk size of the file ino.
+func (fs *Filesystem) chunkSizeOf(ctx context.Context, ino int64) (int64, error) {
+	var value string
+	err := fs.db.QueryRowContext(ctx, metaGet, ino, ChunkSizeMetaKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return int64(fs.chunkSize), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read chunk size: %w", err)
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return int64(fs.chunkSize), nil
+	}
+	return size, nil
+}
+
+// storeChunkSize records size, which is positive, as the chunk size of the
+// file ino, which must hold no data. The database's chunk size is not
+// recorded.
+func (fs *Filesystem) storeChunkSize(ctx context.Context, p string, ino int64, size int) error {
+	if size > MaxChunkSize {
+		return ErrInval("chunksize", p, fmt.Sprintf("chunk size %d out of range (1 to %d)", size, MaxChunkSize))
+	}
+	if size == fs.chunkSize {
+		if _, err := fs.db.ExecContext(ctx, metaDelete, ino, ChunkSizeMetaKey); err != nil {
+			return fmt.Errorf("failed to set chunk size: %w", err)
+		}
+		return nil
+	}
+	if _, err := fs.db.ExecContext(ctx, metaSet, ino, ChunkSizeMetaKey, strconv.Itoa(size)); err != nil {
+		return fmt.Errorf("failed to set chunk size: %w", err)
+	}
+	return nil
+}
+
+// chooseChunkSize applies AgentFSOptions.ChunkSizeFor to the file ino at p,
+// whose content is being replaced and holds no data.
+func (fs *Filesystem) chooseChunkSize(ctx context.Context, p string, ino int64) error {
+	if fs.chunkSizeFor == nil {
+		return nil
+	}
+	size := fs.chunkSizeFor(p)
+	if size <= 0 {
+		return nil
+	}
+	return fs.storeChunkSize(ctx, p, ino, size)
+}
+
+// FileChunkSize returns the size of the chunks the file at p is stored in.
+func (fs *Filesystem) FileChunkSize(ctx context.Context, p string) (int, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "chunksize", p)
+	if err != nil {
+		return 0, err
+	}
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return 0, err
+	}
+	size, err := fs.chunkSizeOf(ctx, ino)
+	return int(size), err
+}
+
+// SetChunkSize stores the file at p in chunks of size bytes, rewriting its
+// content if it has any; 0 restores the database's ChunkSize. Sizes other
+// than ChunkSize fail with EINVAL unless AgentFSOptions.FileChunkSizes is
+// set, as only this SDK reads them. Small chunks
+// suit files edited in place, as a write rewrites only the chunks it
+// touches; large chunks suit blobs read from start to end, as each chunk
+// is one row to fetch. The size is kept when the content is replaced,
+// unless AgentFSOptions.ChunkSizeFor picks another. The content is
+// rewritten in memory and in one transaction; mtime is left unchanged.
+//
+// Example:
+//
+//	err := afs.FS.SetChunkSize(ctx, "/data/model.bin", 1<<20)
+func (fs *Filesystem) SetChunkSize(ctx context.Context, p string, size int) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "chunksize", p)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable(ctx, "chunksize", p); err != nil {
+		return err
+	}
+	if size == 0 {
+		size = fs.chunkSize
+	}
+	if size < 0 || size > MaxChunkSize {
+		return ErrInval("chunksize", p, fmt.Sprintf("chunk size %d out of range (1 to %d)", size, MaxChunkSize))
+	}
+	if size != fs.chunkSize && !fs.fileChunks {
+		return ErrInval("chunksize", p, "per-file chunk sizes need AgentFSOptions.FileChunkSizes")
+	}
+
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return err
+	}
+	return fs.inTx(ctx, func(tfs *Filesystem) error {
+		stats, err := tfs.statInode(ctx, ino)
+		if err != nil {
+			return err
+		}
+		if !stats.IsRegularFile() {
+			return ErrInval("chunksize", p, "not a regular file")
+		}
+		old, err := tfs.chunkSizeOf(ctx, ino)
+		if err != nil || old == int64(size) {
+			return err
+		}
+
+		chunks, err := tfs.readChunks(ctx, ino, 0, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		data := make([]byte, stats.Size) // Sparse regions become zeros
+		for _, c := range chunks {
+			if off := c.index * old; off < stats.Size {
+				copy(data[off:], c.data)
+			}
+		}
+		if err := tfs.deleteChunks(ctx, ino, 0); err != nil {
+			return err
+		}
+		if err := tfs.storeChunkSize(ctx, p, ino, size); err != nil {
+			return err
+		}
+		return tfs.writeChunks(ctx, ino, data)
+	})
+}
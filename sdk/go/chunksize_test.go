@@ -0,0 +1,136 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkSizePerFile(t *testing.T) {
+	ctx := context.Background()
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:           filepath.Join(t.TempDir(), "chunks.db"),
+		ChunkSize:      16,
+		FileChunkSizes: true,
+		ChunkSizeFor: func(p string) int {
+			if path.Ext(p) == ".bin" {
+				return 64
+			}
+			return 0
+		},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 20)
+	afs.FS.WriteFile(ctx, "/notes.txt", data, 0o644)
+	afs.FS.WriteFile(ctx, "/blob.bin", data, 0o644)
+	for p, want := range map[string]int{"/notes.txt": 16, "/blob.bin": 64} {
+		if got, err := afs.FS.FileChunkSize(ctx, p); err != nil || got != want {
+			t.Errorf("FileChunkSize(%s) = %d, %v; want %d", p, got, err, want)
+		}
+	}
+
+	// Rechunking keeps the content, including writes made after it
+	if err := afs.FS.SetChunkSize(ctx, "/notes.txt", 5); err != nil {
+		t.Fatalf("SetChunkSize failed: %v", err)
+	}
+	f, err := afs.FS.Open(ctx, "/notes.txt", O_RDWR)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := f.Pwrite(ctx, []byte("abc"), 98); err != nil {
+		t.Fatalf("Pwrite failed: %v", err)
+	}
+	if err := f.Truncate(ctx, 150); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	f.Close()
+	want := append(append(append([]byte{}, data[:98]...), "abc"...), data[101:150]...)
+	if got, _ := afs.FS.ReadFile(ctx, "/notes.txt"); !bytes.Equal(got, want) {
+		t.Errorf("ReadFile = %q, want %q", got, want)
+	}
+	r, err := afs.FS.OpenReader(ctx, "/notes.txt")
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	if got, _ := io.ReadAll(r); !bytes.Equal(got, want) {
+		t.Errorf("OpenReader read %q, want %q", got, want)
+	}
+	r.Close()
+
+	// Replacing the content keeps the file's size unless ChunkSizeFor picks one
+	afs.FS.WriteFile(ctx, "/notes.txt", data, 0o644)
+	if got, _ := afs.FS.FileChunkSize(ctx, "/notes.txt"); got != 5 {
+		t.Errorf("FileChunkSize after WriteFile = %d, want 5", got)
+	}
+	if err := afs.FS.SetChunkSize(ctx, "/notes.txt", 0); err != nil {
+		t.Fatalf("SetChunkSize(0) failed: %v", err)
+	}
+	if got, _ := afs.FS.FileChunkSize(ctx, "/notes.txt"); got != 16 {
+		t.Errorf("FileChunkSize after reset = %d, want 16", got)
+	}
+	if err := afs.FS.SetChunkSize(ctx, "/notes.txt", MaxChunkSize+1); err == nil {
+		t.Error("SetChunkSize beyond MaxChunkSize succeeded")
+	}
+	if got, _ := afs.FS.ReadFile(ctx, "/notes.txt"); !bytes.Equal(got, data) {
+		t.Errorf("ReadFile after reset = %q", got)
+	}
+}
+
+func TestChunkSizeVersions(t *testing.T) {
+	ctx := context.Background()
+	afs, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "chunks.db"), FileChunkSizes: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	if err := afs.SetPolicy(ctx, Policy{KeepVersions: 2}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	old := bytes.Repeat([]byte("v1"), 5000)
+	afs.FS.WriteFile(ctx, "/doc.txt", old, 0o644)
+	if err := afs.FS.SetChunkSize(ctx, "/doc.txt", 1000); err != nil {
+		t.Fatalf("SetChunkSize failed: %v", err)
+	}
+	afs.FS.WriteFile(ctx, "/doc.txt", []byte("v2"), 0o644)
+
+	versions, err := afs.FS.History(ctx, "/doc.txt")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("History = %v, %v", versions, err)
+	}
+	got, err := afs.FS.ReadVersion(ctx, "/doc.txt", versions[0].Version)
+	if err != nil || !bytes.Equal(got, old) {
+		t.Errorf("ReadVersion = %d bytes, %v; want the content before", len(got), err)
+	}
+}
+
+func TestChunkSizeOptIn(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/doc.txt", []byte("data"), 0o644)
+	var fsErr *FSError
+	if err := afs.FS.SetChunkSize(ctx, "/doc.txt", 1000); !errors.As(err, &fsErr) || fsErr.Code != EINVAL {
+		t.Errorf("SetChunkSize without FileChunkSizes = %v, want EINVAL", err)
+	}
+	if err := afs.FS.SetChunkSize(ctx, "/doc.txt", 0); err != nil {
+		t.Errorf("SetChunkSize(0) without FileChunkSizes failed: %v", err)
+	}
+
+	_, err := Open(ctx, AgentFSOptions{
+		Path:         filepath.Join(t.TempDir(), "chunks.db"),
+		ChunkSizeFor: func(string) int { return 1 << 20 },
+	})
+	if err == nil {
+		t.Error("Open accepted ChunkSizeFor without FileChunkSizes")
+	}
+}
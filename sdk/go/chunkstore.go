@@ -0,0 +1,226 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ChunkStore stores file chunks outside the main database. Keys are the
+// SHA-256 hex digest of the chunk content, so a key always maps to the same
+// data and stores may skip writes of keys they already hold.
+//
+// Implementations must be safe for concurrent use.
+type ChunkStore interface {
+	// Put stores data under key.
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get returns the data stored under key.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List calls fn for every stored key with its last modification time,
+	// in ascending key order.
+	List(ctx context.Context, fn func(key string, modTime time.Time) error) error
+}
+
+// StorageTier routes the chunks of files of at least MinSize bytes to Store.
+type StorageTier struct {
+	// Name identifies the tier in the database and must stay stable across
+	// opens; chunks are read back from the tier they were written to.
+	Name string
+
+	// MinSize is the smallest file size, in bytes, stored in this tier.
+	// Files below the smallest MinSize stay in SQLite.
+	MinSize int64
+
+	// Store holds the chunk data.
+	Store ChunkStore
+}
+
+// DirStore is a ChunkStore backed by a local directory. Chunks are stored as
+// dir/<first two hex digits>/<key>.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a ChunkStore that keeps chunks under dir.
+// The directory is created on first write.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+func (s *DirStore) path(key string) (string, error) {
+	if len(key) < 2 || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid chunk key %q", key)
+	}
+	return filepath.Join(s.dir, key[:2], key), nil
+}
+
+// Put implements ChunkStore. Existing chunks are not rewritten.
+func (s *DirStore) Put(ctx context.Context, key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial chunk
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	return nil
+}
+
+// Get implements ChunkStore.
+func (s *DirStore) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	return data, nil
+}
+
+// Delete implements ChunkStore.
+func (s *DirStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete chunk: %w", err)
+	}
+	return nil
+}
+
+// List implements ChunkStore.
+func (s *DirStore) List(ctx context.Context, fn func(key string, modTime time.Time) error) error {
+	err := filepath.WalkDir(s.dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Dir(p) == filepath.Clean(s.dir) {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil // temporary file of an in-progress Put
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(d.Name(), info.ModTime())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list chunks: %w", err)
+	}
+	return nil
+}
+
+// SQLStore is a ChunkStore backed by a table in a separate SQLite database,
+// keeping large file data out of the agent database while staying a single
+// file.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a ChunkStore that keeps chunks in db, creating its
+// table if needed.
+func NewSQLStore(ctx context.Context, db *sql.DB) (*SQLStore, error) {
+	if _, err := db.ExecContext(ctx, createChunkStoreTable); err != nil {
+		return nil, fmt.Errorf("failed to create chunk store table: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Put implements ChunkStore.
+func (s *SQLStore) Put(ctx context.Context, key string, data []byte) error {
+	if _, err := s.db.ExecContext(ctx, chunkStorePut, key, data); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	return nil
+}
+
+// Get implements ChunkStore.
+func (s *SQLStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	if err := s.db.QueryRowContext(ctx, chunkStoreGet, key).Scan(&data); err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	return data, nil
+}
+
+// Delete implements ChunkStore.
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, chunkStoreDelete, key); err != nil {
+		return fmt.Errorf("failed to delete chunk: %w", err)
+	}
+	return nil
+}
+
+// List implements ChunkStore.
+func (s *SQLStore) List(ctx context.Context, fn func(key string, modTime time.Time) error) error {
+	rows, err := s.db.QueryContext(ctx, chunkStoreList)
+	if err != nil {
+		return fmt.Errorf("failed to list chunks: %w", err)
+	}
+	type entry struct {
+		key     string
+		created int64
+	}
+	// Collect first so fn may use the store
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.key, &e.created); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to list chunks: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list chunks: %w", err)
+	}
+
+	for _, e := range entries {
+		if err := fn(e.key, time.Unix(e.created, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testChunkStore exercises the ChunkStore contract.
+func testChunkStore(t *testing.T, store ChunkStore) {
+	t.Helper()
+	ctx := context.Background()
+	keys := []string{sha256Hex([]byte("one")), sha256Hex([]byte("two"))}
+
+	if err := store.Put(ctx, keys[0], []byte("one")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := store.Put(ctx, keys[1], []byte("two")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := store.Put(ctx, keys[0], []byte("one")); err != nil {
+		t.Fatalf("repeated Put failed: %v", err)
+	}
+
+	data, err := store.Get(ctx, keys[0])
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(data) != "one" {
+		t.Errorf("Get = %q, want %q", data, "one")
+	}
+
+	var listed []string
+	err = store.List(ctx, func(key string, modTime time.Time) error {
+		if modTime.IsZero() {
+			t.Errorf("key %s has no modification time", key)
+		}
+		listed = append(listed, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	sort.Strings(listed)
+	want := append([]string(nil), keys...)
+	sort.Strings(want)
+	if strings.Join(listed, ",") != strings.Join(want, ",") {
+		t.Errorf("List = %v, want %v", listed, want)
+	}
+
+	if err := store.Delete(ctx, keys[0]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, keys[0]); err != nil {
+		t.Fatalf("repeated Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, keys[0]); err == nil {
+		t.Error("Get after Delete should fail")
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	testChunkStore(t, NewDirStore(t.TempDir()))
+}
+
+func TestSQLStore(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "chunks.db"))
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+
+	store, err := NewSQLStore(context.Background(), db)
+	if err != nil {
+		t.Fatalf("NewSQLStore failed: %v", err)
+	}
+	testChunkStore(t, store)
+}
+
+// fakeS3 is a minimal in-memory S3 server.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	bucket  string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "Signature=") {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if sum := sha256.Sum256(body); r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	key = strings.TrimPrefix(key, "/")
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		type object struct {
+			Key          string
+			LastModified time.Time
+		}
+		var result struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			Contents    []object
+			IsTruncated bool
+		}
+		prefix := r.URL.Query().Get("prefix")
+		for k := range f.objects {
+			if strings.HasPrefix(k, prefix) {
+				result.Contents = append(result.Contents, object{Key: k, LastModified: time.Now()})
+			}
+		}
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte), bucket: "chunks"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	store := NewS3Store(S3Options{
+		Endpoint:        srv.URL,
+		Bucket:          "chunks",
+		Prefix:          "agent/",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	})
+	testChunkStore(t, store)
+
+	for key := range fake.objects {
+		if !strings.HasPrefix(key, "agent/") {
+			t.Errorf("object %q stored without prefix", key)
+		}
+	}
+}
+
+func TestStorageTiers(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	chunkDB, err := sql.Open("sqlite", filepath.Join(dir, "chunks.db"))
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer chunkDB.Close()
+	sqlStore, err := NewSQLStore(ctx, chunkDB)
+	if err != nil {
+		t.Fatalf("NewSQLStore failed: %v", err)
+	}
+
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:      filepath.Join(dir, "test.db"),
+		ChunkSize: 1024,
+		External: ExternalStorageOptions{
+			Tiers: []StorageTier{
+				{Name: "warm", MinSize: 2048, Store: sqlStore},
+				{Name: "cold", MinSize: 8192, Store: NewDirStore(filepath.Join(dir, "cold"))},
+			},
+			CacheSize: 1 << 20,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	files := map[string]int{"/small": 100, "/medium": 4000, "/large": 10000}
+	for p, size := range files {
+		if err := afs.FS.WriteFile(ctx, p, bytes.Repeat([]byte(p[1:2]), size), 0o644); err != nil {
+			t.Fatalf("WriteFile(%s) failed: %v", p, err)
+		}
+	}
+
+	tierOf := func(p string) string {
+		stats, err := afs.FS.Stat(ctx, p)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		var tier sql.NullString
+		afs.DB().QueryRow("SELECT MIN(tier) FROM fs_data_ext WHERE ino = ?", stats.Ino).Scan(&tier)
+		return tier.String
+	}
+	for p, want := range map[string]string{"/small": "", "/medium": "warm", "/large": "cold"} {
+		if got := tierOf(p); got != want {
+			t.Errorf("tier of %s = %q, want %q", p, got, want)
+		}
+	}
+
+	for p, size := range files {
+		data, err := afs.FS.ReadFile(ctx, p)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", p, err)
+		}
+		if !bytes.Equal(data, bytes.Repeat([]byte(p[1:2]), size)) {
+			t.Errorf("ReadFile(%s) returned different content", p)
+		}
+	}
+
+	t.Run("read-through cache", func(t *testing.T) {
+		// The medium file's chunks were cached by the read above
+		if _, err := chunkDB.Exec("DELETE FROM agentfs_chunks"); err != nil {
+			t.Fatalf("DELETE failed: %v", err)
+		}
+		if _, err := afs.FS.ReadFile(ctx, "/medium"); err != nil {
+			t.Errorf("cached ReadFile failed: %v", err)
+		}
+	})
+
+	t.Run("invalid tiers", func(t *testing.T) {
+		_, err := Open(ctx, AgentFSOptions{
+			Path: filepath.Join(dir, "bad.db"),
+			External: ExternalStorageOptions{
+				Tiers: []StorageTier{{Name: "x", MinSize: 1}},
+			},
+		})
+		if err == nil {
+			t.Error("Open with a tier without a store should fail")
+		}
+	})
+}
+
+func TestUnconfiguredTier(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store := NewDirStore(filepath.Join(t.TempDir(), "remote"))
+
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:     dbPath,
+		External: ExternalStorageOptions{Tiers: []StorageTier{{Name: "remote", MinSize: 1, Store: store}}},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/f", []byte("data"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	afs.Close()
+
+	afs, err = Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	if _, err := afs.FS.ReadFile(ctx, "/f"); err == nil || !strings.Contains(err.Error(), `"remote"`) {
+		t.Errorf("ReadFile err = %v, want unconfigured store error", err)
+	}
+}
@@ -0,0 +1,181 @@
+package agentfs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock supplies the current time for timestamps recorded in the database
+// and in events. Inject a fixed clock to make recorded histories
+// reproducible.
+type Clock interface {
+	Now() time.Time
+}
+
+// Timer is a pending call started by TimerClock.AfterFunc. *time.Timer
+// implements it.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// TimerClock is a Clock that can also run a function once its time has
+// advanced by d. Features that wait, such as write coalescing, use it when
+// the configured Clock implements it, and real timers otherwise.
+type TimerClock interface {
+	Clock
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// afterFunc calls f in its own goroutine once d has passed on clock.
+func afterFunc(clock Clock, d time.Duration, f func()) Timer {
+	if tc, ok := clock.(TimerClock); ok {
+		return tc.AfterFunc(d, f)
+	}
+	return time.AfterFunc(d, f)
+}
+
+// IDGenerator supplies identifiers that would otherwise be random, such as
+// the owner ID recorded with in-progress tool calls.
+type IDGenerator interface {
+	NewID() string
+}
+
+// systemClock is the default Clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// randomIDs is the default IDGenerator.
+type randomIDs struct{}
+
+func (randomIDs) NewID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ManualClock is a Clock that only moves when told to. It is a TimerClock:
+// Advance and Set run the functions of the timers that fall due before
+// returning. It is safe for concurrent use.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers map[*manualTimer]bool // Pending timers
+}
+
+// manualTimer is a Timer of a ManualClock.
+type manualTimer struct {
+	c    *ManualClock
+	f    func()
+	when time.Time
+}
+
+// NewManualClock returns a ManualClock set to start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+	c.fire()
+}
+
+// Set moves the clock to t.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+	c.fire()
+}
+
+// AfterFunc calls f once the clock has advanced by d. A d <= 0 calls f at
+// once, in its own goroutine, as time.AfterFunc does.
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &manualTimer{c: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// fire runs the functions of the timers that are due, earliest first.
+func (c *ManualClock) fire() {
+	for {
+		c.mu.Lock()
+		var next *manualTimer
+		for t := range c.timers {
+			if !t.when.After(c.now) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			c.mu.Unlock()
+			return
+		}
+		delete(c.timers, next)
+		c.mu.Unlock()
+		next.f()
+	}
+}
+
+// Stop implements Timer.
+func (t *manualTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	was := t.c.timers[t]
+	delete(t.c.timers, t)
+	return was
+}
+
+// Reset implements Timer.
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	was := t.c.timers[t]
+	delete(t.c.timers, t)
+	t.when = t.c.now.Add(d)
+	if d > 0 {
+		if t.c.timers == nil {
+			t.c.timers = make(map[*manualTimer]bool)
+		}
+		t.c.timers[t] = true
+	}
+	t.c.mu.Unlock()
+	if d <= 0 {
+		go t.f()
+	}
+	return was
+}
+
+// SequentialIDs is an IDGenerator returning prefix-1, prefix-2, and so on.
+type SequentialIDs struct {
+	prefix string
+	n      atomic.Int64
+}
+
+// NewSequentialIDs returns an IDGenerator counting from 1.
+func NewSequentialIDs(prefix string) *SequentialIDs {
+	return &SequentialIDs{prefix: prefix}
+}
+
+// NewID returns the next identifier.
+func (g *SequentialIDs) NewID() string {
+	return fmt.Sprintf("%s-%d", g.prefix, g.n.Add(1))
+}
+
+// now returns the current time of the filesystem's clock.
+func (fs *Filesystem) now() time.Time {
+	return fs.clock.Now()
+}
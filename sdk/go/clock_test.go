@@ -0,0 +1,93 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	c := NewManualClock(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Now() = %v, want %v", c.Now(), start)
+	}
+	c.Advance(time.Minute)
+	if want := start.Add(time.Minute); !c.Now().Equal(want) {
+		t.Errorf("Now() after Advance = %v, want %v", c.Now(), want)
+	}
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Now() after Set = %v, want %v", c.Now(), start)
+	}
+}
+
+func TestSequentialIDs(t *testing.T) {
+	g := NewSequentialIDs("run")
+	for _, want := range []string{"run-1", "run-2", "run-3"} {
+		if got := g.NewID(); got != want {
+			t.Errorf("NewID() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestDeterministicTimestamps(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+	clock := NewManualClock(start)
+
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:        filepath.Join(t.TempDir(), "test.db"),
+		Clock:       clock,
+		IDGenerator: NewSequentialIDs("test"),
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/a.txt", []byte("a"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	stats, err := afs.FS.Stat(ctx, "/a.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if stats.Mtime != start.Unix() {
+		t.Errorf("Mtime = %d, want %d", stats.Mtime, start.Unix())
+	}
+
+	clock.Advance(time.Hour)
+	if err := afs.KV.Set(ctx, "k", "v"); err != nil {
+		t.Fatalf("KV.Set failed: %v", err)
+	}
+	entries, err := afs.KV.List(ctx, "k")
+	if err != nil {
+		t.Fatalf("KV.List failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].UpdatedAt != start.Add(time.Hour).Unix() {
+		t.Errorf("KV entries = %+v, want updated_at %d", entries, start.Add(time.Hour).Unix())
+	}
+
+	pending, err := afs.Tools.Start(ctx, "tool", nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	var owner string
+	if err := afs.DB().QueryRow("SELECT owner FROM tool_calls_pending WHERE id = ?", pending.ID()).Scan(&owner); err != nil {
+		t.Fatalf("query owner failed: %v", err)
+	}
+	if owner != "test-1" {
+		t.Errorf("owner = %q, want %q", owner, "test-1")
+	}
+
+	clock.Advance(2 * time.Second)
+	call, err := pending.Success(ctx, nil)
+	if err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+	if call.StartedAt != start.Add(time.Hour).Unix() || call.DurationMs != 2000 {
+		t.Errorf("call = %+v, want started_at %d and duration 2000ms", call, start.Add(time.Hour).Unix())
+	}
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+// pollInterval is how often the browser looks for new tool calls.
+const pollInterval = time.Second
+
+// maxToolCalls is how many of the most recent tool calls the Tools pane
+// holds.
+const maxToolCalls = 500
+
+// maxPreviewLines is how many lines of a file the preview reads.
+const maxPreviewLines = 1000
+
+// listPercent is the share of the screen width the list takes.
+const listPercent = 40
+
+const keyHelp = "tab/1-3 pane  ↑↓ move  enter/→ open  ←/backspace up  pgup/pgdn scroll  s tool stats  r reload  q quit"
+
+// pane is one of the panes of the browser.
+type pane int
+
+const (
+	filesPane pane = iota
+	toolsPane
+	kvPane
+	numPanes
+)
+
+var paneNames = [numPanes]string{"Files", "Tools", "KV"}
+
+var (
+	activeStyle   = lipgloss.NewStyle().Bold(true).Reverse(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	dimStyle      = lipgloss.NewStyle().Faint(true)
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+// tickMsg asks the browser to look for new tool calls.
+type tickMsg time.Time
+
+func tick() tea.Cmd {
+	return tea.Tick(pollInterval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+// browser is the bubbletea model of the browser: a list per pane, with a
+// preview of the selected item beside it.
+type browser struct {
+	ctx context.Context
+	afs *agentfs.AgentFS
+
+	pane          pane
+	width, height int
+	cursor        [numPanes]int // selected row of each pane
+
+	cwd     string
+	entries []agentfs.DirEntry
+	calls   []agentfs.ToolCall // oldest first, like a log
+	keys    []agentfs.KVEntry
+
+	preview    []string
+	previewOf  string // identifies the selection the preview shows
+	previewTop int    // first line of the preview on screen
+	showStats  bool   // per-tool statistics in place of the selected call
+	err        error  // of the last action, shown in the status line
+}
+
+func newBrowser(ctx context.Context, afs *agentfs.AgentFS) *browser {
+	b := &browser{ctx: ctx, afs: afs, cwd: "/"}
+	b.reload()
+	return b
+}
+
+// Init implements tea.Model.
+func (b *browser) Init() tea.Cmd {
+	return tick()
+}
+
+// Update implements tea.Model.
+func (b *browser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width, b.height = msg.Width, msg.Height
+	case tickMsg:
+		if err := b.loadCalls(); err != nil {
+			b.err = err
+		} else if b.pane == toolsPane {
+			b.updatePreview()
+		}
+		return b, tick()
+	case tea.KeyMsg:
+		return b, b.key(msg.String())
+	}
+	return b, nil
+}
+
+func (b *browser) key(k string) tea.Cmd {
+	b.err = nil
+	switch k {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "tab":
+		b.pane = (b.pane + 1) % numPanes
+	case "shift+tab":
+		b.pane = (b.pane + numPanes - 1) % numPanes
+	case "1", "2", "3":
+		b.pane = pane(k[0] - '1')
+	case "up", "k":
+		b.move(-1)
+	case "down", "j":
+		b.move(1)
+	case "home", "g":
+		b.move(-b.listLen())
+	case "end", "G":
+		b.move(b.listLen())
+	case "enter", "right", "l":
+		b.open()
+	case "backspace", "left", "h":
+		if b.pane == filesPane && b.cwd != "/" {
+			b.chdir(path.Dir(b.cwd), path.Base(b.cwd))
+		}
+	case "pgdown", " ":
+		b.scroll(b.bodyHeight() - 1)
+	case "pgup":
+		b.scroll(1 - b.bodyHeight())
+	case "s":
+		if b.pane == toolsPane {
+			b.showStats = !b.showStats
+		}
+	case "r":
+		b.reload()
+		return nil
+	default:
+		return nil
+	}
+	b.updatePreview()
+	return nil
+}
+
+func (b *browser) listLen() int {
+	switch b.pane {
+	case filesPane:
+		return len(b.entries)
+	case toolsPane:
+		return len(b.calls)
+	default:
+		return len(b.keys)
+	}
+}
+
+func (b *browser) move(d int) {
+	b.cursor[b.pane] = max(0, min(b.cursor[b.pane]+d, b.listLen()-1))
+}
+
+func (b *browser) scroll(d int) {
+	b.previewTop = max(0, min(b.previewTop+d, len(b.preview)-b.bodyHeight()))
+}
+
+// open enters the selected directory of the Files pane.
+func (b *browser) open() {
+	if b.pane != filesPane || len(b.entries) == 0 {
+		return
+	}
+	p := path.Join(b.cwd, b.entries[b.cursor[filesPane]].Name)
+	stats, err := b.afs.FS.Stat(b.ctx, p)
+	if err != nil {
+		b.err = err
+		return
+	}
+	if stats.IsDir() {
+		b.chdir(p, "")
+	}
+}
+
+// chdir lists the directory p, selecting its entry named sel if any.
+func (b *browser) chdir(p, sel string) {
+	entries, err := b.afs.FS.ReaddirPlus(b.ctx, p)
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.cwd, b.entries, b.cursor[filesPane] = p, entries, 0
+	for i, e := range entries {
+		if e.Name == sel {
+			b.cursor[filesPane] = i
+		}
+	}
+}
+
+// reload reads every pane again.
+func (b *browser) reload() {
+	b.err = errors.Join(b.loadEntries(), b.loadCalls(), b.loadKeys())
+	b.updatePreview()
+}
+
+func (b *browser) loadEntries() error {
+	entries, err := b.afs.FS.ReaddirPlus(b.ctx, b.cwd)
+	if err != nil {
+		return err
+	}
+	b.entries = entries
+	b.cursor[filesPane] = max(0, min(b.cursor[filesPane], len(entries)-1))
+	return nil
+}
+
+// loadCalls reads the most recent tool calls. The selection follows new
+// calls while it is on the last one, and otherwise stays on its call.
+func (b *browser) loadCalls() error {
+	calls, err := b.afs.Tools.GetRecent(b.ctx, 0, maxToolCalls)
+	if err != nil {
+		return err
+	}
+	slices.Reverse(calls)
+	cur := b.cursor[toolsPane]
+	follow := cur >= len(b.calls)-1
+	var selected int64
+	if !follow {
+		selected = b.calls[cur].ID
+	}
+	b.calls, b.cursor[toolsPane] = calls, max(0, len(calls)-1)
+	if !follow {
+		b.cursor[toolsPane] = 0
+		for i, c := range calls {
+			if c.ID == selected {
+				b.cursor[toolsPane] = i
+			}
+		}
+	}
+	return nil
+}
+
+func (b *browser) loadKeys() error {
+	keys, err := b.afs.KV.List(b.ctx, "")
+	if err != nil {
+		return err
+	}
+	b.keys = keys
+	b.cursor[kvPane] = max(0, min(b.cursor[kvPane], len(keys)-1))
+	return nil
+}
+
+// updatePreview describes the selection of the current pane, keeping the
+// preview scrolled while it shows the same item.
+func (b *browser) updatePreview() {
+	of, lines, err := b.describe()
+	if err != nil {
+		lines = []string{"error: " + err.Error()}
+	}
+	if of != b.previewOf {
+		b.previewOf, b.previewTop = of, 0
+	}
+	b.preview = lines
+	b.scroll(0)
+}
+
+func (b *browser) describe() (string, []string, error) {
+	cur := b.cursor[b.pane]
+	switch {
+	case b.pane == toolsPane && b.showStats:
+		lines, err := b.toolStats()
+		return "stats", lines, err
+	case b.pane == filesPane && len(b.entries) > 0:
+		p := path.Join(b.cwd, b.entries[cur].Name)
+		lines, err := b.describeFile(p, b.entries[cur].Stats)
+		return "file:" + p, lines, err
+	case b.pane == toolsPane && len(b.calls) > 0:
+		c := b.calls[cur]
+		return fmt.Sprintf("call:%d", c.ID), describeCall(c), nil
+	case b.pane == kvPane && len(b.keys) > 0:
+		e := b.keys[cur]
+		lines, err := b.describeKey(e)
+		return "kv:" + e.Key, lines, err
+	}
+	return "", nil, nil
+}
+
+func (b *browser) describeFile(p string, stats *agentfs.Stats) ([]string, error) {
+	lines := []string{
+		p,
+		fmt.Sprintf("mode %o  size %d  links %d  inode %d", stats.Mode, stats.Size, stats.Nlink, stats.Ino),
+		"modified " + stats.MtimeTime().Format(time.DateTime),
+	}
+	meta, err := b.afs.FS.Meta(b.ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("meta %s = %s", k, meta[k]))
+	}
+	lines = append(lines, "")
+
+	switch {
+	case stats.IsDir():
+		entries, err := b.afs.FS.ReaddirPlus(b.ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			lines = append(lines, entryName(e))
+		}
+		if len(entries) == 0 {
+			lines = append(lines, "(empty directory)")
+		}
+	case stats.IsSymlink():
+		target, err := b.afs.FS.Readlink(b.ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, "-> "+target)
+	default:
+		content, err := b.afs.FS.ReadLines(b.ctx, p, 1, maxPreviewLines+1)
+		var binErr *agentfs.ErrBinary
+		if errors.As(err, &binErr) {
+			return append(lines, "(binary file)"), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		truncated := len(content) > maxPreviewLines
+		if truncated {
+			content = content[:maxPreviewLines]
+		}
+		for i, line := range content {
+			lines = append(lines, fmt.Sprintf("%5d  %s", i+1, line))
+		}
+		if truncated {
+			lines = append(lines, "  ...")
+		}
+	}
+	return lines, nil
+}
+
+func describeCall(c agentfs.ToolCall) []string {
+	status := "ok"
+	if c.Error != nil {
+		status = "error: " + *c.Error
+	}
+	lines := []string{
+		fmt.Sprintf("#%d %s", c.ID, c.Name),
+		"started  " + time.Unix(c.StartedAt, 0).Format(time.DateTime),
+		fmt.Sprintf("duration %dms", c.DurationMs),
+		"status   " + status,
+	}
+	if c.UID != "" {
+		lines = append(lines, "uid      "+c.UID)
+	}
+	if len(c.Parameters) > 0 {
+		lines = append(lines, "", "parameters:")
+		lines = append(lines, jsonLines(c.Parameters)...)
+	}
+	if len(c.Result) > 0 {
+		lines = append(lines, "", "result:")
+		lines = append(lines, jsonLines(c.Result)...)
+	}
+	return lines
+}
+
+func (b *browser) toolStats() ([]string, error) {
+	stats, err := b.afs.Tools.GetStats(b.ctx)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tCALLS\tOK\tFAILED\tAVG MS")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.0f\n", s.Name, s.TotalCalls, s.Successful, s.Failed, s.AvgDurationMs)
+	}
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
+
+func (b *browser) describeKey(e agentfs.KVEntry) ([]string, error) {
+	raw, err := b.afs.KV.GetRaw(b.ctx, e.Key)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{e.Key, "updated " + time.Unix(e.UpdatedAt, 0).Format(time.DateTime)}
+	if e.Type != "" {
+		lines = append(lines, "type    "+e.Type)
+	}
+	if e.ExpiresAt > 0 {
+		lines = append(lines, "expires "+time.UnixMilli(e.ExpiresAt).Format(time.DateTime))
+	}
+	return append(append(lines, ""), jsonLines(raw)...), nil
+}
+
+// jsonLines returns raw indented, one line per element.
+func jsonLines(raw json.RawMessage) []string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "  ", "  "); err != nil {
+		return []string{"  " + string(raw)}
+	}
+	return strings.Split("  "+buf.String(), "\n")
+}
+
+func entryName(e agentfs.DirEntry) string {
+	switch {
+	case e.Stats.IsDir():
+		return e.Name + "/"
+	case e.Stats.IsSymlink():
+		return e.Name + "@"
+	}
+	return e.Name
+}
+
+// listLines returns the rows of the list of the current pane.
+func (b *browser) listLines() []string {
+	var lines []string
+	switch b.pane {
+	case filesPane:
+		for _, e := range b.entries {
+			lines = append(lines, entryName(e))
+		}
+	case toolsPane:
+		for _, c := range b.calls {
+			line := fmt.Sprintf("%s  %s  %dms", time.Unix(c.StartedAt, 0).Format(time.TimeOnly), c.Name, c.DurationMs)
+			if c.Error != nil {
+				line += "  failed"
+			}
+			lines = append(lines, line)
+		}
+	case kvPane:
+		for _, e := range b.keys {
+			lines = append(lines, e.Key)
+		}
+	}
+	return lines
+}
+
+// bodyHeight is the number of rows of the list and preview, between the
+// header and the status line.
+func (b *browser) bodyHeight() int {
+	return max(b.height-2, 1)
+}
+
+// View implements tea.Model.
+func (b *browser) View() string {
+	if b.width == 0 {
+		// Until the size of the terminal is known
+		return ""
+	}
+	height := b.bodyHeight()
+	listWidth := b.width * listPercent / 100
+	previewWidth := b.width - listWidth - 1
+
+	list := b.listLines()
+	cur := b.cursor[b.pane]
+	top := max(0, cur-height+1)
+	var left []string
+	for i := top; i < len(list) && i < top+height; i++ {
+		line := fit(list[i], listWidth)
+		if i == cur {
+			line = selectedStyle.Render(line)
+		}
+		left = append(left, line)
+	}
+	if len(list) == 0 {
+		left = append(left, dimStyle.Render(fit("(empty)", listWidth)))
+	}
+	var right []string
+	for i := b.previewTop; i < len(b.preview) && i < b.previewTop+height; i++ {
+		right = append(right, fit(b.preview[i], previewWidth))
+	}
+
+	column := func(lines []string, width int) string {
+		return lipgloss.NewStyle().Width(width).Height(height).Render(strings.Join(lines, "\n"))
+	}
+	separator := dimStyle.Render(strings.TrimSuffix(strings.Repeat("│\n", height), "\n"))
+	body := lipgloss.JoinHorizontal(lipgloss.Top, column(left, listWidth), separator, column(right, previewWidth))
+	return lipgloss.JoinVertical(lipgloss.Left, b.header(), body, b.statusLine())
+}
+
+func (b *browser) header() string {
+	var tabs []string
+	for i, name := range paneNames {
+		tab := fmt.Sprintf(" %d %s ", i+1, name)
+		if pane(i) == b.pane {
+			tab = activeStyle.Render(tab)
+		}
+		tabs = append(tabs, tab)
+	}
+	var where string
+	switch b.pane {
+	case filesPane:
+		where = b.cwd
+	case toolsPane:
+		where = fmt.Sprintf("%d recent calls", len(b.calls))
+		if b.cursor[toolsPane] >= len(b.calls)-1 {
+			where += ", following"
+		}
+	case kvPane:
+		where = fmt.Sprintf("%d entries", len(b.keys))
+	}
+	return ansi.Truncate(strings.Join(tabs, "")+"  "+where, b.width, "…")
+}
+
+func (b *browser) statusLine() string {
+	if b.err != nil {
+		return errorStyle.Render(fit("error: "+b.err.Error(), b.width))
+	}
+	return dimStyle.Render(fit(keyHelp, b.width))
+}
+
+// fit cuts s to width cells and pads it to them. Tabs are expanded and
+// other control characters replaced, so file content cannot move the
+// cursor or restyle the screen.
+func fit(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return '?'
+		}
+		return r
+	}, s)
+	s = ansi.Truncate(s, width, "…")
+	return s + strings.Repeat(" ", max(0, width-ansi.StringWidth(s)))
+}
@@ -0,0 +1,55 @@
+// Command agentfs-browse is a terminal UI for inspecting AgentFS
+// databases, the fastest way to answer "what is this agent doing?". It
+// has three panes, each a list with a preview of the selected item:
+//
+//   - Files navigates the filesystem tree and previews files, directories,
+//     and symlinks.
+//   - Tools tails the tool calls as they are recorded, showing the
+//     parameters, result, and error of the selected call.
+//   - KV lists the KV entries and shows their values.
+//
+// The database is opened read-only, so inspecting a live agent never
+// changes its state.
+//
+// Usage:
+//
+//	agentfs-browse --id my-agent
+//	agentfs-browse --path ./agent.db
+//
+// The keys are listed at the bottom of the screen.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func main() {
+	id := flag.String("id", "", "agent ID (opens ~/.agentfs/<id>.db)")
+	dbPath := flag.String("path", "", "database path (takes precedence over --id)")
+	flag.Parse()
+
+	ctx := context.Background()
+	afs, err := open(ctx, *id, *dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "agentfs-browse:", err)
+		os.Exit(1)
+	}
+	defer afs.Close()
+
+	if _, err := tea.NewProgram(newBrowser(ctx, afs), tea.WithAltScreen()).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "agentfs-browse:", err)
+		os.Exit(1)
+	}
+}
+
+// open opens the database to inspect without writing to it.
+func open(ctx context.Context, id, dbPath string) (*agentfs.AgentFS, error) {
+	return agentfs.Open(ctx, agentfs.AgentFSOptions{ID: id, Path: dbPath, ReadOnly: true})
+}
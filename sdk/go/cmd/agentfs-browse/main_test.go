@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+)
+
+func TestBrowser(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	afs, err := agentfs.Open(ctx, agentfs.AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/work/notes.md", []byte("first\n\x1b[2Jsecond\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.KV.Set(ctx, "phase", map[string]string{"step": "planning"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := afs.Tools.Record(ctx, "web_search", map[string]string{"query": "agentfs"}, "ok", nil, 1, 2); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	ro, err := open(ctx, "", dbPath)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer ro.Close()
+
+	b := newBrowser(ctx, ro)
+	press := func(keys ...tea.KeyMsg) string {
+		t.Helper()
+		for _, k := range keys {
+			b.Update(k)
+		}
+		return b.View()
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	expect := func(view string, want ...string) {
+		t.Helper()
+		for _, w := range want {
+			if !strings.Contains(view, w) {
+				t.Errorf("view missing %q:\n%s", w, view)
+			}
+		}
+	}
+	b.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+
+	// Files: the tree and a preview of the selection
+	expect(press(), "1 Files", "work/", "notes.md")
+	view := press(tea.KeyMsg{Type: tea.KeyEnter})
+	expect(view, "/work", "/work/notes.md", "    1  first", "    2  ?[2Jsecond")
+	if strings.Contains(view, "\x1b[2J") {
+		t.Error("view passes control sequences of the file through")
+	}
+	expect(press(tea.KeyMsg{Type: tea.KeyBackspace}), "work/", "/work", "notes.md")
+
+	// Tools: the tail of the calls, with the selected one in full
+	expect(press(runes("2")), "1 recent calls, following", "web_search", `"query": "agentfs"`)
+	if _, err := afs.Tools.Record(ctx, "read_file", nil, "done", nil, 3, 4); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	_, cmd := b.Update(tickMsg{})
+	if cmd == nil {
+		t.Error("tick did not schedule the next one")
+	}
+	expect(b.View(), "2 recent calls, following", "#2 read_file", `"done"`)
+	expect(press(runes("s")), "TOOL", "web_search")
+
+	// KV: the keys and their values
+	expect(press(runes("3")), "1 entries", "phase", `"step": "planning"`)
+
+	if _, cmd := b.Update(runes("q")); cmd == nil || cmd() != tea.Quit() {
+		t.Error("q did not quit")
+	}
+	if err := ro.FS.WriteFile(ctx, "/x", nil, 0o644); err == nil {
+		t.Error("WriteFile through the browser's database succeeded, want read-only")
+	}
+}
@@ -0,0 +1,57 @@
+// Command agentfs-mcp serves an AgentFS database as a Model Context
+// Protocol server on stdin and stdout, so LLM clients like Claude Desktop
+// can read and write the agent workspace.
+//
+// Usage:
+//
+//	agentfs-mcp --id my-agent
+//	agentfs-mcp --path ./agent.db --read-only
+//	agentfs-mcp --id my-agent --tools read_file,readdir,kv_get
+//
+// Diagnostics go to stderr; stdout carries only protocol messages.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+	"github.com/tursodatabase/agentfs/sdk/go/agentfsmcp"
+)
+
+func main() {
+	id := flag.String("id", "", "agent ID (opens ~/.agentfs/<id>.db)")
+	dbPath := flag.String("path", "", "database path (takes precedence over --id)")
+	readOnly := flag.Bool("read-only", false, "open the database read-only and expose only tools that do not modify it")
+	toolList := flag.String("tools", "", "comma-separated tools to expose (default: all)")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, *id, *dbPath, *readOnly, *toolList); err != nil {
+		fmt.Fprintln(os.Stderr, "agentfs-mcp:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, id, dbPath string, readOnly bool, toolList string) error {
+	afs, err := agentfs.Open(ctx, agentfs.AgentFSOptions{ID: id, Path: dbPath, ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	defer afs.Close()
+
+	opts := agentfsmcp.Options{ReadOnly: readOnly, Name: "agentfs-mcp"}
+	if toolList != "" {
+		opts.Tools = strings.Split(toolList, ",")
+	}
+	srv, err := agentfsmcp.NewServer(afs, opts)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ctx, os.Stdin, os.Stdout)
+}
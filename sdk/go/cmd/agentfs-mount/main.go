@@ -0,0 +1,83 @@
+// Command agentfs-mount mounts an AgentFS database with FUSE, so ordinary
+// tools (grep, editors, shells) can work on agent files directly. It runs
+// in the foreground and unmounts on interrupt, or exits once the mount is
+// unmounted with fusermount -u (umount on macOS).
+//
+// Usage:
+//
+//	agentfs-mount --id my-agent /mnt/agent
+//	agentfs-mount --path ./agent.db --read-only /mnt/agent
+//
+// Linux needs /dev/fuse and fusermount; macOS needs macFUSE.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+	"github.com/tursodatabase/agentfs/sdk/go/agentfsmount"
+)
+
+func main() {
+	id := flag.String("id", "", "agent ID (opens ~/.agentfs/<id>.db)")
+	dbPath := flag.String("path", "", "database path (takes precedence over --id)")
+	readOnly := flag.Bool("read-only", false, "open the database read-only and mount it read-only")
+	allowOther := flag.Bool("allow-other", false, "let other users access the mount")
+	timeout := flag.Duration("timeout", agentfsmount.DefaultTimeout, "how long the kernel caches attributes and names")
+	debug := flag.Bool("debug", false, "log every FUSE request")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: agentfs-mount [flags] <dir>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := &agentfsmount.Options{ReadOnly: *readOnly, AllowOther: *allowOther, Timeout: *timeout, Debug: *debug}
+	if err := run(*id, *dbPath, flag.Arg(0), opts); err != nil {
+		fmt.Fprintln(os.Stderr, "agentfs-mount:", err)
+		os.Exit(1)
+	}
+}
+
+func run(id, dbPath, dir string, opts *agentfsmount.Options) error {
+	afs, err := agentfs.Open(context.Background(), agentfs.AgentFSOptions{ID: id, Path: dbPath, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer afs.Close()
+
+	srv, err := agentfsmount.Mount(afs, dir, opts)
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %w", dir, err)
+	}
+	fmt.Fprintf(os.Stderr, "agentfs-mount: mounted at %s\n", dir)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	unmounted := make(chan struct{})
+	go func() {
+		srv.Wait()
+		close(unmounted)
+	}()
+	for {
+		select {
+		case <-unmounted:
+			return nil
+		case <-signals:
+			// Files still open in the mount keep it busy; a later
+			// interrupt tries again
+			if err := srv.Unmount(); err != nil {
+				fmt.Fprintf(os.Stderr, "agentfs-mount: failed to unmount %s: %v\n", dir, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Command agentfs-sftp-server serves an AgentFS database over SFTP on
+// stdin and stdout, in place of OpenSSH's sftp-server, so operators can
+// browse and fetch agent artifacts with sftp and scp from remote machines.
+// sshd does the authentication and encryption; configure it as the sftp
+// subsystem in sshd_config:
+//
+//	Subsystem sftp /usr/local/bin/agentfs-sftp-server --id my-agent
+//
+// Usage:
+//
+//	agentfs-sftp-server --id my-agent
+//	agentfs-sftp-server --path /var/lib/agents/agent.db --read-only
+//
+// Diagnostics go to stderr; stdout carries only protocol messages.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+	"github.com/tursodatabase/agentfs/sdk/go/agentfssftp"
+)
+
+func main() {
+	id := flag.String("id", "", "agent ID (opens ~/.agentfs/<id>.db)")
+	dbPath := flag.String("path", "", "database path (takes precedence over --id)")
+	readOnly := flag.Bool("read-only", false, "open the database read-only and refuse changes")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, *id, *dbPath, *readOnly); err != nil {
+		fmt.Fprintln(os.Stderr, "agentfs-sftp-server:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, id, dbPath string, readOnly bool) error {
+	afs, err := agentfs.Open(ctx, agentfs.AgentFSOptions{ID: id, Path: dbPath, ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	defer afs.Close()
+
+	srv := agentfssftp.NewServer(afs, agentfssftp.Options{ReadOnly: readOnly})
+	return srv.Serve(ctx, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,62 @@
+// Command agentfs-webdav serves an AgentFS database over WebDAV, so the
+// agent workspace can be mounted on macOS, Windows, or Linux without FUSE.
+//
+// Usage:
+//
+//	agentfs-webdav --id my-agent
+//	agentfs-webdav --path ./agent.db --addr 127.0.0.1:9000 --read-only
+//
+// Then mount http://127.0.0.1:8080/ with Finder (Go > Connect to Server),
+// Windows Explorer (Map Network Drive), or davfs2. The server does no
+// authentication, so it listens on loopback by default.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	agentfs "github.com/tursodatabase/agentfs/sdk/go"
+	"github.com/tursodatabase/agentfs/sdk/go/agentfshttp"
+)
+
+func main() {
+	id := flag.String("id", "", "agent ID (opens ~/.agentfs/<id>.db)")
+	dbPath := flag.String("path", "", "database path (takes precedence over --id)")
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	readOnly := flag.Bool("read-only", false, "open the database read-only and refuse changes")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, *id, *dbPath, *addr, *readOnly); err != nil {
+		fmt.Fprintln(os.Stderr, "agentfs-webdav:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, id, dbPath, addr string, readOnly bool) error {
+	afs, err := agentfs.Open(ctx, agentfs.AgentFSOptions{ID: id, Path: dbPath, ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	defer afs.Close()
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: agentfshttp.WebDAVHandler(afs, &agentfshttp.WebDAVOptions{ReadOnly: readOnly}),
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(os.Stderr, "agentfs-webdav: serving on http://%s/\n", addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalescer holds the latest content of files rewritten in quick
+// succession until they go quiet (see CoalesceOptions).
+type coalescer struct {
+	fs   *Filesystem
+	opts CoalesceOptions
+
+	flushMu sync.Mutex // held while writing to the database, so writes of a file stay in order
+	mu      sync.Mutex
+	files   map[string]*heldWrite
+	closed  bool
+}
+
+// heldWrite is a file written recently. Until its timer fires, further
+// writes of it replace data instead of reaching the database.
+type heldWrite struct {
+	data  []byte
+	mode  int64
+	dirty bool      // data has not been written yet
+	since time.Time // when data became dirty
+	timer Timer
+}
+
+func newCoalescer(fs *Filesystem, opts CoalesceOptions) *coalescer {
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * opts.Interval
+	}
+	return &coalescer{fs: fs, opts: opts, files: make(map[string]*heldWrite)}
+}
+
+// direct returns the filesystem without coalescing, to write through.
+func (c *coalescer) direct() *Filesystem {
+	fs := *c.fs
+	fs.coalesce = nil
+	return &fs
+}
+
+// write is WriteFile of the cleaned path p: it writes a file not written
+// recently and holds the content of one that was.
+func (c *coalescer) write(ctx context.Context, p string, data []byte, mode int64) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return c.direct().WriteFile(ctx, p, data, mode)
+	}
+	if h := c.files[p]; h != nil {
+		now := c.fs.now()
+		if !h.dirty {
+			h.dirty, h.since = true, now
+		}
+		h.data, h.mode = append([]byte(nil), data...), mode
+		h.timer.Reset(max(min(c.opts.Interval, h.since.Add(c.opts.MaxDelay).Sub(now)), 0))
+		c.mu.Unlock()
+		return nil
+	}
+	h := &heldWrite{}
+	h.timer = afterFunc(c.fs.clock, c.opts.Interval, func() { c.expire(p, h) })
+	c.files[p] = h
+	c.mu.Unlock()
+
+	if err := c.direct().WriteFile(ctx, p, data, mode); err != nil {
+		c.mu.Lock()
+		h.timer.Stop()
+		delete(c.files, p)
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// held returns the content held for p, if any.
+func (c *coalescer) held(p string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h := c.files[p]; h != nil && h.dirty {
+		return append([]byte(nil), h.data...), true
+	}
+	return nil, false
+}
+
+// expire runs when the file p has been quiet for the interval, or held
+// for MaxDelay: it writes the held content, if any, and otherwise stops
+// tracking the file.
+func (c *coalescer) expire(p string, h *heldWrite) {
+	c.flushMu.Lock()
+	c.mu.Lock()
+	if c.files[p] != h {
+		c.mu.Unlock()
+		c.flushMu.Unlock()
+		return
+	}
+	if !h.dirty {
+		delete(c.files, p)
+		c.mu.Unlock()
+		c.flushMu.Unlock()
+		return
+	}
+	data, mode := h.data, h.mode
+	h.timer.Reset(c.opts.Interval)
+	c.mu.Unlock()
+
+	// Reads keep getting the held content until it is in the database
+	err := c.direct().WriteFile(context.Background(), p, data, mode)
+	c.mu.Lock()
+	h.data, h.dirty = nil, false
+	c.mu.Unlock()
+	c.flushMu.Unlock()
+	if err != nil && c.opts.OnError != nil {
+		c.opts.OnError(p, err)
+	}
+}
+
+// sync writes the held content of the files under p ("" for all) and
+// returns the errors.
+func (c *coalescer) sync(ctx context.Context, p string) error {
+	if c == nil {
+		return nil
+	}
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	type write struct {
+		path string
+		data []byte
+		mode int64
+		held *heldWrite
+	}
+	var writes []write
+	c.mu.Lock()
+	for fp, h := range c.files {
+		if h.dirty && (p == "" || p == "/" || fp == p || strings.HasPrefix(fp, p+"/")) {
+			writes = append(writes, write{fp, h.data, h.mode, h})
+		}
+	}
+	c.mu.Unlock()
+
+	// As in expire, the content stays held until it is written
+	var errs []error
+	for _, w := range writes {
+		if err := c.direct().WriteFile(ctx, w.path, w.data, w.mode); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %s: %w", w.path, err))
+		}
+		c.mu.Lock()
+		w.held.data, w.held.dirty = nil, false
+		c.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// syncPath writes the held content of the files under p before another
+// operation on p, reporting failures to OnError.
+func (c *coalescer) syncPath(p string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	n := len(c.files)
+	c.mu.Unlock()
+	if n == 0 {
+		return
+	}
+	if err := c.sync(context.Background(), p); err != nil && c.opts.OnError != nil {
+		c.opts.OnError(p, err)
+	}
+}
+
+// discard drops the held content and stops tracking files, before an
+// operation that replaces every file.
+func (c *coalescer) discard() {
+	if c == nil {
+		return
+	}
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p, h := range c.files {
+		h.timer.Stop()
+		delete(c.files, p)
+	}
+}
+
+// close writes all held content and makes later writes go through.
+func (c *coalescer) close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	c.closed = true
+	for _, h := range c.files {
+		h.timer.Stop()
+	}
+	c.mu.Unlock()
+	return c.sync(context.Background(), "")
+}
+
+// Sync writes the content held for the file at p, or for the files below
+// the directory at p, by write coalescing (see CoalesceOptions). It does
+// nothing if coalescing is disabled or nothing is held.
+//
+// Example:
+//
+//	afs.FS.WriteFile(ctx, "/draft.md", draft, 0o644)
+//	if err := afs.FS.Sync(ctx, "/draft.md"); err != nil {
+//	    return err
+//	}
+func (fs *Filesystem) Sync(ctx context.Context, p string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "sync", p)
+	if err != nil {
+		return err
+	}
+	return fs.coalesce.sync(ctx, p)
+}
+
+// SyncAll writes all content held by write coalescing (see
+// CoalesceOptions).
+func (fs *Filesystem) SyncAll(ctx context.Context) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return fs.coalesce.sync(ctx, "")
+}
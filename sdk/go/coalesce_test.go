@@ -0,0 +1,149 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoalesceWrites(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "coalesce.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: dbPath, Coalesce: CoalesceOptions{Interval: time.Hour}})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	other, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer other.Close()
+
+	persisted := func(p string) string {
+		t.Helper()
+		data, err := other.FS.ReadFile(ctx, p)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", p, err)
+		}
+		return string(data)
+	}
+
+	// The first write goes through; later ones are held but readable
+	for _, s := range []string{"v1", "v2", "v3"} {
+		if err := afs.FS.WriteFile(ctx, "/draft.md", []byte(s), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	if got := persisted("/draft.md"); got != "v1" {
+		t.Errorf("persisted = %q, want v1", got)
+	}
+	if got, _ := afs.FS.ReadFile(ctx, "/draft.md"); string(got) != "v3" {
+		t.Errorf("ReadFile = %q, want v3", got)
+	}
+
+	if err := afs.FS.Sync(ctx, "/draft.md"); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if got := persisted("/draft.md"); got != "v3" {
+		t.Errorf("persisted after Sync = %q, want v3", got)
+	}
+
+	// Other operations on the file write it first
+	afs.FS.WriteFile(ctx, "/draft.md", []byte("v4 longer"), 0o644)
+	if stats, err := afs.FS.Stat(ctx, "/draft.md"); err != nil || stats.Size != 9 {
+		t.Errorf("Stat = %+v, %v; want size 9", stats, err)
+	}
+	afs.FS.WriteFile(ctx, "/draft.md", []byte("v5"), 0o644)
+	if err := afs.FS.Rename(ctx, "/draft.md", "/final.md"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if got := persisted("/final.md"); got != "v5" {
+		t.Errorf("persisted after Rename = %q, want v5", got)
+	}
+
+	// Close writes what is still held
+	afs.FS.WriteFile(ctx, "/final.md", []byte("v6"), 0o644)
+	afs.FS.WriteFile(ctx, "/final.md", []byte("v7"), 0o644)
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := persisted("/final.md"); got != "v7" {
+		t.Errorf("persisted after Close = %q, want v7", got)
+	}
+}
+
+func TestCoalesceInterval(t *testing.T) {
+	ctx := context.Background()
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:     filepath.Join(t.TempDir(), "coalesce.db"),
+		Coalesce: CoalesceOptions{Interval: 20 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/a.txt", []byte("1"), 0o644)
+	afs.FS.WriteFile(ctx, "/a.txt", []byte("2"), 0o644)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, held := afs.FS.coalesce.held("/a.txt"); !held {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("held content was not written after the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, _ := afs.FS.ReadFile(ctx, "/a.txt"); string(got) != "2" {
+		t.Errorf("ReadFile = %q, want 2", got)
+	}
+}
+
+func TestCoalesceManualClock(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:     filepath.Join(t.TempDir(), "coalesce.db"),
+		Clock:    clock,
+		Coalesce: CoalesceOptions{Interval: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	fs := afs.FS
+
+	stored := func() string {
+		t.Helper()
+		var data []byte
+		afs.DB().QueryRowContext(ctx, "SELECT data FROM fs_data WHERE ino = (SELECT ino FROM fs_dentry WHERE name = 'f.txt')").Scan(&data)
+		return string(data)
+	}
+
+	fs.WriteFile(ctx, "/f.txt", []byte("v1"), 0o644)
+	fs.WriteFile(ctx, "/f.txt", []byte("v2"), 0o644)
+	if got := stored(); got != "v1" {
+		t.Fatalf("stored = %q, want v2 held", got)
+	}
+	clock.Advance(time.Second)
+	if got := stored(); got != "v2" {
+		t.Errorf("stored after the interval = %q, want v2", got)
+	}
+
+	// Snapshot sees held content; Restore drops it
+	fs.WriteFile(ctx, "/f.txt", []byte("v3"), 0o644)
+	if err := afs.Snapshot(ctx, "s"); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	fs.WriteFile(ctx, "/f.txt", []byte("v4"), 0o644)
+	if err := afs.Restore(ctx, "s"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if data, err := fs.ReadFile(ctx, "/f.txt"); err != nil || string(data) != "v3" {
+		t.Errorf("ReadFile after Restore = %q, %v; want v3", data, err)
+	}
+}
@@ -0,0 +1,272 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Features recorded in databases opened by this SDK (see Capabilities).
+// Each names tables or columns that SDKs without the feature do not
+// maintain.
+const (
+	FeatureKVNamespaces    = "kv_namespaces"
+	FeatureKVTTL           = "kv_ttl"
+	FeatureKVTypes         = "kv_types"
+	FeatureKVWatch         = "kv_watch"
+	FeatureFSWatch         = "fs_watch"
+	FeatureStreams         = "streams"
+	FeatureSinks           = "sinks"
+	FeatureMailbox         = "mailbox"
+	FeatureSnapshots       = "snapshots"
+	FeatureFileVersions    = "file_versions"
+	FeatureExternalStorage = "external_storage"
+	FeatureToolCallArchive = "tool_call_archive"
+	FeatureRecordIDs       = "record_ids"
+)
+
+// knownFeatures lists the features this SDK records and understands.
+var knownFeatures = []string{
+	FeatureKVNamespaces,
+	FeatureKVTTL,
+	FeatureKVTypes,
+	FeatureKVWatch,
+	FeatureFSWatch,
+	FeatureStreams,
+	FeatureSinks,
+	FeatureMailbox,
+	FeatureSnapshots,
+	FeatureFileVersions,
+	FeatureExternalStorage,
+	FeatureToolCallArchive,
+	FeatureRecordIDs,
+}
+
+// featureKeyPrefix marks the fs_config rows recording features.
+const featureKeyPrefix = "feature:"
+
+// Capabilities describes what a database supports and how it was opened,
+// so tools and mixed-version fleets can adapt instead of failing.
+type Capabilities struct {
+	// SchemaVersion is the version recorded in the database.
+	SchemaVersion string `json:"schema_version"`
+	// SDKSchemaVersion is the newest version this SDK writes.
+	SDKSchemaVersion string `json:"sdk_schema_version"`
+	// ReadOnly is set if the database was opened read-only, either by
+	// AgentFSOptions.ReadOnly or because a newer SDK wrote it.
+	ReadOnly bool `json:"read_only"`
+	// Features lists the features recorded in the database, sorted.
+	Features []string `json:"features"`
+	// UnknownFeatures lists recorded features this SDK does not know,
+	// written by a newer SDK.
+	UnknownFeatures []string `json:"unknown_features,omitempty"`
+
+	// Versioning is set if earlier file contents are kept (see
+	// Policy.KeepVersions).
+	Versioning bool `json:"versioning"`
+	// Dedup is set if large file chunks are stored content-addressed, once
+	// per distinct content (see AgentFSOptions.External). Snapshot and
+	// version chunks are always deduplicated.
+	Dedup bool `json:"dedup"`
+	// FTS is set if the SQLite engine provides FTS5 full-text indexes.
+	FTS bool `json:"fts"`
+	// Vectors is set if the engine provides libSQL vector functions.
+	Vectors bool `json:"vectors"`
+	// Encryption is set if the database is encrypted at rest by the engine
+	// (SQLCipher or libSQL encryption).
+	Encryption bool `json:"encryption"`
+}
+
+// Has reports whether feature is recorded in the database.
+func (c *Capabilities) Has(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities reports the schema version and features of the database,
+// and which optional features are enabled, so generic tools can adapt
+// instead of finding out from errors.
+//
+// Example:
+//
+//	caps, err := afs.Capabilities(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	if caps.ReadOnly {
+//	    log.Printf("database written by a newer SDK (schema %s); read-only", caps.SchemaVersion)
+//	}
+func (a *AgentFS) Capabilities(ctx context.Context) (*Capabilities, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	c := &Capabilities{SDKSchemaVersion: schemaVersion, ReadOnly: a.readOnly, Features: []string{}}
+	if c.SchemaVersion, err = storedSchemaVersion(ctx, a.db); err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.QueryContext(ctx, featureList, escapePattern(featureKeyPrefix)+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read features: %w", err)
+	}
+	defer rows.Close()
+	known := make(map[string]bool, len(knownFeatures))
+	for _, f := range knownFeatures {
+		known[f] = true
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to read features: %w", err)
+		}
+		f := strings.TrimPrefix(key, featureKeyPrefix)
+		c.Features = append(c.Features, f)
+		if !known[f] {
+			c.UnknownFeatures = append(c.UnknownFeatures, f)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read features: %w", err)
+	}
+	sort.Strings(c.Features)
+
+	p, err := a.policy.get(ctx, a.db)
+	if err != nil {
+		return nil, err
+	}
+	c.Versioning = p.KeepVersions > 0
+	c.Dedup = a.FS.blobs != nil
+	c.FTS = hasCompileOption(ctx, a.db, "ENABLE_FTS5")
+	c.Vectors = probeSQL(ctx, a.db, "SELECT vector32('[1]')")
+	c.Encryption = probeSQL(ctx, a.db, "PRAGMA cipher_version") || probeSQL(ctx, a.db, "PRAGMA cipher")
+	return c, nil
+}
+
+// hasCompileOption reports whether SQLite was built with option.
+func hasCompileOption(ctx context.Context, db *sql.DB, option string) bool {
+	rows, err := db.QueryContext(ctx, "PRAGMA compile_options")
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var opt string
+		if rows.Scan(&opt) == nil && opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// probeSQL reports whether query runs and returns a non-empty first
+// column. Engines without the feature fail or return nothing.
+func probeSQL(ctx context.Context, db *sql.DB, query string) bool {
+	var v sql.NullString
+	if err := db.QueryRowContext(ctx, query).Scan(&v); err != nil {
+		return false
+	}
+	return v.String != ""
+}
+
+// recordFeatures notes the features of this SDK in the database.
+func recordFeatures(ctx context.Context, db *sql.DB) error {
+	for _, f := range knownFeatures {
+		if _, err := db.ExecContext(ctx, featureRecord, featureKeyPrefix+f); err != nil {
+			return fmt.Errorf("failed to record features: %w", err)
+		}
+	}
+	return nil
+}
+
+// storedSchemaVersion returns the schema version recorded in db, or "" if
+// db has no AgentFS schema yet.
+func storedSchemaVersion(ctx context.Context, db *sql.DB) (string, error) {
+	// Asked by table name, sqlite_master would need the prefix of a
+	// prefixed database spelled out, so the query itself tells
+	var version string
+	err := db.QueryRowContext(ctx, getSchemaVersion).Scan(&version)
+	if err == sql.ErrNoRows || isMissingTable(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema_version: %w", err)
+	}
+	return version, nil
+}
+
+// newerSchema reports whether the database at p exists and was written by
+// an SDK with a newer schema version. It does not write to the database.
+func newerSchema(ctx context.Context, p, prefix string) (bool, error) {
+	if _, err := os.Stat(p); err != nil {
+		return false, nil // Created by this SDK
+	}
+	db, err := sql.Open("sqlite", "file:"+p+"?mode=ro")
+	if err != nil {
+		return false, fmt.Errorf("failed to open database: %w", err)
+	}
+	if prefix != "" {
+		pdb, err := withTablePrefix(db, prefix, true)
+		if err != nil {
+			db.Close()
+			return false, err
+		}
+		db = pdb
+	}
+	defer db.Close()
+	version, err := storedSchemaVersion(ctx, db)
+	if err != nil {
+		return false, nil // Reported, or verified, by the open proper
+	}
+	return version != "" && compareSchemaVersions(version, schemaVersion) > 0, nil
+}
+
+// openReadOnly sets up an AgentFS over db without initializing the schema.
+// The database must already hold a schema at least as new as this SDK's.
+func openReadOnly(ctx context.Context, db *sql.DB, dbPath string, ownsDB bool, opts AgentFSOptions, version string) (*AgentFS, error) {
+	if version == "" {
+		return nil, fmt.Errorf("failed to open read-only: %s is not an AgentFS database", dbPath)
+	}
+	if compareSchemaVersions(version, schemaVersion) < 0 {
+		return nil, &ErrSchemaVersionMismatch{Found: version, Expected: schemaVersion}
+	}
+	return newAgentFS(ctx, db, dbPath, ownsDB, opts)
+}
+
+// compareSchemaVersions compares dotted version numbers such as "0.4",
+// returning -1, 0, or 1.
+func compareSchemaVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// isMissingTable reports whether err is SQLite's error for a query on a
+// table that does not exist.
+func isMissingTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such table")
+}
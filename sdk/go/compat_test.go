@@ -0,0 +1,95 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewerSchemaOpensReadOnly(t *testing.T) {
+	ctx := context.Background()
+	p := filepath.Join(t.TempDir(), "test.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: p})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	afs.FS.WriteFile(ctx, "/notes.md", []byte("hello"), 0o644)
+	caps, err := afs.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+	if caps.ReadOnly || caps.SchemaVersion != schemaVersion || !caps.Has(FeatureKVTTL) || len(caps.UnknownFeatures) != 0 {
+		t.Errorf("Capabilities = %+v", caps)
+	}
+	// Pretend a newer SDK wrote the database
+	afs.db.ExecContext(ctx, "UPDATE fs_config SET value = '99.0' WHERE key = 'schema_version'")
+	afs.db.ExecContext(ctx, "INSERT INTO fs_config (key, value) VALUES ('feature:holograms', '1')")
+	afs.Close()
+
+	afs, err = Open(ctx, AgentFSOptions{Path: p})
+	if err != nil {
+		t.Fatalf("Open of a newer database failed: %v", err)
+	}
+	defer afs.Close()
+	if data, err := afs.FS.ReadFile(ctx, "/notes.md"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/new.md", []byte("x"), 0o644); err == nil {
+		t.Error("write to a read-only database succeeded")
+	}
+	caps, err = afs.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+	if !caps.ReadOnly || caps.SchemaVersion != "99.0" || len(caps.UnknownFeatures) != 1 || caps.UnknownFeatures[0] != "holograms" {
+		t.Errorf("Capabilities = %+v", caps)
+	}
+
+	// OpenWith cannot open read-only, so it refuses without touching the schema
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+	var mismatch *ErrSchemaVersionMismatch
+	if _, err := OpenWith(ctx, db); !errors.As(err, &mismatch) {
+		t.Errorf("OpenWith = %v, want ErrSchemaVersionMismatch", err)
+	}
+}
+
+func TestCompareSchemaVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"0.4", "0.4", 0},
+		{"0.4", "0.10", -1},
+		{"1.0", "0.9", 1},
+		{"0.4.1", "0.4", 1},
+	} {
+		if got := compareSchemaVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareSchemaVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCapabilitiesEnabledFeatures(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	caps, err := afs.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+	if caps.Versioning || caps.Dedup || caps.Vectors || caps.Encryption {
+		t.Errorf("Capabilities = %+v, want no optional features", caps)
+	}
+
+	if err := afs.SetPolicy(ctx, Policy{KeepVersions: 3}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	if caps, err = afs.Capabilities(ctx); err != nil || !caps.Versioning {
+		t.Errorf("Capabilities = %+v, %v, want Versioning", caps, err)
+	}
+}
@@ -39,6 +39,18 @@ const (
 // Default chunk size for file data storage
 const DefaultChunkSize = 4096
 
+// DefaultRemoteChunkSize is the chunk size of new remote databases (see
+// AgentFSOptions.Remote), larger so a file takes fewer rows to transfer.
+const DefaultRemoteChunkSize = 64 << 10
+
+// MaxChunkSize is the largest chunk size a file may be stored in (see
+// Filesystem.SetChunkSize).
+const MaxChunkSize = 64 << 20
+
+// DefaultReaddirPageSize is the number of entries ReaddirPage returns when
+// no limit is given.
+const DefaultReaddirPageSize = 1000
+
 // POSIX error codes
 const (
 	EPERM        = 1  // Operation not permitted
@@ -46,6 +58,7 @@ const (
 	EIO          = 5  // I/O error
 	EBADF        = 9  // Bad file descriptor
 	EACCES       = 13 // Permission denied
+	EBUSY        = 16 // Device or resource busy
 	EEXIST       = 17 // File exists
 	ENOTDIR      = 20 // Not a directory
 	EISDIR       = 21 // Is a directory
@@ -0,0 +1,119 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// AddUsage adds u to the usage attributed to the tool call id. Calls that
+// make several model requests can report each one as it completes.
+func (tc *ToolCalls) AddUsage(ctx context.Context, id int64, u Usage) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	res, err := tc.db.ExecContext(ctx, toolCallUsageAdd, id, u.InputTokens, u.OutputTokens, u.CostUSD)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("tool call not found: %d", id)
+	}
+	return nil
+}
+
+// Usage returns the usage attributed to the tool call id, or a zero Usage
+// if none was recorded.
+func (tc *ToolCalls) Usage(ctx context.Context, id int64) (Usage, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return Usage{}, err
+	}
+	defer done()
+
+	var u Usage
+	err = tc.db.QueryRowContext(ctx, toolCallUsageGet, id).Scan(&u.InputTokens, &u.OutputTokens, &u.CostUSD)
+	if err != nil && err != sql.ErrNoRows {
+		return Usage{}, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return u, nil
+}
+
+// CostByPath reports what was spent producing root and each file and
+// directory below it, combining the files linked to tool calls (see
+// LinkFiles) with their usage (see AddUsage). Entries are sorted by path,
+// root first; paths without linked calls are left out. Links record the
+// path at link time, so files renamed since are attributed to their old
+// path.
+//
+// Example:
+//
+//	costs, err := afs.CostByPath(ctx, "/reports/q3")
+//	fmt.Printf("$%.2f over %d calls\n", costs[0].CostUSD, costs[0].Calls)
+func (a *AgentFS) CostByPath(ctx context.Context, root string) ([]PathCost, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	root, err = a.FS.cleanPath(ctx, "cost", root)
+	if err != nil {
+		return nil, err
+	}
+	prefix := root + "/"
+	if root == "/" {
+		prefix = "/"
+	}
+
+	rows, err := a.db.QueryContext(ctx, toolCallCostLinks, root, escapePattern(prefix)+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tool call links: %w", err)
+	}
+	defer rows.Close()
+
+	// Collect the distinct calls charged to each path and its ancestors
+	calls := map[string]map[int64]bool{}
+	usage := map[int64]Usage{}
+	for rows.Next() {
+		var p string
+		var id int64
+		var u Usage
+		if err := rows.Scan(&p, &id, &u.InputTokens, &u.OutputTokens, &u.CostUSD); err != nil {
+			return nil, err
+		}
+		usage[id] = u
+		for {
+			if calls[p] == nil {
+				calls[p] = map[int64]bool{}
+			}
+			calls[p][id] = true
+			if p == root {
+				break
+			}
+			p = path.Dir(p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	costs := make([]PathCost, 0, len(calls))
+	for p, ids := range calls {
+		pc := PathCost{Path: p, Calls: int64(len(ids))}
+		for id := range ids {
+			u := usage[id]
+			pc.InputTokens += u.InputTokens
+			pc.OutputTokens += u.OutputTokens
+			pc.CostUSD += u.CostUSD
+		}
+		costs = append(costs, pc)
+	}
+	sort.Slice(costs, func(i, j int) bool { return costs[i].Path < costs[j].Path })
+	return costs, nil
+}
@@ -0,0 +1,58 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCostByPath(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	record := func(u Usage, paths ...string) int64 {
+		call, err := afs.Tools.Record(ctx, "llm", nil, "ok", nil, 1, 2)
+		if err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		if err := afs.Tools.AddUsage(ctx, call.ID, u); err != nil {
+			t.Fatalf("AddUsage failed: %v", err)
+		}
+		if err := afs.Tools.LinkFiles(ctx, call.ID, paths...); err != nil {
+			t.Fatalf("LinkFiles failed: %v", err)
+		}
+		return call.ID
+	}
+	draft := record(Usage{InputTokens: 100, OutputTokens: 50, CostUSD: 0.5}, "/reports/q3/draft.md", "/reports/q3/data.csv")
+	record(Usage{InputTokens: 10, OutputTokens: 5, CostUSD: 0.25}, "/reports/q3/final.md")
+	record(Usage{CostUSD: 9}, "/reports/q4/final.md", "/reports/q3x.md")
+
+	// Usage accumulates
+	afs.Tools.AddUsage(ctx, draft, Usage{InputTokens: 100, CostUSD: 0.5})
+	if u, _ := afs.Tools.Usage(ctx, draft); u.InputTokens != 200 || u.CostUSD != 1 {
+		t.Errorf("Usage = %+v", u)
+	}
+
+	costs, err := afs.CostByPath(ctx, "/reports/q3")
+	if err != nil {
+		t.Fatalf("CostByPath failed: %v", err)
+	}
+	want := []PathCost{
+		{Path: "/reports/q3", Calls: 2, Usage: Usage{InputTokens: 210, OutputTokens: 55, CostUSD: 1.25}},
+		{Path: "/reports/q3/data.csv", Calls: 1, Usage: Usage{InputTokens: 200, OutputTokens: 50, CostUSD: 1}},
+		{Path: "/reports/q3/draft.md", Calls: 1, Usage: Usage{InputTokens: 200, OutputTokens: 50, CostUSD: 1}},
+		{Path: "/reports/q3/final.md", Calls: 1, Usage: Usage{InputTokens: 10, OutputTokens: 5, CostUSD: 0.25}},
+	}
+	if len(costs) != len(want) {
+		t.Fatalf("CostByPath = %+v", costs)
+	}
+	for i := range want {
+		if costs[i] != want[i] {
+			t.Errorf("costs[%d] = %+v, want %+v", i, costs[i], want[i])
+		}
+	}
+
+	if err := afs.Tools.AddUsage(ctx, 999, Usage{CostUSD: 1}); err == nil {
+		t.Error("AddUsage for a missing call succeeded")
+	}
+}
@@ -0,0 +1,179 @@
+package agentfs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrNoDecoder is returned by OpenDecoded for a file compressed in a format
+// without a registered decoder.
+var ErrNoDecoder = errors.New("agentfs: no decoder for compression format")
+
+// ErrDecodedTooLarge is returned by Grep and Excerpt with Decompress set
+// for a file that decompresses to more than MaxDecodedSize bytes.
+var ErrDecodedTooLarge = errors.New("agentfs: decompressed file too large")
+
+// MaxDecodedSize is the most decompressed content Grep and Excerpt read
+// from one file with Decompress set, so a small, highly compressed file
+// cannot exhaust memory. Stream larger files through OpenDecoded.
+const MaxDecodedSize = 64 << 20
+
+// DecodeFunc wraps a compressed stream in a decompressing reader.
+type DecodeFunc func(r io.Reader) (io.ReadCloser, error)
+
+// compressionFormat is a compression format recognized by its magic bytes.
+type compressionFormat struct {
+	name   string
+	magic  []byte
+	decode DecodeFunc // nil until a decoder is registered
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = []compressionFormat{
+		{name: "gzip", magic: []byte{0x1f, 0x8b}, decode: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		}},
+		{name: "bzip2", magic: []byte("BZh"), decode: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		}},
+		// Recognized so OpenDecoded can name them; decoders live outside
+		// the standard library (see RegisterDecoder)
+		{name: "zstd", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+		{name: "xz", magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	}
+)
+
+// RegisterDecoder makes OpenDecoded decompress files starting with magic
+// using decode, replacing any decoder registered for name. gzip and bzip2
+// are built in; zstd and xz are recognized but need a decoder, e.g.
+//
+//	agentfs.RegisterDecoder("zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}, func(r io.Reader) (io.ReadCloser, error) {
+//	    d, err := zstd.NewReader(r)
+//	    if err != nil {
+//	        return nil, err
+//	    }
+//	    return d.IOReadCloser(), nil
+//	})
+func RegisterDecoder(name string, magic []byte, decode DecodeFunc) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for i := range formats {
+		if formats[i].name == name {
+			formats[i] = compressionFormat{name: name, magic: magic, decode: decode}
+			return
+		}
+	}
+	formats = append(formats, compressionFormat{name: name, magic: magic, decode: decode})
+}
+
+// detectCompression returns the format whose magic starts head, if any.
+func detectCompression(head []byte) (compressionFormat, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, f := range formats {
+		if len(f.magic) > 0 && bytes.HasPrefix(head, f.magic) {
+			return f, true
+		}
+	}
+	return compressionFormat{}, false
+}
+
+// DecodedReader reads the decompressed content of a file opened with
+// OpenDecoded.
+type DecodedReader struct {
+	io.Reader
+	// Encoding names the detected compression format, e.g. "gzip", or is
+	// empty for a file that is not compressed.
+	Encoding string
+
+	file    *File
+	decoder io.Closer
+}
+
+// Close releases the decoder and the file handle.
+func (d *DecodedReader) Close() error {
+	var err error
+	if d.decoder != nil {
+		err = d.decoder.Close()
+	}
+	d.file.Close()
+	return err
+}
+
+// OpenDecoded opens the file at p for reading and, if its first bytes
+// identify a compression format, decompresses it on the fly, whatever the
+// file is named. Files that are not compressed are read as-is. A format
+// without a decoder fails with ErrNoDecoder.
+//
+// Example:
+//
+//	r, err := afs.FS.OpenDecoded(ctx, "/logs/build.log.gz")
+//	if err != nil {
+//	    return err
+//	}
+//	defer r.Close()
+//	sc := bufio.NewScanner(r)
+func (fs *Filesystem) OpenDecoded(ctx context.Context, p string) (*DecodedReader, error) {
+	f, err := fs.Open(ctx, p, O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(f.WithContext(ctx))
+	head, err := br.Peek(8)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	d := &DecodedReader{Reader: br, file: f}
+
+	format, ok := detectCompression(head)
+	if !ok {
+		return d, nil
+	}
+	if format.decode == nil {
+		f.Close()
+		return nil, fmt.Errorf("%w %s: %s", ErrNoDecoder, format.name, f.Path())
+	}
+	dec, err := format.decode(br)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to decode %s: %w", f.Path(), err)
+	}
+	d.Reader, d.decoder, d.Encoding = dec, dec, format.name
+	return d, nil
+}
+
+// readMaybeDecoded reads the file at p, decompressed if decode is set, in
+// which case more than limit decompressed bytes fail with
+// ErrDecodedTooLarge. Compressed files without a decoder are read as
+// stored.
+func (fs *Filesystem) readMaybeDecoded(ctx context.Context, p string, decode bool, limit int64) ([]byte, error) {
+	if !decode {
+		return fs.ReadFile(ctx, p)
+	}
+	r, err := fs.OpenDecoded(ctx, p)
+	if errors.Is(err, ErrNoDecoder) {
+		return fs.ReadFile(ctx, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s decompresses to more than %d bytes", ErrDecodedTooLarge, p, limit)
+	}
+	return data, nil
+}
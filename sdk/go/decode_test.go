@@ -0,0 +1,82 @@
+package agentfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestOpenDecoded(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("starting\nERROR disk full\ndone\n"))
+	zw.Close()
+	fs.WriteFile(ctx, "/logs/build.log.gz", gz.Bytes(), 0o644)
+	fs.WriteFile(ctx, "/logs/plain.log", []byte("ERROR plain\n"), 0o644)
+	fs.WriteFile(ctx, "/logs/archive.zst", []byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0}, 0o644)
+
+	r, err := fs.OpenDecoded(ctx, "/logs/build.log.gz")
+	if err != nil {
+		t.Fatalf("OpenDecoded failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || r.Encoding != "gzip" || string(data) != "starting\nERROR disk full\ndone\n" {
+		t.Errorf("OpenDecoded(gzip) = %q (%s), %v", data, r.Encoding, err)
+	}
+
+	r, err = fs.OpenDecoded(ctx, "/logs/plain.log")
+	if err != nil {
+		t.Fatalf("OpenDecoded failed: %v", err)
+	}
+	data, _ = io.ReadAll(r)
+	r.Close()
+	if r.Encoding != "" || string(data) != "ERROR plain\n" {
+		t.Errorf("OpenDecoded(plain) = %q (%s)", data, r.Encoding)
+	}
+
+	if _, err := fs.OpenDecoded(ctx, "/logs/archive.zst"); !errors.Is(err, ErrNoDecoder) {
+		t.Errorf("OpenDecoded(zstd) = %v, want ErrNoDecoder", err)
+	}
+
+	matches, err := fs.Grep(ctx, "/logs", "ERROR", &GrepOptions{Decompress: true})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Path != "/logs/build.log.gz" || matches[0].Line != 2 {
+		t.Errorf("Grep with Decompress = %+v", matches)
+	}
+
+	ex, err := fs.Excerpt(ctx, "/logs/build.log.gz", ExcerptOptions{Decompress: true})
+	if err != nil || ex.TotalLines != 3 {
+		t.Errorf("Excerpt with Decompress = %+v, %v", ex, err)
+	}
+}
+
+func TestReadDecodedLimit(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(make([]byte, 1<<20))
+	zw.Close()
+	fs.WriteFile(ctx, "/bomb.gz", gz.Bytes(), 0o644)
+
+	if _, err := fs.readMaybeDecoded(ctx, "/bomb.gz", true, 1<<10); !errors.Is(err, ErrDecodedTooLarge) {
+		t.Errorf("readMaybeDecoded over the limit = %v, want ErrDecodedTooLarge", err)
+	}
+	if data, err := fs.readMaybeDecoded(ctx, "/bomb.gz", true, 1<<20); err != nil || len(data) != 1<<20 {
+		t.Errorf("readMaybeDecoded at the limit = %d bytes, %v", len(data), err)
+	}
+}
@@ -0,0 +1,246 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultEditLockTimeout is how long an EditSession holds its lock. A lock
+// left by an abandoned session can be taken over once it expires.
+const DefaultEditLockTimeout = 10 * time.Minute
+
+// maxEditDiffLines bounds the line-diff work done for an EditConflictError.
+// Larger files report only their sizes.
+const maxEditDiffLines = 1000
+
+// ErrEditConflict is matched by errors.Is for every *EditConflictError.
+var ErrEditConflict = errors.New("agentfs: file changed during edit")
+
+// EditConflictError is returned by EditSession.Commit when the file no
+// longer has the content the session started from.
+type EditConflictError struct {
+	Path string
+	// Diff lists the lines removed ("- ") and added ("+ ") since the
+	// session began. It is empty if the file was removed.
+	Diff string
+	// Removed is set if the file no longer exists.
+	Removed bool
+}
+
+func (e *EditConflictError) Error() string {
+	if e.Removed {
+		return fmt.Sprintf("edit %s: file removed during edit", e.Path)
+	}
+	return fmt.Sprintf("edit %s: file changed during edit:\n%s", e.Path, e.Diff)
+}
+
+func (e *EditConflictError) Unwrap() error { return ErrEditConflict }
+
+// EditSession is a read-modify-write of one file, started by BeginEdit.
+// It holds an advisory lock that makes other BeginEdit calls for the file
+// fail with EBUSY, and its Commit fails if the file changed by any means
+// since the session began.
+type EditSession struct {
+	fs      *Filesystem
+	path    string
+	ino     int64
+	mode    int64
+	id      string
+	content []byte
+	hash    string
+	done    bool
+}
+
+// BeginEdit locks the regular file at p for editing and returns its current
+// content. The caller must end the session with Commit or Abort; a session
+// that does neither loses its lock after DefaultEditLockTimeout.
+//
+// Example:
+//
+//	edit, err := afs.FS.BeginEdit(ctx, "/plan.md")
+//	if err != nil {
+//		return err
+//	}
+//	defer edit.Abort(ctx)
+//	return edit.Commit(ctx, append(edit.Content(), "- [ ] ship\n"...))
+func (fs *Filesystem) BeginEdit(ctx context.Context, p string) (*EditSession, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "edit", p)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &EditSession{fs: fs, path: p, id: fs.ids.NewID()}
+	err = fs.inTx(ctx, func(tfs *Filesystem) error {
+		ino, err := tfs.resolvePathFollow(ctx, p, true)
+		if err != nil {
+			return err
+		}
+		stats, err := tfs.statInode(ctx, ino)
+		if err != nil {
+			return err
+		}
+		if stats.IsDir() {
+			return ErrIsDir("edit", p)
+		}
+		if !stats.IsRegularFile() {
+			return ErrInval("edit", p, "not a regular file")
+		}
+
+		now := fs.now()
+		if _, err := tfs.db.ExecContext(ctx, editLockDeleteExpired, ino, now.Unix()); err != nil {
+			return err
+		}
+		expires := now.Add(DefaultEditLockTimeout).Unix()
+		if _, err := tfs.db.ExecContext(ctx, editLockInsert, ino, e.id, expires); err != nil {
+			if isUniqueConstraintError(err) {
+				return ErrBusy("edit", p, "file is being edited")
+			}
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+
+		data, err := tfs.ReadFile(ctx, p)
+		if err != nil {
+			return err
+		}
+		e.ino, e.mode, e.content = ino, stats.Mode&0o7777, data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.hash = contentHash(e.content)
+	return e, nil
+}
+
+// Path returns the path being edited.
+func (e *EditSession) Path() string { return e.path }
+
+// Content returns the file content when the session began.
+func (e *EditSession) Content() []byte { return e.content }
+
+// Hash returns the SHA-256 of Content, hex encoded.
+func (e *EditSession) Hash() string { return e.hash }
+
+// Commit replaces the file with data and releases the lock. If the file
+// changed since BeginEdit, nothing is written, the lock is kept, and an
+// *EditConflictError describes the change; the caller can then Abort and
+// start over from the new content.
+func (e *EditSession) Commit(ctx context.Context, data []byte) error {
+	if e.done {
+		return ErrInval("edit", e.path, "edit session ended")
+	}
+	ctx, done, err := e.fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	err = e.fs.inTx(ctx, func(tfs *Filesystem) error {
+		current, err := tfs.ReadFile(ctx, e.path)
+		if IsNotExist(err) {
+			return &EditConflictError{Path: e.path, Removed: true}
+		}
+		if err != nil {
+			return err
+		}
+		if contentHash(current) != e.hash {
+			return &EditConflictError{Path: e.path, Diff: editDiff(e.content, current)}
+		}
+
+		if err := tfs.WriteFile(ctx, e.path, data, e.mode); err != nil {
+			return err
+		}
+		_, err = tfs.db.ExecContext(ctx, editLockRelease, e.ino, e.id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	e.done = true
+	return nil
+}
+
+// Abort releases the lock without writing. It is a no-op after Commit or
+// a previous Abort, so it can be deferred.
+func (e *EditSession) Abort(ctx context.Context) error {
+	if e.done {
+		return nil
+	}
+	ctx, done, err := e.fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if _, err := e.fs.db.ExecContext(ctx, editLockRelease, e.ino, e.id); err != nil {
+		return fmt.Errorf("failed to release edit lock: %w", err)
+	}
+	e.done = true
+	return nil
+}
+
+// contentHash returns the hex SHA-256 of data.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// editDiff describes how a changed to b for an EditConflictError.
+func editDiff(a, b []byte) string {
+	al := strings.Split(string(a), "\n")
+	bl := strings.Split(string(b), "\n")
+	if len(al) > maxEditDiffLines || len(bl) > maxEditDiffLines || bytes.IndexByte(b, 0) >= 0 {
+		return fmt.Sprintf("content changed (%d -> %d bytes)", len(a), len(b))
+	}
+	return strings.Join(lineDiff(al, bl), "\n")
+}
+
+// lineDiff returns the lines removed ("- ") and added ("+ ") to turn a into
+// b, using a longest common subsequence. Unchanged lines are omitted.
+func lineDiff(a, b []string) []string {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			out = append(out, "+ "+b[j])
+			j++
+		default:
+			out = append(out, "- "+a[i])
+			i++
+		}
+	}
+	return out
+}
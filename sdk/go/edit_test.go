@@ -0,0 +1,101 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEditSession(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/plan.md", []byte("one\ntwo\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	edit, err := afs.FS.BeginEdit(ctx, "/plan.md")
+	if err != nil {
+		t.Fatalf("BeginEdit failed: %v", err)
+	}
+	if string(edit.Content()) != "one\ntwo\n" {
+		t.Errorf("Content = %q", edit.Content())
+	}
+	if _, err := afs.FS.BeginEdit(ctx, "/plan.md"); !IsBusy(err) {
+		t.Errorf("second BeginEdit: err = %v, want EBUSY", err)
+	}
+
+	if err := edit.Commit(ctx, []byte("one\ntwo\nthree\n")); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	data, _ := afs.FS.ReadFile(ctx, "/plan.md")
+	if string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("content after Commit = %q", data)
+	}
+	if stats, _ := afs.FS.Stat(ctx, "/plan.md"); stats.Mode&0o777 != 0o600 {
+		t.Errorf("mode = %o, want 600", stats.Mode&0o777)
+	}
+	if err := edit.Abort(ctx); err != nil {
+		t.Errorf("Abort after Commit failed: %v", err)
+	}
+}
+
+func TestEditSessionConflict(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/plan.md", []byte("one\ntwo\n"), 0o644)
+	edit, err := afs.FS.BeginEdit(ctx, "/plan.md")
+	if err != nil {
+		t.Fatalf("BeginEdit failed: %v", err)
+	}
+
+	// Another agent writes without taking the lock
+	afs.FS.WriteFile(ctx, "/plan.md", []byte("one\n2\n"), 0o644)
+
+	err = edit.Commit(ctx, []byte("mine\n"))
+	var conflict *EditConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("Commit: err = %v, want *EditConflictError", err)
+	}
+	if conflict.Diff != "- two\n+ 2" {
+		t.Errorf("Diff = %q", conflict.Diff)
+	}
+	data, _ := afs.FS.ReadFile(ctx, "/plan.md")
+	if string(data) != "one\n2\n" {
+		t.Errorf("conflicting Commit wrote %q", data)
+	}
+
+	if err := edit.Abort(ctx); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if _, err := afs.FS.BeginEdit(ctx, "/plan.md"); err != nil {
+		t.Errorf("BeginEdit after Abort failed: %v", err)
+	}
+}
+
+func TestEditLockExpires(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	afs, err := Open(ctx, AgentFSOptions{Path: t.TempDir() + "/test.db", Clock: clock})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/a.txt", []byte("a"), 0o644)
+	if _, err := afs.FS.BeginEdit(ctx, "/a.txt"); err != nil {
+		t.Fatalf("BeginEdit failed: %v", err)
+	}
+	clock.Advance(DefaultEditLockTimeout)
+	if _, err := afs.FS.BeginEdit(ctx, "/a.txt"); err != nil {
+		t.Errorf("BeginEdit after expiry failed: %v", err)
+	}
+	if _, err := afs.FS.BeginEdit(ctx, "/"); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("BeginEdit(/): err = %v, want EISDIR", err)
+	}
+}
@@ -34,6 +34,8 @@ func (e *FSError) codeMessage() string {
 		return "bad file descriptor"
 	case EACCES:
 		return "permission denied"
+	case EBUSY:
+		return "device or resource busy"
 	case EEXIST:
 		return "file exists"
 	case ENOTDIR:
@@ -88,6 +90,11 @@ func ErrExist(syscall, path string) *FSError {
 	return &FSError{Code: EEXIST, Syscall: syscall, Path: path}
 }
 
+// ErrBusy returns an EBUSY error (device or resource busy)
+func ErrBusy(syscall, path, message string) *FSError {
+	return &FSError{Code: EBUSY, Syscall: syscall, Path: path, Message: message}
+}
+
 // ErrIsDir returns an EISDIR error (is a directory)
 func ErrIsDir(syscall, path string) *FSError {
 	return &FSError{Code: EISDIR, Syscall: syscall, Path: path}
@@ -108,6 +115,11 @@ func ErrInval(syscall, path, message string) *FSError {
 	return &FSError{Code: EINVAL, Syscall: syscall, Path: path, Message: message}
 }
 
+// ErrNoSpace returns an ENOSPC error (no space left, e.g. a quota is full)
+func ErrNoSpace(syscall, path, message string) *FSError {
+	return &FSError{Code: ENOSPC, Syscall: syscall, Path: path, Message: message}
+}
+
 // ErrPerm returns an EPERM error (operation not permitted)
 func ErrPerm(syscall, path string) *FSError {
 	return &FSError{Code: EPERM, Syscall: syscall, Path: path}
@@ -143,6 +155,16 @@ func ErrNotSymlink(syscall, path string) *FSError {
 	return &FSError{Code: EINVAL, Syscall: syscall, Path: path, Message: "not a symbolic link"}
 }
 
+// IsNoSpace returns true if the error indicates that a write exceeded the
+// quota
+func IsNoSpace(err error) bool {
+	var fsErr *FSError
+	if errors.As(err, &fsErr) {
+		return fsErr.Code == ENOSPC
+	}
+	return false
+}
+
 // IsNameTooLong returns true if the error indicates a filename was too long
 func IsNameTooLong(err error) bool {
 	var fsErr *FSError
@@ -187,3 +209,13 @@ func IsPermission(err error) bool {
 	}
 	return false
 }
+
+// IsBusy returns true if the error indicates a resource held by someone
+// else (EBUSY)
+func IsBusy(err error) bool {
+	var fsErr *FSError
+	if errors.As(err, &fsErr) {
+		return fsErr.Code == EBUSY
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package agentfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EvalFormat selects the record layout written by ExportEvalSet.
+type EvalFormat string
+
+const (
+	// EvalFormatJSONL writes one self-describing record per call: input,
+	// output, error, annotations, and linked files.
+	EvalFormatJSONL EvalFormat = "jsonl"
+	// EvalFormatOpenAI writes OpenAI evals samples, with the parameters as
+	// the user message and the result as the ideal answer.
+	EvalFormatOpenAI EvalFormat = "openai"
+	// EvalFormatPromptfoo writes promptfoo test cases, with the parameters
+	// as vars and an equality assertion on the result.
+	EvalFormatPromptfoo EvalFormat = "promptfoo"
+)
+
+// EvalDatasetFile is the name of the JSONL file written by ExportEvalSet.
+// Linked files are copied below the "files" directory next to it.
+const EvalDatasetFile = "dataset.jsonl"
+
+// LinkFiles records that the tool call id read or produced the files at
+// paths, so ExportEvalSet can bundle them with the call.
+func (tc *ToolCalls) LinkFiles(ctx context.Context, id int64, paths ...string) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	for _, p := range paths {
+		res, err := tc.db.ExecContext(ctx, toolCallFileLink, id, normalizePath(p))
+		if err != nil {
+			return fmt.Errorf("failed to link file: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			// Either already linked or the call does not exist
+			if _, err := tc.Get(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LinkedFiles returns the paths linked to the tool call id, sorted.
+func (tc *ToolCalls) LinkedFiles(ctx context.Context, id int64) ([]string, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallFileList, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query linked files: %w", err)
+	}
+	defer rows.Close()
+
+	paths := []string{}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// evalCall is a tool call with everything ExportEvalSet bundles.
+type evalCall struct {
+	ToolCall
+	Annotations map[string]json.RawMessage
+	Files       []string // Relative to the dataset directory
+}
+
+// ExportEvalSet writes the tool calls matching filter to hostDir as an
+// evaluation dataset: EvalDatasetFile holds one record per call in format,
+// and the current content of each call's linked files is copied to
+// files/<call id>/<path>. Linked files that no longer exist are left out.
+// Set filter.Limit to export more than the default 100 calls.
+//
+// Example:
+//
+//	err := afs.Tools.ExportEvalSet(ctx, agentfs.ToolCallFilter{
+//	    Annotations: map[string]any{"reviewed": true},
+//	    Limit:       10000,
+//	}, agentfs.EvalFormatPromptfoo, "./evals/search")
+func (tc *ToolCalls) ExportEvalSet(ctx context.Context, filter ToolCallFilter, format EvalFormat, hostDir string) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	switch format {
+	case EvalFormatJSONL, EvalFormatOpenAI, EvalFormatPromptfoo:
+	default:
+		return fmt.Errorf("unknown eval format %q", format)
+	}
+
+	calls, err := tc.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(hostDir, 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(filepath.Join(hostDir, EvalDatasetFile))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	for _, call := range calls {
+		ec := evalCall{ToolCall: call, Files: []string{}}
+		if ec.Annotations, err = listAnnotations(ctx, tc.db, call.ID); err != nil {
+			return err
+		}
+		if ec.Files, err = tc.exportLinkedFiles(ctx, call.ID, hostDir); err != nil {
+			return err
+		}
+		if err := enc.Encode(ec.record(format)); err != nil {
+			return fmt.Errorf("failed to write eval record: %w", err)
+		}
+	}
+	return out.Close()
+}
+
+// exportLinkedFiles copies the files linked to id below hostDir and returns
+// their dataset-relative paths.
+func (tc *ToolCalls) exportLinkedFiles(ctx context.Context, id int64, hostDir string) ([]string, error) {
+	paths, err := tc.LinkedFiles(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, p := range paths {
+		data, err := tc.fs.ReadFile(ctx, p)
+		if IsNotExist(err) || errors.Is(err, &FSError{Code: EISDIR}) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rel := filepath.Join("files", fmt.Sprint(id), filepath.FromSlash(strings.TrimPrefix(p, "/")))
+		target := filepath.Join(hostDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return nil, err
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	return files, nil
+}
+
+// record builds the dataset line for c in format.
+func (c *evalCall) record(format EvalFormat) any {
+	switch format {
+	case EvalFormatOpenAI:
+		return map[string]any{
+			"input": []map[string]string{{"role": "user", "content": string(c.Parameters)}},
+			"ideal": c.ideal(),
+			"metadata": map[string]any{
+				"tool_call_id": c.ID,
+				"tool":         c.Name,
+				"annotations":  c.Annotations,
+				"files":        c.Files,
+			},
+		}
+	case EvalFormatPromptfoo:
+		test := map[string]any{
+			"description": fmt.Sprintf("%s #%d", c.Name, c.ID),
+			"vars": map[string]any{
+				"tool":  c.Name,
+				"input": c.Parameters,
+				"files": c.Files,
+			},
+			"metadata": map[string]any{"tool_call_id": c.ID, "annotations": c.Annotations},
+		}
+		if c.Error == nil {
+			test["assert"] = []map[string]string{{"type": "equals", "value": c.ideal()}}
+		}
+		return test
+	default:
+		return map[string]any{
+			"id":           c.ID,
+			"name":         c.Name,
+			"input":        c.Parameters,
+			"output":       c.Result,
+			"error":        c.Error,
+			"started_at":   c.StartedAt,
+			"completed_at": c.CompletedAt,
+			"annotations":  c.Annotations,
+			"files":        c.Files,
+		}
+	}
+}
+
+// ideal returns the expected answer of c as text: the result JSON, or the
+// error message of a failed call.
+func (c *evalCall) ideal() string {
+	if c.Error != nil {
+		return *c.Error
+	}
+	return string(c.Result)
+}
@@ -0,0 +1,80 @@
+package agentfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportEvalSet(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/out/report.md", []byte("# Report"), 0o644)
+	call, err := afs.Tools.Record(ctx, "write_report", map[string]string{"topic": "q3"}, "done", nil, 100, 101)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	afs.Tools.Record(ctx, "write_report", nil, "skipped", nil, 102, 103)
+	afs.Tools.Annotate(ctx, call.ID, "reviewed", true)
+	if err := afs.Tools.LinkFiles(ctx, call.ID, "/out/report.md", "/out/gone.md"); err != nil {
+		t.Fatalf("LinkFiles failed: %v", err)
+	}
+	if err := afs.Tools.LinkFiles(ctx, 999, "/out/report.md"); err == nil {
+		t.Error("LinkFiles to a missing call succeeded")
+	}
+
+	dir := t.TempDir()
+	filter := ToolCallFilter{Annotations: map[string]any{"reviewed": true}}
+	if err := afs.Tools.ExportEvalSet(ctx, filter, EvalFormatJSONL, dir); err != nil {
+		t.Fatalf("ExportEvalSet failed: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, EvalDatasetFile))
+	if err != nil {
+		t.Fatalf("dataset missing: %v", err)
+	}
+	defer f.Close()
+	var records []map[string]any
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("bad record %q: %v", sc.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 1 {
+		t.Fatalf("exported %d records, want 1", len(records))
+	}
+	files := records[0]["files"].([]any)
+	if len(files) != 1 || files[0] != fmt.Sprintf("files/%d/out/report.md", call.ID) {
+		t.Errorf("files = %v", files)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "files", fmt.Sprint(call.ID), "out", "report.md")); err != nil || string(data) != "# Report" {
+		t.Errorf("linked file = %q, %v", data, err)
+	}
+
+	if err := afs.Tools.ExportEvalSet(ctx, filter, EvalFormatPromptfoo, dir); err != nil {
+		t.Fatalf("ExportEvalSet(promptfoo) failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, EvalDatasetFile))
+	var test struct {
+		Vars   map[string]any      `json:"vars"`
+		Assert []map[string]string `json:"assert"`
+	}
+	if err := json.Unmarshal(data, &test); err != nil {
+		t.Fatalf("bad promptfoo record: %v", err)
+	}
+	if test.Vars["tool"] != "write_report" || len(test.Assert) != 1 || test.Assert[0]["value"] != `"done"` {
+		t.Errorf("promptfoo record = %s", data)
+	}
+
+	if err := afs.Tools.ExportEvalSet(ctx, filter, "csv", dir); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,216 @@
+package agentfs
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind identifies the kind of change reported by the change feed.
+type EventKind string
+
+// Change feed event kinds
+const (
+	EventFileCreated       EventKind = "file.created"        // File, symlink, hard link, or node created
+	EventFileWritten       EventKind = "file.written"        // File content written or truncated
+	EventFileRemoved       EventKind = "file.removed"        // File or directory removed
+	EventFileRenamed       EventKind = "file.renamed"        // Entry moved from OldPath to Path
+	EventDirCreated        EventKind = "dir.created"         // Directory created
+	EventFileOpened        EventKind = "file.opened"         // File handle opened
+	EventFileClosed        EventKind = "file.closed"         // File handle closed
+	EventKVSet             EventKind = "kv.set"              // Key set (Path is the key)
+	EventKVDeleted         EventKind = "kv.deleted"          // Key deleted (Path is the key or prefix)
+	EventToolCallCompleted EventKind = "tool_call.completed" // Tool call recorded
+	EventToolCallOutput    EventKind = "tool_call.output"    // Output appended to a running tool call (Path is its name)
+	EventQuotaWarning      EventKind = "quota.warning"       // Usage crossed a quota threshold
+	EventStreamAppended    EventKind = "stream.appended"     // Record appended to a stream (Path is its name)
+)
+
+// DefaultEventBuffer is the channel buffer used by Subscribe when none is given.
+const DefaultEventBuffer = 256
+
+// Event is a single entry in the change feed.
+type Event struct {
+	// Seq increases by one for every event published by this AgentFS.
+	// A gap in Seq means the subscriber fell behind and events were dropped.
+	Seq       int64         `json:"seq"`
+	Kind      EventKind     `json:"kind"`
+	Path      string        `json:"path,omitempty"`
+	OldPath   string        `json:"old_path,omitempty"`
+	Namespace string        `json:"namespace,omitempty"` // KV namespace of Path (see KVStore.Namespace)
+	ToolCall  *ToolCall     `json:"tool_call,omitempty"`
+	Quota     *QuotaWarning `json:"quota,omitempty"`
+	Time      time.Time     `json:"time"`
+}
+
+// EventFilter selects events for a subscription. The zero value matches
+// every event.
+type EventFilter struct {
+	// Kinds limits events to these kinds (default: all kinds)
+	Kinds []EventKind
+	// PathPrefix limits events to paths or keys with this prefix (default: all)
+	PathPrefix string
+}
+
+// Match reports whether e passes the filter.
+func (f EventFilter) Match(e Event) bool {
+	if len(f.Kinds) > 0 {
+		found := false
+		for _, k := range f.Kinds {
+			if k == e.Kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(e.Path, f.PathPrefix) && !strings.HasPrefix(e.OldPath, f.PathPrefix) {
+		return false
+	}
+	return true
+}
+
+// eventBus fans out change events to in-process subscribers.
+type eventBus struct {
+	mu     sync.Mutex
+	seq    int64
+	subs   map[chan Event]EventFilter
+	hooks  []func(Event) // Called for every event; must not block
+	closed bool
+	clock  Clock
+}
+
+func newEventBus(clock Clock) *eventBus {
+	return &eventBus{subs: make(map[chan Event]EventFilter), clock: clock}
+}
+
+// publish assigns the next sequence number to e and delivers it to matching
+// subscribers without blocking; full subscribers miss the event.
+func (b *eventBus) publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	e.Seq = b.seq
+	if e.Time.IsZero() {
+		e.Time = b.clock.Now()
+	}
+	for _, hook := range b.hooks {
+		hook(e)
+	}
+	for ch, filter := range b.subs {
+		if !filter.Match(e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (b *eventBus) subscribe(filter EventFilter, buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = filter
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subs[ch]; ok {
+				delete(b.subs, ch)
+				close(ch)
+			}
+		})
+	}
+}
+
+// hook calls fn for every event published from now on.
+func (b *eventBus) hook(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hooks = append(b.hooks, fn)
+}
+
+// close ends all subscriptions.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Subscribe streams change events matching filter until cancel is called or
+// the AgentFS is closed, after which the channel is closed. Delivery never
+// blocks writers: if the channel buffer is full, events are dropped and the
+// gap is visible in Event.Seq. A buffer <= 0 uses DefaultEventBuffer.
+//
+// Only changes made through this AgentFS instance are reported.
+//
+// Example:
+//
+//	events, cancel := afs.Subscribe(agentfs.EventFilter{
+//	    Kinds: []agentfs.EventKind{agentfs.EventFileWritten},
+//	}, 0)
+//	defer cancel()
+//	for e := range events {
+//	    fmt.Println(e.Kind, e.Path)
+//	}
+func (a *AgentFS) Subscribe(filter EventFilter, buffer int) (<-chan Event, func()) {
+	return a.events.subscribe(filter, buffer)
+}
+
+// emit publishes a filesystem event. Inside a transaction the event is held
+// until commit so subscribers never see changes that were rolled back.
+func (fs *Filesystem) emit(kind EventKind, p, oldPath string) {
+	fs.emitEvent(Event{Kind: kind, Path: p, OldPath: oldPath, Time: fs.now()})
+}
+
+// emitEvent is emit for events that carry more than paths.
+func (fs *Filesystem) emitEvent(e Event) {
+	deliver(fs.events, fs.pending, e)
+}
+
+// publish reports a KV change, held until commit inside AgentFS.Begin.
+func (kv *KVStore) publish(e Event) {
+	e.Namespace = kv.ns
+	deliver(kv.events, kv.pending, e)
+}
+
+// publish reports a tool call, held until commit inside AgentFS.Begin.
+func (tc *ToolCalls) publish(e Event) {
+	deliver(tc.events, tc.pending, e)
+}
+
+// deliver publishes e on bus, or holds it in pending while a transaction
+// is open.
+func deliver(bus *eventBus, pending *[]Event, e Event) {
+	if pending != nil {
+		*pending = append(*pending, e)
+		return
+	}
+	bus.publish(e)
+}
@@ -0,0 +1,137 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("event channel closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return Event{}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	events, cancel := afs.Subscribe(EventFilter{}, 0)
+	defer cancel()
+
+	if err := afs.FS.WriteFile(ctx, "/a.txt", []byte("a"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.Rename(ctx, "/a.txt", "/b.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := afs.KV.Set(ctx, "k", 1); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	pending, err := afs.Tools.Start(ctx, "search", nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := pending.Success(ctx, "ok"); err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+
+	want := []Event{
+		{Kind: EventFileWritten, Path: "/a.txt"},
+		{Kind: EventFileRenamed, Path: "/b.txt", OldPath: "/a.txt"},
+		{Kind: EventKVSet, Path: "k"},
+		{Kind: EventToolCallCompleted, Path: "search"},
+	}
+	var lastSeq int64
+	for _, w := range want {
+		e := nextEvent(t, events)
+		if e.Kind != w.Kind || e.Path != w.Path || e.OldPath != w.OldPath {
+			t.Errorf("event = %+v, want %+v", e, w)
+		}
+		if e.Seq != lastSeq+1 {
+			t.Errorf("Seq = %d, want %d", e.Seq, lastSeq+1)
+		}
+		lastSeq = e.Seq
+		if e.Kind == EventToolCallCompleted && (e.ToolCall == nil || string(e.ToolCall.Result) != `"ok"`) {
+			t.Errorf("ToolCall = %+v", e.ToolCall)
+		}
+	}
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	events, cancel := afs.Subscribe(EventFilter{
+		Kinds:      []EventKind{EventFileWritten},
+		PathPrefix: "/out/",
+	}, 0)
+	defer cancel()
+
+	for _, p := range []string{"/tmp/x", "/out/y"} {
+		if err := afs.FS.WriteFile(ctx, p, []byte("x"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	if e := nextEvent(t, events); e.Path != "/out/y" {
+		t.Errorf("event = %+v, want /out/y", e)
+	}
+}
+
+func TestSubscribeSkipsRolledBackChanges(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.FS.Mkdir(ctx, "/d", 0o755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := afs.FS.Symlink(ctx, "/d", "/link"); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+
+	events, cancel := afs.Subscribe(EventFilter{}, 0)
+	defer cancel()
+
+	// Creates /d/sub inside the transaction, then fails because the
+	// destination is inside the moved directory
+	if err := afs.FS.MoveTree(ctx, "/d", "/link/sub/d"); err == nil {
+		t.Fatal("MoveTree into own subtree should fail")
+	}
+	if err := afs.KV.Set(ctx, "marker", true); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if e := nextEvent(t, events); e.Kind != EventKVSet {
+		t.Errorf("first event = %+v, want kv.set", e)
+	}
+}
+
+func TestSubscribeClosedOnClose(t *testing.T) {
+	afs := setupTestDB(t)
+	events, cancel := afs.Subscribe(EventFilter{}, 0)
+	defer cancel()
+
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("received event after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after Close")
+	}
+}
@@ -0,0 +1,202 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultExcerptMaxBytes is the excerpt budget when ExcerptOptions sets
+// neither MaxBytes nor MaxTokens.
+const DefaultExcerptMaxBytes = 8 << 10
+
+// bytesPerToken is the rough size of a token used to turn
+// ExcerptOptions.MaxTokens into a byte budget.
+const bytesPerToken = 4
+
+// excerptMarkerCost is the budget charged for each elision marker.
+const excerptMarkerCost = 40
+
+// LineRange is an inclusive range of 1-based line numbers.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Excerpt is a budget-sized view of a text file: the lines kept, grouped
+// into sections, with the gaps between them elided.
+type Excerpt struct {
+	Path       string           `json:"path"`
+	TotalLines int              `json:"total_lines"`
+	TotalBytes int64            `json:"total_bytes"`
+	Sections   []ExcerptSection `json:"sections"`
+	// Truncated is set if any line was left out.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ExcerptSection is a run of consecutive lines kept in an Excerpt.
+type ExcerptSection struct {
+	Start int      `json:"start"` // Line number of the first line (1-based)
+	Lines []string `json:"lines"`
+}
+
+// Excerpt returns as much of the text file at p as fits the budget of
+// opts. The lines in opts.Around (plus Context lines on either side) are
+// kept first, then lines from the head and the tail of the file in turn.
+// Lines are never split, so a file with a line longer than the budget may
+// yield an empty excerpt. Binary files are refused with *ErrBinary.
+//
+// Example:
+//
+//	ex, err := afs.FS.Excerpt(ctx, "/logs/build.log", agentfs.ExcerptOptions{
+//	    MaxTokens: 2000,
+//	    Around:    []agentfs.LineRange{{Start: 812, End: 815}},
+//	})
+//	prompt := ex.String()
+func (fs *Filesystem) Excerpt(ctx context.Context, p string, opts ExcerptOptions) (*Excerpt, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "read", p)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range opts.Around {
+		if r.Start < 1 || r.End < r.Start {
+			return nil, ErrInval("excerpt", p, "bad line range")
+		}
+	}
+
+	data, err := fs.readMaybeDecoded(ctx, p, opts.Decompress, MaxDecodedSize)
+	if err != nil {
+		return nil, err
+	}
+	if looksBinary(data) {
+		return nil, &ErrBinary{Path: p}
+	}
+
+	var lines []string
+	for _, line := range splitLines(data) {
+		lines = append(lines, string(line))
+	}
+	ex := &Excerpt{Path: p, TotalLines: len(lines), TotalBytes: int64(len(data)), Sections: []ExcerptSection{}}
+
+	keep := selectExcerptLines(lines, opts)
+	for i := 0; i < len(lines); {
+		if !keep[i] {
+			ex.Truncated = true
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && keep[i] {
+			i++
+		}
+		ex.Sections = append(ex.Sections, ExcerptSection{Start: start + 1, Lines: lines[start:i]})
+	}
+	return ex, nil
+}
+
+// budget returns the byte budget of opts.
+func (opts ExcerptOptions) budget() int {
+	budget := opts.MaxBytes
+	if opts.MaxTokens > 0 && (budget <= 0 || opts.MaxTokens*bytesPerToken < budget) {
+		budget = opts.MaxTokens * bytesPerToken
+	}
+	if budget <= 0 {
+		budget = DefaultExcerptMaxBytes
+	}
+	return budget
+}
+
+// selectExcerptLines marks the lines to keep within the budget of opts.
+func selectExcerptLines(lines []string, opts ExcerptOptions) []bool {
+	n := len(lines)
+	keep := make([]bool, n)
+	budget := opts.budget()
+
+	total := 0
+	for _, line := range lines {
+		total += len(line) + 1
+	}
+	if total <= budget {
+		for i := range keep {
+			keep[i] = true
+		}
+		return keep
+	}
+
+	// used counts kept lines plus one marker per run of omitted lines
+	used := excerptMarkerCost
+	add := func(i int) bool {
+		if keep[i] {
+			return true
+		}
+		leftGap := i > 0 && !keep[i-1]
+		rightGap := i < n-1 && !keep[i+1]
+		cost := len(lines[i]) + 1
+		switch {
+		case leftGap && rightGap:
+			cost += excerptMarkerCost // Splits a gap in two
+		case !leftGap && !rightGap:
+			cost -= excerptMarkerCost // Closes a gap
+		}
+		if used+cost > budget {
+			return false
+		}
+		keep[i] = true
+		used += cost
+		return true
+	}
+
+	for _, r := range opts.Around {
+		start := max(r.Start-opts.Context, 1)
+		end := min(r.End+opts.Context, n)
+		for i := start - 1; i < end; i++ {
+			if !add(i) {
+				break
+			}
+		}
+	}
+
+	head, tail := 0, n-1
+	headOK, tailOK := true, true
+	for (headOK || tailOK) && head <= tail {
+		if headOK {
+			headOK = add(head)
+			head++
+		}
+		if tailOK && head <= tail {
+			tailOK = add(tail)
+			tail--
+		}
+	}
+	return keep
+}
+
+// String renders the excerpt as plain text, with a marker line in place of
+// each run of omitted lines.
+func (ex *Excerpt) String() string {
+	var b strings.Builder
+	next := 1
+	marker := func(from, to int) {
+		fmt.Fprintf(&b, "[… %d lines omitted (%d-%d) …]\n", to-from+1, from, to)
+	}
+	for _, s := range ex.Sections {
+		if s.Start > next {
+			marker(next, s.Start-1)
+		}
+		for _, line := range s.Lines {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+		next = s.Start + len(s.Lines)
+	}
+	if next <= ex.TotalLines {
+		marker(next, ex.TotalLines)
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExcerpt(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	var b strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&b, "line %03d\n", i) // 9 bytes per line
+	}
+	afs.FS.WriteFile(ctx, "/log.txt", []byte(b.String()), 0o644)
+
+	ex, err := afs.FS.Excerpt(ctx, "/log.txt", ExcerptOptions{MaxBytes: 10 << 10})
+	if err != nil {
+		t.Fatalf("Excerpt failed: %v", err)
+	}
+	if ex.Truncated || len(ex.Sections) != 1 || ex.String() != b.String() {
+		t.Errorf("a file within budget should be returned whole: %+v", ex)
+	}
+
+	ex, err = afs.FS.Excerpt(ctx, "/log.txt", ExcerptOptions{
+		MaxBytes: 300,
+		Around:   []LineRange{{Start: 50, End: 50}},
+		Context:  1,
+	})
+	if err != nil {
+		t.Fatalf("Excerpt failed: %v", err)
+	}
+	if !ex.Truncated || len(ex.Sections) != 3 {
+		t.Fatalf("Sections = %+v, want head, region, and tail", ex.Sections)
+	}
+	if s := ex.Sections[1]; s.Start != 49 || len(s.Lines) != 3 {
+		t.Errorf("region section = %+v, want lines 49-51", s)
+	}
+	if ex.Sections[0].Start != 1 || ex.Sections[2].Start+len(ex.Sections[2].Lines) != 101 {
+		t.Errorf("sections do not cover head and tail: %+v", ex.Sections)
+	}
+	out := ex.String()
+	if len(out) > 300 {
+		t.Errorf("rendered %d bytes, over the 300 byte budget", len(out))
+	}
+	if !strings.Contains(out, "lines omitted") {
+		t.Errorf("missing elision marker:\n%s", out)
+	}
+
+	if _, err := afs.FS.Excerpt(ctx, "/log.txt", ExcerptOptions{Around: []LineRange{{Start: 0, End: 1}}}); err == nil {
+		t.Error("Excerpt accepted line 0")
+	}
+}
+
+func TestExcerptBudget(t *testing.T) {
+	for _, tc := range []struct {
+		opts ExcerptOptions
+		want int
+	}{
+		{ExcerptOptions{}, DefaultExcerptMaxBytes},
+		{ExcerptOptions{MaxBytes: 100}, 100},
+		{ExcerptOptions{MaxTokens: 10}, 40},
+		{ExcerptOptions{MaxBytes: 100, MaxTokens: 10}, 40},
+		{ExcerptOptions{MaxBytes: 30, MaxTokens: 10}, 30},
+	} {
+		if got := tc.opts.budget(); got != tc.want {
+			t.Errorf("budget(%+v) = %d, want %d", tc.opts, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openExternalTestDB(t *testing.T, threshold int64) (*AgentFS, string) {
+	t.Helper()
+	dir := t.TempDir()
+	blobDir := filepath.Join(dir, "blobs")
+	afs, err := Open(context.Background(), AgentFSOptions{
+		Path:      filepath.Join(dir, "test.db"),
+		ChunkSize: 1024,
+		External:  ExternalStorageOptions{Threshold: threshold, Dir: blobDir},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return afs, blobDir
+}
+
+func countBlobs(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestExternalStorage(t *testing.T) {
+	ctx := context.Background()
+	afs, blobDir := openExternalTestDB(t, 4096)
+	defer afs.Close()
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), 512) // 8 KiB
+	small := []byte("small file")
+
+	if err := afs.FS.WriteFile(ctx, "/big.bin", big, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/small.txt", small, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	t.Run("large file data leaves the database", func(t *testing.T) {
+		stats, err := afs.FS.Stat(ctx, "/big.bin")
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		var inline, external int
+		afs.DB().QueryRow("SELECT COUNT(*) FROM fs_data WHERE ino = ?", stats.Ino).Scan(&inline)
+		afs.DB().QueryRow("SELECT COUNT(*) FROM fs_data_ext WHERE ino = ?", stats.Ino).Scan(&external)
+		if inline != 0 || external != 8 {
+			t.Errorf("inline = %d, external = %d, want 0 and 8", inline, external)
+		}
+		// All chunks have the same content and are stored once
+		if n := countBlobs(t, blobDir); n != 1 {
+			t.Errorf("blobs = %d, want 1", n)
+		}
+	})
+
+	t.Run("small file stays inline", func(t *testing.T) {
+		stats, err := afs.FS.Stat(ctx, "/small.txt")
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		var external int
+		afs.DB().QueryRow("SELECT COUNT(*) FROM fs_data_ext WHERE ino = ?", stats.Ino).Scan(&external)
+		if external != 0 {
+			t.Errorf("external = %d, want 0", external)
+		}
+	})
+
+	t.Run("reads", func(t *testing.T) {
+		got, err := afs.FS.ReadFile(ctx, "/big.bin")
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if !bytes.Equal(got, big) {
+			t.Error("ReadFile returned different content")
+		}
+
+		f, err := afs.FS.Open(ctx, "/big.bin", O_RDONLY)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer f.Close()
+		buf := make([]byte, 100)
+		n, err := f.Pread(ctx, buf, 1000)
+		if err != nil {
+			t.Fatalf("Pread failed: %v", err)
+		}
+		if !bytes.Equal(buf[:n], big[1000:1100]) {
+			t.Errorf("Pread = %q", buf[:n])
+		}
+	})
+
+	t.Run("partial writes and truncate", func(t *testing.T) {
+		f, err := afs.FS.Open(ctx, "/big.bin", O_RDWR)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer f.Close()
+		if _, err := f.Pwrite(ctx, []byte("XYZ"), 1023); err != nil {
+			t.Fatalf("Pwrite failed: %v", err)
+		}
+		if err := f.Truncate(ctx, 2000); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+
+		want := append([]byte(nil), big[:2000]...)
+		copy(want[1023:], "XYZ")
+		got, err := afs.FS.ReadFile(ctx, "/big.bin")
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Error("content after Pwrite and Truncate differs")
+		}
+	})
+
+	t.Run("shrinking moves data back inline", func(t *testing.T) {
+		if err := afs.FS.WriteFile(ctx, "/big.bin", small, 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		stats, err := afs.FS.Stat(ctx, "/big.bin")
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		var external int
+		afs.DB().QueryRow("SELECT COUNT(*) FROM fs_data_ext WHERE ino = ?", stats.Ino).Scan(&external)
+		if external != 0 {
+			t.Errorf("external = %d, want 0", external)
+		}
+		got, err := afs.FS.ReadFile(ctx, "/big.bin")
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if !bytes.Equal(got, small) {
+			t.Errorf("ReadFile = %q, want %q", got, small)
+		}
+	})
+}
+
+func TestExternalStorageReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	data := bytes.Repeat([]byte{7}, 10000)
+
+	afs, err := Open(ctx, AgentFSOptions{Path: dbPath, External: ExternalStorageOptions{Threshold: 1}})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/f.bin", data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	afs.Close()
+
+	if _, err := os.Stat(dbPath + ".blobs"); err != nil {
+		t.Fatalf("default blob directory missing: %v", err)
+	}
+
+	// Reopening without the option still reads externalized data
+	afs, err = Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	got, err := afs.FS.ReadFile(ctx, "/f.bin")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("ReadFile after reopen returned different content")
+	}
+}
+
+func TestPruneBlobs(t *testing.T) {
+	ctx := context.Background()
+	afs, blobDir := openExternalTestDB(t, 1)
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/a.bin", []byte("first"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/b.bin", []byte("second"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.Unlink(ctx, "/a.bin"); err != nil {
+		t.Fatalf("Unlink failed: %v", err)
+	}
+
+	// Fresh blobs are kept
+	n, err := afs.PruneBlobs(ctx, &PruneOptions{MinAge: time.Hour})
+	if err != nil {
+		t.Fatalf("PruneBlobs failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("removed = %d, want 0", n)
+	}
+
+	n, err = afs.PruneBlobs(ctx, nil)
+	if err != nil {
+		t.Fatalf("PruneBlobs failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("removed = %d, want 1", n)
+	}
+	if got := countBlobs(t, blobDir); got != 1 {
+		t.Errorf("blobs = %d, want 1", got)
+	}
+	if _, err := afs.FS.ReadFile(ctx, "/b.bin"); err != nil {
+		t.Errorf("ReadFile of live file failed: %v", err)
+	}
+}
+
+func TestExternalChunkMismatch(t *testing.T) {
+	ctx := context.Background()
+	afs, blobDir := openExternalTestDB(t, 1024)
+	defer afs.Close()
+
+	data := bytes.Repeat([]byte("x"), 1024)
+	if err := afs.FS.WriteFile(ctx, "/f.bin", data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	var blob string
+	filepath.Walk(blobDir, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			blob = p
+		}
+		return nil
+	})
+	if blob == "" {
+		t.Fatal("no blob written")
+	}
+	if err := os.WriteFile(blob, bytes.Repeat([]byte("y"), 1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := afs.FS.ReadFile(ctx, "/f.bin"); !errors.Is(err, ErrChunkMismatch) {
+			t.Fatalf("read %d: err = %v, want ErrChunkMismatch", i, err)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package agentfs
 import (
 	"context"
 	"io"
-	"time"
 )
 
 // File represents an open file handle for read/write operations.
@@ -22,6 +21,7 @@ type File struct {
 	flags  int
 	offset int64           // Current file position for Read/Write
 	ctx    context.Context // Context for streaming operations
+	handle *fileHandle     // Entry in the open-handle table, shared with copies
 }
 
 // Compile-time interface checks
@@ -46,6 +46,7 @@ func (f *File) WithContext(ctx context.Context) *File {
 		flags:  f.flags,
 		offset: f.offset,
 		ctx:    ctx,
+		handle: f.handle,
 	}
 }
 
@@ -145,6 +146,12 @@ func (f *File) Offset() int64 {
 //
 // Unlike Read, Pread does not modify the file's current offset.
 func (f *File) Pread(ctx context.Context, buf []byte, offset int64) (int, error) {
+	ctx, done, err := f.fs.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
 	if len(buf) == 0 {
 		return 0, nil
 	}
@@ -164,28 +171,22 @@ func (f *File) Pread(ctx context.Context, buf []byte, offset int64) (int, error)
 		length = stats.Size - offset
 	}
 
-	chunkSize := int64(f.fs.chunkSize)
+	chunkSize, err := f.fs.chunkSizeOf(ctx, f.ino)
+	if err != nil {
+		return 0, err
+	}
 	startChunk := offset / chunkSize
 	endChunk := (offset + length - 1) / chunkSize
 
-	rows, err := f.fs.db.QueryContext(ctx, queryChunkRange, f.ino, startChunk, endChunk)
+	stored, err := f.fs.readChunks(ctx, f.ino, startChunk, endChunk)
 	if err != nil {
 		return 0, err
 	}
-	defer rows.Close()
 
 	// Collect chunks
-	chunks := make(map[int64][]byte)
-	for rows.Next() {
-		var chunkIndex int64
-		var data []byte
-		if err := rows.Scan(&chunkIndex, &data); err != nil {
-			return 0, err
-		}
-		chunks[chunkIndex] = data
-	}
-	if err := rows.Err(); err != nil {
-		return 0, err
+	chunks := make(map[int64][]byte, len(stored))
+	for _, c := range stored {
+		chunks[c.index] = c.data
 	}
 
 	// Extract requested bytes
@@ -218,7 +219,7 @@ func (f *File) Pread(ctx context.Context, buf []byte, offset int64) (int, error)
 	}
 
 	// Update atime
-	now := time.Now()
+	now := f.fs.now()
 	f.fs.db.ExecContext(ctx, updateInodeAtime, now.Unix(), int64(now.Nanosecond()), f.ino)
 
 	return bytesRead, nil
@@ -229,6 +230,12 @@ func (f *File) Pread(ctx context.Context, buf []byte, offset int64) (int, error)
 //
 // Unlike Write, Pwrite does not modify the file's current offset.
 func (f *File) Pwrite(ctx context.Context, data []byte, offset int64) (int, error) {
+	ctx, done, err := f.fs.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
 	if len(data) == 0 {
 		return 0, nil
 	}
@@ -238,7 +245,10 @@ func (f *File) Pwrite(ctx context.Context, data []byte, offset int64) (int, erro
 		return 0, err
 	}
 
-	chunkSize := int64(f.fs.chunkSize)
+	chunkSize, err := f.fs.chunkSizeOf(ctx, f.ino)
+	if err != nil {
+		return 0, err
+	}
 	endOffset := offset + int64(len(data))
 
 	// Calculate affected chunks
@@ -248,20 +258,21 @@ func (f *File) Pwrite(ctx context.Context, data []byte, offset int64) (int, erro
 	// Read existing chunks that we'll partially overwrite
 	existingChunks := make(map[int64][]byte)
 
-	rows, err := f.fs.db.QueryContext(ctx, queryChunkRange, f.ino, startChunk, endChunk)
+	stored, err := f.fs.readChunks(ctx, f.ino, startChunk, endChunk)
 	if err != nil {
 		return 0, err
 	}
-	for rows.Next() {
-		var chunkIndex int64
-		var chunkData []byte
-		if err := rows.Scan(&chunkIndex, &chunkData); err != nil {
-			rows.Close()
+	for _, c := range stored {
+		existingChunks[c.index] = c.data
+	}
+
+	newSize := stats.Size
+	if endOffset > stats.Size {
+		newSize = endOffset
+		if err := f.fs.checkQuota(ctx, "write", f.path, f.ino, newSize); err != nil {
 			return 0, err
 		}
-		existingChunks[chunkIndex] = chunkData
 	}
-	rows.Close()
 
 	// Write data
 	bytesWritten := 0
@@ -300,7 +311,7 @@ func (f *File) Pwrite(ctx context.Context, data []byte, offset int64) (int, erro
 		copy(chunk[offsetInChunk:], data[dataOffset:dataOffset+int(bytesToWrite)])
 
 		// Store chunk
-		if _, err := f.fs.db.ExecContext(ctx, insertChunk, f.ino, chunkIndex, chunk); err != nil {
+		if err := f.fs.writeChunk(ctx, f.ino, chunkIndex, chunk, newSize); err != nil {
 			return bytesWritten, err
 		}
 
@@ -310,37 +321,45 @@ func (f *File) Pwrite(ctx context.Context, data []byte, offset int64) (int, erro
 	}
 
 	// Update inode size if we extended the file
-	newSize := stats.Size
-	if endOffset > stats.Size {
-		newSize = endOffset
-	}
-
-	now := time.Now()
+	now := f.fs.now()
 	if _, err := f.fs.db.ExecContext(ctx, updateInodeSize, newSize, now.Unix(), int64(now.Nanosecond()), f.ino); err != nil {
 		return bytesWritten, err
 	}
+	if err := f.fs.checkFile(ctx, "write", f.path, f.ino); err != nil {
+		return bytesWritten, err
+	}
 
+	f.fs.emit(EventFileWritten, f.path, "")
 	return bytesWritten, nil
 }
 
 // Truncate sets the file size.
 func (f *File) Truncate(ctx context.Context, size int64) error {
+	ctx, done, err := f.fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	stats, err := f.fs.statInode(ctx, f.ino)
 	if err != nil {
 		return err
 	}
 
-	chunkSize := int64(f.fs.chunkSize)
+	chunkSize, err := f.fs.chunkSizeOf(ctx, f.ino)
+	if err != nil {
+		return err
+	}
 
 	if size < stats.Size {
 		// Shrinking: delete chunks beyond new size
 		lastChunk := size / chunkSize
-		if size%chunkSize == 0 && size > 0 {
+		if size%chunkSize == 0 {
 			lastChunk--
 		}
 
 		// Delete chunks beyond the new last chunk
-		if _, err := f.fs.db.ExecContext(ctx, deleteChunksFromIndex, f.ino, lastChunk+1); err != nil {
+		if err := f.fs.deleteChunks(ctx, f.ino, lastChunk+1); err != nil {
 			return err
 		}
 
@@ -349,12 +368,14 @@ func (f *File) Truncate(ctx context.Context, size int64) error {
 			offsetInLastChunk := size % chunkSize
 			if offsetInLastChunk > 0 {
 				// Read the last chunk
-				var chunk []byte
-				err := f.fs.db.QueryRowContext(ctx, "SELECT data FROM fs_data WHERE ino = ? AND chunk_index = ?", f.ino, lastChunk).Scan(&chunk)
-				if err == nil && int64(len(chunk)) > offsetInLastChunk {
+				stored, err := f.fs.readChunks(ctx, f.ino, lastChunk, lastChunk)
+				if err != nil {
+					return err
+				}
+				if len(stored) == 1 && int64(len(stored[0].data)) > offsetInLastChunk {
 					// Truncate and rewrite
-					chunk = chunk[:offsetInLastChunk]
-					if _, err := f.fs.db.ExecContext(ctx, insertChunk, f.ino, lastChunk, chunk); err != nil {
+					chunk := stored[0].data[:offsetInLastChunk]
+					if err := f.fs.writeChunk(ctx, f.ino, lastChunk, chunk, size); err != nil {
 						return err
 					}
 				}
@@ -363,13 +384,20 @@ func (f *File) Truncate(ctx context.Context, size int64) error {
 	} else if size > stats.Size {
 		// Extending: just update size (sparse file behavior)
 		// The missing chunks will be treated as zeros on read
+		if err := f.fs.checkQuota(ctx, "truncate", f.path, f.ino, size); err != nil {
+			return err
+		}
 	}
 
-	now := time.Now()
+	now := f.fs.now()
 	if _, err := f.fs.db.ExecContext(ctx, updateInodeSize, size, now.Unix(), int64(now.Nanosecond()), f.ino); err != nil {
 		return err
 	}
+	if err := f.fs.checkFile(ctx, "truncate", f.path, f.ino); err != nil {
+		return err
+	}
 
+	f.fs.emit(EventFileWritten, f.path, "")
 	return nil
 }
 
@@ -400,10 +428,10 @@ func (f *File) Size() (int64, error) {
 	return stats.Size, nil
 }
 
-// Close closes the file handle.
+// Close closes the file handle, removing it from the open-handle table.
+// Closing a handle more than once is a no-op.
 func (f *File) Close() error {
-	// Currently no resources to release
-	// In the future, this could handle things like advisory locks
+	f.handle.close()
 	return nil
 }
 
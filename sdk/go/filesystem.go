@@ -3,19 +3,43 @@ package agentfs
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"path"
 	"strings"
-	"time"
+
+	"github.com/tursodatabase/agentfs/sdk/go/internal/cache"
 )
 
 // Filesystem provides POSIX-like file operations backed by SQLite.
 type Filesystem struct {
-	db        *sql.DB
-	chunkSize int
+	db           dbtx
+	conn         sqlConn // nil when db is a transaction (see inTx)
+	chunkSize    int
+	chunkSizeFor func(path string) int // nil unless AgentFSOptions.ChunkSizeFor is set
+	fileChunks   bool                  // AgentFSOptions.FileChunkSizes
+	blobs        *blobStore            // nil unless external storage is configured or in use
+	life         *lifecycle
+	gate         *writeGate // orders write transactions by priority
+	clock        Clock
+	ids          IDGenerator
+	paths        PathOptions
+	system       bool // may write to the reserved directory (see systemFS)
+	handles      *handleTable
+	events       *eventBus
+	pending      *[]Event // events held until the transaction commits (see inTx)
+	previews     *previewers
+	policy       *policyCache // nil means no quota
+	changes      *fsChangeLog // nil when read-only (see Watch)
+	lookups      *cache.Bloom // nil unless AgentFSOptions.LookupFilter is set
+	coalesce     *coalescer   // nil unless AgentFSOptions.Coalesce is set, and in transactions
+	optimizer    *optimizer   // nil when read-only, and in transactions
+	strict       StrictMode   // See AgentFSOptions.Strict
 }
 
-// ChunkSize returns the configured chunk size for file data.
+// ChunkSize returns the configured chunk size for file data. Files given
+// their own chunk size are stored in chunks of that (see FileChunkSize).
 func (fs *Filesystem) ChunkSize() int {
 	return fs.chunkSize
 }
@@ -24,7 +48,16 @@ func (fs *Filesystem) ChunkSize() int {
 // If the path refers to a symlink, Stat follows the symlink and returns
 // the target's stats. Use Lstat to get the symlink's own stats.
 func (fs *Filesystem) Stat(ctx context.Context, p string) (*Stats, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "stat", p)
+	if err != nil {
+		return nil, err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
@@ -36,7 +69,16 @@ func (fs *Filesystem) Stat(ctx context.Context, p string) (*Stats, error) {
 
 // Readdir returns the names of entries in a directory.
 func (fs *Filesystem) Readdir(ctx context.Context, p string) ([]string, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "readdir", p)
+	if err != nil {
+		return nil, err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
@@ -72,7 +114,16 @@ func (fs *Filesystem) Readdir(ctx context.Context, p string) ([]string, error) {
 
 // ReaddirPlus returns directory entries with their stats (optimized batch operation).
 func (fs *Filesystem) ReaddirPlus(ctx context.Context, p string) ([]DirEntry, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "readdir", p)
+	if err != nil {
+		return nil, err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
@@ -108,9 +159,97 @@ func (fs *Filesystem) ReaddirPlus(ctx context.Context, p string) ([]DirEntry, er
 	return entries, rows.Err()
 }
 
+// ReaddirPage returns a page of the entries of a directory with their
+// stats, in name order. Unlike Readdir and ReaddirPlus, it holds only one
+// page in memory, and each page costs the same however large the directory
+// is, so it suits directories of millions of entries. Entries created or
+// removed while paging are seen if they sort after the current page.
+//
+// Example:
+//
+//	opts := agentfs.ReaddirOptions{}
+//	for {
+//	    page, err := afs.FS.ReaddirPage(ctx, "/crawl/pages", opts)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    for _, e := range page.Entries {
+//	        index(e.Name, e.Stats.Size)
+//	    }
+//	    if page.Next == "" {
+//	        break
+//	    }
+//	    opts.After = page.Next
+//	}
+func (fs *Filesystem) ReaddirPage(ctx context.Context, p string, opts ReaddirOptions) (*DirPage, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "readdir", p)
+	if err != nil {
+		return nil, err
+	}
+
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify it's a directory
+	stats, err := fs.statInode(ctx, ino)
+	if err != nil {
+		return nil, err
+	}
+	if !stats.IsDir() {
+		return nil, ErrNotDir("readdir", p)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultReaddirPageSize
+	}
+	// One extra row tells whether there is a next page
+	rows, err := fs.db.QueryContext(ctx, queryDentriesPlusPage, ino, opts.After, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &DirPage{}
+	for rows.Next() {
+		var name string
+		var s Stats
+		if err := rows.Scan(&name, &s.Ino, &s.Mode, &s.Nlink, &s.UID, &s.GID, &s.Size, &s.Atime, &s.Mtime, &s.Ctime, &s.Rdev,
+			&s.AtimeNsec, &s.MtimeNsec, &s.CtimeNsec); err != nil {
+			return nil, err
+		}
+		page.Entries = append(page.Entries, DirEntry{Name: name, Stats: &s})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		page.Next = page.Entries[limit-1].Name
+	}
+	return page, nil
+}
+
 // Mkdir creates a directory.
 func (fs *Filesystem) Mkdir(ctx context.Context, p string, mode int64) error {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "mkdir", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return ErrExist("mkdir", p)
 	}
@@ -143,7 +282,7 @@ func (fs *Filesystem) Mkdir(ctx context.Context, p string, mode int64) error {
 	}
 
 	// Create inode
-	now := time.Now()
+	now := fs.now()
 	nowSec := now.Unix()
 	nowNsec := int64(now.Nanosecond())
 	dirMode := S_IFDIR | (mode & 0o777)
@@ -155,6 +294,7 @@ func (fs *Filesystem) Mkdir(ctx context.Context, p string, mode int64) error {
 	}
 
 	// Create dentry
+	fs.noteDentry(parentIno, name)
 	if _, err := fs.db.ExecContext(ctx, insertDentry, name, parentIno, ino); err != nil {
 		return err
 	}
@@ -164,12 +304,22 @@ func (fs *Filesystem) Mkdir(ctx context.Context, p string, mode int64) error {
 		return err
 	}
 
+	fs.emit(EventDirCreated, p, "")
 	return nil
 }
 
 // MkdirAll creates a directory and all parent directories as needed.
 func (fs *Filesystem) MkdirAll(ctx context.Context, p string, mode int64) error {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "mkdir", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return nil
 	}
@@ -199,7 +349,19 @@ func (fs *Filesystem) MkdirAll(ctx context.Context, p string, mode int64) error
 
 // ReadFile reads the entire contents of a file.
 func (fs *Filesystem) ReadFile(ctx context.Context, p string) ([]byte, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "read", p)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := fs.coalesce.held(p); ok {
+		return data, nil
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
@@ -215,26 +377,18 @@ func (fs *Filesystem) ReadFile(ctx context.Context, p string) ([]byte, error) {
 	}
 
 	// Read all chunks
-	rows, err := fs.db.QueryContext(ctx, queryChunksByIno, ino)
+	chunks, err := fs.readChunks(ctx, ino, 0, math.MaxInt64)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var data []byte
-	for rows.Next() {
-		var chunk []byte
-		if err := rows.Scan(&chunk); err != nil {
-			return nil, err
-		}
-		data = append(data, chunk...)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	for _, c := range chunks {
+		data = append(data, c.data...)
 	}
 
 	// Update atime
-	now := time.Now()
+	now := fs.now()
 	fs.db.ExecContext(ctx, updateInodeAtime, now.Unix(), int64(now.Nanosecond()), ino)
 
 	return data, nil
@@ -242,26 +396,60 @@ func (fs *Filesystem) ReadFile(ctx context.Context, p string) ([]byte, error) {
 
 // WriteFile writes data to a file, creating it if it doesn't exist.
 func (fs *Filesystem) WriteFile(ctx context.Context, p string, data []byte, mode int64) error {
-	p = normalizePath(p)
-
-	parentPath, name := path.Split(p)
-	parentPath = normalizePath(parentPath)
-
-	if err := validateName("write", name); err != nil {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
 		return err
 	}
+	defer done()
 
-	// Ensure parent directory exists
-	if err := fs.MkdirAll(ctx, parentPath, 0o755); err != nil {
+	p, err = fs.cleanPath(ctx, "write", p)
+	if err != nil {
 		return err
 	}
+	if fs.coalesce != nil {
+		return fs.coalesce.write(ctx, p, data, mode)
+	}
 
-	parentIno, err := fs.resolvePathFollow(ctx, parentPath, true)
-	if err != nil {
-		return err
+	var parentIno int64
+	var name string
+	for depth := 0; ; depth++ {
+		var parentPath string
+		parentPath, name = path.Split(p)
+		parentPath = normalizePath(parentPath)
+
+		if err := validateName("write", name); err != nil {
+			return err
+		}
+
+		// Ensure parent directory exists
+		if err := fs.MkdirAll(ctx, parentPath, 0o755); err != nil {
+			return err
+		}
+
+		parentIno, err = fs.resolvePathFollow(ctx, parentPath, true)
+		if err != nil {
+			return err
+		}
+
+		// Writing through a symlink writes its target, as open(2) does
+		linkIno, linkMode, err := fs.lookupDentryWithMode(ctx, parentIno, name)
+		if err != nil || linkMode&S_IFMT != S_IFLNK {
+			break
+		}
+		if depth >= MaxSymlinkDepth {
+			return ErrLoop("write", p)
+		}
+		target, err := fs.readSymlinkTarget(ctx, linkIno)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(target, "/") {
+			target = parentPath + "/" + target
+		}
+		p = normalizePath(target)
 	}
 
-	now := time.Now()
+	now := fs.now()
 	nowSec := now.Unix()
 	nowNsec := int64(now.Nanosecond())
 	fileMode := S_IFREG | (mode & 0o777)
@@ -277,9 +465,18 @@ func (fs *Filesystem) WriteFile(ctx context.Context, p string, data []byte, mode
 		if stats.IsDir() {
 			return ErrIsDir("write", p)
 		}
+		if err := fs.checkQuota(ctx, "write", p, existingIno, int64(len(data))); err != nil {
+			return err
+		}
+		if err := fs.saveVersion(ctx, p, existingIno); err != nil {
+			return err
+		}
 
 		// Delete existing data
-		if _, err := fs.db.ExecContext(ctx, deleteChunksByIno, existingIno); err != nil {
+		if err := fs.deleteChunks(ctx, existingIno, 0); err != nil {
+			return err
+		}
+		if err := fs.chooseChunkSize(ctx, p, existingIno); err != nil {
 			return err
 		}
 
@@ -292,11 +489,18 @@ func (fs *Filesystem) WriteFile(ctx context.Context, p string, data []byte, mode
 		if _, err := fs.db.ExecContext(ctx, updateInodeSize, len(data), nowSec, nowNsec, existingIno); err != nil {
 			return err
 		}
+		if err := fs.checkFile(ctx, "write", p, existingIno); err != nil {
+			return err
+		}
 
+		fs.emit(EventFileWritten, p, "")
 		return nil
 	}
 
 	// Create new file
+	if err := fs.checkQuota(ctx, "write", p, 0, int64(len(data))); err != nil {
+		return err
+	}
 	var ino int64
 	err = fs.db.QueryRowContext(ctx, insertInode, fileMode, 0, 0, len(data), nowSec, nowSec, nowSec, 0, nowNsec, nowNsec, nowNsec).Scan(&ino)
 	if err != nil {
@@ -304,6 +508,7 @@ func (fs *Filesystem) WriteFile(ctx context.Context, p string, data []byte, mode
 	}
 
 	// Create dentry
+	fs.noteDentry(parentIno, name)
 	if _, err := fs.db.ExecContext(ctx, insertDentry, name, parentIno, ino); err != nil {
 		return err
 	}
@@ -314,16 +519,32 @@ func (fs *Filesystem) WriteFile(ctx context.Context, p string, data []byte, mode
 	}
 
 	// Write data chunks
+	if err := fs.chooseChunkSize(ctx, p, ino); err != nil {
+		return err
+	}
 	if err := fs.writeChunks(ctx, ino, data); err != nil {
 		return err
 	}
+	if err := fs.checkFile(ctx, "write", p, ino); err != nil {
+		return err
+	}
 
+	fs.emit(EventFileWritten, p, "")
 	return nil
 }
 
 // Unlink removes a file.
 func (fs *Filesystem) Unlink(ctx context.Context, p string) error {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "unlink", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return ErrRootOperation("unlink", p)
 	}
@@ -366,23 +587,42 @@ func (fs *Filesystem) Unlink(ctx context.Context, p string) error {
 	}
 	if newStats.Nlink == 0 {
 		// Delete inode and data
-		if _, err := fs.db.ExecContext(ctx, deleteChunksByIno, ino); err != nil {
+		if err := fs.deleteChunks(ctx, ino, 0); err != nil {
 			return err
 		}
 		if _, err := fs.db.ExecContext(ctx, deleteSymlink, ino); err != nil {
 			return err
 		}
+		if _, err := fs.db.ExecContext(ctx, metaDeleteByIno, ino); err != nil {
+			return err
+		}
+		if _, err := fs.db.ExecContext(ctx, previewDeleteByIno, ino); err != nil {
+			return err
+		}
 		if _, err := fs.db.ExecContext(ctx, deleteInode, ino); err != nil {
 			return err
 		}
 	}
+	if err := fs.checkFile(ctx, "unlink", p, ino); err != nil {
+		return err
+	}
 
+	fs.emit(EventFileRemoved, p, "")
 	return nil
 }
 
 // Rmdir removes an empty directory.
 func (fs *Filesystem) Rmdir(ctx context.Context, p string) error {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "rmdir", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return ErrRootOperation("rmdir", p)
 	}
@@ -409,11 +649,11 @@ func (fs *Filesystem) Rmdir(ctx context.Context, p string) error {
 	}
 
 	// Check if directory is empty
-	var count int
-	if err := fs.db.QueryRowContext(ctx, countDentriesByParent, ino).Scan(&count); err != nil {
+	var hasEntries bool
+	if err := fs.db.QueryRowContext(ctx, hasDentriesByParent, ino).Scan(&hasEntries); err != nil {
 		return err
 	}
-	if count > 0 {
+	if hasEntries {
 		return ErrNotEmpty("rmdir", p)
 	}
 
@@ -426,17 +666,33 @@ func (fs *Filesystem) Rmdir(ctx context.Context, p string) error {
 	if _, err := fs.db.ExecContext(ctx, decrementNlink, ino); err != nil {
 		return err
 	}
+	if _, err := fs.db.ExecContext(ctx, metaDeleteByIno, ino); err != nil {
+		return err
+	}
 	if _, err := fs.db.ExecContext(ctx, deleteInode, ino); err != nil {
 		return err
 	}
 
+	fs.emit(EventFileRemoved, p, "")
 	return nil
 }
 
 // Rename moves or renames a file or directory.
 func (fs *Filesystem) Rename(ctx context.Context, oldPath, newPath string) error {
-	oldPath = normalizePath(oldPath)
-	newPath = normalizePath(newPath)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	oldPath, err = fs.cleanPath(ctx, "rename", oldPath)
+	if err != nil {
+		return err
+	}
+	newPath, err = fs.cleanPath(ctx, "rename", newPath)
+	if err != nil {
+		return err
+	}
 
 	if oldPath == "/" || newPath == "/" {
 		return ErrRootOperation("rename", oldPath)
@@ -457,70 +713,175 @@ func (fs *Filesystem) Rename(ctx context.Context, oldPath, newPath string) error
 		return ErrInvalidRename("rename", oldPath)
 	}
 
-	oldParentIno, err := fs.resolvePathFollow(ctx, oldParentPath, true)
+	// Run atomically so a failure never leaves the destination removed
+	// without the source being moved
+	return fs.inTx(ctx, func(tfs *Filesystem) error {
+		oldParentIno, err := tfs.resolvePathFollow(ctx, oldParentPath, true)
+		if err != nil {
+			return err
+		}
+
+		ino, err := tfs.lookupDentry(ctx, oldParentIno, oldName)
+		if err != nil {
+			return ErrNoent("rename", oldPath)
+		}
+
+		// Ensure new parent exists
+		if err := tfs.MkdirAll(ctx, newParentPath, 0o755); err != nil {
+			return err
+		}
+
+		newParentIno, err := tfs.resolvePathFollow(ctx, newParentPath, true)
+		if err != nil {
+			return err
+		}
+
+		// Check if destination exists
+		existingIno, err := tfs.lookupDentry(ctx, newParentIno, newName)
+		if err == nil {
+			// Destination exists, remove it
+			existingStats, err := tfs.statInode(ctx, existingIno)
+			if err != nil {
+				return err
+			}
+
+			sourceStats, err := tfs.statInode(ctx, ino)
+			if err != nil {
+				return err
+			}
+
+			if existingStats.IsDir() && !sourceStats.IsDir() {
+				return ErrIsDir("rename", newPath)
+			}
+			if !existingStats.IsDir() && sourceStats.IsDir() {
+				return ErrNotDir("rename", newPath)
+			}
+
+			if existingStats.IsDir() {
+				if err := tfs.Rmdir(ctx, newPath); err != nil {
+					return err
+				}
+			} else {
+				if err := tfs.Unlink(ctx, newPath); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Update dentry
+		tfs.noteDentry(newParentIno, newName)
+		if _, err := tfs.db.ExecContext(ctx, updateDentryParent, newParentIno, newName, oldParentIno, oldName); err != nil {
+			return err
+		}
+
+		tfs.emit(EventFileRenamed, newPath, oldPath)
+		return nil
+	})
+}
+
+// MoveTree atomically moves a file or an entire directory subtree from src to dst.
+//
+// Unlike Rename, MoveTree never replaces an existing destination: it returns
+// EEXIST instead. Paths are not stored per row (entries reference their parent
+// inode), so moving a subtree rewrites exactly one directory entry regardless of
+// how many descendants it has. Missing parent directories of dst are created.
+func (fs *Filesystem) MoveTree(ctx context.Context, src, dst string) error {
+	ctx, done, err := fs.life.begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer done()
 
-	ino, err := fs.lookupDentry(ctx, oldParentIno, oldName)
+	src, err = fs.cleanPath(ctx, "move", src)
 	if err != nil {
-		return ErrNoent("rename", oldPath)
+		return err
 	}
-
-	// Ensure new parent exists
-	if err := fs.MkdirAll(ctx, newParentPath, 0o755); err != nil {
+	dst, err = fs.cleanPath(ctx, "move", dst)
+	if err != nil {
 		return err
 	}
 
-	newParentIno, err := fs.resolvePathFollow(ctx, newParentPath, true)
-	if err != nil {
+	if src == "/" || dst == "/" {
+		return ErrRootOperation("move", src)
+	}
+	if src == dst {
+		return ErrExist("move", dst)
+	}
+	if strings.HasPrefix(dst, src+"/") {
+		return ErrInvalidRename("move", src)
+	}
+
+	srcParentPath, srcName := path.Split(src)
+	srcParentPath = normalizePath(srcParentPath)
+
+	dstParentPath, dstName := path.Split(dst)
+	dstParentPath = normalizePath(dstParentPath)
+
+	if err := validateName("move", dstName); err != nil {
 		return err
 	}
 
-	// Check if destination exists
-	existingIno, err := fs.lookupDentry(ctx, newParentIno, newName)
-	if err == nil {
-		// Destination exists, remove it
-		existingStats, err := fs.statInode(ctx, existingIno)
+	return fs.inTx(ctx, func(tfs *Filesystem) error {
+		srcParentIno, err := tfs.resolvePathFollow(ctx, srcParentPath, true)
 		if err != nil {
 			return err
 		}
+		ino, err := tfs.lookupDentry(ctx, srcParentIno, srcName)
+		if err != nil {
+			return ErrNoent("move", src)
+		}
 
-		sourceStats, err := fs.statInode(ctx, ino)
+		if err := tfs.MkdirAll(ctx, dstParentPath, 0o755); err != nil {
+			return err
+		}
+		dstParentIno, err := tfs.resolvePathFollow(ctx, dstParentPath, true)
 		if err != nil {
 			return err
 		}
 
-		if existingStats.IsDir() && !sourceStats.IsDir() {
-			return ErrIsDir("rename", newPath)
+		// Symlinks in dst's parent path may still lead back into the moved subtree
+		inside, err := tfs.isAncestor(ctx, ino, dstParentIno)
+		if err != nil {
+			return err
 		}
-		if !existingStats.IsDir() && sourceStats.IsDir() {
-			return ErrNotDir("rename", newPath)
+		if inside {
+			return ErrInvalidRename("move", src)
 		}
 
-		if existingStats.IsDir() {
-			if err := fs.Rmdir(ctx, newPath); err != nil {
-				return err
-			}
-		} else {
-			if err := fs.Unlink(ctx, newPath); err != nil {
-				return err
+		tfs.noteDentry(dstParentIno, dstName)
+		if _, err := tfs.db.ExecContext(ctx, updateDentryParent, dstParentIno, dstName, srcParentIno, srcName); err != nil {
+			if isUniqueConstraintError(err) {
+				return ErrExist("move", dst)
 			}
+			return err
 		}
-	}
 
-	// Update dentry
-	if _, err := fs.db.ExecContext(ctx, updateDentryParent, newParentIno, newName, oldParentIno, oldName); err != nil {
-		return err
-	}
+		now := fs.now()
+		if _, err := tfs.db.ExecContext(ctx, updateInodeCtime, now.Unix(), int64(now.Nanosecond()), ino); err != nil {
+			return err
+		}
 
-	return nil
+		tfs.emit(EventFileRenamed, dst, src)
+		return nil
+	})
 }
 
 // Link creates a hard link.
 func (fs *Filesystem) Link(ctx context.Context, existingPath, newPath string) error {
-	existingPath = normalizePath(existingPath)
-	newPath = normalizePath(newPath)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	existingPath, err = fs.cleanPath(ctx, "link", existingPath)
+	if err != nil {
+		return err
+	}
+	newPath, err = fs.cleanPath(ctx, "link", newPath)
+	if err != nil {
+		return err
+	}
 
 	_, newName := path.Split(newPath)
 	if err := validateName("link", newName); err != nil {
@@ -560,6 +921,7 @@ func (fs *Filesystem) Link(ctx context.Context, existingPath, newPath string) er
 	}
 
 	// Create dentry
+	fs.noteDentry(newParentIno, newName)
 	if _, err := fs.db.ExecContext(ctx, insertDentry, newName, newParentIno, ino); err != nil {
 		return err
 	}
@@ -568,13 +930,26 @@ func (fs *Filesystem) Link(ctx context.Context, existingPath, newPath string) er
 	if _, err := fs.db.ExecContext(ctx, incrementNlink, ino); err != nil {
 		return err
 	}
+	if err := fs.checkFile(ctx, "link", newPath, ino); err != nil {
+		return err
+	}
 
+	fs.emit(EventFileCreated, newPath, "")
 	return nil
 }
 
 // Symlink creates a symbolic link.
 func (fs *Filesystem) Symlink(ctx context.Context, target, linkPath string) error {
-	linkPath = normalizePath(linkPath)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	linkPath, err = fs.cleanPath(ctx, "symlink", linkPath)
+	if err != nil {
+		return err
+	}
 
 	parentPath, name := path.Split(linkPath)
 	parentPath = normalizePath(parentPath)
@@ -583,6 +958,22 @@ func (fs *Filesystem) Symlink(ctx context.Context, target, linkPath string) erro
 		return err
 	}
 
+	// A link into the reserved directory would let writes through it
+	// bypass the reserved check
+	if !fs.system {
+		resolved := target
+		if !strings.HasPrefix(resolved, "/") {
+			resolved = parentPath + "/" + resolved
+		}
+		err := fs.checkWritable(ctx, "write", normalizePath(resolved))
+		if errors.Is(err, ErrReserved) {
+			return &reservedError{&FSError{Code: EPERM, Syscall: "symlink", Path: linkPath, Message: "target is reserved for internal use"}}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	// Ensure parent exists
 	if err := fs.MkdirAll(ctx, parentPath, 0o755); err != nil {
 		return err
@@ -600,7 +991,7 @@ func (fs *Filesystem) Symlink(ctx context.Context, target, linkPath string) erro
 	}
 
 	// Create inode
-	now := time.Now()
+	now := fs.now()
 	nowSec := now.Unix()
 	nowNsec := int64(now.Nanosecond())
 	var ino int64
@@ -615,6 +1006,7 @@ func (fs *Filesystem) Symlink(ctx context.Context, target, linkPath string) erro
 	}
 
 	// Create dentry
+	fs.noteDentry(parentIno, name)
 	if _, err := fs.db.ExecContext(ctx, insertDentry, name, parentIno, ino); err != nil {
 		return err
 	}
@@ -624,13 +1016,23 @@ func (fs *Filesystem) Symlink(ctx context.Context, target, linkPath string) erro
 		return err
 	}
 
+	fs.emit(EventFileCreated, linkPath, "")
 	return nil
 }
 
 // Readlink returns the target of a symbolic link.
 // Intermediate symlinks in the path are followed, but the final component is not.
 func (fs *Filesystem) Readlink(ctx context.Context, p string) (string, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "readlink", p)
+	if err != nil {
+		return "", err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, false)
 	if err != nil {
@@ -652,7 +1054,16 @@ func (fs *Filesystem) Readlink(ctx context.Context, p string) (string, error) {
 // Intermediate symlinks in the path are still followed.
 // If the path refers to a symlink, Lstat returns the symlink's own stats.
 func (fs *Filesystem) Lstat(ctx context.Context, p string) (*Stats, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "lstat", p)
+	if err != nil {
+		return nil, err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, false)
 	if err != nil {
@@ -664,6 +1075,12 @@ func (fs *Filesystem) Lstat(ctx context.Context, p string) (*Stats, error) {
 
 // Statfs returns aggregate filesystem statistics.
 func (fs *Filesystem) Statfs(ctx context.Context) (*FilesystemStats, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	var stats FilesystemStats
 
 	if err := fs.db.QueryRowContext(ctx, statfsInodeCount).Scan(&stats.Inodes); err != nil {
@@ -680,11 +1097,20 @@ func (fs *Filesystem) Statfs(ctx context.Context) (*FilesystemStats, error) {
 // Chown changes file ownership.
 // Pass -1 for uid or gid to leave that field unchanged.
 func (fs *Filesystem) Chown(ctx context.Context, p string, uid, gid int64) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	if uid == -1 && gid == -1 {
 		return nil
 	}
 
-	p = normalizePath(p)
+	p, err = fs.cleanPath(ctx, "chown", p)
+	if err != nil {
+		return err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
@@ -703,7 +1129,7 @@ func (fs *Filesystem) Chown(ctx context.Context, p string, uid, gid int64) error
 		args = append(args, gid)
 	}
 
-	now := time.Now()
+	now := fs.now()
 	setClauses = append(setClauses, "ctime = ?", "ctime_nsec = ?")
 	args = append(args, now.Unix(), int64(now.Nanosecond()))
 
@@ -721,7 +1147,16 @@ func (fs *Filesystem) Chown(ctx context.Context, p string, uid, gid int64) error
 // The mode parameter must include one of S_IFIFO, S_IFCHR, S_IFBLK, or S_IFSOCK.
 // The rdev parameter specifies the device number (used for character and block devices).
 func (fs *Filesystem) Mknod(ctx context.Context, p string, mode, rdev int64) error {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "mknod", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return ErrRootOperation("mknod", p)
 	}
@@ -760,7 +1195,7 @@ func (fs *Filesystem) Mknod(ctx context.Context, p string, mode, rdev int64) err
 	}
 
 	// Create inode
-	now := time.Now()
+	now := fs.now()
 	nowSec := now.Unix()
 	nowNsec := int64(now.Nanosecond())
 
@@ -771,6 +1206,7 @@ func (fs *Filesystem) Mknod(ctx context.Context, p string, mode, rdev int64) err
 	}
 
 	// Create dentry
+	fs.noteDentry(parentIno, name)
 	if _, err := fs.db.ExecContext(ctx, insertDentry, name, parentIno, ino); err != nil {
 		return err
 	}
@@ -780,12 +1216,22 @@ func (fs *Filesystem) Mknod(ctx context.Context, p string, mode, rdev int64) err
 		return err
 	}
 
+	fs.emit(EventFileCreated, p, "")
 	return nil
 }
 
 // Chmod changes file permissions.
 func (fs *Filesystem) Chmod(ctx context.Context, p string, mode int64) error {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "chmod", p)
+	if err != nil {
+		return err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
@@ -799,7 +1245,7 @@ func (fs *Filesystem) Chmod(ctx context.Context, p string, mode int64) error {
 
 	// Preserve file type, change permissions
 	newMode := (stats.Mode & S_IFMT) | (mode & 0o777)
-	now := time.Now()
+	now := fs.now()
 
 	if _, err := fs.db.ExecContext(ctx, updateInodeMode, newMode, now.Unix(), int64(now.Nanosecond()), ino); err != nil {
 		return err
@@ -824,18 +1270,27 @@ func (fs *Filesystem) UtimesNano(ctx context.Context, p string, atimeSec, atimeN
 // Each timestamp can be set to a specific value (TimeSet), the current time (TimeNow),
 // or left unchanged (TimeOmit).
 func (fs *Filesystem) Utimens(ctx context.Context, p string, atime, mtime TimeChange) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	if atime.IsOmit() && mtime.IsOmit() {
 		return nil
 	}
 
-	p = normalizePath(p)
+	p, err = fs.cleanPath(ctx, "utimens", p)
+	if err != nil {
+		return err
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
 		return err
 	}
 
-	now := time.Now()
+	now := fs.now()
 
 	var setClauses []string
 	var args []any
@@ -878,7 +1333,30 @@ func (fs *Filesystem) Utimens(ctx context.Context, p string, atime, mtime TimeCh
 
 // Open opens a file and returns a handle for read/write operations.
 func (fs *Filesystem) Open(ctx context.Context, p string, flags int) (*File, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "open", p)
+	if err != nil {
+		return nil, err
+	}
+	if flags&(O_WRONLY|O_RDWR|O_CREATE|O_TRUNC|O_APPEND) != 0 {
+		if err := fs.checkWritable(ctx, "open", p); err != nil {
+			return nil, err
+		}
+	}
+
+	if (flags&O_CREATE) != 0 && (flags&O_EXCL) != 0 {
+		_, f, err := fs.CreateExclusive(ctx, p, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		f.flags = flags
+		return f, nil
+	}
 
 	ino, err := fs.resolvePathFollow(ctx, p, true)
 	if err != nil {
@@ -906,26 +1384,37 @@ func (fs *Filesystem) Open(ctx context.Context, p string, flags int) (*File, err
 
 	if (flags & O_TRUNC) != 0 {
 		// Truncate file
-		if _, err := fs.db.ExecContext(ctx, deleteChunksByIno, ino); err != nil {
+		if err := fs.saveVersion(ctx, p, ino); err != nil {
+			return nil, err
+		}
+		if err := fs.deleteChunks(ctx, ino, 0); err != nil {
 			return nil, err
 		}
-		now := time.Now()
+		if err := fs.chooseChunkSize(ctx, p, ino); err != nil {
+			return nil, err
+		}
+		now := fs.now()
 		if _, err := fs.db.ExecContext(ctx, updateInodeSize, 0, now.Unix(), int64(now.Nanosecond()), ino); err != nil {
 			return nil, err
 		}
+		fs.emit(EventFileWritten, p, "")
 	}
 
-	return &File{
-		fs:    fs,
-		ino:   ino,
-		path:  p,
-		flags: flags,
-	}, nil
+	return fs.newFile(ino, p, flags), nil
 }
 
 // Create creates a new file and returns its stats and a file handle.
 func (fs *Filesystem) Create(ctx context.Context, p string, mode int64) (*Stats, *File, error) {
-	p = normalizePath(p)
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "create", p)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	_, name := path.Split(p)
 	if err := validateName("create", name); err != nil {
@@ -947,12 +1436,75 @@ func (fs *Filesystem) Create(ctx context.Context, p string, mode int64) (*Stats,
 		return nil, nil, err
 	}
 
-	return stats, &File{
-		fs:    fs,
-		ino:   ino,
-		path:  p,
-		flags: O_RDWR,
-	}, nil
+	return stats, fs.newFile(ino, p, O_RDWR), nil
+}
+
+// CreateExclusive atomically creates a new empty file (O_CREAT|O_EXCL semantics).
+// If any entry already exists at the path, EEXIST is returned and the existing
+// entry is left untouched, so concurrent agents racing for the same output
+// path cannot clobber each other. Parent directories are created as needed.
+func (fs *Filesystem) CreateExclusive(ctx context.Context, p string, mode int64) (*Stats, *File, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "create", p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p == "/" {
+		return nil, nil, ErrExist("create", p)
+	}
+
+	parentPath, name := path.Split(p)
+	parentPath = normalizePath(parentPath)
+
+	if err := validateName("create", name); err != nil {
+		return nil, nil, err
+	}
+
+	if err := fs.MkdirAll(ctx, parentPath, 0o755); err != nil {
+		return nil, nil, err
+	}
+
+	parentIno, err := fs.resolvePathFollow(ctx, parentPath, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fileMode := S_IFREG | (mode & 0o777)
+
+	var ino int64
+	err = fs.inTx(ctx, func(tfs *Filesystem) error {
+		var err error
+		ino, err = tfs.createNode(ctx, parentIno, name, fileMode, 0)
+		if isUniqueConstraintError(err) {
+			// The UNIQUE(parent_ino, name) constraint makes the existence check atomic
+			return ErrExist("create", p)
+		}
+		if err != nil {
+			return err
+		}
+		return tfs.chooseChunkSize(ctx, p, ino)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	fs.emit(EventFileCreated, p, "")
+
+	stats, err := fs.statInode(ctx, ino)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stats, fs.newFile(ino, p, O_RDWR), nil
+}
+
+// isUniqueConstraintError reports whether err is a SQLite UNIQUE constraint violation.
+func isUniqueConstraintError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
 
 // validateName checks that a filename component is valid.
@@ -988,6 +1540,9 @@ func splitPath(p string) []string {
 
 // lookupDentry looks up a directory entry
 func (fs *Filesystem) lookupDentry(ctx context.Context, parentIno int64, name string) (int64, error) {
+	if !fs.mayHaveDentry(parentIno, name) {
+		return 0, ErrNoent("lookup", name)
+	}
 	var ino int64
 	err := fs.db.QueryRowContext(ctx, queryDentryByParentAndName, parentIno, name).Scan(&ino)
 	if err == sql.ErrNoRows {
@@ -999,6 +1554,9 @@ func (fs *Filesystem) lookupDentry(ctx context.Context, parentIno int64, name st
 // lookupDentryWithMode looks up a directory entry and returns its inode number and mode
 // in a single query (avoids two round-trips per path component).
 func (fs *Filesystem) lookupDentryWithMode(ctx context.Context, parentIno int64, name string) (int64, int64, error) {
+	if !fs.mayHaveDentry(parentIno, name) {
+		return 0, 0, ErrNoent("lookup", name)
+	}
 	var ino, mode int64
 	err := fs.db.QueryRowContext(ctx, queryDentryWithMode, parentIno, name).Scan(&ino, &mode)
 	if err == sql.ErrNoRows {
@@ -1099,6 +1657,95 @@ func (fs *Filesystem) resolvePathFollow(ctx context.Context, p string, followLas
 	}
 }
 
+// realPath returns p with the symlinks along it resolved, as far as its
+// components exist; the rest is appended as given. The final component is
+// followed only if followLast is set.
+func (fs *Filesystem) realPath(ctx context.Context, p string, followLast bool) (string, error) {
+	p = normalizePath(p)
+	for depth := 0; ; depth++ {
+		components := splitPath(p)
+		currentIno := int64(RootIno)
+		resolved := "/"
+		followed := false
+		for i, component := range components {
+			childIno, childMode, err := fs.lookupDentryWithMode(ctx, currentIno, component)
+			if IsNotExist(err) {
+				return normalizePath(resolved + "/" + strings.Join(components[i:], "/")), nil
+			}
+			if err != nil {
+				return "", err
+			}
+			isLast := i == len(components)-1
+			if childMode&S_IFMT == S_IFLNK && (!isLast || followLast) {
+				if depth >= MaxSymlinkDepth {
+					return "", ErrLoop("resolve", p)
+				}
+				target, err := fs.readSymlinkTarget(ctx, childIno)
+				if err != nil {
+					return "", err
+				}
+				if !strings.HasPrefix(target, "/") {
+					target = resolved + "/" + target
+				}
+				p = normalizePath(target + "/" + strings.Join(components[i+1:], "/"))
+				followed = true
+				break
+			}
+			currentIno = childIno
+			resolved = path.Join(resolved, component)
+		}
+		if !followed {
+			return resolved, nil
+		}
+	}
+}
+
+// isAncestor reports whether the directory ancestor contains ino (or is ino),
+// walking parent entries up to the root.
+func (fs *Filesystem) isAncestor(ctx context.Context, ancestor, ino int64) (bool, error) {
+	for depth := 0; ino != RootIno; depth++ {
+		if ino == ancestor {
+			return true, nil
+		}
+		if depth > 4096 {
+			return false, ErrLoop("resolve", "")
+		}
+		if err := fs.db.QueryRowContext(ctx, queryParentIno, ino).Scan(&ino); err != nil {
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	return ancestor == RootIno, nil
+}
+
+// createNode inserts an empty inode with the given mode and links it into
+// the parent directory. Callers run it inside inTx so a failed dentry insert
+// does not leave an orphaned inode behind.
+func (fs *Filesystem) createNode(ctx context.Context, parentIno int64, name string, mode, rdev int64) (int64, error) {
+	now := fs.now()
+	nowSec := now.Unix()
+	nowNsec := int64(now.Nanosecond())
+
+	var ino int64
+	err := fs.db.QueryRowContext(ctx, insertInode, mode, 0, 0, 0, nowSec, nowSec, nowSec, rdev, nowNsec, nowNsec, nowNsec).Scan(&ino)
+	if err != nil {
+		return 0, err
+	}
+
+	fs.noteDentry(parentIno, name)
+	if _, err := fs.db.ExecContext(ctx, insertDentry, name, parentIno, ino); err != nil {
+		return 0, err
+	}
+
+	if _, err := fs.db.ExecContext(ctx, incrementNlink, ino); err != nil {
+		return 0, err
+	}
+
+	return ino, nil
+}
+
 // statInode retrieves stats for an inode
 func (fs *Filesystem) statInode(ctx context.Context, ino int64) (*Stats, error) {
 	var s Stats
@@ -1112,18 +1759,24 @@ func (fs *Filesystem) statInode(ctx context.Context, ino int64) (*Stats, error)
 	return &s, err
 }
 
-// writeChunks writes data in chunks to fs_data
+// writeChunks writes data in chunks to fs_data, or to the blob store for
+// files above the external storage threshold
 func (fs *Filesystem) writeChunks(ctx context.Context, ino int64, data []byte) error {
-	chunkIndex := 0
+	size, err := fs.chunkSizeOf(ctx, ino)
+	if err != nil {
+		return err
+	}
+	fileSize := int64(len(data))
+	chunkIndex := int64(0)
 	for len(data) > 0 {
-		chunkSize := fs.chunkSize
+		chunkSize := int(size)
 		if len(data) < chunkSize {
 			chunkSize = len(data)
 		}
 		chunk := data[:chunkSize]
 		data = data[chunkSize:]
 
-		if _, err := fs.db.ExecContext(ctx, insertChunk, ino, chunkIndex, chunk); err != nil {
+		if err := fs.writeChunk(ctx, ino, chunkIndex, chunk, fileSize); err != nil {
 			return err
 		}
 		chunkIndex++
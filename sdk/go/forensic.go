@@ -0,0 +1,197 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// sqliteMagic starts the header of every SQLite database file.
+const sqliteMagic = "SQLite format 3\x00"
+
+// walHeaderSize and walFrameHeaderSize lay out a -wal file: a header, then
+// frames of a frame header and one page each.
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+// ForensicReport describes the files OpenForensic found and what it had to
+// recover from them.
+type ForensicReport struct {
+	// Path is the database that was inspected.
+	Path string `json:"path"`
+	// WAL is set if a -wal file was present; its committed frames are
+	// included in what the AgentFS reads.
+	WAL bool `json:"wal"`
+	// WALFrames is the number of complete frames in the -wal file.
+	WALFrames int64 `json:"wal_frames,omitempty"`
+	// SHM is set if a -shm file was present. It is never read: SQLite
+	// rebuilds the index from the -wal file.
+	SHM bool `json:"shm"`
+	// SchemaVersion is the AgentFS schema version of the database.
+	SchemaVersion string `json:"schema_version"`
+	// Warnings lists what may be missing or damaged, e.g. a WAL-mode
+	// database copied without its -wal file.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// OpenForensic opens a copied-out agent database for inspection without
+// writing to it. The database and its -wal file, if any, are copied to a
+// temporary directory and recovered there, so the files at dbPath are only
+// ever read: no journal recovery, checkpoint, pragma, or -shm file touches
+// them. A missing -wal or -shm file is tolerated, and anything that may
+// have been lost is listed in the report's Warnings. The returned AgentFS is
+// read-only; Close removes the temporary copy.
+//
+// Example:
+//
+//	afs, report, err := agentfs.OpenForensic(ctx, "/evidence/agent-7.db")
+//	if err != nil {
+//	    return err
+//	}
+//	defer afs.Close()
+//	for _, w := range report.Warnings {
+//	    log.Printf("warning: %s", w)
+//	}
+//	calls, err := afs.Tools.GetRecent(ctx, 0, 100)
+func OpenForensic(ctx context.Context, dbPath string) (*AgentFS, *ForensicReport, error) {
+	report := &ForensicReport{Path: dbPath}
+	walMode, pageSize, err := readDBHeader(dbPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmp, err := os.MkdirTemp("", "agentfs-forensic-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create forensic copy: %w", err)
+	}
+	afs, err := openForensicCopy(ctx, dbPath, tmp, walMode, pageSize, report)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, nil, err
+	}
+	afs.tempDir = tmp
+	return afs, report, nil
+}
+
+func openForensicCopy(ctx context.Context, dbPath, tmp string, walMode bool, pageSize int64, report *ForensicReport) (*AgentFS, error) {
+	copyPath := filepath.Join(tmp, filepath.Base(dbPath))
+	if err := copyFile(dbPath, copyPath); err != nil {
+		return nil, fmt.Errorf("failed to create forensic copy: %w", err)
+	}
+
+	if st, err := os.Stat(dbPath + "-wal"); err == nil {
+		report.WAL = true
+		if err := copyFile(dbPath+"-wal", copyPath+"-wal"); err != nil {
+			return nil, fmt.Errorf("failed to create forensic copy: %w", err)
+		}
+		if n := st.Size() - walHeaderSize; n > 0 {
+			frameSize := pageSize + walFrameHeaderSize
+			report.WALFrames = n / frameSize
+			if n%frameSize != 0 {
+				report.Warnings = append(report.Warnings, "the -wal file ends in a partial frame; the transaction being written when it was copied is lost")
+			}
+		}
+		if !walMode {
+			report.Warnings = append(report.Warnings, "a -wal file is present but the database is not in WAL mode; it may be stale")
+		}
+	} else if walMode {
+		report.Warnings = append(report.Warnings, "the database is in WAL mode but has no -wal file; transactions committed since the last checkpoint may be missing")
+	}
+	if _, err := os.Stat(dbPath + "-shm"); err == nil {
+		report.SHM = true
+	}
+	if _, err := os.Stat(dbPath + "-journal"); err == nil {
+		report.Warnings = append(report.Warnings, "a rollback journal is present; the interrupted transaction it records is not applied")
+	}
+
+	// Recover the copy: reading applies the -wal file, and leaving WAL mode
+	// folds it in so the read-only copy needs neither -wal nor -shm
+	if err := recoverForensicCopy(ctx, copyPath, report); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", "file:"+copyPath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	afs, err := initAgentFS(ctx, db, copyPath, true, AgentFSOptions{ReadOnly: true})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return afs, nil
+}
+
+// recoverForensicCopy applies the -wal file of the copy at p, checks it,
+// and upgrades an older schema so this SDK can read it.
+func recoverForensicCopy(ctx context.Context, p string, report *ForensicReport) error {
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	problems, err := pragmaCheck(ctx, db, "quick_check")
+	if err != nil {
+		report.Warnings = append(report.Warnings, err.Error())
+	}
+	for _, p := range problems {
+		report.Warnings = append(report.Warnings, "integrity: "+p)
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode=DELETE"); err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("failed to apply the -wal file: %v", err))
+	}
+
+	if report.SchemaVersion, err = storedSchemaVersion(ctx, db); err != nil {
+		return err
+	}
+	switch {
+	case report.SchemaVersion == "":
+		return fmt.Errorf("%s is not an AgentFS database", report.Path)
+	case compareSchemaVersions(report.SchemaVersion, schemaVersion) < 0:
+		afs, err := OpenWith(ctx, db)
+		if err != nil {
+			return fmt.Errorf("failed to upgrade forensic copy: %w", err)
+		}
+		if err := afs.Close(); err != nil {
+			return fmt.Errorf("failed to upgrade forensic copy: %w", err)
+		}
+		report.Warnings = append(report.Warnings, fmt.Sprintf("schema %s was upgraded to %s in the copy", report.SchemaVersion, schemaVersion))
+	case compareSchemaVersions(report.SchemaVersion, schemaVersion) > 0:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("schema %s is newer than this SDK's %s; newer data is not shown", report.SchemaVersion, schemaVersion))
+	}
+	return nil
+}
+
+// readDBHeader reads the header of the SQLite database at p, reporting
+// whether it is in WAL mode and its page size.
+func readDBHeader(p string) (walMode bool, pageSize int64, err error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer f.Close()
+
+	var header [100]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return false, 0, fmt.Errorf("%s is not a SQLite database: too short", p)
+		}
+		return false, 0, fmt.Errorf("failed to read database header: %w", err)
+	}
+	if string(header[:len(sqliteMagic)]) != sqliteMagic {
+		return false, 0, fmt.Errorf("%s is not a SQLite database", p)
+	}
+	pageSize = int64(binary.BigEndian.Uint16(header[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	return header[18] == 2, pageSize, nil
+}
@@ -0,0 +1,100 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyDBFiles copies the database at src, with whichever of its -wal and
+// -shm files exist, as a crashed process would leave them.
+func copyDBFiles(t *testing.T, src, dest string, suffixes ...string) {
+	t.Helper()
+	for _, suffix := range append([]string{""}, suffixes...) {
+		data, err := os.ReadFile(src + suffix)
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(dest+suffix, data, 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+}
+
+func TestOpenForensic(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "agent.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: src})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	afs.Checkpoint(ctx, CheckpointTruncate)
+	// Committed to the WAL only, as at a crash
+	afs.FS.WriteFile(ctx, "/notes.md", []byte("in the wal"), 0o644)
+
+	evidence := filepath.Join(dir, "evidence.db")
+	copyDBFiles(t, src, evidence, "-wal") // No -shm
+	before, _ := os.ReadFile(evidence)
+	beforeWAL, _ := os.ReadFile(evidence + "-wal")
+
+	fafs, report, err := OpenForensic(ctx, evidence)
+	if err != nil {
+		t.Fatalf("OpenForensic failed: %v", err)
+	}
+	if !report.WAL || report.WALFrames == 0 || report.SHM || report.SchemaVersion != schemaVersion {
+		t.Errorf("report = %+v", report)
+	}
+	if data, err := fafs.FS.ReadFile(ctx, "/notes.md"); err != nil || string(data) != "in the wal" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if err := fafs.FS.WriteFile(ctx, "/x", []byte("x"), 0o644); err == nil {
+		t.Error("write to a forensic database succeeded")
+	}
+	if err := fafs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The evidence is untouched
+	after, _ := os.ReadFile(evidence)
+	afterWAL, _ := os.ReadFile(evidence + "-wal")
+	if !bytes.Equal(before, after) || !bytes.Equal(beforeWAL, afterWAL) {
+		t.Error("OpenForensic modified the database files")
+	}
+	if _, err := os.Stat(evidence + "-shm"); err == nil {
+		t.Error("OpenForensic created a -shm file")
+	}
+}
+
+func TestOpenForensicMissingWAL(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "agent.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: src})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	afs.Checkpoint(ctx, CheckpointTruncate)
+	afs.FS.WriteFile(ctx, "/lost.md", []byte("x"), 0o644)
+
+	evidence := filepath.Join(dir, "evidence.db")
+	copyDBFiles(t, src, evidence) // The -wal file was not copied
+	fafs, report, err := OpenForensic(ctx, evidence)
+	if err != nil {
+		t.Fatalf("OpenForensic failed: %v", err)
+	}
+	defer fafs.Close()
+	if report.WAL || len(report.Warnings) == 0 || !strings.Contains(report.Warnings[0], "no -wal file") {
+		t.Errorf("report = %+v, want a missing -wal warning", report)
+	}
+
+	os.WriteFile(filepath.Join(dir, "junk.db"), []byte("not a database at all, just some text padding it out"), 0o644)
+	if _, _, err := OpenForensic(ctx, filepath.Join(dir, "junk.db")); err == nil {
+		t.Error("OpenForensic of a non-database succeeded")
+	}
+}
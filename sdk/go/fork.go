@@ -0,0 +1,406 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrForkExists is returned by Fork when a database for the new ID already
+// exists.
+var ErrForkExists = errors.New("agentfs: fork already exists")
+
+// Fork copies the database into a new agent database newID, next to this
+// one as "<newID>.db", and opens it with the same options. The fork starts
+// with the same files, KV entries, tool call history, snapshots, and
+// policy, and diverges from there; changes to either are invisible to the
+// other.
+//
+// Fork is a copy, not a copy-on-write branch: every row of the AgentFS
+// tables is written to the new database, so it takes time and disk space
+// in proportion to the database, chunks kept in SQLite included. Only the
+// AgentFS tables (with TablePrefix, those carrying the prefix) are copied;
+// an application's own tables in the same file stay behind. The copy is
+// taken in one read transaction, so it is consistent while writers
+// continue.
+//
+// Chunks in the local external storage tier (see
+// ExternalStorageOptions.Threshold) are shared with the fork by hard links
+// rather than copied, so keep bulk data there when forks should be cheap.
+// Other tiers are shared by reference: each database records the other,
+// and PruneBlobs keeps the chunks that any database sharing a store still
+// references. The fork does not inherit Policy.BlobPruneAge.
+//
+// Example:
+//
+//	for i := range strategies {
+//	    run, err := base.Fork(ctx, fmt.Sprintf("attempt-%d", i))
+//	    if err != nil {
+//	        return err
+//	    }
+//	    go explore(ctx, run, strategies[i])
+//	}
+func (a *AgentFS) Fork(ctx context.Context, newID string) (*AgentFS, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if !validIDPattern.MatchString(newID) {
+		return nil, fmt.Errorf("invalid agent ID: must match pattern %s", validIDPattern.String())
+	}
+	if a.path == "" {
+		return nil, fmt.Errorf("fork requires a database opened by path")
+	}
+	dest := filepath.Join(filepath.Dir(a.path), newID+".db")
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrForkExists, dest)
+	}
+
+	if err := a.FS.coalesce.sync(ctx, ""); err != nil {
+		return nil, err
+	}
+
+	// Recorded before the copy, so the fork inherits the parent's shares
+	shared := a.sharesStores()
+	if shared {
+		if err := addBlobShare(ctx, a.db, dest); err != nil {
+			return nil, err
+		}
+	}
+	if err := copyAgentTables(ctx, a.path, dest, a.opts.TablePrefix); err != nil {
+		removeFork(dest)
+		return nil, fmt.Errorf("failed to fork database: %w", err)
+	}
+
+	opts := a.opts
+	opts.ID, opts.Path = newID, dest
+	opts.Mailbox.AgentID = ""
+	if opts.External.Threshold > 0 {
+		opts.External.Dir = "" // The fork keeps its own links
+	}
+	fork, err := Open(ctx, opts)
+	if err != nil {
+		os.Remove(dest)
+		return nil, err
+	}
+	if err := a.linkBlobs(ctx, fork); err != nil {
+		fork.Close()
+		removeFork(dest)
+		return nil, err
+	}
+	if shared {
+		if err := fork.shareWith(ctx, a.path); err != nil {
+			fork.Close()
+			removeFork(dest)
+			return nil, err
+		}
+	}
+	return fork, nil
+}
+
+// copyAgentTables copies the tables AgentFS keeps in the database at src,
+// with their indexes, views, and triggers, into a new database at dest.
+// Tables that are not AgentFS's are left out.
+func copyAgentTables(ctx context.Context, src, dest, prefix string) error {
+	db, err := sql.Open("sqlite", dest)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// ATTACH holds for one connection only
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS parent", src); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "DETACH DATABASE parent")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	names := schemaNames()
+	ours := func(table string) bool {
+		name, ok := strings.CutPrefix(table, prefix)
+		return ok && (names[name] || isArchiveName(name))
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT type, tbl_name, sql FROM parent.sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY rowid`)
+	if err != nil {
+		return err
+	}
+	var tables, later []string
+	for rows.Next() {
+		var kind, table, stmt string
+		if err := rows.Scan(&kind, &table, &stmt); err != nil {
+			rows.Close()
+			return err
+		}
+		if !ours(table) {
+			continue
+		}
+		if kind == "table" {
+			tables = append(tables, table)
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				rows.Close()
+				return err
+			}
+		} else {
+			later = append(later, stmt) // Once the rows are in, so triggers stay quiet
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		q := fmt.Sprintf("INSERT INTO main.%[1]q SELECT * FROM parent.%[1]q", table)
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range later {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	// AUTOINCREMENT counters continue where the parent's are
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT count(*) FROM main.sqlite_master WHERE name = 'sqlite_sequence'").Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM main.sqlite_sequence"); err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO main.sqlite_sequence SELECT name, seq FROM parent.sqlite_sequence WHERE name = ?", table); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// sharesStores reports whether a has external tiers other than the local
+// one, which a fork shares rather than copies.
+func (a *AgentFS) sharesStores() bool {
+	if a.FS.blobs == nil {
+		return false
+	}
+	for name := range a.FS.blobs.stores {
+		if name != localTier {
+			return true
+		}
+	}
+	return false
+}
+
+// shareWith records parent among the databases sharing the stores of the
+// fork a, and turns off the automatic pruning a copied from it.
+func (a *AgentFS) shareWith(ctx context.Context, parent string) error {
+	if err := addBlobShare(ctx, a.db, parent); err != nil {
+		return err
+	}
+	p, err := a.GetPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	if p.BlobPruneAge == 0 {
+		return nil
+	}
+	p.BlobPruneAge = 0
+	return a.SetPolicy(ctx, p)
+}
+
+// addBlobShare records the database at p as sharing the stores of db.
+func addBlobShare(ctx context.Context, db dbtx, p string) error {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, blobShareAdd, abs); err != nil {
+		return fmt.Errorf("failed to record shared chunk stores: %w", err)
+	}
+	return nil
+}
+
+// addSharedReferences adds to referenced the chunks in tiers other than the
+// local one that the databases sharing a's stores reference, following
+// their own shares in turn, so forks of forks are covered. A database that
+// no longer exists references nothing.
+func (a *AgentFS) addSharedReferences(ctx context.Context, referenced map[string]map[string]bool) error {
+	if a.path == "" {
+		return nil
+	}
+	self, err := filepath.Abs(a.path)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{self: true}
+	queue, err := blobShares(ctx, a.db)
+	if err != nil {
+		return err
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		other, err := Open(ctx, AgentFSOptions{Path: p, ReadOnly: true, TablePrefix: a.opts.TablePrefix})
+		if err != nil {
+			return fmt.Errorf("failed to read chunks shared with %s: %w", p, err)
+		}
+		more, err := other.sharedChunks(ctx, referenced)
+		other.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read chunks shared with %s: %w", p, err)
+		}
+		queue = append(queue, more...)
+	}
+	return nil
+}
+
+// sharedChunks adds the chunks a references outside the local tier to
+// referenced, and returns the databases a shares its stores with.
+func (a *AgentFS) sharedChunks(ctx context.Context, referenced map[string]map[string]bool) ([]string, error) {
+	rows, err := a.db.QueryContext(ctx, extChunkHashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tier, key string
+		if err := rows.Scan(&tier, &key); err != nil {
+			return nil, err
+		}
+		if tier == localTier {
+			continue // Each database has its own
+		}
+		if referenced[tier] == nil {
+			referenced[tier] = make(map[string]bool)
+		}
+		referenced[tier][key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return blobShares(ctx, a.db)
+}
+
+// blobShares lists the databases recorded as sharing the stores of db.
+func blobShares(ctx context.Context, db dbtx) ([]string, error) {
+	rows, err := db.QueryContext(ctx, blobShareList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shared chunk stores: %w", err)
+	}
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("failed to list shared chunk stores: %w", err)
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// linkBlobs gives fork its own links to the chunks it references in the
+// local tier.
+func (a *AgentFS) linkBlobs(ctx context.Context, fork *AgentFS) error {
+	if a.FS.blobs == nil {
+		return nil
+	}
+	src, ok := a.FS.blobs.stores[localTier].(*DirStore)
+	if !ok {
+		return nil
+	}
+	dst := NewDirStore(fork.path + ".blobs")
+
+	rows, err := fork.db.QueryContext(ctx, extChunkHashes)
+	if err != nil {
+		return fmt.Errorf("failed to list external chunks: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var tier, key string
+		if err := rows.Scan(&tier, &key); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to list external chunks: %w", err)
+		}
+		if tier == localTier {
+			keys = append(keys, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list external chunks: %w", err)
+	}
+
+	for _, key := range keys {
+		from, err := src.path(key)
+		if err != nil {
+			return err
+		}
+		to, err := dst.path(key)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			return fmt.Errorf("failed to link chunk: %w", err)
+		}
+		if err := os.Link(from, to); err != nil && !errors.Is(err, os.ErrExist) {
+			// Another device; fall back to copying
+			if err := copyFile(from, to); err != nil {
+				return fmt.Errorf("failed to link chunk: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file at from to a new file at to.
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// removeFork deletes a fork that could not be completed.
+func removeFork(dest string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(dest + suffix)
+	}
+	os.RemoveAll(dest + ".blobs")
+}
@@ -0,0 +1,146 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFork(t *testing.T) {
+	ctx := context.Background()
+	base, blobDir := openExternalTestDB(t, 4096)
+	defer base.Close()
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), 512) // 8 KiB, external
+	base.FS.WriteFile(ctx, "/data/big.bin", big, 0o644)
+	base.FS.WriteFile(ctx, "/notes.md", []byte("base"), 0o644)
+	base.KV.Set(ctx, "step", 1)
+
+	fork, err := base.Fork(ctx, "attempt-1")
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	defer fork.Close()
+	if want := filepath.Join(filepath.Dir(base.Path()), "attempt-1.db"); fork.Path() != want {
+		t.Errorf("fork path = %s, want %s", fork.Path(), want)
+	}
+
+	if data, err := fork.FS.ReadFile(ctx, "/data/big.bin"); err != nil || !bytes.Equal(data, big) {
+		t.Fatalf("fork ReadFile(big) = %d bytes, %v", len(data), err)
+	}
+	if step, _ := KVGet[int](ctx, fork.KV, "step"); step != 1 {
+		t.Errorf("fork step = %d, want 1", step)
+	}
+
+	// External chunks are linked, not copied
+	if n := countBlobs(t, fork.Path()+".blobs"); n != countBlobs(t, blobDir) || n == 0 {
+		t.Errorf("fork has %d blobs, base has %d", n, countBlobs(t, blobDir))
+	}
+
+	// The two diverge
+	fork.FS.WriteFile(ctx, "/notes.md", []byte("fork"), 0o644)
+	base.FS.Unlink(ctx, "/data/big.bin")
+	base.PruneBlobs(ctx, nil)
+	if data, _ := base.FS.ReadFile(ctx, "/notes.md"); string(data) != "base" {
+		t.Errorf("base notes = %q, want base", data)
+	}
+	if data, err := fork.FS.ReadFile(ctx, "/data/big.bin"); err != nil || !bytes.Equal(data, big) {
+		t.Errorf("fork lost big.bin after base pruned: %v", err)
+	}
+
+	if _, err := base.Fork(ctx, "attempt-1"); !errors.Is(err, ErrForkExists) {
+		t.Errorf("second Fork = %v, want ErrForkExists", err)
+	}
+	if _, err := base.Fork(ctx, "../escape"); err == nil {
+		t.Error("expected invalid ID to be rejected")
+	}
+}
+
+func TestForkSharedTier(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewDirStore(filepath.Join(dir, "remote"))
+	base, err := Open(ctx, AgentFSOptions{
+		Path:     filepath.Join(dir, "base.db"),
+		External: ExternalStorageOptions{Tiers: []StorageTier{{Name: "remote", MinSize: 1, Store: store}}},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer base.Close()
+	base.SetPolicy(ctx, Policy{BlobPruneAge: time.Hour})
+	base.FS.WriteFile(ctx, "/f", []byte("shared"), 0o644)
+
+	fork, err := base.Fork(ctx, "fork")
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	defer fork.Close()
+	if p, _ := fork.GetPolicy(ctx); p.BlobPruneAge != 0 {
+		t.Errorf("fork BlobPruneAge = %v, want 0", p.BlobPruneAge)
+	}
+	grandchild, err := fork.Fork(ctx, "grandchild")
+	if err != nil {
+		t.Fatalf("Fork of fork failed: %v", err)
+	}
+
+	// The remote store is shared, so chunks still read elsewhere are kept
+	base.FS.Unlink(ctx, "/f")
+	fork.FS.Unlink(ctx, "/f")
+	if n, err := base.PruneBlobs(ctx, nil); err != nil || n != 0 {
+		t.Errorf("PruneBlobs = %d, %v; want 0", n, err)
+	}
+	if data, err := grandchild.FS.ReadFile(ctx, "/f"); err != nil || string(data) != "shared" {
+		t.Errorf("grandchild ReadFile = %q, %v", data, err)
+	}
+
+	// Once no database references them, they go
+	grandchild.Close()
+	removeFork(grandchild.Path())
+	if n, err := base.PruneBlobs(ctx, nil); err != nil || n != 1 {
+		t.Errorf("PruneBlobs after removing the grandchild = %d, %v; want 1", n, err)
+	}
+}
+
+func TestForkCopiesOnlyAgentTables(t *testing.T) {
+	ctx := context.Background()
+	p := filepath.Join(t.TempDir(), "app.db")
+	base, err := Open(ctx, AgentFSOptions{Path: p, TablePrefix: "agent_"})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer base.Close()
+	base.FS.WriteFile(ctx, "/f", []byte("agent"), 0o644)
+
+	app, err := sql.Open("sqlite", p)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer app.Close()
+	if _, err := app.ExecContext(ctx, "CREATE TABLE users (name TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+
+	fork, err := base.Fork(ctx, "fork")
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	defer fork.Close()
+	if data, err := fork.FS.ReadFile(ctx, "/f"); err != nil || string(data) != "agent" {
+		t.Errorf("fork ReadFile = %q, %v", data, err)
+	}
+
+	copied, err := sql.Open("sqlite", fork.Path())
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer copied.Close()
+	var n int
+	if err := copied.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master WHERE name = 'users'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("fork has the application's table: %d, %v", n, err)
+	}
+}
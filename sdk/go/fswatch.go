@@ -0,0 +1,236 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFSWatchPollInterval is how often a filesystem watch checks for
+	// changes made by other processes.
+	DefaultFSWatchPollInterval = 250 * time.Millisecond
+
+	// DefaultFSChangeRetention is how long filesystem changes are kept for
+	// watches that fall behind. Older changes are removed in the background.
+	DefaultFSChangeRetention = time.Hour
+
+	// fsChangeFlushDelay batches the changes recorded by a busy writer.
+	fsChangeFlushDelay = 20 * time.Millisecond
+
+	// fsWatchBatch is how many changes a watch reads at a time.
+	fsWatchBatch = 64
+)
+
+// FSChange is a change to the filesystem reported by Filesystem.Watch.
+type FSChange struct {
+	// Seq orders the changes of a database.
+	Seq int64 `json:"seq"`
+	// Kind is EventFileCreated, EventFileWritten, EventFileRemoved,
+	// EventFileRenamed, or EventDirCreated.
+	Kind EventKind `json:"kind"`
+	Path string    `json:"path"`
+	// OldPath is the path an entry was renamed from.
+	OldPath string `json:"old_path,omitempty"`
+	// ChangedAt is the time of the change in Unix milliseconds.
+	ChangedAt int64 `json:"changed_at"`
+}
+
+// fsChangeLog records the filesystem events of this AgentFS in the
+// fs_changelog table, in batches, so watches in other processes see them.
+type fsChangeLog struct {
+	mu      sync.Mutex
+	queue   []Event
+	flushed chan struct{} // Closed and replaced after each flush
+	kick    chan struct{}
+}
+
+func newFSChangeLog() *fsChangeLog {
+	return &fsChangeLog{flushed: make(chan struct{}), kick: make(chan struct{}, 1)}
+}
+
+// record queues the changes among the events of the change feed.
+func (l *fsChangeLog) record(e Event) {
+	switch e.Kind {
+	case EventFileCreated, EventFileWritten, EventFileRemoved, EventFileRenamed, EventDirCreated:
+	default:
+		return
+	}
+	l.mu.Lock()
+	l.queue = append(l.queue, e)
+	l.mu.Unlock()
+	select {
+	case l.kick <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns a channel closed after the next flush.
+func (l *fsChangeLog) wait() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flushed
+}
+
+// flush writes the queued changes to db in one transaction.
+func (l *fsChangeLog) flush(ctx context.Context, db sqlConn) error {
+	l.mu.Lock()
+	queue := l.queue
+	l.queue = nil
+	l.mu.Unlock()
+	if len(queue) == 0 {
+		return nil
+	}
+
+	err := func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, e := range queue {
+			if _, err := tx.ExecContext(ctx, fsChangeInsert, string(e.Kind), e.Path, e.OldPath, e.Time.UnixMilli()); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	}()
+
+	l.mu.Lock()
+	close(l.flushed)
+	l.flushed = make(chan struct{})
+	l.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to record filesystem changes: %w", err)
+	}
+	return nil
+}
+
+// startFSChangeLog records filesystem changes in the background, and
+// removes those older than DefaultFSChangeRetention.
+func (a *AgentFS) startFSChangeLog() {
+	l := a.FS.changes
+	a.events.hook(l.record)
+	a.goBackground(func(stop <-chan struct{}) {
+		trim := time.NewTicker(DefaultKVReapInterval)
+		defer trim.Stop()
+		for {
+			select {
+			case <-stop:
+				// The AgentFS is closing, but the database is still open
+				l.flush(context.Background(), a.db)
+				return
+			case <-l.kick:
+				time.Sleep(fsChangeFlushDelay)
+				l.flush(context.Background(), a.db) // Watches miss changes that fail to record
+			case <-trim.C:
+				cutoff := a.FS.now().Add(-DefaultFSChangeRetention).UnixMilli()
+				a.db.ExecContext(context.Background(), fsChangeTrim, cutoff)
+			}
+		}
+	})
+}
+
+// Watch reports the changes to paths starting with prefix made after it is
+// called, in order, until ctx ends or the AgentFS closes; then the channel
+// is closed. Creates, writes, removals, and renames (matched by either
+// path) are recorded in the database, so a supervisor can follow the
+// output of an agent running in another process, within
+// DefaultFSWatchPollInterval. Changes inside a transaction are recorded
+// once it commits. Changes made in the same millisecond as the call may be
+// reported too. A watch that falls more than DefaultFSChangeRetention
+// behind misses the changes removed meanwhile.
+//
+// Example:
+//
+//	changes, err := afs.FS.Watch(ctx, "/output/")
+//	if err != nil {
+//	    return err
+//	}
+//	for c := range changes {
+//	    if c.Kind == agentfs.EventFileWritten {
+//	        data, _ := afs.FS.ReadFile(ctx, c.Path)
+//	        publish(c.Path, data)
+//	    }
+//	}
+func (fs *Filesystem) Watch(ctx context.Context, prefix string) (<-chan FSChange, error) {
+	bctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Changes are recorded shortly after they happen, so some made before
+	// the watch may still come after the head: they are told apart by time
+	since := fs.now().UnixMilli()
+	var seq int64
+	err = fs.db.QueryRowContext(bctx, fsChangeHead).Scan(&seq)
+	done()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filesystem changes: %w", err)
+	}
+
+	out := make(chan FSChange, fsWatchBatch)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(DefaultFSWatchPollInterval)
+		defer ticker.Stop()
+		pattern := escapePattern(prefix) + "%"
+		for {
+			var flushed <-chan struct{}
+			if fs.changes != nil {
+				flushed = fs.changes.wait()
+			}
+			changes, err := fs.changesSince(ctx, seq, pattern)
+			if err != nil {
+				return // ctx ended or the AgentFS closed
+			}
+			for _, c := range changes {
+				if c.ChangedAt < since {
+					seq = c.Seq
+					continue
+				}
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+				seq = c.Seq
+			}
+			if len(changes) == fsWatchBatch {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-flushed:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return out, nil
+}
+
+// changesSince reads the next batch of changes after seq to paths matching
+// the LIKE pattern.
+func (fs *Filesystem) changesSince(ctx context.Context, seq int64, pattern string) ([]FSChange, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := fs.db.QueryContext(ctx, fsChangesSince, seq, pattern, fsWatchBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filesystem changes: %w", err)
+	}
+	defer rows.Close()
+	var changes []FSChange
+	for rows.Next() {
+		var c FSChange
+		if err := rows.Scan(&c.Seq, &c.Kind, &c.Path, &c.OldPath, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to read filesystem changes: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,96 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextFSChange(t *testing.T, changes <-chan FSChange) FSChange {
+	t.Helper()
+	select {
+	case c, ok := <-changes:
+		if !ok {
+			t.Fatal("watch closed")
+		}
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change")
+	}
+	return FSChange{}
+}
+
+func TestFSWatchAcrossProcesses(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := filepath.Join(t.TempDir(), "agent.db")
+
+	agent, err := Open(ctx, AgentFSOptions{Path: p})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer agent.Close()
+	agent.FS.WriteFile(ctx, "/output/old.txt", []byte("before"), 0o644) // Not reported
+	time.Sleep(2 * time.Millisecond)                                    // Not in the millisecond of the watch
+
+	// The supervisor opens the same database separately, read-only
+	supervisor, err := Open(ctx, AgentFSOptions{Path: p, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Open of the supervisor failed: %v", err)
+	}
+	defer supervisor.Close()
+	changes, err := supervisor.FS.Watch(ctx, "/output/")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	agent.FS.WriteFile(ctx, "/scratch.txt", []byte("x"), 0o644)
+	agent.FS.WriteFile(ctx, "/output/report.md", []byte("# Report"), 0o644)
+	agent.FS.Mkdir(ctx, "/archive", 0o755)
+	agent.FS.Rename(ctx, "/output/report.md", "/archive/report.md")
+
+	var kinds []EventKind
+	for len(kinds) < 2 {
+		c := nextFSChange(t, changes)
+		kinds = append(kinds, c.Kind)
+		if c.Path == "/output/old.txt" {
+			t.Errorf("change from before the watch reported: %+v", c)
+		}
+		if c.Kind == EventFileRenamed && (c.OldPath != "/output/report.md" || c.Path != "/archive/report.md") {
+			t.Errorf("rename = %+v", c)
+		}
+		if c.Path == "/scratch.txt" {
+			t.Errorf("change outside the prefix reported: %+v", c)
+		}
+	}
+	if kinds[0] != EventFileWritten || kinds[1] != EventFileRenamed {
+		t.Errorf("kinds = %v", kinds)
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestFSWatchSkipsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	changes, err := afs.FS.Watch(ctx, "/")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	tx, err := afs.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	tx.FS.WriteFile(ctx, "/discarded.txt", []byte("x"), 0o644)
+	tx.Rollback()
+	afs.FS.Mkdir(ctx, "/kept", 0o755)
+
+	if c := nextFSChange(t, changes); c.Kind != EventDirCreated || c.Path != "/kept" {
+		t.Errorf("change = %+v, want /kept created", c)
+	}
+}
@@ -0,0 +1,117 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GrowthReport answers "why did this database grow?" by listing the files,
+// top-level directories, KV namespaces, and tools that contributed the most
+// bytes since the given time. Files count at their full current size if
+// they were written or changed since; KV entries if they were set since;
+// tool calls if they started since, by the size of their parameters,
+// results, and errors. Space held by removed files, snapshots, and file
+// versions is not included.
+//
+// Example:
+//
+//	g, err := afs.GrowthReport(ctx, time.Now().Add(-24*time.Hour))
+//	if err != nil {
+//	    return err
+//	}
+//	for _, e := range g.Subtrees {
+//	    fmt.Printf("%-20s %10d bytes in %d files\n", e.Name, e.Bytes, e.Count)
+//	}
+func (a *AgentFS) GrowthReport(ctx context.Context, since time.Time) (*Growth, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	g := &Growth{Since: since}
+	cutoff := since.Unix()
+
+	rows, err := a.db.QueryContext(ctx, growthFiles, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure file growth: %w", err)
+	}
+	subtrees := make(map[string]*GrowthEntry)
+	for rows.Next() {
+		var e GrowthEntry
+		if err := rows.Scan(&e.Name, &e.Bytes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to measure file growth: %w", err)
+		}
+		e.Count = 1
+		g.FileBytes += e.Bytes
+		g.Paths = append(g.Paths, e)
+
+		name := "/" + strings.SplitN(e.Name[1:], "/", 2)[0]
+		s := subtrees[name]
+		if s == nil {
+			s = &GrowthEntry{Name: name}
+			subtrees[name] = s
+		}
+		s.Bytes += e.Bytes
+		s.Count++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to measure file growth: %w", err)
+	}
+	for _, s := range subtrees {
+		g.Subtrees = append(g.Subtrees, *s)
+	}
+
+	if g.KVNamespaces, g.KVBytes, err = a.growthGroups(ctx, growthKV, cutoff); err != nil {
+		return nil, fmt.Errorf("failed to measure KV growth: %w", err)
+	}
+	if g.Tools, g.ToolBytes, err = a.growthGroups(ctx, growthTools, cutoff); err != nil {
+		return nil, fmt.Errorf("failed to measure tool call growth: %w", err)
+	}
+
+	for _, list := range []*[]GrowthEntry{&g.Paths, &g.Subtrees, &g.KVNamespaces, &g.Tools} {
+		*list = topGrowth(*list)
+	}
+	return g, nil
+}
+
+// growthGroups runs a query returning (name, bytes, count) groups and
+// their total bytes.
+func (a *AgentFS) growthGroups(ctx context.Context, query string, cutoff int64) ([]GrowthEntry, int64, error) {
+	rows, err := a.db.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var entries []GrowthEntry
+	var total int64
+	for rows.Next() {
+		var e GrowthEntry
+		if err := rows.Scan(&e.Name, &e.Bytes, &e.Count); err != nil {
+			return nil, 0, err
+		}
+		total += e.Bytes
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// topGrowth sorts entries by size, largest first, and keeps the first
+// DefaultGrowthTopN.
+func topGrowth(entries []GrowthEntry) []GrowthEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Bytes != entries[j].Bytes {
+			return entries[i].Bytes > entries[j].Bytes
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > DefaultGrowthTopN {
+		entries = entries[:DefaultGrowthTopN]
+	}
+	return entries
+}
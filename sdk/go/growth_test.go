@@ -0,0 +1,54 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGrowthReport(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+	clock := NewManualClock(start)
+	afs, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "test.db"), Clock: clock})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	// Before the window
+	afs.FS.WriteFile(ctx, "/old/base.bin", make([]byte, 5000), 0o644)
+	afs.KV.Set(ctx, "config:model", "x")
+	afs.Tools.Record(ctx, "setup", nil, "done", nil, start.Unix(), start.Unix())
+
+	clock.Advance(12 * time.Hour)
+	since := clock.Now()
+	afs.FS.WriteFile(ctx, "/runs/1/out.bin", make([]byte, 3000), 0o644)
+	afs.FS.WriteFile(ctx, "/runs/2/out.bin", make([]byte, 1000), 0o644)
+	afs.FS.WriteFile(ctx, "/notes.md", make([]byte, 10), 0o644)
+	afs.KV.Set(ctx, "cache:a", "0123456789")
+	afs.KV.Set(ctx, "cache:b", "0123456789")
+	afs.KV.Set(ctx, "step", 1)
+	afs.Tools.Record(ctx, "search", nil, "many results here", nil, since.Unix(), since.Unix())
+
+	g, err := afs.GrowthReport(ctx, since)
+	if err != nil {
+		t.Fatalf("GrowthReport failed: %v", err)
+	}
+	if g.FileBytes != 4010 {
+		t.Errorf("FileBytes = %d, want 4010", g.FileBytes)
+	}
+	if len(g.Paths) != 3 || g.Paths[0].Name != "/runs/1/out.bin" {
+		t.Errorf("Paths = %+v", g.Paths)
+	}
+	if len(g.Subtrees) != 2 || g.Subtrees[0] != (GrowthEntry{Name: "/runs", Bytes: 4000, Count: 2}) {
+		t.Errorf("Subtrees = %+v", g.Subtrees)
+	}
+	if len(g.KVNamespaces) != 2 || g.KVNamespaces[0].Name != "cache" || g.KVNamespaces[0].Count != 2 {
+		t.Errorf("KVNamespaces = %+v", g.KVNamespaces)
+	}
+	if len(g.Tools) != 1 || g.Tools[0].Name != "search" || g.ToolBytes == 0 {
+		t.Errorf("Tools = %+v (%d bytes)", g.Tools, g.ToolBytes)
+	}
+}
@@ -0,0 +1,173 @@
+package agentfs
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OpenHandle describes a File that has not been closed.
+type OpenHandle struct {
+	ID       int64     `json:"id"`
+	Path     string    `json:"path"`
+	Ino      int64     `json:"ino"`
+	Flags    int       `json:"flags"`
+	OpenedAt time.Time `json:"opened_at"`
+	// Leaked is set once the handle's File was garbage collected without
+	// being closed (only when HandleOptions.AutoClose is false).
+	Leaked bool `json:"leaked,omitempty"`
+	// Stack is where the handle was opened, if HandleOptions.Stacks is set.
+	Stack string `json:"stack,omitempty"`
+}
+
+// handleTable tracks the open Files of a Filesystem.
+type handleTable struct {
+	opts HandleOptions
+
+	mu      sync.Mutex
+	next    int64
+	open    map[int64]*OpenHandle
+	onEvent func(kind EventKind, p string)
+}
+
+// fileHandle is shared by a File and its WithContext copies. A finalizer on
+// it detects Files dropped without Close.
+type fileHandle struct {
+	table  *handleTable
+	id     int64
+	mu     sync.Mutex
+	closed bool
+}
+
+func newHandleTable(opts HandleOptions, onEvent func(kind EventKind, p string)) *handleTable {
+	return &handleTable{opts: opts, open: map[int64]*OpenHandle{}, onEvent: onEvent}
+}
+
+// register records a newly opened File and returns its handle.
+func (t *handleTable) register(ino int64, p string, flags int, now time.Time) *fileHandle {
+	if t == nil {
+		return nil
+	}
+
+	h := &OpenHandle{Path: p, Ino: ino, Flags: flags, OpenedAt: now}
+	if t.opts.Stacks {
+		buf := make([]byte, 4096)
+		h.Stack = string(buf[:runtime.Stack(buf, false)])
+	}
+
+	t.mu.Lock()
+	t.next++
+	h.ID = t.next
+	t.open[h.ID] = h
+	t.mu.Unlock()
+
+	fh := &fileHandle{table: t, id: h.ID}
+	runtime.SetFinalizer(fh, (*fileHandle).finalize)
+	t.onEvent(EventFileOpened, p)
+	return fh
+}
+
+// close removes the handle from the table. It reports false if the handle
+// was already closed.
+func (fh *fileHandle) close() bool {
+	if fh == nil {
+		return true
+	}
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if fh.closed {
+		return false
+	}
+	fh.closed = true
+	runtime.SetFinalizer(fh, nil)
+
+	t := fh.table
+	t.mu.Lock()
+	h := t.open[fh.id]
+	delete(t.open, fh.id)
+	t.mu.Unlock()
+	if h != nil {
+		t.onEvent(EventFileClosed, h.Path)
+	}
+	return true
+}
+
+// finalize reports a File garbage collected without Close.
+func (fh *fileHandle) finalize() {
+	t := fh.table
+	t.mu.Lock()
+	h, ok := t.open[fh.id]
+	if ok {
+		if t.opts.AutoClose {
+			delete(t.open, fh.id)
+		} else {
+			h.Leaked = true
+		}
+	}
+	t.mu.Unlock()
+	if ok {
+		t.leaked(*h)
+	}
+}
+
+// leaked passes h to the OnLeak hook, if any.
+func (t *handleTable) leaked(h OpenHandle) {
+	if t.opts.OnLeak != nil {
+		t.opts.OnLeak(h)
+	}
+}
+
+// list returns the open handles ordered by ID.
+func (t *handleTable) list() []OpenHandle {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	handles := make([]OpenHandle, 0, len(t.open))
+	for _, h := range t.open {
+		handles = append(handles, *h)
+	}
+	t.mu.Unlock()
+	sort.Slice(handles, func(i, j int) bool { return handles[i].ID < handles[j].ID })
+	return handles
+}
+
+// closeAll reports every handle still open to OnLeak and empties the table.
+// It runs when the AgentFS closes.
+func (t *handleTable) closeAll() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	var open []OpenHandle
+	for _, h := range t.open {
+		if !h.Leaked {
+			open = append(open, *h)
+		}
+	}
+	t.open = map[int64]*OpenHandle{}
+	t.mu.Unlock()
+
+	sort.Slice(open, func(i, j int) bool { return open[i].ID < open[j].ID })
+	for _, h := range open {
+		t.leaked(h)
+	}
+}
+
+// newFile returns a File for ino registered in the handle table.
+func (fs *Filesystem) newFile(ino int64, p string, flags int) *File {
+	return &File{
+		fs:     fs,
+		ino:    ino,
+		path:   p,
+		flags:  flags,
+		handle: fs.handles.register(ino, p, flags, fs.now()),
+	}
+}
+
+// OpenHandles returns the Files opened from this filesystem that have not
+// been closed, ordered by when they were opened.
+func (fs *Filesystem) OpenHandles() []OpenHandle {
+	return fs.handles.list()
+}
@@ -0,0 +1,108 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenHandles(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	events, cancel := afs.Subscribe(EventFilter{Kinds: []EventKind{EventFileOpened, EventFileClosed}}, 0)
+	defer cancel()
+
+	_, f1, err := afs.FS.Create(ctx, "/a.txt", 0o644)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	f2, err := afs.FS.Open(ctx, "/a.txt", O_RDONLY)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	handles := afs.FS.OpenHandles()
+	if len(handles) != 2 || handles[0].Flags != O_RDWR || handles[1].Flags != O_RDONLY || handles[0].Path != "/a.txt" {
+		t.Fatalf("OpenHandles = %+v", handles)
+	}
+
+	// Closing a WithContext copy closes the shared handle, once
+	f1.WithContext(ctx).Close()
+	f1.Close()
+	if handles := afs.FS.OpenHandles(); len(handles) != 1 || handles[0].ID != f2.handle.id {
+		t.Errorf("OpenHandles after Close = %+v", handles)
+	}
+	f2.Close()
+
+	var kinds []EventKind
+	for i := 0; i < 4; i++ {
+		kinds = append(kinds, (<-events).Kind)
+	}
+	want := []EventKind{EventFileOpened, EventFileOpened, EventFileClosed, EventFileClosed}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("events = %v, want %v", kinds, want)
+		}
+	}
+}
+
+func TestLeakedHandles(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var leaks []OpenHandle
+	afs, err := Open(ctx, AgentFSOptions{
+		Path: filepath.Join(t.TempDir(), "test.db"),
+		Handles: HandleOptions{
+			AutoClose: true,
+			Stacks:    true,
+			OnLeak: func(h OpenHandle) {
+				mu.Lock()
+				leaks = append(leaks, h)
+				mu.Unlock()
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	func() {
+		if _, _, err := afs.FS.Create(ctx, "/dropped.txt", 0o644); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}()
+	_, kept, err := afs.FS.Create(ctx, "/kept.txt", 0o644)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(afs.FS.OpenHandles()) > 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("dropped handle was not auto-closed")
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	runtime.KeepAlive(kept)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(leaks) != 2 {
+		t.Fatalf("leaks = %+v, want 2", leaks)
+	}
+	if !strings.Contains(leaks[0].Stack, "TestLeakedHandles") {
+		t.Errorf("leak stack does not name the opener:\n%s", leaks[0].Stack)
+	}
+}
@@ -0,0 +1,140 @@
+package agentfs
+
+import (
+	"path"
+	"strings"
+)
+
+// IgnoreRules is a set of .gitignore-style patterns used to exclude paths
+// from ImportDir, ExportDir, Find, and Grep.
+//
+// Supported syntax follows .gitignore: blank lines and lines starting with
+// "#" are skipped, "!" negates a pattern, a trailing "/" matches directories
+// only, a pattern containing "/" is anchored to the root of the walk, and
+// "**" matches any number of directories. Later patterns take precedence
+// over earlier ones. Paths below an ignored directory are always ignored.
+//
+// Example:
+//
+//	rules := agentfs.NewIgnoreRules("node_modules/", "*.o", "!keep.o")
+type IgnoreRules struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// NewIgnoreRules creates rules from individual pattern lines.
+func NewIgnoreRules(patterns ...string) *IgnoreRules {
+	r := &IgnoreRules{}
+	r.Add(patterns...)
+	return r
+}
+
+// ParseIgnore creates rules from the contents of a .gitignore-style file.
+func ParseIgnore(data []byte) *IgnoreRules {
+	return NewIgnoreRules(strings.Split(string(data), "\n")...)
+}
+
+// Add appends pattern lines to the rules.
+func (r *IgnoreRules) Add(patterns ...string) {
+	for _, line := range patterns {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimLeft(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.segments = strings.Split(line, "/")
+		r.patterns = append(r.patterns, p)
+	}
+}
+
+// Match reports whether p, relative to the root of the walk, is ignored.
+// isDir reports whether p itself is a directory. A nil *IgnoreRules
+// matches nothing.
+func (r *IgnoreRules) Match(p string, isDir bool) bool {
+	if r == nil || len(r.patterns) == 0 {
+		return false
+	}
+
+	segments := splitPath(p)
+	for i := 1; i <= len(segments); i++ {
+		last := i == len(segments)
+		if r.matchExact(segments[:i], !last || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExact applies the patterns to a single path without considering
+// whether any of its parents are ignored.
+func (r *IgnoreRules) matchExact(segments []string, isDir bool) bool {
+	ignored := false
+	for _, p := range r.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.matches(segments) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func (p *ignorePattern) matches(segments []string) bool {
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], segments[len(segments)-1])
+		return ok
+	}
+	return matchSegments(p.segments, segments)
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches zero or more path segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				// Trailing "**" matches everything inside, but not the directory itself
+				return len(segments) > 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,48 @@
+package agentfs
+
+import "testing"
+
+func TestIgnoreRules_Match(t *testing.T) {
+	rules := ParseIgnore([]byte(`
+# build output
+node_modules/
+*.o
+!keep.o
+/dist
+docs/**/*.tmp
+build/
+`))
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"node_modules/pkg/index.js", false, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false}, // dir-only pattern
+		{"main.o", false, true},
+		{"src/lib.o", false, true},
+		{"keep.o", false, false},
+		{"dist", true, true},
+		{"src/dist", true, false}, // anchored to root
+		{"docs/a/b/x.tmp", false, true},
+		{"docs/x.tmp", false, true},
+		{"x.tmp", false, false},
+		{"build/keep.o", false, true}, // parent ignored
+		{"src/main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRules_Nil(t *testing.T) {
+	var rules *IgnoreRules
+	if rules.Match("anything", false) {
+		t.Error("nil rules should match nothing")
+	}
+}
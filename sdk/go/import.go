@@ -0,0 +1,327 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// DefaultImportBatchSize is the number of entries ImportDir writes per
+// transaction when ImportOptions.BatchSize is not set.
+const DefaultImportBatchSize = 64
+
+// importEntry is one host entry to import, in walk order.
+type importEntry struct {
+	hostPath string
+	rel      string
+	target   string
+	mode     os.FileMode
+	size     int64
+	link     string          // symlink target
+	result   chan importData // regular files only; filled by a reader
+}
+
+// importData is the content of a regular file read by an import worker.
+type importData struct {
+	data []byte
+	hash [sha256.Size]byte
+	err  error
+}
+
+// ImportDir copies a host directory tree into AgentFS at dst, creating dst
+// if needed. Regular files, directories, and symlinks are copied along with
+// their permission bits; other file types are skipped.
+//
+// Files are read and hashed by a pool of opts.Concurrency workers while a
+// single writer applies them in batches of opts.BatchSize entries per
+// transaction. Files and symlinks that already match the destination are
+// not rewritten, so re-importing a tree is cheap. The import
+// as a whole is not atomic: batches committed before an error, cancellation,
+// or a Progress error are kept. With opts.ResumeToken set, a later call with
+// the same token and arguments continues after the last committed batch.
+//
+// Example:
+//
+//	err := afs.FS.ImportDir(ctx, "./repo", "/workspace", &agentfs.ImportOptions{
+//	    Ignore:     agentfs.NewIgnoreRules("node_modules/", ".git/"),
+//	    IgnoreFile: ".gitignore",
+//	})
+func (fs *Filesystem) ImportDir(ctx context.Context, hostDir, dst string, opts *ImportOptions) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	dst, err = fs.cleanPath(ctx, "import", dst)
+	if err != nil {
+		return err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+
+	rules, err := loadIgnore(opts.Ignore, opts.IgnoreFile, func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(hostDir, name))
+	})
+	if err != nil {
+		return err
+	}
+
+	absHost, err := filepath.Abs(hostDir)
+	if err != nil {
+		return err
+	}
+	tracker := newProgressTracker("import", opts.Progress)
+	st, err := loadResume(ctx, fs.db, fs.clock, opts.ResumeToken, "import", absHost+"\x00"+dst, tracker)
+	if err != nil {
+		return err
+	}
+	cursor := ""
+	if st != nil {
+		cursor = st.cursor
+	}
+
+	if err := fs.MkdirAll(ctx, dst, 0o755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The walker feeds entries to the writer in walk order and regular files
+	// to the readers. The entries buffer bounds the number of files in memory.
+	entries := make(chan *importEntry, 2*concurrency)
+	jobs := make(chan *importEntry)
+	var wg sync.WaitGroup
+	var walkErr error
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(entries)
+		defer close(jobs)
+		walkErr = walkImport(ctx, hostDir, dst, cursor, rules, entries, jobs)
+	}()
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				var d importData
+				if d.err = opts.Limiter.Wait(ctx, e.size); d.err == nil {
+					d.data, d.err = os.ReadFile(e.hostPath)
+				}
+				if d.err == nil {
+					d.hash = sha256.Sum256(d.data)
+				}
+				e.result <- d
+			}
+		}()
+	}
+
+	writeErr := fs.writeImport(ctx, entries, batchSize, tracker, st)
+	if writeErr != nil {
+		cancel()
+	}
+	wg.Wait()
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	if err := st.finish(ctx, fs.db); err != nil {
+		return err
+	}
+	// A large import skews the planner statistics; the import itself
+	// succeeded, so a failure here is left to the next run
+	fs.optimizer.run(ctx)
+	return nil
+}
+
+// walkImport walks hostDir and sends every entry to import to entries, and
+// regular files also to jobs. Entries up to cursor were imported by an
+// earlier run and are skipped.
+func walkImport(ctx context.Context, hostDir, dst, cursor string, rules *IgnoreRules, entries, jobs chan<- *importEntry) error {
+	return filepath.WalkDir(hostDir, func(hostPath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(hostDir, hostPath)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rules.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if resumed(rel, cursor) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		e := &importEntry{hostPath: hostPath, rel: rel, target: path.Join(dst, rel), mode: info.Mode(), size: info.Size()}
+
+		switch {
+		case d.IsDir():
+		case d.Type()&os.ModeSymlink != 0:
+			if e.link, err = os.Readlink(hostPath); err != nil {
+				return err
+			}
+		case d.Type().IsRegular():
+			e.result = make(chan importData, 1)
+		default:
+			return nil
+		}
+
+		select {
+		case entries <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if e.result != nil {
+			select {
+			case jobs <- e:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
+
+// writeImport applies entries in batches, one transaction per batch. The
+// resume checkpoint is saved in the same transaction.
+func (fs *Filesystem) writeImport(ctx context.Context, entries <-chan *importEntry, batchSize int, tracker *progressTracker, st *resumeState) error {
+	batch := make([]*importEntry, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		var size int64
+		err := fs.inTx(ctx, func(tfs *Filesystem) error {
+			size = 0
+			for _, e := range batch {
+				n, err := tfs.importEntry(ctx, e)
+				if err != nil {
+					return err
+				}
+				size += n
+			}
+			if err := tfs.checkAll(ctx, "import", batch[len(batch)-1].target); err != nil {
+				return err
+			}
+			return st.save(ctx, tfs.db, batch[len(batch)-1].rel, Progress{
+				Items: tracker.p.Items + int64(len(batch)),
+				Bytes: tracker.p.Bytes + size,
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		n := int64(len(batch))
+		last := batch[n-1].target
+		batch = batch[:0]
+		return tracker.add(ctx, n, size, last)
+	}
+
+	for e := range entries {
+		batch = append(batch, e)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// importEntry writes a single entry and returns the number of bytes imported.
+func (fs *Filesystem) importEntry(ctx context.Context, e *importEntry) (int64, error) {
+	perm := int64(e.mode.Perm())
+
+	switch {
+	case e.mode.IsDir():
+		if err := fs.Mkdir(ctx, e.target, perm); err != nil && !IsExist(err) {
+			return 0, err
+		}
+		return 0, nil
+	case e.mode&os.ModeSymlink != 0:
+		if link, err := fs.Readlink(ctx, e.target); err == nil && link == e.link {
+			return 0, nil
+		}
+		return 0, fs.Symlink(ctx, e.link, e.target)
+	}
+
+	var d importData
+	select {
+	case d = <-e.result:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	same, err := fs.sameFile(ctx, e.target, d.hash, int64(len(d.data)), perm)
+	if err != nil || same {
+		return int64(len(d.data)), err
+	}
+	return int64(len(d.data)), fs.WriteFile(ctx, e.target, d.data, perm)
+}
+
+// sameFile reports whether p is a regular file with the given permissions,
+// size, and content hash.
+func (fs *Filesystem) sameFile(ctx context.Context, p string, hash [sha256.Size]byte, size, perm int64) (bool, error) {
+	stats, err := fs.Lstat(ctx, p)
+	if IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !stats.IsRegularFile() || stats.Size != size || stats.Permissions() != perm {
+		return false, nil
+	}
+
+	data, err := fs.ReadFile(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	got := sha256.Sum256(data)
+	return bytes.Equal(got[:], hash[:]), nil
+}
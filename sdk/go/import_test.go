@@ -0,0 +1,120 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParallelImport(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	src := t.TempDir()
+	files := make(map[string]string)
+	var total int64
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("dir%d/file%02d.txt", i%5, i)
+		files[name] = fmt.Sprintf("content of file %d\n", i)
+		total += int64(len(files[name]))
+	}
+	writeHostTree(t, src, files)
+	if err := os.Symlink("dir0/file00.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+
+	var reports []Progress
+	err := afs.FS.ImportDir(ctx, src, "/import", &ImportOptions{
+		Concurrency: 4,
+		BatchSize:   7,
+		Progress: func(p Progress) error {
+			reports = append(reports, p)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ImportDir failed: %v", err)
+	}
+
+	for name, want := range files {
+		got, err := afs.FS.ReadFile(ctx, "/import/"+name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if target, err := afs.FS.Readlink(ctx, "/import/link"); err != nil || target != "dir0/file00.txt" {
+		t.Errorf("Readlink = %q, %v", target, err)
+	}
+
+	t.Run("progress", func(t *testing.T) {
+		// 50 files, 5 directories, and 1 symlink in batches of 7
+		if len(reports) != 8 {
+			t.Fatalf("progress reports = %d, want 8", len(reports))
+		}
+		last := reports[len(reports)-1]
+		if last.Items != 56 || last.Bytes != total {
+			t.Errorf("final progress = %+v, want 56 items and %d bytes", last, total)
+		}
+		for i := 1; i < len(reports); i++ {
+			if reports[i].Items <= reports[i-1].Items {
+				t.Errorf("progress not increasing: %+v then %+v", reports[i-1], reports[i])
+			}
+		}
+	})
+
+	t.Run("reimport skips unchanged files", func(t *testing.T) {
+		before, err := afs.FS.Stat(ctx, "/import/dir1/file01.txt")
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		writeHostTree(t, src, map[string]string{"dir2/file02.txt": "changed\n"})
+
+		events, cancel := afs.Subscribe(EventFilter{Kinds: []EventKind{EventFileWritten}}, 64)
+		defer cancel()
+		if err := afs.FS.ImportDir(ctx, src, "/import", &ImportOptions{Concurrency: 2}); err != nil {
+			t.Fatalf("ImportDir failed: %v", err)
+		}
+
+		select {
+		case e := <-events:
+			if e.Path != "/import/dir2/file02.txt" {
+				t.Errorf("written = %s, want only the changed file", e.Path)
+			}
+		default:
+			t.Fatal("changed file was not written")
+		}
+		select {
+		case e := <-events:
+			t.Errorf("unexpected write of %s", e.Path)
+		default:
+		}
+
+		after, err := afs.FS.Stat(ctx, "/import/dir1/file01.txt")
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if after.Mtime != before.Mtime || after.MtimeNsec != before.MtimeNsec {
+			t.Error("unchanged file was rewritten")
+		}
+	})
+
+	t.Run("read errors abort the import", func(t *testing.T) {
+		bad := t.TempDir()
+		writeHostTree(t, bad, map[string]string{"a.txt": "a", "b.txt": "b"})
+		if err := os.Chmod(filepath.Join(bad, "b.txt"), 0); err != nil {
+			t.Fatalf("Chmod failed: %v", err)
+		}
+		if os.Geteuid() == 0 {
+			t.Skip("root can read unreadable files")
+		}
+		if err := afs.FS.ImportDir(ctx, bad, "/bad", &ImportOptions{Concurrency: 2}); err == nil {
+			t.Error("ImportDir of an unreadable file should fail")
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Bloom is a scalable bloom filter: it answers whether a key may have been
+// added, with no false negatives. It grows by adding layers of twice the
+// capacity and half the false positive rate of the last, so the overall
+// rate stays near the initial one however many keys are added. Keys cannot
+// be removed.
+type Bloom struct {
+	mu     sync.RWMutex
+	seed   maphash.Seed
+	layers []*bloomLayer
+	hits   atomic.Int64 // Keys reported absent
+	misses atomic.Int64 // Keys reported possibly present
+}
+
+type bloomLayer struct {
+	bits     []uint64
+	m        uint64 // Number of bits
+	k        int    // Number of hashes
+	capacity int
+	n        int
+}
+
+// NewBloom creates a filter sized for capacity keys at a false positive
+// rate of fpRate before it grows.
+func NewBloom(capacity int, fpRate float64) *Bloom {
+	if capacity < 1024 {
+		capacity = 1024
+	}
+	b := &Bloom{seed: maphash.MakeSeed()}
+	b.layers = []*bloomLayer{newBloomLayer(capacity, fpRate/2)}
+	return b
+}
+
+func newBloomLayer(capacity int, fpRate float64) *bloomLayer {
+	// Optimal sizes: m = -n ln p / (ln 2)^2, k = m/n ln 2
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) &^ 63
+	k := int(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomLayer{bits: make([]uint64, m/64), m: m, k: k, capacity: capacity}
+}
+
+// hashes returns the two hashes combined into the k bit positions of key.
+func (b *Bloom) hashes(key string) (uint64, uint64) {
+	h := maphash.String(b.seed, key)
+	return h, h>>32 | 1
+}
+
+func (l *bloomLayer) add(h1, h2 uint64) {
+	for i := 0; i < l.k; i++ {
+		bit := (h1 + uint64(i)*h2) % l.m
+		l.bits[bit/64] |= 1 << (bit % 64)
+	}
+	l.n++
+}
+
+func (l *bloomLayer) has(h1, h2 uint64) bool {
+	for i := 0; i < l.k; i++ {
+		bit := (h1 + uint64(i)*h2) % l.m
+		if l.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Add records key, growing the filter if the newest layer is full.
+func (b *Bloom) Add(key string) {
+	h1, h2 := b.hashes(key)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	last := b.layers[len(b.layers)-1]
+	if last.n >= last.capacity {
+		// Each layer halves the rate so their sum stays below twice the first
+		fp := math.Exp(-float64(last.m) / float64(last.capacity) * math.Ln2 * math.Ln2)
+		last = newBloomLayer(last.capacity*2, fp/2)
+		b.layers = append(b.layers, last)
+	}
+	last.add(h1, h2)
+}
+
+// MayContain reports whether key may have been added. False means it
+// certainly was not.
+func (b *Bloom) MayContain(key string) bool {
+	h1, h2 := b.hashes(key)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, l := range b.layers {
+		if l.has(h1, h2) {
+			b.misses.Add(1)
+			return true
+		}
+	}
+	b.hits.Add(1)
+	return false
+}
+
+// Stats returns filter statistics: Hits counts the keys reported absent,
+// Misses those reported possibly present, and Entries the keys added.
+func (b *Bloom) Stats() Stats {
+	b.mu.RLock()
+	entries := 0
+	for _, l := range b.layers {
+		entries += l.n
+	}
+	b.mu.RUnlock()
+
+	return Stats{
+		Hits:    b.hits.Load(),
+		Misses:  b.misses.Load(),
+		Entries: entries,
+	}
+}
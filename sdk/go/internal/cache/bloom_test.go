@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestBloom_NoFalseNegatives(t *testing.T) {
+	b := NewBloom(1000, 0.01)
+
+	// Ten times the initial capacity forces several layers
+	for i := 0; i < 10000; i++ {
+		b.Add(fmt.Sprintf("key-%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if !b.MayContain(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("MayContain(key-%d) = false after Add", i)
+		}
+	}
+	if n := b.Stats().Entries; n != 10000 {
+		t.Errorf("Entries = %d, want 10000", n)
+	}
+}
+
+func TestBloom_FalsePositiveRate(t *testing.T) {
+	b := NewBloom(1000, 0.01)
+	for i := 0; i < 20000; i++ {
+		b.Add(fmt.Sprintf("present-%d", i))
+	}
+
+	falsePositives := 0
+	const probes = 100000
+	for i := 0; i < probes; i++ {
+		if b.MayContain(fmt.Sprintf("absent-%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / probes; rate > 0.02 {
+		t.Errorf("false positive rate = %.4f, want at most 0.02", rate)
+	}
+
+	stats := b.Stats()
+	if stats.Hits+stats.Misses != probes || stats.Misses != int64(falsePositives) {
+		t.Errorf("Stats = %+v, want %d lookups with %d misses", stats, probes, falsePositives)
+	}
+}
+
+func TestBloom_Concurrent(t *testing.T) {
+	b := NewBloom(1000, 0.01)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := fmt.Sprintf("%d/%d", w, i)
+				b.Add(key)
+				if !b.MayContain(key) {
+					t.Errorf("MayContain(%s) = false after Add", key)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
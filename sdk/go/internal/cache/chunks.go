@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// ChunkLRU caches immutable chunk data by key, bounded by total size in bytes.
+// Pinned entries are kept outside the LRU and never evicted.
+type ChunkLRU struct {
+	mu       sync.Mutex
+	cache    *lru.Cache[string, []byte]
+	bytes    int64
+	maxBytes int64
+	pinned   map[string][]byte
+	pinBytes int64
+	hits     atomic.Int64
+	misses   atomic.Int64
+}
+
+// NewChunkLRU creates a chunk cache holding at most maxBytes of data.
+// Entries larger than maxBytes are not cached.
+func NewChunkLRU(maxBytes int64) (*ChunkLRU, error) {
+	c := &ChunkLRU{maxBytes: maxBytes, pinned: make(map[string][]byte)}
+	inner, err := lru.NewWithEvict[string, []byte](math.MaxInt32, func(_ string, data []byte) {
+		c.bytes -= int64(len(data))
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.cache = inner
+	return c, nil
+}
+
+// Get returns the cached data for key.
+func (c *ChunkLRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	data, ok := c.pinned[key]
+	if !ok {
+		data, ok = c.cache.Get(key)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+// Add caches data under key, evicting the least recently used entries to
+// stay within the size limit.
+func (c *ChunkLRU) Add(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pinned[key]; ok || c.cache.Contains(key) {
+		return
+	}
+	c.add(key, data)
+}
+
+// add inserts into the LRU; c.mu must be held.
+func (c *ChunkLRU) add(key string, data []byte) {
+	c.cache.Add(key, data)
+	c.bytes += int64(len(data))
+	for c.bytes > c.maxBytes {
+		c.cache.RemoveOldest()
+	}
+}
+
+// AddPinned caches data under key until Unpin, whatever the size limit.
+func (c *ChunkLRU) AddPinned(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pinned[key]; ok {
+		return
+	}
+	c.cache.Remove(key)
+	c.pinned[key] = data
+	c.pinBytes += int64(len(data))
+}
+
+// Unpin returns a pinned entry to the LRU, where it may be evicted.
+func (c *ChunkLRU) Unpin(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.pinned[key]
+	if !ok {
+		return
+	}
+	delete(c.pinned, key)
+	c.pinBytes -= int64(len(data))
+	if int64(len(data)) <= c.maxBytes {
+		c.add(key, data)
+	}
+}
+
+// Bytes returns the total size of the cached data, pinned or not.
+func (c *ChunkLRU) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes + c.pinBytes
+}
+
+// Stats returns cache statistics.
+func (c *ChunkLRU) Stats() Stats {
+	c.mu.Lock()
+	entries := c.cache.Len() + len(c.pinned)
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+}
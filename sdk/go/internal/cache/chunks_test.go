@@ -0,0 +1,75 @@
+package cache
+
+import "testing"
+
+func TestChunkLRU_EvictsBySize(t *testing.T) {
+	c, err := NewChunkLRU(10)
+	if err != nil {
+		t.Fatalf("NewChunkLRU failed: %v", err)
+	}
+
+	c.Add("a", []byte("aaaa"))
+	c.Add("b", []byte("bbbb"))
+	c.Get("a") // a is now most recently used
+	c.Add("c", []byte("cccc"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if data, ok := c.Get("a"); !ok || string(data) != "aaaa" {
+		t.Errorf("Get(a) = %q, %v", data, ok)
+	}
+	if n := c.Bytes(); n != 8 {
+		t.Errorf("Bytes() = %d, want 8", n)
+	}
+}
+
+func TestChunkLRU_SkipsOversizedEntries(t *testing.T) {
+	c, _ := NewChunkLRU(4)
+
+	c.Add("big", []byte("too large"))
+	if _, ok := c.Get("big"); ok {
+		t.Error("Expected oversized entry not to be cached")
+	}
+	if n := c.Bytes(); n != 0 {
+		t.Errorf("Bytes() = %d, want 0", n)
+	}
+}
+
+func TestChunkLRU_Stats(t *testing.T) {
+	c, _ := NewChunkLRU(100)
+
+	c.Add("a", []byte("x"))
+	c.Add("a", []byte("x"))
+	c.Get("a")
+	c.Get("missing")
+
+	s := c.Stats()
+	if s.Hits != 1 || s.Misses != 1 || s.Entries != 1 {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
+
+func TestChunkLRU_PinnedEntriesSurviveEviction(t *testing.T) {
+	c, _ := NewChunkLRU(8)
+
+	c.AddPinned("p", []byte("pinned-and-large"))
+	c.Add("a", []byte("aaaa"))
+	c.Add("b", []byte("bbbb"))
+	c.Add("c", []byte("cccc"))
+
+	if data, ok := c.Get("p"); !ok || string(data) != "pinned-and-large" {
+		t.Errorf("Get(p) = %q, %v", data, ok)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to be evicted")
+	}
+
+	c.Unpin("p") // Larger than the limit, so dropped
+	if _, ok := c.Get("p"); ok {
+		t.Error("Expected unpinned oversized entry to be dropped")
+	}
+	if n := c.Bytes(); n != 8 {
+		t.Errorf("Bytes() = %d, want 8", n)
+	}
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// ValueLRU caches values by key, evicting the least recently used beyond
+// a number of entries. Readers that fill it from a slower store take a
+// Generation before reading and pass it to Add, so a value read before a
+// concurrent Remove or Purge is not cached after it.
+type ValueLRU[V any] struct {
+	mu         sync.Mutex
+	cache      *lru.Cache[string, V]
+	gen        uint64 // Incremented by every Remove and Purge
+	maxEntries int
+	hits       atomic.Int64
+	misses     atomic.Int64
+}
+
+// NewValueLRU creates a cache of at most maxEntries values.
+func NewValueLRU[V any](maxEntries int) (*ValueLRU[V], error) {
+	inner, err := lru.New[string, V](maxEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &ValueLRU[V]{cache: inner, maxEntries: maxEntries}, nil
+}
+
+// Get returns the cached value for key.
+func (c *ValueLRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	v, ok := c.cache.Get(key)
+	c.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return v, false
+	}
+	c.hits.Add(1)
+	return v, true
+}
+
+// Generation returns a token that Add checks for intervening removals.
+func (c *ValueLRU[V]) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// Add caches v under key unless an entry has been removed, or the cache
+// purged, since gen was taken.
+func (c *ValueLRU[V]) Add(key string, v V, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.cache.Add(key, v)
+	}
+}
+
+// Remove drops the entry for key.
+func (c *ValueLRU[V]) Remove(key string) {
+	c.mu.Lock()
+	c.cache.Remove(key)
+	c.gen++
+	c.mu.Unlock()
+}
+
+// Purge drops all entries.
+func (c *ValueLRU[V]) Purge() {
+	c.mu.Lock()
+	c.cache.Purge()
+	c.gen++
+	c.mu.Unlock()
+}
+
+// Stats returns cache statistics.
+func (c *ValueLRU[V]) Stats() Stats {
+	c.mu.Lock()
+	entries := c.cache.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Entries:    entries,
+		MaxEntries: c.maxEntries,
+	}
+}
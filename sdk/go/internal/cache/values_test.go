@@ -0,0 +1,50 @@
+package cache
+
+import "testing"
+
+func TestValueLRU_Evicts(t *testing.T) {
+	c, err := NewValueLRU[string](2)
+	if err != nil {
+		t.Fatalf("NewValueLRU failed: %v", err)
+	}
+
+	c.Add("a", "1", c.Generation())
+	c.Add("b", "2", c.Generation())
+	c.Get("a") // a is now most recently used
+	c.Add("c", "3", c.Generation())
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 2 || stats.MaxEntries != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestValueLRU_StaleAdd(t *testing.T) {
+	c, _ := NewValueLRU[string](10)
+
+	// A value read before a removal must not be cached after it
+	gen := c.Generation()
+	c.Remove("a")
+	c.Add("a", "old", gen)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected add after Remove to be dropped")
+	}
+
+	gen = c.Generation()
+	c.Purge()
+	c.Add("a", "old", gen)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected add after Purge to be dropped")
+	}
+
+	c.Add("a", "new", c.Generation())
+	if v, ok := c.Get("a"); !ok || v != "new" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+}
@@ -27,6 +27,8 @@ var (
 	_ fs.ReadFileFS = (*IOFS)(nil)
 	_ fs.ReadDirFS  = (*IOFS)(nil)
 	_ fs.SubFS      = (*IOFS)(nil)
+	_ io.ReadSeeker = (*iofsFile)(nil)
+	_ io.ReaderAt   = (*iofsFile)(nil)
 	// Note: We intentionally do NOT implement fs.GlobFS.
 	// The standard library's fs.Glob will use our ReadDir implementation.
 	// Implementing GlobFS by delegating to fs.Glob causes infinite recursion.
@@ -248,7 +250,8 @@ func errorAs(err error, target interface{}) bool {
 // iofsFile - implements fs.File for regular files
 // ============================================================================
 
-// iofsFile wraps File to implement fs.File with stateful reading.
+// iofsFile wraps File to implement fs.File with stateful reading. It also
+// implements io.Seeker and io.ReaderAt.
 type iofsFile struct {
 	file   *File
 	ctx    context.Context
@@ -285,6 +288,38 @@ func (f *iofsFile) Read(b []byte) (int, error) {
 	return n, err
 }
 
+// Seek implements io.Seeker, which http.FileServer needs to serve content
+// and byte ranges.
+func (f *iofsFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		size, err := f.file.Size()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt without moving the file offset.
+func (f *iofsFile) ReadAt(b []byte, off int64) (int, error) {
+	n, err := f.file.Pread(f.ctx, b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
 // Close implements fs.File.
 func (f *iofsFile) Close() error {
 	return f.file.Close()
@@ -296,12 +331,12 @@ func (f *iofsFile) Close() error {
 
 // iofsDir implements fs.File and fs.ReadDirFile for directories.
 type iofsDir struct {
-	iofs    *IOFS
-	path    string
-	name    string
-	stats   *Stats
-	entries []fs.DirEntry // Lazy-loaded
-	offset  int           // Current position for ReadDir iteration
+	iofs  *IOFS
+	path  string
+	name  string
+	stats *Stats
+	after string // Name of the last entry returned by ReadDir
+	done  bool   // ReadDir has returned the last entry
 }
 
 // Compile-time check that iofsDir implements fs.ReadDirFile
@@ -320,56 +355,40 @@ func (d *iofsDir) Read(b []byte) (int, error) {
 
 // Close implements fs.File.
 func (d *iofsDir) Close() error {
-	d.entries = nil
-	d.offset = 0
+	d.after = ""
+	d.done = false
 	return nil
 }
 
 // ReadDir implements fs.ReadDirFile.
-// Reads the contents of the directory and returns up to n DirEntry values.
-// If n <= 0, ReadDir returns all entries.
+// Reads the contents of the directory and returns up to n DirEntry values,
+// in name order. If n <= 0, ReadDir returns all remaining entries. Entries
+// are read a page at a time, so walking a huge directory with n > 0 does
+// not load it into memory.
 func (d *iofsDir) ReadDir(n int) ([]fs.DirEntry, error) {
-	// Lazy-load entries on first call
-	if d.entries == nil {
-		entries, err := d.iofs.fs.ReaddirPlus(d.iofs.ctx, d.path)
+	var entries []fs.DirEntry
+	for !d.done && (n <= 0 || len(entries) < n) {
+		limit := DefaultReaddirPageSize
+		if n > 0 && n-len(entries) < limit {
+			limit = n - len(entries)
+		}
+		page, err := d.iofs.fs.ReaddirPage(d.iofs.ctx, d.path, ReaddirOptions{After: d.after, Limit: limit})
 		if err != nil {
-			return nil, err
+			return entries, err
 		}
-
-		d.entries = make([]fs.DirEntry, len(entries))
-		for i := range entries {
-			d.entries[i] = &iofsDirEntry{entry: &entries[i]}
+		for i := range page.Entries {
+			entries = append(entries, &iofsDirEntry{entry: &page.Entries[i]})
 		}
-
-		// Sort by name
-		sort.Slice(d.entries, func(i, j int) bool {
-			return d.entries[i].Name() < d.entries[j].Name()
-		})
-	}
-
-	// Return all remaining entries
-	if n <= 0 {
-		if d.offset >= len(d.entries) {
-			return nil, nil
+		if page.Next == "" {
+			d.done = true
+		} else {
+			d.after = page.Next
 		}
-		entries := d.entries[d.offset:]
-		d.offset = len(d.entries)
-		return entries, nil
 	}
 
-	// Return up to n entries
-	if d.offset >= len(d.entries) {
+	if n > 0 && len(entries) == 0 {
 		return nil, io.EOF
 	}
-
-	end := d.offset + n
-	if end > len(d.entries) {
-		end = len(d.entries)
-	}
-
-	entries := d.entries[d.offset:end]
-	d.offset = end
-
 	return entries, nil
 }
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"testing/fstest"
 )
@@ -559,6 +561,38 @@ func TestIOFS_FSTest(t *testing.T) {
 	}
 }
 
+func TestIOFS_HTTPFileServer(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	afs.FS.WriteFile(ctx, "/public/report.txt", []byte("0123456789"), 0o644)
+
+	srv := httptest.NewServer(http.FileServer(http.FS(NewIOFS(afs.FS))))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/public/report.txt")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "0123456789" {
+		t.Errorf("GET = %d %q", resp.StatusCode, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/public/report.txt", nil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "345" {
+		t.Errorf("range GET = %d %q", resp.StatusCode, body)
+	}
+}
+
 // Helper function to check for specific path errors
 func isPathError(err error, target error) bool {
 	if pathErr, ok := err.(*fs.PathError); ok {
@@ -0,0 +1,61 @@
+package agentfs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+// AgentKeySize is the size in bytes of the keys returned by DeriveAgentKey.
+const AgentKeySize = 32
+
+// MinMasterKeySize is the smallest master secret DeriveAgentKey accepts.
+const MinMasterKeySize = 32
+
+// agentKeyInfo binds derived keys to their purpose, so the same master
+// secret can derive unrelated keys for other uses.
+const agentKeyInfo = "agentfs/agent-key/v1"
+
+// DeriveAgentKey derives the at-rest encryption key of agentID from a
+// fleet-wide master secret with HKDF-SHA256 (RFC 5869). The same inputs
+// always yield the same key and different agents get independent keys, so
+// only the master secret needs to be configured and no per-agent keys need
+// to be stored. agentID must be a valid agent ID (see AgentFSOptions.ID).
+//
+// Example:
+//
+//	master, _ := hex.DecodeString(os.Getenv("AGENTFS_MASTER_KEY"))
+//	key, err := agentfs.DeriveAgentKey(master, "agent-42")
+func DeriveAgentKey(master []byte, agentID string) ([]byte, error) {
+	if len(master) < MinMasterKeySize {
+		return nil, fmt.Errorf("master key must be at least %d bytes, got %d", MinMasterKeySize, len(master))
+	}
+	if !validIDPattern.MatchString(agentID) {
+		return nil, fmt.Errorf("invalid agent ID: must match pattern %s", validIDPattern.String())
+	}
+	info := append([]byte(agentKeyInfo+"\x00"), agentID...)
+	return hkdfSHA256(master, nil, info, AgentKeySize), nil
+}
+
+// hkdfSHA256 derives n bytes (at most 255 hash lengths) from secret with
+// HKDF-SHA256: extract with salt, then expand with info.
+func hkdfSHA256(secret, salt, info []byte, n int) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	out := make([]byte, 0, n+sha256.Size)
+	var block []byte
+	for counter := byte(1); len(out) < n; counter++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(block)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		block = expand.Sum(nil)
+		out = append(out, block...)
+	}
+	return out[:n]
+}
@@ -0,0 +1,46 @@
+package agentfs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHKDFSHA256(t *testing.T) {
+	// RFC 5869, test case 1
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	want := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+
+	if got := hex.EncodeToString(hkdfSHA256(ikm, salt, info, 42)); got != want {
+		t.Errorf("hkdfSHA256 = %s, want %s", got, want)
+	}
+}
+
+func TestDeriveAgentKey(t *testing.T) {
+	master := bytes.Repeat([]byte{0x42}, MinMasterKeySize)
+
+	a1, err := DeriveAgentKey(master, "agent-a")
+	if err != nil {
+		t.Fatalf("DeriveAgentKey failed: %v", err)
+	}
+	a2, _ := DeriveAgentKey(master, "agent-a")
+	b, _ := DeriveAgentKey(master, "agent-b")
+	if len(a1) != AgentKeySize {
+		t.Errorf("key size = %d, want %d", len(a1), AgentKeySize)
+	}
+	if !bytes.Equal(a1, a2) {
+		t.Error("derivation is not deterministic")
+	}
+	if bytes.Equal(a1, b) {
+		t.Error("different agents got the same key")
+	}
+
+	if _, err := DeriveAgentKey(master[:16], "agent-a"); err == nil {
+		t.Error("DeriveAgentKey accepted a short master key")
+	}
+	if _, err := DeriveAgentKey(master, "../etc"); err == nil {
+		t.Error("DeriveAgentKey accepted an invalid agent ID")
+	}
+}
@@ -0,0 +1,141 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNotInteger is returned by KV.Incr when the key holds a value other
+// than an integer.
+var ErrNotInteger = errors.New("agentfs: value is not an integer")
+
+// Incr adds delta to the integer stored at key and returns the new value.
+// A missing or expired key counts as 0. The read and the write are one SQL
+// statement, so concurrent increments from any number of workers are never
+// lost; one that finds the database locked by another writer is retried.
+// A key set with SetWithTTL keeps its expiry, so Incr can count within a
+// time window. It fails with ErrNotInteger if the key holds another kind
+// of value.
+//
+// Example:
+//
+//	n, err := afs.KV.Incr(ctx, "stats:requests", 1)
+func (kv *KVStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	if err := kv.checkKey(key); err != nil {
+		return 0, err
+	}
+
+	now := kv.clock.Now()
+	var n int64
+	err = retryBusy(ctx, func() error {
+		return kv.write(ctx, func(db dbtx) error {
+			return setLogged(ctx, db, kv.ns, kv.storeKey(key), func() (bool, error) {
+				err := db.QueryRowContext(ctx, kvIncr, kv.ns, kv.storeKey(key), delta, now.Unix(), now.UnixMilli()).Scan(&n)
+				return true, err
+			})
+		})
+	})
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("key %q: %w", key, ErrNotInteger)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment key: %w", err)
+	}
+	kv.cache.remove(kv.storeKey(key))
+	kv.publish(Event{Kind: EventKVSet, Path: key})
+	return n, nil
+}
+
+// CompareAndSwap stores new at key if its current value equals old, and
+// reports whether it did. Values are compared as JSON, so old must encode
+// the same way as the stored value. A nil old matches only a missing or
+// expired key, which makes CompareAndSwap a create-if-absent. The check
+// and the write are one SQL statement; like Set, a swap clears any expiry.
+//
+// Example:
+//
+//	// Take the lock only if nobody holds it
+//	ok, err := afs.KV.CompareAndSwap(ctx, "lock:deploy", nil, workerID)
+func (kv *KVStore) CompareAndSwap(ctx context.Context, key string, old, new any) (bool, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	if err := kv.checkKey(key); err != nil {
+		return false, err
+	}
+	newJSON, err := json.Marshal(new)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	now := kv.clock.Now()
+	swap, args := kvSetIfAbsent, []any{kv.ns, kv.storeKey(key), string(newJSON), now.Unix(), now.UnixMilli()}
+	if old != nil {
+		oldJSON, err := json.Marshal(old)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal value: %w", err)
+		}
+		swap, args = kvCompareAndSwap, []any{string(newJSON), now.Unix(), kv.storeKey(key), now.UnixMilli(), string(oldJSON)}
+	}
+	var n int64
+	err = kv.write(ctx, func(db dbtx) error {
+		return setLogged(ctx, db, kv.ns, kv.storeKey(key), func() (bool, error) {
+			res, err := db.ExecContext(ctx, swap, args...)
+			if err != nil {
+				return false, err
+			}
+			n, err = res.RowsAffected()
+			return n > 0, err
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to swap key: %w", err)
+	}
+	kv.cache.remove(kv.storeKey(key))
+	if n == 0 {
+		return false, nil
+	}
+	kv.publish(Event{Kind: EventKVSet, Path: key})
+	return true, nil
+}
+
+// GetSet stores value at key and returns the value it replaced, or nil if
+// the key was missing or expired. SQLite cannot return a row's previous
+// value from the statement that changes it, so GetSet runs as a Txn: it is
+// atomic, but retried if another writer changes the key meanwhile.
+//
+// Example:
+//
+//	prev, err := afs.KV.GetSet(ctx, "cursor", next)
+func (kv *KVStore) GetSet(ctx context.Context, key string, value any) (json.RawMessage, error) {
+	var prev json.RawMessage
+	err := kv.Txn(ctx, func(tx KVTx) error {
+		prev = nil
+		ok, err := tx.Has(ctx, key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if prev, err = tx.GetRaw(ctx, key); err != nil {
+				return err
+			}
+		}
+		return tx.Set(ctx, key, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
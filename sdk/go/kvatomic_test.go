@@ -0,0 +1,70 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestKVAtomic(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	t.Run("incr", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 25; j++ {
+					if _, err := afs.KV.Incr(ctx, "hits", 1); err != nil {
+						t.Errorf("Incr failed: %v", err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if n, err := afs.KV.Incr(ctx, "hits", -100); err != nil || n != 100 {
+			t.Errorf("Incr = %d, %v; want 100", n, err)
+		}
+		if v, _ := KVGet[int](ctx, afs.KV, "hits"); v != 100 {
+			t.Errorf("hits = %d, want 100", v)
+		}
+
+		afs.KV.Set(ctx, "name", "x")
+		if _, err := afs.KV.Incr(ctx, "name", 1); !errors.Is(err, ErrNotInteger) {
+			t.Errorf("Incr of a string = %v, want ErrNotInteger", err)
+		}
+	})
+
+	t.Run("compare and swap", func(t *testing.T) {
+		if ok, err := afs.KV.CompareAndSwap(ctx, "lock", nil, "w1"); err != nil || !ok {
+			t.Fatalf("CompareAndSwap(absent) = %v, %v", ok, err)
+		}
+		if ok, _ := afs.KV.CompareAndSwap(ctx, "lock", nil, "w2"); ok {
+			t.Error("create-if-absent replaced an existing key")
+		}
+		if ok, _ := afs.KV.CompareAndSwap(ctx, "lock", "w2", "w3"); ok {
+			t.Error("swap succeeded with a stale old value")
+		}
+		if ok, err := afs.KV.CompareAndSwap(ctx, "lock", "w1", "w3"); err != nil || !ok {
+			t.Errorf("CompareAndSwap(w1) = %v, %v", ok, err)
+		}
+		if v, _ := KVGet[string](ctx, afs.KV, "lock"); v != "w3" {
+			t.Errorf("lock = %q, want w3", v)
+		}
+	})
+
+	t.Run("getset", func(t *testing.T) {
+		prev, err := afs.KV.GetSet(ctx, "cursor", 1)
+		if err != nil || prev != nil {
+			t.Fatalf("GetSet(new) = %s, %v", prev, err)
+		}
+		if prev, _ = afs.KV.GetSet(ctx, "cursor", 2); string(prev) != "1" {
+			t.Errorf("GetSet = %s, want 1", prev)
+		}
+	})
+}
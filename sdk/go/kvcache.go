@@ -0,0 +1,141 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tursodatabase/agentfs/sdk/go/internal/cache"
+)
+
+// kvCached is a value held by the KV cache.
+type kvCached struct {
+	value     string
+	expiresAt int64 // Unix milliseconds; 0 if the entry does not expire
+}
+
+// kvCache is the read-through cache of KV values (see KVCacheOptions).
+// Writes through this AgentFS remove the keys they change. Writes by other
+// connections are noticed through PRAGMA data_version, which changes on a
+// connection when others commit, so the cache holds one of its own; the
+// KV change log then tells whether the commits touched the KV store.
+type kvCache struct {
+	values   *cache.ValueLRU[kvCached]
+	conn     *sql.Conn
+	maxStale time.Duration
+
+	mu      sync.Mutex // guards the fields below and serializes checks on conn
+	version int64      // data_version at the last check
+	seq     int64      // KV change log head at the last check
+	checked time.Time
+}
+
+func newKVCache(ctx context.Context, db *sql.DB, opts KVCacheOptions) (*kvCache, error) {
+	values, err := cache.NewValueLRU[kvCached](opts.MaxEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KV cache: %w", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KV cache: %w", err)
+	}
+	// The first read checks for changes, which also sets version and seq
+	return &kvCache{values: values, conn: conn, maxStale: opts.MaxStaleness, version: -1}, nil
+}
+
+// validate empties the cache if the KV store has changed since the last
+// check, unless that was less than MaxStaleness ago.
+func (c *kvCache) validate(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxStale > 0 && time.Since(c.checked) < c.maxStale {
+		return nil
+	}
+
+	var version int64
+	if err := c.conn.QueryRowContext(ctx, "PRAGMA data_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to check KV cache: %w", err)
+	}
+	if version != c.version {
+		var seq int64
+		if err := c.conn.QueryRowContext(ctx, kvChangeHead).Scan(&seq); err != nil {
+			return fmt.Errorf("failed to check KV cache: %w", err)
+		}
+		if seq != c.seq {
+			c.values.Purge()
+		}
+		c.version, c.seq = version, seq
+	}
+	c.checked = time.Now()
+	return nil
+}
+
+// remove drops the stored key after a write.
+func (c *kvCache) remove(stored string) {
+	if c != nil {
+		c.values.Remove(stored)
+	}
+}
+
+// purge drops all keys after a write to many.
+func (c *kvCache) purge() {
+	if c != nil {
+		c.values.Purge()
+	}
+}
+
+// close releases the cache's connection.
+func (c *kvCache) close() error {
+	if c == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// lookup returns the JSON value of key, from the cache if it is enabled.
+func (kv *KVStore) lookup(ctx context.Context, key string) (string, error) {
+	stored := kv.storeKey(key)
+	now := kv.clock.Now().UnixMilli()
+	if kv.cache == nil {
+		var value string
+		err := kv.db.QueryRowContext(ctx, kvGet, stored, now).Scan(&value)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("key not found: %s", key)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get key: %w", err)
+		}
+		return value, nil
+	}
+
+	if err := kv.cache.validate(ctx); err != nil {
+		return "", err
+	}
+	if v, ok := kv.cache.values.Get(stored); ok && (v.expiresAt == 0 || v.expiresAt > now) {
+		return v.value, nil
+	}
+	gen := kv.cache.values.Generation()
+	var v kvCached
+	err := kv.db.QueryRowContext(ctx, kvGetCached, stored, now).Scan(&v.value, &v.expiresAt)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("key not found: %s", key)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get key: %w", err)
+	}
+	kv.cache.values.Add(stored, v, gen)
+	return v.value, nil
+}
+
+// CacheStats returns statistics of the KV cache: Hits counts the reads it
+// answered, Misses those passed on to SQLite, and Entries the keys it
+// holds. It returns nil unless AgentFSOptions.KVCache is set.
+func (kv *KVStore) CacheStats() *cache.Stats {
+	if kv.cache == nil {
+		return nil
+	}
+	stats := kv.cache.values.Stats()
+	return &stats
+}
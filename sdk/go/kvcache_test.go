@@ -0,0 +1,89 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKVCache(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "kvcache.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: dbPath, KVCache: KVCacheOptions{MaxEntries: 16}})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	other, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer other.Close()
+
+	get := func(key string) string {
+		t.Helper()
+		var v string
+		if err := afs.KV.Get(ctx, key, &v); err != nil {
+			t.Fatalf("Get(%s) failed: %v", key, err)
+		}
+		return v
+	}
+
+	afs.KV.Set(ctx, "config", "a")
+	get("config")
+	get("config")
+	if stats := afs.KV.CacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("CacheStats = %+v, want 1 hit and 1 miss", stats)
+	}
+
+	// Local writes are seen at once
+	afs.KV.Set(ctx, "config", "b")
+	if got := get("config"); got != "b" {
+		t.Errorf("after local Set: %q, want b", got)
+	}
+	afs.KV.Delete(ctx, "config")
+	if err := afs.KV.Get(ctx, "config", new(string)); err == nil {
+		t.Error("Get after Delete succeeded")
+	}
+
+	// So are writes by other connections
+	afs.KV.Set(ctx, "config", "c")
+	get("config")
+	other.KV.Set(ctx, "config", "d")
+	if got := get("config"); got != "d" {
+		t.Errorf("after external Set: %q, want d", got)
+	}
+
+	// Writes that do not touch the KV store keep the cache
+	get("config")
+	other.FS.WriteFile(ctx, "/notes.txt", []byte("x"), 0o644)
+	before := afs.KV.CacheStats().Hits
+	get("config")
+	if afs.KV.CacheStats().Hits != before+1 {
+		t.Error("filesystem write emptied the KV cache")
+	}
+}
+
+func TestKVCache_Expiry(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Unix(1_700_000_000, 0))
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:    filepath.Join(t.TempDir(), "kvcache.db"),
+		Clock:   clock,
+		KVCache: KVCacheOptions{MaxEntries: 16},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	afs.KV.SetWithTTL(ctx, "token", "t", time.Minute)
+	if _, err := afs.KV.GetRaw(ctx, "token"); err != nil {
+		t.Fatalf("GetRaw failed: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := afs.KV.GetRaw(ctx, "token"); err == nil {
+		t.Error("GetRaw served an expired value from the cache")
+	}
+}
@@ -0,0 +1,109 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// kvNamespaceSep joins a namespace and a key into the stored key. Keeping
+// stored keys distinct lets namespaces share kv_store's primary key, which
+// other SDKs rely on; the separator is a control character so no key
+// written through the root store can collide with a namespaced one.
+const kvNamespaceSep = "\x1f"
+
+// Namespace returns a view of the store scoped to name. Keys set through it
+// are separate from the root store's keys and every other namespace's, so
+// components of one agent can each keep their own "state" or "step" key;
+// Keys, List, Clear, DeletePrefix, and Count cover only the namespace.
+// Namespaces nest: Namespace("a").Namespace("b") is the namespace "a/b".
+// The root store does not see namespaced keys; Namespaces lists the
+// namespaces in use.
+//
+// Name is usually a constant, so Namespace panics if it is empty or
+// contains a control character.
+//
+// Example:
+//
+//	planner := afs.KV.Namespace("planner")
+//	planner.Set(ctx, "step", 3)
+//	n, err := planner.Count(ctx)
+func (kv *KVStore) Namespace(name string) *KVStore {
+	if name == "" || strings.IndexFunc(name, isControl) >= 0 {
+		panic(fmt.Sprintf("agentfs: invalid KV namespace %q", name))
+	}
+	nkv := *kv
+	if kv.ns != "" {
+		name = kv.ns + "/" + name
+	}
+	nkv.ns = name
+	return &nkv
+}
+
+// Count returns the number of keys in the store's namespace, including
+// system keys.
+func (kv *KVStore) Count(ctx context.Context) (int64, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	var n int64
+	if err := kv.db.QueryRowContext(ctx, kvCount, kv.ns, kv.clock.Now().UnixMilli()).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count keys: %w", err)
+	}
+	return n, nil
+}
+
+// Namespaces lists the namespaces that hold at least one key, sorted.
+// Nested namespaces are listed by their full name, such as "a/b".
+func (kv *KVStore) Namespaces(ctx context.Context) ([]string, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := kv.db.QueryContext(ctx, kvNamespaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// storeKey returns the key stored in kv_store for key.
+func (kv *KVStore) storeKey(key string) string {
+	if kv.ns == "" {
+		return key
+	}
+	return kv.ns + kvNamespaceSep + key
+}
+
+// userKey returns the key within kv's namespace for a stored key.
+func (kv *KVStore) userKey(stored string) string {
+	return splitStoreKey(kv.ns, stored)
+}
+
+// splitStoreKey strips namespace ns from a stored key.
+func splitStoreKey(ns, stored string) string {
+	if ns == "" {
+		return stored
+	}
+	return strings.TrimPrefix(stored, ns+kvNamespaceSep)
+}
+
+// isControl reports whether r is an ASCII control character.
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
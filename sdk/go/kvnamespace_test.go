@@ -0,0 +1,79 @@
+package agentfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestKVNamespace(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	planner := afs.KV.Namespace("planner")
+	executor := afs.KV.Namespace("executor")
+	afs.KV.Set(ctx, "step", 1)
+	planner.Set(ctx, "step", 2)
+	planner.Set(ctx, "plan:a", "x")
+	executor.Set(ctx, "step", 3)
+
+	for _, tc := range []struct {
+		kv   *KVStore
+		want int
+	}{{afs.KV, 1}, {planner, 2}, {executor, 3}} {
+		if step, err := KVGet[int](ctx, tc.kv, "step"); err != nil || step != tc.want {
+			t.Errorf("step in %q = %d, %v; want %d", tc.kv.ns, step, err, tc.want)
+		}
+	}
+
+	if keys, _ := planner.Keys(ctx, ""); !reflect.DeepEqual(keys, []string{"plan:a", "step"}) {
+		t.Errorf("planner keys = %v", keys)
+	}
+	if entries, _ := planner.List(ctx, "plan:"); len(entries) != 1 || entries[0].Key != "plan:a" {
+		t.Errorf("planner List(plan:) = %+v", entries)
+	}
+	if keys, _ := afs.KV.Keys(ctx, ""); !reflect.DeepEqual(keys, []string{"step"}) {
+		t.Errorf("root keys = %v", keys)
+	}
+	if n, err := planner.Count(ctx); err != nil || n != 2 {
+		t.Errorf("planner Count = %d, %v; want 2", n, err)
+	}
+	if names, _ := afs.KV.Namespaces(ctx); !reflect.DeepEqual(names, []string{"executor", "planner"}) {
+		t.Errorf("Namespaces = %v", names)
+	}
+
+	if err := planner.Clear(ctx, ""); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if n, _ := planner.Count(ctx); n != 0 {
+		t.Errorf("planner Count after Clear = %d", n)
+	}
+	if ok, _ := executor.Has(ctx, "step"); !ok {
+		t.Error("Clear in planner removed executor's key")
+	}
+	if ok, _ := afs.KV.Has(ctx, "step"); !ok {
+		t.Error("Clear in planner removed the root key")
+	}
+
+	err := executor.Txn(ctx, func(tx KVTx) error {
+		var step int
+		if err := tx.Get(ctx, "step", &step); err != nil {
+			return err
+		}
+		return tx.Set(ctx, "step", step+1)
+	})
+	if err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+	if step, _ := KVGet[int](ctx, executor, "step"); step != 4 {
+		t.Errorf("executor step after Txn = %d, want 4", step)
+	}
+
+	if got := planner.Namespace("sub").ns; got != "planner/sub" {
+		t.Errorf("nested namespace = %q", got)
+	}
+	if err := afs.KV.Set(ctx, "planner"+kvNamespaceSep+"step", 0); err == nil {
+		t.Error("expected a key containing the separator to be rejected")
+	}
+}
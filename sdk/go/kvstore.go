@@ -6,37 +6,81 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // KVStore provides key-value storage backed by SQLite.
 type KVStore struct {
-	db *sql.DB
+	db      dbtx
+	conn    sqlConn // nil when db is a transaction (see AgentFS.Begin)
+	life    *lifecycle
+	events  *eventBus
+	pending *[]Event // events held until the transaction commits
+	clock   Clock
+	system  bool     // may modify system keys (see systemKV)
+	ns      string   // namespace; "" for the root store (see Namespace)
+	cache   *kvCache // nil unless AgentFSOptions.KVCache is set, and in transactions
 }
 
 // Set stores a value (JSON-serialized) for the given key.
 func (kv *KVStore) Set(ctx context.Context, key string, value any) error {
+	return kv.set(ctx, key, value, 0, "")
+}
+
+// set stores value for key, expiring after ttl if ttl > 0, and tagged with
+// tag if it is not empty (see KVSetAs).
+func (kv *KVStore) set(ctx context.Context, key string, value any, ttl time.Duration, tag string) error {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := kv.checkKey(key); err != nil {
+		return err
+	}
+
 	jsonValue, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
 
-	if _, err := kv.db.ExecContext(ctx, kvSet, key, string(jsonValue)); err != nil {
+	now := kv.clock.Now()
+	var expiresAt sql.NullInt64
+	if ttl > 0 {
+		expiresAt = sql.NullInt64{Int64: now.Add(ttl).UnixMilli(), Valid: true}
+	}
+	var typeTag sql.NullString
+	if tag != "" {
+		typeTag = sql.NullString{String: tag, Valid: true}
+	}
+	err = kv.write(ctx, func(db dbtx) error {
+		return setLogged(ctx, db, kv.ns, kv.storeKey(key), func() (bool, error) {
+			_, err := db.ExecContext(ctx, kvSet, kv.ns, kv.storeKey(key), string(jsonValue), now.Unix(), now.Unix(), expiresAt, now.UnixMilli(), typeTag)
+			return true, err
+		})
+	})
+	if err != nil {
 		return fmt.Errorf("failed to set key: %w", err)
 	}
+	kv.cache.remove(kv.storeKey(key))
 
+	kv.publish(Event{Kind: EventKVSet, Path: key})
 	return nil
 }
 
 // Get retrieves a value and unmarshals it into dest.
 // Returns an error if the key does not exist.
 func (kv *KVStore) Get(ctx context.Context, key string, dest any) error {
-	var jsonValue string
-	err := kv.db.QueryRowContext(ctx, kvGet, key).Scan(&jsonValue)
-	if err == sql.ErrNoRows {
-		return fmt.Errorf("key not found: %s", key)
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer done()
+
+	jsonValue, err := kv.lookup(ctx, key)
 	if err != nil {
-		return fmt.Errorf("failed to get key: %w", err)
+		return err
 	}
 
 	if err := json.Unmarshal([]byte(jsonValue), dest); err != nil {
@@ -49,13 +93,15 @@ func (kv *KVStore) Get(ctx context.Context, key string, dest any) error {
 // GetRaw retrieves the raw JSON value for a key.
 // Returns an error if the key does not exist.
 func (kv *KVStore) GetRaw(ctx context.Context, key string) (json.RawMessage, error) {
-	var jsonValue string
-	err := kv.db.QueryRowContext(ctx, kvGet, key).Scan(&jsonValue)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("key not found: %s", key)
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer done()
+
+	jsonValue, err := kv.lookup(ctx, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get key: %w", err)
+		return nil, err
 	}
 
 	return json.RawMessage(jsonValue), nil
@@ -63,16 +109,41 @@ func (kv *KVStore) GetRaw(ctx context.Context, key string) (json.RawMessage, err
 
 // Delete removes a key.
 func (kv *KVStore) Delete(ctx context.Context, key string) error {
-	if _, err := kv.db.ExecContext(ctx, kvDelete, key); err != nil {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := kv.checkKey(key); err != nil {
+		return err
+	}
+
+	err = kv.write(ctx, func(db dbtx) error {
+		if _, err := db.ExecContext(ctx, kvLogDelete, kv.storeKey(key)); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, kvDelete, kv.storeKey(key))
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("failed to delete key: %w", err)
 	}
+	kv.cache.remove(kv.storeKey(key))
+	kv.publish(Event{Kind: EventKVDeleted, Path: key})
 	return nil
 }
 
 // Has checks if a key exists.
 func (kv *KVStore) Has(ctx context.Context, key string) (bool, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
 	var exists int
-	err := kv.db.QueryRowContext(ctx, kvHas, key).Scan(&exists)
+	err = kv.db.QueryRowContext(ctx, kvHas, kv.storeKey(key), kv.clock.Now().UnixMilli()).Scan(&exists)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -85,14 +156,19 @@ func (kv *KVStore) Has(ctx context.Context, key string) (bool, error) {
 // Keys returns all keys, optionally filtered by prefix.
 // If prefix is empty, all keys are returned.
 func (kv *KVStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	var rows *sql.Rows
-	var err error
 
 	if prefix == "" {
-		rows, err = kv.db.QueryContext(ctx, kvKeys)
+		rows, err = kv.db.QueryContext(ctx, kvKeys, kv.ns, kv.clock.Now().UnixMilli())
 	} else {
-		pattern := escapePattern(prefix) + "%"
-		rows, err = kv.db.QueryContext(ctx, kvKeysWithPrefix, pattern)
+		pattern := escapePattern(kv.storeKey(prefix)) + "%"
+		rows, err = kv.db.QueryContext(ctx, kvKeysWithPrefix, kv.ns, kv.clock.Now().UnixMilli(), pattern)
 	}
 
 	if err != nil {
@@ -106,7 +182,7 @@ func (kv *KVStore) Keys(ctx context.Context, prefix string) ([]string, error) {
 		if err := rows.Scan(&key); err != nil {
 			return nil, err
 		}
-		keys = append(keys, key)
+		keys = append(keys, kv.userKey(key))
 	}
 
 	return keys, rows.Err()
@@ -114,14 +190,19 @@ func (kv *KVStore) Keys(ctx context.Context, prefix string) ([]string, error) {
 
 // List returns all key entries with metadata, optionally filtered by prefix.
 func (kv *KVStore) List(ctx context.Context, prefix string) ([]KVEntry, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	var rows *sql.Rows
-	var err error
 
 	if prefix == "" {
-		rows, err = kv.db.QueryContext(ctx, kvList)
+		rows, err = kv.db.QueryContext(ctx, kvList, kv.ns, kv.clock.Now().UnixMilli())
 	} else {
-		pattern := escapePattern(prefix) + "%"
-		rows, err = kv.db.QueryContext(ctx, kvListWithPrefix, pattern)
+		pattern := escapePattern(kv.storeKey(prefix)) + "%"
+		rows, err = kv.db.QueryContext(ctx, kvListWithPrefix, kv.ns, kv.clock.Now().UnixMilli(), pattern)
 	}
 
 	if err != nil {
@@ -132,9 +213,10 @@ func (kv *KVStore) List(ctx context.Context, prefix string) ([]KVEntry, error) {
 	var entries []KVEntry
 	for rows.Next() {
 		var entry KVEntry
-		if err := rows.Scan(&entry.Key, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+		if err := rows.Scan(&entry.Key, &entry.CreatedAt, &entry.UpdatedAt, &entry.ExpiresAt, &entry.Type); err != nil {
 			return nil, err
 		}
+		entry.Key = kv.userKey(entry.Key)
 		entries = append(entries, entry)
 	}
 
@@ -142,20 +224,50 @@ func (kv *KVStore) List(ctx context.Context, prefix string) ([]KVEntry, error) {
 }
 
 // Clear removes all keys, optionally filtered by prefix.
+// System keys (see SystemKeyPrefix) are never removed.
 func (kv *KVStore) Clear(ctx context.Context, prefix string) error {
-	var err error
+	_, err := kv.DeletePrefix(ctx, prefix)
+	return err
+}
 
-	if prefix == "" {
-		_, err = kv.db.ExecContext(ctx, kvClear)
-	} else {
-		pattern := escapePattern(prefix) + "%"
-		_, err = kv.db.ExecContext(ctx, kvClearWithPrefix, pattern)
+// DeletePrefix removes every key starting with prefix with one DELETE
+// statement, in a transaction with the changelog entries of the keys (see
+// Watch), and returns how many keys it removed. An empty prefix removes
+// all keys. System keys (see SystemKeyPrefix) are never removed.
+func (kv *KVStore) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	if err := kv.checkKey(prefix); err != nil {
+		return 0, err
 	}
 
+	log, clear, args := kvLogClear, kvClear, []any{kv.ns}
+	if prefix != "" {
+		log, clear = kvLogClearWithPrefix, kvClearWithPrefix
+		args = append(args, escapePattern(kv.storeKey(prefix))+"%")
+	}
+	var n int64
+	err = kv.write(ctx, func(db dbtx) error {
+		if _, err := db.ExecContext(ctx, log, args...); err != nil {
+			return err
+		}
+		res, err := db.ExecContext(ctx, clear, args...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to clear keys: %w", err)
+		return 0, fmt.Errorf("failed to clear keys: %w", err)
 	}
-	return nil
+	kv.cache.purge()
+	kv.publish(Event{Kind: EventKVDeleted, Path: prefix})
+	return n, nil
 }
 
 // escapePattern escapes special characters for LIKE pattern matching
@@ -0,0 +1,111 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultKVReapInterval is how often expired KV entries are removed in the
+// background. Reads skip expired entries whether or not they were removed.
+const DefaultKVReapInterval = time.Minute
+
+// SetWithTTL stores a value like Set that expires after ttl. Once expired,
+// the key reads as missing and is removed by a background reaper. Set and
+// Txn writes replace the expiry with none; Touch extends it.
+//
+// Example:
+//
+//	afs.KV.SetWithTTL(ctx, "auth:token", token, 55*time.Minute)
+func (kv *KVStore) SetWithTTL(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid TTL %v: must be positive", ttl)
+	}
+	return kv.set(ctx, key, value, ttl, "")
+}
+
+// Touch makes key expire ttl from now, or never if ttl <= 0. It returns an
+// error if the key does not exist or has expired.
+func (kv *KVStore) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := kv.checkKey(key); err != nil {
+		return err
+	}
+
+	now := kv.clock.Now()
+	var expiresAt sql.NullInt64
+	if ttl > 0 {
+		expiresAt = sql.NullInt64{Int64: now.Add(ttl).UnixMilli(), Valid: true}
+	}
+	res, err := kv.db.ExecContext(ctx, kvTouch, expiresAt, kv.storeKey(key), now.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to touch key: %w", err)
+	}
+	kv.cache.remove(kv.storeKey(key))
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("key not found: %s", key)
+	}
+	return nil
+}
+
+// reapExpired removes the expired entries of every namespace and reports
+// each as deleted.
+func (kv *KVStore) reapExpired(ctx context.Context) (int64, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	var events []Event
+	now := kv.clock.Now().UnixMilli()
+	err = kv.write(ctx, func(db dbtx) error {
+		if _, err := db.ExecContext(ctx, kvLogReap, now); err != nil {
+			return err
+		}
+		rows, err := db.QueryContext(ctx, kvReap, now)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var ns, key string
+			if err := rows.Scan(&ns, &key); err != nil {
+				return err
+			}
+			events = append(events, Event{Kind: EventKVDeleted, Path: splitStoreKey(ns, key), Namespace: ns})
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired keys: %w", err)
+	}
+	for _, e := range events {
+		deliver(kv.events, kv.pending, e)
+	}
+	return int64(len(events)), nil
+}
+
+// startKVReaper removes expired KV entries, and KV changes kept for
+// watches (see KVStore.Watch), in the background.
+func (a *AgentFS) startKVReaper() {
+	a.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(DefaultKVReapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			a.KV.reapExpired(context.Background()) // Expired keys already read as missing
+			a.KV.trimChanges(context.Background())
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKVTTL(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	afs, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "test.db"), Clock: clock})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	if err := afs.KV.SetWithTTL(ctx, "token", "abc", time.Minute); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	afs.KV.SetWithTTL(ctx, "cache", 1, time.Minute)
+	afs.KV.Set(ctx, "config", "keep")
+	if err := afs.KV.SetWithTTL(ctx, "bad", 1, 0); err == nil {
+		t.Error("expected a zero TTL to be rejected")
+	}
+
+	entries, _ := afs.KV.List(ctx, "token")
+	if len(entries) != 1 || entries[0].ExpiresAt != clock.Now().Add(time.Minute).UnixMilli() {
+		t.Errorf("List(token) = %+v", entries)
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := afs.KV.Touch(ctx, "cache", time.Minute); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+
+	clock.Advance(45 * time.Second)
+	if ok, _ := afs.KV.Has(ctx, "token"); ok {
+		t.Error("token still readable after it expired")
+	}
+	if err := afs.KV.Get(ctx, "token", new(string)); err == nil {
+		t.Error("Get of an expired key succeeded")
+	}
+	if err := afs.KV.Touch(ctx, "token", time.Minute); err == nil {
+		t.Error("Touch of an expired key succeeded")
+	}
+	if keys, _ := afs.KV.Keys(ctx, ""); len(keys) != 2 {
+		t.Errorf("keys = %v, want cache and config", keys)
+	}
+
+	events, cancel := afs.Subscribe(EventFilter{Kinds: []EventKind{EventKVDeleted}}, 0)
+	defer cancel()
+	if n, err := afs.KV.reapExpired(ctx); err != nil || n != 1 {
+		t.Errorf("reapExpired = %d, %v; want 1", n, err)
+	}
+	if e := <-events; e.Path != "token" {
+		t.Errorf("reaped event path = %q, want token", e.Path)
+	}
+
+	// Set clears the expiry
+	afs.KV.Set(ctx, "cache", 2)
+	clock.Advance(time.Hour)
+	if v, err := KVGet[int](ctx, afs.KV, "cache"); err != nil || v != 2 {
+		t.Errorf("cache = %d, %v; want 2", v, err)
+	}
+}
@@ -0,0 +1,324 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// DefaultKVTxnAttempts is how many times KV.Txn runs its function before
+// giving up with ErrTxnConflict.
+const DefaultKVTxnAttempts = 10
+
+// ErrTxnConflict is returned by KV.Txn when every attempt conflicted with a
+// concurrent writer.
+var ErrTxnConflict = errors.New("agentfs: transaction conflict")
+
+// KVTx reads and writes the KV store within KV.Txn. Reads see the
+// transaction's own writes; writes are applied when the function returns.
+type KVTx interface {
+	// Get retrieves a value and unmarshals it into dest.
+	Get(ctx context.Context, key string, dest any) error
+
+	// GetRaw retrieves the raw JSON value for a key.
+	GetRaw(ctx context.Context, key string) (json.RawMessage, error)
+
+	// Has checks if a key exists.
+	Has(ctx context.Context, key string) (bool, error)
+
+	// Set stores a value (JSON-serialized) for the given key.
+	Set(ctx context.Context, key string, value any) error
+
+	// Delete removes a key.
+	Delete(ctx context.Context, key string) error
+}
+
+// kvTx implements KVTx. Reads run against a snapshot and are remembered in
+// reads; writes are buffered until commit.
+type kvTx struct {
+	kv     *KVStore
+	tx     dbtx
+	reads  map[string]*string // nil value: key did not exist
+	writes map[string]*string // nil value: delete
+	order  []string
+}
+
+// Txn runs fn as one atomic transaction over multiple keys.
+//
+// fn reads from a consistent snapshot and its writes are buffered. On
+// return, the keys it read are checked against the current store: if any
+// changed, fn is run again with a fresh snapshot, so invariants spanning
+// several keys hold even with interleaved writers. fn may therefore run
+// more than once and should not have side effects outside tx. After
+// DefaultKVTxnAttempts conflicts, Txn returns ErrTxnConflict. An error
+// from fn aborts the transaction without retrying.
+//
+// Example:
+//
+//	err := afs.KV.Txn(ctx, func(tx agentfs.KVTx) error {
+//	    var from, to int
+//	    if err := tx.Get(ctx, "balance:a", &from); err != nil {
+//	        return err
+//	    }
+//	    if err := tx.Get(ctx, "balance:b", &to); err != nil {
+//	        return err
+//	    }
+//	    tx.Set(ctx, "balance:a", from-10)
+//	    return tx.Set(ctx, "balance:b", to+10)
+//	})
+func (kv *KVStore) Txn(ctx context.Context, fn func(tx KVTx) error) error {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if kv.conn == nil {
+		// Inside AgentFS.Begin the enclosing transaction already isolates fn
+		t := &kvTx{kv: kv, tx: kv.db, reads: map[string]*string{}, writes: map[string]*string{}}
+		if err := fn(t); err != nil {
+			return err
+		}
+		t.tx = nil
+		if err := kv.applyTxn(ctx, kv.db, t); err != nil {
+			return err
+		}
+		kv.publishTxn(t)
+		return nil
+	}
+
+	for attempt := 0; attempt < DefaultKVTxnAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, txnBackoff(attempt)); err != nil {
+				return err
+			}
+		}
+
+		t, err := kv.runTxn(ctx, fn)
+		if err != nil {
+			if isBusyError(err) {
+				continue
+			}
+			return err
+		}
+
+		committed, err := kv.commitTxn(ctx, t)
+		if err != nil {
+			if isBusyError(err) {
+				continue
+			}
+			return err
+		}
+		if committed {
+			kv.publishTxn(t)
+			return nil
+		}
+	}
+	return ErrTxnConflict
+}
+
+// runTxn runs fn against a read snapshot and returns its read and write sets.
+func (kv *KVStore) runTxn(ctx context.Context, fn func(tx KVTx) error) (*kvTx, error) {
+	// Writes are buffered, so the snapshot need not hold the write lock
+	tx, err := kv.conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	t := &kvTx{kv: kv, tx: tx, reads: map[string]*string{}, writes: map[string]*string{}}
+	if err := fn(t); err != nil {
+		return nil, err
+	}
+	t.tx = nil
+	return t, nil
+}
+
+// commitTxn applies t's writes if none of the keys it read have changed.
+// It reports false on a conflict.
+func (kv *KVStore) commitTxn(ctx context.Context, t *kvTx) (bool, error) {
+	if len(t.writes) == 0 {
+		return true, nil
+	}
+
+	tx, err := kv.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, was := range t.reads {
+		now, err := readKV(ctx, tx, kv.storeKey(key), kv.clock.Now())
+		if err != nil {
+			return false, err
+		}
+		if (was == nil) != (now == nil) || (was != nil && *was != *now) {
+			return false, nil
+		}
+	}
+
+	if err := kv.applyTxn(ctx, tx, t); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// applyTxn writes t's buffered writes to db.
+func (kv *KVStore) applyTxn(ctx context.Context, db dbtx, t *kvTx) error {
+	now := kv.clock.Now()
+	for _, key := range t.order {
+		var err error
+		if value := t.writes[key]; value != nil {
+			err = setLogged(ctx, db, kv.ns, kv.storeKey(key), func() (bool, error) {
+				_, err := db.ExecContext(ctx, kvSet, kv.ns, kv.storeKey(key), *value, now.Unix(), now.Unix(), nil, now.UnixMilli(), nil)
+				return true, err
+			})
+		} else if _, err = db.ExecContext(ctx, kvLogDelete, kv.storeKey(key)); err == nil {
+			_, err = db.ExecContext(ctx, kvDelete, kv.storeKey(key))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write key: %w", err)
+		}
+	}
+	return nil
+}
+
+// publishTxn reports the keys t wrote.
+func (kv *KVStore) publishTxn(t *kvTx) {
+	for _, key := range t.order {
+		kv.cache.remove(kv.storeKey(key))
+		if t.writes[key] == nil {
+			kv.publish(Event{Kind: EventKVDeleted, Path: key})
+		} else {
+			kv.publish(Event{Kind: EventKVSet, Path: key})
+		}
+	}
+}
+
+// readKV returns the stored JSON for key, or nil if it does not exist or
+// expired before now.
+func readKV(ctx context.Context, db dbtx, key string, now time.Time) (*string, error) {
+	var value string
+	err := db.QueryRowContext(ctx, kvGet, key, now.UnixMilli()).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key: %w", err)
+	}
+	return &value, nil
+}
+
+// lookup returns the value of key as seen by the transaction.
+func (t *kvTx) lookup(ctx context.Context, key string) (*string, error) {
+	if value, ok := t.writes[key]; ok {
+		return value, nil
+	}
+	if value, ok := t.reads[key]; ok {
+		return value, nil
+	}
+	if t.tx == nil {
+		return nil, errors.New("transaction has finished")
+	}
+	value, err := readKV(ctx, t.tx, t.kv.storeKey(key), t.kv.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	t.reads[key] = value
+	return value, nil
+}
+
+func (t *kvTx) Get(ctx context.Context, key string, dest any) error {
+	raw, err := t.GetRaw(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+	return nil
+}
+
+func (t *kvTx) GetRaw(ctx context.Context, key string) (json.RawMessage, error) {
+	value, err := t.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	return json.RawMessage(*value), nil
+}
+
+func (t *kvTx) Has(ctx context.Context, key string) (bool, error) {
+	value, err := t.lookup(ctx, key)
+	return value != nil, err
+}
+
+func (t *kvTx) Set(ctx context.Context, key string, value any) error {
+	if err := t.kv.checkKey(key); err != nil {
+		return err
+	}
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	s := string(jsonValue)
+	t.write(key, &s)
+	return nil
+}
+
+func (t *kvTx) Delete(ctx context.Context, key string) error {
+	if err := t.kv.checkKey(key); err != nil {
+		return err
+	}
+	t.write(key, nil)
+	return nil
+}
+
+// write buffers a write, remembering the order keys were first written.
+func (t *kvTx) write(key string, value *string) {
+	if _, ok := t.writes[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.writes[key] = value
+}
+
+// txnBackoff returns a randomized delay before retry attempt n.
+func txnBackoff(attempt int) time.Duration {
+	base := time.Millisecond << uint(attempt)
+	if base > 100*time.Millisecond {
+		base = 100 * time.Millisecond
+	}
+	return base/2 + time.Duration(rand.Int63n(int64(base)))
+}
+
+// retryBusy runs fn, a single write statement that has no effect if it
+// fails with SQLITE_BUSY, until it gets past the other writers or
+// DefaultKVTxnAttempts attempts are made.
+func retryBusy(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isBusyError(err) || attempt >= DefaultKVTxnAttempts {
+			return err
+		}
+		if err := sleepContext(ctx, txnBackoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// isBusyError reports whether err is SQLite's SQLITE_BUSY or SQLITE_LOCKED.
+func isBusyError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "SQLITE_BUSY") || strings.Contains(msg, "SQLITE_LOCKED") ||
+		strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
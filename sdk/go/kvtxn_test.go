@@ -0,0 +1,124 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestKVTxn(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.KV.Set(ctx, "a", 100)
+	afs.KV.Set(ctx, "b", 0)
+
+	t.Run("reads own writes", func(t *testing.T) {
+		err := afs.KV.Txn(ctx, func(tx KVTx) error {
+			if err := tx.Set(ctx, "c", "x"); err != nil {
+				return err
+			}
+			var c string
+			if err := tx.Get(ctx, "c", &c); err != nil || c != "x" {
+				t.Errorf("Get(c) = %q, %v; want x", c, err)
+			}
+			if err := tx.Delete(ctx, "c"); err != nil {
+				return err
+			}
+			if ok, _ := tx.Has(ctx, "c"); ok {
+				t.Error("Has(c) after Delete = true")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Txn failed: %v", err)
+		}
+		if ok, _ := afs.KV.Has(ctx, "c"); ok {
+			t.Error("c exists after Txn deleted it")
+		}
+	})
+
+	t.Run("error aborts", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := afs.KV.Txn(ctx, func(tx KVTx) error {
+			tx.Set(ctx, "a", 0)
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Fatalf("Txn: err = %v, want boom", err)
+		}
+		var a int
+		afs.KV.Get(ctx, "a", &a)
+		if a != 100 {
+			t.Errorf("a = %d after aborted Txn, want 100", a)
+		}
+	})
+
+	t.Run("retries on conflict", func(t *testing.T) {
+		runs := 0
+		err := afs.KV.Txn(ctx, func(tx KVTx) error {
+			runs++
+			var a int
+			if err := tx.Get(ctx, "a", &a); err != nil {
+				return err
+			}
+			if runs == 1 {
+				// Interleaved writer changes a key in the read set
+				if err := afs.KV.Set(ctx, "a", a+1); err != nil {
+					return err
+				}
+			}
+			return tx.Set(ctx, "b", a)
+		})
+		if err != nil {
+			t.Fatalf("Txn failed: %v", err)
+		}
+		if runs != 2 {
+			t.Errorf("runs = %d, want 2", runs)
+		}
+		var b int
+		afs.KV.Get(ctx, "b", &b)
+		if b != 101 {
+			t.Errorf("b = %d, want 101", b)
+		}
+	})
+
+	t.Run("concurrent transfers keep sum", func(t *testing.T) {
+		afs.KV.Set(ctx, "a", 100)
+		afs.KV.Set(ctx, "b", 0)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 5; j++ {
+					err := afs.KV.Txn(ctx, func(tx KVTx) error {
+						var a, b int
+						if err := tx.Get(ctx, "a", &a); err != nil {
+							return err
+						}
+						if err := tx.Get(ctx, "b", &b); err != nil {
+							return err
+						}
+						tx.Set(ctx, "a", a-1)
+						return tx.Set(ctx, "b", b+1)
+					})
+					if err != nil && !errors.Is(err, ErrTxnConflict) {
+						t.Errorf("Txn failed: %v", err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		var a, b int
+		afs.KV.Get(ctx, "a", &a)
+		afs.KV.Get(ctx, "b", &b)
+		if a+b != 100 {
+			t.Errorf("a + b = %d + %d, want 100", a, b)
+		}
+	})
+}
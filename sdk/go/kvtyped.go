@@ -0,0 +1,102 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// KVTyper is implemented by types that declare their own KV type tag. By
+// default a type is tagged with its package path and name, which changes
+// if the type is moved or renamed; a declared tag keeps stored values
+// readable across such refactors.
+//
+// Example:
+//
+//	func (Config) KVType() string { return "config/v1" }
+type KVTyper interface {
+	KVType() string
+}
+
+// ErrKVTypeMismatch is returned by KVGetAs when a key holds a value stored
+// by KVSetAs with a different type.
+type ErrKVTypeMismatch struct {
+	Key    string
+	Stored string // Type tag stored with the value
+	Want   string // Type tag of the requested type
+}
+
+func (e *ErrKVTypeMismatch) Error() string {
+	return fmt.Sprintf("key %s: stored type %q, want %q", e.Key, e.Stored, e.Want)
+}
+
+// KVSetAs stores value like KVStore.Set, tagged with the type T, so that
+// KVGetAs reading it as another type fails instead of decoding whatever
+// fields happen to match. Set, Txn, and the atomic operations store
+// untagged values.
+//
+// Example:
+//
+//	err := agentfs.KVSetAs(ctx, afs.KV, "app:config", Config{Debug: true})
+func KVSetAs[T any](ctx context.Context, kv *KVStore, key string, value T) error {
+	return kv.set(ctx, key, value, 0, kvTypeTag[T]())
+}
+
+// KVGetAs retrieves the value at key as a T. It returns *ErrKVTypeMismatch
+// if the value was stored by KVSetAs with another type; untagged values
+// are decoded as with KVGet.
+//
+// Example:
+//
+//	cfg, err := agentfs.KVGetAs[Config](ctx, afs.KV, "app:config")
+//	var mismatch *agentfs.ErrKVTypeMismatch
+//	if errors.As(err, &mismatch) {
+//	    log.Printf("app:config holds a %s", mismatch.Stored)
+//	}
+func KVGetAs[T any](ctx context.Context, kv *KVStore, key string) (T, error) {
+	var result T
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer done()
+
+	var jsonValue, tag string
+	err = kv.db.QueryRowContext(ctx, kvGetTyped, kv.storeKey(key), kv.clock.Now().UnixMilli()).Scan(&jsonValue, &tag)
+	if err == sql.ErrNoRows {
+		return result, fmt.Errorf("key not found: %s", key)
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to get key: %w", err)
+	}
+
+	if want := kvTypeTag[T](); tag != "" && want != "" && tag != want {
+		return result, &ErrKVTypeMismatch{Key: key, Stored: tag, Want: want}
+	}
+	if err := json.Unmarshal([]byte(jsonValue), &result); err != nil {
+		return result, fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+	return result, nil
+}
+
+// kvTypeTag returns the type tag of T: its KVTyper tag if it declares one,
+// else its package path and name. T and *T share a tag. Interface types
+// have no tag, so values stored as them are untagged and read as anything.
+func kvTypeTag[T any]() string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return ""
+	}
+	if v, ok := reflect.New(t).Interface().(KVTyper); ok {
+		return v.KVType()
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
@@ -0,0 +1,82 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type typedConfig struct {
+	Debug bool `json:"debug"`
+}
+
+type typedSession struct {
+	Debug bool `json:"debug"`
+	User  string
+}
+
+type typedDeclared struct{ N int }
+
+func (typedDeclared) KVType() string { return "declared/v1" }
+
+func TestKVSetAsGetAs(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := KVSetAs(ctx, afs.KV, "cfg", typedConfig{Debug: true}); err != nil {
+		t.Fatalf("KVSetAs failed: %v", err)
+	}
+	cfg, err := KVGetAs[typedConfig](ctx, afs.KV, "cfg")
+	if err != nil || !cfg.Debug {
+		t.Errorf("KVGetAs = %+v, %v", cfg, err)
+	}
+	if _, err := KVGetAs[*typedConfig](ctx, afs.KV, "cfg"); err != nil {
+		t.Errorf("KVGetAs as pointer failed: %v", err)
+	}
+
+	// Same JSON shape, different type
+	var mismatch *ErrKVTypeMismatch
+	if _, err := KVGetAs[typedSession](ctx, afs.KV, "cfg"); !errors.As(err, &mismatch) {
+		t.Fatalf("KVGetAs as another type = %v, want ErrKVTypeMismatch", err)
+	}
+	if mismatch.Stored != kvTypeTag[typedConfig]() || mismatch.Want != kvTypeTag[typedSession]() {
+		t.Errorf("mismatch = %+v", mismatch)
+	}
+	if _, err := KVGet[typedSession](ctx, afs.KV, "cfg"); err != nil {
+		t.Errorf("untyped KVGet failed: %v", err)
+	}
+
+	entries, err := afs.KV.List(ctx, "cfg")
+	if err != nil || len(entries) != 1 || entries[0].Type != kvTypeTag[typedConfig]() {
+		t.Errorf("List = %+v, %v", entries, err)
+	}
+
+	// Set replaces the tag, so any type reads the value
+	afs.KV.Set(ctx, "cfg", typedSession{User: "ann"})
+	if s, err := KVGetAs[typedSession](ctx, afs.KV, "cfg"); err != nil || s.User != "ann" {
+		t.Errorf("KVGetAs after Set = %+v, %v", s, err)
+	}
+
+	if _, err := KVGetAs[int](ctx, afs.KV, "missing"); !isKeyNotFoundError(err) {
+		t.Errorf("KVGetAs of a missing key = %v", err)
+	}
+}
+
+func TestKVTypeTag(t *testing.T) {
+	if got := kvTypeTag[typedDeclared](); got != "declared/v1" {
+		t.Errorf("declared tag = %q", got)
+	}
+	if got := kvTypeTag[*typedDeclared](); got != "declared/v1" {
+		t.Errorf("pointer tag = %q", got)
+	}
+	if got := kvTypeTag[typedConfig](); got != "github.com/tursodatabase/agentfs/sdk/go.typedConfig" {
+		t.Errorf("named tag = %q", got)
+	}
+	if got := kvTypeTag[[]string](); got != "[]string" {
+		t.Errorf("unnamed tag = %q", got)
+	}
+	if got := kvTypeTag[any](); got != "" {
+		t.Errorf("interface tag = %q, want none", got)
+	}
+}
@@ -0,0 +1,184 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultKVWatchPollInterval is how often a KV watch checks for changes
+// made by other processes.
+const DefaultKVWatchPollInterval = 250 * time.Millisecond
+
+// DefaultKVChangeRetention is how long changes are kept for KV watches that
+// fall behind. Older changes are removed in the background.
+const DefaultKVChangeRetention = time.Hour
+
+// kvWatchBatch is how many changes a watch reads at a time.
+const kvWatchBatch = 64
+
+// KVChange is a change to a key reported by KVStore.Watch.
+type KVChange struct {
+	// Seq orders the changes of a database.
+	Seq int64 `json:"seq"`
+	// Kind is EventKVSet or EventKVDeleted.
+	Kind EventKind `json:"kind"`
+	Key  string    `json:"key"`
+	// Old is the value before the change; nil if the key was created.
+	Old json.RawMessage `json:"old,omitempty"`
+	// New is the value after the change; nil if the key was deleted.
+	New json.RawMessage `json:"new,omitempty"`
+	// ChangedAt is the Unix time of the change, as recorded by SQLite.
+	ChangedAt int64 `json:"changed_at"`
+}
+
+// Watch reports the changes to keys starting with prefix made after it is
+// called, in order, until ctx ends or the AgentFS closes; then the channel
+// is closed. This SDK records its changes in the database, so writes from
+// other processes using it are reported too, within
+// DefaultKVWatchPollInterval; writes made through other SDKs are not. A
+// watch that falls more than DefaultKVChangeRetention behind misses the
+// changes removed meanwhile.
+//
+// Example:
+//
+//	changes, err := afs.KV.Watch(ctx, "results:")
+//	if err != nil {
+//	    return err
+//	}
+//	for c := range changes {
+//	    if c.Kind == agentfs.EventKVSet {
+//	        log.Printf("worker wrote %s = %s", c.Key, c.New)
+//	    }
+//	}
+func (kv *KVStore) Watch(ctx context.Context, prefix string) (<-chan KVChange, error) {
+	bctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var seq int64
+	err = kv.db.QueryRowContext(bctx, kvChangeHead).Scan(&seq)
+	done()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KV changes: %w", err)
+	}
+
+	var wake <-chan Event
+	cancel := func() {}
+	if kv.events != nil {
+		wake, cancel = kv.events.subscribe(EventFilter{Kinds: []EventKind{EventKVSet, EventKVDeleted}}, 1)
+	}
+	out := make(chan KVChange, kvWatchBatch)
+	go func() {
+		defer close(out)
+		defer cancel()
+		ticker := time.NewTicker(DefaultKVWatchPollInterval)
+		defer ticker.Stop()
+		pattern := escapePattern(kv.storeKey(prefix)) + "%"
+		for {
+			changes, err := kv.changesSince(ctx, seq, pattern)
+			if err != nil {
+				return // ctx ended or the AgentFS closed
+			}
+			for _, c := range changes {
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+				seq = c.Seq
+			}
+			if len(changes) == kvWatchBatch {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return out, nil
+}
+
+// changesSince reads the next batch of changes after seq to keys matching
+// the LIKE pattern.
+func (kv *KVStore) changesSince(ctx context.Context, seq int64, pattern string) ([]KVChange, error) {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := kv.db.QueryContext(ctx, kvChangesSince, seq, kv.ns, pattern, kvWatchBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KV changes: %w", err)
+	}
+	defer rows.Close()
+	var changes []KVChange
+	for rows.Next() {
+		var c KVChange
+		var old, new sql.NullString
+		if err := rows.Scan(&c.Seq, &c.Key, &c.Kind, &old, &new, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to read KV changes: %w", err)
+		}
+		c.Key = kv.userKey(c.Key)
+		if old.Valid {
+			c.Old = json.RawMessage(old.String)
+		}
+		if new.Valid {
+			c.New = json.RawMessage(new.String)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// write runs fn, which changes kv_store and records the change in
+// kv_changelog, in one transaction, or in kv's own if it has one.
+func (kv *KVStore) write(ctx context.Context, fn func(db dbtx) error) error {
+	if kv.conn == nil {
+		return fn(kv.db)
+	}
+	tx, err := kv.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setLogged runs set, which stores a value for key in namespace ns on db,
+// and records the change in kv_changelog if set reports one.
+func setLogged(ctx context.Context, db dbtx, ns, key string, set func() (bool, error)) error {
+	var old sql.NullString // Expired or not
+	if err := db.QueryRowContext(ctx, kvStoredValue, key).Scan(&old); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	changed, err := set()
+	if err != nil || !changed {
+		return err
+	}
+	_, err = db.ExecContext(ctx, kvLogSet, ns, key, old)
+	return err
+}
+
+// trimChanges removes the KV changes older than DefaultKVChangeRetention.
+func (kv *KVStore) trimChanges(ctx context.Context) error {
+	ctx, done, err := kv.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if _, err := kv.db.ExecContext(ctx, kvChangeTrim, int64(DefaultKVChangeRetention/time.Second)); err != nil {
+		return fmt.Errorf("failed to trim KV changes: %w", err)
+	}
+	return nil
+}
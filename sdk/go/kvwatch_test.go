@@ -0,0 +1,121 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextChange(t *testing.T, changes <-chan KVChange) KVChange {
+	t.Helper()
+	select {
+	case c, ok := <-changes:
+		if !ok {
+			t.Fatal("watch closed")
+		}
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change")
+	}
+	return KVChange{}
+}
+
+func TestKVWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.KV.Set(ctx, "results:before", 0) // Not reported
+	changes, err := afs.KV.Watch(ctx, "results:")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	afs.KV.Set(ctx, "other", 1)
+	afs.KV.Set(ctx, "results:a", 1)
+	afs.KV.Set(ctx, "results:a", 2)
+	afs.KV.Delete(ctx, "results:a")
+
+	c := nextChange(t, changes)
+	if c.Kind != EventKVSet || c.Key != "results:a" || c.Old != nil || string(c.New) != "1" {
+		t.Errorf("first change = %+v", c)
+	}
+	c = nextChange(t, changes)
+	if c.Kind != EventKVSet || string(c.Old) != "1" || string(c.New) != "2" {
+		t.Errorf("second change = %+v", c)
+	}
+	c = nextChange(t, changes)
+	if c.Kind != EventKVDeleted || string(c.Old) != "2" || c.New != nil {
+		t.Errorf("third change = %+v", c)
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestKVWatchOtherProcess(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := filepath.Join(t.TempDir(), "test.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: p})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	changes, err := afs.KV.Namespace("jobs").Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// Another process writes through its own connection
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+	other, err := OpenWith(ctx, db)
+	if err != nil {
+		t.Fatalf("OpenWith failed: %v", err)
+	}
+	defer other.Close()
+	other.KV.Set(ctx, "done", true)
+	other.KV.Namespace("jobs").Set(ctx, "42", "ok")
+
+	c := nextChange(t, changes)
+	if c.Key != "42" || string(c.New) != `"ok"` {
+		t.Errorf("change = %+v", c)
+	}
+}
+
+func TestKVChangesRecordedBySDKOnly(t *testing.T) {
+	ctx := context.Background()
+	p := filepath.Join(t.TempDir(), "test.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: p})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+	afs.KV.Set(ctx, "a", 1)
+	if ok, _ := afs.KV.CompareAndSwap(ctx, "a", 2, 3); ok {
+		t.Fatal("CompareAndSwap succeeded on a mismatch")
+	}
+
+	// Another SDK writes kv_store directly
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "INSERT INTO kv_store (key, value) VALUES ('b', '2')"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM kv_changelog").Scan(&n); err != nil || n != 1 {
+		t.Errorf("kv_changelog has %d rows, %v; want 1", n, err)
+	}
+}
@@ -0,0 +1,207 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// languageByExt maps lower-case file extensions to language names.
+var languageByExt = map[string]string{
+	".c":     "C",
+	".h":     "C",
+	".cc":    "C++",
+	".cpp":   "C++",
+	".cxx":   "C++",
+	".hpp":   "C++",
+	".hh":    "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".go":    "Go",
+	".hs":    "Haskell",
+	".html":  "HTML",
+	".htm":   "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".mjs":   "JavaScript",
+	".cjs":   "JavaScript",
+	".jsx":   "JavaScript",
+	".json":  "JSON",
+	".kt":    "Kotlin",
+	".lua":   "Lua",
+	".md":    "Markdown",
+	".nix":   "Nix",
+	".php":   "PHP",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".scala": "Scala",
+	".sh":    "Shell",
+	".bash":  "Shell",
+	".zsh":   "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".toml":  "TOML",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+	".zig":   "Zig",
+}
+
+// languageByName maps well-known extensionless file names to languages.
+var languageByName = map[string]string{
+	"Makefile":   "Makefile",
+	"Dockerfile": "Dockerfile",
+}
+
+// otherLanguage is reported for files with an unrecognized extension.
+const otherLanguage = "Other"
+
+// DetectLanguage returns the language of a file based on its name, or
+// "Other" if it is not recognized.
+func DetectLanguage(p string) string {
+	name := path.Base(p)
+	if lang, ok := languageByName[name]; ok {
+		return lang
+	}
+	if lang, ok := languageByExt[strings.ToLower(path.Ext(name))]; ok {
+		return lang
+	}
+	return otherLanguage
+}
+
+// LanguageStats summarizes the regular files below root by language, in the
+// style of tokei or cloc. Results are sorted by line count, largest first.
+// opts may be used to filter files by name and ignore rules. Files are read
+// a buffer at a time, so large files are counted without loading them into
+// memory.
+//
+// Example:
+//
+//	stats, err := afs.FS.LanguageStats(ctx, "/workspace", &agentfs.FindOptions{
+//	    IgnoreFile: ".gitignore",
+//	})
+//	for _, s := range stats {
+//	    fmt.Printf("%-12s %5d files %7d lines\n", s.Language, s.Files, s.Lines)
+//	}
+func (fs *Filesystem) LanguageStats(ctx context.Context, root string, opts *FindOptions) ([]LanguageStat, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+	root, err = fs.cleanPath(ctx, "langstats", root)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := fs.fsIgnore(ctx, root, opts.Ignore, opts.IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	byLang := make(map[string]*LanguageStat)
+	buf := make([]byte, 32*1024)
+	err = fs.walk(ctx, root, rules, func(p, rel string, stats *Stats) error {
+		if !stats.IsRegularFile() {
+			return nil
+		}
+		if opts.Name != "" {
+			if ok, _ := path.Match(opts.Name, path.Base(p)); !ok {
+				return nil
+			}
+		}
+
+		f, err := fs.Open(ctx, p, O_RDONLY)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var lc lineCounter
+		n, err := io.CopyBuffer(&lc, f.WithContext(ctx), buf)
+		if err != nil {
+			return err
+		}
+		lc.finish()
+
+		lang := DetectLanguage(p)
+		s, ok := byLang[lang]
+		if !ok {
+			s = &LanguageStat{Language: lang}
+			byLang[lang] = s
+		}
+		s.Files++
+		s.Bytes += n
+		s.Lines += lc.lines
+		s.Blank += lc.blank
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]LanguageStat, 0, len(byLang))
+	for _, s := range byLang {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Lines != result[j].Lines {
+			return result[i].Lines > result[j].Lines
+		}
+		return result[i].Language < result[j].Language
+	})
+	return result, nil
+}
+
+// lineCounter is an io.Writer that counts the lines written to it and how
+// many of them are blank, carrying a partial line over to the next write.
+type lineCounter struct {
+	lines, blank int64
+	partial      bool // bytes of an unterminated line have been written
+	content      bool // the current line has non-space characters
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		line := p
+		i := bytes.IndexByte(p, '\n')
+		if i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.content = true
+		}
+		if i < 0 {
+			c.partial = true
+			break
+		}
+		c.endLine()
+	}
+	return n, nil
+}
+
+// finish counts a final line without a trailing newline.
+func (c *lineCounter) finish() {
+	if c.partial {
+		c.endLine()
+	}
+}
+
+func (c *lineCounter) endLine() {
+	c.lines++
+	if !c.content {
+		c.blank++
+	}
+	c.partial, c.content = false, false
+}
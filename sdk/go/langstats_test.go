@@ -0,0 +1,93 @@
+package agentfs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLanguageStats(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	files := map[string]string{
+		"/repo/main.go":       "package main\n\nfunc main() {}\n",
+		"/repo/util/util.go":  "package util",
+		"/repo/README.md":     "# Repo\n",
+		"/repo/Makefile":      "all:\n\tgo build\n",
+		"/repo/data.bin":      "xx",
+		"/repo/vendor/dep.go": "package dep\n",
+		"/repo/.gitignore":    "vendor/\n",
+	}
+	for p, content := range files {
+		if err := afs.FS.WriteFile(ctx, p, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	stats, err := afs.FS.LanguageStats(ctx, "/repo", &FindOptions{IgnoreFile: ".gitignore"})
+	if err != nil {
+		t.Fatalf("LanguageStats failed: %v", err)
+	}
+
+	want := []LanguageStat{
+		{Language: "Go", Files: 2, Lines: 4, Blank: 1, Bytes: 41},
+		{Language: "Makefile", Files: 1, Lines: 2, Blank: 0, Bytes: 15},
+		{Language: "Other", Files: 2, Lines: 2, Blank: 0, Bytes: 10},
+		{Language: "Markdown", Files: 1, Lines: 1, Blank: 0, Bytes: 7},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("LanguageStats =\n%+v\nwant\n%+v", stats, want)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := map[string]string{
+		"/a/b.go":       "Go",
+		"/a/B.PY":       "Python",
+		"/Dockerfile":   "Dockerfile",
+		"/a/noext":      "Other",
+		"/a/styles.css": "CSS",
+	}
+	for p, want := range tests {
+		if got := DetectLanguage(p); got != want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestLineCounter(t *testing.T) {
+	data := "one\n\n  \ntwo  \nthree"
+	// Every split must give the same counts as a single write
+	for split := 0; split <= len(data); split++ {
+		var lc lineCounter
+		lc.Write([]byte(data[:split]))
+		lc.Write([]byte(data[split:]))
+		lc.finish()
+		if lc.lines != 5 || lc.blank != 2 {
+			t.Errorf("split at %d: lines = %d, blank = %d, want 5 and 2", split, lc.lines, lc.blank)
+		}
+	}
+}
+
+func TestLanguageStatsLargeFile(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	// Larger than the read buffer, with lines straddling buffer boundaries
+	data := strings.Repeat("0123456789abcdefghi\n\n", 10000)
+	if err := afs.FS.WriteFile(ctx, "/big.txt", []byte(data), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	stats, err := afs.FS.LanguageStats(ctx, "/", nil)
+	if err != nil {
+		t.Fatalf("LanguageStats failed: %v", err)
+	}
+	want := []LanguageStat{{Language: "Other", Files: 1, Lines: 20000, Blank: 10000, Bytes: int64(len(data))}}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("LanguageStats = %+v, want %+v", stats, want)
+	}
+}
@@ -0,0 +1,143 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by operations started after Close has begun.
+var ErrClosed = errors.New("agentfs: closed")
+
+// ErrCloseTimeout is returned by CloseWithTimeout when in-flight operations
+// did not finish before the timeout. The database is closed regardless.
+type ErrCloseTimeout struct {
+	InFlight int
+	Timeout  time.Duration
+}
+
+func (e *ErrCloseTimeout) Error() string {
+	return fmt.Sprintf("close timed out after %s with %d operation(s) in flight", e.Timeout, e.InFlight)
+}
+
+// opKey marks a context as belonging to an in-flight operation so that
+// nested calls (e.g. WriteFile -> MkdirAll -> Mkdir) are only counted once.
+type opKey struct{}
+
+// lifecycle tracks in-flight operations so Close can drain them.
+type lifecycle struct {
+	mu     sync.Mutex
+	closed bool
+	active int
+	wg     sync.WaitGroup
+
+	// foreground counts in-flight foreground operations; fgIdle is closed
+	// while there are none.
+	foreground int
+	fgIdle     chan struct{}
+}
+
+// begin registers an in-flight operation. The returned context must be passed
+// to nested calls and done must be called when the operation completes.
+func (l *lifecycle) begin(ctx context.Context) (context.Context, func(), error) {
+	if l == nil || ctx.Value(opKey{}) == l {
+		return ctx, func() {}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ctx, nil, ErrClosed
+	}
+	l.active++
+	l.wg.Add(1)
+
+	fg := priorityFrom(ctx) == PriorityForeground
+	if fg {
+		if l.foreground == 0 {
+			l.fgIdle = make(chan struct{})
+		}
+		l.foreground++
+	}
+
+	return context.WithValue(ctx, opKey{}, l), func() { l.end(fg) }, nil
+}
+
+// end marks an operation registered by begin as complete.
+func (l *lifecycle) end(fg bool) {
+	l.mu.Lock()
+	l.active--
+	if fg {
+		l.foreground--
+		if l.foreground == 0 {
+			close(l.fgIdle)
+		}
+	}
+	l.mu.Unlock()
+	l.wg.Done()
+}
+
+// waitForegroundIdle waits until no foreground operation is in flight, for
+// at most max.
+func (l *lifecycle) waitForegroundIdle(ctx context.Context, max time.Duration) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	idle := l.fgIdle
+	busy := l.foreground > 0
+	l.mu.Unlock()
+	if !busy {
+		return nil
+	}
+
+	timer := time.NewTimer(max)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// shutdown rejects new operations and waits for in-flight ones.
+// A timeout <= 0 waits indefinitely.
+func (l *lifecycle) shutdown(timeout time.Duration) error {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(drained)
+	}()
+
+	if timeout <= 0 {
+		<-drained
+		return nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return nil
+	case <-timer.C:
+		l.mu.Lock()
+		active := l.active
+		l.mu.Unlock()
+		return &ErrCloseTimeout{InFlight: active, Timeout: timeout}
+	}
+}
+
+// InFlight returns the number of operations currently executing.
+func (a *AgentFS) InFlight() int {
+	a.life.mu.Lock()
+	defer a.life.mu.Unlock()
+	return a.life.active
+}
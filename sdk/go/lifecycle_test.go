@@ -0,0 +1,140 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCloseRejectsNewOperations(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := afs.FS.WriteFile(ctx, "/a.txt", []byte("a"), 0o644); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteFile after Close: err = %v, want ErrClosed", err)
+	}
+	if err := afs.KV.Set(ctx, "k", "v"); !errors.Is(err, ErrClosed) {
+		t.Errorf("KV.Set after Close: err = %v, want ErrClosed", err)
+	}
+	if _, err := afs.Tools.Get(ctx, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Tools.Get after Close: err = %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseIsIdempotent(t *testing.T) {
+	afs := setupTestDB(t)
+	if err := afs.Close(); err != nil {
+		t.Fatalf("first Close failed: %v", err)
+	}
+	if err := afs.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+}
+
+func TestCloseWaitsForInFlight(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+
+	_, done, err := afs.life.begin(ctx)
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	if n := afs.InFlight(); n != 1 {
+		t.Fatalf("InFlight() = %d, want 1", n)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- afs.Close() }()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned while an operation was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after the operation finished")
+	}
+}
+
+func TestCloseWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+
+	_, done, err := afs.life.begin(ctx)
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	defer done()
+
+	err = afs.CloseWithTimeout(20 * time.Millisecond)
+	var timeoutErr *ErrCloseTimeout
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("CloseWithTimeout: err = %v, want *ErrCloseTimeout", err)
+	}
+	if timeoutErr.InFlight != 1 {
+		t.Errorf("InFlight = %d, want 1", timeoutErr.InFlight)
+	}
+}
+
+func TestNestedOperationsCountOnce(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	opCtx, done, err := afs.life.begin(ctx)
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+
+	// Nested calls with the operation context must not block or be rejected
+	// once shutdown has started.
+	afs.life.mu.Lock()
+	afs.life.closed = true
+	afs.life.mu.Unlock()
+
+	if err := afs.FS.WriteFile(opCtx, "/nested/a.txt", []byte("a"), 0o644); err != nil {
+		t.Errorf("nested WriteFile failed: %v", err)
+	}
+	if n := afs.InFlight(); n != 1 {
+		t.Errorf("InFlight() = %d, want 1", n)
+	}
+
+	done()
+}
+
+func TestCheckpointOnClose(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:       dbPath,
+		Checkpoint: CheckpointOptions{OnClose: true, Mode: CheckpointTruncate},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/a.txt", make([]byte, 16*1024), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if m := afs.CheckpointMetrics(); m.Checkpoints != 1 {
+		t.Errorf("Checkpoints = %d, want 1", m.Checkpoints)
+	}
+}
@@ -0,0 +1,290 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogBuffer is the number of buffered bytes after which LogWriter
+// flushes on its own.
+const DefaultLogBuffer = 64 << 10
+
+// LogWriter appends to a log file opened with OpenLog. Writes are buffered
+// until Flush, Close, or DefaultLogBuffer bytes, and each flush appends the
+// buffered bytes in one transaction, so concurrent writers never interleave
+// within a flush. A LogWriter is safe for concurrent use.
+type LogWriter struct {
+	fs   *Filesystem
+	ctx  context.Context
+	path string
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+// Compile-time interface checks
+var _ io.WriteCloser = (*LogWriter)(nil)
+
+// OpenLog opens the file at p for appending, creating it and its parent
+// directories if needed. Existing content is kept. ctx applies to every
+// flush. Pair it with TailFollow to watch a run as it happens.
+//
+// Example:
+//
+//	log, err := afs.FS.OpenLog(ctx, "/runs/42/agent.log")
+//	if err != nil {
+//	    return err
+//	}
+//	defer log.Close()
+//	fmt.Fprintf(log, "step %d: %s\n", i, action)
+//	log.Flush(ctx) // Durable once Flush returns
+func (fs *Filesystem) OpenLog(ctx context.Context, p string) (*LogWriter, error) {
+	f, err := fs.Open(ctx, p, O_WRONLY|O_CREATE)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &LogWriter{fs: fs, ctx: ctx, path: f.Path()}, nil
+}
+
+// Write buffers p for appending. It implements io.Writer.
+func (l *LogWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, ErrWriterClosed
+	}
+	l.buf.Write(p)
+	if l.buf.Len() >= DefaultLogBuffer {
+		if err := l.flush(l.ctx); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// WriteLine buffers s followed by a newline.
+func (l *LogWriter) WriteLine(s string) error {
+	_, err := l.Write([]byte(strings.TrimSuffix(s, "\n") + "\n"))
+	return err
+}
+
+// Flush appends the buffered bytes to the file and syncs the write-ahead
+// log to disk, so the lines survive a crash once Flush returns.
+func (l *LogWriter) Flush(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush(ctx)
+}
+
+func (l *LogWriter) flush(ctx context.Context) error {
+	if l.buf.Len() == 0 {
+		return nil
+	}
+	if err := l.fs.appendFile(ctx, l.path, l.buf.Bytes()); err != nil {
+		return err
+	}
+	l.buf.Reset()
+	return l.fs.syncWAL(ctx)
+}
+
+// Close flushes the buffer. Closing more than once is a no-op.
+func (l *LogWriter) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return l.flush(l.ctx)
+}
+
+// appendFile appends data to the file at p in one transaction.
+func (fs *Filesystem) appendFile(ctx context.Context, p string, data []byte) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return fs.inTx(ctx, func(tfs *Filesystem) error {
+		ino, err := tfs.resolvePathFollow(ctx, p, true)
+		if err != nil {
+			return err
+		}
+		stats, err := tfs.statInode(ctx, ino)
+		if err != nil {
+			return err
+		}
+		if stats.IsDir() {
+			return ErrIsDir("write", p)
+		}
+		f := &File{fs: tfs, ino: ino, path: p, flags: O_WRONLY | O_APPEND}
+		_, err = f.Pwrite(ctx, data, stats.Size)
+		return err
+	})
+}
+
+// syncWAL checkpoints the write-ahead log, which syncs it to disk first.
+// A checkpoint blocked by readers still leaves the log synced.
+func (fs *Filesystem) syncWAL(ctx context.Context) error {
+	if fs.conn == nil {
+		return nil // The enclosing transaction has not committed yet
+	}
+	var busy, frames, checkpointed int64
+	if err := fs.conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &frames, &checkpointed); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	return nil
+}
+
+// LogTail streams the lines appended to a file, from TailFollow.
+type LogTail struct {
+	// Lines delivers each complete line without its newline. It is closed
+	// when the context ends or following fails (see Err).
+	Lines <-chan string
+
+	err error
+}
+
+// Err returns the error that stopped the tail, if any, once Lines is
+// closed. It is nil when the tail stopped because its context ended.
+func (t *LogTail) Err() error {
+	return t.err
+}
+
+// TailFollow streams lines appended to the file at p until ctx ends, like
+// tail -F. Appends made through this Filesystem are picked up at once;
+// appends by other processes sharing the database are found by polling
+// every opts.PollInterval. A line is delivered once its newline is written.
+// If the file is replaced, following restarts at the start of the new file;
+// if it is truncated, at the new end.
+//
+// Example:
+//
+//	tail, err := afs.FS.TailFollow(ctx, "/runs/42/agent.log", nil)
+//	if err != nil {
+//	    return err
+//	}
+//	for line := range tail.Lines {
+//	    fmt.Println(line)
+//	}
+func (fs *Filesystem) TailFollow(ctx context.Context, p string, opts *TailOptions) (*LogTail, error) {
+	if opts == nil {
+		opts = &TailOptions{}
+	}
+	poll := opts.PollInterval
+	if poll <= 0 {
+		poll = 250 * time.Millisecond
+	}
+
+	p, err := fs.cleanPath(ctx, "read", p)
+	if err != nil {
+		return nil, err
+	}
+	// Subscribe before the Stat, so no append falls between them; the
+	// first read then catches up, replaced file included
+	var wake <-chan Event
+	cancel := func() {}
+	if fs.events != nil {
+		wake, cancel = fs.events.subscribe(EventFilter{Kinds: []EventKind{EventFileWritten}, PathPrefix: p}, 1)
+	}
+	stats, err := fs.Stat(ctx, p)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if stats.IsDir() {
+		cancel()
+		return nil, ErrIsDir("read", p)
+	}
+
+	lines := make(chan string, 64)
+	t := &LogTail{Lines: lines}
+
+	f := &tailFollower{fs: fs, path: p, ino: stats.Ino, offset: stats.Size}
+	if opts.FromStart {
+		f.offset = 0
+	}
+	go func() {
+		defer close(lines)
+		defer cancel()
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		for {
+			if err := f.read(ctx, lines); err != nil {
+				if ctx.Err() == nil {
+					t.err = err
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return t, nil
+}
+
+// tailFollower tracks the read position of a TailFollow.
+type tailFollower struct {
+	fs      *Filesystem
+	path    string
+	ino     int64
+	offset  int64
+	partial []byte // Bytes after the last newline
+}
+
+// read sends the complete lines appended since the last call.
+func (f *tailFollower) read(ctx context.Context, lines chan<- string) error {
+	stats, err := f.fs.Stat(ctx, f.path)
+	if IsNotExist(err) {
+		return nil // Removed; wait for it to come back
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case stats.Ino != f.ino:
+		f.ino, f.offset, f.partial = stats.Ino, 0, nil
+	case stats.Size < f.offset:
+		f.offset, f.partial = stats.Size, nil
+	}
+
+	file := &File{fs: f.fs, ino: f.ino, path: f.path, flags: O_RDONLY}
+	buf := make([]byte, f.fs.chunkSize)
+	for f.offset < stats.Size {
+		n, err := file.Pread(ctx, buf[:min(int64(len(buf)), stats.Size-f.offset)], f.offset)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		f.offset += int64(n)
+		data := append(f.partial, buf[:n]...)
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			select {
+			case lines <- strings.TrimSuffix(string(data[:i]), "\r"):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			data = data[i+1:]
+		}
+		f.partial = append([]byte(nil), data...)
+	}
+	return nil
+}
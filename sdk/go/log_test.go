@@ -0,0 +1,108 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextLine waits for the next line from a tail.
+func nextLine(t *testing.T, tail *LogTail) string {
+	t.Helper()
+	select {
+	case line, ok := <-tail.Lines:
+		if !ok {
+			t.Fatalf("tail closed: %v", tail.Err())
+		}
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a line")
+	}
+	return ""
+}
+
+func TestOpenLog_TailFollow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	log, err := afs.FS.OpenLog(ctx, "/runs/1/agent.log")
+	if err != nil {
+		t.Fatalf("OpenLog failed: %v", err)
+	}
+	log.WriteLine("before")
+	if err := log.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	tail, err := afs.FS.TailFollow(ctx, "/runs/1/agent.log", nil)
+	if err != nil {
+		t.Fatalf("TailFollow failed: %v", err)
+	}
+
+	// A partial line is held back until its newline arrives
+	log.Write([]byte("step 1"))
+	log.Flush(ctx)
+	log.Write([]byte(" done\nstep 2\n"))
+	log.Flush(ctx)
+	if got := nextLine(t, tail); got != "step 1 done" {
+		t.Errorf("line = %q, want %q", got, "step 1 done")
+	}
+	if got := nextLine(t, tail); got != "step 2" {
+		t.Errorf("line = %q, want %q", got, "step 2")
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, _ := afs.FS.ReadFile(ctx, "/runs/1/agent.log")
+	if string(data) != "before\nstep 1 done\nstep 2\n" {
+		t.Errorf("content = %q", data)
+	}
+
+	cancel()
+	for range tail.Lines {
+	}
+	if err := tail.Err(); err != nil {
+		t.Errorf("Err() = %v after cancel, want nil", err)
+	}
+}
+
+func TestTailFollow_AcrossInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dbPath := filepath.Join(t.TempDir(), "shared.db")
+
+	reader, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reader.Close()
+	reader.FS.WriteFile(ctx, "/run.log", []byte("old\n"), 0o644)
+
+	tail, err := reader.FS.TailFollow(ctx, "/run.log", &TailOptions{FromStart: true, PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("TailFollow failed: %v", err)
+	}
+	if got := nextLine(t, tail); got != "old" {
+		t.Errorf("line = %q, want %q", got, "old")
+	}
+
+	writer, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer writer.Close()
+	log, err := writer.FS.OpenLog(ctx, "/run.log")
+	if err != nil {
+		t.Fatalf("OpenLog failed: %v", err)
+	}
+	log.WriteLine("from writer")
+	log.Close()
+
+	if got := nextLine(t, tail); got != "from writer" {
+		t.Errorf("line = %q, want %q", got, "from writer")
+	}
+}
@@ -0,0 +1,80 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/tursodatabase/agentfs/sdk/go/internal/cache"
+)
+
+// lookupFilterFPRate is the share of missing entries the lookup filter
+// passes on to SQLite.
+const lookupFilterFPRate = 0.01
+
+// dentryKey identifies the entry name of the directory parentIno in the
+// lookup filter. Names cannot contain "/", so keys are unambiguous.
+func dentryKey(parentIno int64, name string) string {
+	return strconv.FormatInt(parentIno, 36) + "/" + name
+}
+
+// mayHaveDentry reports whether the directory parentIno may have an entry
+// name. Without a lookup filter, every entry may exist.
+func (fs *Filesystem) mayHaveDentry(parentIno int64, name string) bool {
+	return fs.lookups == nil || fs.lookups.MayContain(dentryKey(parentIno, name))
+}
+
+// noteDentry adds an entry about to be created to the lookup filter. It is
+// added before the write, so a concurrent lookup never misses it; if the
+// write fails, the filter just passes on one more missing entry.
+func (fs *Filesystem) noteDentry(parentIno int64, name string) {
+	if fs.lookups != nil {
+		fs.lookups.Add(dentryKey(parentIno, name))
+	}
+}
+
+// loadLookupFilter builds the lookup filter from the entries in the
+// database (see AgentFSOptions.LookupFilter).
+func (fs *Filesystem) loadLookupFilter(ctx context.Context) error {
+	var count int
+	if err := fs.db.QueryRowContext(ctx, countDentries).Scan(&count); err != nil {
+		return fmt.Errorf("failed to build lookup filter: %w", err)
+	}
+	// Room to double before the filter grows
+	fs.lookups = cache.NewBloom(2*count, lookupFilterFPRate)
+	return fs.noteAllDentries(ctx)
+}
+
+// noteAllDentries adds every entry in the database to the lookup filter,
+// for writes that bypass noteDentry such as restoring a snapshot.
+func (fs *Filesystem) noteAllDentries(ctx context.Context) error {
+	if fs.lookups == nil {
+		return nil
+	}
+	rows, err := fs.db.QueryContext(ctx, queryAllDentryNames)
+	if err != nil {
+		return fmt.Errorf("failed to build lookup filter: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var parentIno int64
+		var name string
+		if err := rows.Scan(&parentIno, &name); err != nil {
+			return fmt.Errorf("failed to build lookup filter: %w", err)
+		}
+		fs.noteDentry(parentIno, name)
+	}
+	return rows.Err()
+}
+
+// LookupFilterStats returns statistics of the lookup filter: Hits counts
+// the lookups it answered without a query, Misses those passed on to
+// SQLite, and Entries the entries it holds. It returns nil unless
+// AgentFSOptions.LookupFilter is set.
+func (fs *Filesystem) LookupFilterStats() *cache.Stats {
+	if fs.lookups == nil {
+		return nil
+	}
+	stats := fs.lookups.Stats()
+	return &stats
+}
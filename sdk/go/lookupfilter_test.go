@@ -0,0 +1,76 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupFilter(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "filter.db")
+
+	// Entries written before the filter exists are loaded on open
+	afs, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/old/a.txt", []byte("a"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.Snapshot(ctx, "before"); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if err := afs.FS.Unlink(ctx, "/old/a.txt"); err != nil {
+		t.Fatalf("Unlink failed: %v", err)
+	}
+	afs.Close()
+
+	afs, err = Open(ctx, AgentFSOptions{Path: dbPath, LookupFilter: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	if _, err := afs.FS.Stat(ctx, "/old"); err != nil {
+		t.Errorf("Stat(/old) failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := afs.FS.Stat(ctx, "/missing"); !IsNotExist(err) {
+			t.Fatalf("Stat(/missing) = %v, want ENOENT", err)
+		}
+	}
+	if stats := afs.FS.LookupFilterStats(); stats == nil || stats.Hits < 9 {
+		t.Errorf("LookupFilterStats = %+v, want most missing lookups answered", stats)
+	}
+
+	// New, renamed, and linked entries are found
+	if err := afs.FS.WriteFile(ctx, "/new/b.txt", []byte("b"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.Rename(ctx, "/new/b.txt", "/new/c.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := afs.FS.Link(ctx, "/new/c.txt", "/old/d.txt"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	for _, p := range []string{"/new/c.txt", "/old/d.txt"} {
+		if _, err := afs.FS.Stat(ctx, p); err != nil {
+			t.Errorf("Stat(%s) failed: %v", p, err)
+		}
+	}
+
+	// Entries deleted before open come back with a restore
+	if err := afs.Restore(ctx, "before"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, err := afs.FS.ReadFile(ctx, "/old/a.txt"); err != nil || string(data) != "a" {
+		t.Errorf("ReadFile after restore = %q, %v", data, err)
+	}
+
+	plain := setupTestDB(t)
+	defer plain.Close()
+	if stats := plain.FS.LookupFilterStats(); stats != nil {
+		t.Errorf("LookupFilterStats without a filter = %+v, want nil", stats)
+	}
+}
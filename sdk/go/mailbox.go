@@ -0,0 +1,269 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLeaseExpired is returned when a Delivery is acknowledged or requeued
+// after its visibility timeout passed and the message was handed out again.
+var ErrLeaseExpired = errors.New("agentfs: mailbox lease expired")
+
+// Mailbox exchanges messages between agents without a broker. Messages are
+// durable: a received message stays in the inbox until it is acknowledged,
+// and is delivered again if it is neither acknowledged nor requeued within
+// the visibility timeout.
+type Mailbox struct {
+	db      *sql.DB // Holds this agent's inbox
+	shared  bool    // All inboxes live in db
+	dir     string
+	agentID string
+	prefix  string // See AgentFSOptions.TablePrefix
+	life    *lifecycle
+	clock   Clock
+
+	visibility time.Duration
+	poll       time.Duration
+}
+
+// Delivery is a message received from a Mailbox. It must be acknowledged
+// with Ack once handled, or handed back with Requeue.
+type Delivery struct {
+	ID     int64           `json:"id"`
+	From   string          `json:"from"`
+	Body   json.RawMessage `json:"body"`
+	SentAt int64           `json:"sent_at"`
+	// Attempts counts the deliveries of the message, including this one.
+	Attempts int64 `json:"attempts"`
+
+	mb *Mailbox
+}
+
+// newMailbox creates the mailbox of the agent whose database is db.
+func newMailbox(db *sql.DB, dbPath string, opts AgentFSOptions, life *lifecycle, clock Clock) *Mailbox {
+	mo := opts.Mailbox
+	m := &Mailbox{
+		db:         db,
+		dir:        mo.Dir,
+		agentID:    mo.AgentID,
+		prefix:     opts.TablePrefix,
+		life:       life,
+		clock:      clock,
+		visibility: mo.VisibilityTimeout,
+		poll:       mo.PollInterval,
+	}
+	if m.agentID == "" {
+		m.agentID = opts.ID
+	}
+	if m.agentID == "" && dbPath != "" {
+		m.agentID = strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	}
+	if m.dir == "" && dbPath != "" {
+		m.dir = filepath.Dir(dbPath)
+	}
+	switch {
+	case mo.DB != nil:
+		m.db, m.shared = mo.DB, true
+	case m.dir == "":
+		m.shared = true // OpenWith: the caller's database is the meeting point
+	}
+	if m.visibility <= 0 {
+		m.visibility = 30 * time.Second
+	}
+	if m.poll <= 0 {
+		m.poll = 500 * time.Millisecond
+	}
+	return m
+}
+
+// AgentID returns the address of this mailbox.
+func (m *Mailbox) AgentID() string {
+	return m.agentID
+}
+
+// Send delivers msg, encoded as JSON, to the inbox of the agent toAgentID
+// and returns the message ID. Unless the mailbox uses a shared database,
+// the recipient's database must already exist in the mailbox directory.
+//
+// Example:
+//
+//	_, err := afs.Mailbox.Send(ctx, "reviewer", map[string]string{"review": "/reports/q3.md"})
+func (m *Mailbox) Send(ctx context.Context, toAgentID string, msg any) (int64, error) {
+	ctx, done, err := m.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	if !validIDPattern.MatchString(toAgentID) {
+		return 0, fmt.Errorf("invalid agent ID: must match pattern %s", validIDPattern.String())
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize message: %w", err)
+	}
+
+	db := m.db
+	if !m.shared && toAgentID != m.agentID {
+		if db, err = m.openInbox(ctx, toAgentID); err != nil {
+			return 0, err
+		}
+		defer db.Close()
+	}
+
+	var id int64
+	if err := db.QueryRowContext(ctx, mailboxSend, toAgentID, m.agentID, string(body), m.clock.Now().Unix()).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to send message: %w", err)
+	}
+	return id, nil
+}
+
+// openInbox opens the database of the agent id in the mailbox directory.
+func (m *Mailbox) openInbox(ctx context.Context, id string) (*sql.DB, error) {
+	p := filepath.Join(m.dir, id+".db")
+	if _, err := os.Stat(p); err != nil {
+		return nil, fmt.Errorf("unknown agent %q: %w", id, err)
+	}
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if m.prefix != "" {
+		pdb, err := withTablePrefix(db, m.prefix, true)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db = pdb
+	}
+	// The recipient may not have opened its database since mailboxes were added
+	for _, stmt := range []string{createMailboxTable, createMailboxIndex} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize mailbox: %w", err)
+		}
+	}
+	return db, nil
+}
+
+// Receive waits for the next message in the inbox and leases it for the
+// visibility timeout. It returns when a message arrives, ctx is done, or
+// the AgentFS is closed.
+//
+// Example:
+//
+//	d, err := afs.Mailbox.Receive(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	if err := handle(d.Body); err != nil {
+//	    return d.Requeue(ctx)
+//	}
+//	return d.Ack(ctx)
+func (m *Mailbox) Receive(ctx context.Context) (*Delivery, error) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		d, err := m.TryReceive(ctx)
+		if err != nil || d != nil {
+			return d, err
+		}
+		timer.Reset(m.poll)
+	}
+}
+
+// TryReceive is like Receive but returns nil at once if no message is
+// waiting.
+func (m *Mailbox) TryReceive(ctx context.Context) (*Delivery, error) {
+	ctx, done, err := m.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	now := m.clock.Now()
+	d := &Delivery{mb: m}
+	var body string
+	err = m.db.QueryRowContext(ctx, mailboxReceive, m.agentID, now.UnixMilli(), now.Add(m.visibility).UnixMilli()).
+		Scan(&d.ID, &d.From, &body, &d.SentAt, &d.Attempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive message: %w", err)
+	}
+	d.Body = json.RawMessage(body)
+	return d, nil
+}
+
+// Len returns the number of messages in the inbox, including leased ones.
+func (m *Mailbox) Len(ctx context.Context) (int64, error) {
+	ctx, done, err := m.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	var n int64
+	if err := m.db.QueryRowContext(ctx, mailboxLen, m.agentID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return n, nil
+}
+
+// Decode unmarshals the message body into v.
+func (d *Delivery) Decode(v any) error {
+	return json.Unmarshal(d.Body, v)
+}
+
+// Ack removes the message from the inbox. It fails with ErrLeaseExpired if
+// the message has since been delivered again.
+func (d *Delivery) Ack(ctx context.Context) error {
+	ctx, done, err := d.mb.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	res, err := d.mb.db.ExecContext(ctx, mailboxAck, d.ID, d.Attempts)
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge message: %w", err)
+	}
+	return leaseResult(res)
+}
+
+// Requeue hands the message back so the next Receive delivers it again. It
+// fails with ErrLeaseExpired if the message has since been delivered again.
+func (d *Delivery) Requeue(ctx context.Context) error {
+	ctx, done, err := d.mb.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	res, err := d.mb.db.ExecContext(ctx, mailboxRequeue, d.mb.clock.Now().UnixMilli(), d.ID, d.Attempts)
+	if err != nil {
+		return fmt.Errorf("failed to requeue message: %w", err)
+	}
+	return leaseResult(res)
+}
+
+// leaseResult maps an Ack or Requeue that matched no row to ErrLeaseExpired.
+func leaseResult(res sql.Result) error {
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMailbox(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("shared directory", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := NewManualClock(time.Unix(1700000000, 0))
+		opts := MailboxOptions{VisibilityTimeout: time.Minute}
+		alice, err := Open(ctx, AgentFSOptions{Path: filepath.Join(dir, "alice.db"), Clock: clock, Mailbox: opts})
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer alice.Close()
+		bob, err := Open(ctx, AgentFSOptions{Path: filepath.Join(dir, "bob.db"), Clock: clock, Mailbox: opts})
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer bob.Close()
+
+		if _, err := alice.Mailbox.Send(ctx, "bob", map[string]string{"task": "review"}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if _, err := alice.Mailbox.Send(ctx, "carol", "hi"); err == nil {
+			t.Error("Send to unknown agent succeeded")
+		}
+
+		d, err := bob.Mailbox.TryReceive(ctx)
+		if err != nil || d == nil {
+			t.Fatalf("TryReceive = %v, %v", d, err)
+		}
+		var body map[string]string
+		if err := d.Decode(&body); err != nil || body["task"] != "review" || d.From != "alice" || d.Attempts != 1 {
+			t.Errorf("delivery = %+v, body %v", d, body)
+		}
+		if again, _ := bob.Mailbox.TryReceive(ctx); again != nil {
+			t.Error("leased message delivered twice")
+		}
+
+		// An unacknowledged message comes back after the visibility timeout
+		clock.Advance(2 * time.Minute)
+		redelivered, err := bob.Mailbox.TryReceive(ctx)
+		if err != nil || redelivered == nil || redelivered.Attempts != 2 {
+			t.Fatalf("TryReceive after timeout = %+v, %v", redelivered, err)
+		}
+		if err := d.Ack(ctx); !errors.Is(err, ErrLeaseExpired) {
+			t.Errorf("Ack of expired lease = %v, want ErrLeaseExpired", err)
+		}
+
+		if err := redelivered.Requeue(ctx); err != nil {
+			t.Fatalf("Requeue failed: %v", err)
+		}
+		d, err = bob.Mailbox.Receive(ctx)
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if err := d.Ack(ctx); err != nil {
+			t.Fatalf("Ack failed: %v", err)
+		}
+		if n, _ := bob.Mailbox.Len(ctx); n != 0 {
+			t.Errorf("Len = %d after Ack, want 0", n)
+		}
+	})
+
+	t.Run("shared database", func(t *testing.T) {
+		db, err := sql.Open("sqlite", ":memory:")
+		if err != nil {
+			t.Fatalf("sql.Open failed: %v", err)
+		}
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+
+		a, err := OpenWith(ctx, db, WithMailbox(MailboxOptions{AgentID: "planner"}))
+		if err != nil {
+			t.Fatalf("OpenWith failed: %v", err)
+		}
+		defer a.Close()
+		b, err := OpenWith(ctx, db, WithMailbox(MailboxOptions{AgentID: "worker"}))
+		if err != nil {
+			t.Fatalf("OpenWith failed: %v", err)
+		}
+		defer b.Close()
+
+		a.Mailbox.Send(ctx, "worker", 1)
+		a.Mailbox.Send(ctx, "worker", 2)
+		if d, _ := a.Mailbox.TryReceive(ctx); d != nil {
+			t.Error("planner received a message for worker")
+		}
+
+		for want := 1; want <= 2; want++ {
+			d, err := b.Mailbox.TryReceive(ctx)
+			if err != nil || d == nil {
+				t.Fatalf("TryReceive = %v, %v", d, err)
+			}
+			var got int
+			d.Decode(&got)
+			if got != want {
+				t.Errorf("received %d, want %d", got, want)
+			}
+			d.Ack(ctx)
+		}
+	})
+
+	t.Run("receive honors context", func(t *testing.T) {
+		afs := setupTestDB(t)
+		defer afs.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if _, err := afs.Mailbox.Receive(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Receive = %v, want deadline exceeded", err)
+		}
+	})
+}
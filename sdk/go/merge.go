@@ -0,0 +1,214 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// IDMap reports how ImportSession and MergeHistory numbered the records
+// they imported. Records are inserted in the order they started, so
+// importing the same history into copies of a database gives the same IDs.
+type IDMap struct {
+	// ToolCalls maps source tool call IDs to their IDs in this database
+	ToolCalls map[int64]int64 `json:"tool_calls"`
+	// Messages maps source message IDs to their IDs in this database
+	Messages map[int64]int64 `json:"messages"`
+	// Existing lists the source tool calls found in this database by UID
+	// (see AgentFSOptions.IDStrategy), which map to the calls already there
+	// instead of being imported again
+	Existing []int64 `json:"existing,omitempty"`
+	// Unresolved lists the source tool calls whose parent or first retry
+	// attempt was not imported; those references are dropped
+	Unresolved []int64 `json:"unresolved,omitempty"`
+}
+
+// ImportSession imports the conversation and tool calls of a bundle
+// written by ExportSession, renumbering them after the records of this
+// database. Annotations, usage, linked file paths, parents, and retry
+// chains are carried over under the new IDs. The files and KV snapshot of
+// the bundle are not imported.
+//
+// Example:
+//
+//	b, err := agentfs.ReadSessionBundle(f)
+//	ids, err := afs.ImportSession(ctx, b)
+//	call, err := afs.Tools.Get(ctx, ids.ToolCalls[42])
+func (a *AgentFS) ImportSession(ctx context.Context, b *SessionBundle) (*IDMap, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return a.importHistory(ctx, b.ToolCalls, b.Messages)
+}
+
+// MergeHistory imports the tool calls and session messages of src as
+// ImportSession does, e.g. to gather the histories of many agents in one
+// database. Calls with a UID already in this database are not imported
+// twice, so merging the same source again adds only its new calls;
+// messages have no UID and are imported every time.
+func (a *AgentFS) MergeHistory(ctx context.Context, src *AgentFS) (*IDMap, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	srcCtx, srcDone, err := src.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer srcDone()
+
+	found, err := findToolCalls(srcCtx, src.db, ToolCallFilter{Limit: -1})
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]BundleCall, len(found))
+	for i, call := range found {
+		bc, err := src.bundleCall(srcCtx, call)
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = *bc
+	}
+
+	rows, err := src.db.QueryContext(srcCtx, messageListAll)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return a.importHistory(ctx, calls, messages)
+}
+
+// importHistory inserts calls and messages in one transaction, oldest
+// first, and then the references between calls under their new IDs.
+func (a *AgentFS) importHistory(ctx context.Context, calls []BundleCall, messages []Message) (*IDMap, error) {
+	calls = append([]BundleCall(nil), calls...)
+	sort.SliceStable(calls, func(i, j int) bool {
+		if calls[i].StartedAt != calls[j].StartedAt {
+			return calls[i].StartedAt < calls[j].StartedAt
+		}
+		return calls[i].ID < calls[j].ID
+	})
+	messages = append([]Message(nil), messages...)
+	sort.SliceStable(messages, func(i, j int) bool {
+		if messages[i].CreatedAt != messages[j].CreatedAt {
+			return messages[i].CreatedAt < messages[j].CreatedAt
+		}
+		return messages[i].ID < messages[j].ID
+	})
+
+	m := &IDMap{ToolCalls: make(map[int64]int64, len(calls)), Messages: make(map[int64]int64, len(messages))}
+	now := a.FS.now().Unix()
+	tc := a.Tools
+	err := tc.inTx(ctx, func(db dbtx) error {
+		var imported []BundleCall
+		for _, c := range calls {
+			if c.UID != "" {
+				var id int64
+				err := db.QueryRowContext(ctx, toolCallIDByUID, c.UID).Scan(&id)
+				if err == nil {
+					m.ToolCalls[c.ID] = id
+					m.Existing = append(m.Existing, c.ID)
+					continue
+				}
+				if err != sql.ErrNoRows {
+					return err
+				}
+			}
+			uid := &c.UID
+			if c.UID == "" {
+				uid = tc.uids.next()
+			}
+			var id int64
+			err := db.QueryRowContext(ctx, toolCallsInsert,
+				c.Name, jsonText(c.Parameters), jsonText(c.Result), c.Error, c.StartedAt, c.CompletedAt, c.DurationMs, uid,
+			).Scan(&id)
+			if err != nil {
+				return err
+			}
+			m.ToolCalls[c.ID] = id
+			imported = append(imported, c)
+		}
+
+		// References resolve once every call has its new ID
+		for _, c := range imported {
+			id := m.ToolCalls[c.ID]
+			for key, value := range c.Annotations {
+				if _, err := db.ExecContext(ctx, annotationSet, id, key, string(value), now); err != nil {
+					return err
+				}
+			}
+			if c.Usage != (Usage{}) {
+				if _, err := db.ExecContext(ctx, toolCallUsageAdd, id, c.Usage.InputTokens, c.Usage.OutputTokens, c.Usage.CostUSD); err != nil {
+					return err
+				}
+			}
+			for _, p := range c.Files {
+				if _, err := db.ExecContext(ctx, toolCallFileLink, id, p); err != nil {
+					return err
+				}
+			}
+			unresolved := false
+			if c.ParentID != 0 {
+				if parentID, ok := m.ToolCalls[c.ParentID]; ok {
+					if _, err := db.ExecContext(ctx, toolCallSpansSet, id, parentID); err != nil {
+						return err
+					}
+				} else {
+					unresolved = true
+				}
+			}
+			if c.OriginalID != 0 {
+				if originalID, ok := m.ToolCalls[c.OriginalID]; ok {
+					if _, err := db.ExecContext(ctx, toolCallRetryInsert, id, originalID, c.Attempt); err != nil {
+						return err
+					}
+				} else {
+					unresolved = true
+				}
+			}
+			if unresolved {
+				m.Unresolved = append(m.Unresolved, c.ID)
+			}
+		}
+
+		for _, msg := range messages {
+			var id int64
+			if err := db.QueryRowContext(ctx, messageInsert, msg.SessionID, msg.Role, msg.Content, msg.CreatedAt).Scan(&id); err != nil {
+				return err
+			}
+			m.Messages[msg.ID] = id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import history: %w", err)
+	}
+	return m, nil
+}
+
+// jsonText returns raw as text for a nullable JSON column.
+func jsonText(raw json.RawMessage) *string {
+	if raw == nil {
+		return nil
+	}
+	s := string(raw)
+	return &s
+}
@@ -0,0 +1,123 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeHistory(t *testing.T) {
+	ctx := context.Background()
+	src, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "src.db"), IDStrategy: IDULID})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer src.Close()
+	dst := setupTestDB(t)
+	defer dst.Close()
+
+	plan, _ := src.Tools.Record(ctx, "plan", nil, "ok", nil, 10, 20)
+	timeout := "timeout"
+	search, _ := src.Tools.Record(ctx, "search", nil, nil, &timeout, 11, 12)
+	retry, _ := src.Tools.RecordRetry(ctx, search.ID, nil, "found", nil, 13, 14)
+	src.Tools.SetParent(ctx, search.ID, plan.ID)
+	src.Tools.SetParent(ctx, retry.ID, plan.ID)
+	// A retry that started before the attempt it retries still comes after
+	// it in the chain
+	late, _ := src.Tools.RecordRetry(ctx, retry.ID, nil, "found", nil, 12, 15)
+	src.Tools.Annotate(ctx, plan.ID, "triage", "ok")
+	src.Tools.AddUsage(ctx, plan.ID, Usage{InputTokens: 5})
+	src.Tools.LinkFiles(ctx, retry.ID, "/results.json")
+	src.AddMessage(ctx, "sess-1", "user", "find it")
+
+	// The destination already has calls, so every ID shifts
+	local, _ := dst.Tools.Record(ctx, "local", nil, "ok", nil, 1, 2)
+
+	ids, err := dst.MergeHistory(ctx, src)
+	if err != nil {
+		t.Fatalf("MergeHistory failed: %v", err)
+	}
+	if len(ids.ToolCalls) != 4 || len(ids.Messages) != 1 || len(ids.Existing) != 0 || len(ids.Unresolved) != 0 {
+		t.Fatalf("IDMap = %+v", ids)
+	}
+	// Oldest first, after the calls already there
+	if ids.ToolCalls[plan.ID] != local.ID+1 || ids.ToolCalls[search.ID] != local.ID+2 || ids.ToolCalls[late.ID] != local.ID+3 || ids.ToolCalls[retry.ID] != local.ID+4 {
+		t.Errorf("ToolCalls = %v", ids.ToolCalls)
+	}
+
+	tree, err := dst.Tools.Tree(ctx, ids.ToolCalls[plan.ID])
+	if err != nil || len(tree.Children) != 2 {
+		t.Fatalf("Tree = %+v, %v", tree, err)
+	}
+	if tree.UID != plan.UID {
+		t.Errorf("UID = %q, want %q", tree.UID, plan.UID)
+	}
+	attempts, err := dst.Tools.Attempts(ctx, ids.ToolCalls[retry.ID])
+	if err != nil || len(attempts) != 3 || attempts[0].ID != ids.ToolCalls[search.ID] ||
+		attempts[1].ID != ids.ToolCalls[retry.ID] || attempts[2].ID != ids.ToolCalls[late.ID] {
+		t.Errorf("Attempts = %+v, %v", attempts, err)
+	}
+	if v, _ := dst.Tools.Annotations(ctx, ids.ToolCalls[plan.ID]); string(v["triage"]) != `"ok"` {
+		t.Errorf("Annotations = %s", v)
+	}
+	if u, _ := dst.Tools.Usage(ctx, ids.ToolCalls[plan.ID]); u.InputTokens != 5 {
+		t.Errorf("Usage = %+v", u)
+	}
+	if files, _ := dst.Tools.LinkedFiles(ctx, ids.ToolCalls[retry.ID]); len(files) != 1 || files[0] != "/results.json" {
+		t.Errorf("LinkedFiles = %q", files)
+	}
+	if msgs, _ := dst.Messages(ctx, "sess-1"); len(msgs) != 1 || msgs[0].Content != "find it" {
+		t.Errorf("Messages = %+v", msgs)
+	}
+
+	// Calls are recognized by UID the second time
+	report, _ := src.Tools.Record(ctx, "report", nil, "ok", nil, 30, 31)
+	ids, err = dst.MergeHistory(ctx, src)
+	if err != nil {
+		t.Fatalf("second MergeHistory failed: %v", err)
+	}
+	if len(ids.Existing) != 4 || ids.ToolCalls[plan.ID] != local.ID+1 || ids.ToolCalls[report.ID] != local.ID+5 {
+		t.Errorf("second IDMap = %+v", ids)
+	}
+}
+
+func TestImportSession(t *testing.T) {
+	ctx := context.Background()
+	src := setupTestDB(t)
+	defer src.Close()
+	dst := setupTestDB(t)
+	defer dst.Close()
+
+	parent, _ := src.Tools.Record(ctx, "plan", nil, "ok", nil, 1, 9)
+	call, _ := src.Tools.Record(ctx, "write", nil, "ok", nil, 2, 3)
+	src.Tools.SetParent(ctx, call.ID, parent.ID)
+	src.Tools.Annotate(ctx, call.ID, SessionAnnotation, "sess-1")
+	src.AddMessage(ctx, "sess-1", "user", "write it")
+
+	var buf bytes.Buffer
+	if err := src.ExportSession(ctx, "sess-1", &buf); err != nil {
+		t.Fatalf("ExportSession failed: %v", err)
+	}
+	b, err := ReadSessionBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadSessionBundle failed: %v", err)
+	}
+	if b.ToolCalls[0].ParentID != parent.ID {
+		t.Errorf("bundled ParentID = %d, want %d", b.ToolCalls[0].ParentID, parent.ID)
+	}
+
+	dst.Tools.Record(ctx, "local", nil, "ok", nil, 1, 2)
+	ids, err := dst.ImportSession(ctx, b)
+	if err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	// The parent was not part of the session
+	if len(ids.Unresolved) != 1 || ids.Unresolved[0] != call.ID {
+		t.Errorf("Unresolved = %v", ids.Unresolved)
+	}
+	calls, err := dst.Tools.Find(ctx, ToolCallFilter{Annotations: map[string]any{SessionAnnotation: "sess-1"}})
+	if err != nil || len(calls) != 1 || calls[0].ID != ids.ToolCalls[call.ID] {
+		t.Errorf("imported calls = %+v, %v (IDMap %v)", calls, err, ids.ToolCalls)
+	}
+}
@@ -0,0 +1,191 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SetMeta attaches a custom metadata field to the file or directory at p,
+// replacing any existing value for key. Metadata is stored separately from
+// file content and follows the inode across renames and hard links.
+//
+// Example:
+//
+//	err := afs.FS.SetMeta(ctx, "/output/report.md", "status", "reviewed")
+func (fs *Filesystem) SetMeta(ctx context.Context, p, key, value string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if key == "" {
+		return ErrInval("setmeta", p, "empty metadata key")
+	}
+
+	p, err = fs.cleanPath(ctx, "setmeta", p)
+	if err != nil {
+		return err
+	}
+
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.db.ExecContext(ctx, metaSet, ino, key, value); err != nil {
+		return fmt.Errorf("failed to set metadata: %w", err)
+	}
+	return nil
+}
+
+// GetMeta returns the value of a metadata field. ok is false if the field is
+// not set.
+func (fs *Filesystem) GetMeta(ctx context.Context, p, key string) (value string, ok bool, err error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "getmeta", p)
+	if err != nil {
+		return "", false, err
+	}
+
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return "", false, err
+	}
+
+	err = fs.db.QueryRowContext(ctx, metaGet, ino, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	return value, true, nil
+}
+
+// Meta returns all metadata fields set on p.
+func (fs *Filesystem) Meta(ctx context.Context, p string) (map[string]string, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "meta", p)
+	if err != nil {
+		return nil, err
+	}
+
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := fs.db.QueryContext(ctx, metaList, ino)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metadata: %w", err)
+	}
+	defer rows.Close()
+
+	meta := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan metadata: %w", err)
+		}
+		meta[key] = value
+	}
+	return meta, rows.Err()
+}
+
+// DeleteMeta removes a metadata field from p. Removing a field that is not
+// set is not an error.
+func (fs *Filesystem) DeleteMeta(ctx context.Context, p, key string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "deletemeta", p)
+	if err != nil {
+		return err
+	}
+
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.db.ExecContext(ctx, metaDelete, ino, key); err != nil {
+		return fmt.Errorf("failed to delete metadata: %w", err)
+	}
+	return nil
+}
+
+// FindByMeta returns the paths below q.Root whose metadata matches q, sorted
+// by path. Lookups by key and value use an index.
+//
+// Example:
+//
+//	// Outputs nobody has reviewed yet
+//	paths, err := afs.FS.FindByMeta(ctx, agentfs.MetaQuery{
+//	    Root:    "/output",
+//	    Key:     "status",
+//	    Value:   "reviewed",
+//	    Missing: true,
+//	})
+func (fs *Filesystem) FindByMeta(ctx context.Context, q MetaQuery) ([]string, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if q.Key == "" {
+		return nil, ErrInval("findmeta", q.Root, "empty metadata key")
+	}
+
+	root, err := fs.cleanPath(ctx, "findmeta", q.Root)
+	if err != nil {
+		return nil, err
+	}
+	rootIno, err := fs.resolvePathFollow(ctx, root, true)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := fs.statInode(ctx, rootIno)
+	if err != nil {
+		return nil, err
+	}
+	if !stats.IsDir() {
+		return nil, ErrNotDir("findmeta", root)
+	}
+
+	var value any
+	if q.Value != "" {
+		value = q.Value
+	}
+
+	rows, err := fs.db.QueryContext(ctx, metaQuery, rootIno, root, q.Missing, q.Key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metadata: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("failed to scan path: %w", err)
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
@@ -0,0 +1,109 @@
+package agentfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFileMeta(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	if err := fs.WriteFile(ctx, "/out/a.md", []byte("a"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	t.Run("set and get", func(t *testing.T) {
+		if err := fs.SetMeta(ctx, "/out/a.md", "status", "draft"); err != nil {
+			t.Fatalf("SetMeta failed: %v", err)
+		}
+		if err := fs.SetMeta(ctx, "/out/a.md", "status", "reviewed"); err != nil {
+			t.Fatalf("SetMeta failed: %v", err)
+		}
+		value, ok, err := fs.GetMeta(ctx, "/out/a.md", "status")
+		if err != nil || !ok || value != "reviewed" {
+			t.Errorf("GetMeta = %q, %v, %v; want reviewed", value, ok, err)
+		}
+		if _, ok, err := fs.GetMeta(ctx, "/out/a.md", "owner"); err != nil || ok {
+			t.Errorf("GetMeta(owner) ok = %v, err = %v; want not set", ok, err)
+		}
+	})
+
+	t.Run("follows rename", func(t *testing.T) {
+		if err := fs.Rename(ctx, "/out/a.md", "/out/b.md"); err != nil {
+			t.Fatalf("Rename failed: %v", err)
+		}
+		meta, err := fs.Meta(ctx, "/out/b.md")
+		if err != nil {
+			t.Fatalf("Meta failed: %v", err)
+		}
+		if !reflect.DeepEqual(meta, map[string]string{"status": "reviewed"}) {
+			t.Errorf("Meta = %v", meta)
+		}
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		if err := fs.SetMeta(ctx, "/nope", "k", "v"); !IsNotExist(err) {
+			t.Errorf("SetMeta err = %v, want ENOENT", err)
+		}
+	})
+
+	t.Run("removed with inode", func(t *testing.T) {
+		if err := fs.Unlink(ctx, "/out/b.md"); err != nil {
+			t.Fatalf("Unlink failed: %v", err)
+		}
+		var n int
+		if err := afs.DB().QueryRow("SELECT COUNT(*) FROM fs_meta").Scan(&n); err != nil {
+			t.Fatalf("count failed: %v", err)
+		}
+		if n != 0 {
+			t.Errorf("fs_meta rows = %d, want 0", n)
+		}
+	})
+}
+
+func TestFindByMeta(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	for _, p := range []string{"/out/1.md", "/out/2.md", "/out/sub/3.md", "/other/4.md"} {
+		if err := fs.WriteFile(ctx, p, []byte("x"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	for _, p := range []string{"/out/1.md", "/other/4.md"} {
+		if err := fs.SetMeta(ctx, p, "status", "reviewed"); err != nil {
+			t.Fatalf("SetMeta failed: %v", err)
+		}
+	}
+	if err := fs.SetMeta(ctx, "/out/sub/3.md", "status", "draft"); err != nil {
+		t.Fatalf("SetMeta failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		q    MetaQuery
+		want []string
+	}{
+		{"by value", MetaQuery{Key: "status", Value: "reviewed"}, []string{"/other/4.md", "/out/1.md"}},
+		{"any value", MetaQuery{Root: "/out", Key: "status"}, []string{"/out/1.md", "/out/sub/3.md"}},
+		{"missing", MetaQuery{Root: "/out", Key: "status", Value: "reviewed", Missing: true}, []string{"/out/2.md", "/out/sub/3.md"}},
+		{"no match", MetaQuery{Key: "owner"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fs.FindByMeta(ctx, tt.q)
+			if err != nil {
+				t.Fatalf("FindByMeta failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindByMeta = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
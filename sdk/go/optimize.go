@@ -0,0 +1,110 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultAnalysisLimit is the number of index rows ANALYZE samples per
+// index unless OptimizeOptions.AnalysisLimit is set.
+const DefaultAnalysisLimit = 1000
+
+// optimizeAll makes PRAGMA optimize consider every table, not only those
+// the connection queried, and analyze tables that were never analyzed.
+const optimizeAll = "PRAGMA optimize=0x10002"
+
+// optimizer refreshes the statistics the query planner relies on. It is
+// nil when the database is read-only, and in transactions.
+type optimizer struct {
+	conn  sqlConn
+	gate  *writeGate
+	limit int
+	mu    sync.Mutex // One run at a time
+}
+
+// newOptimizer returns an optimizer of db with defaults applied to opts.
+// Runs are scheduled through gate as background transactions.
+func newOptimizer(db sqlConn, gate *writeGate, opts OptimizeOptions) *optimizer {
+	limit := opts.AnalysisLimit
+	if limit <= 0 {
+		limit = DefaultAnalysisLimit
+	}
+	return &optimizer{conn: db, gate: gate, limit: limit}
+}
+
+// run runs PRAGMA optimize. The analysis limit is a setting of the
+// connection, so both statements run in one transaction to share it. The
+// transaction queues behind foreground ones, since it holds the write lock
+// while it samples the indexes.
+func (o *optimizer) run(ctx context.Context) error {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.gate != nil {
+		if err := o.gate.acquire(ctx, PriorityBackground); err != nil {
+			return err
+		}
+		defer o.gate.release()
+	}
+
+	tx, err := o.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to optimize: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA analysis_limit=%d", o.limit)); err != nil {
+		return fmt.Errorf("failed to optimize: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, optimizeAll); err != nil {
+		return fmt.Errorf("failed to optimize: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to optimize: %w", err)
+	}
+	return nil
+}
+
+// Optimize refreshes the statistics the SQLite query planner uses to pick
+// indexes, analyzing the tables that were never analyzed or whose size
+// changed a lot since. Without fresh statistics, query plans can degrade
+// badly after large imports or deletions. Each index is sampled up to
+// OptimizeOptions.AnalysisLimit rows, so a run takes milliseconds on most
+// databases.
+//
+// AgentFS runs it on Close, after ImportDir, Restore, and EnforcePolicy
+// runs that changed anything, and every OptimizeOptions.Interval if set,
+// always as a background transaction (see PriorityBackground). It does
+// nothing on a read-only database.
+func (a *AgentFS) Optimize(ctx context.Context) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return a.FS.optimizer.run(ctx)
+}
+
+// startOptimizer runs Optimize every interval in the background.
+func (a *AgentFS) startOptimizer(interval time.Duration) {
+	if interval <= 0 || a.FS.optimizer == nil {
+		return
+	}
+	a.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			// Errors are retried on the next interval
+			a.FS.optimizer.run(context.Background())
+		}
+	})
+}
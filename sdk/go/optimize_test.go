@@ -0,0 +1,42 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "agent.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: path, Optimize: OptimizeOptions{AnalysisLimit: 100}})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := afs.FS.WriteFile(ctx, fmt.Sprintf("/src/f%d.go", i), []byte("package main"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	if err := afs.Optimize(ctx); err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	var stats int
+	if err := afs.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'fs_dentry'").Scan(&stats); err != nil || stats == 0 {
+		t.Errorf("no statistics for fs_dentry after Optimize: %d, %v", stats, err)
+	}
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	ro, err := Open(ctx, AgentFSOptions{Path: path, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("read-only Open failed: %v", err)
+	}
+	defer ro.Close()
+	if err := ro.Optimize(ctx); err != nil {
+		t.Errorf("Optimize on a read-only database = %v, want nil", err)
+	}
+}
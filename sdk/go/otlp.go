@@ -0,0 +1,240 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// DefaultOTLPBatchSize is the number of spans ExportOTLP sends per request.
+const DefaultOTLPBatchSize = 512
+
+// OTLPOptions configures ToolCalls.ExportOTLP.
+type OTLPOptions struct {
+	// Endpoint is the OTLP/HTTP traces URL of the collector, e.g.
+	// "http://localhost:4318/v1/traces" for Jaeger, Tempo, or the
+	// OpenTelemetry Collector.
+	Endpoint string
+
+	// Headers are added to each request, e.g. for authentication.
+	Headers map[string]string
+
+	// ServiceName is the service.name resource attribute. It also keys the
+	// trace and span IDs, so use a name per agent database.
+	// Default: "agentfs".
+	ServiceName string
+
+	// BatchSize is the number of spans per request.
+	// Default: DefaultOTLPBatchSize.
+	BatchSize int
+
+	// Client is the HTTP client used for requests.
+	// Default: http.DefaultClient.
+	Client *http.Client
+}
+
+// ExportOTLP sends the tool calls matching filter to an OpenTelemetry
+// collector as spans, over OTLP/HTTP with JSON encoding. Each call becomes
+// a span named after its tool, from StartedAt lasting DurationMs, with its
+// parameters, result, and error as attributes. Calls nested with
+// PendingCall.Start or SetParent share the trace of their root call and
+// point at their parent's span. IDs are derived from ServiceName and the
+// call IDs, so exporting a call again yields the same span. Set
+// filter.Limit to export more than the default 100 calls.
+//
+// Example:
+//
+//	err := afs.Tools.ExportOTLP(ctx, agentfs.ToolCallFilter{Since: lastExport, Limit: -1}, agentfs.OTLPOptions{
+//	    Endpoint:    "http://tempo:4318/v1/traces",
+//	    ServiceName: "agent-worker-7",
+//	})
+func (tc *ToolCalls) ExportOTLP(ctx context.Context, filter ToolCallFilter, opts OTLPOptions) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if opts.Endpoint == "" {
+		return fmt.Errorf("OTLP endpoint is required")
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = "agentfs"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultOTLPBatchSize
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	calls, err := findToolCalls(ctx, tc.db, filter)
+	if err != nil {
+		return err
+	}
+	parents := map[int64]int64{}
+	for start := 0; start < len(calls); start += opts.BatchSize {
+		end := min(start+opts.BatchSize, len(calls))
+		spans := make([]otlpSpan, 0, end-start)
+		for _, call := range calls[start:end] {
+			span, err := tc.otlpSpan(ctx, call, opts.ServiceName, parents)
+			if err != nil {
+				return err
+			}
+			spans = append(spans, span)
+		}
+		if err := sendOTLP(ctx, opts, spans); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parentOf returns the parent of the tool call id, or 0, remembering it in
+// parents.
+func (tc *ToolCalls) parentOf(ctx context.Context, id int64, parents map[int64]int64) (int64, error) {
+	if parent, ok := parents[id]; ok {
+		return parent, nil
+	}
+	var parent sql.NullInt64
+	err := tc.db.QueryRowContext(ctx, toolCallParent, id).Scan(&parent)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query parent: %w", err)
+	}
+	parents[id] = parent.Int64
+	return parent.Int64, nil
+}
+
+// otlpSpan converts call to a span.
+func (tc *ToolCalls) otlpSpan(ctx context.Context, call ToolCall, service string, parents map[int64]int64) (otlpSpan, error) {
+	parent, err := tc.parentOf(ctx, call.ID, parents)
+	if err != nil {
+		return otlpSpan{}, err
+	}
+	root := call.ID
+	// SetParent keeps the chain acyclic; the bound guards against damage
+	for p, depth := parent, 0; p != 0 && depth < 1000; depth++ {
+		root = p
+		if p, err = tc.parentOf(ctx, p, parents); err != nil {
+			return otlpSpan{}, err
+		}
+	}
+
+	span := otlpSpan{
+		TraceID:           otlpID(service, "trace", root, 16),
+		SpanID:            otlpID(service, "span", call.ID, 8),
+		Name:              call.Name,
+		Kind:              1, // SPAN_KIND_INTERNAL
+		StartTimeUnixNano: strconv.FormatInt(call.StartedAt*1e9, 10),
+		EndTimeUnixNano:   strconv.FormatInt(call.StartedAt*1e9+call.DurationMs*1e6, 10),
+		Attributes: []otlpAttribute{
+			otlpString("tool.name", call.Name),
+			{Key: "agentfs.tool_call_id", Value: otlpValue{IntValue: strconv.FormatInt(call.ID, 10)}},
+		},
+		Status: otlpStatus{Code: 1}, // STATUS_CODE_OK
+	}
+	if parent != 0 {
+		span.ParentSpanID = otlpID(service, "span", parent, 8)
+	}
+	if call.Parameters != nil {
+		span.Attributes = append(span.Attributes, otlpString("tool.parameters", string(call.Parameters)))
+	}
+	if call.Result != nil {
+		span.Attributes = append(span.Attributes, otlpString("tool.result", string(call.Result)))
+	}
+	if call.Error != nil {
+		span.Status = otlpStatus{Code: 2, Message: *call.Error} // STATUS_CODE_ERROR
+	}
+	return span, nil
+}
+
+// otlpID derives a trace or span ID of size bytes, as hex.
+func otlpID(service, kind string, id int64, size int) string {
+	sum := sha256.Sum256([]byte(service + "/" + kind + "/" + strconv.FormatInt(id, 10)))
+	return hex.EncodeToString(sum[:size])
+}
+
+// sendOTLP posts spans to the collector.
+func sendOTLP(ctx context.Context, opts OTLPOptions, spans []otlpSpan) error {
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{otlpString("service.name", opts.ServiceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "agentfs"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to export spans: collector returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// OTLP/HTTP JSON encoding of an ExportTraceServiceRequest
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue,omitempty"`
+		IntValue    string `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
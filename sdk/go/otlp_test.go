@@ -0,0 +1,75 @@
+package agentfs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportOTLP(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	root, err := afs.Tools.Record(ctx, "planner", map[string]string{"task": "q3"}, "done", nil, 100, 103)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	errMsg := "timeout"
+	child, _ := afs.Tools.Record(ctx, "web_search", nil, nil, &errMsg, 101, 102)
+	if err := afs.Tools.SetParent(ctx, child.ID, root.ID); err != nil {
+		t.Fatalf("SetParent failed: %v", err)
+	}
+
+	var got otlpRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("bad request: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	err = afs.Tools.ExportOTLP(ctx, ToolCallFilter{}, OTLPOptions{
+		Endpoint:    srv.URL,
+		Headers:     map[string]string{"Authorization": "Bearer t"},
+		ServiceName: "worker",
+	})
+	if err != nil {
+		t.Fatalf("ExportOTLP failed: %v", err)
+	}
+	if auth != "Bearer t" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("request = %+v", got)
+	}
+	spans := map[string]otlpSpan{}
+	for _, s := range got.ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[s.Name] = s
+	}
+	p, c := spans["planner"], spans["web_search"]
+	if p.TraceID == "" || c.TraceID != p.TraceID {
+		t.Errorf("trace IDs = %q, %q; want shared", p.TraceID, c.TraceID)
+	}
+	if p.ParentSpanID != "" || c.ParentSpanID != p.SpanID {
+		t.Errorf("parent span IDs = %q, %q", p.ParentSpanID, c.ParentSpanID)
+	}
+	if p.StartTimeUnixNano != "100000000000" || p.EndTimeUnixNano != "103000000000" {
+		t.Errorf("planner times = %s..%s", p.StartTimeUnixNano, p.EndTimeUnixNano)
+	}
+	if p.Status.Code != 1 || c.Status.Code != 2 || c.Status.Message != "timeout" {
+		t.Errorf("statuses = %+v, %+v", p.Status, c.Status)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer failing.Close()
+	if err := afs.Tools.ExportOTLP(ctx, ToolCallFilter{}, OTLPOptions{Endpoint: failing.URL}); err == nil {
+		t.Error("ExportOTLP to a failing collector succeeded")
+	}
+}
@@ -0,0 +1,207 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultToolOutputPollInterval is how often TailOutput checks for output
+// appended by other processes.
+const DefaultToolOutputPollInterval = 250 * time.Millisecond
+
+// toolOutputBatch is how many output chunks a tail reads at a time.
+const toolOutputBatch = 64
+
+// AppendOutput adds chunk to the output of the running tool call id, the
+// ID of its PendingCall. Long-running tools, such as shell commands or
+// streamed model responses, append as they go so TailOutput can follow
+// them from another process; once the call is recorded, Output returns
+// everything appended. Returns an error if the call is not running.
+//
+// Example:
+//
+//	call, _ := afs.Tools.Start(ctx, "shell", map[string]string{"cmd": cmd})
+//	for line := range lines {
+//	    afs.Tools.AppendOutput(ctx, call.ID(), []byte(line+"\n"))
+//	}
+func (tc *ToolCalls) AppendOutput(ctx context.Context, id int64, chunk []byte) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if len(chunk) == 0 {
+		return nil
+	}
+	var name string
+	err = tc.inTx(ctx, func(db dbtx) error {
+		if err := db.QueryRowContext(ctx, toolCallPendingName, id).Scan(&name); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, toolCallOutputAppend, id, chunk)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("tool call not running: %d", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append output: %w", err)
+	}
+	tc.publish(Event{Kind: EventToolCallOutput, Path: name})
+	return nil
+}
+
+// AppendOutput adds chunk to the output of the pending call.
+func (pc *PendingCall) AppendOutput(ctx context.Context, chunk []byte) error {
+	return pc.tc.AppendOutput(ctx, pc.id, chunk)
+}
+
+// Output returns the output appended to the recorded tool call id while it
+// ran, or nil if there was none.
+func (tc *ToolCalls) Output(ctx context.Context, id int64) ([]byte, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallOutputGet, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	defer rows.Close()
+	var output []byte
+	for rows.Next() {
+		var chunk []byte
+		if err := rows.Scan(&chunk); err != nil {
+			return nil, fmt.Errorf("failed to read output: %w", err)
+		}
+		output = append(output, chunk...)
+	}
+	return output, rows.Err()
+}
+
+// ToolOutput streams the output of a tool call, from TailOutput.
+type ToolOutput struct {
+	// Chunks delivers each appended chunk, in order. It is closed once the
+	// call has stopped running and all its output is delivered, when the
+	// context ends, or when reading fails (see Err).
+	Chunks <-chan []byte
+
+	err error
+}
+
+// Err returns the error that stopped the tail, if any, once Chunks is
+// closed. It is nil when the call finished or the context ended.
+func (o *ToolOutput) Err() error {
+	return o.err
+}
+
+// TailOutput streams the output of the tool call id, the ID of its
+// PendingCall, from the first chunk, until the call completes or is
+// interrupted. Output appended through this AgentFS is picked up at once;
+// output appended by other processes sharing the database is found by
+// polling every DefaultToolOutputPollInterval. Tailing a call that has
+// already finished delivers its output and stops.
+//
+// Example:
+//
+//	out, err := afs.Tools.TailOutput(ctx, pendingID)
+//	if err != nil {
+//	    return err
+//	}
+//	for chunk := range out.Chunks {
+//	    os.Stdout.Write(chunk)
+//	}
+func (tc *ToolCalls) TailOutput(ctx context.Context, id int64) (*ToolOutput, error) {
+	_, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done()
+
+	chunks := make(chan []byte, toolOutputBatch)
+	o := &ToolOutput{Chunks: chunks}
+	var wake <-chan Event
+	cancel := func() {}
+	if tc.events != nil {
+		wake, cancel = tc.events.subscribe(EventFilter{Kinds: []EventKind{EventToolCallOutput, EventToolCallCompleted}}, 1)
+	}
+	go func() {
+		defer close(chunks)
+		defer cancel()
+		ticker := time.NewTicker(DefaultToolOutputPollInterval)
+		defer ticker.Stop()
+		var seq int64
+		for {
+			// Whether it runs is read first, so output appended before it
+			// stopped is read below
+			running, batch, err := tc.outputSince(ctx, id, seq)
+			if err != nil {
+				if ctx.Err() == nil {
+					o.err = err
+				}
+				return
+			}
+			for _, c := range batch {
+				select {
+				case chunks <- c.data:
+				case <-ctx.Done():
+					return
+				}
+				seq = c.seq
+			}
+			if len(batch) == toolOutputBatch {
+				continue
+			}
+			if !running {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return o, nil
+}
+
+// outputChunk is a chunk of tool call output.
+type outputChunk struct {
+	seq  int64
+	data []byte
+}
+
+// outputSince reports whether the tool call id is running, then reads the
+// next batch of its output after seq.
+func (tc *ToolCalls) outputSince(ctx context.Context, id, seq int64) (bool, []outputChunk, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	defer done()
+
+	var running int
+	if err := tc.db.QueryRowContext(ctx, toolCallPendingRunning, id).Scan(&running); err != nil {
+		return false, nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	rows, err := tc.db.QueryContext(ctx, toolCallOutputSince, id, seq, toolOutputBatch)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	defer rows.Close()
+	var batch []outputChunk
+	for rows.Next() {
+		var c outputChunk
+		if err := rows.Scan(&c.seq, &c.data); err != nil {
+			return false, nil, fmt.Errorf("failed to read output: %w", err)
+		}
+		batch = append(batch, c)
+	}
+	return running > 0, batch, rows.Err()
+}
@@ -0,0 +1,51 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestToolCallOutput(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	pending, err := afs.Tools.Start(ctx, "shell", map[string]string{"cmd": "make"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := pending.AppendOutput(ctx, []byte("compiling\n")); err != nil {
+		t.Fatalf("AppendOutput failed: %v", err)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := afs.Tools.TailOutput(tctx, pending.ID())
+	if err != nil {
+		t.Fatalf("TailOutput failed: %v", err)
+	}
+	if chunk := <-out.Chunks; string(chunk) != "compiling\n" {
+		t.Errorf("first chunk = %q", chunk)
+	}
+
+	afs.Tools.AppendOutput(ctx, pending.ID(), []byte("linking\n"))
+	call, err := pending.Success(ctx, nil)
+	if err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+	var rest string
+	for chunk := range out.Chunks {
+		rest += string(chunk)
+	}
+	if rest != "linking\n" || out.Err() != nil {
+		t.Errorf("tail after first chunk = %q, %v", rest, out.Err())
+	}
+
+	if got, err := afs.Tools.Output(ctx, call.ID); err != nil || string(got) != "compiling\nlinking\n" {
+		t.Errorf("Output = %q, %v", got, err)
+	}
+	if err := pending.AppendOutput(ctx, []byte("late")); err == nil {
+		t.Error("AppendOutput to a completed call succeeded")
+	}
+}
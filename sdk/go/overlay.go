@@ -286,7 +286,7 @@ func (ofs *OverlayFS) loadWhiteouts(ctx context.Context) error {
 
 // createWhiteout creates a whiteout for a path.
 func (ofs *OverlayFS) createWhiteout(ctx context.Context, p string) error {
-	now := time.Now().Unix()
+	now := ofs.delta.now().Unix()
 	parent := parentPath(p)
 
 	_, err := ofs.db.ExecContext(ctx, whiteoutInsert, p, parent, now)
@@ -464,7 +464,7 @@ func (ofs *OverlayFS) ensureParentDirs(ctx context.Context, p string, uid, gid i
 		}
 
 		// Create directory in delta
-		now := time.Now()
+		now := ofs.delta.now()
 		nowSec := now.Unix()
 		nowNsec := int64(now.Nanosecond())
 		dirMode := S_IFDIR | 0o755
@@ -475,6 +475,7 @@ func (ofs *OverlayFS) ensureParentDirs(ctx context.Context, p string, uid, gid i
 			return err
 		}
 
+		ofs.delta.noteDentry(currentDeltaIno, component)
 		if _, err := ofs.db.ExecContext(ctx, insertDentry, component, currentDeltaIno, newIno); err != nil {
 			return err
 		}
@@ -544,7 +545,7 @@ func (ofs *OverlayFS) copyUp(ctx context.Context, p string, baseIno int64) (int6
 		parentIno = ino
 	}
 
-	now := time.Now()
+	now := ofs.delta.now()
 	nowSec := now.Unix()
 	nowNsec := int64(now.Nanosecond())
 
@@ -590,6 +591,7 @@ func (ofs *OverlayFS) copyUp(ctx context.Context, p string, baseIno int64) (int6
 	}
 
 	// Create dentry
+	ofs.delta.noteDentry(parentIno, name)
 	if _, err := ofs.db.ExecContext(ctx, insertDentry, name, parentIno, deltaIno); err != nil {
 		return 0, err
 	}
@@ -899,7 +901,10 @@ func (ofs *OverlayFS) ReaddirPlus(ctx context.Context, ino int64) ([]DirEntry, e
 // WriteFile writes data to a file, creating it if it doesn't exist.
 // Creates in the delta layer and removes any whiteout.
 func (ofs *OverlayFS) WriteFile(ctx context.Context, p string, data []byte, mode int64) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "write", p)
+	if err != nil {
+		return err
+	}
 
 	// Remove whiteout if exists (this also invalidates cache)
 	if err := ofs.removeWhiteout(ctx, p); err != nil {
@@ -930,7 +935,10 @@ func (ofs *OverlayFS) WriteFile(ctx context.Context, p string, data []byte, mode
 
 // ReadFile reads the entire contents of a file.
 func (ofs *OverlayFS) ReadFile(ctx context.Context, p string) ([]byte, error) {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "read", p)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check for whiteout
 	if ofs.isWhiteout(p) {
@@ -976,7 +984,10 @@ func (ofs *OverlayFS) resolvePathToBaseIno(ctx context.Context, p string) (int64
 
 // LookupPath looks up a path and returns its stats.
 func (ofs *OverlayFS) LookupPath(ctx context.Context, p string) (*Stats, error) {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "stat", p)
+	if err != nil {
+		return nil, err
+	}
 
 	if p == "/" {
 		return ofs.Stat(ctx, RootIno)
@@ -1016,7 +1027,10 @@ func (ofs *OverlayFS) LookupPath(ctx context.Context, p string) (*Stats, error)
 
 // Mkdir creates a directory in the delta layer.
 func (ofs *OverlayFS) Mkdir(ctx context.Context, p string, mode int64) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "mkdir", p)
+	if err != nil {
+		return err
+	}
 
 	// Check if whiteout exists - if so, we're recreating a deleted item
 	wasWhiteout := ofs.isWhiteout(p)
@@ -1049,7 +1063,10 @@ func (ofs *OverlayFS) Mkdir(ctx context.Context, p string, mode int64) error {
 
 // MkdirAll creates a directory and all parent directories.
 func (ofs *OverlayFS) MkdirAll(ctx context.Context, p string, mode int64) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "mkdir", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return nil
 	}
@@ -1086,7 +1103,10 @@ func (ofs *OverlayFS) MkdirAll(ctx context.Context, p string, mode int64) error
 
 // Unlink removes a file.
 func (ofs *OverlayFS) Unlink(ctx context.Context, p string) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "unlink", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return ErrPerm("unlink", p)
 	}
@@ -1123,7 +1143,10 @@ func (ofs *OverlayFS) Unlink(ctx context.Context, p string) error {
 
 // Rmdir removes an empty directory.
 func (ofs *OverlayFS) Rmdir(ctx context.Context, p string) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "rmdir", p)
+	if err != nil {
+		return err
+	}
 	if p == "/" {
 		return ErrPerm("rmdir", p)
 	}
@@ -1169,8 +1192,14 @@ func (ofs *OverlayFS) Rmdir(ctx context.Context, p string) error {
 
 // Rename moves or renames a file or directory.
 func (ofs *OverlayFS) Rename(ctx context.Context, oldPath, newPath string) error {
-	oldPath = normalizePath(oldPath)
-	newPath = normalizePath(newPath)
+	oldPath, err := ofs.delta.cleanPath(ctx, "rename", oldPath)
+	if err != nil {
+		return err
+	}
+	newPath, err = ofs.delta.cleanPath(ctx, "rename", newPath)
+	if err != nil {
+		return err
+	}
 
 	if oldPath == "/" || newPath == "/" {
 		return ErrPerm("rename", oldPath)
@@ -1223,8 +1252,14 @@ func (ofs *OverlayFS) Rename(ctx context.Context, oldPath, newPath string) error
 
 // Link creates a hard link.
 func (ofs *OverlayFS) Link(ctx context.Context, existingPath, newPath string) error {
-	existingPath = normalizePath(existingPath)
-	newPath = normalizePath(newPath)
+	existingPath, err := ofs.delta.cleanPath(ctx, "link", existingPath)
+	if err != nil {
+		return err
+	}
+	newPath, err = ofs.delta.cleanPath(ctx, "link", newPath)
+	if err != nil {
+		return err
+	}
 
 	// Get existing stats
 	stats, err := ofs.LookupPath(ctx, existingPath)
@@ -1262,7 +1297,10 @@ func (ofs *OverlayFS) Link(ctx context.Context, existingPath, newPath string) er
 
 // Symlink creates a symbolic link.
 func (ofs *OverlayFS) Symlink(ctx context.Context, target, linkPath string) error {
-	linkPath = normalizePath(linkPath)
+	linkPath, err := ofs.delta.cleanPath(ctx, "symlink", linkPath)
+	if err != nil {
+		return err
+	}
 
 	// Remove whiteout if exists (also invalidates cache)
 	if err := ofs.removeWhiteout(ctx, linkPath); err != nil {
@@ -1277,7 +1315,10 @@ func (ofs *OverlayFS) Symlink(ctx context.Context, target, linkPath string) erro
 
 // Readlink returns the target of a symbolic link.
 func (ofs *OverlayFS) Readlink(ctx context.Context, p string) (string, error) {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "readlink", p)
+	if err != nil {
+		return "", err
+	}
 
 	// Check for whiteout
 	if ofs.isWhiteout(p) {
@@ -1304,7 +1345,10 @@ func (ofs *OverlayFS) Readlink(ctx context.Context, p string) (string, error) {
 
 // Chmod changes file permissions.
 func (ofs *OverlayFS) Chmod(ctx context.Context, p string, mode int64) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "chmod", p)
+	if err != nil {
+		return err
+	}
 
 	stats, err := ofs.LookupPath(ctx, p)
 	if err != nil {
@@ -1329,7 +1373,10 @@ func (ofs *OverlayFS) Chmod(ctx context.Context, p string, mode int64) error {
 
 // Utimes updates file timestamps.
 func (ofs *OverlayFS) Utimes(ctx context.Context, p string, atime, mtime int64) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "utimes", p)
+	if err != nil {
+		return err
+	}
 
 	stats, err := ofs.LookupPath(ctx, p)
 	if err != nil {
@@ -1354,7 +1401,10 @@ func (ofs *OverlayFS) Utimes(ctx context.Context, p string, atime, mtime int64)
 
 // Utimens updates file timestamps with selective control.
 func (ofs *OverlayFS) Utimens(ctx context.Context, p string, atime, mtime TimeChange) error {
-	p = normalizePath(p)
+	p, err := ofs.delta.cleanPath(ctx, "utimens", p)
+	if err != nil {
+		return err
+	}
 
 	stats, err := ofs.LookupPath(ctx, p)
 	if err != nil {
@@ -0,0 +1,115 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Path validation limits applied when PathOptions leaves them unset.
+const (
+	DefaultMaxPathLength = 4096 // Maximum path length in bytes (PATH_MAX)
+	DefaultMaxPathDepth  = 256  // Maximum number of path components
+)
+
+// PathProblem describes why a path was rejected.
+type PathProblem string
+
+const (
+	PathEscapesRoot PathProblem = "escapes root"        // ".." climbs above "/"
+	PathHasNUL      PathProblem = "contains NUL byte"   // Paths are C strings to FUSE and the host
+	PathNameTooLong PathProblem = "component too long"  // A component exceeds MaxNameLen bytes
+	PathTooLong     PathProblem = "path too long"       // The path exceeds PathOptions.MaxLength
+	PathTooDeep     PathProblem = "too many components" // The path exceeds PathOptions.MaxDepth
+)
+
+// PathError is returned when a caller-supplied path fails validation. It
+// unwraps to an *FSError with code ENAMETOOLONG for length problems and
+// EINVAL otherwise, so IsNameTooLong and errors.Is work as for other
+// filesystem errors.
+type PathError struct {
+	Op      string
+	Path    string
+	Problem PathProblem
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s %q: invalid path: %s", e.Op, e.Path, e.Problem)
+}
+
+// Unwrap returns the equivalent *FSError.
+func (e *PathError) Unwrap() error {
+	code := EINVAL
+	if e.Problem == PathNameTooLong || e.Problem == PathTooLong {
+		code = ENAMETOOLONG
+	}
+	return &FSError{Code: code, Syscall: e.Op, Path: e.Path, Message: "invalid path: " + string(e.Problem)}
+}
+
+// validate checks a caller-supplied path against opts before it is cleaned.
+// Relative paths are resolved against the root.
+func (opts PathOptions) validate(op, p string) error {
+	if strings.IndexByte(p, 0) >= 0 {
+		return &PathError{Op: op, Path: p, Problem: PathHasNUL}
+	}
+	if len(p) > opts.MaxLength {
+		return &PathError{Op: op, Path: p, Problem: PathTooLong}
+	}
+
+	depth := 0
+	for _, name := range strings.Split(p, "/") {
+		switch name {
+		case "", ".":
+		case "..":
+			depth--
+			if depth < 0 {
+				return &PathError{Op: op, Path: p, Problem: PathEscapesRoot}
+			}
+		default:
+			if len(name) > MaxNameLen {
+				return &PathError{Op: op, Path: p, Problem: PathNameTooLong}
+			}
+			depth++
+			if depth > opts.MaxDepth {
+				return &PathError{Op: op, Path: p, Problem: PathTooDeep}
+			}
+		}
+	}
+	return nil
+}
+
+// readOps are the cleanPath operations that never modify the filesystem
+// and so may see the reserved directory.
+var readOps = map[string]bool{
+	"stat": true, "lstat": true, "readdir": true, "read": true, "readlink": true,
+	"open": true, "find": true, "grep": true, "export": true, "langstats": true,
+	"getmeta": true, "meta": true, "findmeta": true, "summary": true, "cost": true,
+	"preview": true, "query": true, "history": true,
+}
+
+// coalescedOps are the cleanPath operations that use content held by write
+// coalescing rather than needing it written first.
+var coalescedOps = map[string]bool{"read": true, "write": true, "sync": true}
+
+// cleanPath validates a caller-supplied path unless the filesystem is
+// lenient, and returns it normalized. Operations other than readOps are
+// refused inside the reserved directory, also when p reaches it through
+// symlinks. Content held for the path by write coalescing is written first
+// unless the operation uses it.
+func (fs *Filesystem) cleanPath(ctx context.Context, op, p string) (string, error) {
+	if !fs.paths.Lenient {
+		if err := fs.paths.validate(op, p); err != nil {
+			return "", err
+		}
+	}
+	p = normalizePath(p)
+	if !readOps[op] {
+		if err := fs.checkWritable(ctx, op, p); err != nil {
+			return "", err
+		}
+	}
+	if !coalescedOps[op] {
+		fs.coalesce.syncPath(p)
+	}
+	return p, nil
+}
@@ -0,0 +1,82 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathValidation(t *testing.T) {
+	opts := PathOptions{MaxLength: 1024, MaxDepth: 4}
+	tests := []struct {
+		path string
+		want PathProblem
+	}{
+		{"/a/b/c", ""},
+		{"a/b", ""},
+		{"/a/../b", ""},
+		{"/a/./b/", ""},
+		{"/..", PathEscapesRoot},
+		{"/a/../../etc/passwd", PathEscapesRoot},
+		{"../x", PathEscapesRoot},
+		{"/a\x00b", PathHasNUL},
+		{"/" + strings.Repeat("n", MaxNameLen+1), PathNameTooLong},
+		{"/" + strings.Repeat("a/", 600), PathTooLong},
+		{"/a/b/c/d/e", PathTooDeep},
+		{"/a/b/c/d/../e", ""},
+	}
+	for _, tt := range tests {
+		err := opts.validate("stat", tt.path)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("validate(%q) = %v, want nil", tt.path, err)
+			}
+			continue
+		}
+		var pathErr *PathError
+		if !errors.As(err, &pathErr) || pathErr.Problem != tt.want {
+			t.Errorf("validate(%q) = %v, want %s", tt.path, err, tt.want)
+		}
+	}
+}
+
+func TestPathErrorCodes(t *testing.T) {
+	err := error(&PathError{Op: "open", Path: "/x", Problem: PathNameTooLong})
+	if !IsNameTooLong(err) {
+		t.Errorf("IsNameTooLong(%v) = false", err)
+	}
+	err = &PathError{Op: "open", Path: "/..", Problem: PathEscapesRoot}
+	if !errors.Is(err, ErrInval("", "", "")) {
+		t.Errorf("errors.Is(%v, EINVAL) = false", err)
+	}
+}
+
+func TestStrictAndLenientPaths(t *testing.T) {
+	ctx := context.Background()
+
+	strict := setupTestDB(t)
+	defer strict.Close()
+	if err := strict.FS.WriteFile(ctx, "/../escape.txt", []byte("x"), 0o644); !errors.Is(err, ErrInval("", "", "")) {
+		t.Errorf("strict WriteFile: err = %v, want EINVAL", err)
+	}
+	if _, err := strict.FS.Stat(ctx, "/a\x00b"); err == nil {
+		t.Error("strict Stat accepted a NUL byte")
+	}
+
+	lenient, err := Open(ctx, AgentFSOptions{
+		Path:  filepath.Join(t.TempDir(), "test.db"),
+		Paths: PathOptions{Lenient: true},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer lenient.Close()
+	if err := lenient.FS.WriteFile(ctx, "/../escape.txt", []byte("x"), 0o644); err != nil {
+		t.Fatalf("lenient WriteFile failed: %v", err)
+	}
+	if _, err := lenient.FS.Stat(ctx, "/escape.txt"); err != nil {
+		t.Errorf("lenient path was not cleaned to the root: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PinnedMetaKey is the metadata field that marks a file as pinned (see
+// Pin). Being metadata, the mark follows the file across renames and hard
+// links, is captured by snapshots, and goes away with the file.
+const PinnedMetaKey = "sys:pinned"
+
+// pinSet is a process's copy of the pinned inodes, refreshed like the
+// policy so pins made by other processes take effect.
+type pinSet struct {
+	mu     sync.Mutex
+	inos   map[int64]bool
+	loaded time.Time
+}
+
+// pinned reports whether the chunks of ino should stay in the chunk cache.
+// Without a cache there is nothing to keep, and no query is made.
+func (b *blobStore) pinned(ctx context.Context, db dbtx, ino int64) bool {
+	if b == nil || b.cache == nil {
+		return false
+	}
+	s := &b.pins
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded.IsZero() || time.Since(s.loaded) >= policyRefresh {
+		rows, err := db.QueryContext(ctx, pinnedInodes)
+		if err != nil {
+			return s.inos[ino] // Keep the previous copy
+		}
+		inos := make(map[int64]bool)
+		for rows.Next() {
+			var pinned int64
+			if rows.Scan(&pinned) == nil {
+				inos[pinned] = true
+			}
+		}
+		rows.Close()
+		s.inos, s.loaded = inos, time.Now()
+	}
+	return s.inos[ino]
+}
+
+// invalidate makes the next lookup read the pins again.
+func (s *pinSet) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaded = time.Time{}
+}
+
+// Pin marks the file at p as hot. Its chunks in external storage tiers are
+// loaded into the chunk cache (ExternalStorageOptions.CacheSize) now and
+// kept there, outside the cache's size limit, until Unpin; chunks rewritten
+// later are kept as they are read. Chunks stored in SQLite are served from
+// SQLite's own page cache and are unaffected. Replication and sync should
+// send the files listed by Pinned first.
+//
+// Use it for prompts, templates, and other files read on every agent step.
+//
+// Example:
+//
+//	if err := afs.FS.Pin(ctx, "/prompts/system.md"); err != nil {
+//	    return err
+//	}
+func (fs *Filesystem) Pin(ctx context.Context, p string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "pin", p)
+	if err != nil {
+		return err
+	}
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return err
+	}
+	stats, err := fs.statInode(ctx, ino)
+	if err != nil {
+		return err
+	}
+	if stats.IsDir() {
+		return ErrIsDir("pin", p)
+	}
+	if _, err := fs.db.ExecContext(ctx, metaSet, ino, PinnedMetaKey, strconv.FormatInt(fs.now().Unix(), 10)); err != nil {
+		return fmt.Errorf("failed to pin file: %w", err)
+	}
+	if fs.blobs == nil || fs.blobs.cache == nil {
+		return nil
+	}
+	fs.blobs.pins.invalidate()
+	if stats.Size == 0 {
+		return nil
+	}
+
+	// Warm the cache
+	chunkSize, err := fs.chunkSizeOf(ctx, ino)
+	if err != nil {
+		return err
+	}
+	last := (stats.Size - 1) / chunkSize
+	for start := int64(0); start <= last; start += snapshotPageSize {
+		if _, err := fs.readChunks(ctx, ino, start, min(start+snapshotPageSize-1, last)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unpin removes the mark set by Pin. The file's cached chunks become
+// ordinary cache entries, evicted as the cache fills.
+func (fs *Filesystem) Unpin(ctx context.Context, p string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "pin", p)
+	if err != nil {
+		return err
+	}
+	ino, err := fs.resolvePathFollow(ctx, p, true)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.db.ExecContext(ctx, metaDelete, ino, PinnedMetaKey); err != nil {
+		return fmt.Errorf("failed to unpin file: %w", err)
+	}
+	if fs.blobs == nil || fs.blobs.cache == nil {
+		return nil
+	}
+
+	fs.blobs.pins.invalidate()
+	rows, err := fs.db.QueryContext(ctx, extChunkKeysByIno, ino)
+	if err != nil {
+		return fmt.Errorf("failed to unpin file: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return fmt.Errorf("failed to unpin file: %w", err)
+		}
+		fs.blobs.cache.Unpin(key)
+	}
+	return rows.Err()
+}
+
+// Pinned lists the paths of pinned files, sorted.
+func (fs *Filesystem) Pinned(ctx context.Context) ([]string, error) {
+	return fs.FindByMeta(ctx, MetaQuery{Key: PinnedMetaKey})
+}
@@ -0,0 +1,63 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:      filepath.Join(dir, "test.db"),
+		ChunkSize: 1024,
+		External:  ExternalStorageOptions{Threshold: 1024, CacheSize: 2048},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	prompt := bytes.Repeat([]byte("p"), 4096) // Twice the cache size
+	afs.FS.WriteFile(ctx, "/prompts/system.md", prompt, 0o644)
+	other := make([]byte, 4096) // Four distinct chunks
+	for i := range other {
+		other[i] = byte(i % 251)
+	}
+	afs.FS.WriteFile(ctx, "/data/other.bin", other, 0o644)
+
+	if err := afs.FS.Pin(ctx, "/prompts/system.md"); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+	var fsErr *FSError
+	if err := afs.FS.Pin(ctx, "/prompts"); !errors.As(err, &fsErr) || fsErr.Code != EISDIR {
+		t.Errorf("Pin(dir) = %v, want EISDIR", err)
+	}
+
+	// Reading other files churns the cache without evicting the pinned file
+	afs.FS.ReadFile(ctx, "/data/other.bin")
+	key := chunkHash(prompt[:1024])
+	if _, ok := afs.FS.blobs.cache.Get(key); !ok {
+		t.Error("pinned chunk was evicted")
+	}
+
+	// The pin follows the file
+	afs.FS.Rename(ctx, "/prompts/system.md", "/prompts/main.md")
+	if paths, err := afs.FS.Pinned(ctx); err != nil || len(paths) != 1 || paths[0] != "/prompts/main.md" {
+		t.Errorf("Pinned = %v, %v", paths, err)
+	}
+
+	if err := afs.FS.Unpin(ctx, "/prompts/main.md"); err != nil {
+		t.Fatalf("Unpin failed: %v", err)
+	}
+	afs.FS.ReadFile(ctx, "/data/other.bin")
+	if _, ok := afs.FS.blobs.cache.Get(key); ok {
+		t.Error("unpinned chunk stayed cached")
+	}
+	if paths, _ := afs.FS.Pinned(ctx); len(paths) != 0 {
+		t.Errorf("Pinned after Unpin = %v", paths)
+	}
+}
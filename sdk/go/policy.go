@@ -0,0 +1,371 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultEnforceInterval is how often retention rules are applied unless
+// Policy.EnforceInterval is set.
+const DefaultEnforceInterval = time.Hour
+
+// DefaultQuotaWarnings are the fractions of Policy.MaxBytes at which
+// EventQuotaWarning is emitted unless Policy.QuotaWarnings is set.
+var DefaultQuotaWarnings = []float64{0.8, 0.9, 1.0}
+
+// policyRefresh is how long a process trusts its copy of the policy before
+// reading it again, so changes made by other processes take effect.
+const policyRefresh = 5 * time.Second
+
+// Policy holds the quota, retention, and pruning rules of an agent
+// database. It is stored in the database (see SetPolicy), so every process
+// that opens it enforces the same rules. Zero fields are disabled.
+type Policy struct {
+	// MaxBytes caps the total size of all files. A write that would take
+	// the total above it fails with ENOSPC (see IsNoSpace); shrinking and
+	// removing files always succeed.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+
+	// QuotaWarnings are the fractions of MaxBytes, each in (0, 1], at which
+	// a write that takes usage across emits EventQuotaWarning (default:
+	// DefaultQuotaWarnings).
+	QuotaWarnings []float64 `json:"quota_warnings,omitempty"`
+
+	// ToolCallRetention removes completed tool calls, with their
+	// annotations, file links, and usage, once they are older than this.
+	ToolCallRetention time.Duration `json:"tool_call_retention,omitempty"`
+
+	// ToolCallArchiveAfter moves tool calls started longer ago than this
+	// into monthly archive tables (see ToolCalls.Archive).
+	ToolCallArchiveAfter time.Duration `json:"tool_call_archive_after,omitempty"`
+
+	// MessageRetention removes session messages older than this.
+	MessageRetention time.Duration `json:"message_retention,omitempty"`
+
+	// BlobPruneAge prunes unreferenced chunks in external storage tiers
+	// that are older than this (see PruneBlobs).
+	BlobPruneAge time.Duration `json:"blob_prune_age,omitempty"`
+
+	// KeepVersions is how many earlier versions of each path are kept when
+	// files are overwritten (see Filesystem.History).
+	KeepVersions int `json:"keep_versions,omitempty"`
+
+	// EnforceInterval is how often each process applies the retention and
+	// pruning rules (default: DefaultEnforceInterval).
+	EnforceInterval time.Duration `json:"enforce_interval,omitempty"`
+}
+
+// retains reports whether p has any rule applied by EnforcePolicy.
+func (p Policy) retains() bool {
+	return p.ToolCallRetention > 0 || p.ToolCallArchiveAfter > 0 || p.MessageRetention > 0 || p.BlobPruneAge > 0
+}
+
+// quotaWarnings returns the warning thresholds in effect.
+func (p Policy) quotaWarnings() []float64 {
+	if len(p.QuotaWarnings) == 0 {
+		return DefaultQuotaWarnings
+	}
+	return p.QuotaWarnings
+}
+
+// QuotaWarning describes the quota threshold an EventQuotaWarning reports.
+type QuotaWarning struct {
+	Threshold float64 `json:"threshold"` // Fraction of Limit crossed
+	Used      int64   `json:"used"`      // Total file size after the write
+	Limit     int64   `json:"limit"`     // Policy.MaxBytes
+
+	// Subtree is the top-level directory (or root file) that grew the most
+	// in this process since the previous warning, and Growth by how many
+	// bytes, so an orchestrator can tell which task is filling the disk.
+	Subtree string `json:"subtree,omitempty"`
+	Growth  int64  `json:"growth,omitempty"`
+}
+
+// PolicyReport counts what EnforcePolicy removed.
+type PolicyReport struct {
+	ToolCalls         int64 `json:"tool_calls"`
+	ArchivedToolCalls int64 `json:"archived_tool_calls"`
+	Messages          int64 `json:"messages"`
+	Blobs             int   `json:"blobs"`
+}
+
+// policyCache is a process's copy of the stored policy, with the growth
+// of each top-level subtree since the last quota warning.
+type policyCache struct {
+	mu     sync.Mutex
+	policy Policy
+	loaded time.Time
+	growth map[string]int64
+}
+
+// get returns the policy, reading it from db if the copy is stale.
+func (c *policyCache) get(ctx context.Context, db dbtx) (Policy, error) {
+	if c == nil {
+		return Policy{}, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded.IsZero() && time.Since(c.loaded) < policyRefresh {
+		return c.policy, nil
+	}
+	p, err := loadPolicy(ctx, db)
+	if err != nil {
+		return Policy{}, err
+	}
+	c.policy, c.loaded = p, time.Now()
+	return p, nil
+}
+
+// set replaces the copy after the policy is stored.
+func (c *policyCache) set(p Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy, c.loaded = p, time.Now()
+}
+
+// grew records that the subtree containing p grew by n bytes.
+func (c *policyCache) grew(p string, n int64) {
+	subtree := "/" + strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)[0]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.growth == nil {
+		c.growth = make(map[string]int64)
+	}
+	c.growth[subtree] += n
+}
+
+// fastestGrowing returns the subtree that grew the most since the last
+// call, and by how much, and starts counting again.
+func (c *policyCache) fastestGrowing() (string, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var subtree string
+	var most int64
+	for s, n := range c.growth {
+		if n > most || (n == most && s < subtree) {
+			subtree, most = s, n
+		}
+	}
+	c.growth = nil
+	return subtree, most
+}
+
+// loadPolicy reads the stored policy; a database without one has the zero
+// Policy.
+func loadPolicy(ctx context.Context, db dbtx) (Policy, error) {
+	var p Policy
+	var data string
+	err := db.QueryRowContext(ctx, policyGet).Scan(&data)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("failed to read policy: %w", err)
+	}
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return p, fmt.Errorf("invalid policy: %w", err)
+	}
+	return p, nil
+}
+
+// SetPolicy stores p in the database, replacing the previous policy. It
+// takes effect at once in this process, and within a few seconds in other
+// processes that have the database open.
+//
+// Example:
+//
+//	err := afs.SetPolicy(ctx, agentfs.Policy{
+//	    MaxBytes:          10 << 30,
+//	    ToolCallRetention: 30 * 24 * time.Hour,
+//	})
+func (a *AgentFS) SetPolicy(ctx context.Context, p Policy) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if p.MaxBytes < 0 || p.ToolCallRetention < 0 || p.ToolCallArchiveAfter < 0 || p.MessageRetention < 0 || p.BlobPruneAge < 0 || p.KeepVersions < 0 || p.EnforceInterval < 0 {
+		return fmt.Errorf("policy limits must not be negative")
+	}
+	for _, t := range p.QuotaWarnings {
+		if t <= 0 || t > 1 {
+			return fmt.Errorf("quota warning threshold %v must be in (0, 1]", t)
+		}
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if _, err := a.db.ExecContext(ctx, policySet, string(data)); err != nil {
+		return fmt.Errorf("failed to store policy: %w", err)
+	}
+	a.policy.set(p)
+	return nil
+}
+
+// GetPolicy returns the policy stored in the database, or the zero Policy
+// if none was set.
+func (a *AgentFS) GetPolicy(ctx context.Context) (Policy, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return Policy{}, err
+	}
+	defer done()
+
+	p, err := loadPolicy(ctx, a.db)
+	if err != nil {
+		return Policy{}, err
+	}
+	a.policy.set(p)
+	return p, nil
+}
+
+// EnforcePolicy applies the retention and pruning rules of the stored
+// policy now. Every process does this in the background every
+// Policy.EnforceInterval; call it directly to reclaim space at once.
+func (a *AgentFS) EnforcePolicy(ctx context.Context) (*PolicyReport, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err := a.policy.get(ctx, a.db)
+	if err != nil {
+		return nil, err
+	}
+	report := &PolicyReport{}
+	now := a.FS.now()
+
+	if p.ToolCallRetention > 0 {
+		cutoff := now.Add(-p.ToolCallRetention).Unix()
+		err := a.FS.inTx(ctx, func(tfs *Filesystem) error {
+			for _, q := range []string{toolCallAnnotationsExpire, toolCallFilesExpire, toolCallUsageExpire, toolCallSpansExpire, toolCallRetriesExpire, toolCallOutputExpire} {
+				if _, err := tfs.db.ExecContext(ctx, q, cutoff); err != nil {
+					return err
+				}
+			}
+			res, err := tfs.db.ExecContext(ctx, toolCallsExpire, cutoff)
+			if err != nil {
+				return err
+			}
+			report.ToolCalls, _ = res.RowsAffected()
+			archived, err := expireArchives(ctx, tfs.db, cutoff)
+			report.ToolCalls += archived
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to expire tool calls: %w", err)
+		}
+	}
+
+	if p.ToolCallArchiveAfter > 0 {
+		cutoff := now.Add(-p.ToolCallArchiveAfter).Unix()
+		err := a.FS.inTx(ctx, func(tfs *Filesystem) error {
+			var err error
+			report.ArchivedToolCalls, err = archiveToolCalls(ctx, tfs.db, cutoff)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive tool calls: %w", err)
+		}
+	}
+
+	if p.MessageRetention > 0 {
+		res, err := a.db.ExecContext(ctx, messagesExpire, now.Add(-p.MessageRetention).Unix())
+		if err != nil {
+			return nil, fmt.Errorf("failed to expire messages: %w", err)
+		}
+		report.Messages, _ = res.RowsAffected()
+	}
+
+	if p.BlobPruneAge > 0 {
+		if report.Blobs, err = a.PruneBlobs(ctx, &PruneOptions{MinAge: p.BlobPruneAge}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Best effort: large deletions skew the planner statistics
+	if report.ToolCalls > 0 || report.ArchivedToolCalls > 0 || report.Messages > 0 || report.Blobs > 0 {
+		a.FS.optimizer.run(ctx)
+	}
+	return report, nil
+}
+
+// startPolicyEnforcer applies the stored retention rules in the background.
+// The policy is re-read on every tick, so rules set later, or by another
+// process, are picked up without reopening.
+func (a *AgentFS) startPolicyEnforcer() {
+	a.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		var last time.Time
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			p, err := a.policy.get(context.Background(), a.db)
+			if err != nil || !p.retains() {
+				continue
+			}
+			interval := p.EnforceInterval
+			if interval <= 0 {
+				interval = DefaultEnforceInterval
+			}
+			if time.Since(last) < interval {
+				continue
+			}
+			// Errors are retried on the next interval
+			a.EnforcePolicy(context.Background())
+			last = time.Now()
+		}
+	})
+}
+
+// checkQuota fails with ENOSPC if growing the file ino (0 for a new file)
+// to size bytes would take the total file size above Policy.MaxBytes.
+// Otherwise it records the growth and emits EventQuotaWarning for the
+// highest threshold the write takes usage across.
+func (fs *Filesystem) checkQuota(ctx context.Context, op, p string, ino, size int64) error {
+	policy, err := fs.policy.get(ctx, fs.db)
+	if err != nil || policy.MaxBytes <= 0 {
+		return err
+	}
+	var total, current int64
+	if err := fs.db.QueryRowContext(ctx, quotaUsage, ino).Scan(&total, &current); err != nil {
+		return fmt.Errorf("failed to check quota: %w", err)
+	}
+	if size <= current {
+		return nil
+	}
+	used := total - current + size
+	if used > policy.MaxBytes {
+		return ErrNoSpace(op, p, fmt.Sprintf("quota of %d bytes exceeded", policy.MaxBytes))
+	}
+	fs.policy.grew(p, size-current)
+
+	var crossed float64
+	for _, t := range policy.quotaWarnings() {
+		mark := int64(t * float64(policy.MaxBytes))
+		if total < mark && used >= mark {
+			crossed = max(crossed, t)
+		}
+	}
+	if crossed > 0 {
+		subtree, growth := fs.policy.fastestGrowing()
+		fs.emitEvent(Event{Kind: EventQuotaWarning, Path: p, Time: fs.now(), Quota: &QuotaWarning{
+			Threshold: crossed, Used: used, Limit: policy.MaxBytes, Subtree: subtree, Growth: growth,
+		}})
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicy_SharedAcrossProcesses(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+
+	a, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer a.Close()
+
+	if p, err := a.GetPolicy(ctx); err != nil || !reflect.DeepEqual(p, Policy{}) {
+		t.Fatalf("GetPolicy = %+v, %v; want zero policy", p, err)
+	}
+	want := Policy{MaxBytes: 100, ToolCallRetention: 24 * time.Hour}
+	if err := a.SetPolicy(ctx, want); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+
+	b, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer b.Close()
+	if got, err := b.GetPolicy(ctx); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetPolicy = %+v, %v; want %+v", got, err, want)
+	}
+
+	// The quota set through a is enforced by b
+	if err := b.FS.WriteFile(ctx, "/a.bin", make([]byte, 60), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	err = b.FS.WriteFile(ctx, "/b.bin", make([]byte, 60), 0o644)
+	if !IsNoSpace(err) {
+		t.Fatalf("WriteFile over quota = %v, want ENOSPC", err)
+	}
+	if _, err := b.FS.Stat(ctx, "/b.bin"); !IsNotExist(err) {
+		t.Errorf("rejected file was created: %v", err)
+	}
+
+	// Overwriting with less data frees space
+	if err := b.FS.WriteFile(ctx, "/a.bin", make([]byte, 10), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := b.FS.WriteFile(ctx, "/b.bin", make([]byte, 60), 0o644); err != nil {
+		t.Fatalf("WriteFile within quota failed: %v", err)
+	}
+
+	f, err := b.FS.Open(ctx, "/b.bin", O_RDWR)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Pwrite(ctx, make([]byte, 40), 60); !IsNoSpace(err) {
+		t.Errorf("Pwrite over quota = %v, want ENOSPC", err)
+	}
+
+	if err := a.SetPolicy(ctx, Policy{MaxBytes: -1}); err == nil {
+		t.Error("expected negative limits to be rejected")
+	}
+}
+
+func TestEnforcePolicy_Retention(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Unix(1_700_000_000, 0))
+	afs, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "agent.db"), Clock: clock})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	now := clock.Now().Unix()
+	old, err := afs.Tools.Record(ctx, "search", nil, "old", nil, now-3*86400, now-3*86400)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	afs.Tools.Annotate(ctx, old.ID, "label", "x")
+	recent, _ := afs.Tools.Record(ctx, "search", nil, "recent", nil, now-60, now-60)
+	afs.AddMessage(ctx, "s1", "user", "hello")
+
+	if err := afs.SetPolicy(ctx, Policy{ToolCallRetention: 24 * time.Hour, MessageRetention: time.Hour}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+
+	report, err := afs.EnforcePolicy(ctx)
+	if err != nil {
+		t.Fatalf("EnforcePolicy failed: %v", err)
+	}
+	if report.ToolCalls != 1 || report.Messages != 1 {
+		t.Errorf("report = %+v, want 1 tool call and 1 message", report)
+	}
+	if _, err := afs.Tools.Get(ctx, old.ID); err == nil {
+		t.Error("expired tool call still present")
+	}
+	if _, err := afs.Tools.Get(ctx, recent.ID); err != nil {
+		t.Errorf("recent tool call removed: %v", err)
+	}
+}
+
+func TestQuotaWarnings(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.SetPolicy(ctx, Policy{MaxBytes: 1000}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	events, cancel := afs.Subscribe(EventFilter{Kinds: []EventKind{EventQuotaWarning}}, 0)
+	defer cancel()
+
+	afs.FS.WriteFile(ctx, "/logs/a.log", make([]byte, 100), 0o644)
+	afs.FS.WriteFile(ctx, "/runs/1/out.bin", make([]byte, 300), 0o644)
+	afs.FS.WriteFile(ctx, "/runs/2/out.bin", make([]byte, 450), 0o644) // 850: crosses 80%
+	afs.FS.WriteFile(ctx, "/logs/b.log", make([]byte, 20), 0o644)      // 870: no threshold
+	afs.FS.WriteFile(ctx, "/runs/3/out.bin", make([]byte, 130), 0o644) // 1000: crosses 90% and 100%
+
+	want := []QuotaWarning{
+		{Threshold: 0.8, Used: 850, Limit: 1000, Subtree: "/runs", Growth: 750},
+		{Threshold: 1.0, Used: 1000, Limit: 1000, Subtree: "/runs", Growth: 130},
+	}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e.Quota == nil || *e.Quota != w {
+				t.Errorf("warning = %+v, want %+v", e.Quota, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no warning for threshold %v", w.Threshold)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected warning %+v", e.Quota)
+	default:
+	}
+
+	if err := afs.SetPolicy(ctx, Policy{MaxBytes: 1000, QuotaWarnings: []float64{1.5}}); err == nil {
+		t.Error("expected threshold above 1 to be rejected")
+	}
+}
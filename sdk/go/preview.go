@@ -0,0 +1,287 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	// Decoders for the built-in image previewer
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// DefaultPreviewSize is the preview size used when Preview is given 0.
+const DefaultPreviewSize = 256
+
+// MaxPreviewSize is the largest preview size Preview accepts.
+const MaxPreviewSize = 4096
+
+// ErrNoPreview is returned by Preview for content types without a
+// registered Previewer.
+var ErrNoPreview = errors.New("agentfs: no previewer for content type")
+
+// Preview is a rendered preview of a file: a thumbnail image or a text
+// excerpt.
+type Preview struct {
+	// ContentType is the MIME type of Data, e.g. "image/png" or
+	// "text/plain; charset=utf-8".
+	ContentType string `json:"content_type"`
+	// SourceType is the detected MIME type of the file.
+	SourceType string `json:"source_type"`
+	Data       []byte `json:"data"`
+}
+
+// Previewer renders previews of one kind of content. size bounds the
+// preview: the longer side in pixels for images, the length in bytes for
+// text.
+type Previewer interface {
+	Preview(ctx context.Context, data []byte, size int) (*Preview, error)
+}
+
+// PreviewerFunc adapts a function to the Previewer interface.
+type PreviewerFunc func(ctx context.Context, data []byte, size int) (*Preview, error)
+
+// Preview calls f.
+func (f PreviewerFunc) Preview(ctx context.Context, data []byte, size int) (*Preview, error) {
+	return f(ctx, data, size)
+}
+
+// previewers maps content types to Previewers. Keys are full types such as
+// "application/pdf" or wildcards such as "image/*".
+type previewers struct {
+	mu sync.RWMutex
+	m  map[string]Previewer
+}
+
+// newPreviewers returns the built-in previewers for images, text, and
+// Jupyter notebooks.
+func newPreviewers() *previewers {
+	return &previewers{m: map[string]Previewer{
+		"image/*":          PreviewerFunc(previewImage),
+		"text/*":           PreviewerFunc(previewText),
+		notebookType:       PreviewerFunc(previewNotebook),
+		"application/json": PreviewerFunc(previewText),
+	}}
+}
+
+// lookup returns the Previewer for contentType, preferring an exact match
+// over a wildcard.
+func (r *previewers) lookup(contentType string) Previewer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.m[contentType]; ok {
+		return p
+	}
+	if i := strings.IndexByte(contentType, '/'); i > 0 {
+		return r.m[contentType[:i]+"/*"]
+	}
+	return nil
+}
+
+// notebookType is the content type detected for .ipynb files.
+const notebookType = "application/x-ipynb+json"
+
+// RegisterPreviewer sets the Previewer used for files of contentType, which
+// may be a wildcard such as "video/*". It replaces any earlier Previewer for
+// contentType, including the built-in ones for "image/*", "text/*",
+// "application/json", and notebooks. A nil p removes the registration.
+// There is no built-in PDF previewer; register one that shells out to a
+// renderer such as pdftoppm.
+func (fs *Filesystem) RegisterPreviewer(contentType string, p Previewer) {
+	fs.previews.mu.Lock()
+	defer fs.previews.mu.Unlock()
+	if p == nil {
+		delete(fs.previews.m, contentType)
+		return
+	}
+	fs.previews.m[contentType] = p
+}
+
+// Preview returns a preview of the file at p no larger than size (see
+// Previewer; 0 means DefaultPreviewSize). The content type is detected from
+// the extension, then the content. Previews are cached in the database
+// until the file changes. Files without a matching Previewer fail with
+// ErrNoPreview.
+//
+// Example:
+//
+//	thumb, err := afs.FS.Preview(ctx, "/charts/revenue.png", 128)
+//	os.WriteFile("thumb.png", thumb.Data, 0o644)
+func (fs *Filesystem) Preview(ctx context.Context, p string, size int) (*Preview, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "preview", p)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		size = DefaultPreviewSize
+	}
+	if size < 0 || size > MaxPreviewSize {
+		return nil, ErrInval("preview", p, fmt.Sprintf("size must be between 1 and %d", MaxPreviewSize))
+	}
+
+	stats, err := fs.Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if !stats.IsRegularFile() {
+		return nil, ErrInval("preview", p, "not a regular file")
+	}
+
+	pv := &Preview{}
+	err = fs.db.QueryRowContext(ctx, previewGet, stats.Ino, size, stats.Mtime, stats.MtimeNsec, stats.Size).
+		Scan(&pv.ContentType, &pv.SourceType, &pv.Data)
+	if err == nil {
+		return pv, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read preview cache: %w", err)
+	}
+
+	data, err := fs.ReadFile(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	sourceType := detectContentType(p, data)
+	previewer := fs.previews.lookup(sourceType)
+	if previewer == nil {
+		return nil, fmt.Errorf("%w %s: %s", ErrNoPreview, sourceType, p)
+	}
+	if pv, err = previewer.Preview(ctx, data, size); err != nil {
+		return nil, fmt.Errorf("failed to preview %s: %w", p, err)
+	}
+	pv.SourceType = sourceType
+
+	// A failed cache write only costs a re-render next time
+	fs.db.ExecContext(ctx, previewPut, stats.Ino, size, stats.Mtime, stats.MtimeNsec, stats.Size, pv.ContentType, pv.SourceType, pv.Data)
+	return pv, nil
+}
+
+// detectContentType returns the MIME type of the file at p, without
+// parameters.
+func detectContentType(p string, data []byte) string {
+	ext := strings.ToLower(path.Ext(p))
+	if ext == ".ipynb" {
+		return notebookType
+	}
+	t := mime.TypeByExtension(ext)
+	if t == "" {
+		t = http.DetectContentType(data)
+	}
+	if mt, _, err := mime.ParseMediaType(t); err == nil {
+		return mt
+	}
+	return t
+}
+
+// previewImage scales an image to fit a size×size box and encodes it as PNG.
+func previewImage(ctx context.Context, data []byte, size int) (*Preview, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(h*size/w, 1)
+		} else {
+			w, h = max(w*size/h, 1), size
+		}
+	}
+
+	// Nearest-neighbour sampling is enough for a thumbnail
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/w, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return &Preview{ContentType: "image/png", Data: buf.Bytes()}, nil
+}
+
+// previewText keeps the leading lines of a text file that fit in size bytes.
+func previewText(ctx context.Context, data []byte, size int) (*Preview, error) {
+	return &Preview{ContentType: "text/plain; charset=utf-8", Data: truncateText(data, size)}, nil
+}
+
+// truncateText cuts data to at most size bytes, at the last line break if
+// there is one and otherwise at a rune boundary.
+func truncateText(data []byte, size int) []byte {
+	if len(data) <= size {
+		return data
+	}
+	data = data[:size]
+	if i := bytes.LastIndexByte(data, '\n'); i > 0 {
+		return data[:i+1]
+	}
+	for len(data) > 0 && !utf8.Valid(data) {
+		data = data[:len(data)-1]
+	}
+	return data
+}
+
+// previewNotebook renders the cells of a Jupyter notebook as Markdown, with
+// code cells fenced, and keeps what fits in size bytes.
+func previewNotebook(ctx context.Context, data []byte, size int) (*Preview, error) {
+	var nb struct {
+		Metadata struct {
+			LanguageInfo struct {
+				Name string `json:"name"`
+			} `json:"language_info"`
+		} `json:"metadata"`
+		Cells []struct {
+			CellType string          `json:"cell_type"`
+			Source   json.RawMessage `json:"source"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+
+	var b bytes.Buffer
+	for i, cell := range nb.Cells {
+		// Sources are a string or a list of lines
+		var source string
+		var lines []string
+		if err := json.Unmarshal(cell.Source, &lines); err == nil {
+			source = strings.Join(lines, "")
+		} else {
+			json.Unmarshal(cell.Source, &source)
+		}
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if cell.CellType == "code" {
+			fmt.Fprintf(&b, "```%s\n%s\n```\n", nb.Metadata.LanguageInfo.Name, strings.TrimRight(source, "\n"))
+		} else {
+			b.WriteString(strings.TrimRight(source, "\n") + "\n")
+		}
+		if b.Len() > size {
+			break
+		}
+	}
+	return &Preview{ContentType: "text/markdown; charset=utf-8", Data: truncateText(b.Bytes(), size)}, nil
+}
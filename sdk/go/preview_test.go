@@ -0,0 +1,93 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	t.Run("image thumbnail", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+		for x := 0; x < 400; x++ {
+			img.Set(x, 10, color.RGBA{R: 255, A: 255})
+		}
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		fs.WriteFile(ctx, "/chart.png", buf.Bytes(), 0o644)
+
+		pv, err := fs.Preview(ctx, "/chart.png", 100)
+		if err != nil {
+			t.Fatalf("Preview failed: %v", err)
+		}
+		thumb, err := png.Decode(bytes.NewReader(pv.Data))
+		if err != nil {
+			t.Fatalf("thumbnail is not a PNG: %v", err)
+		}
+		if b := thumb.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+			t.Errorf("thumbnail is %dx%d, want 100x50", b.Dx(), b.Dy())
+		}
+		if pv.ContentType != "image/png" || pv.SourceType != "image/png" {
+			t.Errorf("types = %q, %q", pv.ContentType, pv.SourceType)
+		}
+	})
+
+	t.Run("text cached until changed", func(t *testing.T) {
+		fs.WriteFile(ctx, "/notes.txt", []byte("line one\nline two\nline three\n"), 0o644)
+		pv, err := fs.Preview(ctx, "/notes.txt", 20)
+		if err != nil {
+			t.Fatalf("Preview failed: %v", err)
+		}
+		if string(pv.Data) != "line one\nline two\n" {
+			t.Errorf("text preview = %q", pv.Data)
+		}
+
+		calls := 0
+		fs.RegisterPreviewer("text/*", PreviewerFunc(func(ctx context.Context, data []byte, size int) (*Preview, error) {
+			calls++
+			return &Preview{ContentType: "text/plain", Data: []byte("custom")}, nil
+		}))
+		defer fs.RegisterPreviewer("text/*", PreviewerFunc(previewText))
+
+		fs.Preview(ctx, "/notes.txt", 20)
+		if calls != 0 {
+			t.Error("cached preview was rendered again")
+		}
+		fs.WriteFile(ctx, "/notes.txt", []byte("rewritten, and longer than before\n"), 0o644)
+		pv, _ = fs.Preview(ctx, "/notes.txt", 20)
+		if calls != 1 || string(pv.Data) != "custom" {
+			t.Errorf("after change: %d renders, preview %q", calls, pv.Data)
+		}
+	})
+
+	t.Run("notebook", func(t *testing.T) {
+		nb := `{"metadata": {"language_info": {"name": "python"}}, "cells": [
+			{"cell_type": "markdown", "source": ["# Analysis\n", "Intro"]},
+			{"cell_type": "code", "source": "print(1)"}]}`
+		fs.WriteFile(ctx, "/analysis.ipynb", []byte(nb), 0o644)
+		pv, err := fs.Preview(ctx, "/analysis.ipynb", 0)
+		if err != nil {
+			t.Fatalf("Preview failed: %v", err)
+		}
+		if !strings.Contains(string(pv.Data), "# Analysis\nIntro\n") || !strings.Contains(string(pv.Data), "```python\nprint(1)\n```") {
+			t.Errorf("notebook preview = %q", pv.Data)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		fs.WriteFile(ctx, "/report.pdf", []byte("%PDF-1.4"), 0o644)
+		if _, err := fs.Preview(ctx, "/report.pdf", 0); !errors.Is(err, ErrNoPreview) {
+			t.Errorf("Preview(pdf) = %v, want ErrNoPreview", err)
+		}
+	})
+}
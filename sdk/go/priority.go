@@ -0,0 +1,150 @@
+package agentfs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority classifies an operation for write scheduling.
+type Priority int
+
+const (
+	// PriorityForeground is for the agent's own reads and writes (default).
+	PriorityForeground Priority = iota
+
+	// PriorityBackground is for maintenance and bulk work such as imports.
+	// Background transactions are scheduled after queued foreground ones and
+	// wait for in-flight foreground operations to finish, for at most
+	// BackgroundMaxDelay.
+	PriorityBackground
+)
+
+// BackgroundMaxDelay bounds how long a background transaction defers to
+// foreground operations, so background work cannot be starved indefinitely.
+const BackgroundMaxDelay = time.Second
+
+// String returns the priority name.
+func (p Priority) String() string {
+	switch p {
+	case PriorityForeground:
+		return "foreground"
+	case PriorityBackground:
+		return "background"
+	default:
+		return "unknown"
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context that runs the operations it is passed to
+// with priority p.
+//
+// Example:
+//
+//	bg := agentfs.WithPriority(ctx, agentfs.PriorityBackground)
+//	err := afs.FS.ImportDir(bg, "./repo", "/workspace", nil)
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityFrom returns the priority of the operation running with ctx.
+func priorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityForeground
+}
+
+// writeGate serializes write transactions, granting queued foreground
+// transactions before background ones.
+type writeGate struct {
+	mu   sync.Mutex
+	held bool
+	fg   []chan struct{}
+	bg   []chan struct{}
+}
+
+// acquire waits until the caller may start a write transaction.
+func (g *writeGate) acquire(ctx context.Context, p Priority) error {
+	g.mu.Lock()
+	if !g.held && (p == PriorityForeground || len(g.fg) == 0) {
+		g.held = true
+		g.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	if p == PriorityForeground {
+		g.fg = append(g.fg, ch)
+	} else {
+		g.bg = append(g.bg, ch)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		if g.remove(ch) {
+			g.mu.Unlock()
+			return ctx.Err()
+		}
+		g.mu.Unlock()
+		// Granted concurrently with cancellation; pass it on
+		g.release()
+		return ctx.Err()
+	}
+}
+
+// remove drops ch from the wait queues and reports whether it was queued.
+// The caller must hold g.mu.
+func (g *writeGate) remove(ch chan struct{}) bool {
+	for _, q := range []*[]chan struct{}{&g.fg, &g.bg} {
+		for i, c := range *q {
+			if c == ch {
+				*q = append((*q)[:i], (*q)[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// release ends the current transaction and hands the gate to the next
+// waiter, foreground first.
+func (g *writeGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var next chan struct{}
+	switch {
+	case len(g.fg) > 0:
+		next, g.fg = g.fg[0], g.fg[1:]
+	case len(g.bg) > 0:
+		next, g.bg = g.bg[0], g.bg[1:]
+	default:
+		g.held = false
+		return
+	}
+	close(next)
+}
+
+// schedule waits until a transaction with the priority of ctx may run and
+// returns the function that ends it.
+func (fs *Filesystem) schedule(ctx context.Context) (func(), error) {
+	if fs.gate == nil {
+		return func() {}, nil
+	}
+	p := priorityFrom(ctx)
+	if p == PriorityBackground {
+		if err := fs.life.waitForegroundIdle(ctx, BackgroundMaxDelay); err != nil {
+			return nil, err
+		}
+	}
+	if err := fs.gate.acquire(ctx, p); err != nil {
+		return nil, err
+	}
+	return fs.gate.release, nil
+}
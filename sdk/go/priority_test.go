@@ -0,0 +1,131 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWriteGateForegroundFirst(t *testing.T) {
+	ctx := context.Background()
+	g := &writeGate{}
+
+	if err := g.acquire(ctx, PriorityBackground); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	order := make(chan Priority, 2)
+	waitQueued := func(fg, bg int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			g.mu.Lock()
+			ok := len(g.fg) == fg && len(g.bg) == bg
+			g.mu.Unlock()
+			if ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("waiter was not queued")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for _, p := range []Priority{PriorityBackground, PriorityForeground} {
+		p := p
+		go func() {
+			if err := g.acquire(ctx, p); err == nil {
+				order <- p
+				g.release()
+			}
+		}()
+		if p == PriorityBackground {
+			waitQueued(0, 1)
+		} else {
+			waitQueued(1, 1)
+		}
+	}
+
+	g.release()
+	if first := <-order; first != PriorityForeground {
+		t.Errorf("first grant = %v, want foreground", first)
+	}
+	if second := <-order; second != PriorityBackground {
+		t.Errorf("second grant = %v, want background", second)
+	}
+}
+
+func TestWriteGateCancel(t *testing.T) {
+	g := &writeGate{}
+	if err := g.acquire(context.Background(), PriorityForeground); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.acquire(ctx, PriorityBackground); err != context.DeadlineExceeded {
+		t.Fatalf("acquire = %v, want DeadlineExceeded", err)
+	}
+
+	g.release()
+	g.mu.Lock()
+	held := g.held
+	g.mu.Unlock()
+	if held {
+		t.Error("gate still held after cancelled waiter")
+	}
+}
+
+func TestBackgroundWaitsForForeground(t *testing.T) {
+	ctx := context.Background()
+	l := &lifecycle{}
+
+	_, done, err := l.begin(ctx)
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+
+	// Background operations are not counted
+	_, bgDone, err := l.begin(WithPriority(ctx, PriorityBackground))
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	bgDone()
+
+	waited := make(chan struct{})
+	go func() {
+		l.waitForegroundIdle(ctx, time.Minute)
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("background work did not wait for the foreground operation")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	done()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background work still waiting after the foreground operation ended")
+	}
+
+	t.Run("bounded delay", func(t *testing.T) {
+		_, done, _ := l.begin(ctx)
+		defer done()
+
+		start := time.Now()
+		if err := l.waitForegroundIdle(ctx, 10*time.Millisecond); err != nil {
+			t.Fatalf("waitForegroundIdle failed: %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("waited %v, want about 10ms", d)
+		}
+	})
+}
+
+func TestPriority_String(t *testing.T) {
+	if PriorityForeground.String() != "foreground" || PriorityBackground.String() != "background" {
+		t.Errorf("String() = %q, %q", PriorityForeground, PriorityBackground)
+	}
+}
@@ -0,0 +1,35 @@
+package agentfs
+
+import "context"
+
+// ProgressFunc receives progress reports from long-running operations such as
+// ImportDir, ExportDir, and PruneBlobs. Reports are made at points where the
+// operation can stop cleanly; returning a non-nil error stops it there, and
+// the operation returns that error.
+type ProgressFunc func(Progress) error
+
+// progressTracker accumulates the progress of one operation.
+type progressTracker struct {
+	fn ProgressFunc
+	p  Progress
+}
+
+func newProgressTracker(op string, fn ProgressFunc) *progressTracker {
+	return &progressTracker{fn: fn, p: Progress{Op: op}}
+}
+
+// add records items and bytes processed up to path and reports the total.
+// It is a cancellation checkpoint: it also fails once ctx is done.
+func (t *progressTracker) add(ctx context.Context, items, bytes int64, path string) error {
+	t.p.Items += items
+	t.p.Bytes += bytes
+	t.p.Path = path
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if t.fn == nil {
+		return nil
+	}
+	return t.fn(t.p)
+}
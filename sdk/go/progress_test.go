@@ -0,0 +1,124 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestExportProgress(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := afs.FS.WriteFile(ctx, fmt.Sprintf("/src/f%d.txt", i), []byte("12345"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	t.Run("reports", func(t *testing.T) {
+		var last Progress
+		err := afs.FS.ExportDir(ctx, "/src", t.TempDir(), &ExportOptions{
+			Progress: func(p Progress) error {
+				last = p
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("ExportDir failed: %v", err)
+		}
+		if last.Op != "export" || last.Items != 5 || last.Bytes != 25 || last.Path != "/src/f4.txt" {
+			t.Errorf("final progress = %+v", last)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		errStop := errors.New("stop")
+		out := t.TempDir()
+		err := afs.FS.ExportDir(ctx, "/src", out, &ExportOptions{
+			Progress: func(p Progress) error {
+				if p.Items == 2 {
+					return errStop
+				}
+				return nil
+			},
+		})
+		if !errors.Is(err, errStop) {
+			t.Fatalf("ExportDir err = %v, want errStop", err)
+		}
+		entries, _ := os.ReadDir(out)
+		if len(entries) != 2 {
+			t.Errorf("exported %d files before stopping, want 2", len(entries))
+		}
+	})
+}
+
+func TestImportProgressCancel(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	src := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("f%02d.txt", i)] = "x"
+	}
+	writeHostTree(t, src, files)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := afs.FS.ImportDir(ctx, src, "/dst", &ImportOptions{
+		BatchSize: 5,
+		Progress: func(p Progress) error {
+			if p.Items == 5 {
+				cancel()
+			}
+			return nil
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ImportDir err = %v, want context.Canceled", err)
+	}
+
+	// The first batch was committed before cancellation
+	entries, err := afs.FS.Readdir(context.Background(), "/dst")
+	if err != nil {
+		t.Fatalf("Readdir failed: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("imported %d files, want 5", len(entries))
+	}
+}
+
+func TestPruneProgress(t *testing.T) {
+	ctx := context.Background()
+	afs, _ := openExternalTestDB(t, 1)
+	defer afs.Close()
+
+	for i := 0; i < 3; i++ {
+		p := fmt.Sprintf("/f%d", i)
+		if err := afs.FS.WriteFile(ctx, p, []byte(p), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if err := afs.FS.Unlink(ctx, p); err != nil {
+			t.Fatalf("Unlink failed: %v", err)
+		}
+	}
+
+	var reports []Progress
+	n, err := afs.PruneBlobs(ctx, &PruneOptions{
+		Progress: func(p Progress) error {
+			reports = append(reports, p)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("PruneBlobs failed: %v", err)
+	}
+	if n != 3 || len(reports) != 3 || reports[2].Items != 3 || reports[2].Op != "prune" {
+		t.Errorf("removed = %d, reports = %+v", n, reports)
+	}
+}
@@ -0,0 +1,329 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+)
+
+// queryTable is the name under which QueryTable exposes the file.
+const queryTable = "data"
+
+// QueryTable loads the CSV, TSV, or JSON Lines file at p into a table named
+// "data" and runs the read-only SQL query over it, returning
+// one map per result row keyed by column name. The format is chosen by
+// extension (.csv, .tsv, .jsonl, .ndjson), ignoring a compression suffix
+// such as ".gz"; compressed files are decompressed (see OpenDecoded).
+//
+// CSV columns get the SQL types inferred by ReadCSV, with booleans stored
+// as 0 or 1. JSON Lines columns are the union of the records' top-level
+// keys in name order; nested objects and arrays are stored as JSON text, so
+// json_extract can reach into them.
+//
+// The table lives in a private in-memory database opened for the call, so
+// the query sees nothing of the AgentFS database. It must be a single
+// SELECT, WITH, or VALUES statement, and runs with PRAGMA query_only set.
+//
+// Example:
+//
+//	rows, err := afs.FS.QueryTable(ctx, "/data/sales.csv",
+//	    "SELECT region, sum(amount) AS total FROM data WHERE year = ? GROUP BY region", 2024)
+//	out, _ := json.Marshal(rows)
+func (fs *Filesystem) QueryTable(ctx context.Context, p, query string, args ...any) ([]map[string]any, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "query", p)
+	if err != nil {
+		return nil, err
+	}
+	var load func(ctx context.Context, db dbtx, p string) error
+	switch ext := strings.ToLower(path.Ext(trimCompressionExt(p))); ext {
+	case ".csv", ".tsv":
+		comma := ','
+		if ext == ".tsv" {
+			comma = '\t'
+		}
+		load = func(ctx context.Context, db dbtx, p string) error {
+			return fs.loadCSV(ctx, db, p, comma)
+		}
+	case ".jsonl", ".ndjson":
+		load = fs.loadJSONL
+	default:
+		return nil, ErrInval("query", p, "unsupported file type; expected .csv, .tsv, .jsonl, or .ndjson")
+	}
+	if err := checkReadQuery(query); err != nil {
+		return nil, ErrInval("query", p, err.Error())
+	}
+
+	// An in-memory database exists per connection, so the pool keeps one
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open query database: %w", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := load(ctx, db, p); err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, fmt.Errorf("failed to enter read-only mode: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", p, err)
+	}
+	defer rows.Close()
+	return scanMaps(rows)
+}
+
+// trimCompressionExt removes a trailing compression extension from p.
+func trimCompressionExt(p string) string {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".gz", ".bz2", ".zst", ".xz":
+		return strings.TrimSuffix(p, path.Ext(p))
+	}
+	return p
+}
+
+// checkReadQuery checks that query is one SELECT, WITH, or VALUES
+// statement. Anything after its first semicolon other than comments and
+// whitespace is an error, so no second statement can turn query_only off.
+func checkReadQuery(query string) error {
+	var words []string
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(query)
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(query)
+			}
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			// Doubled quotes escape themselves, so skipping to each
+			// closing quote in turn is enough
+			j := strings.IndexByte(query[i+1:], end)
+			if j < 0 {
+				return fmt.Errorf("unterminated quote in query")
+			}
+			i += j + 1
+			words = append(words, "")
+		case c == ';':
+			words = append(words, ";")
+		case isIdentByte(c):
+			j := i
+			for j < len(query) && isIdentByte(query[j]) {
+				j++
+			}
+			words = append(words, strings.ToUpper(query[i:j]))
+			i = j - 1
+		case c > ' ':
+			words = append(words, string(c))
+		}
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("empty query")
+	}
+	switch words[0] {
+	case "SELECT", "WITH", "VALUES":
+	default:
+		return fmt.Errorf("only SELECT, WITH, and VALUES queries are allowed")
+	}
+	for i, w := range words {
+		if w != ";" {
+			continue
+		}
+		for _, rest := range words[i+1:] {
+			if rest != ";" {
+				return fmt.Errorf("only one statement is allowed")
+			}
+		}
+		break
+	}
+	return nil
+}
+
+// isIdentByte reports whether c can be part of an SQL keyword or name.
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// loadCSV creates the query table from the CSV file at p.
+func (fs *Filesystem) loadCSV(ctx context.Context, db dbtx, p string, comma rune) error {
+	r, err := fs.ReadCSV(ctx, p, &CSVOptions{Comma: comma})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	names := make([]string, len(r.Columns))
+	defs := make([]string, len(r.Columns))
+	for i, col := range r.Columns {
+		names[i] = col.Name
+		defs[i] = quoteIdent(col.Name) + " " + sqlColumnType(col.Type)
+	}
+	insert, err := createQueryTable(ctx, db, names, defs)
+	if err != nil {
+		return err
+	}
+	for r.Next() {
+		if _, err := db.ExecContext(ctx, insert, r.Values()...); err != nil {
+			return fmt.Errorf("failed to load %s: %w", p, err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return fmt.Errorf("failed to read CSV %s: %w", p, err)
+	}
+	return nil
+}
+
+// loadJSONL creates the query table from the JSON Lines file at p. The file
+// is read twice: once to collect the columns, once to insert the records.
+func (fs *Filesystem) loadJSONL(ctx context.Context, db dbtx, p string) error {
+	seen := map[string]bool{}
+	r, err := ReadJSONL[map[string]json.RawMessage](ctx, fs, p, nil)
+	if err != nil {
+		return err
+	}
+	for r.Next() {
+		for k := range r.Value() {
+			seen[k] = true
+		}
+	}
+	r.Close()
+	if err := r.Err(); err != nil {
+		return fmt.Errorf("failed to read JSONL %s: %w", p, err)
+	}
+
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	defs := make([]string, len(names))
+	for i, name := range names {
+		defs[i] = quoteIdent(name)
+	}
+	insert, err := createQueryTable(ctx, db, names, defs)
+	if err != nil {
+		return err
+	}
+
+	r, err = ReadJSONL[map[string]json.RawMessage](ctx, fs, p, nil)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	values := make([]any, len(names))
+	for r.Next() {
+		rec := r.Value()
+		for i, name := range names {
+			values[i] = jsonColumnValue(rec[name])
+		}
+		if _, err := db.ExecContext(ctx, insert, values...); err != nil {
+			return fmt.Errorf("failed to load %s: %w", p, err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return fmt.Errorf("failed to read JSONL %s: %w", p, err)
+	}
+	return nil
+}
+
+// createQueryTable creates the temporary query table with the given column
+// definitions and returns the statement that inserts a row.
+func createQueryTable(ctx context.Context, db dbtx, names, defs []string) (string, error) {
+	if len(names) == 0 {
+		return "", fmt.Errorf("failed to load table: file has no columns")
+	}
+	create := fmt.Sprintf("CREATE TEMP TABLE %s (%s)", queryTable, strings.Join(defs, ", "))
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return "", fmt.Errorf("failed to create table: %w", err)
+	}
+	cols := make([]string, len(names))
+	for i, name := range names {
+		cols[i] = quoteIdent(name)
+	}
+	return fmt.Sprintf("INSERT INTO temp.%s (%s) VALUES (?%s)", queryTable,
+		strings.Join(cols, ", "), strings.Repeat(", ?", len(names)-1)), nil
+}
+
+// sqlColumnType returns the SQLite type for an inferred CSV column type.
+func sqlColumnType(t ColumnType) string {
+	switch t {
+	case ColumnInt, ColumnBool:
+		return "INTEGER"
+	case ColumnFloat:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+// jsonColumnValue converts a JSON value to the value stored for it: nil,
+// int64 for integral numbers, float64, bool, string, or JSON text for
+// objects and arrays.
+func jsonColumnValue(raw json.RawMessage) any {
+	var v any
+	if len(raw) == 0 || json.Unmarshal(raw, &v) != nil {
+		return nil
+	}
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case map[string]any, []any:
+		return string(raw)
+	}
+	return v
+}
+
+// scanMaps reads every row as a map keyed by column name. Text and blob
+// values are returned as strings so the rows marshal to readable JSON.
+func scanMaps(rows *sql.Rows) ([]map[string]any, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]any{}
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(cols))
+		for i, col := range cols {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,96 @@
+package agentfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestQueryTable_CSV(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	csvData := "region,year,amount\nnorth,2024,10.5\nsouth,2024,4\nnorth,2024,2\nnorth,2023,100\n"
+	afs.FS.WriteFile(ctx, "/data/sales.csv", []byte(csvData), 0o644)
+
+	rows, err := afs.FS.QueryTable(ctx, "/data/sales.csv",
+		"SELECT region, sum(amount) AS total FROM data WHERE year = ? GROUP BY region ORDER BY region", 2024)
+	if err != nil {
+		t.Fatalf("QueryTable failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2: %v", len(rows), rows)
+	}
+	if rows[0]["region"] != "north" || rows[0]["total"] != 12.5 {
+		t.Errorf("rows[0] = %v, want north 12.5", rows[0])
+	}
+	if rows[1]["region"] != "south" || rows[1]["total"] != 4.0 {
+		t.Errorf("rows[1] = %v, want south 4", rows[1])
+	}
+
+	// The table does not outlive the query
+	if _, err := afs.FS.QueryTable(ctx, "/data/sales.csv", "SELECT count(*) AS n FROM data"); err != nil {
+		t.Fatalf("second QueryTable failed: %v", err)
+	}
+}
+
+func TestQueryTable_JSONL(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	jsonl := `{"kind":"click","n":1,"meta":{"page":"home"}}
+{"kind":"view","n":2}
+{"kind":"click","n":3,"meta":{"page":"docs"}}
+`
+	afs.FS.WriteFile(ctx, "/data/events.jsonl", []byte(jsonl), 0o644)
+
+	rows, err := afs.FS.QueryTable(ctx, "/data/events.jsonl",
+		"SELECT sum(n) AS n, group_concat(json_extract(meta, '$.page')) AS pages FROM data WHERE kind = 'click'")
+	if err != nil {
+		t.Fatalf("QueryTable failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["n"] != int64(4) || rows[0]["pages"] != "home,docs" {
+		t.Errorf("rows = %v, want n=4 pages=home,docs", rows)
+	}
+}
+
+func TestQueryTable_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/data/t.csv", []byte("a\n1\n"), 0o644)
+
+	if _, err := afs.FS.QueryTable(ctx, "/data/t.csv", "DELETE FROM fs_dentry"); err == nil {
+		t.Fatal("expected a write to be refused")
+	}
+	if _, err := afs.FS.Stat(ctx, "/data/t.csv"); err != nil {
+		t.Fatalf("file lost after refused write: %v", err)
+	}
+	// A second statement cannot switch read-only mode off
+	if _, err := afs.FS.QueryTable(ctx, "/data/t.csv", "SELECT 1; PRAGMA query_only=OFF; DELETE FROM fs_dentry"); err == nil {
+		t.Fatal("expected a second statement to be refused")
+	}
+	if _, err := afs.FS.Stat(ctx, "/data/t.csv"); err != nil {
+		t.Fatalf("file lost after refused statements: %v", err)
+	}
+	// The AgentFS tables are out of reach
+	if _, err := afs.FS.QueryTable(ctx, "/data/t.csv", "SELECT CAST(data AS TEXT) FROM fs_data"); err == nil {
+		t.Error("query read the AgentFS database")
+	}
+	// Semicolons in strings and a trailing one are fine
+	rows, err := afs.FS.QueryTable(ctx, "/data/t.csv", "SELECT a, 'x;y' AS s FROM data; -- done")
+	if err != nil || len(rows) != 1 || rows[0]["s"] != "x;y" {
+		t.Errorf("rows = %v, %v", rows, err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/data/u.txt", []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	_, err = afs.FS.QueryTable(ctx, "/data/notes.txt", "SELECT 1")
+	if err == nil || !strings.Contains(err.Error(), "unsupported file type") {
+		t.Errorf("expected unsupported file type error, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package agentfs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit bounds the I/O of an operation. Zero fields are unlimited.
+type RateLimit struct {
+	// BytesPerSecond limits the bytes read or written per second.
+	BytesPerSecond int64
+
+	// OpsPerSecond limits the files, chunks, or other items processed per
+	// second.
+	OpsPerSecond float64
+}
+
+// Limiter throttles background work such as imports, exports, and blob
+// pruning so it does not starve the agent's own reads and writes. A single
+// Limiter may be shared by several operations to give them a common budget.
+// A nil *Limiter does not limit.
+type Limiter struct {
+	mu    sync.Mutex
+	bytes bucket
+	ops   bucket
+
+	// Replaceable for tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// bucket is a token bucket refilled at rate tokens per second, holding at
+// most one second's worth. Requests may overdraw it; the caller then waits
+// until the debt is repaid.
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter enforcing limit.
+func NewLimiter(limit RateLimit) *Limiter {
+	l := &Limiter{now: time.Now, sleep: sleepContext}
+	now := l.now()
+	l.bytes = bucket{rate: float64(limit.BytesPerSecond), tokens: float64(limit.BytesPerSecond), last: now}
+	l.ops = bucket{rate: limit.OpsPerSecond, tokens: limit.OpsPerSecond, last: now}
+	return l
+}
+
+// Wait blocks until one operation moving n bytes may proceed, or ctx is
+// done.
+func (l *Limiter) Wait(ctx context.Context, n int64) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	d := l.bytes.take(now, float64(n))
+	if opsWait := l.ops.take(now, 1); opsWait > d {
+		d = opsWait
+	}
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return ctx.Err()
+	}
+	return l.sleep(ctx, d)
+}
+
+// take withdraws n tokens and returns how long the caller must wait for the
+// bucket to be back in credit.
+func (b *bucket) take(now time.Time, n float64) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
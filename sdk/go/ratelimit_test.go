@@ -0,0 +1,95 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeLimiter returns a limiter driven by a manual clock that records the
+// time it was asked to sleep.
+func fakeLimiter(limit RateLimit) (*Limiter, *time.Duration) {
+	l := NewLimiter(limit)
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	l.now = func() time.Time { return now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+	l.bytes.last, l.ops.last = now, now
+	return l, &slept
+}
+
+func TestLimiterBytes(t *testing.T) {
+	ctx := context.Background()
+	l, slept := fakeLimiter(RateLimit{BytesPerSecond: 1000})
+
+	// The first second's worth passes immediately
+	if err := l.Wait(ctx, 1000); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if *slept != 0 {
+		t.Errorf("slept %v within burst, want 0", *slept)
+	}
+
+	if err := l.Wait(ctx, 500); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if *slept != 500*time.Millisecond {
+		t.Errorf("slept %v, want 500ms", *slept)
+	}
+}
+
+func TestLimiterOps(t *testing.T) {
+	ctx := context.Background()
+	l, slept := fakeLimiter(RateLimit{OpsPerSecond: 10})
+
+	for i := 0; i < 20; i++ {
+		if err := l.Wait(ctx, 0); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	// 10 ops of burst, then 10 more at 10/s
+	if *slept < 990*time.Millisecond || *slept > 1010*time.Millisecond {
+		t.Errorf("slept %v, want ~1s", *slept)
+	}
+}
+
+func TestLimiterNilAndCancel(t *testing.T) {
+	var l *Limiter
+	if err := l.Wait(context.Background(), 1<<30); err != nil {
+		t.Errorf("nil Limiter Wait = %v", err)
+	}
+
+	l = NewLimiter(RateLimit{BytesPerSecond: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, 1<<20); err != context.Canceled {
+		t.Errorf("Wait on cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestImportRateLimit(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	src := t.TempDir()
+	writeHostTree(t, src, map[string]string{
+		"a": string(make([]byte, 1000)),
+		"b": string(make([]byte, 1000)),
+		"c": string(make([]byte, 1000)),
+	})
+
+	l, slept := fakeLimiter(RateLimit{BytesPerSecond: 1000})
+	err := afs.FS.ImportDir(ctx, src, "/dst", &ImportOptions{Concurrency: 1, Limiter: l})
+	if err != nil {
+		t.Fatalf("ImportDir failed: %v", err)
+	}
+	// One second of burst, then 2000 bytes at 1000 B/s
+	if *slept != 2*time.Second {
+		t.Errorf("slept %v, want 2s", *slept)
+	}
+}
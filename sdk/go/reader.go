@@ -0,0 +1,146 @@
+package agentfs
+
+import (
+	"context"
+	"io"
+)
+
+// FileReader reads a file opened with OpenReader, fetching one chunk at a
+// time as the offset reaches it. The size is fixed when the reader is
+// opened, so a file that grows meanwhile reads as its earlier length.
+//
+// A FileReader is not safe for concurrent use.
+type FileReader struct {
+	file   *File
+	ctx    context.Context
+	stats  *Stats
+	size   int64
+	offset int64
+
+	chunkSize int64 // Of the file when it was opened
+
+	// The most recently fetched chunk, padded with zeros to its full
+	// length within the file
+	index int64
+	chunk []byte
+}
+
+// Compile-time interface checks
+var _ io.ReadSeekCloser = (*FileReader)(nil)
+
+// OpenReader opens the file at p for streaming reads. Unlike File.Read,
+// which queries the database on every call, the reader keeps the current
+// chunk, so small sequential reads cost one query per chunk; Seek only
+// moves the offset, so reading a header or serving a byte range fetches
+// just the chunks involved. ctx applies to every read.
+//
+// Example:
+//
+//	r, err := afs.FS.OpenReader(ctx, "/outputs/video.mp4")
+//	if err != nil {
+//	    return err
+//	}
+//	defer r.Close()
+//	http.ServeContent(w, req, "video.mp4", r.Stat().MtimeTime(), r)
+func (fs *Filesystem) OpenReader(ctx context.Context, p string) (*FileReader, error) {
+	f, err := fs.Open(ctx, p, O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := fs.statInode(ctx, f.ino)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	chunkSize, err := fs.chunkSizeOf(ctx, f.ino)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileReader{file: f, ctx: ctx, size: stats.Size, chunkSize: chunkSize, index: -1, stats: stats}, nil
+}
+
+// Read reads up to len(p) bytes at the current offset. It implements
+// io.Reader and returns io.EOF at the end of the file.
+func (r *FileReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && r.offset < r.size {
+		chunk, err := r.fetch(r.offset / r.chunkSize)
+		if err != nil {
+			return n, err
+		}
+		k := copy(p[n:], chunk[r.offset%r.chunkSize:])
+		n += k
+		r.offset += int64(k)
+	}
+	return n, nil
+}
+
+// fetch returns chunk index, reading it from the database unless it is
+// the cached one.
+func (r *FileReader) fetch(index int64) ([]byte, error) {
+	if index == r.index {
+		return r.chunk, nil
+	}
+	fs := r.file.fs
+	ctx, done, err := fs.life.begin(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	stored, err := fs.readChunks(ctx, r.file.ino, index, index)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := r.chunkSize
+	length := min(chunkSize, r.size-index*chunkSize)
+	if cap(r.chunk) < int(length) {
+		r.chunk = make([]byte, chunkSize)
+	}
+	r.chunk = r.chunk[:length]
+	n := 0
+	if len(stored) > 0 {
+		n = copy(r.chunk, stored[0].data)
+	}
+	clear(r.chunk[n:]) // Sparse regions and short chunks read as zeros
+	r.index = index
+	return r.chunk, nil
+}
+
+// Seek sets the offset for the next Read. It implements io.Seeker. Seeking
+// past the end is allowed; reads there return io.EOF.
+func (r *FileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, ErrInval("seek", r.file.path, "invalid whence")
+	}
+	if offset < 0 {
+		return 0, ErrInval("seek", r.file.path, "negative offset")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Size returns the size of the file when it was opened.
+func (r *FileReader) Size() int64 {
+	return r.size
+}
+
+// Stat returns the file's metadata as of when it was opened.
+func (r *FileReader) Stat() *Stats {
+	return r.stats
+}
+
+// Close releases the file handle.
+func (r *FileReader) Close() error {
+	return r.file.Close()
+}
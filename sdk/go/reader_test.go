@@ -0,0 +1,89 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenReader(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	chunk := afs.FS.ChunkSize()
+	data := make([]byte, chunk*3+17)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	afs.FS.WriteFile(ctx, "/big.bin", data, 0o644)
+
+	r, err := afs.FS.OpenReader(ctx, "/big.bin")
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer r.Close()
+
+	// Small reads across chunk boundaries
+	var got bytes.Buffer
+	buf := make([]byte, 100)
+	if _, err := io.CopyBuffer(&got, struct{ io.Reader }{r}, buf); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), data) {
+		t.Fatalf("content mismatch: got %d bytes, want %d", got.Len(), len(data))
+	}
+
+	off := int64(chunk*2 - 5)
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	part := make([]byte, 10)
+	if _, err := io.ReadFull(r, part); err != nil {
+		t.Fatalf("ReadFull failed: %v", err)
+	}
+	if !bytes.Equal(part, data[off:off+10]) {
+		t.Errorf("read at %d = %v, want %v", off, part, data[off:off+10])
+	}
+
+	if pos, _ := r.Seek(-3, io.SeekEnd); pos != int64(len(data)-3) {
+		t.Errorf("Seek(-3, End) = %d, want %d", pos, len(data)-3)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("expected an error seeking before the start")
+	}
+}
+
+func TestOpenReader_RangeRequest(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/report.txt", []byte("0123456789"), 0o644)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r, err := afs.FS.OpenReader(req.Context(), "/report.txt")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer r.Close()
+		http.ServeContent(w, req, "report.txt", r.Stat().MtimeTime(), r)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "345" {
+		t.Errorf("got %d %q, want 206 %q", resp.StatusCode, body, "345")
+	}
+}
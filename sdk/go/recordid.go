@@ -0,0 +1,171 @@
+package agentfs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// IDStrategy selects the globally unique IDs AgentFS gives tool calls,
+// snapshots, and sessions next to their local IDs (see
+// AgentFSOptions.IDStrategy).
+type IDStrategy string
+
+const (
+	// IDRowID gives no global IDs: records are known by their row IDs,
+	// which are unique only within one database.
+	IDRowID IDStrategy = ""
+	// IDULID gives ULIDs: 26 characters of Crockford base32 that sort by
+	// creation time, to the millisecond.
+	IDULID IDStrategy = "ulid"
+	// IDUUIDv7 gives version 7 UUIDs (RFC 9562), which also sort by
+	// creation time.
+	IDUUIDv7 IDStrategy = "uuidv7"
+)
+
+// crockford is the Crockford base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// recordIDs makes the IDs of an ULID or UUIDv7 strategy; it is nil under
+// IDRowID. Both formats start with the creation time in milliseconds. An
+// ID made in the same millisecond as the previous one, or after the clock
+// went back, increments the previous one instead, so the IDs of a process
+// sort in the order they were made.
+type recordIDs struct {
+	strategy IDStrategy
+	clock    Clock
+	ids      IDGenerator // Seeds the random bits if set; else crypto/rand
+
+	mu   sync.Mutex
+	last [16]byte
+}
+
+// newRecordIDs returns the generator of strategy.
+func newRecordIDs(strategy IDStrategy, clock Clock, ids IDGenerator) (*recordIDs, error) {
+	switch strategy {
+	case IDRowID:
+		return nil, nil
+	case IDULID, IDUUIDv7:
+		return &recordIDs{strategy: strategy, clock: clock, ids: ids}, nil
+	}
+	return nil, fmt.Errorf("unknown ID strategy %q", strategy)
+}
+
+// next returns a new ID, or nil under IDRowID, which stores as NULL.
+func (g *recordIDs) next() *string {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var b [16]byte
+	ms := uint64(g.clock.Now().UnixMilli())
+	if last := binary.BigEndian.Uint64(g.last[:8]) >> 16; ms <= last {
+		// Count up in the low 56 bits, clear of the UUID version and variant
+		b = g.last
+		for i := 15; i >= 9; i-- {
+			b[i]++
+			if b[i] != 0 {
+				break
+			}
+		}
+	} else {
+		binary.BigEndian.PutUint64(b[:8], ms<<16)
+		if g.ids != nil {
+			sum := sha256.Sum256([]byte(g.ids.NewID()))
+			copy(b[6:], sum[:10])
+		} else if _, err := rand.Read(b[6:]); err != nil {
+			panic(err)
+		}
+	}
+	g.last = b
+
+	var id string
+	if g.strategy == IDULID {
+		id = encodeULID(b)
+	} else {
+		b[6] = b[6]&0x0f | 0x70 // Version 7
+		b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+		id = fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	}
+	return &id
+}
+
+// uidString returns the ID next returned, or "" for none.
+func uidString(uid *string) string {
+	if uid == nil {
+		return ""
+	}
+	return *uid
+}
+
+// encodeULID returns the 128 bits of b in Crockford base32.
+func encodeULID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// NewSessionID returns a new session ID, for AddMessage and the
+// SessionAnnotation of the session's tool calls: a ULID or UUIDv7 under
+// those ID strategies, or else an ID from AgentFSOptions.IDGenerator.
+func (a *AgentFS) NewSessionID() string {
+	if id := a.Tools.uids.next(); id != nil {
+		return *id
+	}
+	return a.FS.ids.NewID()
+}
+
+// GetByUID retrieves a tool call by its global ID (see
+// AgentFSOptions.IDStrategy).
+func (tc *ToolCalls) GetByUID(ctx context.Context, uid string) (*ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallsGetByUID, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool call: %w", err)
+	}
+	defer rows.Close()
+	calls, err := scanToolCalls(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool call: %w", err)
+	}
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("tool call not found: %s", uid)
+	}
+	return &calls[0], nil
+}
+
+// migrateToolCallHistory rebuilds the tool_call_history view over the uid
+// column once it was added to tool_calls, adding it to the archives of
+// earlier versions first.
+func migrateToolCallHistory(ctx context.Context, db dbtx) error {
+	if _, err := db.ExecContext(ctx, toolCallHistoryProbeUID); err == nil {
+		return nil
+	}
+	archives, err := listArchives(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, a := range archives {
+		db.ExecContext(ctx, fmt.Sprintf(toolCallArchiveAddUID, a.Table)) // Ignore errors (column may already exist)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(toolCallArchiveUIDIndex, a.Table)); err != nil {
+			return err
+		}
+	}
+	return rebuildToolCallHistory(ctx, db)
+}
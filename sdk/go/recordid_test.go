@@ -0,0 +1,97 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRecordIDs(t *testing.T) {
+	formats := map[IDStrategy]*regexp.Regexp{
+		IDULID:   regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`),
+		IDUUIDv7: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+	}
+	for strategy, format := range formats {
+		clock := NewManualClock(time.UnixMilli(1700000000000))
+		g, err := newRecordIDs(strategy, clock, nil)
+		if err != nil {
+			t.Fatalf("newRecordIDs(%q) failed: %v", strategy, err)
+		}
+		var ids []string
+		for i := 0; i < 5; i++ {
+			ids = append(ids, *g.next(), *g.next())
+			if i == 2 {
+				clock.Set(time.UnixMilli(1600000000000)) // Clock goes back
+			} else {
+				clock.Advance(time.Millisecond)
+			}
+		}
+		for _, id := range ids {
+			if !format.MatchString(id) {
+				t.Errorf("%s ID %q is malformed", strategy, id)
+			}
+		}
+		if !sort.StringsAreSorted(ids) {
+			t.Errorf("%s IDs are not in the order they were made: %q", strategy, ids)
+		}
+	}
+
+	if g, _ := newRecordIDs(IDRowID, systemClock{}, nil); g.next() != nil {
+		t.Error("IDRowID gave a global ID")
+	}
+	if _, err := newRecordIDs("snowflake", systemClock{}, nil); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+}
+
+func TestRecordIDs_Deterministic(t *testing.T) {
+	first := func() string {
+		clock := NewManualClock(time.Unix(1700000000, 0))
+		g, _ := newRecordIDs(IDULID, clock, NewSequentialIDs("run"))
+		return *g.next()
+	}
+	if a, b := first(), first(); a != b {
+		t.Errorf("IDs with injected clock and IDs differ: %q, %q", a, b)
+	}
+}
+
+func TestIDStrategy(t *testing.T) {
+	ctx := context.Background()
+	afs, err := Open(ctx, AgentFSOptions{
+		Path:       filepath.Join(t.TempDir(), "test.db"),
+		IDStrategy: IDULID,
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	call, err := afs.Tools.Record(ctx, "build", nil, "ok", nil, 1, 2)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if len(call.UID) != 26 {
+		t.Fatalf("UID = %q, want a ULID", call.UID)
+	}
+	got, err := afs.Tools.GetByUID(ctx, call.UID)
+	if err != nil || got.ID != call.ID || got.UID != call.UID {
+		t.Errorf("GetByUID = %+v, %v", got, err)
+	}
+	if _, err := afs.Tools.GetByUID(ctx, "01ARZ3NDEKTSV4RRFFQ69G5FAV"); err == nil {
+		t.Error("GetByUID of an unknown ID succeeded")
+	}
+
+	if err := afs.Snapshot(ctx, "s"); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	snaps, err := afs.Snapshots(ctx)
+	if err != nil || len(snaps) != 1 || len(snaps[0].ID) != 26 || snaps[0].ID <= call.UID {
+		t.Errorf("Snapshots = %+v, %v", snaps, err)
+	}
+	if id := afs.NewSessionID(); len(id) != 26 {
+		t.Errorf("NewSessionID = %q, want a ULID", id)
+	}
+}
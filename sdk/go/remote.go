@@ -0,0 +1,118 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// remoteSchemes are the URL schemes of libSQL servers.
+var remoteSchemes = []string{"libsql://", "https://", "http://", "wss://", "ws://"}
+
+// isRemoteURL reports whether path is the URL of a remote database rather
+// than a file path.
+func isRemoteURL(path string) bool {
+	for _, scheme := range remoteSchemes {
+		if strings.HasPrefix(path, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// openRemote opens the remote database at opts.Path (see RemoteOptions).
+// There is no local file, so the features that keep files next to the
+// database do without: the mailbox is shared through the remote database,
+// and External needs its own Dir or Tiers.
+func openRemote(ctx context.Context, opts AgentFSOptions) (*AgentFS, error) {
+	driver := opts.Remote.Driver
+	if driver == "" {
+		driver = "libsql"
+	}
+	if !driverRegistered(driver) {
+		return nil, fmt.Errorf("failed to open %s: no database/sql driver named %q is registered (import a libSQL driver such as github.com/tursodatabase/libsql-client-go/libsql)", redactURL(opts.Path), driver)
+	}
+	dsn, err := remoteDSN(opts.Path, opts.Remote.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	configurePool(db, opts.Pool)
+
+	if err := setupPragmas(ctx, db, opts); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if opts.TablePrefix != "" {
+		pdb, err := withTablePrefix(db, opts.TablePrefix, true)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db = pdb
+	}
+
+	// Fewer, larger chunks save round trips
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultRemoteChunkSize
+	}
+	afs, err := initAgentFS(ctx, db, "", true, opts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return afs, nil
+}
+
+// driverRegistered reports whether a database/sql driver is registered
+// under name.
+func driverRegistered(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteDSN returns rawURL with token as its authToken parameter.
+func remoteDSN(rawURL, token string) (string, error) {
+	if token == "" {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// The error quotes the URL, so it is left out
+		return "", fmt.Errorf("invalid database URL %s", redactURL(rawURL))
+	}
+	q := u.Query()
+	q.Set("authToken", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// redactURL returns rawURL without its user info and query, which may hold
+// credentials, for error messages.
+func redactURL(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rest := rawURL[i+3:]
+		host := rest
+		if j := strings.IndexByte(rest, '/'); j >= 0 {
+			host = rest[:j]
+		}
+		if j := strings.LastIndexByte(host, '@'); j >= 0 {
+			rawURL = rawURL[:i+3] + rest[j+1:]
+		}
+	}
+	return rawURL
+}
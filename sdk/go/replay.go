@@ -0,0 +1,272 @@
+package agentfs
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SessionAnnotation is the tool call annotation naming the session a call
+// belongs to. ExportSession bundles the calls annotated with the session ID.
+const SessionAnnotation = "session"
+
+// SessionBundleFormat identifies the bundles written by ExportSession.
+const SessionBundleFormat = "agentfs-session/1"
+
+// Message is one message of an agent session's conversation.
+type Message struct {
+	ID        int64  `json:"id"`
+	SessionID string `json:"session_id"`
+	Role      string `json:"role"` // e.g. "system", "user", "assistant"
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// AddMessage appends a message to the conversation of sessionID.
+func (a *AgentFS) AddMessage(ctx context.Context, sessionID, role, content string) (*Message, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if sessionID == "" {
+		return nil, fmt.Errorf("session ID must not be empty")
+	}
+	msg := &Message{SessionID: sessionID, Role: role, Content: content, CreatedAt: a.FS.now().Unix()}
+	if err := a.db.QueryRowContext(ctx, messageInsert, sessionID, role, content, msg.CreatedAt).Scan(&msg.ID); err != nil {
+		return nil, fmt.Errorf("failed to add message: %w", err)
+	}
+	return msg, nil
+}
+
+// Messages returns the conversation of sessionID in the order it was added.
+func (a *AgentFS) Messages(ctx context.Context, sessionID string) ([]Message, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := a.db.QueryContext(ctx, messageList, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// SessionBundle is a self-contained, read-only record of one agent session,
+// written by ExportSession and loaded with ReadSessionBundle.
+type SessionBundle struct {
+	Format     string          `json:"format"`
+	SessionID  string          `json:"session_id"`
+	ExportedAt int64           `json:"exported_at"`
+	Messages   []Message       `json:"messages"`
+	ToolCalls  []BundleCall    `json:"tool_calls"`
+	Files      []BundleFile    `json:"files"`
+	KV         []BundleKVEntry `json:"kv"`
+}
+
+// BundleCall is a tool call in a SessionBundle with its annotations, usage,
+// the paths of its linked files, and the calls it refers to.
+type BundleCall struct {
+	ToolCall
+	Annotations map[string]json.RawMessage `json:"annotations"`
+	Usage       Usage                      `json:"usage"`
+	Files       []string                   `json:"files"`
+	ParentID    int64                      `json:"parent_id,omitempty"`   // See ToolCalls.SetParent
+	OriginalID  int64                      `json:"original_id,omitempty"` // First call of its retry chain
+	Attempt     int                        `json:"attempt,omitempty"`     // Attempt number in the retry chain
+}
+
+// BundleFile is a file referenced by the tool calls of a SessionBundle, as
+// it was when the bundle was exported.
+type BundleFile struct {
+	Path  string `json:"path"`
+	Mode  int64  `json:"mode"`
+	Mtime int64  `json:"mtime"`
+	Data  []byte `json:"data"`
+}
+
+// BundleKVEntry is a key of the KV snapshot in a SessionBundle.
+type BundleKVEntry struct {
+	Namespace string          `json:"namespace,omitempty"` // See KVStore.Namespace
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// File returns the bundled file at p, or nil if the bundle has none.
+func (b *SessionBundle) File(p string) *BundleFile {
+	p = normalizePath(p)
+	for i := range b.Files {
+		if b.Files[i].Path == p {
+			return &b.Files[i]
+		}
+	}
+	return nil
+}
+
+// ExportSession writes a bundle of session sessionID to w: its messages,
+// the tool calls annotated with SessionAnnotation = sessionID (with their
+// annotations and usage), the current content of the files linked to those
+// calls, and a snapshot of the KV store without system keys. The bundle is
+// gzip-compressed JSON, so a support engineer can inspect a user's failed
+// run with ReadSessionBundle without access to the database.
+//
+// Example:
+//
+//	afs.Tools.Annotate(ctx, call.ID, agentfs.SessionAnnotation, "sess-42")
+//	f, _ := os.Create("sess-42.agentfs.gz")
+//	err := afs.ExportSession(ctx, "sess-42", f)
+func (a *AgentFS) ExportSession(ctx context.Context, sessionID string, w io.Writer) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	b := &SessionBundle{
+		Format:     SessionBundleFormat,
+		SessionID:  sessionID,
+		ExportedAt: a.FS.now().Unix(),
+		ToolCalls:  []BundleCall{},
+		Files:      []BundleFile{},
+		KV:         []BundleKVEntry{},
+	}
+	if b.Messages, err = a.Messages(ctx, sessionID); err != nil {
+		return err
+	}
+
+	calls, err := a.Tools.Find(ctx, ToolCallFilter{
+		Annotations: map[string]any{SessionAnnotation: sessionID},
+		Limit:       -1,
+	})
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for i := len(calls) - 1; i >= 0; i-- { // Oldest first
+		bc, err := a.bundleCall(ctx, calls[i])
+		if err != nil {
+			return err
+		}
+		b.ToolCalls = append(b.ToolCalls, *bc)
+
+		for _, p := range bc.Files {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			f, err := a.bundleFile(ctx, p)
+			if err != nil {
+				return err
+			}
+			if f != nil {
+				b.Files = append(b.Files, *f)
+			}
+		}
+	}
+
+	rows, err := a.db.QueryContext(ctx, kvSnapshot, a.FS.now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to snapshot KV store: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e BundleKVEntry
+		var value string
+		if err := rows.Scan(&e.Namespace, &e.Key, &value, &e.UpdatedAt); err != nil {
+			return err
+		}
+		e.Key = splitStoreKey(e.Namespace, e.Key)
+		e.Value = json.RawMessage(value)
+		b.KV = append(b.KV, e)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(b); err != nil {
+		return fmt.Errorf("failed to write session bundle: %w", err)
+	}
+	return zw.Close()
+}
+
+// bundleCall returns call with the records that refer to it.
+func (a *AgentFS) bundleCall(ctx context.Context, call ToolCall) (*BundleCall, error) {
+	bc := &BundleCall{ToolCall: call}
+	var err error
+	if bc.Annotations, err = a.Tools.Annotations(ctx, bc.ID); err != nil {
+		return nil, err
+	}
+	if bc.Usage, err = a.Tools.Usage(ctx, bc.ID); err != nil {
+		return nil, err
+	}
+	if bc.Files, err = a.Tools.LinkedFiles(ctx, bc.ID); err != nil {
+		return nil, err
+	}
+	var parentID sql.NullInt64
+	err = a.db.QueryRowContext(ctx, toolCallParent, bc.ID).Scan(&parentID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get parent: %w", err)
+	}
+	bc.ParentID = parentID.Int64
+	err = a.db.QueryRowContext(ctx, toolCallRetryOf, bc.ID).Scan(&bc.OriginalID, &bc.Attempt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get retry chain: %w", err)
+	}
+	return bc, nil
+}
+
+// bundleFile reads the regular file at p for a bundle. It returns nil if
+// p no longer exists or is not a regular file.
+func (a *AgentFS) bundleFile(ctx context.Context, p string) (*BundleFile, error) {
+	stats, err := a.FS.Stat(ctx, p)
+	if IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !stats.IsRegularFile() {
+		return nil, nil
+	}
+	data, err := a.FS.ReadFile(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &BundleFile{Path: p, Mode: stats.Mode & 0o7777, Mtime: stats.Mtime, Data: data}, nil
+}
+
+// ReadSessionBundle loads a bundle written by ExportSession.
+func ReadSessionBundle(r io.Reader) (*SessionBundle, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session bundle: %w", err)
+	}
+	defer zr.Close()
+
+	var b SessionBundle
+	if err := json.NewDecoder(zr).Decode(&b); err != nil {
+		return nil, fmt.Errorf("failed to read session bundle: %w", err)
+	}
+	if b.Format != SessionBundleFormat {
+		return nil, fmt.Errorf("unsupported session bundle format %q", b.Format)
+	}
+	return &b, nil
+}
@@ -0,0 +1,53 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestExportSession(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.AddMessage(ctx, "sess-1", "user", "write the report")
+	afs.AddMessage(ctx, "sess-2", "user", "unrelated")
+	afs.AddMessage(ctx, "sess-1", "assistant", "done")
+
+	afs.FS.WriteFile(ctx, "/report.md", []byte("# Q3"), 0o644)
+	call, _ := afs.Tools.Record(ctx, "write", nil, "ok", nil, 1, 2)
+	other, _ := afs.Tools.Record(ctx, "write", nil, "ok", nil, 3, 4)
+	afs.Tools.Annotate(ctx, call.ID, SessionAnnotation, "sess-1")
+	afs.Tools.Annotate(ctx, other.ID, SessionAnnotation, "sess-2")
+	afs.Tools.LinkFiles(ctx, call.ID, "/report.md")
+	afs.Tools.AddUsage(ctx, call.ID, Usage{InputTokens: 7})
+	afs.KV.Set(ctx, "plan", []string{"draft", "review"})
+	afs.KV.systemKV().Set(ctx, "sys:internal", 1)
+
+	var buf bytes.Buffer
+	if err := afs.ExportSession(ctx, "sess-1", &buf); err != nil {
+		t.Fatalf("ExportSession failed: %v", err)
+	}
+	b, err := ReadSessionBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadSessionBundle failed: %v", err)
+	}
+
+	if len(b.Messages) != 2 || b.Messages[0].Content != "write the report" || b.Messages[1].Role != "assistant" {
+		t.Errorf("Messages = %+v", b.Messages)
+	}
+	if len(b.ToolCalls) != 1 || b.ToolCalls[0].ID != call.ID || b.ToolCalls[0].Usage.InputTokens != 7 {
+		t.Errorf("ToolCalls = %+v", b.ToolCalls)
+	}
+	if f := b.File("/report.md"); f == nil || string(f.Data) != "# Q3" {
+		t.Errorf("File(/report.md) = %+v", f)
+	}
+	if len(b.KV) != 1 || b.KV[0].Key != "plan" || string(b.KV[0].Value) != `["draft","review"]` {
+		t.Errorf("KV = %+v", b.KV)
+	}
+
+	if _, err := ReadSessionBundle(bytes.NewReader([]byte("not a bundle"))); err == nil {
+		t.Error("ReadSessionBundle accepted garbage")
+	}
+}
@@ -0,0 +1,147 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotReplica is returned by Sync on an AgentFS that was not opened as
+// an embedded replica (see SyncOptions).
+var ErrNotReplica = errors.New("agentfs: not an embedded replica")
+
+// Replica is a local database file kept in sync with a remote libSQL
+// primary, such as the embedded replica connector of
+// github.com/tursodatabase/go-libsql (see SyncOptions.Connect).
+type Replica interface {
+	// DB returns the database of the local file.
+	DB() *sql.DB
+	// Sync sends local changes to the primary and applies the changes made
+	// there since the last sync.
+	Sync(ctx context.Context) error
+	// Close releases the replica. AgentFS closes DB first.
+	Close() error
+}
+
+// ReplicaConnector opens the embedded replica at path of the primary
+// described by opts.
+type ReplicaConnector func(ctx context.Context, path string, opts SyncOptions) (Replica, error)
+
+// openReplica opens the embedded replica at the resolved path of opts and
+// pulls the primary's state before setting up AgentFS, so a fresh machine
+// starts where the last one left off.
+func openReplica(ctx context.Context, opts AgentFSOptions) (*AgentFS, error) {
+	if opts.Sync.Connect == nil {
+		return nil, fmt.Errorf("failed to open replica of %s: SyncOptions.Connect is not set", redactURL(opts.Sync.URL))
+	}
+	dbPath, err := resolveDBPath(opts)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	r, err := opts.Sync.Connect(ctx, dbPath, opts.Sync)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica of %s: %w", redactURL(opts.Sync.URL), err)
+	}
+	if err := r.Sync(ctx); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to sync replica: %w", err)
+	}
+
+	db := r.DB()
+	configurePool(db, opts.Pool)
+	if err := setupPragmas(ctx, db, opts); err != nil {
+		db.Close()
+		r.Close()
+		return nil, err
+	}
+	if opts.TablePrefix != "" {
+		pdb, err := withTablePrefix(db, opts.TablePrefix, true)
+		if err != nil {
+			db.Close()
+			r.Close()
+			return nil, err
+		}
+		db = pdb
+	}
+
+	afs, err := initAgentFS(ctx, db, dbPath, true, opts)
+	if err != nil {
+		db.Close()
+		r.Close()
+		return nil, err
+	}
+	afs.replica = r
+	if !afs.readOnly {
+		afs.startSyncer(opts.Sync.Interval, opts.Sync.OnError)
+	}
+	return afs, nil
+}
+
+// Sync exchanges changes with the primary of an embedded replica right
+// away, e.g. after finishing a task, instead of waiting for the next
+// SyncOptions.Interval. It returns ErrNotReplica if the AgentFS was not
+// opened with SyncOptions.
+func (a *AgentFS) Sync(ctx context.Context) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return a.sync(ctx)
+}
+
+// sync syncs the replica, one run at a time.
+func (a *AgentFS) sync(ctx context.Context) error {
+	if a.replica == nil {
+		return ErrNotReplica
+	}
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	if err := a.replica.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync replica: %w", err)
+	}
+	return nil
+}
+
+// startSyncer syncs the replica every interval in the background,
+// reporting failures to onError. Close cancels a sync in progress.
+func (a *AgentFS) startSyncer(interval time.Duration, onError func(error)) {
+	if interval <= 0 || a.replica == nil {
+		return
+	}
+	a.goBackground(func(stop <-chan struct{}) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			// Errors are retried on the next interval
+			if err := a.sync(ctx); err != nil && ctx.Err() == nil && onError != nil {
+				onError(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errPrimaryDown = errors.New("primary unreachable")
+
+// fakeReplica is a plain SQLite file that counts its syncs. While fail is
+// set its syncs fail; if hang is set, the next one waits for its context.
+type fakeReplica struct {
+	db      *sql.DB
+	syncs   atomic.Int32
+	fail    atomic.Bool
+	hang    atomic.Bool
+	hanging chan struct{}
+	closed  bool
+}
+
+func (r *fakeReplica) DB() *sql.DB { return r.db }
+
+func (r *fakeReplica) Sync(ctx context.Context) error {
+	r.syncs.Add(1)
+	if r.hang.CompareAndSwap(true, false) {
+		close(r.hanging)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if r.fail.Load() {
+		return errPrimaryDown
+	}
+	return nil
+}
+
+func (r *fakeReplica) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestOpen_Replica(t *testing.T) {
+	ctx := context.Background()
+	var replica *fakeReplica
+	opts := AgentFSOptions{
+		Path: filepath.Join(t.TempDir(), "agent.db"),
+		Sync: SyncOptions{
+			URL:       "libsql://agents.example.com",
+			AuthToken: "s3cret",
+			Interval:  10 * time.Millisecond,
+			Connect: func(ctx context.Context, path string, opts SyncOptions) (Replica, error) {
+				if opts.AuthToken != "s3cret" {
+					t.Errorf("AuthToken = %q", opts.AuthToken)
+				}
+				db, err := sql.Open("sqlite", path)
+				replica = &fakeReplica{db: db}
+				return replica, err
+			},
+		},
+	}
+	afs, err := Open(ctx, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if n := replica.syncs.Load(); n != 1 {
+		t.Errorf("syncs after open = %d, want 1", n)
+	}
+
+	if err := afs.FS.WriteFile(ctx, "/notes.md", []byte("hi"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for replica.syncs.Load() < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := replica.syncs.Load(); n < 4 {
+		t.Errorf("background syncs did not run: %d syncs", n)
+	}
+
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !replica.closed {
+		t.Error("Close did not close the replica")
+	}
+	if err := afs.Sync(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Sync after Close = %v, want ErrClosed", err)
+	}
+
+	opts.Sync.Connect = nil
+	if _, err := Open(ctx, opts); err == nil {
+		t.Error("Open without Connect succeeded")
+	}
+
+	plain := setupTestDB(t)
+	defer plain.Close()
+	if err := plain.Sync(ctx); !errors.Is(err, ErrNotReplica) {
+		t.Errorf("Sync of a local database = %v, want ErrNotReplica", err)
+	}
+}
+
+func TestReplicaBackgroundSync(t *testing.T) {
+	ctx := context.Background()
+	errs := make(chan error, 100)
+	var replica *fakeReplica
+	afs, err := Open(ctx, AgentFSOptions{
+		Path: filepath.Join(t.TempDir(), "agent.db"),
+		Sync: SyncOptions{
+			URL:      "libsql://agents.example.com",
+			Interval: 5 * time.Millisecond,
+			OnError: func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			},
+			Connect: func(ctx context.Context, path string, opts SyncOptions) (Replica, error) {
+				db, err := sql.Open("sqlite", path)
+				replica = &fakeReplica{db: db, hanging: make(chan struct{})}
+				return replica, err
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	replica.fail.Store(true)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errPrimaryDown) {
+			t.Errorf("OnError got %v, want errPrimaryDown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnError was not called for a failed background sync")
+	}
+	replica.fail.Store(false)
+
+	// Close cancels a sync that does not return by itself
+	replica.hang.Store(true)
+	<-replica.hanging
+	closed := make(chan error, 1)
+	go func() { closed <- afs.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waited for a background sync")
+	}
+	for len(errs) > 0 {
+		if err := <-errs; !errors.Is(err, errPrimaryDown) {
+			t.Errorf("OnError got %v after Close", err)
+		}
+	}
+}
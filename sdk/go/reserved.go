@@ -0,0 +1,157 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ReservedDir holds data the SDK keeps inside the filesystem (trash,
+// snapshots, journals). It can be read like any directory, but only the
+// SDK may modify it.
+const ReservedDir = "/.agentfs"
+
+// SystemKeyPrefix marks KV keys reserved for the SDK. They can be read but
+// not set or deleted, and Clear leaves them in place.
+const SystemKeyPrefix = "sys:"
+
+// ErrReserved is returned when user code tries to modify the reserved
+// directory or a system key. Filesystem operations return an EPERM
+// *FSError that also matches ErrReserved with errors.Is.
+var ErrReserved = errors.New("agentfs: reserved for internal use")
+
+// reservedError is the EPERM *FSError returned for the reserved directory.
+type reservedError struct {
+	*FSError
+}
+
+func (e *reservedError) Unwrap() []error { return []error{e.FSError, ErrReserved} }
+
+// IsReserved reports whether p is ReservedDir or inside it.
+func IsReserved(p string) bool {
+	p = normalizePath(p)
+	return p == ReservedDir || strings.HasPrefix(p, ReservedDir+"/")
+}
+
+// IsSystemKey reports whether key is in the reserved KV namespace.
+func IsSystemKey(key string) bool {
+	return strings.HasPrefix(key, SystemKeyPrefix)
+}
+
+// noFollowOps are the operations that act on a symlink itself rather than
+// on its target, so a final symlink component is not followed when
+// checking them against the reserved directory.
+var noFollowOps = map[string]bool{
+	"unlink": true, "rmdir": true, "rename": true, "move": true, "link": true,
+	"symlink": true, "removeglob": true,
+}
+
+// checkWritable refuses op on the normalized path p if it is reserved, or
+// reaches the reserved directory through symlinks, and fs is not the SDK's
+// own view.
+func (fs *Filesystem) checkWritable(ctx context.Context, op, p string) error {
+	if fs.system {
+		return nil
+	}
+	if !IsReserved(p) {
+		real, err := fs.realPath(ctx, p, !noFollowOps[op])
+		if err != nil || !IsReserved(real) {
+			return err
+		}
+	}
+	return &reservedError{&FSError{Code: EPERM, Syscall: op, Path: p, Message: "reserved for internal use"}}
+}
+
+// systemFS returns a view of fs that may modify the reserved directory.
+// SDK features storing data there must write through it.
+func (fs *Filesystem) systemFS() *Filesystem {
+	sfs := *fs
+	sfs.system = true
+	sfs.coalesce = nil // Internal writes go through at once
+	return &sfs
+}
+
+// checkKey refuses to modify a system key unless kv is the SDK's own view,
+// and keys that would reach into a namespace.
+func (kv *KVStore) checkKey(key string) error {
+	if strings.Contains(key, kvNamespaceSep) {
+		return fmt.Errorf("key %q: must not contain a unit separator", key)
+	}
+	if kv.system || !IsSystemKey(key) {
+		return nil
+	}
+	return fmt.Errorf("key %q: %w", key, ErrReserved)
+}
+
+// systemKV returns a view of kv that may modify system keys.
+func (kv *KVStore) systemKV() *KVStore {
+	skv := *kv
+	skv.system = true
+	return &skv
+}
+
+// ReservedUsage summarizes the SDK's internal data.
+type ReservedUsage struct {
+	// Entries lists the top-level entries of ReservedDir.
+	Entries []ReservedEntry `json:"entries"`
+	// Keys is the number of system KV keys.
+	Keys int `json:"keys"`
+}
+
+// ReservedEntry is one top-level entry of ReservedDir.
+type ReservedEntry struct {
+	Name  string `json:"name"`
+	Files int64  `json:"files"` // Regular files at or below the entry
+	Bytes int64  `json:"bytes"` // Total size of those files
+}
+
+// ReservedUsage reports what the SDK stores in ReservedDir and the system
+// KV namespace, without modifying either.
+func (a *AgentFS) ReservedUsage(ctx context.Context) (*ReservedUsage, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	usage := &ReservedUsage{Entries: []ReservedEntry{}}
+
+	keys, err := a.KV.Keys(ctx, SystemKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	usage.Keys = len(keys)
+
+	entries, err := a.FS.ReaddirPlus(ctx, ReservedDir)
+	if IsNotExist(err) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		re := ReservedEntry{Name: e.Name}
+		if !e.Stats.IsDir() {
+			if e.Stats.IsRegularFile() {
+				re.Files, re.Bytes = 1, e.Stats.Size
+			}
+			usage.Entries = append(usage.Entries, re)
+			continue
+		}
+		err := a.FS.walk(ctx, path.Join(ReservedDir, e.Name), nil, func(p, rel string, stats *Stats) error {
+			if stats.IsRegularFile() {
+				re.Files++
+				re.Bytes += stats.Size
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		usage.Entries = append(usage.Entries, re)
+	}
+	return usage, nil
+}
@@ -0,0 +1,132 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReservedDir(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	sys := afs.FS.systemFS()
+	if err := sys.WriteFile(ctx, ReservedDir+"/trash/1/a.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("system WriteFile failed: %v", err)
+	}
+
+	writes := map[string]func() error{
+		"WriteFile": func() error { return afs.FS.WriteFile(ctx, ReservedDir+"/x", nil, 0o644) },
+		"Mkdir":     func() error { return afs.FS.Mkdir(ctx, ReservedDir+"/d", 0o755) },
+		"Unlink":    func() error { return afs.FS.Unlink(ctx, ReservedDir+"/trash/1/a.txt") },
+		"RenameIn":  func() error { return afs.FS.Rename(ctx, "/f", ReservedDir+"/f") },
+		"RenameOut": func() error { return afs.FS.Rename(ctx, ReservedDir+"/trash", "/trash") },
+		"Rmdir":     func() error { return afs.FS.Rmdir(ctx, ReservedDir) },
+		"OpenWrite": func() error {
+			_, err := afs.FS.Open(ctx, ReservedDir+"/trash/1/a.txt", O_RDWR)
+			return err
+		},
+	}
+	for name, fn := range writes {
+		err := fn()
+		if !errors.Is(err, ErrReserved) || !IsPermission(err) {
+			t.Errorf("%s: err = %v, want EPERM matching ErrReserved", name, err)
+		}
+	}
+
+	data, err := afs.FS.ReadFile(ctx, ReservedDir+"/trash/1/a.txt")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile = %q, %v; want reads to be allowed", data, err)
+	}
+
+	usage, err := afs.ReservedUsage(ctx)
+	if err != nil {
+		t.Fatalf("ReservedUsage failed: %v", err)
+	}
+	if len(usage.Entries) != 1 || usage.Entries[0] != (ReservedEntry{Name: "trash", Files: 1, Bytes: 5}) {
+		t.Errorf("Entries = %+v", usage.Entries)
+	}
+}
+
+func TestReservedDirThroughSymlink(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	sys := afs.FS.systemFS()
+	if err := sys.WriteFile(ctx, ReservedDir+"/trash/keep", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("system WriteFile failed: %v", err)
+	}
+	// Links into the reserved directory may come from other tools
+	if err := sys.Symlink(ctx, ReservedDir, "/x"); err != nil {
+		t.Fatalf("system Symlink failed: %v", err)
+	}
+	if err := sys.Symlink(ctx, ReservedDir+"/trash/keep", "/f"); err != nil {
+		t.Fatalf("system Symlink failed: %v", err)
+	}
+
+	writes := map[string]func() error{
+		"WriteFile":     func() error { return afs.FS.WriteFile(ctx, "/x/trash/keep", []byte("bye"), 0o644) },
+		"WriteFileLink": func() error { return afs.FS.WriteFile(ctx, "/f", []byte("bye"), 0o644) },
+		"Unlink":        func() error { return afs.FS.Unlink(ctx, "/x/trash/keep") },
+		"Mkdir":         func() error { return afs.FS.Mkdir(ctx, "/x/d", 0o755) },
+		"Symlink":       func() error { return afs.FS.Symlink(ctx, ReservedDir+"/trash", "/t") },
+		"SymlinkRel":    func() error { return afs.FS.Symlink(ctx, "../.agentfs", "/d/t") },
+		"SymlinkVia":    func() error { return afs.FS.Symlink(ctx, "/x/trash", "/t") },
+	}
+	for name, fn := range writes {
+		err := fn()
+		if !errors.Is(err, ErrReserved) || !IsPermission(err) {
+			t.Errorf("%s: err = %v, want EPERM matching ErrReserved", name, err)
+		}
+	}
+
+	data, err := afs.FS.ReadFile(ctx, "/x/trash/keep")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile = %q, %v; want the reserved file unchanged", data, err)
+	}
+	// The links themselves are ordinary files
+	if err := afs.FS.Unlink(ctx, "/f"); err != nil {
+		t.Errorf("Unlink of the link failed: %v", err)
+	}
+	if err := afs.FS.Mkdir(ctx, "/work", 0o755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := afs.FS.Symlink(ctx, "/work", "/w"); err != nil {
+		t.Errorf("Symlink to an ordinary directory failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/w/a.txt", []byte("ok"), 0o644); err != nil {
+		t.Errorf("WriteFile through an ordinary link failed: %v", err)
+	}
+}
+
+func TestSystemKeys(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.KV.systemKV().Set(ctx, "sys:journal", 1); err != nil {
+		t.Fatalf("system Set failed: %v", err)
+	}
+	afs.KV.Set(ctx, "user", 2)
+
+	if err := afs.KV.Set(ctx, "sys:journal", 0); !errors.Is(err, ErrReserved) {
+		t.Errorf("Set: err = %v, want ErrReserved", err)
+	}
+	if err := afs.KV.Delete(ctx, "sys:journal"); !errors.Is(err, ErrReserved) {
+		t.Errorf("Delete: err = %v, want ErrReserved", err)
+	}
+	err := afs.KV.Txn(ctx, func(tx KVTx) error { return tx.Set(ctx, "sys:journal", 0) })
+	if !errors.Is(err, ErrReserved) {
+		t.Errorf("Txn Set: err = %v, want ErrReserved", err)
+	}
+
+	if err := afs.KV.Clear(ctx, ""); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	keys, _ := afs.KV.Keys(ctx, "")
+	if len(keys) != 1 || keys[0] != "sys:journal" {
+		t.Errorf("keys after Clear = %v, want [sys:journal]", keys)
+	}
+}
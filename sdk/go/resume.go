@@ -0,0 +1,148 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResumePoint is the saved checkpoint of an interrupted operation.
+type ResumePoint struct {
+	// Token is the ResumeToken the operation was started with.
+	Token string
+	// Op names the operation, e.g. "import", "export", or "prune".
+	Op string
+	// Cursor is the last item completed; the operation resumes after it.
+	Cursor string
+	// Items and Bytes are the progress made so far.
+	Items int64
+	Bytes int64
+	// Updated is when the checkpoint was last saved.
+	Updated time.Time
+}
+
+// resumeState tracks the checkpoint of one resumable operation. A nil
+// *resumeState means the operation is not resumable and all methods are
+// no-ops.
+type resumeState struct {
+	token  string
+	op     string
+	params string
+	cursor string
+	clock  Clock
+}
+
+// loadResume returns the checkpoint saved under token, or a fresh state if
+// there is none. params identifies the operation's arguments; resuming with
+// different arguments is an error. An empty token disables checkpointing.
+func loadResume(ctx context.Context, db dbtx, clock Clock, token, op, params string, tracker *progressTracker) (*resumeState, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	st := &resumeState{token: token, op: op, params: params, clock: clock}
+	var savedOp, savedParams string
+	err := db.QueryRowContext(ctx, resumeGet, token).Scan(&savedOp, &savedParams, &st.cursor, &tracker.p.Items, &tracker.p.Bytes)
+	if err == sql.ErrNoRows {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load resume point: %w", err)
+	}
+	if savedOp != op || savedParams != params {
+		return nil, ErrInval(op, token, fmt.Sprintf("resume token belongs to a different %s operation", savedOp))
+	}
+	return st, nil
+}
+
+// skip reports whether the item at cursor position pos was completed before
+// the operation was interrupted.
+func (st *resumeState) skip(pos string) bool {
+	return st != nil && resumed(pos, st.cursor)
+}
+
+// resumed reports whether pos is at or before a non-empty cursor.
+func resumed(pos, cursor string) bool {
+	return cursor != "" && !cursorAfter(pos, cursor)
+}
+
+// save records that everything up to and including pos is complete.
+func (st *resumeState) save(ctx context.Context, db dbtx, pos string, p Progress) error {
+	if st == nil {
+		return nil
+	}
+	st.cursor = pos
+	if _, err := db.ExecContext(ctx, resumeSave, st.token, st.op, st.params, pos, p.Items, p.Bytes, st.clock.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to save resume point: %w", err)
+	}
+	return nil
+}
+
+// finish removes the checkpoint once the operation has completed.
+func (st *resumeState) finish(ctx context.Context, db dbtx) error {
+	if st == nil {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, resumeDelete, st.token); err != nil {
+		return fmt.Errorf("failed to clear resume point: %w", err)
+	}
+	return nil
+}
+
+// cursorAfter reports whether slash-separated path a comes after b in a
+// depth-first walk that visits names in byte order and parents before their
+// children.
+func cursorAfter(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] > bs[i]
+		}
+	}
+	return len(as) > len(bs)
+}
+
+// ResumePoints returns the checkpoints of interrupted operations, ordered by
+// token.
+func (a *AgentFS) ResumePoints(ctx context.Context) ([]ResumePoint, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := a.db.QueryContext(ctx, resumeList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resume points: %w", err)
+	}
+	defer rows.Close()
+
+	var points []ResumePoint
+	for rows.Next() {
+		var rp ResumePoint
+		var updated int64
+		if err := rows.Scan(&rp.Token, &rp.Op, &rp.Cursor, &rp.Items, &rp.Bytes, &updated); err != nil {
+			return nil, fmt.Errorf("failed to list resume points: %w", err)
+		}
+		rp.Updated = time.Unix(updated, 0)
+		points = append(points, rp)
+	}
+	return points, rows.Err()
+}
+
+// DiscardResumePoint deletes the checkpoint saved under token, so the next
+// operation started with it begins from scratch.
+func (a *AgentFS) DiscardResumePoint(ctx context.Context, token string) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if _, err := a.db.ExecContext(ctx, resumeDelete, token); err != nil {
+		return fmt.Errorf("failed to discard resume point: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCursorAfter(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"b", "a", true},
+		{"a", "b", false},
+		{"a", "a", false},
+		{"a/b", "a", true},     // children follow their parent
+		{"a.txt", "a/b", true}, // a's subtree comes before "a.txt"
+		{"a/b", "a.txt", false},
+		{"b", "a/z/z", true},
+	}
+	for _, tt := range tests {
+		if got := cursorAfter(tt.a, tt.b); got != tt.want {
+			t.Errorf("cursorAfter(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestResumeImport(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	src := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("d/f%d.txt", i)] = "v1"
+	}
+	writeHostTree(t, src, files)
+
+	errStop := errors.New("stop")
+	err := afs.FS.ImportDir(ctx, src, "/dst", &ImportOptions{
+		BatchSize:   3,
+		ResumeToken: "seed",
+		Progress: func(p Progress) error {
+			if p.Items == 6 {
+				return errStop
+			}
+			return nil
+		},
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("ImportDir err = %v, want errStop", err)
+	}
+
+	points, err := afs.ResumePoints(ctx)
+	if err != nil {
+		t.Fatalf("ResumePoints failed: %v", err)
+	}
+	if len(points) != 1 || points[0].Token != "seed" || points[0].Op != "import" || points[0].Items != 6 {
+		t.Fatalf("ResumePoints = %+v", points)
+	}
+
+	t.Run("token bound to operation", func(t *testing.T) {
+		err := afs.FS.ImportDir(ctx, t.TempDir(), "/other", &ImportOptions{ResumeToken: "seed"})
+		if err == nil {
+			t.Error("resuming with different arguments should fail")
+		}
+	})
+
+	// Changes to already imported files are not picked up on resume
+	writeHostTree(t, src, map[string]string{"d/f0.txt": "v2", "d/f9.txt": "v2"})
+
+	var last Progress
+	err = afs.FS.ImportDir(ctx, src, "/dst", &ImportOptions{
+		BatchSize:   3,
+		ResumeToken: "seed",
+		Progress: func(p Progress) error {
+			last = p
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("resumed ImportDir failed: %v", err)
+	}
+	if last.Items != 11 {
+		t.Errorf("final Items = %d, want 11", last.Items)
+	}
+
+	for name, want := range map[string]string{"d/f0.txt": "v1", "d/f5.txt": "v1", "d/f9.txt": "v2"} {
+		got, err := afs.FS.ReadFile(ctx, "/dst/"+name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	points, err = afs.ResumePoints(ctx)
+	if err != nil {
+		t.Fatalf("ResumePoints failed: %v", err)
+	}
+	if len(points) != 0 {
+		t.Errorf("ResumePoints after completion = %+v, want none", points)
+	}
+}
+
+func TestResumeExport(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	for i := 0; i < 4; i++ {
+		if err := afs.FS.WriteFile(ctx, fmt.Sprintf("/src/f%d.txt", i), []byte("x"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	out := t.TempDir()
+	errStop := errors.New("stop")
+	err := afs.FS.ExportDir(ctx, "/src", out, &ExportOptions{
+		ResumeToken: "out",
+		Progress: func(p Progress) error {
+			if p.Items == 2 {
+				return errStop
+			}
+			return nil
+		},
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("ExportDir err = %v, want errStop", err)
+	}
+
+	// Remove what was exported; a resumed export does not write it again
+	for _, name := range []string{"f0.txt", "f1.txt"} {
+		if err := os.Remove(filepath.Join(out, name)); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+	}
+	if err := afs.FS.ExportDir(ctx, "/src", out, &ExportOptions{ResumeToken: "out"}); err != nil {
+		t.Fatalf("resumed ExportDir failed: %v", err)
+	}
+
+	entries, _ := os.ReadDir(out)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if fmt.Sprint(names) != "[f2.txt f3.txt]" {
+		t.Errorf("exported = %v, want [f2.txt f3.txt]", names)
+	}
+
+	if err := afs.DiscardResumePoint(ctx, "out"); err != nil {
+		t.Fatalf("DiscardResumePoint failed: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// RecordRetry records a complete tool call like Record, as a retry of the
+// call originalID: it takes the original's name and the next attempt number
+// of its chain. originalID may be any attempt of the chain. Attempts
+// returns the chain, Query with CollapseRetries shows only its latest
+// attempt, and RetryStats reports how many retries calls needed.
+//
+// Example:
+//
+//	call, err := afs.Tools.Record(ctx, "fetch", params, nil, &msg, start, end)
+//	retry, err := afs.Tools.RecordRetry(ctx, call.ID, params, page, nil, start2, end2)
+func (tc *ToolCalls) RecordRetry(ctx context.Context, originalID int64, parameters, result any, errMsg *string, startedAt, completedAt int64) (*ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var call *ToolCall
+	err = tc.inTx(ctx, func(db dbtx) error {
+		var name string
+		var chain int64
+		var attempt int
+		err := db.QueryRowContext(ctx, toolCallRetryTarget, originalID).Scan(&name, &chain, &attempt)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("tool call not found: %d", originalID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to record retry: %w", err)
+		}
+		if call, err = insertToolCall(ctx, db, name, parameters, result, errMsg, startedAt, completedAt, tc.uids.next()); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, toolCallRetryInsert, call.ID, chain, attempt); err != nil {
+			return fmt.Errorf("failed to record retry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	tc.publish(Event{Kind: EventToolCallCompleted, Path: call.Name, ToolCall: call})
+	return call, nil
+}
+
+// Attempts returns the attempts of the retry chain of the tool call id, the
+// original first. A call never retried is its own only attempt.
+func (tc *ToolCalls) Attempts(ctx context.Context, id int64) ([]ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallAttempts, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attempts: %w", err)
+	}
+	defer rows.Close()
+
+	calls, err := scanToolCalls(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("tool call not found: %d", id)
+	}
+	return calls, nil
+}
+
+// RetryStats returns, for each tool, how its retry chains ended: how many
+// succeeded after each number of attempts, and how many still failed.
+func (tc *ToolCalls) RetryStats(ctx context.Context) ([]RetryStats, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallRetryStats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retry stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []RetryStats
+	for rows.Next() {
+		var name string
+		var attempt int
+		var ok bool
+		var n int64
+		if err := rows.Scan(&name, &attempt, &ok, &n); err != nil {
+			return nil, err
+		}
+		if len(stats) == 0 || stats[len(stats)-1].Name != name {
+			stats = append(stats, RetryStats{Name: name, SucceededAfter: map[int]int64{}})
+		}
+		s := &stats[len(stats)-1]
+		s.Chains += n
+		if ok {
+			s.SucceededAfter[attempt] += n
+		} else {
+			s.Failed += n
+		}
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,82 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestToolCallRetries(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	timeout := "timeout"
+	first, err := afs.Tools.Record(ctx, "fetch", map[string]string{"url": "a"}, nil, &timeout, 100, 101)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	second, err := afs.Tools.RecordRetry(ctx, first.ID, map[string]string{"url": "a"}, nil, &timeout, 102, 103)
+	if err != nil {
+		t.Fatalf("RecordRetry failed: %v", err)
+	}
+	// Retrying a retry extends the same chain
+	third, err := afs.Tools.RecordRetry(ctx, second.ID, map[string]string{"url": "a"}, "page", nil, 104, 105)
+	if err != nil {
+		t.Fatalf("RecordRetry failed: %v", err)
+	}
+	if third.Name != "fetch" {
+		t.Errorf("retry name = %q, want fetch", third.Name)
+	}
+	if _, err := afs.Tools.Record(ctx, "fetch", nil, "page", nil, 106, 107); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	lost, err := afs.Tools.Record(ctx, "shell", nil, nil, &timeout, 108, 109)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if _, err := afs.Tools.RecordRetry(ctx, lost.ID, nil, nil, &timeout, 110, 111); err != nil {
+		t.Fatalf("RecordRetry failed: %v", err)
+	}
+	if _, err := afs.Tools.RecordRetry(ctx, 999, nil, nil, nil, 0, 0); err == nil {
+		t.Error("RecordRetry of a missing call succeeded")
+	}
+
+	attempts, err := afs.Tools.Attempts(ctx, third.ID)
+	if err != nil {
+		t.Fatalf("Attempts failed: %v", err)
+	}
+	if len(attempts) != 3 || attempts[0].ID != first.ID || attempts[2].ID != third.ID {
+		t.Errorf("Attempts = %+v, want %d, %d, %d", attempts, first.ID, second.ID, third.ID)
+	}
+
+	all, err := afs.Tools.Query(ctx, ToolCallQuery{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	collapsed, err := afs.Tools.Query(ctx, ToolCallQuery{CollapseRetries: true})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(all.Calls) != 6 || len(collapsed.Calls) != 3 {
+		t.Fatalf("Query found %d calls, collapsed %d; want 6 and 3", len(all.Calls), len(collapsed.Calls))
+	}
+	for _, c := range collapsed.Calls {
+		if c.ID == first.ID || c.ID == second.ID || c.ID == lost.ID {
+			t.Errorf("collapsed Query includes superseded attempt %d", c.ID)
+		}
+	}
+
+	stats, err := afs.Tools.RetryStats(ctx)
+	if err != nil {
+		t.Fatalf("RetryStats failed: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("RetryStats = %+v, want fetch and shell", stats)
+	}
+	if s := stats[0]; s.Name != "fetch" || s.Chains != 2 || s.SucceededAfter[1] != 1 || s.SucceededAfter[3] != 1 || s.Failed != 0 {
+		t.Errorf("fetch stats = %+v", s)
+	}
+	if s := stats[1]; s.Name != "shell" || s.Chains != 1 || s.Failed != 1 || len(s.SucceededAfter) != 0 {
+		t.Errorf("shell stats = %+v", s)
+	}
+}
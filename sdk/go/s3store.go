@@ -0,0 +1,282 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options configures an S3Store.
+type S3Options struct {
+	// Endpoint is the base URL of the service, e.g.
+	// "https://s3.us-east-1.amazonaws.com", "https://storage.googleapis.com"
+	// (with HMAC keys), or a MinIO server.
+	Endpoint string
+
+	// Bucket is the bucket name. Requests use path-style addressing.
+	Bucket string
+
+	// Prefix is prepended to every object key, e.g. "agents/my-agent/".
+	Prefix string
+
+	// Region is used for request signing.
+	// Default: "us-east-1". GCS accepts "auto".
+	Region string
+
+	// AccessKeyID, SecretAccessKey, and SessionToken are the credentials.
+	// SessionToken is only needed for temporary credentials.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Client is the HTTP client used for requests.
+	// Default: http.DefaultClient.
+	Client *http.Client
+}
+
+// S3Store is a ChunkStore backed by an S3-compatible object store, including
+// AWS S3, Google Cloud Storage's XML API, and MinIO.
+type S3Store struct {
+	opts S3Options
+}
+
+// NewS3Store returns a ChunkStore that keeps chunks as objects in a bucket.
+func NewS3Store(opts S3Options) *S3Store {
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	opts.Endpoint = strings.TrimSuffix(opts.Endpoint, "/")
+	return &S3Store{opts: opts}
+}
+
+// Put implements ChunkStore.
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, s.opts.Prefix+key, nil, data)
+	if err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get implements ChunkStore.
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.opts.Prefix+key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	return data, nil
+}
+
+// Delete implements ChunkStore.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.opts.Prefix+key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete chunk: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the subset of a ListObjectsV2 response used by List.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List implements ChunkStore.
+func (s *S3Store) List(ctx context.Context, fn func(key string, modTime time.Time) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.opts.Prefix != "" {
+			query.Set("prefix", s.opts.Prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return fmt.Errorf("failed to list chunks: %w", err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to list chunks: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			if err := fn(strings.TrimPrefix(obj.Key, s.opts.Prefix), obj.LastModified); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request for an object key, or for the bucket when key
+// is empty, and returns the response if it succeeded.
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := "/" + s.opts.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u, err := url.Parse(s.opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = escapeS3Path(u.Path)
+	u.RawQuery = canonicalS3Query(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.opts.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.opts.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretAccessKey), date)
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// escapeS3Path URI-encodes each segment of p as required by SigV4.
+func escapeS3Path(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = escapeS3(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalS3Query encodes query sorted by key, as required by SigV4.
+func canonicalS3Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, escapeS3(k)+"="+escapeS3(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapeS3 percent-encodes everything except RFC 3986 unreserved characters.
+func escapeS3(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
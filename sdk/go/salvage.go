@@ -0,0 +1,301 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// salvageBatchSize is how many rows are copied per destination transaction.
+const salvageBatchSize = 500
+
+// salvageMaxSkip bounds how far past an unreadable row Salvage probes for
+// the next readable one before giving up on the rest of a table.
+const salvageMaxSkip = 1 << 20
+
+// SalvageReport summarizes a Salvage run.
+type SalvageReport struct {
+	Tables []SalvageTable `json:"tables"`
+}
+
+// SalvageTable reports how many rows of one table were copied and how many
+// read errors were skipped.
+type SalvageTable struct {
+	Name   string `json:"name"`
+	Copied int64  `json:"copied"`
+	Errors int64  `json:"errors"`
+	// Incomplete is set if the end of the table could not be reached.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// Lost reports whether any rows may have been lost.
+func (r *SalvageReport) Lost() bool {
+	for _, t := range r.Tables {
+		if t.Errors > 0 || t.Incomplete {
+			return true
+		}
+	}
+	return false
+}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns the problems it
+// reports. An empty result means the database is intact.
+func (a *AgentFS) IntegrityCheck(ctx context.Context) ([]string, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return pragmaCheck(ctx, a.db, "integrity_check")
+}
+
+// pragmaCheck runs a checking pragma (integrity_check or quick_check) and
+// returns its messages, excluding the single "ok" of a clean result.
+func pragmaCheck(ctx context.Context, db *sql.DB, pragma string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA "+pragma)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", pragma, err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", pragma, err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Salvage copies every readable row of this database into a fresh database
+// at dest, skipping rows that cannot be read. Use it when IntegrityCheck
+// reports corruption, e.g. after a power loss mid-write, then open dest in
+// place of the damaged database. dest must not exist.
+//
+// Example:
+//
+//	if problems, _ := afs.IntegrityCheck(ctx); len(problems) > 0 {
+//	    report, err := afs.Salvage(ctx, "/data/agent.repaired.db")
+//	    if report.Lost() {
+//	        log.Printf("some rows could not be recovered: %+v", report.Tables)
+//	    }
+//	}
+func (a *AgentFS) Salvage(ctx context.Context, dest string) (*SalvageReport, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return salvage(ctx, a.db, dest)
+}
+
+// SalvageFile is like AgentFS.Salvage but reads the database at src directly,
+// for databases too damaged to open with Open.
+func SalvageFile(ctx context.Context, src, dest string) (*SalvageReport, error) {
+	db, err := sql.Open("sqlite", src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	return salvage(ctx, db, dest)
+}
+
+func salvage(ctx context.Context, src *sql.DB, dest string) (*SalvageReport, error) {
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("salvage destination %s already exists", dest)
+	}
+
+	dst, err := Open(ctx, AgentFSOptions{Path: dest})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create salvage database: %w", err)
+	}
+	defer dst.Close()
+
+	tables, err := salvageTables(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SalvageReport{}
+	for _, t := range tables {
+		// Extension tables unknown to this version are recreated as-is
+		if _, err := dst.db.ExecContext(ctx, strings.Replace(t.sql, "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1)); err != nil {
+			return nil, fmt.Errorf("failed to create table %s: %w", t.name, err)
+		}
+		result, err := salvageTable(ctx, src, dst.db, t.name)
+		if err != nil {
+			return nil, err
+		}
+		report.Tables = append(report.Tables, result)
+	}
+
+	return report, nil
+}
+
+type salvageSource struct {
+	name string
+	sql  string
+}
+
+// salvageTables lists the user tables of src from the schema.
+func salvageTables(ctx context.Context, src *sql.DB) ([]salvageSource, error) {
+	rows, err := src.QueryContext(ctx,
+		`SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []salvageSource
+	for rows.Next() {
+		var t salvageSource
+		if err := rows.Scan(&t.name, &t.sql); err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// salvageTable copies the readable rows of one table in rowid order. After a
+// read error it probes forward with growing steps to find the next readable
+// row, so a single damaged page does not lose the rest of the table.
+func salvageTable(ctx context.Context, src, dst *sql.DB, table string) (SalvageTable, error) {
+	result := SalvageTable{Name: table}
+
+	cols, err := tableColumns(ctx, src, table)
+	if err != nil {
+		result.Errors++
+		result.Incomplete = true
+		return result, nil
+	}
+
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quoteIdent(c)
+	}
+	selectSQL := fmt.Sprintf("SELECT rowid, %s FROM %s WHERE rowid > ? ORDER BY rowid LIMIT %d",
+		strings.Join(quoted, ", "), quoteIdent(table), salvageBatchSize)
+	insertSQL := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		quoteIdent(table), strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+
+	after := int64(math.MinInt64)
+	skip := int64(1)
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		n, last, readErr, err := copyRows(ctx, src, dst, selectSQL, insertSQL, len(cols), after)
+		if err != nil {
+			return result, err
+		}
+		result.Copied += n
+		if readErr == nil && n == 0 {
+			return result, nil
+		}
+		if readErr == nil {
+			after, skip = last, 1
+			continue
+		}
+
+		// Unreadable row after last: probe past it
+		result.Errors++
+		if skip > salvageMaxSkip {
+			result.Incomplete = true
+			return result, nil
+		}
+		if n > 0 {
+			after = last
+		}
+		after += skip
+		skip *= 2
+	}
+}
+
+// copyRows copies one batch of rows with rowid > after. It returns the number
+// of rows copied, the last copied rowid, and any error reading the source;
+// rows read before a read error are still copied. err reports failures to
+// write the destination.
+func copyRows(ctx context.Context, src, dst *sql.DB, selectSQL, insertSQL string, ncols int, after int64) (n, last int64, readErr, err error) {
+	rows, err := src.QueryContext(ctx, selectSQL, after)
+	if err != nil {
+		return 0, after, err, nil
+	}
+	defer rows.Close()
+
+	var batch [][]any
+	last = after
+	for rows.Next() {
+		var rowid int64
+		values := make([]any, ncols)
+		dest := make([]any, ncols+1)
+		dest[0] = &rowid
+		for i := range values {
+			dest[i+1] = &values[i]
+		}
+		if readErr = rows.Scan(dest...); readErr != nil {
+			break
+		}
+		batch = append(batch, values)
+		last = rowid
+	}
+	if readErr == nil {
+		readErr = rows.Err()
+	}
+
+	if len(batch) > 0 {
+		tx, err := dst.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, after, nil, fmt.Errorf("failed to write salvaged rows: %w", err)
+		}
+		defer tx.Rollback()
+		for _, values := range batch {
+			if _, err := tx.ExecContext(ctx, insertSQL, values...); err != nil {
+				return 0, after, nil, fmt.Errorf("failed to write salvaged rows: %w", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, after, nil, fmt.Errorf("failed to write salvaged rows: %w", err)
+		}
+	}
+
+	return int64(len(batch)), last, readErr, nil
+}
+
+// tableColumns returns the column names of a table.
+func tableColumns(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+	if len(cols) == 0 && rows.Err() == nil {
+		return nil, fmt.Errorf("table %s has no columns", table)
+	}
+	return cols, rows.Err()
+}
+
+// quoteIdent quotes an SQL identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,78 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestIntegrityCheck(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	problems, err := afs.IntegrityCheck(ctx)
+	if err != nil {
+		t.Fatalf("IntegrityCheck failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("IntegrityCheck = %v, want none", problems)
+	}
+}
+
+func TestSalvage(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/docs/a.txt", make([]byte, 10000), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/docs/b.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.KV.Set(ctx, "state", map[string]int{"step": 3}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := afs.Tools.Record(ctx, "search", nil, "ok", nil, 1, 2); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "repaired.db")
+	report, err := afs.Salvage(ctx, dest)
+	if err != nil {
+		t.Fatalf("Salvage failed: %v", err)
+	}
+	if report.Lost() {
+		t.Errorf("healthy database reported lost rows: %+v", report.Tables)
+	}
+
+	repaired, err := Open(ctx, AgentFSOptions{Path: dest})
+	if err != nil {
+		t.Fatalf("Open repaired failed: %v", err)
+	}
+	defer repaired.Close()
+
+	data, err := repaired.FS.ReadFile(ctx, "/docs/b.txt")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	stats, err := repaired.FS.Stat(ctx, "/docs/a.txt")
+	if err != nil || stats.Size != 10000 {
+		t.Errorf("Stat = %+v, %v", stats, err)
+	}
+	var state map[string]int
+	if err := repaired.KV.Get(ctx, "state", &state); err != nil || state["step"] != 3 {
+		t.Errorf("KV.Get = %v, %v", state, err)
+	}
+	calls, err := repaired.Tools.GetByName(ctx, "search", 10)
+	if err != nil || len(calls) != 1 {
+		t.Errorf("GetByName = %d calls, %v", len(calls), err)
+	}
+
+	t.Run("existing destination", func(t *testing.T) {
+		if _, err := afs.Salvage(ctx, dest); err == nil {
+			t.Error("Salvage to an existing file should fail")
+		}
+	})
+}
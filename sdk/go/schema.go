@@ -81,6 +81,83 @@ const (
 	createToolCallsStartedAtIndex = `
 		CREATE INDEX IF NOT EXISTS idx_tool_calls_started_at ON tool_calls(started_at)`
 
+	// Tool call archives (see ToolCalls.Archive): each month's old calls
+	// move into a table of their own, listed here, and tool_call_history
+	// unions them with tool_calls so reads see every call.
+	createToolCallArchivesTable = `
+		CREATE TABLE IF NOT EXISTS tool_call_archives (
+			name TEXT PRIMARY KEY,
+			month TEXT NOT NULL
+		)`
+
+	// The view as first specified; migrateToolCallHistory rebuilds it over
+	// the uid column added by recordIDMigrations.
+	createToolCallHistoryView = `
+		CREATE VIEW IF NOT EXISTS tool_call_history AS
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms FROM tool_calls`
+
+	// In-progress tool calls (extension table; tool_calls stays insert-only)
+	createToolCallsPendingTable = `
+		CREATE TABLE IF NOT EXISTS tool_calls_pending (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			parameters TEXT,
+			started_at INTEGER NOT NULL,
+			heartbeat_at INTEGER NOT NULL,
+			owner TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'running',
+			tool_call_id INTEGER
+		)`
+
+	createToolCallsPendingStatusIndex = `
+		CREATE INDEX IF NOT EXISTS idx_tool_calls_pending_status ON tool_calls_pending(status, heartbeat_at)`
+
+	// Chunks stored outside SQLite (extension table; data lives in a blob store)
+	createFsDataExtTable = `
+		CREATE TABLE IF NOT EXISTS fs_data_ext (
+			ino INTEGER NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			tier TEXT NOT NULL DEFAULT 'local',
+			hash TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			PRIMARY KEY (ino, chunk_index)
+		)`
+
+	createFsDataExtHashIndex = `
+		CREATE INDEX IF NOT EXISTS idx_fs_data_ext_hash ON fs_data_ext(hash)`
+
+	// Chunk table of SQLStore (lives in the store's own database)
+	createChunkStoreTable = `
+		CREATE TABLE IF NOT EXISTS agentfs_chunks (
+			key TEXT PRIMARY KEY,
+			data BLOB NOT NULL,
+			created_at INTEGER NOT NULL
+		)`
+
+	// Checkpoints of resumable long-running operations (extension table)
+	createFsResumeTable = `
+		CREATE TABLE IF NOT EXISTS fs_resume (
+			token TEXT PRIMARY KEY,
+			op TEXT NOT NULL,
+			params TEXT NOT NULL,
+			cursor TEXT NOT NULL DEFAULT '',
+			items INTEGER NOT NULL DEFAULT 0,
+			bytes INTEGER NOT NULL DEFAULT 0,
+			updated_at INTEGER NOT NULL
+		)`
+
+	// Custom file metadata (extension table, separate from file content)
+	createFsMetaTable = `
+		CREATE TABLE IF NOT EXISTS fs_meta (
+			ino INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (ino, key)
+		)`
+
+	createFsMetaKeyIndex = `
+		CREATE INDEX IF NOT EXISTS idx_fs_meta_key_value ON fs_meta(key, value)`
+
 	// Overlay filesystem tables (optional)
 	createFsWhiteoutTable = `
 		CREATE TABLE IF NOT EXISTS fs_whiteout (
@@ -97,6 +174,319 @@ const (
 			delta_ino INTEGER PRIMARY KEY,
 			base_ino INTEGER NOT NULL
 		)`
+
+	createToolCallAnnotationsTable = `
+		CREATE TABLE IF NOT EXISTS tool_call_annotations (
+			tool_call_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			updated_at INTEGER NOT NULL,
+			PRIMARY KEY (tool_call_id, key)
+		)`
+
+	createToolCallAnnotationsKeyIndex = `
+		CREATE INDEX IF NOT EXISTS idx_tool_call_annotations_key ON tool_call_annotations(key, value)`
+
+	createToolCallFilesTable = `
+		CREATE TABLE IF NOT EXISTS tool_call_files (
+			tool_call_id INTEGER NOT NULL,
+			path TEXT NOT NULL,
+			PRIMARY KEY (tool_call_id, path)
+		)`
+
+	createToolCallUsageTable = `
+		CREATE TABLE IF NOT EXISTS tool_call_usage (
+			tool_call_id INTEGER PRIMARY KEY,
+			input_tokens INTEGER NOT NULL DEFAULT 0,
+			output_tokens INTEGER NOT NULL DEFAULT 0,
+			cost_usd REAL NOT NULL DEFAULT 0
+		)`
+
+	// Parents of tool calls (extension table; see ToolCalls.Tree). A child
+	// recorded while its parent is still running names the parent's pending
+	// ID until the parent is recorded too.
+	createToolCallSpansTable = `
+		CREATE TABLE IF NOT EXISTS tool_call_spans (
+			tool_call_id INTEGER PRIMARY KEY,
+			parent_id INTEGER,
+			parent_pending_id INTEGER
+		)`
+
+	createToolCallSpansParentIndex = `
+		CREATE INDEX IF NOT EXISTS idx_tool_call_spans_parent ON tool_call_spans(parent_id)`
+
+	// Retries of tool calls (extension table; see ToolCalls.RecordRetry).
+	// The original call of a chain is attempt 1 and has no row.
+	createToolCallRetriesTable = `
+		CREATE TABLE IF NOT EXISTS tool_call_retries (
+			tool_call_id INTEGER PRIMARY KEY,
+			original_id INTEGER NOT NULL,
+			attempt INTEGER NOT NULL
+		)`
+
+	createToolCallRetriesOriginalIndex = `
+		CREATE INDEX IF NOT EXISTS idx_tool_call_retries_original ON tool_call_retries(original_id, attempt)`
+
+	// Output of tool calls (extension table; see ToolCalls.AppendOutput),
+	// appended while a call runs and keyed by its pending ID; tool_call_id
+	// is set once the call is recorded.
+	createToolCallOutputTable = `
+		CREATE TABLE IF NOT EXISTS tool_call_output (
+			pending_id INTEGER NOT NULL,
+			seq INTEGER NOT NULL,
+			data BLOB NOT NULL,
+			tool_call_id INTEGER,
+			PRIMARY KEY (pending_id, seq)
+		)`
+
+	createToolCallOutputCallIndex = `
+		CREATE INDEX IF NOT EXISTS idx_tool_call_output_call ON tool_call_output(tool_call_id, seq)`
+
+	createSessionMessagesTable = `
+		CREATE TABLE IF NOT EXISTS session_messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			role TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		)`
+
+	createSessionMessagesIndex = `
+		CREATE INDEX IF NOT EXISTS idx_session_messages_session ON session_messages(session_id, id)`
+
+	createMailboxTable = `
+		CREATE TABLE IF NOT EXISTS mailbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipient TEXT NOT NULL,
+			sender TEXT NOT NULL,
+			body TEXT NOT NULL,
+			sent_at INTEGER NOT NULL,
+			visible_at INTEGER NOT NULL DEFAULT 0,
+			deliveries INTEGER NOT NULL DEFAULT 0
+		)`
+
+	createMailboxIndex = `
+		CREATE INDEX IF NOT EXISTS idx_mailbox_recipient ON mailbox(recipient, visible_at, id)`
+
+	createStreamRecordTable = `
+		CREATE TABLE IF NOT EXISTS stream_record (
+			stream TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			data BLOB NOT NULL,
+			written_at INTEGER NOT NULL,
+			closed INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (stream, seq)
+		)`
+
+	// kv_changelog records the changes this SDK makes to kv_store, for
+	// KVStore.Watch. It is not part of the specification: other SDKs
+	// neither write nor read it, and this SDK trims it.
+	createKvChangelogTable = `
+		CREATE TABLE IF NOT EXISTS kv_changelog (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			namespace TEXT NOT NULL,
+			key TEXT NOT NULL,
+			op TEXT NOT NULL,
+			old_value TEXT,
+			new_value TEXT,
+			changed_at INTEGER NOT NULL DEFAULT (unixepoch())
+		)`
+
+	// fs_changelog records filesystem changes for Filesystem.Watch.
+	// changed_at is in Unix milliseconds.
+	createFsChangelogTable = `
+		CREATE TABLE IF NOT EXISTS fs_changelog (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			path TEXT NOT NULL,
+			old_path TEXT NOT NULL DEFAULT '',
+			changed_at INTEGER NOT NULL
+		)`
+
+	createSinkDeliveryTable = `
+		CREATE TABLE IF NOT EXISTS sink_delivery (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			sink TEXT NOT NULL,
+			path TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at INTEGER NOT NULL,
+			last_error TEXT,
+			created_at INTEGER NOT NULL,
+			delivered_at INTEGER
+		)`
+
+	createSinkDeliveryPendingIndex = `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_sink_delivery_pending ON sink_delivery(sink, path)
+		WHERE status = 'pending'`
+
+	createStreamOffsetTable = `
+		CREATE TABLE IF NOT EXISTS stream_offset (
+			stream TEXT NOT NULL,
+			consumer TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL,
+			PRIMARY KEY (stream, consumer)
+		)`
+
+	createFsPreviewTable = `
+		CREATE TABLE IF NOT EXISTS fs_preview (
+			ino INTEGER NOT NULL,
+			size INTEGER NOT NULL,
+			mtime INTEGER NOT NULL,
+			mtime_nsec INTEGER NOT NULL,
+			file_size INTEGER NOT NULL,
+			content_type TEXT NOT NULL,
+			source_type TEXT NOT NULL,
+			data BLOB NOT NULL,
+			PRIMARY KEY (ino, size)
+		)`
+
+	// Growth accounting (see AgentFS.GrowthReport)
+	growthFiles = `
+		WITH RECURSIVE tree(ino, path) AS (
+			SELECT d.ino, '/' || d.name FROM fs_dentry d WHERE d.parent_ino = 1
+			UNION ALL
+			SELECT d.ino, tree.path || '/' || d.name
+			FROM fs_dentry d JOIN tree ON d.parent_ino = tree.ino
+		)
+		SELECT t.path, i.size FROM tree t JOIN fs_inode i ON i.ino = t.ino
+		WHERE (i.mode & 61440) = 32768 AND i.size > 0 AND max(i.mtime, i.ctime) >= ?`
+
+	growthKV = `
+		SELECT CASE WHEN namespace != '' THEN namespace
+			WHEN instr(key, ':') > 0 THEN substr(key, 1, instr(key, ':') - 1) ELSE key END,
+			SUM(length(CAST(key AS BLOB)) + length(CAST(value AS BLOB))), COUNT(*)
+		FROM kv_store WHERE updated_at >= ? GROUP BY 1`
+
+	growthTools = `
+		SELECT name, SUM(COALESCE(length(CAST(parameters AS BLOB)), 0) + COALESCE(length(CAST(result AS BLOB)), 0)
+			+ COALESCE(length(CAST(error AS BLOB)), 0)), COUNT(*)
+		FROM tool_calls WHERE started_at >= ? GROUP BY name`
+
+	// Snapshots (see AgentFS.Snapshot). Chunk data is stored once per
+	// distinct content and shared by every snapshot that contains it.
+	createFsSnapshotTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot (
+			name TEXT PRIMARY KEY,
+			created_at INTEGER NOT NULL,
+			max_tool_call_id INTEGER NOT NULL
+		)`
+
+	createFsSnapshotInodeTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_inode (
+			snapshot TEXT NOT NULL,
+			ino INTEGER NOT NULL,
+			mode INTEGER NOT NULL,
+			nlink INTEGER NOT NULL,
+			uid INTEGER NOT NULL,
+			gid INTEGER NOT NULL,
+			size INTEGER NOT NULL,
+			atime INTEGER NOT NULL,
+			mtime INTEGER NOT NULL,
+			ctime INTEGER NOT NULL,
+			rdev INTEGER NOT NULL,
+			atime_nsec INTEGER NOT NULL,
+			mtime_nsec INTEGER NOT NULL,
+			ctime_nsec INTEGER NOT NULL,
+			PRIMARY KEY (snapshot, ino)
+		)`
+
+	createFsSnapshotDentryTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_dentry (
+			snapshot TEXT NOT NULL,
+			id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			parent_ino INTEGER NOT NULL,
+			ino INTEGER NOT NULL,
+			PRIMARY KEY (snapshot, id)
+		)`
+
+	createFsSnapshotSymlinkTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_symlink (
+			snapshot TEXT NOT NULL,
+			ino INTEGER NOT NULL,
+			target TEXT NOT NULL,
+			PRIMARY KEY (snapshot, ino)
+		)`
+
+	createFsSnapshotChunkTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_chunk (
+			hash TEXT PRIMARY KEY,
+			data BLOB NOT NULL
+		)`
+
+	createFsSnapshotDataTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_data (
+			snapshot TEXT NOT NULL,
+			ino INTEGER NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			hash TEXT NOT NULL,
+			PRIMARY KEY (snapshot, ino, chunk_index)
+		)`
+
+	createFsSnapshotDataHashIndex = `
+		CREATE INDEX IF NOT EXISTS idx_fs_snapshot_data_hash ON fs_snapshot_data(hash)`
+
+	createFsSnapshotDataExtTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_data_ext (
+			snapshot TEXT NOT NULL,
+			ino INTEGER NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			tier TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			PRIMARY KEY (snapshot, ino, chunk_index)
+		)`
+
+	createFsSnapshotMetaTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_meta (
+			snapshot TEXT NOT NULL,
+			ino INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (snapshot, ino, key)
+		)`
+
+	createFsSnapshotKvTable = `
+		CREATE TABLE IF NOT EXISTS fs_snapshot_kv (
+			snapshot TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			created_at INTEGER,
+			updated_at INTEGER,
+			PRIMARY KEY (snapshot, key)
+		)`
+
+	createFsVersionTable = `
+		CREATE TABLE IF NOT EXISTS fs_version (
+			path TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			size INTEGER NOT NULL,
+			mode INTEGER NOT NULL,
+			mtime INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (path, version)
+		)`
+
+	createFsVersionDataTable = `
+		CREATE TABLE IF NOT EXISTS fs_version_data (
+			path TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			hash TEXT NOT NULL,
+			PRIMARY KEY (path, version, chunk_index)
+		)`
+
+	createFsVersionDataHashIndex = `
+		CREATE INDEX IF NOT EXISTS idx_fs_version_data_hash ON fs_version_data(hash)`
+
+	createFsEditLockTable = `
+		CREATE TABLE IF NOT EXISTS fs_edit_lock (
+			ino INTEGER PRIMARY KEY,
+			session TEXT NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`
 )
 
 // allSchemaStatements returns all schema creation statements in order
@@ -113,9 +503,53 @@ func allSchemaStatements() []string {
 		createToolCallsTable,
 		createToolCallsNameIndex,
 		createToolCallsStartedAtIndex,
+		createToolCallArchivesTable,
+		createToolCallHistoryView,
+		createToolCallsPendingTable,
+		createToolCallsPendingStatusIndex,
+		createFsDataExtTable,
+		createFsDataExtHashIndex,
+		createFsResumeTable,
+		createFsMetaTable,
+		createFsMetaKeyIndex,
 		createFsWhiteoutTable,
 		createFsWhiteoutIndex,
 		createFsOriginTable,
+		createFsEditLockTable,
+		createToolCallAnnotationsTable,
+		createToolCallAnnotationsKeyIndex,
+		createToolCallFilesTable,
+		createToolCallUsageTable,
+		createToolCallSpansTable,
+		createToolCallSpansParentIndex,
+		createToolCallRetriesTable,
+		createToolCallRetriesOriginalIndex,
+		createToolCallOutputTable,
+		createToolCallOutputCallIndex,
+		createSessionMessagesTable,
+		createSessionMessagesIndex,
+		createMailboxTable,
+		createMailboxIndex,
+		createStreamRecordTable,
+		createStreamOffsetTable,
+		createSinkDeliveryTable,
+		createSinkDeliveryPendingIndex,
+		createKvChangelogTable,
+		createFsChangelogTable,
+		createFsPreviewTable,
+		createFsSnapshotTable,
+		createFsSnapshotInodeTable,
+		createFsSnapshotDentryTable,
+		createFsSnapshotSymlinkTable,
+		createFsSnapshotChunkTable,
+		createFsSnapshotDataTable,
+		createFsSnapshotDataHashIndex,
+		createFsSnapshotDataExtTable,
+		createFsSnapshotMetaTable,
+		createFsSnapshotKvTable,
+		createFsVersionTable,
+		createFsVersionDataTable,
+		createFsVersionDataHashIndex,
 	}
 }
 
@@ -125,8 +559,90 @@ const (
 	migrateAddAtimeNsec = `ALTER TABLE fs_inode ADD COLUMN atime_nsec INTEGER NOT NULL DEFAULT 0`
 	migrateAddMtimeNsec = `ALTER TABLE fs_inode ADD COLUMN mtime_nsec INTEGER NOT NULL DEFAULT 0`
 	migrateAddCtimeNsec = `ALTER TABLE fs_inode ADD COLUMN ctime_nsec INTEGER NOT NULL DEFAULT 0`
+
+	migrateAddExtTier = `ALTER TABLE fs_data_ext ADD COLUMN tier TEXT NOT NULL DEFAULT 'local'`
+
+	migrateAddVersionChunkSize = `ALTER TABLE fs_version ADD COLUMN chunk_size INTEGER NOT NULL DEFAULT 0`
+
+	migrateAddPendingParentID        = `ALTER TABLE tool_calls_pending ADD COLUMN parent_id INTEGER`
+	migrateAddPendingParentPendingID = `ALTER TABLE tool_calls_pending ADD COLUMN parent_pending_id INTEGER`
+	migrateAddPendingLastSeen        = `ALTER TABLE tool_calls_pending ADD COLUMN last_seen INTEGER`
+
+	migrateAddKvNamespace         = `ALTER TABLE kv_store ADD COLUMN namespace TEXT NOT NULL DEFAULT ''`
+	migrateAddSnapshotKvNamespace = `ALTER TABLE fs_snapshot_kv ADD COLUMN namespace TEXT NOT NULL DEFAULT ''`
+	migrateKvNamespaceIndex       = `CREATE INDEX IF NOT EXISTS idx_kv_store_namespace ON kv_store(namespace, key)`
+
+	migrateAddKvExpiresAt         = `ALTER TABLE kv_store ADD COLUMN expires_at INTEGER`
+	migrateAddSnapshotKvExpiresAt = `ALTER TABLE fs_snapshot_kv ADD COLUMN expires_at INTEGER`
+	migrateKvExpiresAtIndex       = `CREATE INDEX IF NOT EXISTS idx_kv_store_expires_at ON kv_store(expires_at) WHERE expires_at IS NOT NULL`
+
+	migrateAddToolCallUID   = `ALTER TABLE tool_calls ADD COLUMN uid TEXT`
+	migrateToolCallUIDIndex = `CREATE UNIQUE INDEX IF NOT EXISTS idx_tool_calls_uid ON tool_calls(uid) WHERE uid IS NOT NULL`
+	migrateAddSnapshotUID   = `ALTER TABLE fs_snapshot ADD COLUMN uid TEXT`
+
+	migrateAddKvTypeTag         = `ALTER TABLE kv_store ADD COLUMN type_tag TEXT`
+	migrateAddSnapshotKvTypeTag = `ALTER TABLE fs_snapshot_kv ADD COLUMN type_tag TEXT`
+
+	// kv_changelog was once filled by triggers on kv_store, which also fired
+	// for the writes of other SDKs; this SDK now records its own changes.
+	migrateDropKvChangelogInsert = `DROP TRIGGER IF EXISTS kv_changelog_insert`
+	migrateDropKvChangelogUpdate = `DROP TRIGGER IF EXISTS kv_changelog_update`
+	migrateDropKvChangelogDelete = `DROP TRIGGER IF EXISTS kv_changelog_delete`
 )
 
+// toolCallMigrations adds the columns introduced after tool_calls_pending
+// was specified (see PendingCall.Start)
+func toolCallMigrations() []string {
+	return []string{
+		migrateAddPendingParentID,
+		migrateAddPendingParentPendingID,
+		migrateAddPendingLastSeen,
+	}
+}
+
+// recordIDMigrations adds the columns holding global IDs (see
+// AgentFSOptions.IDStrategy)
+func recordIDMigrations() []string {
+	return []string{
+		migrateAddToolCallUID,
+		migrateToolCallUIDIndex,
+		migrateAddSnapshotUID,
+	}
+}
+
+// kvMigrations adds the columns introduced after kv_store was specified
+// (see KVStore.Namespace, KVStore.SetWithTTL, KVSetAs, and KVStore.Watch)
+func kvMigrations() []string {
+	return []string{
+		migrateAddKvNamespace,
+		migrateAddSnapshotKvNamespace,
+		migrateKvNamespaceIndex,
+		migrateAddKvExpiresAt,
+		migrateAddSnapshotKvExpiresAt,
+		migrateKvExpiresAtIndex,
+		migrateAddKvTypeTag,
+		migrateAddSnapshotKvTypeTag,
+		migrateDropKvChangelogInsert,
+		migrateDropKvChangelogUpdate,
+		migrateDropKvChangelogDelete,
+	}
+}
+
+// extChunkMigrations adds columns introduced after fs_data_ext was created
+func extChunkMigrations() []string {
+	return []string{
+		migrateAddExtTier,
+	}
+}
+
+// versionMigrations adds the columns introduced after fs_version was
+// specified (see Filesystem.SetChunkSize)
+func versionMigrations() []string {
+	return []string{
+		migrateAddVersionChunkSize,
+	}
+}
+
 // nsecMigrations returns the nanosecond column migration statements
 func nsecMigrations() []string {
 	return []string{
@@ -141,18 +657,52 @@ const (
 	initFsConfig = `
 		INSERT OR IGNORE INTO fs_config (key, value) VALUES ('chunk_size', ?)`
 
+	initTemplateApplied = `
+		INSERT OR IGNORE INTO fs_config (key, value) VALUES ('template_applied', '1')`
+
 	initSchemaVersion = `
 		INSERT OR IGNORE INTO fs_config (key, value) VALUES ('schema_version', ?)`
 
 	getSchemaVersion = `
 		SELECT value FROM fs_config WHERE key = 'schema_version'`
 
+	featureRecord = `
+		INSERT OR IGNORE INTO fs_config (key, value) VALUES (?, '1')`
+
+	featureList = `
+		SELECT key FROM fs_config WHERE key LIKE ? ESCAPE '\' ORDER BY key`
+
 	initRootInode = `
 		INSERT OR IGNORE INTO fs_inode (ino, mode, nlink, uid, gid, size, atime, mtime, ctime)
-		VALUES (1, ?, 1, 0, 0, 0, unixepoch(), unixepoch(), unixepoch())`
+		VALUES (1, ?, 1, 0, 0, 0, ?, ?, ?)`
 
 	getChunkSize = `
 		SELECT value FROM fs_config WHERE key = 'chunk_size'`
+
+	// Policy (see AgentFS.SetPolicy), stored as JSON
+	policyGet = `
+		SELECT value FROM fs_config WHERE key = 'policy'`
+
+	policySet = `
+		INSERT INTO fs_config (key, value) VALUES ('policy', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`
+
+	// quotaUsage returns the total size of regular files and the size of
+	// one inode (0 if it does not exist).
+	quotaUsage = `
+		SELECT COALESCE(SUM(size), 0), COALESCE((SELECT size FROM fs_inode WHERE ino = ?), 0)
+		FROM fs_inode WHERE (mode & 61440) = 32768`
+
+	// Retention (see AgentFS.EnforcePolicy); parameter is the cutoff in
+	// Unix seconds
+	toolCallsExpire           = `DELETE FROM tool_calls WHERE completed_at < ?`
+	toolCallAnnotationsExpire = `DELETE FROM tool_call_annotations WHERE tool_call_id IN (SELECT id FROM tool_call_history WHERE completed_at < ?)`
+	toolCallFilesExpire       = `DELETE FROM tool_call_files WHERE tool_call_id IN (SELECT id FROM tool_call_history WHERE completed_at < ?)`
+	toolCallUsageExpire       = `DELETE FROM tool_call_usage WHERE tool_call_id IN (SELECT id FROM tool_call_history WHERE completed_at < ?)`
+	toolCallSpansExpire       = `DELETE FROM tool_call_spans WHERE tool_call_id IN (SELECT id FROM tool_call_history WHERE completed_at < ?)`
+	toolCallRetriesExpire     = `DELETE FROM tool_call_retries WHERE tool_call_id IN (SELECT id FROM tool_call_history WHERE completed_at < ?)`
+	toolCallOutputExpire      = `DELETE FROM tool_call_output WHERE tool_call_id IN (SELECT id FROM tool_call_history WHERE completed_at < ?)`
+	messagesExpire            = `DELETE FROM session_messages WHERE created_at < ?`
 )
 
 // Filesystem queries
@@ -167,6 +717,9 @@ const (
 		JOIN fs_inode i ON d.ino = i.ino
 		WHERE d.parent_ino = ? AND d.name = ?`
 
+	queryParentIno = `
+		SELECT parent_ino FROM fs_dentry WHERE ino = ? LIMIT 1`
+
 	// Inode operations
 	queryInodeByIno = `
 		SELECT ino, mode, nlink, uid, gid, size, atime, mtime, ctime, rdev,
@@ -193,6 +746,9 @@ const (
 	updateInodeMode = `
 		UPDATE fs_inode SET mode = ?, ctime = ?, ctime_nsec = ? WHERE ino = ?`
 
+	updateInodeCtime = `
+		UPDATE fs_inode SET ctime = ?, ctime_nsec = ? WHERE ino = ?`
+
 	incrementNlink = `
 		UPDATE fs_inode SET nlink = nlink + 1 WHERE ino = ?`
 
@@ -220,8 +776,24 @@ const (
 		WHERE d.parent_ino = ?
 		ORDER BY d.name ASC`
 
-	countDentriesByParent = `
-		SELECT COUNT(*) FROM fs_dentry WHERE parent_ino = ?`
+	// Seeks the (parent_ino, name) index, so pages of huge directories
+	// cost the same wherever they start
+	queryDentriesPlusPage = `
+		SELECT d.name, i.ino, i.mode, i.nlink, i.uid, i.gid, i.size, i.atime, i.mtime, i.ctime, i.rdev,
+		       i.atime_nsec, i.mtime_nsec, i.ctime_nsec
+		FROM fs_dentry d
+		JOIN fs_inode i ON d.ino = i.ino
+		WHERE d.parent_ino = ? AND d.name > ?
+		ORDER BY d.name ASC
+		LIMIT ?`
+
+	countDentries = `SELECT COUNT(*) FROM fs_dentry`
+
+	queryAllDentryNames = `SELECT parent_ino, name FROM fs_dentry`
+
+	// Stops at the first entry instead of counting them all
+	hasDentriesByParent = `
+		SELECT EXISTS (SELECT 1 FROM fs_dentry WHERE parent_ino = ?)`
 
 	updateDentryParent = `
 		UPDATE fs_dentry SET parent_ino = ?, name = ? WHERE parent_ino = ? AND name = ?`
@@ -230,20 +802,95 @@ const (
 	insertChunk = `
 		INSERT OR REPLACE INTO fs_data (ino, chunk_index, data) VALUES (?, ?, ?)`
 
-	queryChunksByIno = `
-		SELECT data FROM fs_data WHERE ino = ? ORDER BY chunk_index ASC`
-
 	queryChunkRange = `
 		SELECT chunk_index, data FROM fs_data
 		WHERE ino = ? AND chunk_index >= ? AND chunk_index <= ?
 		ORDER BY chunk_index ASC`
 
-	deleteChunksByIno = `
-		DELETE FROM fs_data WHERE ino = ?`
-
 	deleteChunksFromIndex = `
 		DELETE FROM fs_data WHERE ino = ? AND chunk_index >= ?`
 
+	deleteChunkAt = `
+		DELETE FROM fs_data WHERE ino = ? AND chunk_index = ?`
+
+	// External chunk operations
+	extChunkInsert = `
+		INSERT OR REPLACE INTO fs_data_ext (ino, chunk_index, tier, hash, size) VALUES (?, ?, ?, ?, ?)`
+
+	extChunkDeleteAt = `
+		DELETE FROM fs_data_ext WHERE ino = ? AND chunk_index = ?`
+
+	extChunksDeleteFromIndex = `
+		DELETE FROM fs_data_ext WHERE ino = ? AND chunk_index >= ?`
+
+	extChunkKeysByIno = `
+		SELECT hash FROM fs_data_ext WHERE ino = ?`
+
+	pinnedInodes = `
+		SELECT ino FROM fs_meta WHERE key = '` + PinnedMetaKey + `'`
+
+	extChunksExist = `
+		SELECT EXISTS (SELECT 1 FROM fs_data_ext)`
+
+	// Chunks kept by snapshots stay referenced
+	extChunkHashes = `
+		SELECT tier, hash FROM fs_data_ext
+		UNION SELECT tier, hash FROM fs_snapshot_data_ext`
+
+	// Databases sharing the external stores of this one (see Fork) are
+	// recorded as fs_config keys "blob_share:<path>"
+	blobShareAdd = `
+		INSERT OR IGNORE INTO fs_config (key, value) VALUES ('blob_share:' || ?, '1')`
+
+	blobShareList = `
+		SELECT substr(key, 12) FROM fs_config WHERE key LIKE 'blob\_share:%' ESCAPE '\'`
+
+	extChunkTiers = `
+		SELECT tier FROM fs_data_ext
+		UNION SELECT tier FROM fs_snapshot_data_ext`
+
+	// SQLStore operations
+	chunkStorePut = `
+		INSERT OR IGNORE INTO agentfs_chunks (key, data, created_at) VALUES (?, ?, unixepoch())`
+
+	chunkStoreGet = `
+		SELECT data FROM agentfs_chunks WHERE key = ?`
+
+	chunkStoreDelete = `
+		DELETE FROM agentfs_chunks WHERE key = ?`
+
+	chunkStoreList = `
+		SELECT key, created_at FROM agentfs_chunks ORDER BY key ASC`
+
+	// Resume checkpoint operations
+	resumeGet = `
+		SELECT op, params, cursor, items, bytes FROM fs_resume WHERE token = ?`
+
+	resumeSave = `
+		INSERT INTO fs_resume (token, op, params, cursor, items, bytes, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(token) DO UPDATE SET
+			cursor = excluded.cursor,
+			items = excluded.items,
+			bytes = excluded.bytes,
+			updated_at = excluded.updated_at`
+
+	resumeDelete = `
+		DELETE FROM fs_resume WHERE token = ?`
+
+	resumeList = `
+		SELECT token, op, cursor, items, bytes, updated_at FROM fs_resume ORDER BY token ASC`
+
+	// queryChunkRangeWithExt is queryChunkRange including external chunks,
+	// which are returned with a NULL data column and their tier and hash.
+	queryChunkRangeWithExt = `
+		SELECT chunk_index, data, NULL, NULL FROM fs_data
+		WHERE ino = ?1 AND chunk_index >= ?2 AND chunk_index <= ?3
+		UNION ALL
+		SELECT chunk_index, NULL, tier, hash FROM fs_data_ext
+		WHERE ino = ?1 AND chunk_index >= ?2 AND chunk_index <= ?3
+		ORDER BY 1 ASC`
+
 	// Symlink operations
 	insertSymlink = `
 		INSERT INTO fs_symlink (ino, target) VALUES (?, ?)`
@@ -264,64 +911,429 @@ const (
 
 // Key-value store queries
 const (
+	// kvSet also resets created_at when it replaces an expired entry. ?7 is
+	// the current time in Unix milliseconds and ?8 the type tag (see KVSetAs).
 	kvSet = `
-		INSERT INTO kv_store (key, value, updated_at)
-		VALUES (?, ?, unixepoch())
+		INSERT INTO kv_store (namespace, key, value, created_at, updated_at, expires_at, type_tag)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?8)
 		ON CONFLICT(key) DO UPDATE SET
 			value = excluded.value,
-			updated_at = unixepoch()`
+			created_at = CASE WHEN kv_store.expires_at <= ?7 THEN excluded.created_at ELSE kv_store.created_at END,
+			updated_at = excluded.updated_at,
+			expires_at = excluded.expires_at,
+			type_tag = excluded.type_tag`
 
+	// Reads take the current time in Unix milliseconds and skip expired
+	// entries, which the reaper removes later (see KVStore.SetWithTTL).
 	kvGet = `
-		SELECT value FROM kv_store WHERE key = ?`
+		SELECT value FROM kv_store WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`
+
+	// kvGetCached also returns the expiry in Unix milliseconds, 0 if none,
+	// so the KV cache stops serving the value when it expires.
+	kvGetCached = `
+		SELECT value, COALESCE(expires_at, 0) FROM kv_store WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`
+
+	kvGetTyped = `
+		SELECT value, COALESCE(type_tag, '') FROM kv_store WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`
 
 	kvDelete = `
 		DELETE FROM kv_store WHERE key = ?`
 
 	kvHas = `
-		SELECT 1 FROM kv_store WHERE key = ? LIMIT 1`
+		SELECT 1 FROM kv_store WHERE key = ? AND (expires_at IS NULL OR expires_at > ?) LIMIT 1`
 
 	kvKeys = `
-		SELECT key FROM kv_store ORDER BY key ASC`
+		SELECT key FROM kv_store WHERE namespace = ? AND (expires_at IS NULL OR expires_at > ?) ORDER BY key ASC`
 
 	kvKeysWithPrefix = `
-		SELECT key FROM kv_store WHERE key LIKE ? ESCAPE '\' ORDER BY key ASC`
+		SELECT key FROM kv_store WHERE namespace = ? AND (expires_at IS NULL OR expires_at > ?) AND key LIKE ? ESCAPE '\'
+		ORDER BY key ASC`
 
 	kvList = `
-		SELECT key, created_at, updated_at FROM kv_store ORDER BY key ASC`
+		SELECT key, created_at, updated_at, COALESCE(expires_at, 0), COALESCE(type_tag, '') FROM kv_store
+		WHERE namespace = ? AND (expires_at IS NULL OR expires_at > ?) ORDER BY key ASC`
 
 	kvListWithPrefix = `
-		SELECT key, created_at, updated_at FROM kv_store WHERE key LIKE ? ESCAPE '\' ORDER BY key ASC`
+		SELECT key, created_at, updated_at, COALESCE(expires_at, 0), COALESCE(type_tag, '') FROM kv_store
+		WHERE namespace = ? AND (expires_at IS NULL OR expires_at > ?) AND key LIKE ? ESCAPE '\' ORDER BY key ASC`
+
+	kvCount = `
+		SELECT COUNT(*) FROM kv_store WHERE namespace = ? AND (expires_at IS NULL OR expires_at > ?)`
+
+	kvNamespaces = `
+		SELECT DISTINCT namespace FROM kv_store WHERE namespace != '' ORDER BY namespace`
+
+	kvSnapshot = `
+		SELECT namespace, key, value, updated_at FROM kv_store
+		WHERE substr(key, 1, 4) != 'sys:' AND (expires_at IS NULL OR expires_at > ?) ORDER BY namespace, key`
 
 	kvClear = `
-		DELETE FROM kv_store`
+		DELETE FROM kv_store WHERE namespace = ? AND substr(key, 1, 4) != 'sys:'`
 
 	kvClearWithPrefix = `
-		DELETE FROM kv_store WHERE key LIKE ? ESCAPE '\'`
+		DELETE FROM kv_store WHERE namespace = ? AND key LIKE ? ESCAPE '\' AND substr(key, 1, 4) != 'sys:'`
+
+	// kvIncr adds ?3 to an integer value, starting from 0 for a missing or
+	// expired key; it keeps a live key's expiry. It returns no row if the
+	// value is not an integer.
+	kvIncr = `
+		INSERT INTO kv_store (namespace, key, value, created_at, updated_at)
+		VALUES (?1, ?2, CAST(?3 AS TEXT), ?4, ?4)
+		ON CONFLICT(key) DO UPDATE SET
+			value = CAST(CASE WHEN kv_store.expires_at <= ?5 THEN ?3 ELSE CAST(kv_store.value AS INTEGER) + ?3 END AS TEXT),
+			created_at = CASE WHEN kv_store.expires_at <= ?5 THEN ?4 ELSE kv_store.created_at END,
+			updated_at = ?4,
+			expires_at = CASE WHEN kv_store.expires_at <= ?5 THEN NULL ELSE kv_store.expires_at END,
+			type_tag = CASE WHEN kv_store.expires_at <= ?5 THEN NULL ELSE kv_store.type_tag END
+		WHERE kv_store.expires_at <= ?5 OR json_type(kv_store.value) = 'integer'
+		RETURNING CAST(value AS INTEGER)`
+
+	kvCompareAndSwap = `
+		UPDATE kv_store SET value = ?1, updated_at = ?2, expires_at = NULL, type_tag = NULL
+		WHERE key = ?3 AND (expires_at IS NULL OR expires_at > ?4) AND json(value) = json(?5)`
+
+	// kvSetIfAbsent stores a key that is missing or expired.
+	kvSetIfAbsent = `
+		INSERT INTO kv_store (namespace, key, value, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?4)
+		ON CONFLICT(key) DO UPDATE SET
+			value = excluded.value,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			expires_at = NULL,
+			type_tag = NULL
+		WHERE kv_store.expires_at <= ?5`
+
+	kvTouch = `
+		UPDATE kv_store SET expires_at = ? WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`
+
+	kvReap = `
+		DELETE FROM kv_store WHERE expires_at <= ? RETURNING namespace, key`
+
+	kvChangeHead = `
+		SELECT COALESCE(MAX(seq), 0) FROM kv_changelog`
+
+	kvChangesSince = `
+		SELECT seq, key, op, old_value, new_value, changed_at FROM kv_changelog
+		WHERE seq > ? AND namespace = ? AND key LIKE ? ESCAPE '\' ORDER BY seq LIMIT ?`
+
+	// The kvLog statements record changes in kv_changelog, in the
+	// transaction that makes them. kvLogSet runs after the write and takes
+	// the value it replaced as ?3; the others run before it and take the
+	// parameters of the statement they record.
+	kvStoredValue = `
+		SELECT value FROM kv_store WHERE key = ?`
+
+	kvLogSet = `
+		INSERT INTO kv_changelog (namespace, key, op, old_value, new_value)
+		SELECT ?1, ?2, 'kv.set', ?3, value FROM kv_store WHERE key = ?2`
+
+	kvLogDelete = `
+		INSERT INTO kv_changelog (namespace, key, op, old_value)
+		SELECT namespace, key, 'kv.deleted', value FROM kv_store WHERE key = ?`
+
+	kvLogClear = `
+		INSERT INTO kv_changelog (namespace, key, op, old_value)
+		SELECT namespace, key, 'kv.deleted', value FROM kv_store WHERE namespace = ? AND substr(key, 1, 4) != 'sys:'`
+
+	kvLogClearWithPrefix = `
+		INSERT INTO kv_changelog (namespace, key, op, old_value)
+		SELECT namespace, key, 'kv.deleted', value FROM kv_store
+		WHERE namespace = ? AND key LIKE ? ESCAPE '\' AND substr(key, 1, 4) != 'sys:'`
+
+	kvLogReap = `
+		INSERT INTO kv_changelog (namespace, key, op, old_value)
+		SELECT namespace, key, 'kv.deleted', value FROM kv_store WHERE expires_at <= ?`
+
+	kvChangeTrim = `
+		DELETE FROM kv_changelog WHERE changed_at < unixepoch() - ?`
+
+	fsChangeInsert = `
+		INSERT INTO fs_changelog (kind, path, old_path, changed_at) VALUES (?, ?, ?, ?)`
+
+	fsChangeHead = `
+		SELECT COALESCE(MAX(seq), 0) FROM fs_changelog`
+
+	fsChangesSince = `
+		SELECT seq, kind, path, old_path, changed_at FROM fs_changelog
+		WHERE seq > ?1 AND (path LIKE ?2 ESCAPE '\' OR old_path LIKE ?2 ESCAPE '\') ORDER BY seq LIMIT ?3`
+
+	fsChangeTrim = `
+		DELETE FROM fs_changelog WHERE changed_at < ?`
 )
 
 // Tool calls queries
 const (
 	toolCallsInsert = `
-		INSERT INTO tool_calls (name, parameters, result, error, started_at, completed_at, duration_ms)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO tool_calls (name, parameters, result, error, started_at, completed_at, duration_ms, uid)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 		RETURNING id`
 
 	toolCallsGetByID = `
-		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms
-		FROM tool_calls WHERE id = ?`
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_call_history WHERE id = ?`
+
+	toolCallsGetByUID = `
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_call_history WHERE uid = ?`
+
+	toolCallIDByUID = `SELECT id FROM tool_call_history WHERE uid = ?`
+
+	// Annotations (see ToolCalls.Annotate)
+	annotationSet = `
+		INSERT INTO tool_call_annotations (tool_call_id, key, value, updated_at)
+		SELECT ?1, ?2, ?3, ?4 WHERE EXISTS (SELECT 1 FROM tool_call_history WHERE id = ?1)
+		ON CONFLICT(tool_call_id, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`
+
+	annotationDelete = `
+		DELETE FROM tool_call_annotations WHERE tool_call_id = ? AND key = ?`
+
+	annotationList = `
+		SELECT key, value FROM tool_call_annotations WHERE tool_call_id = ? ORDER BY key`
+
+	toolCallFileLink = `
+		INSERT OR IGNORE INTO tool_call_files (tool_call_id, path)
+		SELECT ?1, ?2 WHERE EXISTS (SELECT 1 FROM tool_call_history WHERE id = ?1)`
+
+	toolCallFileList = `
+		SELECT path FROM tool_call_files WHERE tool_call_id = ? ORDER BY path`
+
+	// Usage accounting (see ToolCalls.AddUsage)
+	toolCallUsageAdd = `
+		INSERT INTO tool_call_usage (tool_call_id, input_tokens, output_tokens, cost_usd)
+		SELECT ?1, ?2, ?3, ?4 WHERE EXISTS (SELECT 1 FROM tool_call_history WHERE id = ?1)
+		ON CONFLICT(tool_call_id) DO UPDATE SET
+			input_tokens = input_tokens + excluded.input_tokens,
+			output_tokens = output_tokens + excluded.output_tokens,
+			cost_usd = cost_usd + excluded.cost_usd`
+
+	toolCallUsageGet = `
+		SELECT input_tokens, output_tokens, cost_usd FROM tool_call_usage WHERE tool_call_id = ?`
+
+	// toolCallCostLinks lists the files linked to tool calls at or below a
+	// path, with the usage of each call. Parameters: ?1 path, ?2 LIKE prefix.
+	toolCallCostLinks = `
+		SELECT f.path, f.tool_call_id,
+			COALESCE(u.input_tokens, 0), COALESCE(u.output_tokens, 0), COALESCE(u.cost_usd, 0)
+		FROM tool_call_files f LEFT JOIN tool_call_usage u ON u.tool_call_id = f.tool_call_id
+		WHERE f.path = ?1 OR f.path LIKE ?2 ESCAPE '\'`
+
+	// Session messages (see AgentFS.AddMessage)
+	messageInsert = `
+		INSERT INTO session_messages (session_id, role, content, created_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id`
+
+	messageList = `
+		SELECT id, session_id, role, content, created_at
+		FROM session_messages WHERE session_id = ? ORDER BY id`
+
+	messageListAll = `
+		SELECT id, session_id, role, content, created_at FROM session_messages ORDER BY id`
+
+	// Agent mailbox (see Mailbox). visible_at is in Unix milliseconds.
+	mailboxSend = `
+		INSERT INTO mailbox (recipient, sender, body, sent_at) VALUES (?, ?, ?, ?)
+		RETURNING id`
+
+	// mailboxReceive leases the oldest visible message of a recipient.
+	// Parameters: ?1 recipient, ?2 now, ?3 visible again at.
+	mailboxReceive = `
+		UPDATE mailbox SET visible_at = ?3, deliveries = deliveries + 1
+		WHERE id = (
+			SELECT id FROM mailbox WHERE recipient = ?1 AND visible_at <= ?2
+			ORDER BY id LIMIT 1)
+		RETURNING id, sender, body, sent_at, deliveries`
+
+	mailboxAck = `
+		DELETE FROM mailbox WHERE id = ? AND deliveries = ?`
+
+	mailboxRequeue = `
+		UPDATE mailbox SET visible_at = ? WHERE id = ? AND deliveries = ?`
+
+	mailboxLen = `
+		SELECT COUNT(*) FROM mailbox WHERE recipient = ?`
+
+	// Streams (see Stream). Appends fail once the stream holds its closing
+	// record, which is never trimmed since consumers stop before it.
+	streamAppend = `
+		INSERT INTO stream_record (stream, seq, data, written_at, closed)
+		SELECT ?1, COALESCE(MAX(seq), 0) + 1, COALESCE(?2, X''), ?3, ?4 FROM stream_record WHERE stream = ?1
+		HAVING COALESCE(MAX(closed), 0) = 0
+		RETURNING seq`
+
+	streamRead = `
+		SELECT seq, data, written_at, closed FROM stream_record
+		WHERE stream = ? AND seq > ? ORDER BY seq LIMIT ?`
+
+	streamHead = `
+		SELECT COALESCE(MAX(seq), 0) FROM stream_record WHERE stream = ?`
+
+	streamPosition = `
+		SELECT position FROM stream_offset WHERE stream = ? AND consumer = ?`
+
+	streamCommit = `
+		INSERT INTO stream_offset (stream, consumer, position, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(stream, consumer) DO UPDATE SET
+			position = max(position, excluded.position),
+			updated_at = excluded.updated_at`
+
+	// File delivery to sinks (see AgentFS.OnFileFinalized). Times are in
+	// Unix milliseconds; a file finalized again while its delivery is
+	// pending is delivered once.
+	sinkEnqueue = `
+		INSERT INTO sink_delivery (sink, path, next_attempt_at, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(sink, path) WHERE status = 'pending' DO NOTHING`
+
+	sinkDue = `
+		SELECT id, sink, path, attempts, next_attempt_at FROM sink_delivery
+		WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY id LIMIT ?`
+
+	// sinkClaim leases a due delivery so other processes skip it.
+	sinkClaim = `
+		UPDATE sink_delivery SET next_attempt_at = ? WHERE id = ? AND status = 'pending' AND next_attempt_at = ?`
+
+	sinkDelivered = `
+		UPDATE sink_delivery SET status = 'delivered', attempts = attempts + 1, last_error = NULL, delivered_at = ?
+		WHERE id = ?`
+
+	sinkRetry = `
+		UPDATE sink_delivery SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?`
+
+	sinkFailed = `
+		UPDATE sink_delivery SET status = 'failed', attempts = attempts + 1, last_error = ? WHERE id = ?`
+
+	sinkLog = `
+		SELECT id, sink, path, status, attempts, COALESCE(last_error, ''), created_at, COALESCE(delivered_at, 0)
+		FROM sink_delivery WHERE (? = '' OR status = ?) ORDER BY id DESC LIMIT ?`
+
+	streamTrim = `
+		DELETE FROM stream_record
+		WHERE stream = ?1 AND seq < (SELECT MIN(position) FROM stream_offset WHERE stream = ?1)`
+
+	// Cached previews (see Filesystem.Preview). A row is stale unless
+	// mtime and file size still match the file.
+	previewGet = `
+		SELECT content_type, source_type, data FROM fs_preview
+		WHERE ino = ? AND size = ? AND mtime = ? AND mtime_nsec = ? AND file_size = ?`
+
+	previewDeleteByIno = `
+		DELETE FROM fs_preview WHERE ino = ?`
+
+	previewPut = `
+		INSERT OR REPLACE INTO fs_preview (ino, size, mtime, mtime_nsec, file_size, content_type, source_type, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// toolCallsSearch lists tool calls whose name, parameters, result, or
+	// error contain a string. Parameters: ?1 string, ?2 limit.
+	toolCallsSearch = `
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_call_history
+		WHERE instr(name, ?1) > 0 OR instr(COALESCE(parameters, ''), ?1) > 0
+			OR instr(COALESCE(result, ''), ?1) > 0 OR instr(COALESCE(error, ''), ?1) > 0
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?2`
+
+	// toolCallsFind lists tool calls matching a ToolCallFilter.
+	// Parameters: ?1 name ('' = any), ?2 since, ?3 until (0 = none),
+	// ?4 JSON object of annotation key to JSON value ('' = any value), ?5 limit.
+	toolCallsFind = `
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_call_history c
+		WHERE (?1 = '' OR c.name = ?1)
+			AND c.started_at >= ?2
+			AND (?3 = 0 OR c.started_at < ?3)
+			AND NOT EXISTS (
+				SELECT 1 FROM json_each(?4) f WHERE NOT EXISTS (
+					SELECT 1 FROM tool_call_annotations a
+					WHERE a.tool_call_id = c.id AND a.key = f.key AND (f.value = '' OR a.value = f.value)))
+		ORDER BY c.started_at DESC, c.id DESC
+		LIMIT ?5`
+
+	// Keyset pagination: ?6 and ?7 are the started_at and id of the last
+	// call of the previous page, or 0 for the first page
+	toolCallsQuery = `
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_call_history
+		WHERE (?1 = '' OR name = ?1)
+			AND (?2 = '' OR (?2 = 'success') = (error IS NULL))
+			AND started_at >= ?3
+			AND (?4 = 0 OR started_at < ?4)
+			AND (?7 = 0 OR (started_at, id) < (?6, ?7))
+			AND (?8 = 0 OR NOT EXISTS (
+				SELECT 1 FROM tool_call_retries r
+				WHERE r.original_id = COALESCE((SELECT original_id FROM tool_call_retries WHERE tool_call_id = tool_call_history.id), tool_call_history.id)
+					AND r.attempt > COALESCE((SELECT attempt FROM tool_call_retries WHERE tool_call_id = tool_call_history.id), 1)))
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?5`
 
 	toolCallsGetByName = `
-		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms
-		FROM tool_calls WHERE name = ?
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_call_history WHERE name = ?
 		ORDER BY started_at DESC
 		LIMIT ?`
 
 	toolCallsGetRecent = `
-		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms
-		FROM tool_calls WHERE started_at > ?
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_call_history WHERE started_at > ?
 		ORDER BY started_at DESC
 		LIMIT ?`
 
+	// The chain, name, and next attempt of a retry of the call ?1
+	toolCallRetryTarget = `
+		SELECT c.name, COALESCE(r.original_id, c.id),
+			(SELECT COALESCE(MAX(attempt), 1) FROM tool_call_retries WHERE original_id = COALESCE(r.original_id, c.id)) + 1
+		FROM tool_call_history c LEFT JOIN tool_call_retries r ON r.tool_call_id = c.id
+		WHERE c.id = ?1`
+
+	toolCallPendingName = `
+		SELECT name FROM tool_calls_pending WHERE id = ? AND status = 'running'`
+
+	toolCallPendingRunning = `
+		SELECT COUNT(*) FROM tool_calls_pending WHERE id = ? AND status = 'running'`
+
+	toolCallOutputAppend = `
+		INSERT INTO tool_call_output (pending_id, seq, data)
+		VALUES (?1, (SELECT COALESCE(MAX(seq), 0) + 1 FROM tool_call_output WHERE pending_id = ?1), ?2)`
+
+	toolCallOutputResolve = `
+		UPDATE tool_call_output SET tool_call_id = ? WHERE pending_id = ?`
+
+	toolCallOutputSince = `
+		SELECT seq, data FROM tool_call_output WHERE pending_id = ? AND seq > ? ORDER BY seq LIMIT ?`
+
+	toolCallOutputGet = `
+		SELECT data FROM tool_call_output WHERE tool_call_id = ? ORDER BY seq`
+
+	toolCallRetryInsert = `
+		INSERT INTO tool_call_retries (tool_call_id, original_id, attempt) VALUES (?, ?, ?)`
+
+	toolCallRetryOf = `
+		SELECT original_id, attempt FROM tool_call_retries WHERE tool_call_id = ?`
+
+	// Attempts in order; the original is attempt 1
+	toolCallAttempts = `
+		WITH chain(id) AS (
+			SELECT COALESCE((SELECT original_id FROM tool_call_retries WHERE tool_call_id = ?1), ?1))
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid FROM (
+			SELECT 1 AS attempt, c.id, c.name, c.parameters, c.result, c.error, c.started_at, c.completed_at, c.duration_ms, c.uid
+			FROM chain JOIN tool_call_history c ON c.id = chain.id
+			UNION ALL
+			SELECT r.attempt, c.id, c.name, c.parameters, c.result, c.error, c.started_at, c.completed_at, c.duration_ms, c.uid
+			FROM chain JOIN tool_call_retries r ON r.original_id = chain.id JOIN tool_call_history c ON c.id = r.tool_call_id
+		)
+		ORDER BY attempt, id`
+
+	// The outcome of the last attempt of each chain, by tool and attempts
+	toolCallRetryStats = `
+		WITH attempts AS (
+			SELECT c.name, c.error IS NULL AS ok, COALESCE(r.attempt, 1) AS attempt,
+				ROW_NUMBER() OVER (PARTITION BY COALESCE(r.original_id, c.id) ORDER BY COALESCE(r.attempt, 1) DESC) AS rank
+			FROM tool_call_history c LEFT JOIN tool_call_retries r ON r.tool_call_id = c.id)
+		SELECT name, attempt, ok, COUNT(*) FROM attempts
+		WHERE rank = 1
+		GROUP BY name, attempt, ok
+		ORDER BY name, attempt`
+
 	toolCallsGetStats = `
 		SELECT
 			name,
@@ -329,9 +1341,156 @@ const (
 			SUM(CASE WHEN error IS NULL THEN 1 ELSE 0 END) as successful,
 			SUM(CASE WHEN error IS NOT NULL THEN 1 ELSE 0 END) as failed,
 			AVG(duration_ms) as avg_duration_ms
-		FROM tool_calls
+		FROM tool_call_history
 		GROUP BY name
 		ORDER BY total_calls DESC`
+
+	// In-progress tool calls
+	toolCallsPendingInsert = `
+		INSERT INTO tool_calls_pending (name, parameters, started_at, heartbeat_at, last_seen, owner, status, parent_pending_id)
+		VALUES (?1, ?2, ?3, ?3, ?3, ?4, 'running', ?5)
+		RETURNING id`
+
+	toolCallsPendingDelete = `
+		DELETE FROM tool_calls_pending WHERE id = ?`
+
+	// heartbeat_at tells that the owning process is alive, last_seen that
+	// the call itself is (see ToolCalls.Heartbeat); calls started before
+	// last_seen existed count from their start
+	toolCallsPendingHeartbeatOwner = `
+		UPDATE tool_calls_pending SET heartbeat_at = ?
+		WHERE owner = ? AND status = 'running'`
+
+	toolCallsPendingHeartbeat = `
+		UPDATE tool_calls_pending SET heartbeat_at = ?1, last_seen = ?1
+		WHERE id = ?2 AND status = 'running'`
+
+	toolCallsPendingOlderThan = `
+		SELECT id, name, parameters, started_at, heartbeat_at, COALESCE(last_seen, started_at), status, tool_call_id
+		FROM tool_calls_pending
+		WHERE status = 'running' AND COALESCE(last_seen, started_at) < ?
+		ORDER BY COALESCE(last_seen, started_at) ASC`
+
+	toolCallsPendingStale = `
+		SELECT id, name, parameters, started_at, heartbeat_at
+		FROM tool_calls_pending
+		WHERE status = 'running' AND owner != ? AND heartbeat_at < ?`
+
+	toolCallsPendingMarkInterrupted = `
+		UPDATE tool_calls_pending SET status = 'interrupted'
+		WHERE id = ? AND status = 'running'`
+
+	toolCallsPendingSetToolCallID = `
+		UPDATE tool_calls_pending SET tool_call_id = ? WHERE id = ?`
+
+	// A call with a parent links itself when it is recorded; a parent being
+	// recorded hands its ID to the children linked to its pending ID
+	toolCallSpansLink = `
+		INSERT INTO tool_call_spans (tool_call_id, parent_id, parent_pending_id)
+		SELECT ?1, parent_id, parent_pending_id FROM tool_calls_pending
+		WHERE id = ?2 AND (parent_id IS NOT NULL OR parent_pending_id IS NOT NULL)`
+
+	toolCallSpansResolve = `
+		UPDATE tool_call_spans SET parent_id = ?1, parent_pending_id = NULL
+		WHERE parent_pending_id = ?2`
+
+	toolCallsPendingResolveParent = `
+		UPDATE tool_calls_pending SET parent_id = ?1, parent_pending_id = NULL
+		WHERE parent_pending_id = ?2`
+
+	toolCallParent = `SELECT parent_id FROM tool_call_spans WHERE tool_call_id = ?`
+
+	toolCallExists = `SELECT EXISTS (SELECT 1 FROM tool_call_history WHERE id = ?)`
+
+	toolCallSpansSet = `
+		INSERT INTO tool_call_spans (tool_call_id, parent_id) VALUES (?, ?)
+		ON CONFLICT (tool_call_id) DO UPDATE SET parent_id = excluded.parent_id, parent_pending_id = NULL`
+
+	// Whether ?2 is ?1 or one of its ancestors
+	toolCallSpansIsAncestor = `
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT ?1
+			UNION
+			SELECT s.parent_id FROM tool_call_spans s JOIN ancestors a ON s.tool_call_id = a.id
+			WHERE s.parent_id IS NOT NULL)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?2)`
+
+	toolCallsTree = `
+		WITH RECURSIVE tree(id, parent_id) AS (
+			SELECT ?1, NULL
+			UNION
+			SELECT s.tool_call_id, s.parent_id FROM tool_call_spans s JOIN tree t ON s.parent_id = t.id)
+		SELECT c.id, c.name, c.parameters, c.result, c.error, c.started_at, c.completed_at, c.duration_ms, c.uid, t.parent_id
+		FROM tree t JOIN tool_call_history c ON c.id = t.id
+		ORDER BY c.started_at, c.id`
+
+	toolCallsPendingByStatus = `
+		SELECT id, name, parameters, started_at, heartbeat_at, COALESCE(last_seen, started_at), status, tool_call_id
+		FROM tool_calls_pending WHERE status = ?
+		ORDER BY started_at ASC`
+
+	// Archival (see ToolCalls.Archive). Queries with %[1]s take the name of
+	// an archive table.
+	toolCallArchiveMonths = `
+		SELECT DISTINCT strftime('%Y-%m', started_at, 'unixepoch') FROM tool_calls WHERE started_at < ?`
+
+	toolCallArchiveCreate = `
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			parameters TEXT,
+			result TEXT,
+			error TEXT,
+			started_at INTEGER NOT NULL,
+			completed_at INTEGER NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			uid TEXT
+		)`
+
+	toolCallArchiveNameIndex = `
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_name ON %[1]s(name)`
+
+	toolCallArchiveStartedAtIndex = `
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_started_at ON %[1]s(started_at)`
+
+	toolCallArchiveUIDIndex = `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_uid ON %[1]s(uid) WHERE uid IS NOT NULL`
+
+	toolCallArchiveAddUID = `ALTER TABLE %[1]s ADD COLUMN uid TEXT`
+
+	toolCallArchiveRegister = `
+		INSERT OR IGNORE INTO tool_call_archives (name, month) VALUES (?, ?)`
+
+	toolCallArchiveMove = `
+		INSERT INTO %[1]s (id, name, parameters, result, error, started_at, completed_at, duration_ms, uid)
+		SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid
+		FROM tool_calls WHERE started_at >= ? AND started_at < ?`
+
+	toolCallArchiveRemove = `
+		DELETE FROM tool_calls WHERE started_at < ?`
+
+	toolCallArchiveList = `
+		SELECT name, month FROM tool_call_archives ORDER BY month`
+
+	toolCallArchiveCount = `SELECT COUNT(*) FROM %[1]s`
+
+	toolCallArchiveExpire = `DELETE FROM %[1]s WHERE completed_at < ?`
+
+	toolCallArchiveAfter = `DELETE FROM %[1]s WHERE id > ?`
+
+	toolCallArchiveDrop = `DROP TABLE %[1]s`
+
+	toolCallArchiveUnregister = `
+		DELETE FROM tool_call_archives WHERE name = ?`
+
+	toolCallHistoryDrop = `DROP VIEW IF EXISTS tool_call_history`
+
+	toolCallHistoryCreate = `CREATE VIEW tool_call_history AS `
+
+	toolCallHistorySelect = `SELECT id, name, parameters, result, error, started_at, completed_at, duration_ms, uid FROM %[1]s`
+
+	// Fails until the view includes the uid column (see migrateToolCallHistory)
+	toolCallHistoryProbeUID = `SELECT uid FROM tool_call_history LIMIT 0`
 )
 
 // Overlay filesystem queries
@@ -366,4 +1525,263 @@ const (
 
 	originDelete = `
 		DELETE FROM fs_origin WHERE delta_ino = ?`
+
+	// Custom metadata operations
+	metaSet = `
+		INSERT INTO fs_meta (ino, key, value) VALUES (?, ?, ?)
+		ON CONFLICT(ino, key) DO UPDATE SET value = excluded.value`
+
+	metaGet = `
+		SELECT value FROM fs_meta WHERE ino = ? AND key = ?`
+
+	metaList = `
+		SELECT key, value FROM fs_meta WHERE ino = ? ORDER BY key`
+
+	metaDelete = `
+		DELETE FROM fs_meta WHERE ino = ? AND key = ?`
+
+	metaDeleteByIno = `
+		DELETE FROM fs_meta WHERE ino = ?`
+
+	// removeGlobCandidates lists the non-directory entries below a directory.
+	// Parameters: ?1 root ino, ?2 root path.
+	removeGlobCandidates = `
+		WITH RECURSIVE tree(id, ino, path) AS (
+			SELECT d.id, d.ino, CASE WHEN ?2 = '/' THEN '/' || d.name ELSE ?2 || '/' || d.name END
+			FROM fs_dentry d WHERE d.parent_ino = ?1
+			UNION ALL
+			SELECT d.id, d.ino, tree.path || '/' || d.name
+			FROM fs_dentry d JOIN tree ON d.parent_ino = tree.ino
+		)
+		SELECT t.id, t.ino, t.path FROM tree t JOIN fs_inode i ON i.ino = t.ino
+		WHERE (i.mode & 61440) != 16384
+		ORDER BY t.path`
+
+	// The bulk removal statements take JSON arrays of dentry IDs or inode
+	// numbers. An inode appears once per removed link.
+	removeDentriesByID = `
+		DELETE FROM fs_dentry WHERE id IN (SELECT value FROM json_each(?))`
+
+	removeLinksByIno = `
+		UPDATE fs_inode SET nlink = nlink - (SELECT count(*) FROM json_each(?1) j WHERE j.value = fs_inode.ino)
+		WHERE ino IN (SELECT value FROM json_each(?1))`
+
+	unlinkedInodes = `SELECT ino FROM fs_inode WHERE nlink = 0 AND ino IN (SELECT value FROM json_each(?))`
+
+	removeUnlinkedData     = `DELETE FROM fs_data WHERE ino IN (` + unlinkedInodes + `)`
+	removeUnlinkedExtData  = `DELETE FROM fs_data_ext WHERE ino IN (` + unlinkedInodes + `)`
+	removeUnlinkedSymlinks = `DELETE FROM fs_symlink WHERE ino IN (` + unlinkedInodes + `)`
+	removeUnlinkedMeta     = `DELETE FROM fs_meta WHERE ino IN (` + unlinkedInodes + `)`
+	removeUnlinkedPreviews = `DELETE FROM fs_preview WHERE ino IN (` + unlinkedInodes + `)`
+	removeUnlinkedInodes   = `DELETE FROM fs_inode WHERE nlink = 0 AND ino IN (SELECT value FROM json_each(?))`
+
+	// Edit locks (see BeginEdit)
+	editLockDeleteExpired = `
+		DELETE FROM fs_edit_lock WHERE ino = ? AND expires_at <= ?`
+
+	editLockInsert = `
+		INSERT INTO fs_edit_lock (ino, session, expires_at) VALUES (?, ?, ?)`
+
+	editLockRelease = `
+		DELETE FROM fs_edit_lock WHERE ino = ? AND session = ?`
+
+	// Strict mode checks (see AgentFSOptions.Strict)
+	strictChunksOf = `
+		SELECT chunk_index, length(data) FROM fs_data WHERE ino = ?1
+		UNION ALL
+		SELECT chunk_index, size FROM fs_data_ext WHERE ino = ?1
+		ORDER BY 1`
+
+	strictChunks = `
+		SELECT c.ino, c.chunk_index, c.len, i.size, COALESCE(m.value, '')
+		FROM (
+			SELECT ino, chunk_index, length(data) AS len FROM fs_data
+			UNION ALL
+			SELECT ino, chunk_index, size FROM fs_data_ext
+		) c
+		JOIN fs_inode i ON i.ino = c.ino
+		LEFT JOIN fs_meta m ON m.ino = c.ino AND m.key = 'sys:chunk_size'
+		ORDER BY c.ino, c.chunk_index`
+
+	strictNlinkOf = `
+		SELECT nlink, (SELECT COUNT(*) FROM fs_dentry WHERE ino = ?1) FROM fs_inode WHERE ino = ?1`
+
+	// ?1 is the root inode, which has no entry
+	strictNlinks = `
+		SELECT i.ino, i.nlink, COUNT(d.id) FROM fs_inode i
+		LEFT JOIN fs_dentry d ON d.ino = i.ino
+		WHERE i.ino != ?1
+		GROUP BY i.ino HAVING i.nlink != COUNT(d.id)`
+
+	// Entries whose inode is missing or whose parent is not a directory
+	strictDentries = `
+		SELECT d.parent_ino, d.name, d.ino, i.ino IS NULL
+		FROM fs_dentry d
+		LEFT JOIN fs_inode i ON i.ino = d.ino
+		LEFT JOIN fs_inode p ON p.ino = d.parent_ino
+		WHERE i.ino IS NULL OR p.ino IS NULL OR (p.mode & 61440) != 16384`
+
+	// Rows kept for inodes that no longer exist
+	strictOrphans = `
+		SELECT 'data', ino FROM fs_data WHERE ino NOT IN (SELECT ino FROM fs_inode)
+		UNION
+		SELECT 'external data', ino FROM fs_data_ext WHERE ino NOT IN (SELECT ino FROM fs_inode)
+		UNION
+		SELECT 'symlink target', ino FROM fs_symlink WHERE ino NOT IN (SELECT ino FROM fs_inode)
+		UNION
+		SELECT 'metadata', ino FROM fs_meta WHERE ino NOT IN (SELECT ino FROM fs_inode)`
+
+	// metaQuery lists entries below a directory filtered by metadata.
+	// Parameters: ?1 root ino, ?2 root path, ?3 missing, ?4 key, ?5 value (NULL = any).
+	// When ?3 is true, regular files lacking the key/value are returned instead.
+	metaQuery = `
+		WITH RECURSIVE tree(ino, path) AS (
+			SELECT d.ino, CASE WHEN ?2 = '/' THEN '/' || d.name ELSE ?2 || '/' || d.name END
+			FROM fs_dentry d WHERE d.parent_ino = ?1
+			UNION ALL
+			SELECT d.ino, tree.path || '/' || d.name
+			FROM fs_dentry d JOIN tree ON d.parent_ino = tree.ino
+		)
+		SELECT t.path FROM tree t JOIN fs_inode i ON i.ino = t.ino
+		WHERE CASE WHEN ?3
+			THEN (i.mode & 61440) = 32768 AND NOT EXISTS (
+				SELECT 1 FROM fs_meta m WHERE m.ino = t.ino AND m.key = ?4 AND (?5 IS NULL OR m.value = ?5))
+			ELSE EXISTS (
+				SELECT 1 FROM fs_meta m WHERE m.ino = t.ino AND m.key = ?4 AND (?5 IS NULL OR m.value = ?5))
+			END
+		ORDER BY t.path`
+
+	// Snapshots (see AgentFS.Snapshot)
+	snapshotInsert = `
+		INSERT INTO fs_snapshot (name, created_at, max_tool_call_id, uid)
+		VALUES (?, ?, (SELECT COALESCE(MAX(id), 0) FROM tool_call_history), ?)`
+
+	snapshotGet = `
+		SELECT name, created_at, max_tool_call_id, COALESCE(uid, '') FROM fs_snapshot WHERE name = ?`
+
+	snapshotList = `
+		SELECT name, created_at, max_tool_call_id, COALESCE(uid, '') FROM fs_snapshot ORDER BY created_at, name`
+
+	snapshotChunkPage = `
+		SELECT ino, chunk_index, data FROM fs_data
+		WHERE (ino, chunk_index) > (?, ?) ORDER BY ino, chunk_index LIMIT ?`
+
+	snapshotChunkPut = `
+		INSERT OR IGNORE INTO fs_snapshot_chunk (hash, data) VALUES (?, ?)`
+
+	snapshotDataPut = `
+		INSERT INTO fs_snapshot_data (snapshot, ino, chunk_index, hash) VALUES (?, ?, ?, ?)`
+
+	snapshotChunksPrune = `
+		DELETE FROM fs_snapshot_chunk
+		WHERE hash NOT IN (SELECT hash FROM fs_snapshot_data) AND hash NOT IN (SELECT hash FROM fs_version_data)`
+
+	// File versions (see Filesystem.History); contents share
+	// fs_snapshot_chunk with snapshots
+	versionInsert = `
+		INSERT INTO fs_version (path, version, size, mode, mtime, created_at, chunk_size)
+		VALUES (?1, (SELECT COALESCE(MAX(version), 0) + 1 FROM fs_version WHERE path = ?1), ?2, ?3, ?4, ?5, ?6)
+		RETURNING version`
+
+	versionDataPut = `
+		INSERT INTO fs_version_data (path, version, chunk_index, hash) VALUES (?, ?, ?, ?)`
+
+	versionGet = `
+		SELECT version, size, mode, mtime, created_at, chunk_size FROM fs_version WHERE path = ? AND version = ?`
+
+	versionList = `
+		SELECT version, size, mode, mtime, created_at FROM fs_version WHERE path = ? ORDER BY version DESC`
+
+	versionChunks = `
+		SELECT d.chunk_index, c.data FROM fs_version_data d JOIN fs_snapshot_chunk c ON c.hash = d.hash
+		WHERE d.path = ? AND d.version = ? ORDER BY d.chunk_index`
+
+	// Versions of path older than the newest ?2 are expired
+	versionExpiredHashes = `
+		SELECT DISTINCT hash FROM fs_version_data
+		WHERE path = ?1 AND version <= (SELECT MAX(version) FROM fs_version WHERE path = ?1) - ?2`
+
+	versionExpireData = `
+		DELETE FROM fs_version_data
+		WHERE path = ?1 AND version <= (SELECT MAX(version) FROM fs_version WHERE path = ?1) - ?2`
+
+	versionExpire = `
+		DELETE FROM fs_version
+		WHERE path = ?1 AND version <= (SELECT MAX(version) FROM fs_version WHERE path = ?1) - ?2`
+
+	versionChunksPrune = `
+		DELETE FROM fs_snapshot_chunk WHERE hash IN (SELECT value FROM json_each(?))
+			AND hash NOT IN (SELECT hash FROM fs_snapshot_data) AND hash NOT IN (SELECT hash FROM fs_version_data)`
 )
+
+// snapshotCopies copy the live tables into a snapshot; each takes the
+// snapshot name as its only parameter. fs_data is copied separately so
+// chunks can be shared.
+var snapshotCopies = []string{
+	`INSERT INTO fs_snapshot_inode
+		SELECT ?, ino, mode, nlink, uid, gid, size, atime, mtime, ctime, rdev, atime_nsec, mtime_nsec, ctime_nsec FROM fs_inode`,
+	`INSERT INTO fs_snapshot_dentry SELECT ?, id, name, parent_ino, ino FROM fs_dentry`,
+	`INSERT INTO fs_snapshot_symlink SELECT ?, ino, target FROM fs_symlink`,
+	`INSERT INTO fs_snapshot_data_ext SELECT ?, ino, chunk_index, tier, hash, size FROM fs_data_ext`,
+	`INSERT INTO fs_snapshot_meta SELECT ?, ino, key, value FROM fs_meta`,
+	`INSERT INTO fs_snapshot_kv (snapshot, namespace, key, value, created_at, updated_at, expires_at, type_tag)
+		SELECT ?, namespace, key, value, created_at, updated_at, expires_at, type_tag FROM kv_store`,
+}
+
+// snapshotClears empty the live state before a restore.
+var snapshotClears = []string{
+	`DELETE FROM fs_inode`,
+	`DELETE FROM fs_dentry`,
+	`DELETE FROM fs_symlink`,
+	`DELETE FROM fs_data`,
+	`DELETE FROM fs_data_ext`,
+	`DELETE FROM fs_meta`,
+	`DELETE FROM fs_preview`,
+	`DELETE FROM fs_edit_lock`,
+	`INSERT INTO kv_changelog (namespace, key, op, old_value) SELECT namespace, key, 'kv.deleted', value FROM kv_store`,
+	`DELETE FROM kv_store`,
+}
+
+// snapshotRestores copy a snapshot back into the cleared live tables; each
+// takes the snapshot name as its only parameter.
+var snapshotRestores = []string{
+	`INSERT INTO fs_inode (ino, mode, nlink, uid, gid, size, atime, mtime, ctime, rdev, atime_nsec, mtime_nsec, ctime_nsec)
+		SELECT ino, mode, nlink, uid, gid, size, atime, mtime, ctime, rdev, atime_nsec, mtime_nsec, ctime_nsec
+		FROM fs_snapshot_inode WHERE snapshot = ?`,
+	`INSERT INTO fs_dentry (id, name, parent_ino, ino) SELECT id, name, parent_ino, ino FROM fs_snapshot_dentry WHERE snapshot = ?`,
+	`INSERT INTO fs_symlink (ino, target) SELECT ino, target FROM fs_snapshot_symlink WHERE snapshot = ?`,
+	`INSERT INTO fs_data (ino, chunk_index, data)
+		SELECT d.ino, d.chunk_index, c.data FROM fs_snapshot_data d JOIN fs_snapshot_chunk c ON c.hash = d.hash
+		WHERE d.snapshot = ?`,
+	`INSERT INTO fs_data_ext (ino, chunk_index, tier, hash, size)
+		SELECT ino, chunk_index, tier, hash, size FROM fs_snapshot_data_ext WHERE snapshot = ?`,
+	`INSERT INTO fs_meta (ino, key, value) SELECT ino, key, value FROM fs_snapshot_meta WHERE snapshot = ?`,
+	`INSERT INTO kv_store (namespace, key, value, created_at, updated_at, expires_at, type_tag)
+		SELECT namespace, key, value, created_at, updated_at, expires_at, type_tag FROM fs_snapshot_kv WHERE snapshot = ?`,
+	`INSERT INTO kv_changelog (namespace, key, op, new_value)
+		SELECT namespace, key, 'kv.set', value FROM fs_snapshot_kv WHERE snapshot = ?`,
+}
+
+// snapshotDeletes remove one snapshot; each takes its name.
+var snapshotDeletes = []string{
+	`DELETE FROM fs_snapshot_inode WHERE snapshot = ?`,
+	`DELETE FROM fs_snapshot_dentry WHERE snapshot = ?`,
+	`DELETE FROM fs_snapshot_symlink WHERE snapshot = ?`,
+	`DELETE FROM fs_snapshot_data WHERE snapshot = ?`,
+	`DELETE FROM fs_snapshot_data_ext WHERE snapshot = ?`,
+	`DELETE FROM fs_snapshot_meta WHERE snapshot = ?`,
+	`DELETE FROM fs_snapshot_kv WHERE snapshot = ?`,
+	`DELETE FROM fs_snapshot WHERE name = ?`,
+}
+
+// toolCallsAfter remove the tool calls recorded after a snapshot; each
+// takes the snapshot's highest tool call ID.
+var toolCallsAfter = []string{
+	`DELETE FROM tool_call_annotations WHERE tool_call_id > ?`,
+	`DELETE FROM tool_call_files WHERE tool_call_id > ?`,
+	`DELETE FROM tool_call_usage WHERE tool_call_id > ?`,
+	`DELETE FROM tool_call_spans WHERE tool_call_id > ?`,
+	`DELETE FROM tool_call_retries WHERE tool_call_id > ?`,
+	`DELETE FROM tool_call_output WHERE tool_call_id > ?`,
+	`DELETE FROM tool_calls WHERE id > ?`,
+}
@@ -0,0 +1,173 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SearchKind is the kind of data a SearchResult was found in.
+type SearchKind string
+
+const (
+	// SearchPath matches a file or directory path.
+	SearchPath SearchKind = "path"
+	// SearchContent matches a line of a file.
+	SearchContent SearchKind = "content"
+	// SearchToolCall matches the name, parameters, result, or error of a
+	// tool call.
+	SearchToolCall SearchKind = "tool_call"
+)
+
+// SearchResult is a match found by SearchAll, attributed to the agent whose
+// database holds it.
+type SearchResult struct {
+	Agent string     `json:"agent"` // Database file name without extension
+	Kind  SearchKind `json:"kind"`
+	// Path and Line locate path and content matches. Line is 0 for path
+	// matches and for matches in binary files.
+	Path string `json:"path,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Text is the matching line, or the name of a matching tool call.
+	Text string `json:"text,omitempty"`
+	// ToolCallID identifies a matching tool call.
+	ToolCallID int64 `json:"tool_call_id,omitempty"`
+}
+
+// SearchAll looks for query in every agent database (*.db) in dir: in file
+// paths, file contents, and recorded tool calls. The databases are opened
+// read-only, opts.Concurrency at a time. Results are grouped by agent, in
+// name order. A database that cannot be searched does not stop the others;
+// its error is joined into the returned error alongside the results found.
+//
+// Example:
+//
+//	results, err := agentfs.SearchAll(ctx, "/var/lib/agents", "sk-live-", nil)
+//	for _, r := range results {
+//	    fmt.Printf("%s: %s %s:%d\n", r.Agent, r.Kind, r.Path, r.Line)
+//	}
+func SearchAll(ctx context.Context, dir, query string, opts *SearchOptions) ([]SearchResult, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	if query == "" {
+		return nil, fmt.Errorf("search query must not be empty")
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	dbs, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(dbs)
+
+	results := make([][]SearchResult, len(dbs))
+	errs := make([]error, len(dbs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, p := range dbs {
+		wg.Add(1)
+		go func(i int, p string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			agent := strings.TrimSuffix(filepath.Base(p), ".db")
+			results[i], errs[i] = searchAgent(ctx, p, agent, query, opts)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("agent %s: %w", agent, errs[i])
+			}
+		}(i, p)
+	}
+	wg.Wait()
+
+	var all []SearchResult
+	for _, r := range results {
+		all = append(all, r...)
+	}
+	return all, errors.Join(errs...)
+}
+
+// searchAgent searches the database at p without writing to it.
+func searchAgent(ctx context.Context, p, agent, query string, opts *SearchOptions) ([]SearchResult, error) {
+	db, err := sql.Open("sqlite", "file:"+p+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	afs, err := newAgentFS(ctx, db, p, false, AgentFSOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer afs.Close()
+
+	limit := opts.MaxResults
+	if limit <= 0 {
+		limit = 100
+	}
+	wants := func(kind SearchKind) bool {
+		if len(opts.Kinds) == 0 {
+			return true
+		}
+		for _, k := range opts.Kinds {
+			if k == kind {
+				return true
+			}
+		}
+		return false
+	}
+
+	var results []SearchResult
+	if wants(SearchPath) {
+		paths, err := afs.FS.Find(ctx, "/", nil)
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		for _, fp := range paths {
+			if n < limit && strings.Contains(fp, query) {
+				results = append(results, SearchResult{Agent: agent, Kind: SearchPath, Path: fp})
+				n++
+			}
+		}
+	}
+	if wants(SearchContent) {
+		matches, err := afs.FS.Grep(ctx, "/", regexp.QuoteMeta(query), &GrepOptions{MaxMatches: limit})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			results = append(results, SearchResult{Agent: agent, Kind: SearchContent, Path: m.Path, Line: m.Line, Text: m.Text})
+		}
+	}
+	if wants(SearchToolCall) {
+		rows, err := db.QueryContext(ctx, toolCallsSearch, query, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search tool calls: %w", err)
+		}
+		calls, err := scanToolCalls(rows)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range calls {
+			results = append(results, SearchResult{Agent: agent, Kind: SearchToolCall, Text: c.Name, ToolCallID: c.ID})
+		}
+	}
+	return results, nil
+}
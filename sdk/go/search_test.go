@@ -0,0 +1,59 @@
+package agentfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchAll(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	for _, agent := range []string{"alpha", "beta"} {
+		afs, err := Open(ctx, AgentFSOptions{Path: filepath.Join(dir, agent+".db")})
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if agent == "beta" {
+			afs.FS.WriteFile(ctx, "/notes/token.txt", []byte("first\nkey=sk-live-123\n"), 0o644)
+			afs.Tools.Record(ctx, "http_post", map[string]string{"auth": "sk-live-123"}, "ok", nil, 1, 2)
+		} else {
+			afs.FS.WriteFile(ctx, "/readme.md", []byte("nothing here"), 0o644)
+		}
+		afs.Close()
+	}
+	os.WriteFile(filepath.Join(dir, "broken.db"), []byte("not a database"), 0o644)
+
+	results, err := SearchAll(ctx, dir, "sk-live-", nil)
+	if err == nil {
+		t.Error("SearchAll ignored the broken database")
+	}
+	var content, calls int
+	for _, r := range results {
+		if r.Agent != "beta" {
+			t.Errorf("unexpected result from %s: %+v", r.Agent, r)
+		}
+		switch r.Kind {
+		case SearchContent:
+			content++
+			if r.Path != "/notes/token.txt" || r.Line != 2 {
+				t.Errorf("content match = %+v", r)
+			}
+		case SearchToolCall:
+			calls++
+			if r.Text != "http_post" {
+				t.Errorf("tool call match = %+v", r)
+			}
+		}
+	}
+	if content != 1 || calls != 1 {
+		t.Errorf("got %d content and %d tool call matches, want 1 and 1", content, calls)
+	}
+
+	results, _ = SearchAll(ctx, dir, "token", &SearchOptions{Kinds: []SearchKind{SearchPath}})
+	if len(results) != 1 || results[0].Path != "/notes/token.txt" {
+		t.Errorf("path search = %+v", results)
+	}
+}
@@ -0,0 +1,56 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Session is a view of an AgentFS whose filesystem and KV operations all
+// run on one database connection, so every read observes the session's
+// earlier writes.
+//
+// Outside a session each operation borrows a connection from the pool.
+// With a file database in WAL mode a committed write is visible to every
+// later read, but a connection that is still inside a read transaction
+// (an unfinished iteration, a long export) keeps its older snapshot, and
+// each connection to ":memory:" is a separate database. A session avoids
+// both by never changing connections.
+//
+// A Session is not safe for concurrent use; give each goroutine or agent
+// its own. It holds a pooled connection until Close.
+type Session struct {
+	FS *Filesystem
+	KV *KVStore
+
+	conn *sql.Conn
+}
+
+// Session starts a session pinned to a connection taken from the pool.
+// The caller must Close it to return the connection.
+func (a *AgentFS) Session(ctx context.Context) (*Session, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	fs := *a.FS
+	fs.db = conn
+	fs.conn = conn
+	kv := *a.KV
+	kv.db = conn
+	kv.conn = conn
+	return &Session{FS: &fs, KV: &kv, conn: conn}, nil
+}
+
+// Close returns the session's connection to the pool. Files opened through
+// the session must be closed first.
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,57 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	s, err := afs.Session(ctx)
+	if err != nil {
+		t.Fatalf("Session failed: %v", err)
+	}
+
+	if err := s.FS.WriteFile(ctx, "/notes.txt", []byte("v1"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := s.KV.Set(ctx, "step", 1); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	err = s.KV.Txn(ctx, func(tx KVTx) error {
+		var step int
+		if err := tx.Get(ctx, "step", &step); err != nil {
+			return err
+		}
+		return tx.Set(ctx, "step", step+1)
+	})
+	if err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+
+	// Reads on the session see its writes immediately
+	data, err := s.FS.ReadFile(ctx, "/notes.txt")
+	if err != nil || string(data) != "v1" {
+		t.Errorf("session ReadFile = %q, %v", data, err)
+	}
+	var step int
+	if err := s.KV.Get(ctx, "step", &step); err != nil || step != 2 {
+		t.Errorf("session Get = %d, %v; want 2", step, err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := s.FS.ReadFile(ctx, "/notes.txt"); err == nil {
+		t.Error("ReadFile on a closed session succeeded")
+	}
+
+	// Committed session writes are visible through the pool
+	data, err = afs.FS.ReadFile(ctx, "/notes.txt")
+	if err != nil || string(data) != "v1" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,424 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink delivery defaults
+const (
+	// DefaultSinkSettle is how long a file must go unchanged before it is
+	// handed to sinks.
+	DefaultSinkSettle = 2 * time.Second
+
+	// DefaultSinkAttempts is how many times a delivery is tried.
+	DefaultSinkAttempts = 10
+
+	// DefaultSinkRetryDelay is the wait before the first retry.
+	DefaultSinkRetryDelay = time.Second
+)
+
+const (
+	sinkPollInterval  = 250 * time.Millisecond
+	sinkMaxRetryDelay = time.Hour
+	sinkLease         = 10 * time.Minute // Longest a delivery is expected to take
+	sinkBatch         = 64
+)
+
+// Sink delivers finalized files somewhere outside the database (see
+// OnFileFinalized). Deliver may be called more than once for the same
+// version of a file, so it should be idempotent.
+type Sink interface {
+	Deliver(ctx context.Context, f *SinkFile) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, f *SinkFile) error
+
+// Deliver calls fn.
+func (fn SinkFunc) Deliver(ctx context.Context, f *SinkFile) error {
+	return fn(ctx, f)
+}
+
+// SinkFile is a file handed to a Sink.
+type SinkFile struct {
+	Path  string
+	Stats *Stats
+	// Body reads the content of the file, Stats.Size bytes
+	Body io.Reader
+}
+
+// sinkRegistry holds the sinks registered in this process and the files
+// changed since they were last handed over.
+type sinkRegistry struct {
+	mu    sync.Mutex
+	sinks map[string]*registeredSink
+	dirty map[string]time.Time // Path to time of the last change
+	start sync.Once
+}
+
+type registeredSink struct {
+	name string
+	glob string
+	sink Sink
+	opts SinkOptions
+}
+
+// OnFileFinalized delivers every file whose path matches glob to sink once
+// the file is finalized: written, or renamed into place, and then left
+// unchanged for opts.Settle. The glob uses path.Match syntax against the
+// whole absolute path, so "*" does not cross "/".
+//
+// Deliveries are recorded in the database before they are attempted and
+// retried with exponential backoff until they succeed or run out of
+// attempts, so a delivery pending when the process stops resumes once a
+// sink of the same name is registered again. Delivery is at least once:
+// sinks should tolerate receiving a file twice. SinkDeliveries lists the
+// delivery log.
+//
+// Example:
+//
+//	afs.OnFileFinalized("/outputs/*.pdf", agentfs.WebhookSink("https://example.com/reports"), nil)
+//	afs.OnFileFinalized("/outputs/*", agentfs.DirSink("/srv/share/run-42"), nil)
+func (a *AgentFS) OnFileFinalized(glob string, sink Sink, opts *SinkOptions) error {
+	if _, err := path.Match(glob, ""); err != nil {
+		return ErrInval("sink", glob, "bad pattern")
+	}
+	glob = normalizePath(glob)
+
+	rs := &registeredSink{glob: glob, sink: sink}
+	if opts != nil {
+		rs.opts = *opts
+	}
+	if rs.opts.Settle <= 0 {
+		rs.opts.Settle = DefaultSinkSettle
+	}
+	if rs.opts.MaxAttempts <= 0 {
+		rs.opts.MaxAttempts = DefaultSinkAttempts
+	}
+	if rs.opts.RetryDelay <= 0 {
+		rs.opts.RetryDelay = DefaultSinkRetryDelay
+	}
+	rs.name = rs.opts.Name
+	if rs.name == "" {
+		rs.name = glob
+	}
+
+	r := &a.sinks
+	r.mu.Lock()
+	if _, ok := r.sinks[rs.name]; ok {
+		r.mu.Unlock()
+		return fmt.Errorf("sink %q is already registered", rs.name)
+	}
+	if r.sinks == nil {
+		r.sinks = make(map[string]*registeredSink)
+		r.dirty = make(map[string]time.Time)
+	}
+	r.sinks[rs.name] = rs
+	r.mu.Unlock()
+
+	r.start.Do(func() {
+		a.events.hook(a.sinkTouch)
+		a.goBackground(a.runSinks)
+	})
+	return nil
+}
+
+// sinkTouch notes a changed file that a sink may want.
+func (a *AgentFS) sinkTouch(e Event) {
+	if e.Kind != EventFileWritten && e.Kind != EventFileRenamed {
+		return
+	}
+	r := &a.sinks
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rs := range r.sinks {
+		if ok, _ := path.Match(rs.glob, e.Path); ok {
+			r.dirty[e.Path] = e.Time
+			return
+		}
+	}
+}
+
+// runSinks delivers files in the background until stop is closed.
+func (a *AgentFS) runSinks(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(sinkPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		a.deliverSinks(ctx, false) // Failures stay pending for the next round
+	}
+}
+
+// DeliverSinks hands every file changed since the last delivery to its
+// sinks now, without waiting for it to settle, and attempts the deliveries
+// that are due. Call it before Close so the last outputs go out.
+func (a *AgentFS) DeliverSinks(ctx context.Context) error {
+	return a.deliverSinks(ctx, true)
+}
+
+// sinkJob is a delivery claimed for this process.
+type sinkJob struct {
+	id       int64
+	sink     *registeredSink
+	path     string
+	attempts int64
+}
+
+func (a *AgentFS) deliverSinks(ctx context.Context, force bool) error {
+	due, err := a.claimSinkDeliveries(ctx, force)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, d := range due {
+		if err := a.deliverSink(ctx, d); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// claimSinkDeliveries records the settled files as pending deliveries and
+// leases the due deliveries of the sinks registered here.
+func (a *AgentFS) claimSinkDeliveries(ctx context.Context, force bool) ([]sinkJob, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	now := a.FS.now()
+	type settled struct{ sink, path string }
+	var ready []settled
+	r := &a.sinks
+	r.mu.Lock()
+	sinks := make(map[string]*registeredSink, len(r.sinks))
+	for name, rs := range r.sinks {
+		sinks[name] = rs
+	}
+	for p, changed := range r.dirty {
+		var matches []string
+		waiting := false
+		for _, rs := range r.sinks {
+			if ok, _ := path.Match(rs.glob, p); ok {
+				matches = append(matches, rs.name)
+				waiting = waiting || now.Sub(changed) < rs.opts.Settle
+			}
+		}
+		if waiting && !force {
+			continue
+		}
+		for _, name := range matches {
+			ready = append(ready, settled{name, p})
+		}
+		delete(r.dirty, p)
+	}
+	r.mu.Unlock()
+
+	for i, s := range ready {
+		if _, err := a.db.ExecContext(ctx, sinkEnqueue, s.sink, s.path, now.UnixMilli(), now.UnixMilli()); err != nil {
+			// Try again next round
+			r.mu.Lock()
+			for _, s := range ready[i:] {
+				r.dirty[s.path] = now
+			}
+			r.mu.Unlock()
+			return nil, fmt.Errorf("failed to record sink delivery: %w", err)
+		}
+	}
+
+	rows, err := a.db.QueryContext(ctx, sinkDue, now.UnixMilli(), sinkBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sink deliveries: %w", err)
+	}
+	type candidate struct {
+		sinkJob
+		nextAt int64
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		var name string
+		if err := rows.Scan(&c.id, &name, &c.path, &c.attempts, &c.nextAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list sink deliveries: %w", err)
+		}
+		if c.sink = sinks[name]; c.sink != nil {
+			candidates = append(candidates, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sink deliveries: %w", err)
+	}
+
+	var due []sinkJob
+	lease := now.Add(sinkLease).UnixMilli()
+	for _, c := range candidates {
+		res, err := a.db.ExecContext(ctx, sinkClaim, lease, c.id, c.nextAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim sink delivery: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			due = append(due, c.sinkJob)
+		}
+	}
+	return due, nil
+}
+
+// deliverSink attempts one delivery and records the outcome.
+func (a *AgentFS) deliverSink(ctx context.Context, d sinkJob) error {
+	deliverErr := a.sendToSink(ctx, d)
+
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err // The lease runs out and another round retries
+	}
+	defer done()
+
+	now := a.FS.now()
+	switch {
+	case deliverErr == nil:
+		_, err = a.db.ExecContext(ctx, sinkDelivered, now.UnixMilli(), d.id)
+	case d.attempts+1 >= int64(d.sink.opts.MaxAttempts) || IsNotExist(deliverErr):
+		_, err = a.db.ExecContext(ctx, sinkFailed, deliverErr.Error(), d.id)
+	default:
+		delay := d.sink.opts.RetryDelay << uint(min(d.attempts, 30))
+		if delay <= 0 || delay > sinkMaxRetryDelay {
+			delay = sinkMaxRetryDelay
+		}
+		_, err = a.db.ExecContext(ctx, sinkRetry, deliverErr.Error(), now.Add(delay).UnixMilli(), d.id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record sink delivery: %w", err)
+	}
+	if deliverErr != nil {
+		return fmt.Errorf("sink %q: %s: %w", d.sink.name, d.path, deliverErr)
+	}
+	return nil
+}
+
+// sendToSink hands the file at d.path to its sink.
+func (a *AgentFS) sendToSink(ctx context.Context, d sinkJob) error {
+	r, err := a.FS.OpenReader(ctx, d.path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// Hide Close so the sink cannot end the reader early
+	body := struct{ io.Reader }{r}
+	return d.sink.sink.Deliver(ctx, &SinkFile{Path: d.path, Stats: r.Stat(), Body: body})
+}
+
+// SinkDeliveries returns the delivery log, newest first. status limits it
+// to SinkPending, SinkDelivered, or SinkFailed deliveries (default: "",
+// all); limit caps the entries returned (default: <= 0, no limit).
+func (a *AgentFS) SinkDeliveries(ctx context.Context, status string, limit int) ([]SinkDelivery, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := a.db.QueryContext(ctx, sinkLog, status, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sink deliveries: %w", err)
+	}
+	defer rows.Close()
+	var log []SinkDelivery
+	for rows.Next() {
+		var d SinkDelivery
+		if err := rows.Scan(&d.ID, &d.Sink, &d.Path, &d.Status, &d.Attempts, &d.LastError, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to list sink deliveries: %w", err)
+		}
+		log = append(log, d)
+	}
+	return log, rows.Err()
+}
+
+// WebhookSink POSTs each file to url with Content-Type
+// application/octet-stream and the file's path in the X-AgentFS-Path
+// header. Responses other than 2xx fail the delivery.
+func WebhookSink(url string) Sink {
+	return SinkFunc(func(ctx context.Context, f *SinkFile) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, f.Body)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = f.Stats.Size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("X-AgentFS-Path", f.Path)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		return nil
+	})
+}
+
+// DirSink copies each file to the same path below dir on the local
+// filesystem, replacing it atomically so readers never see a partial copy.
+func DirSink(dir string) Sink {
+	return SinkFunc(func(ctx context.Context, f *SinkFile) error {
+		dest := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := io.Copy(tmp, f.Body); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Chmod(tmp.Name(), os.FileMode(f.Stats.Mode&0o777)); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), dest)
+	})
+}
+
+// S3Sink uploads each file to store, keyed by its path without the leading
+// "/" (after S3Options.Prefix). The file is read into memory first.
+func S3Sink(store *S3Store) Sink {
+	return SinkFunc(func(ctx context.Context, f *SinkFile) error {
+		data, err := io.ReadAll(f.Body)
+		if err != nil {
+			return err
+		}
+		return store.Put(ctx, strings.TrimPrefix(f.Path, "/"), data)
+	})
+}
@@ -0,0 +1,65 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOnFileFinalized(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	dir := t.TempDir()
+	if err := afs.OnFileFinalized("/outputs/*.md", DirSink(dir), &SinkOptions{Settle: time.Hour}); err != nil {
+		t.Fatalf("OnFileFinalized failed: %v", err)
+	}
+	calls := 0
+	flaky := SinkFunc(func(ctx context.Context, f *SinkFile) error {
+		calls++
+		return errors.New("unreachable")
+	})
+	opts := &SinkOptions{Name: "flaky", Settle: time.Hour, MaxAttempts: 2, RetryDelay: time.Nanosecond}
+	if err := afs.OnFileFinalized("/outputs/*.md", flaky, opts); err != nil {
+		t.Fatalf("OnFileFinalized failed: %v", err)
+	}
+	if err := afs.OnFileFinalized("/outputs/*.md", flaky, opts); err == nil {
+		t.Error("expected a second sink named flaky to be rejected")
+	}
+
+	afs.FS.WriteFile(ctx, "/outputs/report.md", []byte("draft"), 0o644)
+	afs.FS.WriteFile(ctx, "/outputs/report.md", []byte("final"), 0o644)
+	afs.FS.WriteFile(ctx, "/scratch/notes.md", []byte("skip"), 0o644)
+
+	if err := afs.DeliverSinks(ctx); err == nil {
+		t.Error("expected the flaky sink's error")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "outputs", "report.md")); err != nil || string(data) != "final" {
+		t.Errorf("delivered copy = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scratch")); !os.IsNotExist(err) {
+		t.Error("file outside the glob was delivered")
+	}
+
+	// The flaky sink is retried, then given up on
+	time.Sleep(time.Millisecond)
+	afs.DeliverSinks(ctx)
+	if calls != 2 {
+		t.Errorf("flaky sink called %d times, want 2", calls)
+	}
+	failed, err := afs.SinkDeliveries(ctx, SinkFailed, 0)
+	if err != nil {
+		t.Fatalf("SinkDeliveries failed: %v", err)
+	}
+	if len(failed) != 1 || failed[0].Sink != "flaky" || failed[0].Attempts != 2 || failed[0].LastError != "unreachable" {
+		t.Errorf("failed deliveries = %+v", failed)
+	}
+	delivered, _ := afs.SinkDeliveries(ctx, SinkDelivered, 0)
+	if len(delivered) != 1 || delivered[0].Path != "/outputs/report.md" || delivered[0].Sink != "/outputs/*.md" {
+		t.Errorf("delivered = %+v", delivered)
+	}
+}
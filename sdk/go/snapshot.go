@@ -0,0 +1,233 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrSnapshotNotFound is returned by Restore and DeleteSnapshot for an
+// unknown snapshot name.
+var ErrSnapshotNotFound = errors.New("agentfs: snapshot not found")
+
+// ErrSnapshotExists is returned by Snapshot when the name is taken.
+var ErrSnapshotExists = errors.New("agentfs: snapshot already exists")
+
+// snapshotPageSize is the number of chunks Snapshot reads per query.
+const snapshotPageSize = 256
+
+// SnapshotInfo describes a snapshot.
+type SnapshotInfo struct {
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"created_at"` // Unix seconds
+	// MaxToolCallID is the last tool call recorded before the snapshot.
+	MaxToolCallID int64 `json:"max_tool_call_id"`
+	// ID is the global ID of the snapshot, if AgentFSOptions.IDStrategy
+	// gives one.
+	ID string `json:"id,omitempty"`
+}
+
+// Snapshot captures the files, KV entries, and tool call history under
+// name, inside the database, in one transaction. Chunk contents are stored
+// once however many snapshots contain them, so a snapshot of a mostly
+// unchanged filesystem costs little beyond its metadata. Files in external
+// storage tiers are kept by reference; PruneBlobs leaves them in place
+// while a snapshot needs them.
+//
+// Example:
+//
+//	if err := afs.Snapshot(ctx, "before-refactor"); err != nil {
+//	    return err
+//	}
+//	if err := runTask(ctx, afs); err != nil {
+//	    return afs.Restore(ctx, "before-refactor")
+//	}
+func (a *AgentFS) Snapshot(ctx context.Context, name string) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if name == "" {
+		return fmt.Errorf("snapshot name must not be empty")
+	}
+	if err := a.FS.coalesce.sync(ctx, ""); err != nil {
+		return err
+	}
+	return a.FS.inTx(ctx, func(tfs *Filesystem) error {
+		if _, err := getSnapshot(ctx, tfs.db, name); err == nil {
+			return fmt.Errorf("%w: %s", ErrSnapshotExists, name)
+		} else if !errors.Is(err, ErrSnapshotNotFound) {
+			return err
+		}
+		if _, err := tfs.db.ExecContext(ctx, snapshotInsert, name, tfs.now().Unix(), a.Tools.uids.next()); err != nil {
+			return fmt.Errorf("failed to create snapshot: %w", err)
+		}
+		for _, q := range snapshotCopies {
+			if _, err := tfs.db.ExecContext(ctx, q, name); err != nil {
+				return fmt.Errorf("failed to create snapshot: %w", err)
+			}
+		}
+		return snapshotChunks(ctx, tfs.db, name)
+	})
+}
+
+// snapshotChunks records the chunks of fs_data in the snapshot, storing
+// each distinct content once.
+func snapshotChunks(ctx context.Context, db dbtx, name string) error {
+	type chunk struct {
+		ino, index int64
+		data       []byte
+	}
+	lastIno, lastIndex := int64(-1), int64(-1)
+	for {
+		rows, err := db.QueryContext(ctx, snapshotChunkPage, lastIno, lastIndex, snapshotPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to read chunks: %w", err)
+		}
+		var page []chunk
+		for rows.Next() {
+			var c chunk
+			if err := rows.Scan(&c.ino, &c.index, &c.data); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read chunks: %w", err)
+			}
+			page = append(page, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read chunks: %w", err)
+		}
+
+		for _, c := range page {
+			hash := chunkHash(c.data)
+			if _, err := db.ExecContext(ctx, snapshotChunkPut, hash, c.data); err != nil {
+				return fmt.Errorf("failed to store chunk: %w", err)
+			}
+			if _, err := db.ExecContext(ctx, snapshotDataPut, name, c.ino, c.index, hash); err != nil {
+				return fmt.Errorf("failed to store chunk: %w", err)
+			}
+		}
+		if len(page) < snapshotPageSize {
+			return nil
+		}
+		lastIno, lastIndex = page[len(page)-1].ino, page[len(page)-1].index
+	}
+}
+
+// Restore replaces the files and KV entries with those captured by the
+// snapshot name and forgets the tool calls recorded since, in one
+// transaction. The snapshot is kept, so it can be restored again. Files
+// opened before the restore must not be used afterwards, and content still
+// held by write coalescing is dropped.
+func (a *AgentFS) Restore(ctx context.Context, name string) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	a.FS.coalesce.discard()
+	err = a.FS.inTx(ctx, func(tfs *Filesystem) error {
+		info, err := getSnapshot(ctx, tfs.db, name)
+		if err != nil {
+			return err
+		}
+		for _, q := range snapshotClears {
+			if _, err := tfs.db.ExecContext(ctx, q); err != nil {
+				return fmt.Errorf("failed to restore snapshot: %w", err)
+			}
+		}
+		for _, q := range snapshotRestores {
+			if _, err := tfs.db.ExecContext(ctx, q, name); err != nil {
+				return fmt.Errorf("failed to restore snapshot: %w", err)
+			}
+		}
+		for _, q := range toolCallsAfter {
+			if _, err := tfs.db.ExecContext(ctx, q, info.MaxToolCallID); err != nil {
+				return fmt.Errorf("failed to restore snapshot: %w", err)
+			}
+		}
+		if _, err := pruneArchives(ctx, tfs.db, toolCallArchiveAfter, info.MaxToolCallID); err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+		if err := tfs.noteAllDentries(ctx); err != nil {
+			return err
+		}
+		if err := tfs.checkAll(ctx, "restore", "/"); err != nil {
+			return err
+		}
+		tfs.emit(EventFileWritten, "/", "")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Best effort: the restore replaced every table's rows
+	a.FS.optimizer.run(ctx)
+	return nil
+}
+
+// Snapshots lists the snapshots in the order they were taken.
+func (a *AgentFS) Snapshots(ctx context.Context) ([]SnapshotInfo, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := a.db.QueryContext(ctx, snapshotList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	defer rows.Close()
+	var infos []SnapshotInfo
+	for rows.Next() {
+		var info SnapshotInfo
+		if err := rows.Scan(&info.Name, &info.CreatedAt, &info.MaxToolCallID, &info.ID); err != nil {
+			return nil, fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, rows.Err()
+}
+
+// DeleteSnapshot removes a snapshot and the chunk contents no other
+// snapshot or file version shares.
+func (a *AgentFS) DeleteSnapshot(ctx context.Context, name string) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return a.FS.inTx(ctx, func(tfs *Filesystem) error {
+		if _, err := getSnapshot(ctx, tfs.db, name); err != nil {
+			return err
+		}
+		for _, q := range snapshotDeletes {
+			if _, err := tfs.db.ExecContext(ctx, q, name); err != nil {
+				return fmt.Errorf("failed to delete snapshot: %w", err)
+			}
+		}
+		if _, err := tfs.db.ExecContext(ctx, snapshotChunksPrune); err != nil {
+			return fmt.Errorf("failed to delete snapshot: %w", err)
+		}
+		return nil
+	})
+}
+
+// getSnapshot looks up a snapshot by name.
+func getSnapshot(ctx context.Context, db dbtx, name string) (*SnapshotInfo, error) {
+	info := &SnapshotInfo{}
+	err := db.QueryRowContext(ctx, snapshotGet, name).Scan(&info.Name, &info.CreatedAt, &info.MaxToolCallID, &info.ID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return info, nil
+}
@@ -0,0 +1,102 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/src/main.go", []byte("package main\n"), 0o644)
+	afs.FS.Symlink(ctx, "/src/main.go", "/entry")
+	afs.FS.SetMeta(ctx, "/src/main.go", "status", "good")
+	afs.KV.Set(ctx, "step", 1)
+	kept, err := afs.Tools.Record(ctx, "build", nil, "ok", nil, 1, 2)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	if err := afs.Snapshot(ctx, "good"); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if err := afs.Snapshot(ctx, "good"); !errors.Is(err, ErrSnapshotExists) {
+		t.Errorf("duplicate Snapshot = %v, want ErrSnapshotExists", err)
+	}
+
+	// A failed task makes a mess
+	afs.FS.WriteFile(ctx, "/src/main.go", []byte("broken"), 0o644)
+	afs.FS.WriteFile(ctx, "/src/junk.txt", []byte("junk"), 0o644)
+	afs.FS.SetMeta(ctx, "/src/main.go", "status", "bad")
+	afs.KV.Set(ctx, "step", 2)
+	afs.KV.Set(ctx, "scratch", true)
+	later, _ := afs.Tools.Record(ctx, "deploy", nil, "failed", nil, 3, 4)
+
+	if err := afs.Restore(ctx, "good"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if data, err := afs.FS.ReadFile(ctx, "/entry"); err != nil || string(data) != "package main\n" {
+		t.Errorf("ReadFile(/entry) = %q, %v", data, err)
+	}
+	if _, err := afs.FS.Stat(ctx, "/src/junk.txt"); !IsNotExist(err) {
+		t.Errorf("file created after snapshot survived restore: %v", err)
+	}
+	if v, _, _ := afs.FS.GetMeta(ctx, "/src/main.go", "status"); v != "good" {
+		t.Errorf("metadata = %q, want good", v)
+	}
+	if step, _ := KVGet[int](ctx, afs.KV, "step"); step != 1 {
+		t.Errorf("step = %d, want 1", step)
+	}
+	if has, _ := afs.KV.Has(ctx, "scratch"); has {
+		t.Error("KV key set after snapshot survived restore")
+	}
+	if _, err := afs.Tools.Get(ctx, kept.ID); err != nil {
+		t.Errorf("tool call before snapshot lost: %v", err)
+	}
+	if _, err := afs.Tools.Get(ctx, later.ID); err == nil {
+		t.Error("tool call after snapshot survived restore")
+	}
+
+	// The filesystem keeps working after a restore
+	if err := afs.FS.WriteFile(ctx, "/src/new.go", []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile after restore failed: %v", err)
+	}
+}
+
+func TestSnapshot_SharedChunks(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	big := make([]byte, afs.FS.ChunkSize()*4)
+	afs.FS.WriteFile(ctx, "/big.bin", big, 0o644)
+	afs.Snapshot(ctx, "one")
+	afs.Snapshot(ctx, "two")
+
+	// Zero-filled chunks have identical contents: one stored copy
+	var chunks int
+	afs.DB().QueryRowContext(ctx, "SELECT count(*) FROM fs_snapshot_chunk").Scan(&chunks)
+	if chunks != 1 {
+		t.Errorf("stored %d chunk contents, want 1", chunks)
+	}
+
+	infos, err := afs.Snapshots(ctx)
+	if err != nil || len(infos) != 2 || infos[0].Name != "one" {
+		t.Fatalf("Snapshots = %+v, %v", infos, err)
+	}
+	if err := afs.DeleteSnapshot(ctx, "one"); err != nil {
+		t.Fatalf("DeleteSnapshot failed: %v", err)
+	}
+	afs.DeleteSnapshot(ctx, "two")
+	afs.DB().QueryRowContext(ctx, "SELECT count(*) FROM fs_snapshot_chunk").Scan(&chunks)
+	if chunks != 0 {
+		t.Errorf("%d chunk contents left after deleting all snapshots", chunks)
+	}
+	if err := afs.Restore(ctx, "one"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Restore of deleted snapshot = %v, want ErrSnapshotNotFound", err)
+	}
+}
@@ -0,0 +1,133 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Start begins tracking a tool call made by this call, such as a sub-agent
+// it spawned or a tool it re-entered, so ToolCalls.Tree can reconstruct the
+// hierarchy. The child may complete before or after its parent.
+//
+// Example:
+//
+//	plan, err := afs.Tools.Start(ctx, "planner", task)
+//	step, err := plan.Start(ctx, "web_search", query)
+//	step.Success(ctx, results)
+//	call, err := plan.Success(ctx, summary)
+//	tree, err := afs.Tools.Tree(ctx, call.ID)
+func (pc *PendingCall) Start(ctx context.Context, name string, parameters any) (*PendingCall, error) {
+	return pc.tc.start(ctx, name, parameters, &pc.id)
+}
+
+// SetParent records the tool call parentID as the parent of the tool call
+// id, for calls recorded with Record. It replaces any parent id had, and
+// fails if either call does not exist or parentID descends from id.
+func (tc *ToolCalls) SetParent(ctx context.Context, id, parentID int64) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	err = tc.inTx(ctx, func(db dbtx) error {
+		for _, callID := range []int64{id, parentID} {
+			var exists bool
+			if err := db.QueryRowContext(ctx, toolCallExists, callID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("tool call not found: %d", callID)
+			}
+		}
+		var cycle bool
+		if err := db.QueryRowContext(ctx, toolCallSpansIsAncestor, parentID, id).Scan(&cycle); err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("tool call %d cannot be the parent of its ancestor %d", parentID, id)
+		}
+		_, err := db.ExecContext(ctx, toolCallSpansSet, id, parentID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set parent: %w", err)
+	}
+	return nil
+}
+
+// Tree returns the recorded tool call rootID with its descendants. Calls
+// still running are not included until they complete.
+func (tc *ToolCalls) Tree(ctx context.Context, rootID int64) (*ToolCallNode, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallsTree, rootID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tool call tree: %w", err)
+	}
+	defer rows.Close()
+
+	// Rows are ordered by start time, so children may precede their parent
+	nodes := make(map[int64]*ToolCallNode)
+	parents := make(map[int64]int64)
+	var order []int64
+	for rows.Next() {
+		node := &ToolCallNode{}
+		var params, result, errStr, uid sql.NullString
+		var parentID sql.NullInt64
+		if err := rows.Scan(
+			&node.ID, &node.Name, &params, &result, &errStr,
+			&node.StartedAt, &node.CompletedAt, &node.DurationMs, &uid, &parentID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to query tool call tree: %w", err)
+		}
+		node.UID = uid.String
+		if params.Valid {
+			node.Parameters = json.RawMessage(params.String)
+		}
+		if result.Valid {
+			node.Result = json.RawMessage(result.String)
+		}
+		if errStr.Valid {
+			node.Error = &errStr.String
+		}
+		if _, ok := nodes[node.ID]; ok {
+			continue
+		}
+		nodes[node.ID] = node
+		parents[node.ID] = parentID.Int64
+		order = append(order, node.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query tool call tree: %w", err)
+	}
+
+	root, ok := nodes[rootID]
+	if !ok {
+		return nil, fmt.Errorf("tool call not found: %d", rootID)
+	}
+	for _, id := range order {
+		if parent, ok := nodes[parents[id]]; ok && id != rootID {
+			parent.Children = append(parent.Children, nodes[id])
+		}
+	}
+	return root, nil
+}
+
+// linkSpans records the parent of the tool call id, recorded from the
+// pending call pendingID, and makes it the parent of the children linked
+// to pendingID so far, in the transaction db.
+func linkSpans(ctx context.Context, db dbtx, id, pendingID int64) error {
+	for _, q := range []string{toolCallSpansLink, toolCallSpansResolve, toolCallsPendingResolveParent} {
+		if _, err := db.ExecContext(ctx, q, id, pendingID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
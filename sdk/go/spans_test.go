@@ -0,0 +1,116 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestToolCallTree(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	root, err := afs.Tools.Start(ctx, "agent", nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	search, err := root.Start(ctx, "search", nil)
+	if err != nil {
+		t.Fatalf("Start child failed: %v", err)
+	}
+	fetch, err := search.Start(ctx, "fetch", nil)
+	if err != nil {
+		t.Fatalf("Start grandchild failed: %v", err)
+	}
+	if _, err := fetch.Success(ctx, "page"); err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+	if _, err := search.Success(ctx, "results"); err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+
+	// A child that outlives its parent is linked when it completes
+	summarize, err := root.Start(ctx, "summarize", nil)
+	if err != nil {
+		t.Fatalf("Start child failed: %v", err)
+	}
+	rootCall, err := root.Success(ctx, "done")
+	if err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+	if _, err := summarize.Error(ctx, context.Canceled); err != nil {
+		t.Fatalf("Error failed: %v", err)
+	}
+
+	tree, err := afs.Tools.Tree(ctx, rootCall.ID)
+	if err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+	if tree.Name != "agent" || len(tree.Children) != 2 {
+		t.Fatalf("Tree = %+v, want agent with 2 children", tree)
+	}
+	names := map[string]*ToolCallNode{}
+	for _, c := range tree.Children {
+		names[c.Name] = c
+	}
+	if names["search"] == nil || names["summarize"] == nil {
+		t.Fatalf("children = %+v, want search and summarize", tree.Children)
+	}
+	if c := names["search"].Children; len(c) != 1 || c[0].Name != "fetch" {
+		t.Errorf("search children = %+v, want fetch", c)
+	}
+	if names["summarize"].Error == nil {
+		t.Error("summarize error not recorded")
+	}
+
+	sub, err := afs.Tools.Tree(ctx, names["search"].ID)
+	if err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+	if sub.Name != "search" || len(sub.Children) != 1 {
+		t.Errorf("subtree = %+v, want search with fetch", sub)
+	}
+
+	if _, err := afs.Tools.Tree(ctx, 999); err == nil {
+		t.Error("Tree of a missing call succeeded")
+	}
+}
+
+func TestToolCallSetParent(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	var ids []int64
+	for i, name := range []string{"agent", "tool", "subtool"} {
+		call, err := afs.Tools.Record(ctx, name, nil, nil, nil, int64(100+i), int64(110-i))
+		if err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		ids = append(ids, call.ID)
+	}
+	if err := afs.Tools.SetParent(ctx, ids[1], ids[0]); err != nil {
+		t.Fatalf("SetParent failed: %v", err)
+	}
+	if err := afs.Tools.SetParent(ctx, ids[2], ids[1]); err != nil {
+		t.Fatalf("SetParent failed: %v", err)
+	}
+
+	if err := afs.Tools.SetParent(ctx, ids[0], ids[2]); err == nil {
+		t.Error("SetParent creating a cycle succeeded")
+	}
+	if err := afs.Tools.SetParent(ctx, ids[0], ids[0]); err == nil {
+		t.Error("SetParent of a call to itself succeeded")
+	}
+	if err := afs.Tools.SetParent(ctx, ids[0], 999); err == nil {
+		t.Error("SetParent to a missing call succeeded")
+	}
+
+	tree, err := afs.Tools.Tree(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+	if len(tree.Children) != 1 || len(tree.Children[0].Children) != 1 || tree.Children[0].Children[0].ID != ids[2] {
+		t.Errorf("Tree = %+v, want agent > tool > subtool", tree)
+	}
+}
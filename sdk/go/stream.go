@@ -0,0 +1,332 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultStreamPollInterval is how often a StreamConsumer waiting for
+// records checks for appends made by other processes.
+const DefaultStreamPollInterval = 250 * time.Millisecond
+
+// streamBatch is how many records a StreamConsumer reads at a time.
+const streamBatch = 64
+
+// ErrStreamClosed is returned when appending to a stream that was closed.
+var ErrStreamClosed = errors.New("agentfs: stream closed")
+
+// Stream is a durable, append-only sequence of records, like a named pipe
+// kept in the database. A tool process appends its output and the agent
+// reads it, in the same process or another one sharing the database, with
+// no OS pipe between them. Any number of consumer groups read a stream,
+// each at its own committed position, so a restarted reader resumes where
+// it left off.
+type Stream struct {
+	db     *sql.DB
+	life   *lifecycle
+	events *eventBus
+	clock  Clock
+	name   string
+}
+
+// StreamRecord is a record read from a Stream.
+type StreamRecord struct {
+	// Seq numbers the records of a stream from 1.
+	Seq       int64  `json:"seq"`
+	Data      []byte `json:"data"`
+	WrittenAt int64  `json:"written_at"`
+}
+
+// Stream returns the stream called name, which exists once a record is
+// appended to it. It panics if name is empty.
+//
+// Example:
+//
+//	out := afs.Stream("build-output")
+//	go func() {
+//	    w := out.Writer(ctx)
+//	    cmd.Stdout = w
+//	    cmd.Run()
+//	    w.Close()
+//	}()
+//	io.Copy(os.Stdout, out.Consumer("agent").Reader(ctx))
+func (a *AgentFS) Stream(name string) *Stream {
+	if name == "" {
+		panic("agentfs: empty stream name")
+	}
+	return &Stream{db: a.db, life: a.life, events: a.events, clock: a.FS.clock, name: name}
+}
+
+// Name returns the name of the stream.
+func (s *Stream) Name() string {
+	return s.name
+}
+
+// Append adds data as the next record and returns its sequence number. It
+// fails with ErrStreamClosed once the stream is closed.
+func (s *Stream) Append(ctx context.Context, data []byte) (int64, error) {
+	return s.append(ctx, data, false)
+}
+
+// Close appends the end of the stream. Consumers read the records before
+// it, then get io.EOF.
+func (s *Stream) Close(ctx context.Context) error {
+	_, err := s.append(ctx, nil, true)
+	return err
+}
+
+func (s *Stream) append(ctx context.Context, data []byte, closed bool) (int64, error) {
+	ctx, done, err := s.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	// Appends and commits are single statements, retried while another
+	// writer holds the database
+	var seq int64
+	err = retryBusy(ctx, func() error {
+		return s.db.QueryRowContext(ctx, streamAppend, s.name, data, s.clock.Now().Unix(), closed).Scan(&seq)
+	})
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %s", ErrStreamClosed, s.name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to append to stream: %w", err)
+	}
+	s.events.publish(Event{Kind: EventStreamAppended, Path: s.name})
+	return seq, nil
+}
+
+// Head returns the sequence number of the last record, or 0 if the stream
+// is empty.
+func (s *Stream) Head(ctx context.Context) (int64, error) {
+	ctx, done, err := s.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	var seq int64
+	if err := s.db.QueryRowContext(ctx, streamHead, s.name).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to read stream head: %w", err)
+	}
+	return seq, nil
+}
+
+// Trim removes the records every consumer group has committed, and
+// returns how many it removed. Groups that first read the stream after a
+// Trim start at the oldest remaining record.
+func (s *Stream) Trim(ctx context.Context) (int64, error) {
+	ctx, done, err := s.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	res, err := s.db.ExecContext(ctx, streamTrim, s.name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim stream: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Writer returns an io.WriteCloser that appends each Write as a record and
+// closes the stream on Close.
+func (s *Stream) Writer(ctx context.Context) io.WriteCloser {
+	return &streamWriter{ctx: ctx, s: s}
+}
+
+type streamWriter struct {
+	ctx context.Context
+	s   *Stream
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if _, err := w.s.Append(w.ctx, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *streamWriter) Close() error {
+	return w.s.Close(w.ctx)
+}
+
+// StreamConsumer reads a Stream for one consumer group. Next hands out
+// records in order; Commit records how far the group has got, and a new
+// StreamConsumer for the group starts after the last committed record.
+// Different groups read independently. A StreamConsumer is not safe for
+// concurrent use.
+type StreamConsumer struct {
+	s        *Stream
+	group    string
+	pos      int64 // Seq of the last record handed out; -1 until loaded
+	buf      []StreamRecord
+	closedAt int64 // Seq of the closing record, once read
+}
+
+// Consumer returns a reader of the stream for the consumer group group.
+func (s *Stream) Consumer(group string) *StreamConsumer {
+	return &StreamConsumer{s: s, group: group, pos: -1}
+}
+
+// Position returns the sequence number of the last record handed out by
+// Next, or of the last committed record before the first Next.
+func (c *StreamConsumer) Position() int64 {
+	return max(c.pos, 0)
+}
+
+// Next waits for the record after the consumer's position and returns it.
+// It returns io.EOF once every record before the end of a closed stream
+// has been read, and ctx.Err() if ctx ends first. Appends made through
+// this AgentFS are picked up at once; appends by other processes are found
+// by polling every DefaultStreamPollInterval.
+func (c *StreamConsumer) Next(ctx context.Context) (*StreamRecord, error) {
+	var wake <-chan Event
+	if c.s.events != nil {
+		var cancel func()
+		wake, cancel = c.s.events.subscribe(EventFilter{Kinds: []EventKind{EventStreamAppended}, PathPrefix: c.s.name}, 1)
+		defer cancel()
+	}
+	var ticker *time.Ticker
+	for {
+		rec, err := c.TryNext(ctx)
+		if rec != nil || err != nil {
+			return rec, err
+		}
+		if ticker == nil {
+			ticker = time.NewTicker(DefaultStreamPollInterval)
+			defer ticker.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// TryNext is like Next but returns nil at once if no record is waiting.
+func (c *StreamConsumer) TryNext(ctx context.Context) (*StreamRecord, error) {
+	if len(c.buf) == 0 {
+		if c.closedAt > 0 {
+			return nil, io.EOF
+		}
+		if err := c.fill(ctx); err != nil {
+			return nil, err
+		}
+		if len(c.buf) == 0 {
+			if c.closedAt > 0 {
+				return nil, io.EOF
+			}
+			return nil, nil
+		}
+	}
+	rec := c.buf[0]
+	c.buf = c.buf[1:]
+	c.pos = rec.Seq
+	return &rec, nil
+}
+
+// fill reads the next batch of records after the position.
+func (c *StreamConsumer) fill(ctx context.Context) error {
+	ctx, done, err := c.s.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if c.pos < 0 {
+		err := c.s.db.QueryRowContext(ctx, streamPosition, c.s.name, c.group).Scan(&c.pos)
+		if err == sql.ErrNoRows {
+			c.pos = 0
+		} else if err != nil {
+			return fmt.Errorf("failed to read stream position: %w", err)
+		}
+	}
+
+	rows, err := c.s.db.QueryContext(ctx, streamRead, c.s.name, c.pos, streamBatch)
+	if err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rec StreamRecord
+		var closed bool
+		if err := rows.Scan(&rec.Seq, &rec.Data, &rec.WrittenAt, &closed); err != nil {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+		if closed {
+			c.closedAt = rec.Seq
+			break
+		}
+		c.buf = append(c.buf, rec)
+	}
+	return rows.Err()
+}
+
+// Commit saves the consumer's position for its group, so the records up to
+// it are not handed out to the group again. A group's position never moves
+// back.
+func (c *StreamConsumer) Commit(ctx context.Context) error {
+	ctx, done, err := c.s.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if c.pos <= 0 {
+		return nil
+	}
+	err = retryBusy(ctx, func() error {
+		_, err := c.s.db.ExecContext(ctx, streamCommit, c.s.name, c.group, c.pos, c.s.clock.Now().Unix())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit stream position: %w", err)
+	}
+	return nil
+}
+
+// Reader returns an io.Reader over the bytes of the records after the
+// consumer's position, blocking like Next. Each record is committed once it
+// has been read in full, and the reader returns io.EOF at the end of a
+// closed stream.
+func (c *StreamConsumer) Reader(ctx context.Context) io.Reader {
+	return &streamReader{ctx: ctx, c: c}
+}
+
+type streamReader struct {
+	ctx  context.Context
+	c    *StreamConsumer
+	data []byte
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		rec, err := r.c.Next(r.ctx)
+		if err != nil {
+			return 0, err
+		}
+		r.data = rec.Data
+		if len(r.data) == 0 {
+			if err := r.c.Commit(r.ctx); err != nil {
+				return 0, err
+			}
+		}
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		if err := r.c.Commit(r.ctx); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
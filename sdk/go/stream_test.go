@@ -0,0 +1,109 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	s := afs.Stream("build")
+	for _, rec := range []string{"one\n", "two\n", "three\n"} {
+		if _, err := s.Append(ctx, []byte(rec)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if head, _ := s.Head(ctx); head != 3 {
+		t.Errorf("Head = %d, want 3", head)
+	}
+
+	// Groups read independently and resume from their committed position
+	agent := s.Consumer("agent")
+	rec, err := agent.Next(ctx)
+	if err != nil || string(rec.Data) != "one\n" || rec.Seq != 1 {
+		t.Fatalf("Next = %+v, %v", rec, err)
+	}
+	if err := agent.Commit(ctx); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if rec, _ := s.Consumer("logger").Next(ctx); rec == nil || rec.Seq != 1 {
+		t.Errorf("logger Next = %+v, want seq 1", rec)
+	}
+	agent = s.Consumer("agent")
+	if rec, _ := agent.Next(ctx); rec == nil || rec.Seq != 2 {
+		t.Errorf("resumed Next = %+v, want seq 2", rec)
+	}
+
+	// Next waits for appends
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		w := s.Writer(ctx)
+		w.Write([]byte("four\n"))
+		w.Close()
+	}()
+	data, err := io.ReadAll(agent.Reader(ctx))
+	if err != nil || string(data) != "three\nfour\n" {
+		t.Fatalf("ReadAll = %q, %v", data, err)
+	}
+	if _, err := s.Append(ctx, []byte("late")); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("Append after Close = %v, want ErrStreamClosed", err)
+	}
+
+	// Only records every group committed are trimmed
+	logger := s.Consumer("logger")
+	logger.Next(ctx)
+	logger.Next(ctx)
+	logger.Commit(ctx)
+	if n, err := s.Trim(ctx); err != nil || n != 1 {
+		t.Errorf("Trim = %d, %v; want 1", n, err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := afs.Stream("idle").Consumer("agent").Next(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Next on empty stream = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestStreamConcurrentWriters(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	// Writers and the committing reader contend for the database lock
+	s := afs.Stream("jobs")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, err := s.Append(ctx, []byte("x")); err != nil {
+					t.Errorf("Append failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	c := s.Consumer("agent")
+	for n := 0; n < 100; n++ {
+		if _, err := c.Next(ctx); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if err := c.Commit(ctx); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+	wg.Wait()
+	if head, _ := s.Head(ctx); head != 100 || c.Position() != 100 {
+		t.Errorf("Head = %d, Position = %d; want 100", head, c.Position())
+	}
+}
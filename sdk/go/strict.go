@@ -0,0 +1,274 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// StrictMode selects how operations react to a broken filesystem invariant
+// (see AgentFSOptions.Strict).
+type StrictMode int
+
+const (
+	// StrictOff skips the checks (default).
+	StrictOff StrictMode = iota
+	// StrictError fails the operation with *ErrInvariant. Operations that
+	// run in a transaction, such as Rename, RemoveGlob, ImportDir, and
+	// Restore, are rolled back.
+	StrictError
+	// StrictPanic panics with *ErrInvariant, to stop a test or development
+	// run at the operation that broke the database.
+	StrictPanic
+)
+
+// String returns the name of the mode.
+func (m StrictMode) String() string {
+	switch m {
+	case StrictError:
+		return "error"
+	case StrictPanic:
+		return "panic"
+	default:
+		return "off"
+	}
+}
+
+// ErrInvariant reports that the database no longer matches what the
+// filesystem expects, e.g. a file size that does not cover its chunks.
+// It points at a bug in AgentFS or at writes to its tables from outside.
+type ErrInvariant struct {
+	// Op is the operation after which the check ran.
+	Op string
+	// Path is the path of the operation.
+	Path string
+	// Problems lists the broken invariants (at most 100).
+	Problems []string
+}
+
+func (e *ErrInvariant) Error() string {
+	msg := fmt.Sprintf("%s %s: %d invariant(s) broken", e.Op, e.Path, len(e.Problems))
+	if len(e.Problems) > 0 {
+		msg += ": " + e.Problems[0]
+	}
+	return msg
+}
+
+// CheckInvariants checks the whole filesystem as strict mode does after
+// bulk operations: file sizes cover their chunks, link counts match the
+// directory entries, every entry has an inode and a directory as parent,
+// no rows outlive their inode, and foreign keys hold. It returns
+// *ErrInvariant if any check fails, whatever AgentFSOptions.Strict is.
+func (a *AgentFS) CheckInvariants(ctx context.Context) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	problems, err := a.FS.allProblems(ctx)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &ErrInvariant{Op: "check", Path: "/", Problems: problems}
+	}
+	return nil
+}
+
+// checkFile checks the chunks and link count of the file ino after op on p
+// in strict mode. A removed file passes.
+func (fs *Filesystem) checkFile(ctx context.Context, op, p string, ino int64) error {
+	if fs.strict == StrictOff {
+		return nil
+	}
+	var nlink, entries int64
+	err := fs.db.QueryRowContext(ctx, strictNlinkOf, ino).Scan(&nlink, &entries)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check invariants: %w", err)
+	}
+	var problems []string
+	if ino != RootIno && nlink != entries {
+		problems = append(problems, nlinkProblem(ino, nlink, entries))
+	}
+
+	stats, err := fs.statInode(ctx, ino)
+	if err != nil {
+		return err
+	}
+	chunkSize, err := fs.chunkSizeOf(ctx, ino)
+	if err != nil {
+		return err
+	}
+	rows, err := fs.db.QueryContext(ctx, strictChunksOf, ino)
+	if err != nil {
+		return fmt.Errorf("failed to check invariants: %w", err)
+	}
+	defer rows.Close()
+	prev := int64(-1)
+	for rows.Next() {
+		var index, length int64
+		if err := rows.Scan(&index, &length); err != nil {
+			return fmt.Errorf("failed to check invariants: %w", err)
+		}
+		if problem := chunkProblem(ino, index, prev, length, stats.Size, chunkSize); problem != "" {
+			problems = append(problems, problem)
+		}
+		prev = index
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check invariants: %w", err)
+	}
+	return fs.invariants(op, p, problems)
+}
+
+// checkAll checks the whole filesystem after the bulk operation op on p
+// in strict mode.
+func (fs *Filesystem) checkAll(ctx context.Context, op, p string) error {
+	if fs.strict == StrictOff {
+		return nil
+	}
+	problems, err := fs.allProblems(ctx)
+	if err != nil {
+		return err
+	}
+	return fs.invariants(op, p, problems)
+}
+
+// invariants reports problems as strict mode says.
+func (fs *Filesystem) invariants(op, p string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	if len(problems) > maxCorruptProblems {
+		problems = problems[:maxCorruptProblems]
+	}
+	err := &ErrInvariant{Op: op, Path: p, Problems: problems}
+	if fs.strict == StrictPanic {
+		panic(err)
+	}
+	return err
+}
+
+// allProblems runs every invariant check over the whole filesystem.
+func (fs *Filesystem) allProblems(ctx context.Context) ([]string, error) {
+	var problems []string
+	more := func() bool { return len(problems) < maxCorruptProblems }
+
+	rows, err := fs.db.QueryContext(ctx, strictChunks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	defer rows.Close()
+	lastIno, prev := int64(0), int64(-1)
+	for rows.Next() && more() {
+		var ino, index, length, size int64
+		var meta string
+		if err := rows.Scan(&ino, &index, &length, &size, &meta); err != nil {
+			return nil, fmt.Errorf("failed to check invariants: %w", err)
+		}
+		if ino != lastIno {
+			lastIno, prev = ino, -1
+		}
+		chunkSize := int64(fs.chunkSize)
+		if n, err := strconv.ParseInt(meta, 10, 64); err == nil && n > 0 {
+			chunkSize = n
+		}
+		if problem := chunkProblem(ino, index, prev, length, size, chunkSize); problem != "" {
+			problems = append(problems, problem)
+		}
+		prev = index
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	rows.Close()
+
+	rows, err = fs.db.QueryContext(ctx, strictNlinks, RootIno)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() && more() {
+		var ino, nlink, entries int64
+		if err := rows.Scan(&ino, &nlink, &entries); err != nil {
+			return nil, fmt.Errorf("failed to check invariants: %w", err)
+		}
+		problems = append(problems, nlinkProblem(ino, nlink, entries))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	rows.Close()
+
+	rows, err = fs.db.QueryContext(ctx, strictDentries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() && more() {
+		var parent, ino int64
+		var name string
+		var missing bool
+		if err := rows.Scan(&parent, &name, &ino, &missing); err != nil {
+			return nil, fmt.Errorf("failed to check invariants: %w", err)
+		}
+		if missing {
+			problems = append(problems, fmt.Sprintf("entry %q in directory %d points to missing inode %d", name, parent, ino))
+		} else {
+			problems = append(problems, fmt.Sprintf("entry %q has parent %d, which is not a directory", name, parent))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	rows.Close()
+
+	rows, err = fs.db.QueryContext(ctx, strictOrphans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() && more() {
+		var kind string
+		var ino int64
+		if err := rows.Scan(&kind, &ino); err != nil {
+			return nil, fmt.Errorf("failed to check invariants: %w", err)
+		}
+		problems = append(problems, fmt.Sprintf("%s of missing inode %d", kind, ino))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check invariants: %w", err)
+	}
+	rows.Close()
+
+	fks, err := foreignKeyCheck(ctx, fs.db)
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, fks...), nil
+}
+
+// chunkProblem describes what is wrong with chunk index of the file ino,
+// holding length bytes, given the index of the chunk before it (-1 for
+// none), or returns "".
+func chunkProblem(ino, index, prev, length, size, chunkSize int64) string {
+	switch {
+	case index == prev:
+		return fmt.Sprintf("inode %d stores chunk %d twice", ino, index)
+	case length > chunkSize:
+		return fmt.Sprintf("inode %d chunk %d holds %d bytes, more than its chunk size %d", ino, index, length, chunkSize)
+	case index*chunkSize+length > size:
+		return fmt.Sprintf("inode %d chunk %d ends at byte %d, past the file size %d", ino, index, index*chunkSize+length, size)
+	}
+	return ""
+}
+
+// nlinkProblem describes a link count that does not match the entries.
+func nlinkProblem(ino, nlink, entries int64) string {
+	return fmt.Sprintf("inode %d has link count %d but %d entries", ino, nlink, entries)
+}
@@ -0,0 +1,69 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	ctx := context.Background()
+	afs, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "test.db"), Strict: StrictError})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs.Close()
+
+	// Correct operations pass
+	afs.FS.WriteFile(ctx, "/a.txt", []byte(strings.Repeat("a", 10000)), 0o644)
+	afs.FS.WriteFile(ctx, "/b.txt", []byte("b"), 0o644)
+	if err := afs.FS.Link(ctx, "/a.txt", "/c.txt"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	if err := afs.FS.Unlink(ctx, "/c.txt"); err != nil {
+		t.Fatalf("Unlink failed: %v", err)
+	}
+	if err := afs.FS.EnsurePaths(ctx, []string{"/out/", "/out/log.txt"}); err != nil {
+		t.Fatalf("EnsurePaths failed: %v", err)
+	}
+	if err := afs.CheckInvariants(ctx); err != nil {
+		t.Fatalf("CheckInvariants = %v", err)
+	}
+
+	a, _ := afs.FS.Stat(ctx, "/a.txt")
+	b, _ := afs.FS.Stat(ctx, "/b.txt")
+	afs.DB().ExecContext(ctx, "UPDATE fs_inode SET nlink = 3 WHERE ino = ?", a.Ino)
+	var inv *ErrInvariant
+	err = afs.FS.WriteFile(ctx, "/a.txt", []byte("a"), 0o644)
+	if !errors.As(err, &inv) || inv.Op != "write" || !strings.Contains(inv.Problems[0], "link count 3 but 1 entries") {
+		t.Errorf("write with a bad link count = %v", err)
+	}
+	afs.DB().ExecContext(ctx, "UPDATE fs_inode SET nlink = 1 WHERE ino = ?", a.Ino)
+
+	// Whole-filesystem checks find what the operations did not touch
+	afs.DB().ExecContext(ctx, "UPDATE fs_inode SET size = 0 WHERE ino = ?", b.Ino)
+	afs.DB().ExecContext(ctx, "INSERT INTO fs_meta (ino, key, value) VALUES (999, 'k', 'v')")
+	err = afs.CheckInvariants(ctx)
+	if !errors.As(err, &inv) || len(inv.Problems) != 2 ||
+		!strings.Contains(inv.Problems[0], "past the file size 0") || inv.Problems[1] != "metadata of missing inode 999" {
+		t.Fatalf("CheckInvariants = %v (%q)", err, inv.Problems)
+	}
+
+	afs.FS.strict = StrictPanic
+	func() {
+		defer func() {
+			if _, ok := recover().(*ErrInvariant); !ok {
+				t.Error("StrictPanic did not panic with *ErrInvariant")
+			}
+		}()
+		afs.FS.EnsurePaths(ctx, []string{"/more/"})
+	}()
+
+	// Without strict mode the damage goes unnoticed
+	afs.FS.strict = StrictOff
+	if err := afs.FS.WriteFile(ctx, "/b2.txt", []byte("b"), 0o644); err != nil {
+		t.Errorf("WriteFile without strict mode = %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Summary limits applied when SummaryOptions leaves them unset.
+const (
+	DefaultSummaryMaxEntries = 100
+	DefaultSummaryMaxDepth   = 3
+)
+
+// Summary is a compact description of a directory tree, sized for
+// inclusion in a prompt. Marshal it as JSON or render it with Markdown.
+type Summary struct {
+	Root  string `json:"root"`
+	Dirs  int64  `json:"dirs"`  // Directories below Root
+	Files int64  `json:"files"` // Regular files below Root
+	Bytes int64  `json:"bytes"` // Total size of those files
+	// Entries lists the top of the tree, breadth first, so shallow entries
+	// are kept when MaxEntries cuts the listing short.
+	Entries []*SummaryEntry `json:"entries"`
+	// Omitted is the number of entries directly below Root not listed.
+	Omitted int64 `json:"omitted,omitempty"`
+	// Truncated is set if any entry was left out.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// SummaryEntry is one entry of a Summary.
+type SummaryEntry struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "dir", "file", "symlink", or "other"
+	// Bytes is the file size, or the total size of the files below a
+	// directory, including those not listed.
+	Bytes int64 `json:"bytes"`
+	// Files is the number of regular files below a directory.
+	Files   int64           `json:"files,omitempty"`
+	Entries []*SummaryEntry `json:"entries,omitempty"`
+	// Omitted is the number of direct children of a directory that are not
+	// listed because of MaxEntries or MaxDepth.
+	Omitted int64 `json:"omitted,omitempty"`
+}
+
+// summaryDir collects the children of a directory within MaxDepth.
+type summaryDir struct {
+	entry    *SummaryEntry // nil for the root
+	children []*SummaryEntry
+	dirs     []*summaryDir // parallel to children; nil for non-directories
+	count    int64         // direct children, including those beyond MaxDepth
+}
+
+// Summary describes the tree below root: every entry is counted in the
+// totals, but only the first MaxEntries entries within MaxDepth levels,
+// taken breadth first, are listed. Truncation is marked on each directory
+// with Omitted.
+//
+// Example:
+//
+//	sum, err := afs.FS.Summary(ctx, "/workspace", agentfs.SummaryOptions{MaxEntries: 50})
+//	prompt := "Workspace:\n" + sum.Markdown()
+func (fs *Filesystem) Summary(ctx context.Context, root string, opts SummaryOptions) (*Summary, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultSummaryMaxEntries
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultSummaryMaxDepth
+	}
+	root, err = fs.cleanPath(ctx, "summary", root)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := fs.fsIgnore(ctx, root, opts.Ignore, opts.IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := &Summary{Root: root, Entries: []*SummaryEntry{}}
+	top := &summaryDir{}
+	dirs := map[string]*summaryDir{".": top}
+	err = fs.walk(ctx, root, rules, func(p, rel string, stats *Stats) error {
+		names := strings.Split(rel, "/")
+		depth := len(names)
+
+		if stats.IsDir() {
+			sum.Dirs++
+		} else if stats.IsRegularFile() {
+			sum.Files++
+			sum.Bytes += stats.Size
+			// Charge the file to each listed ancestor
+			for i := 1; i < depth && i <= opts.MaxDepth; i++ {
+				e := dirs[strings.Join(names[:i], "/")].entry
+				e.Files++
+				e.Bytes += stats.Size
+			}
+		}
+
+		parent := dirs[path.Dir(rel)]
+		if parent == nil {
+			return nil // Below MaxDepth
+		}
+		parent.count++
+		if depth > opts.MaxDepth {
+			return nil
+		}
+
+		e := &SummaryEntry{Name: names[depth-1], Type: summaryType(stats)}
+		var d *summaryDir
+		if stats.IsDir() {
+			d = &summaryDir{entry: e}
+			dirs[rel] = d
+		} else {
+			e.Bytes = stats.Size
+		}
+		parent.children = append(parent.children, e)
+		parent.dirs = append(parent.dirs, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// List entries breadth first until MaxEntries is spent
+	budget := opts.MaxEntries
+	level := []*summaryDir{top}
+	for len(level) > 0 {
+		var next []*summaryDir
+		for _, d := range level {
+			listed := d.children
+			if len(listed) > budget {
+				listed = listed[:budget]
+			}
+			budget -= len(listed)
+
+			entries := &sum.Entries
+			if d.entry != nil {
+				entries = &d.entry.Entries
+			}
+			*entries = append(*entries, listed...)
+			omitted := d.count - int64(len(listed))
+			if omitted > 0 {
+				sum.Truncated = true
+				if d.entry != nil {
+					d.entry.Omitted = omitted
+				} else {
+					sum.Omitted = omitted
+				}
+			}
+			for _, child := range d.dirs[:len(listed)] {
+				if child != nil {
+					next = append(next, child)
+				}
+			}
+		}
+		level = next
+	}
+	return sum, nil
+}
+
+// summaryType names the file type of stats for a SummaryEntry.
+func summaryType(stats *Stats) string {
+	switch {
+	case stats.IsDir():
+		return "dir"
+	case stats.IsRegularFile():
+		return "file"
+	case stats.IsSymlink():
+		return "symlink"
+	default:
+		return "other"
+	}
+}
+
+// Markdown renders the summary as a nested list, one entry per line, with
+// sizes and a "… N more" line wherever entries were left out.
+func (s *Summary) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d dirs, %d files, %s)\n", s.Root, s.Dirs, s.Files, formatSize(s.Bytes))
+	writeSummaryEntries(&b, s.Entries, 0)
+	if s.Omitted > 0 {
+		fmt.Fprintf(&b, "- … %d more\n", s.Omitted)
+	}
+	return b.String()
+}
+
+func writeSummaryEntries(b *strings.Builder, entries []*SummaryEntry, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, e := range entries {
+		switch e.Type {
+		case "dir":
+			fmt.Fprintf(b, "%s- %s/ (%d files, %s)\n", indent, e.Name, e.Files, formatSize(e.Bytes))
+		case "file":
+			fmt.Fprintf(b, "%s- %s (%s)\n", indent, e.Name, formatSize(e.Bytes))
+		case "symlink":
+			fmt.Fprintf(b, "%s- %s@\n", indent, e.Name)
+		default:
+			fmt.Fprintf(b, "%s- %s\n", indent, e.Name)
+		}
+		writeSummaryEntries(b, e.Entries, depth+1)
+		if e.Omitted > 0 {
+			fmt.Fprintf(b, "%s  - … %d more\n", indent, e.Omitted)
+		}
+	}
+}
+
+// formatSize renders n bytes with a binary unit, e.g. "1.5 KB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,66 @@
+package agentfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	files := map[string]int{
+		"/ws/README.md":          2048,
+		"/ws/src/main.go":        100,
+		"/ws/src/util.go":        50,
+		"/ws/src/deep/a/b/c.txt": 10,
+	}
+	for p, n := range files {
+		if err := afs.FS.WriteFile(ctx, p, make([]byte, n), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	sum, err := afs.FS.Summary(ctx, "/ws", SummaryOptions{MaxDepth: 2})
+	if err != nil {
+		t.Fatalf("Summary failed: %v", err)
+	}
+	if sum.Files != 4 || sum.Dirs != 4 || sum.Bytes != 2208 {
+		t.Errorf("totals = %d files, %d dirs, %d bytes", sum.Files, sum.Dirs, sum.Bytes)
+	}
+
+	want := `/ws (4 dirs, 4 files, 2.2 KB)
+- README.md (2.0 KB)
+- src/ (3 files, 160 B)
+  - deep/ (1 files, 10 B)
+    - … 1 more
+  - main.go (100 B)
+  - util.go (50 B)
+`
+	if got := sum.Markdown(); got != want {
+		t.Errorf("Markdown =\n%s\nwant\n%s", got, want)
+	}
+
+	// MaxEntries keeps the shallowest entries
+	sum, err = afs.FS.Summary(ctx, "/ws", SummaryOptions{MaxEntries: 3})
+	if err != nil {
+		t.Fatalf("Summary failed: %v", err)
+	}
+	src := sum.Entries[1]
+	if !sum.Truncated || len(src.Entries) != 1 || src.Entries[0].Name != "deep" || src.Omitted != 2 {
+		t.Errorf("src = %+v, want deep listed and 2 omitted", src)
+	}
+	if !strings.Contains(sum.Markdown(), "  - … 2 more\n") {
+		t.Errorf("Markdown lacks truncation marker:\n%s", sum.Markdown())
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 20: "5.0 MB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
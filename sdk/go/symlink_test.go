@@ -178,6 +178,37 @@ func TestSymlinkResolution(t *testing.T) {
 		}
 	})
 
+	t.Run("WriteFile writes through final symlink", func(t *testing.T) {
+		fs, ctx := setupSymlinkTest(t)
+		fs.Mkdir(ctx, "/dir", 0o755)
+		fs.WriteFile(ctx, "/dir/target.txt", []byte("old"), 0o644)
+		fs.Symlink(ctx, "target.txt", "/dir/link.txt")
+		fs.Symlink(ctx, "/dir/new.txt", "/dangling")
+
+		if err := fs.WriteFile(ctx, "/dir/link.txt", []byte("new"), 0o644); err != nil {
+			t.Fatalf("WriteFile through symlink failed: %v", err)
+		}
+		if data, err := fs.ReadFile(ctx, "/dir/target.txt"); err != nil || string(data) != "new" {
+			t.Errorf("target = %q, %v; want %q", data, err, "new")
+		}
+		if stats, err := fs.Lstat(ctx, "/dir/link.txt"); err != nil || !stats.IsSymlink() {
+			t.Errorf("link replaced: %+v, %v", stats, err)
+		}
+
+		// A dangling symlink creates its target
+		if err := fs.WriteFile(ctx, "/dangling", []byte("created"), 0o644); err != nil {
+			t.Fatalf("WriteFile through dangling symlink failed: %v", err)
+		}
+		if data, err := fs.ReadFile(ctx, "/dir/new.txt"); err != nil || string(data) != "created" {
+			t.Errorf("new target = %q, %v; want %q", data, err, "created")
+		}
+
+		fs.Symlink(ctx, "/loop", "/loop")
+		if err := fs.WriteFile(ctx, "/loop", []byte("x"), 0o644); !IsLoop(err) {
+			t.Errorf("WriteFile through symlink loop = %v, want ELOOP", err)
+		}
+	})
+
 	t.Run("dangling symlink: Stat returns ENOENT, Lstat succeeds", func(t *testing.T) {
 		fs, ctx := setupSymlinkTest(t)
 		fs.Symlink(ctx, "/nonexistent", "/dangling")
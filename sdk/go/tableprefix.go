@@ -0,0 +1,341 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// validPrefixPattern matches valid table prefixes (see AgentFSOptions.TablePrefix)
+var validPrefixPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// schemaNamePattern finds the names created by schema statements.
+var schemaNamePattern = regexp.MustCompile(`CREATE\s+(?:UNIQUE\s+)?(?:TABLE|INDEX|TRIGGER|VIEW)\s+(?:IF\s+NOT\s+EXISTS\s+)?(\w+)`)
+
+// schemaNames returns the tables, indexes, and triggers AgentFS creates.
+func schemaNames() map[string]bool {
+	var stmts []string
+	stmts = append(stmts, allSchemaStatements()...)
+	stmts = append(stmts, nsecMigrations()...)
+	stmts = append(stmts, extChunkMigrations()...)
+	stmts = append(stmts, versionMigrations()...)
+	stmts = append(stmts, toolCallMigrations()...)
+	stmts = append(stmts, recordIDMigrations()...)
+	stmts = append(stmts, kvMigrations()...)
+	names := make(map[string]bool)
+	for _, stmt := range stmts {
+		for _, m := range schemaNamePattern.FindAllStringSubmatch(stmt, -1) {
+			names[m[1]] = true
+		}
+	}
+	return names
+}
+
+// maxRewritten bounds the rewritten queries a prefixed database keeps.
+// Queries are mostly constants, but some are built at run time.
+const maxRewritten = 4096
+
+// withTablePrefix returns a database that runs the queries of AgentFS
+// against db with prefix added to the schema's table, index, and trigger
+// names, so AgentFS can share a database with an application's own tables.
+// Its connections are borrowed from db for one statement or transaction,
+// or for as long as a caller holds one with Conn, and are returned
+// afterwards rather than kept idle. Closing it closes db too if owned.
+func withTablePrefix(db *sql.DB, prefix string, owned bool) (*sql.DB, error) {
+	if !validPrefixPattern.MatchString(prefix) {
+		return nil, fmt.Errorf("invalid table prefix %q: must match pattern %s", prefix, validPrefixPattern.String())
+	}
+	c := &prefixConnector{db: db, prefix: prefix, names: schemaNames(), owned: owned, rewritten: make(map[string]string)}
+	// The schema statements are rewritten up front; other queries the
+	// first time they run
+	for _, stmt := range allSchemaStatements() {
+		c.rewrite(stmt)
+	}
+	pdb := sql.OpenDB(c)
+	pdb.SetMaxIdleConns(0)
+	return pdb, nil
+}
+
+// prefixConnector opens connections that rewrite table names and run on a
+// connection of db.
+type prefixConnector struct {
+	db     *sql.DB
+	prefix string
+	names  map[string]bool
+	owned  bool
+
+	mu        sync.Mutex
+	rewritten map[string]string // By original query, up to maxRewritten
+}
+
+func (c *prefixConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &prefixConn{c: c, conn: conn}, nil
+}
+
+func (c *prefixConnector) Driver() driver.Driver {
+	return prefixDriver{}
+}
+
+// Close is called by sql.DB.Close.
+func (c *prefixConnector) Close() error {
+	if c.owned {
+		return c.db.Close()
+	}
+	return nil
+}
+
+// rewrite returns query with the prefix added to the schema names, and to
+// the names of tool call archives, in it. String literals and comments are
+// left as they are, so values spelled out in a query never change.
+func (c *prefixConnector) rewrite(query string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if q, ok := c.rewritten[query]; ok {
+		return q
+	}
+	q := c.prefixNames(query)
+	if len(c.rewritten) < maxRewritten {
+		c.rewritten[query] = q
+	}
+	return q
+}
+
+// prefixNames does the work of rewrite.
+func (c *prefixConnector) prefixNames(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 64)
+	for i := 0; i < len(query); {
+		j := i + 1
+		switch ch := query[i]; {
+		case ch == '\'':
+			// '' inside a literal is an escaped quote, which the scan
+			// passes as the end of one literal and the start of another
+			for j < len(query) && query[j] != '\'' {
+				j++
+			}
+			j = min(j+1, len(query))
+		case strings.HasPrefix(query[i:], "--"):
+			if k := strings.IndexByte(query[i:], '\n'); k >= 0 {
+				j = i + k
+			} else {
+				j = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if k := strings.Index(query[i+2:], "*/"); k >= 0 {
+				j = i + 2 + k + 2
+			} else {
+				j = len(query)
+			}
+		case isIdentStart(ch):
+			for j < len(query) && (isIdentStart(query[j]) || query[j] >= '0' && query[j] <= '9') {
+				j++
+			}
+			if id := query[i:j]; c.names[id] || isArchiveName(id) {
+				b.WriteString(c.prefix)
+			}
+		case ch >= '0' && ch <= '9':
+			// Numbers, including hex ones like 0x10002, are not names
+			for j < len(query) && (isIdentStart(query[j]) || query[j] >= '0' && query[j] <= '9') {
+				j++
+			}
+		}
+		b.WriteString(query[i:j])
+		i = j
+	}
+	return b.String()
+}
+
+// isIdentStart reports whether ch can start an SQL identifier.
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z'
+}
+
+// prefixDriver exists because a connector must name its driver; prefixed
+// databases are only opened through a prefixConnector.
+type prefixDriver struct{}
+
+func (prefixDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("agentfs: prefixed databases cannot be opened by name")
+}
+
+// prefixConn is a connection of the underlying database, running in tx
+// while a transaction is open.
+type prefixConn struct {
+	c    *prefixConnector
+	conn *sql.Conn
+	tx   *sql.Tx
+}
+
+func (pc *prefixConn) q() dbtx {
+	if pc.tx != nil {
+		return pc.tx
+	}
+	return pc.conn
+}
+
+func (pc *prefixConn) Prepare(query string) (driver.Stmt, error) {
+	return &prefixStmt{pc: pc, query: query}, nil
+}
+
+func (pc *prefixConn) Close() error {
+	return pc.conn.Close()
+}
+
+func (pc *prefixConn) Begin() (driver.Tx, error) {
+	return pc.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (pc *prefixConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := pc.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.IsolationLevel(opts.Isolation), ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	pc.tx = tx
+	return prefixTx{pc}, nil
+}
+
+// CheckNamedValue passes arguments through unchanged; the underlying
+// database converts them.
+func (pc *prefixConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (pc *prefixConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return pc.q().ExecContext(ctx, pc.c.rewrite(query), namedArgs(args)...)
+}
+
+func (pc *prefixConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := pc.q().QueryContext(ctx, pc.c.rewrite(query), namedArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return &prefixRows{rows: rows}, nil
+}
+
+func namedArgs(args []driver.NamedValue) []any {
+	out := make([]any, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			out[i] = sql.Named(a.Name, a.Value)
+		} else {
+			out[i] = a.Value
+		}
+	}
+	return out
+}
+
+type prefixTx struct{ pc *prefixConn }
+
+func (t prefixTx) Commit() error {
+	err := t.pc.tx.Commit()
+	t.pc.tx = nil
+	return err
+}
+
+func (t prefixTx) Rollback() error {
+	err := t.pc.tx.Rollback()
+	t.pc.tx = nil
+	return err
+}
+
+// prefixStmt runs its query on each use; the underlying database caches
+// prepared statements itself.
+type prefixStmt struct {
+	pc    *prefixConn
+	query string
+}
+
+func (s *prefixStmt) Close() error  { return nil }
+func (s *prefixStmt) NumInput() int { return -1 }
+
+func (s *prefixStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valueArgs(args))
+}
+
+func (s *prefixStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valueArgs(args))
+}
+
+func (s *prefixStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.pc.ExecContext(ctx, s.query, args)
+}
+
+func (s *prefixStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.pc.QueryContext(ctx, s.query, args)
+}
+
+func valueArgs(args []driver.Value) []driver.NamedValue {
+	out := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		out[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return out
+}
+
+// prefixRows hands out the rows of the underlying database.
+type prefixRows struct {
+	rows  *sql.Rows
+	types []*sql.ColumnType
+}
+
+func (r *prefixRows) Columns() []string {
+	cols, _ := r.rows.Columns()
+	return cols
+}
+
+func (r *prefixRows) ColumnTypeDatabaseTypeName(i int) string {
+	if r.types == nil {
+		r.types, _ = r.rows.ColumnTypes()
+	}
+	if i < len(r.types) {
+		return r.types[i].DatabaseTypeName()
+	}
+	return ""
+}
+
+func (r *prefixRows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *prefixRows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	vals := make([]any, len(dest))
+	ptrs := make([]any, len(dest))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := r.rows.Scan(ptrs...); err != nil {
+		return err
+	}
+	for i, v := range vals {
+		dest[i] = v
+	}
+	return nil
+}
+
+// Compile-time interface checks
+var (
+	_ driver.Connector                      = (*prefixConnector)(nil)
+	_ driver.ConnBeginTx                    = (*prefixConn)(nil)
+	_ driver.ExecerContext                  = (*prefixConn)(nil)
+	_ driver.QueryerContext                 = (*prefixConn)(nil)
+	_ driver.NamedValueChecker              = (*prefixConn)(nil)
+	_ driver.StmtExecContext                = (*prefixStmt)(nil)
+	_ driver.StmtQueryContext               = (*prefixStmt)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*prefixRows)(nil)
+)
@@ -0,0 +1,135 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestTablePrefixSharesAppDatabase(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+	// The application already has a table AgentFS would use
+	if _, err := db.ExecContext(ctx, "CREATE TABLE kv_store (id INTEGER PRIMARY KEY, note TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+
+	afs, err := OpenWith(ctx, db, WithTablePrefix("agentfs_"))
+	if err != nil {
+		t.Fatalf("OpenWith failed: %v", err)
+	}
+	if err := afs.KV.Set(ctx, "k", "v"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/a.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	tx, err := afs.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	tx.KV.Set(ctx, "in-tx", 1)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := afs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The caller's database stays open, with its table untouched
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM kv_store").Scan(&n); err != nil || n != 0 {
+		t.Errorf("app kv_store has %d rows, %v", n, err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM agentfs_kv_store").Scan(&n); err != nil || n != 2 {
+		t.Errorf("agentfs_kv_store has %d rows, %v; want 2", n, err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'fs_inode'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("unprefixed fs_inode exists (%d, %v)", n, err)
+	}
+
+	afs, err = OpenWith(ctx, db, WithTablePrefix("agentfs_"))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer afs.Close()
+	if data, err := afs.FS.ReadFile(ctx, "/a.txt"); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+}
+
+func TestTablePrefixOpen(t *testing.T) {
+	ctx := context.Background()
+	p := filepath.Join(t.TempDir(), "test.db")
+	afs, err := Open(ctx, AgentFSOptions{Path: p, TablePrefix: "agent_"})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	afs.KV.Set(ctx, "k", "v")
+	afs.Close()
+
+	afs, err = Open(ctx, AgentFSOptions{Path: p, TablePrefix: "agent_"})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer afs.Close()
+	if v, err := KVGet[string](ctx, afs.KV, "k"); err != nil || v != "v" {
+		t.Errorf("KVGet = %q, %v", v, err)
+	}
+
+	if _, err := Open(ctx, AgentFSOptions{Path: p, TablePrefix: "bad-prefix"}); err == nil {
+		t.Error("Open with an invalid prefix succeeded")
+	}
+}
+
+func TestPrefixRewrite(t *testing.T) {
+	c := &prefixConnector{prefix: "p_", names: schemaNames(), rewritten: make(map[string]string)}
+	for _, tt := range []struct{ query, want string }{
+		{"SELECT key FROM kv_store WHERE namespace = 'kv_store' AND name = 'it''s fs_config'",
+			"SELECT key FROM p_kv_store WHERE namespace = 'kv_store' AND name = 'it''s fs_config'"},
+		{"SELECT x FROM fs_inode -- fs_inode\nJOIN fs_dentry /* fs_data */ ON 1",
+			"SELECT x FROM p_fs_inode -- fs_inode\nJOIN p_fs_dentry /* fs_data */ ON 1"},
+		{"SELECT COUNT(*) FROM tool_calls_archive_2026_01 WHERE id > 0x10fs_inode",
+			"SELECT COUNT(*) FROM p_tool_calls_archive_2026_01 WHERE id > 0x10fs_inode"},
+	} {
+		if got := c.rewrite(tt.query); got != tt.want {
+			t.Errorf("rewrite(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestTablePrefixReleasesConnections(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	afs, err := OpenWith(ctx, db, WithTablePrefix("agentfs_"))
+	if err != nil {
+		t.Fatalf("OpenWith failed: %v", err)
+	}
+	defer afs.Close()
+	for i := 0; i < 10; i++ {
+		if err := afs.KV.Set(ctx, "k", i); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	// A value holding a schema name is stored as written
+	if err := afs.FS.WriteFile(ctx, "/fs_inode", []byte("fs_inode"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if data, err := afs.FS.ReadFile(ctx, "/fs_inode"); err != nil || string(data) != "fs_inode" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if n := db.Stats().InUse; n != 0 {
+		t.Errorf("%d connections of the caller's pool in use while idle, want 0", n)
+	}
+}
@@ -0,0 +1,332 @@
+package agentfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DefaultInferRows is the number of rows ReadCSV samples to infer column
+// types unless CSVOptions.InferRows is set.
+const DefaultInferRows = 100
+
+// ColumnType is the inferred type of a CSV column.
+type ColumnType string
+
+const (
+	ColumnString ColumnType = "string"
+	ColumnInt    ColumnType = "int"
+	ColumnFloat  ColumnType = "float"
+	ColumnBool   ColumnType = "bool"
+)
+
+// Column describes a column of a CSV file.
+type Column struct {
+	Name string     `json:"name"`
+	Type ColumnType `json:"type"`
+}
+
+// CSVReader iterates over the rows of a CSV file opened with ReadCSV.
+//
+//	for r.Next() {
+//	    rec := r.Record()
+//	}
+//	if err := r.Err(); err != nil { ... }
+type CSVReader struct {
+	// Columns holds the column names and the types inferred from the first
+	// CSVOptions.InferRows rows.
+	Columns []Column
+
+	src     *DecodedReader
+	cr      *csv.Reader
+	pending [][]string // Rows read for inference, not yet returned
+	row     []string
+	rows    int
+	max     int
+	err     error
+}
+
+// ReadCSV opens the CSV file at p for iteration. The header names the
+// columns unless opts.NoHeader is set, and column types are inferred from a
+// sample of rows. Compressed files are decompressed (see OpenDecoded).
+// Close the reader when done.
+//
+// Example:
+//
+//	r, err := afs.FS.ReadCSV(ctx, "/data/sales.csv", &agentfs.CSVOptions{MaxRows: 1000})
+//	if err != nil {
+//	    return err
+//	}
+//	defer r.Close()
+//	for r.Next() {
+//	    total += r.Record()["amount"].(float64)
+//	}
+//	return r.Err()
+func (fs *Filesystem) ReadCSV(ctx context.Context, p string, opts *CSVOptions) (*CSVReader, error) {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	src, err := fs.OpenDecoded(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(src)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.FieldsPerRecord = -1
+	r := &CSVReader{src: src, cr: cr, max: opts.MaxRows}
+
+	var header []string
+	if !opts.NoHeader {
+		if header, err = cr.Read(); err != nil && err != io.EOF {
+			src.Close()
+			return nil, fmt.Errorf("failed to read CSV header of %s: %w", p, err)
+		}
+	}
+
+	infer := opts.InferRows
+	if infer <= 0 {
+		infer = DefaultInferRows
+	}
+	for len(r.pending) < infer {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			src.Close()
+			return nil, fmt.Errorf("failed to read CSV %s: %w", p, err)
+		}
+		r.pending = append(r.pending, row)
+	}
+
+	width := len(header)
+	for _, row := range r.pending {
+		width = max(width, len(row))
+	}
+	r.Columns = make([]Column, width)
+	for i := range r.Columns {
+		name := fmt.Sprintf("col%d", i+1)
+		if i < len(header) && header[i] != "" {
+			name = header[i]
+		}
+		r.Columns[i] = Column{Name: name, Type: inferColumn(r.pending, i)}
+	}
+	return r, nil
+}
+
+// inferColumn returns the narrowest type that parses every non-empty value
+// of column i.
+func inferColumn(rows [][]string, i int) ColumnType {
+	isInt, isFloat, isBool, seen := true, true, true, false
+	for _, row := range rows {
+		if i >= len(row) || row[i] == "" {
+			continue
+		}
+		seen = true
+		v := row[i]
+		if isInt {
+			_, err := strconv.ParseInt(v, 10, 64)
+			isInt = err == nil
+		}
+		if isFloat {
+			_, err := strconv.ParseFloat(v, 64)
+			isFloat = err == nil
+		}
+		if isBool {
+			_, err := strconv.ParseBool(v)
+			isBool = err == nil && !isFloat // "1" and "0" are numbers
+		}
+	}
+	switch {
+	case !seen:
+		return ColumnString
+	case isInt:
+		return ColumnInt
+	case isFloat:
+		return ColumnFloat
+	case isBool:
+		return ColumnBool
+	}
+	return ColumnString
+}
+
+// Next advances to the next row. It returns false at the end of the file,
+// after CSVOptions.MaxRows rows, or on an error (see Err).
+func (r *CSVReader) Next() bool {
+	if r.err != nil || (r.max > 0 && r.rows >= r.max) {
+		return false
+	}
+	if len(r.pending) > 0 {
+		r.row, r.pending = r.pending[0], r.pending[1:]
+	} else {
+		row, err := r.cr.Read()
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+			}
+			return false
+		}
+		r.row = row
+	}
+	r.rows++
+	return true
+}
+
+// Row returns the fields of the current row as read.
+func (r *CSVReader) Row() []string {
+	return r.row
+}
+
+// Values returns the fields of the current row converted to their column
+// types: int64, float64, bool, or string. Empty fields are nil, and values
+// that do not parse as their column type are left as strings.
+func (r *CSVReader) Values() []any {
+	values := make([]any, len(r.Columns))
+	for i, col := range r.Columns {
+		if i >= len(r.row) || r.row[i] == "" {
+			continue
+		}
+		values[i] = convertField(r.row[i], col.Type)
+	}
+	return values
+}
+
+// Record returns the current row as converted values (see Values) keyed by
+// column name.
+func (r *CSVReader) Record() map[string]any {
+	rec := make(map[string]any, len(r.Columns))
+	for i, v := range r.Values() {
+		rec[r.Columns[i].Name] = v
+	}
+	return rec
+}
+
+func convertField(v string, t ColumnType) any {
+	switch t {
+	case ColumnInt:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case ColumnFloat:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case ColumnBool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+// Err returns the error that stopped Next, if any.
+func (r *CSVReader) Err() error {
+	return r.err
+}
+
+// Close releases the file.
+func (r *CSVReader) Close() error {
+	return r.src.Close()
+}
+
+// JSONLReader iterates over the records of a JSON Lines file opened with
+// ReadJSONL, decoding each into a T.
+type JSONLReader[T any] struct {
+	src   *DecodedReader
+	br    *bufio.Reader
+	opts  JSONLOptions
+	value T
+	line  int
+	rows  int
+	err   error
+}
+
+// ReadJSONL opens the JSON Lines file at p for iteration, decoding each
+// non-blank line into a T. Compressed files are decompressed (see
+// OpenDecoded). Close the reader when done.
+//
+// Example:
+//
+//	type Event struct {
+//	    Kind string `json:"kind"`
+//	}
+//	r, err := agentfs.ReadJSONL[Event](ctx, afs.FS, "/data/events.jsonl", nil)
+//	if err != nil {
+//	    return err
+//	}
+//	defer r.Close()
+//	for r.Next() {
+//	    counts[r.Value().Kind]++
+//	}
+//	return r.Err()
+func ReadJSONL[T any](ctx context.Context, fs *Filesystem, p string, opts *JSONLOptions) (*JSONLReader[T], error) {
+	src, err := fs.OpenDecoded(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	r := &JSONLReader[T]{src: src, br: bufio.NewReader(src)}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return r, nil
+}
+
+// Next decodes the next record. It returns false at the end of the file,
+// after JSONLOptions.MaxRows records, or on an error (see Err).
+func (r *JSONLReader[T]) Next() bool {
+	for r.err == nil && (r.opts.MaxRows <= 0 || r.rows < r.opts.MaxRows) {
+		line, err := r.br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			r.err = err
+			return false
+		}
+		if len(line) == 0 && err == io.EOF {
+			return false
+		}
+		r.line++
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+
+		var v T
+		if derr := json.Unmarshal(line, &v); derr != nil {
+			if r.opts.SkipInvalid {
+				continue
+			}
+			r.err = fmt.Errorf("line %d: %w", r.line, derr)
+			return false
+		}
+		r.value = v
+		r.rows++
+		return true
+	}
+	return false
+}
+
+// Value returns the current record.
+func (r *JSONLReader[T]) Value() T {
+	return r.value
+}
+
+// Line returns the line number of the current record.
+func (r *JSONLReader[T]) Line() int {
+	return r.line
+}
+
+// Err returns the error that stopped Next, if any.
+func (r *JSONLReader[T]) Err() error {
+	return r.err
+}
+
+// Close releases the file.
+func (r *JSONLReader[T]) Close() error {
+	return r.src.Close()
+}
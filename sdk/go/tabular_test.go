@@ -0,0 +1,111 @@
+package agentfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	csvData := "name,qty,price,active,note\nwidget,3,1.5,true,\ngadget,10,2,false,fragile\ngizmo,,3.25,true,x\n"
+	afs.FS.WriteFile(ctx, "/data/items.csv", []byte(csvData), 0o644)
+
+	r, err := afs.FS.ReadCSV(ctx, "/data/items.csv", nil)
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	defer r.Close()
+
+	want := []Column{
+		{"name", ColumnString}, {"qty", ColumnInt}, {"price", ColumnFloat},
+		{"active", ColumnBool}, {"note", ColumnString},
+	}
+	for i, col := range want {
+		if r.Columns[i] != col {
+			t.Errorf("column %d = %+v, want %+v", i, r.Columns[i], col)
+		}
+	}
+
+	var rows []map[string]any
+	for r.Next() {
+		rows = append(rows, r.Record())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	if rows[1]["qty"] != int64(10) || rows[1]["price"] != 2.0 || rows[1]["active"] != false {
+		t.Errorf("row 2 = %v", rows[1])
+	}
+	if rows[2]["qty"] != nil {
+		t.Errorf("empty field = %v, want nil", rows[2]["qty"])
+	}
+
+	// Rows beyond the inference sample are still returned, and MaxRows caps them
+	limited, err := afs.FS.ReadCSV(ctx, "/data/items.csv", &CSVOptions{InferRows: 1, MaxRows: 2})
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	defer limited.Close()
+	n := 0
+	for limited.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("MaxRows 2 returned %d rows", n)
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	type event struct {
+		Kind string `json:"kind"`
+		N    int    `json:"n"`
+	}
+	afs.FS.WriteFile(ctx, "/data/events.jsonl", []byte(`{"kind":"a","n":1}
+
+{"kind":"b","n":2}
+not json
+{"kind":"c","n":3}`), 0o644)
+
+	r, err := ReadJSONL[event](ctx, afs.FS, "/data/events.jsonl", nil)
+	if err != nil {
+		t.Fatalf("ReadJSONL failed: %v", err)
+	}
+	var kinds string
+	for r.Next() {
+		kinds += r.Value().Kind
+	}
+	r.Close()
+	if kinds != "ab" || r.Err() == nil {
+		t.Errorf("strict read = %q, %v; want \"ab\" and an error on line 4", kinds, r.Err())
+	}
+
+	r, _ = ReadJSONL[event](ctx, afs.FS, "/data/events.jsonl", &JSONLOptions{SkipInvalid: true})
+	kinds = ""
+	for r.Next() {
+		kinds += r.Value().Kind
+	}
+	r.Close()
+	if kinds != "abc" || r.Err() != nil || r.Line() != 5 {
+		t.Errorf("SkipInvalid read = %q, %v at line %d", kinds, r.Err(), r.Line())
+	}
+
+	r, _ = ReadJSONL[event](ctx, afs.FS, "/data/events.jsonl", &JSONLOptions{MaxRows: 1})
+	n := 0
+	for r.Next() {
+		n++
+	}
+	r.Close()
+	if n != 1 {
+		t.Errorf("MaxRows 1 returned %d records", n)
+	}
+}
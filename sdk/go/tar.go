@@ -0,0 +1,329 @@
+package agentfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ExportTar streams the tree below root to w as a tar archive, with entry
+// names relative to root. Permissions, owners, and timestamps are kept;
+// hard links are stored once and linked, and symlinks, FIFOs, and device
+// nodes are archived as such. Wrap w in a gzip.Writer for a .tar.gz.
+//
+// Example:
+//
+//	f, _ := os.Create("results.tar")
+//	defer f.Close()
+//	err := afs.FS.ExportTar(ctx, f, "/outputs")
+func (fs *Filesystem) ExportTar(ctx context.Context, w io.Writer, root string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	root, err = fs.cleanPath(ctx, "export", root)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	buf := make([]byte, 64<<10)
+	linked := map[int64]*tar.Header{} // First entry of each inode with several links
+	err = fs.walk(ctx, root, nil, func(p, rel string, stats *Stats) error {
+		hdr := &tar.Header{
+			Name:       rel,
+			Mode:       stats.Permissions(),
+			Uid:        int(stats.UID),
+			Gid:        int(stats.GID),
+			ModTime:    stats.MtimeTime(),
+			AccessTime: stats.AtimeTime(),
+			ChangeTime: stats.CtimeTime(),
+			Format:     tar.FormatPAX, // Keeps atime, ctime, and nanoseconds
+		}
+		if !stats.IsDir() && stats.Nlink > 1 {
+			if first, ok := linked[stats.Ino]; ok {
+				// Times from before the first entry's content was read
+				hdr.Typeflag, hdr.Linkname = tar.TypeLink, first.Name
+				hdr.ModTime, hdr.AccessTime, hdr.ChangeTime = first.ModTime, first.AccessTime, first.ChangeTime
+				return tw.WriteHeader(hdr)
+			}
+			linked[stats.Ino] = hdr
+		}
+
+		switch {
+		case stats.IsDir():
+			hdr.Typeflag, hdr.Name = tar.TypeDir, rel+"/"
+		case stats.IsSymlink():
+			link, err := fs.Readlink(ctx, p)
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, link
+		case stats.IsFIFO():
+			hdr.Typeflag = tar.TypeFifo
+		case stats.IsCharDevice(), stats.IsBlockDevice():
+			hdr.Typeflag = tar.TypeChar
+			if stats.IsBlockDevice() {
+				hdr.Typeflag = tar.TypeBlock
+			}
+			hdr.Devmajor, hdr.Devminor = devMajor(stats.Rdev), devMinor(stats.Rdev)
+		case stats.IsRegularFile():
+			hdr.Typeflag, hdr.Size = tar.TypeReg, stats.Size
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			f, err := fs.Open(ctx, p, O_RDONLY)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.CopyBuffer(tw, io.LimitReader(f.WithContext(ctx), stats.Size), buf)
+			return err
+		default:
+			return nil // Sockets have no tar representation
+		}
+		return tw.WriteHeader(hdr)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ImportTar extracts the tar archive read from r below dest, creating dest
+// and any missing directories, e.g. to seed a workspace from a project
+// tarball. Archives compressed with gzip or bzip2 (or a format with a
+// registered decoder, see RegisterDecoder) are decompressed. Permissions
+// and modification, access, and change times are restored to the
+// nanosecond; owners are not. Entry
+// names and hard link targets are confined to dest: "../" prefixes are
+// dropped, and entries reaching outside dest through a symlink, as well as
+// symlinks with absolute targets or targets outside dest, fail the import.
+// Existing files are overwritten.
+//
+// Example:
+//
+//	f, _ := os.Open("project.tar.gz")
+//	defer f.Close()
+//	err := afs.FS.ImportTar(ctx, f, "/workspace")
+func (fs *Filesystem) ImportTar(ctx context.Context, r io.Reader, dest string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	dest, err = fs.cleanPath(ctx, "untar", dest)
+	if err != nil {
+		return err
+	}
+	src, err := tarSource(r)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := fs.MkdirAll(ctx, dest, 0o755); err != nil {
+		return err
+	}
+
+	// Directories get their mode and times once their entries exist
+	var dirs []*tar.Header
+	tr := tar.NewReader(src)
+	buf := make([]byte, 64<<10)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		rel := tarName(hdr.Name)
+		if rel == "" {
+			continue
+		}
+		target := path.Join(dest, rel)
+		perm := hdr.FileInfo().Mode().Perm()
+		linkname := ""
+		if hdr.Typeflag == tar.TypeSymlink {
+			linkname = hdr.Linkname
+		}
+		if err := fs.confineEntry(ctx, "untar", dest, target, linkname); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			if err := fs.MkdirAll(ctx, target, int64(perm)); err != nil {
+				return err
+			}
+			hdr.Name = target
+			dirs = append(dirs, hdr)
+			continue
+		}
+		if err := fs.MkdirAll(ctx, path.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			// A new file, so other hard links to the old one keep their content
+			if err := fs.replace(ctx, target); err != nil {
+				return err
+			}
+			if _, err := fs.copyIn(ctx, target, tr, int64(perm), buf); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := fs.replace(ctx, target); err != nil {
+				return err
+			}
+			if err := fs.Symlink(ctx, hdr.Linkname, target); err != nil {
+				return err
+			}
+			continue // Times would apply to the symlink's target
+		case tar.TypeLink:
+			existing := path.Join(dest, tarName(hdr.Linkname))
+			if err := fs.confineEntry(ctx, "untar", dest, existing, ""); err != nil {
+				return err
+			}
+			if err := fs.replace(ctx, target); err != nil {
+				return err
+			}
+			if err := fs.Link(ctx, existing, target); err != nil {
+				return err
+			}
+			// Linking changed the ctime of the file it shares
+		case tar.TypeFifo, tar.TypeChar, tar.TypeBlock:
+			kind := map[byte]int64{tar.TypeFifo: S_IFIFO, tar.TypeChar: S_IFCHR, tar.TypeBlock: S_IFBLK}[hdr.Typeflag]
+			if err := fs.replace(ctx, target); err != nil {
+				return err
+			}
+			if err := fs.Mknod(ctx, target, kind|int64(perm), makeDev(hdr.Devmajor, hdr.Devminor)); err != nil {
+				return err
+			}
+		default:
+			continue // PAX headers are read by tar.Reader; other types are skipped
+		}
+		if err := fs.setTarTimes(ctx, target, hdr); err != nil {
+			return err
+		}
+	}
+
+	// Deepest first, so setting a directory's times is not undone by
+	// changes to a directory inside it
+	for i := len(dirs) - 1; i >= 0; i-- {
+		hdr := dirs[i]
+		if err := fs.Chmod(ctx, hdr.Name, int64(hdr.FileInfo().Mode().Perm())); err != nil {
+			return err
+		}
+		if err := fs.setTarTimes(ctx, hdr.Name, hdr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTarTimes sets the times of p from hdr, including the ctime Utimens
+// would bump. Archives without an access or change time get the
+// modification time instead.
+func (fs *Filesystem) setTarTimes(ctx context.Context, p string, hdr *tar.Header) error {
+	ino, err := fs.resolvePathFollow(ctx, p, false)
+	if err != nil {
+		return err
+	}
+	mtime, atime, ctime := hdr.ModTime, hdr.AccessTime, hdr.ChangeTime
+	if atime.IsZero() {
+		atime = mtime
+	}
+	if ctime.IsZero() {
+		ctime = mtime
+	}
+	_, err = fs.db.ExecContext(ctx, updateInodeTimes, atime.Unix(), mtime.Unix(), ctime.Unix(),
+		atime.Nanosecond(), mtime.Nanosecond(), ctime.Nanosecond(), ino)
+	return err
+}
+
+// replace removes the file at p, if any, so an entry can take its place.
+func (fs *Filesystem) replace(ctx context.Context, p string) error {
+	if err := fs.Unlink(ctx, p); err != nil && !IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// tarName returns an entry name relative to the extraction root.
+func tarName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// confineEntry refuses an archive entry at target below dest whose parent
+// directory resolves outside dest through symlinks extracted earlier, and a
+// symlink entry (linkname != "") whose target is absolute or leads out of
+// dest, so extraction cannot write outside dest.
+func (fs *Filesystem) confineEntry(ctx context.Context, op, dest, target, linkname string) error {
+	realDest, err := fs.realPath(ctx, dest, true)
+	if err != nil {
+		return err
+	}
+	realParent, err := fs.realPath(ctx, path.Dir(target), true)
+	if err != nil {
+		return err
+	}
+	if !isWithin(realParent, realDest) {
+		return ErrInval(op, target, "entry escapes the destination through a symlink")
+	}
+	if linkname != "" && (path.IsAbs(linkname) || !isWithin(path.Join(realParent, linkname), realDest)) {
+		return ErrInval(op, target, "symlink target escapes the destination")
+	}
+	return nil
+}
+
+// isWithin reports whether the normalized path p is root or below it.
+func isWithin(p, root string) bool {
+	return root == "/" || p == root || strings.HasPrefix(p, root+"/")
+}
+
+// tarSource returns r, decompressed if it starts with the magic bytes of a
+// known compression format.
+func tarSource(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(8)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read tar archive: %w", err)
+	}
+	format, ok := detectCompression(head)
+	if !ok {
+		return io.NopCloser(br), nil
+	}
+	if format.decode == nil {
+		return nil, fmt.Errorf("%w %s: tar archive", ErrNoDecoder, format.name)
+	}
+	dec, err := format.decode(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tar archive: %w", err)
+	}
+	return dec, nil
+}
+
+// devMajor, devMinor, and makeDev split and join device numbers as Linux
+// encodes them.
+func devMajor(rdev int64) int64 {
+	return (rdev>>8)&0xfff | (rdev>>32)&^0xfff
+}
+
+func devMinor(rdev int64) int64 {
+	return rdev&0xff | (rdev>>12)&0xffffff00
+}
+
+func makeDev(major, minor int64) int64 {
+	return (major&0xfff)<<8 | (major&^0xfff)<<32 | minor&0xff | (minor&^0xff)<<12
+}
@@ -0,0 +1,146 @@
+package agentfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+)
+
+func TestTar(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	fs.WriteFile(ctx, "/src/reports/q3.csv", []byte("a,b\n1,2\n"), 0o600)
+	fs.Mkdir(ctx, "/src/empty", 0o700)
+	fs.Symlink(ctx, "reports/q3.csv", "/src/latest")
+	fs.Link(ctx, "/src/reports/q3.csv", "/src/q3.csv")
+	fs.Mknod(ctx, "/src/pipe", S_IFIFO|0o644, 0)
+	fs.UtimesNano(ctx, "/src/reports/q3.csv", 1700000000, 5, 1700000100, 7)
+	fs.Utimes(ctx, "/src/reports", 1600000000, 1600000000)
+	src, _ := fs.Stat(ctx, "/src/reports/q3.csv")
+
+	var buf bytes.Buffer
+	if err := fs.ExportTar(ctx, &buf, "/src"); err != nil {
+		t.Fatalf("ExportTar failed: %v", err)
+	}
+
+	// Compressed archives are recognized
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(buf.Bytes())
+	zw.Close()
+	if err := fs.ImportTar(ctx, &gz, "/copy"); err != nil {
+		t.Fatalf("ImportTar failed: %v", err)
+	}
+
+	// Stat first: reading the file sets its atime
+	stats, err := fs.Stat(ctx, "/copy/reports/q3.csv")
+	if err != nil || stats.Permissions() != 0o600 || stats.Mtime != 1700000100 || stats.MtimeNsec != 7 ||
+		stats.Atime != 1700000000 || stats.AtimeNsec != 5 || stats.Ctime != src.Ctime || stats.CtimeNsec != src.CtimeNsec {
+		t.Errorf("file stats not kept: %+v, %v; source %+v", stats, err, src)
+	}
+	if data, err := fs.ReadFile(ctx, "/copy/reports/q3.csv"); err != nil || string(data) != "a,b\n1,2\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	// Both names share one inode, linked once
+	if link, err := fs.Stat(ctx, "/copy/q3.csv"); err != nil || link.Ino != stats.Ino || link.Nlink != 2 {
+		t.Errorf("hard link not kept: %+v, %v; file %+v", link, err, stats)
+	}
+	if stats, err := fs.Stat(ctx, "/src/q3.csv"); err != nil || stats.Nlink != 2 {
+		t.Errorf("source links changed: %+v, %v", stats, err)
+	}
+	if stats, err := fs.Stat(ctx, "/copy/reports"); err != nil || stats.Mtime != 1600000000 {
+		t.Errorf("directory times not kept: %+v, %v", stats, err)
+	}
+	if stats, err := fs.Stat(ctx, "/copy/empty"); err != nil || !stats.IsDir() || stats.Permissions() != 0o700 {
+		t.Errorf("empty directory not kept: %+v, %v", stats, err)
+	}
+	if link, err := fs.Readlink(ctx, "/copy/latest"); err != nil || link != "reports/q3.csv" {
+		t.Errorf("Readlink = %q, %v", link, err)
+	}
+	if stats, err := fs.Lstat(ctx, "/copy/pipe"); err != nil || !stats.IsFIFO() {
+		t.Errorf("FIFO not kept: %+v, %v", stats, err)
+	}
+
+	// Names cannot escape the destination
+	var evil bytes.Buffer
+	tw := tar.NewWriter(&evil)
+	tw.WriteHeader(&tar.Header{Name: "../../etc/passwd", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+	if err := fs.ImportTar(ctx, &evil, "/jail"); err != nil {
+		t.Fatalf("ImportTar failed: %v", err)
+	}
+	if _, err := fs.Stat(ctx, "/jail/etc/passwd"); err != nil {
+		t.Errorf("entry not confined to the destination: %v", err)
+	}
+	if _, err := fs.Stat(ctx, "/etc/passwd"); !IsNotExist(err) {
+		t.Errorf("entry escaped the destination: %v", err)
+	}
+}
+
+func TestTarSymlinkEscape(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	archive := func(entries ...*tar.Header) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range entries {
+			tw.WriteHeader(hdr)
+			tw.Write(make([]byte, hdr.Size))
+		}
+		tw.Close()
+		return &buf
+	}
+	file := &tar.Header{Name: "l/escaped.txt", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}
+
+	for name, link := range map[string]string{"absolute": "/", "relative": "../.."} {
+		evil := archive(&tar.Header{Name: "l", Linkname: link, Typeflag: tar.TypeSymlink}, file)
+		if err := fs.ImportTar(ctx, evil, "/jail/"+name); err == nil {
+			t.Errorf("%s: ImportTar of a symlink out of dest succeeded", name)
+		}
+		if _, err := fs.Stat(ctx, "/escaped.txt"); !IsNotExist(err) {
+			t.Fatalf("%s: entry escaped the destination: %v", name, err)
+		}
+	}
+
+	// A symlink already in dest is not followed out of it either
+	if err := fs.Symlink(ctx, "/", "/jail/old/l"); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if err := fs.ImportTar(ctx, archive(file), "/jail/old"); err == nil {
+		t.Error("ImportTar through an existing symlink out of dest succeeded")
+	}
+	if _, err := fs.Stat(ctx, "/escaped.txt"); !IsNotExist(err) {
+		t.Fatalf("entry escaped the destination: %v", err)
+	}
+
+	// Symlinks within dest still work
+	ok := archive(
+		&tar.Header{Name: "sub/", Mode: 0o755, Typeflag: tar.TypeDir},
+		&tar.Header{Name: "l", Linkname: "sub", Typeflag: tar.TypeSymlink},
+		file,
+	)
+	if err := fs.ImportTar(ctx, ok, "/jail/ok"); err != nil {
+		t.Fatalf("ImportTar failed: %v", err)
+	}
+	if _, err := fs.Stat(ctx, "/jail/ok/sub/escaped.txt"); err != nil {
+		t.Errorf("entry through an inner symlink not extracted: %v", err)
+	}
+}
+
+func TestDeviceNumbers(t *testing.T) {
+	for _, dev := range [][2]int64{{8, 1}, {259, 65536}, {4095, 255}, {4096, 256}} {
+		rdev := makeDev(dev[0], dev[1])
+		if devMajor(rdev) != dev[0] || devMinor(rdev) != dev[1] {
+			t.Errorf("makeDev(%d, %d) splits into %d, %d", dev[0], dev[1], devMajor(rdev), devMinor(rdev))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+)
+
+// templateParamPattern matches a {{name}} placeholder in a template file.
+// Placeholders of other template languages, e.g. {{ .Name }}, don't match.
+var templateParamPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// TemplateAgentID is the template parameter set to the agent's ID (see
+// Mailbox.AgentID) unless TemplateOptions.Params gives it.
+const TemplateAgentID = "agent_id"
+
+// applyTemplate seeds a new workspace with the files of t. A marker in
+// fs_config makes concurrent first opens seed it once.
+func (a *AgentFS) applyTemplate(ctx context.Context, t TemplateOptions) error {
+	params := map[string]string{}
+	if a.Mailbox != nil {
+		params[TemplateAgentID] = a.Mailbox.AgentID()
+	}
+	for k, v := range t.Params {
+		params[k] = v
+	}
+
+	err := a.FS.inTx(ctx, func(tfs *Filesystem) error {
+		res, err := tfs.db.ExecContext(ctx, initTemplateApplied)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil // Seeded by another process
+		}
+		return fs.WalkDir(t.FS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || p == "." {
+				return err
+			}
+			dst := path.Join("/", p)
+			if d.IsDir() {
+				return tfs.MkdirAll(ctx, dst, 0o755)
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			data, err := fs.ReadFile(t.FS, p)
+			if err != nil {
+				return err
+			}
+			if !looksBinary(data) {
+				if data, err = expandTemplate(dst, data, params); err != nil {
+					return err
+				}
+			}
+			// Embedded files are read-only; only the execute bits carry over
+			mode := int64(0o644)
+			if info.Mode().Perm()&0o111 != 0 {
+				mode = 0o755
+			}
+			return tfs.WriteFile(ctx, dst, data, mode)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply template: %w", err)
+	}
+	return nil
+}
+
+// expandTemplate replaces the {{name}} placeholders in data, the content
+// of the file p, with params.
+func expandTemplate(p string, data []byte, params map[string]string) ([]byte, error) {
+	var missing string
+	out := templateParamPattern.ReplaceAllFunc(data, func(m []byte) []byte {
+		name := string(templateParamPattern.FindSubmatch(m)[1])
+		v, ok := params[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return m
+		}
+		return []byte(v)
+	})
+	if missing != "" {
+		return nil, fmt.Errorf("%s: undefined template parameter %q", p, missing)
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	params := map[string]string{"agent_id": "worker-1", "model": "large"}
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"id = {{agent_id}}", "id = worker-1"},
+		{"{{ agent_id }}/{{model}}", "worker-1/large"},
+		{"no placeholders", "no placeholders"},
+		{"{{ .Go }} stays", "{{ .Go }} stays"},
+	} {
+		got, err := expandTemplate("/f", []byte(tc.in), params)
+		if err != nil {
+			t.Fatalf("expandTemplate(%q) failed: %v", tc.in, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("expandTemplate(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if _, err := expandTemplate("/f", []byte("{{agent_id}} {{region}}"), params); err == nil {
+		t.Error("expandTemplate with an undefined parameter succeeded")
+	}
+}
+
+func TestOpenTemplate(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "worker-1.db")
+	template := TemplateOptions{
+		FS: fstest.MapFS{
+			"config/agent.toml": {Data: []byte("id = \"{{agent_id}}\"\nregion = \"{{region}}\"\n")},
+			"bin/run.sh":        {Data: []byte("#!/bin/sh\n"), Mode: 0o555},
+			"logo.png":          {Data: []byte("\x89PNG\x00{{agent_id}}")},
+		},
+		Params: map[string]string{"region": "eu"},
+	}
+
+	afs, err := Open(ctx, AgentFSOptions{Path: dbPath, Template: template})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, err := afs.FS.ReadFile(ctx, "/config/agent.toml")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if want := "id = \"worker-1\"\nregion = \"eu\"\n"; string(data) != want {
+		t.Errorf("config = %q, want %q", data, want)
+	}
+	if data, _ := afs.FS.ReadFile(ctx, "/logo.png"); string(data) != "\x89PNG\x00{{agent_id}}" {
+		t.Errorf("binary file = %q, want it unchanged", data)
+	}
+	if st, err := afs.FS.Stat(ctx, "/bin/run.sh"); err != nil || st.Mode&0o777 != 0o755 {
+		t.Errorf("Stat(run.sh) = %+v, %v, want mode 0755", st, err)
+	}
+
+	// Existing workspaces are not seeded again
+	if err := afs.FS.WriteFile(ctx, "/config/agent.toml", []byte("edited"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	afs.Close()
+	afs, err = Open(ctx, AgentFSOptions{Path: dbPath, Template: template})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer afs.Close()
+	if data, _ := afs.FS.ReadFile(ctx, "/config/agent.toml"); string(data) != "edited" {
+		t.Errorf("config after reopen = %q, want it kept", data)
+	}
+
+	delete(template.Params, "region")
+	if _, err := Open(ctx, AgentFSOptions{Path: filepath.Join(t.TempDir(), "w.db"), Template: template}); err == nil {
+		t.Error("Open with an undefined template parameter succeeded")
+	}
+}
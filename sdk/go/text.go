@@ -0,0 +1,113 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// binarySniffLen is how many leading bytes are inspected to classify a file,
+// matching the heuristic used by git.
+const binarySniffLen = 8000
+
+// ErrBinary is returned by text APIs such as ReadLines when a file looks
+// like binary content.
+type ErrBinary struct {
+	Path string
+}
+
+func (e *ErrBinary) Error() string {
+	return fmt.Sprintf("%s: binary file", e.Path)
+}
+
+// looksBinary reports whether data contains a NUL byte in its leading bytes.
+func looksBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// IsBinary reports whether the file at p looks like binary content. Only the
+// first few kilobytes are read. Empty files are text.
+func (fs *Filesystem) IsBinary(ctx context.Context, p string) (bool, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	stats, err := fs.Stat(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	if stats.IsDir() {
+		return false, ErrIsDir("read", normalizePath(p))
+	}
+
+	f, err := fs.Open(ctx, p, O_RDONLY)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := f.Pread(ctx, buf, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return looksBinary(buf[:n]), nil
+}
+
+// ReadLines returns up to limit lines of the file at p starting at the
+// 1-based line start, without trailing newlines. A limit <= 0 returns all
+// remaining lines. Binary files are refused with *ErrBinary.
+//
+// Example:
+//
+//	lines, err := afs.FS.ReadLines(ctx, "/src/main.go", 10, 20)
+//	var binErr *agentfs.ErrBinary
+//	if errors.As(err, &binErr) {
+//	    // Not text; don't put it in a prompt
+//	}
+func (fs *Filesystem) ReadLines(ctx context.Context, p string, start, limit int) ([]string, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if start < 1 {
+		return nil, ErrInval("readlines", p, "start line must be >= 1")
+	}
+
+	data, err := fs.ReadFile(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if looksBinary(data) {
+		return nil, &ErrBinary{Path: normalizePath(p)}
+	}
+
+	var lines []string
+	for i, line := range splitLines(data) {
+		if i+1 < start {
+			continue
+		}
+		if limit > 0 && len(lines) >= limit {
+			break
+		}
+		lines = append(lines, string(line))
+	}
+	return lines, nil
+}
+
+// splitLines splits data on newlines. A trailing newline does not produce an
+// empty final line.
+func splitLines(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
@@ -0,0 +1,99 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	files := map[string][]byte{
+		"/text.txt":  []byte("hello\nworld\n"),
+		"/image.png": {0x89, 'P', 'N', 'G', 0x00, 0x01},
+		"/empty":     {},
+	}
+	want := map[string]bool{"/text.txt": false, "/image.png": true, "/empty": false}
+
+	for p, data := range files {
+		if err := afs.FS.WriteFile(ctx, p, data, 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	for p, w := range want {
+		got, err := afs.FS.IsBinary(ctx, p)
+		if err != nil {
+			t.Fatalf("IsBinary(%q) failed: %v", p, err)
+		}
+		if got != w {
+			t.Errorf("IsBinary(%q) = %v, want %v", p, got, w)
+		}
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/f.txt", []byte("one\ntwo\nthree\nfour\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/f.bin", []byte("a\x00b\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tests := []struct {
+		start, limit int
+		want         []string
+	}{
+		{1, 0, []string{"one", "two", "three", "four"}},
+		{2, 2, []string{"two", "three"}},
+		{4, 10, []string{"four"}},
+		{5, 0, nil},
+	}
+	for _, tt := range tests {
+		got, err := afs.FS.ReadLines(ctx, "/f.txt", tt.start, tt.limit)
+		if err != nil {
+			t.Fatalf("ReadLines failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReadLines(%d, %d) = %q, want %q", tt.start, tt.limit, got, tt.want)
+		}
+	}
+
+	_, err := afs.FS.ReadLines(ctx, "/f.bin", 1, 0)
+	var binErr *ErrBinary
+	if !errors.As(err, &binErr) || binErr.Path != "/f.bin" {
+		t.Errorf("ReadLines(binary) err = %v, want *ErrBinary", err)
+	}
+}
+
+func TestGrepBinary(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/d/blob", []byte("needle\x00\x01\x02"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := afs.FS.WriteFile(ctx, "/d/text", []byte("a needle\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	matches, err := afs.FS.Grep(ctx, "/d", "needle", nil)
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	want := []GrepMatch{
+		{Path: "/d/blob", Binary: true},
+		{Path: "/d/text", Line: 1, Text: "a needle"},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("Grep = %+v, want %+v", matches, want)
+	}
+}
@@ -3,93 +3,168 @@ package agentfs
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 )
 
+// Default in-progress tool call settings
+const (
+	DefaultToolHeartbeatInterval = 30 * time.Second
+	DefaultToolStaleAfter        = 2 * time.Minute
+)
+
+// interruptedError is recorded as the error of tool calls whose owner
+// stopped heartbeating before the call completed.
+const interruptedError = "interrupted: owner stopped before the tool call completed"
+
 // ToolCalls provides tool call tracking backed by SQLite.
 type ToolCalls struct {
-	db *sql.DB
+	db      dbtx
+	conn    sqlConn // nil when db is a transaction (see AgentFS.Begin)
+	life    *lifecycle
+	events  *eventBus
+	pending *[]Event // events held until the transaction commits
+	clock   Clock
+	fs      *Filesystem // reads linked files (see ExportEvalSet)
+	uids    *recordIDs  // nil under IDRowID
+
+	// In-progress tracking
+	owner         string // Identifies this instance in tool_calls_pending
+	opts          ToolCallOptions
+	heartbeatOnce sync.Once
+	goBackground  func(fn func(stop <-chan struct{}))
 }
 
 // PendingCall represents an in-progress tool call.
 type PendingCall struct {
 	tc        *ToolCalls
+	id        int64
 	name      string
 	params    json.RawMessage
 	startedAt int64
 }
 
+// ID returns the identifier of the in-progress record for this call.
+// It differs from the ID of the ToolCall recorded on completion.
+func (pc *PendingCall) ID() int64 {
+	return pc.id
+}
+
+// newToolCalls creates a ToolCalls with defaults applied to opts.
+func newToolCalls(db *sql.DB, life *lifecycle, clock Clock, owner string, opts ToolCallOptions, goBackground func(fn func(stop <-chan struct{}))) *ToolCalls {
+	if opts.HeartbeatInterval <= 0 {
+		opts.HeartbeatInterval = DefaultToolHeartbeatInterval
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = DefaultToolStaleAfter
+	}
+
+	return &ToolCalls{
+		db:           db,
+		conn:         db,
+		life:         life,
+		clock:        clock,
+		owner:        owner,
+		opts:         opts,
+		goBackground: goBackground,
+	}
+}
+
 // Start begins tracking a tool call.
-// Returns a PendingCall that should be completed with Success() or Error().
+// The call is persisted as running until it is completed with Success() or
+// Error(), so calls left behind by a crashed process can be detected.
 func (tc *ToolCalls) Start(ctx context.Context, name string, parameters any) (*PendingCall, error) {
+	return tc.start(ctx, name, parameters, nil)
+}
+
+// start begins tracking a tool call, as a child of the pending call
+// parentPendingID if it is not nil.
+func (tc *ToolCalls) start(ctx context.Context, name string, parameters any, parentPendingID *int64) (*PendingCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	var params json.RawMessage
 	if parameters != nil {
-		var err error
 		params, err = json.Marshal(parameters)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal parameters: %w", err)
 		}
 	}
 
+	var paramsPtr *string
+	if params != nil {
+		s := string(params)
+		paramsPtr = &s
+	}
+
+	startedAt := tc.clock.Now().Unix()
+
+	var id int64
+	err = tc.db.QueryRowContext(ctx, toolCallsPendingInsert,
+		name, paramsPtr, startedAt, tc.owner, parentPendingID,
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start tool call: %w", err)
+	}
+
+	tc.startHeartbeat()
+
 	return &PendingCall{
 		tc:        tc,
+		id:        id,
 		name:      name,
 		params:    params,
-		startedAt: time.Now().Unix(),
+		startedAt: startedAt,
 	}, nil
 }
 
 // Success marks the pending call as successful and records it.
 func (pc *PendingCall) Success(ctx context.Context, result any) (*ToolCall, error) {
+	ctx, done, err := pc.tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	var resultJSON json.RawMessage
 	if result != nil {
-		var err error
 		resultJSON, err = json.Marshal(result)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal result: %w", err)
 		}
 	}
 
-	completedAt := time.Now().Unix()
-	durationMs := (completedAt - pc.startedAt) * 1000
-
-	var id int64
-	var paramsPtr *string
-	if pc.params != nil {
-		s := string(pc.params)
-		paramsPtr = &s
-	}
-
 	var resultPtr *string
 	if resultJSON != nil {
 		s := string(resultJSON)
 		resultPtr = &s
 	}
 
-	err := pc.tc.db.QueryRowContext(ctx, toolCallsInsert,
-		pc.name, paramsPtr, resultPtr, nil, pc.startedAt, completedAt, durationMs,
-	).Scan(&id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to record tool call: %w", err)
-	}
-
-	return &ToolCall{
-		ID:          id,
-		Name:        pc.name,
-		Parameters:  pc.params,
-		Result:      resultJSON,
-		Error:       nil,
-		StartedAt:   pc.startedAt,
-		CompletedAt: completedAt,
-		DurationMs:  durationMs,
-	}, nil
+	return pc.complete(ctx, resultPtr, nil)
 }
 
 // Error marks the pending call as failed and records it.
 func (pc *PendingCall) Error(ctx context.Context, err error) (*ToolCall, error) {
-	completedAt := time.Now().Unix()
+	ctx, done, beginErr := pc.tc.life.begin(ctx)
+	if beginErr != nil {
+		return nil, beginErr
+	}
+	defer done()
+
+	errStr := err.Error()
+	return pc.complete(ctx, nil, &errStr)
+}
+
+// complete records the finished call and removes its in-progress record
+// in a single transaction.
+func (pc *PendingCall) complete(ctx context.Context, resultPtr, errStr *string) (*ToolCall, error) {
+	completedAt := pc.tc.clock.Now().Unix()
 	durationMs := (completedAt - pc.startedAt) * 1000
 
 	var paramsPtr *string
@@ -98,31 +173,82 @@ func (pc *PendingCall) Error(ctx context.Context, err error) (*ToolCall, error)
 		paramsPtr = &s
 	}
 
-	errStr := err.Error()
-
 	var id int64
-	queryErr := pc.tc.db.QueryRowContext(ctx, toolCallsInsert,
-		pc.name, paramsPtr, nil, errStr, pc.startedAt, completedAt, durationMs,
-	).Scan(&id)
-	if queryErr != nil {
-		return nil, fmt.Errorf("failed to record tool call: %w", queryErr)
+	uid := pc.tc.uids.next()
+	err := pc.tc.inTx(ctx, func(db dbtx) error {
+		err := db.QueryRowContext(ctx, toolCallsInsert,
+			pc.name, paramsPtr, resultPtr, errStr, pc.startedAt, completedAt, durationMs, uid,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		if err := linkSpans(ctx, db, id, pc.id); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, toolCallOutputResolve, id, pc.id); err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, toolCallsPendingDelete, pc.id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record tool call: %w", err)
 	}
 
-	return &ToolCall{
+	call := &ToolCall{
 		ID:          id,
+		UID:         uidString(uid),
 		Name:        pc.name,
 		Parameters:  pc.params,
-		Result:      nil,
-		Error:       &errStr,
+		Error:       errStr,
 		StartedAt:   pc.startedAt,
 		CompletedAt: completedAt,
 		DurationMs:  durationMs,
-	}, nil
+	}
+	if resultPtr != nil {
+		call.Result = json.RawMessage(*resultPtr)
+	}
+	pc.tc.publish(Event{Kind: EventToolCallCompleted, Path: call.Name, ToolCall: call})
+	return call, nil
+}
+
+// inTx runs fn in a transaction, or within the enclosing one inside
+// AgentFS.Begin.
+func (tc *ToolCalls) inTx(ctx context.Context, fn func(db dbtx) error) error {
+	if tc.conn == nil {
+		return fn(tc.db)
+	}
+	tx, err := tc.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Record inserts a complete tool call record directly.
 // This is an alternative to the Start/Success/Error pattern.
 func (tc *ToolCalls) Record(ctx context.Context, name string, parameters, result any, errMsg *string, startedAt, completedAt int64) (*ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	call, err := insertToolCall(ctx, tc.db, name, parameters, result, errMsg, startedAt, completedAt, tc.uids.next())
+	if err != nil {
+		return nil, err
+	}
+	tc.publish(Event{Kind: EventToolCallCompleted, Path: name, ToolCall: call})
+	return call, nil
+}
+
+// insertToolCall records a complete tool call in db, with the global ID
+// uid if not nil.
+func insertToolCall(ctx context.Context, db dbtx, name string, parameters, result any, errMsg *string, startedAt, completedAt int64, uid *string) (*ToolCall, error) {
 	var paramsJSON json.RawMessage
 	if parameters != nil {
 		var err error
@@ -156,8 +282,8 @@ func (tc *ToolCalls) Record(ctx context.Context, name string, parameters, result
 	}
 
 	var id int64
-	err := tc.db.QueryRowContext(ctx, toolCallsInsert,
-		name, paramsPtr, resultPtr, errMsg, startedAt, completedAt, durationMs,
+	err := db.QueryRowContext(ctx, toolCallsInsert,
+		name, paramsPtr, resultPtr, errMsg, startedAt, completedAt, durationMs, uid,
 	).Scan(&id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to record tool call: %w", err)
@@ -165,6 +291,7 @@ func (tc *ToolCalls) Record(ctx context.Context, name string, parameters, result
 
 	return &ToolCall{
 		ID:          id,
+		UID:         uidString(uid),
 		Name:        name,
 		Parameters:  paramsJSON,
 		Result:      resultJSON,
@@ -177,12 +304,18 @@ func (tc *ToolCalls) Record(ctx context.Context, name string, parameters, result
 
 // Get retrieves a tool call by ID.
 func (tc *ToolCalls) Get(ctx context.Context, id int64) (*ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	var call ToolCall
-	var params, result, errStr sql.NullString
+	var params, result, errStr, uid sql.NullString
 
-	err := tc.db.QueryRowContext(ctx, toolCallsGetByID, id).Scan(
+	err = tc.db.QueryRowContext(ctx, toolCallsGetByID, id).Scan(
 		&call.ID, &call.Name, &params, &result, &errStr,
-		&call.StartedAt, &call.CompletedAt, &call.DurationMs,
+		&call.StartedAt, &call.CompletedAt, &call.DurationMs, &uid,
 	)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("tool call not found: %d", id)
@@ -191,6 +324,7 @@ func (tc *ToolCalls) Get(ctx context.Context, id int64) (*ToolCall, error) {
 		return nil, fmt.Errorf("failed to get tool call: %w", err)
 	}
 
+	call.UID = uid.String
 	if params.Valid {
 		call.Parameters = json.RawMessage(params.String)
 	}
@@ -206,6 +340,12 @@ func (tc *ToolCalls) Get(ctx context.Context, id int64) (*ToolCall, error) {
 
 // GetByName retrieves tool calls by name.
 func (tc *ToolCalls) GetByName(ctx context.Context, name string, limit int) ([]ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	if limit <= 0 {
 		limit = 100
 	}
@@ -221,6 +361,12 @@ func (tc *ToolCalls) GetByName(ctx context.Context, name string, limit int) ([]T
 
 // GetRecent retrieves recent tool calls (since timestamp).
 func (tc *ToolCalls) GetRecent(ctx context.Context, since int64, limit int) ([]ToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	if limit <= 0 {
 		limit = 100
 	}
@@ -234,8 +380,94 @@ func (tc *ToolCalls) GetRecent(ctx context.Context, since int64, limit int) ([]T
 	return scanToolCalls(rows)
 }
 
+// Query returns a page of the tool calls matching q, most recent first.
+// Pass the page's NextCursor as the next query's Cursor, with the same
+// filters, to read the following page; pages stay consistent while new
+// calls are recorded, since a cursor marks a position rather than an offset.
+//
+// Example:
+//
+//	q := agentfs.ToolCallQuery{ToolName: "shell", Status: agentfs.ToolCallFailed}
+//	for {
+//	    page, err := afs.Tools.Query(ctx, q)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    for _, call := range page.Calls {
+//	        fmt.Println(call.ID, *call.Error)
+//	    }
+//	    if page.NextCursor == "" {
+//	        break
+//	    }
+//	    q.Cursor = page.NextCursor
+//	}
+func (tc *ToolCalls) Query(ctx context.Context, q ToolCallQuery) (*ToolCallPage, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	switch q.Status {
+	case "", ToolCallSucceeded, ToolCallFailed:
+	default:
+		return nil, fmt.Errorf("invalid tool call status %q: must be %q or %q", q.Status, ToolCallSucceeded, ToolCallFailed)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	var afterStarted, afterID int64
+	if q.Cursor != "" {
+		if afterStarted, afterID, err = decodeToolCallCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra row tells whether there is a next page
+	rows, err := tc.db.QueryContext(ctx, toolCallsQuery, q.ToolName, q.Status, q.Since, q.Until, q.Limit+1, afterStarted, afterID, q.CollapseRetries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	calls, err := scanToolCalls(rows)
+	if err != nil {
+		return nil, err
+	}
+	page := &ToolCallPage{Calls: calls}
+	if len(calls) > q.Limit {
+		page.Calls = calls[:q.Limit]
+		last := page.Calls[q.Limit-1]
+		page.NextCursor = encodeToolCallCursor(last.StartedAt, last.ID)
+	}
+	return page, nil
+}
+
+// encodeToolCallCursor returns an opaque cursor for the position after the
+// call started at startedAt with id.
+func encodeToolCallCursor(startedAt, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", startedAt, id)))
+}
+
+func decodeToolCallCursor(cursor string) (startedAt, id int64, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		_, err = fmt.Sscanf(string(data), "%d.%d", &startedAt, &id)
+	}
+	if err != nil || id <= 0 {
+		return 0, 0, fmt.Errorf("invalid tool call cursor %q", cursor)
+	}
+	return startedAt, id, nil
+}
+
 // GetStats returns aggregated statistics for tool calls.
 func (tc *ToolCalls) GetStats(ctx context.Context) ([]ToolCallStats, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	rows, err := tc.db.QueryContext(ctx, toolCallsGetStats)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query stats: %w", err)
@@ -259,14 +491,15 @@ func scanToolCalls(rows *sql.Rows) ([]ToolCall, error) {
 	var calls []ToolCall
 	for rows.Next() {
 		var call ToolCall
-		var params, result, errStr sql.NullString
+		var params, result, errStr, uid sql.NullString
 
 		if err := rows.Scan(
 			&call.ID, &call.Name, &params, &result, &errStr,
-			&call.StartedAt, &call.CompletedAt, &call.DurationMs,
+			&call.StartedAt, &call.CompletedAt, &call.DurationMs, &uid,
 		); err != nil {
 			return nil, err
 		}
+		call.UID = uid.String
 
 		if params.Valid {
 			call.Parameters = json.RawMessage(params.String)
@@ -283,3 +516,216 @@ func scanToolCalls(rows *sql.Rows) ([]ToolCall, error) {
 
 	return calls, rows.Err()
 }
+
+// Heartbeat records that the in-progress call with the given ID is still alive,
+// setting its LastSeen. Long-running tools should call this periodically so
+// supervisors in other processes can tell them apart from hung calls (see
+// Stale); the background heartbeat of the owning process does not count, as
+// it shows only that the process is alive.
+// Returns an error if the call is not running.
+func (tc *ToolCalls) Heartbeat(ctx context.Context, id int64) error {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	res, err := tc.db.ExecContext(ctx, toolCallsPendingHeartbeat, tc.clock.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("tool call not running: %d", id)
+	}
+	return nil
+}
+
+// Heartbeat records that the pending call is still alive.
+func (pc *PendingCall) Heartbeat(ctx context.Context) error {
+	return pc.tc.Heartbeat(ctx, pc.id)
+}
+
+// Stale returns running tool calls, from any process, not seen for longer
+// than olderThan: neither Heartbeat nor, for calls never heartbeated, Start
+// was called for them since. They are ordered by LastSeen, oldest first.
+func (tc *ToolCalls) Stale(ctx context.Context, olderThan time.Duration) ([]PendingToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	cutoff := tc.clock.Now().Add(-olderThan).Unix()
+	rows, err := tc.db.QueryContext(ctx, toolCallsPendingOlderThan, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stale tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPendingToolCalls(rows)
+}
+
+// ============================================================================
+// In-progress Tool Call Recovery
+// ============================================================================
+
+// Orphans returns tool calls that were interrupted because their owner
+// stopped heartbeating (typically a crashed process) before completion.
+// Each orphan also has a failed ToolCall record referenced by ToolCallID.
+func (tc *ToolCalls) Orphans(ctx context.Context) ([]PendingToolCall, error) {
+	ctx, done, err := tc.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallsPendingByStatus, ToolCallInterrupted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orphaned tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPendingToolCalls(rows)
+}
+
+// recoverOrphans marks running tool calls owned by other instances whose
+// heartbeat is older than StaleAfter as interrupted, and records a failed
+// ToolCall for each so the history reflects the interruption.
+// Returns the number of calls recovered.
+func (tc *ToolCalls) recoverOrphans(ctx context.Context) (int, error) {
+	cutoff := tc.clock.Now().Add(-tc.opts.StaleAfter).Unix()
+
+	rows, err := tc.db.QueryContext(ctx, toolCallsPendingStale, tc.owner, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	type staleCall struct {
+		id          int64
+		name        string
+		params      sql.NullString
+		startedAt   int64
+		heartbeatAt int64
+	}
+	var stale []staleCall
+	for rows.Next() {
+		var c staleCall
+		if err := rows.Scan(&c.id, &c.name, &c.params, &c.startedAt, &c.heartbeatAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stale = append(stale, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, c := range stale {
+		tx, err := tc.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return recovered, err
+		}
+
+		// Another process may have recovered the call concurrently
+		res, err := tx.ExecContext(ctx, toolCallsPendingMarkInterrupted, c.id)
+		if err != nil {
+			tx.Rollback()
+			return recovered, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			continue
+		}
+
+		var paramsPtr *string
+		if c.params.Valid {
+			paramsPtr = &c.params.String
+		}
+		errStr := interruptedError
+		durationMs := (c.heartbeatAt - c.startedAt) * 1000
+
+		var callID int64
+		err = tx.QueryRowContext(ctx, toolCallsInsert,
+			c.name, paramsPtr, nil, errStr, c.startedAt, c.heartbeatAt, durationMs, tc.uids.next(),
+		).Scan(&callID)
+		if err != nil {
+			tx.Rollback()
+			return recovered, err
+		}
+		if _, err := tx.ExecContext(ctx, toolCallsPendingSetToolCallID, callID, c.id); err != nil {
+			tx.Rollback()
+			return recovered, err
+		}
+		if err := linkSpans(ctx, tx, callID, c.id); err != nil {
+			tx.Rollback()
+			return recovered, err
+		}
+		if _, err := tx.ExecContext(ctx, toolCallOutputResolve, callID, c.id); err != nil {
+			tx.Rollback()
+			return recovered, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return recovered, err
+		}
+		recovered++
+	}
+
+	return recovered, nil
+}
+
+// startHeartbeat starts the background worker that refreshes heartbeats of
+// running calls owned by this instance and recovers stale calls of others.
+// The worker is started lazily on the first Start call.
+func (tc *ToolCalls) startHeartbeat() {
+	if tc.goBackground == nil {
+		return
+	}
+	tc.heartbeatOnce.Do(func() {
+		tc.goBackground(func(stop <-chan struct{}) {
+			ticker := time.NewTicker(tc.opts.HeartbeatInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+				}
+				ctx := context.Background()
+				// Failures are retried on the next tick
+				tc.db.ExecContext(ctx, toolCallsPendingHeartbeatOwner, tc.clock.Now().Unix(), tc.owner)
+				tc.recoverOrphans(ctx)
+			}
+		})
+	})
+}
+
+// scanPendingToolCalls scans rows into a slice of PendingToolCall
+func scanPendingToolCalls(rows *sql.Rows) ([]PendingToolCall, error) {
+	var calls []PendingToolCall
+	for rows.Next() {
+		var call PendingToolCall
+		var params sql.NullString
+		var toolCallID sql.NullInt64
+
+		if err := rows.Scan(
+			&call.ID, &call.Name, &params, &call.StartedAt, &call.HeartbeatAt,
+			&call.LastSeen, &call.Status, &toolCallID,
+		); err != nil {
+			return nil, err
+		}
+
+		if params.Valid {
+			call.Parameters = json.RawMessage(params.String)
+		}
+		if toolCallID.Valid {
+			id := toolCallID.Int64
+			call.ToolCallID = &id
+		}
+
+		calls = append(calls, call)
+	}
+
+	return calls, rows.Err()
+}
@@ -0,0 +1,258 @@
+package agentfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func countPendingToolCalls(t *testing.T, afs *AgentFS, status string) int {
+	t.Helper()
+	var n int
+	err := afs.DB().QueryRow("SELECT COUNT(*) FROM tool_calls_pending WHERE status = ?", status).Scan(&n)
+	if err != nil {
+		t.Fatalf("count pending failed: %v", err)
+	}
+	return n
+}
+
+func TestPendingToolCallLifecycle(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	pending, err := afs.Tools.Start(ctx, "long_tool", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if pending.ID() == 0 {
+		t.Error("PendingCall.ID() is 0")
+	}
+	if n := countPendingToolCalls(t, afs, ToolCallRunning); n != 1 {
+		t.Errorf("running calls = %d, want 1", n)
+	}
+
+	if _, err := pending.Success(ctx, "ok"); err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+	if n := countPendingToolCalls(t, afs, ToolCallRunning); n != 0 {
+		t.Errorf("running calls after Success = %d, want 0", n)
+	}
+}
+
+func TestOrphanedToolCallRecovery(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// First process starts a call and "crashes" without completing it
+	afs1, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := afs1.Tools.Start(ctx, "crashy_tool", map[string]string{"q": "x"}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := afs1.DB().Exec("UPDATE tool_calls_pending SET heartbeat_at = heartbeat_at - 3600"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+	afs1.Close()
+
+	// Second process recovers it on open
+	afs2, err := Open(ctx, AgentFSOptions{Path: dbPath})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer afs2.Close()
+
+	orphans, err := afs2.Tools.Orphans(ctx)
+	if err != nil {
+		t.Fatalf("Orphans failed: %v", err)
+	}
+	if len(orphans) != 1 {
+		t.Fatalf("len(orphans) = %d, want 1", len(orphans))
+	}
+	o := orphans[0]
+	if o.Name != "crashy_tool" || o.Status != ToolCallInterrupted {
+		t.Errorf("orphan = %+v", o)
+	}
+	if o.ToolCallID == nil {
+		t.Fatal("orphan has no ToolCallID")
+	}
+
+	call, err := afs2.Tools.Get(ctx, *o.ToolCallID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if call.Error == nil || *call.Error != interruptedError {
+		t.Errorf("call.Error = %v, want %q", call.Error, interruptedError)
+	}
+}
+
+func TestRecoverOrphansSkipsOwnAndFreshCalls(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if _, err := afs.Tools.Start(ctx, "mine", nil); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := afs.DB().Exec("UPDATE tool_calls_pending SET heartbeat_at = heartbeat_at - 3600"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+
+	// A fresh call from another owner
+	if _, err := afs.DB().Exec(`INSERT INTO tool_calls_pending (name, started_at, heartbeat_at, owner)
+		VALUES ('theirs', unixepoch(), unixepoch(), 'other')`); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	n, err := afs.Tools.recoverOrphans(ctx)
+	if err != nil {
+		t.Fatalf("recoverOrphans failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("recovered = %d, want 0", n)
+	}
+}
+
+func TestToolCallHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	pending, err := afs.Tools.Start(ctx, "slow_tool", nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	other, err := afs.Tools.Start(ctx, "other_tool", nil)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := afs.DB().Exec("UPDATE tool_calls_pending SET last_seen = last_seen - 600"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+
+	// The process heartbeat refreshes neither call
+	if _, err := afs.DB().Exec(toolCallsPendingHeartbeatOwner, time.Now().Unix(), afs.Tools.owner); err != nil {
+		t.Fatalf("owner heartbeat failed: %v", err)
+	}
+	stale, err := afs.Tools.Stale(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("Stale failed: %v", err)
+	}
+	if len(stale) != 2 {
+		t.Fatalf("Stale = %+v, want both calls", stale)
+	}
+
+	// A call's heartbeat refreshes that call only
+	if err := other.Heartbeat(ctx); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	stale, err = afs.Tools.Stale(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("Stale failed: %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != pending.ID() {
+		t.Fatalf("Stale = %+v, want call %d", stale, pending.ID())
+	}
+
+	if err := pending.Heartbeat(ctx); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+
+	stale, err = afs.Tools.Stale(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("Stale failed: %v", err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("Stale after heartbeat = %+v, want none", stale)
+	}
+
+	if _, err := pending.Success(ctx, nil); err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+	if err := afs.Tools.Heartbeat(ctx, pending.ID()); err == nil {
+		t.Error("Heartbeat on completed call should fail")
+	}
+}
+
+func TestToolCallQuery(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	failed := "boom"
+	var shellFailures []int64
+	for i := 0; i < 7; i++ {
+		name, errMsg := "shell", (*string)(nil)
+		if i%2 == 1 {
+			errMsg = &failed
+		}
+		if i == 6 {
+			name = "search"
+		}
+		// Calls 2 and 3 start in the same second, so the cursor needs the ID
+		started := int64(100 + i)
+		if i == 3 {
+			started = 102
+		}
+		call, err := afs.Tools.Record(ctx, name, nil, nil, errMsg, started, started+1)
+		if err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		if name == "shell" && errMsg != nil {
+			shellFailures = append(shellFailures, call.ID)
+		}
+	}
+
+	var all []int64
+	q := ToolCallQuery{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 4 {
+			t.Fatal("Query did not reach the last page")
+		}
+		page, err := afs.Tools.Query(ctx, q)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for _, c := range page.Calls {
+			all = append(all, c.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if len(all) != 7 {
+		t.Fatalf("paged through %v, want 7 calls", all)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i] >= all[i-1] {
+			t.Fatalf("paged through %v, want most recent first", all)
+		}
+	}
+
+	page, err := afs.Tools.Query(ctx, ToolCallQuery{ToolName: "shell", Status: ToolCallFailed})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(page.Calls) != 3 || page.Calls[0].ID != shellFailures[2] || page.Calls[2].ID != shellFailures[0] || page.NextCursor != "" {
+		t.Errorf("Query(failed shell) = %+v, want %v", page, shellFailures)
+	}
+
+	page, err = afs.Tools.Query(ctx, ToolCallQuery{Status: ToolCallSucceeded, Since: 102, Until: 106})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(page.Calls) != 2 || page.Calls[0].StartedAt != 104 || page.Calls[1].StartedAt != 102 {
+		t.Errorf("Query(succeeded in [102, 106)) = %+v", page.Calls)
+	}
+
+	if _, err := afs.Tools.Query(ctx, ToolCallQuery{Status: "running"}); err == nil {
+		t.Error("Query with an invalid status succeeded")
+	}
+	if _, err := afs.Tools.Query(ctx, ToolCallQuery{Cursor: "not a cursor"}); err == nil {
+		t.Error("Query with an invalid cursor succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package agentfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// DefaultTransferBlockSize is the block size used by Checksums when none is
+// given.
+const DefaultTransferBlockSize = 1 << 20
+
+// MaxTransferBlockSize is the largest block size Checksums accepts and the
+// most data one ReadRange call returns, which bounds the memory a single
+// request can make a server allocate.
+const MaxTransferBlockSize = 64 << 20
+
+// BlockChecksum is the SHA-256 of one fixed-size block of a file. The last
+// block may be shorter than the block size.
+type BlockChecksum struct {
+	Index  int64  `json:"index"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ErrChecksumMismatch is returned by WriteBlock when the data does not match
+// the expected checksum. Nothing is written.
+type ErrChecksumMismatch struct {
+	Path   string
+	Offset int64
+	Want   string
+	Got    string
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("%s: checksum mismatch at offset %d: want %s, got %s", e.Path, e.Offset, e.Want, e.Got)
+}
+
+// Checksums returns per-block SHA-256 checksums of the file at p.
+//
+// Together with ReadRange and WriteBlock this supports resumable transfers:
+// the receiver compares its checksums against the sender's and only
+// transfers the blocks that differ or are missing. A blockSize <= 0 uses
+// DefaultTransferBlockSize; one above MaxTransferBlockSize is invalid.
+func (fs *Filesystem) Checksums(ctx context.Context, p string, blockSize int64) ([]BlockChecksum, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if blockSize <= 0 {
+		blockSize = DefaultTransferBlockSize
+	}
+	if blockSize > MaxTransferBlockSize {
+		return nil, ErrInval("checksums", p, "block size exceeds MaxTransferBlockSize")
+	}
+
+	f, err := fs.Open(ctx, p, O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make([]BlockChecksum, 0, (size+blockSize-1)/blockSize)
+	buf := make([]byte, min(blockSize, size))
+	for offset, index := int64(0), int64(0); offset < size; offset, index = offset+blockSize, index+1 {
+		n, err := f.Pread(ctx, buf, offset)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(buf[:n])
+		sums = append(sums, BlockChecksum{
+			Index:  index,
+			Offset: offset,
+			Size:   int64(n),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	return sums, nil
+}
+
+// ReadRange reads up to length bytes of the file at p starting at offset.
+// Fewer bytes are returned at end of file, and at most
+// MaxTransferBlockSize at a time; use OpenReader to stream larger ranges.
+func (fs *Filesystem) ReadRange(ctx context.Context, p string, offset, length int64) ([]byte, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if offset < 0 || length < 0 {
+		return nil, ErrInval("read", p, "negative offset or length")
+	}
+
+	f, err := fs.Open(ctx, p, O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	length = max(0, min(length, size-offset, MaxTransferBlockSize))
+	buf := make([]byte, length)
+	n, err := f.Pread(ctx, buf, offset)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// WriteBlock writes data at offset in the file at p, creating the file if
+// needed, after verifying it against the hex-encoded SHA-256 checksum.
+// An empty checksum skips verification. On mismatch nothing is written and
+// *ErrChecksumMismatch is returned, so the block can be retried.
+//
+// Example:
+//
+//	for _, block := range missing {
+//	    data := download(block.Offset, block.Size)
+//	    err := afs.FS.WriteBlock(ctx, "/artifacts/model.bin", block.Offset, data, block.SHA256)
+//	}
+func (fs *Filesystem) WriteBlock(ctx context.Context, p string, offset int64, data []byte, checksum string) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if offset < 0 {
+		return ErrInval("write", p, "negative offset")
+	}
+	if checksum != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != checksum {
+			return &ErrChecksumMismatch{Path: normalizePath(p), Offset: offset, Want: checksum, Got: got}
+		}
+	}
+
+	f, err := fs.Open(ctx, p, O_WRONLY|O_CREATE)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Pwrite(ctx, data, offset)
+	return err
+}
@@ -0,0 +1,113 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResumableTransfer(t *testing.T) {
+	ctx := context.Background()
+	src := setupTestDB(t)
+	defer src.Close()
+	dst := setupTestDB(t)
+	defer dst.Close()
+
+	const blockSize = 1000
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if err := src.FS.WriteFile(ctx, "/big.bin", data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	want, err := src.FS.Checksums(ctx, "/big.bin", blockSize)
+	if err != nil {
+		t.Fatalf("Checksums failed: %v", err)
+	}
+	if len(want) != 3 || want[2].Size != 500 {
+		t.Fatalf("Checksums = %+v, want 3 blocks with a short tail", want)
+	}
+
+	// An interrupted transfer left only the first block behind
+	if err := dst.FS.WriteBlock(ctx, "/big.bin", 0, data[:blockSize], want[0].SHA256); err != nil {
+		t.Fatalf("WriteBlock failed: %v", err)
+	}
+
+	have, err := dst.FS.Checksums(ctx, "/big.bin", blockSize)
+	if err != nil {
+		t.Fatalf("Checksums failed: %v", err)
+	}
+
+	transferred := 0
+	for i, block := range want {
+		if i < len(have) && have[i] == block {
+			continue
+		}
+		chunk, err := src.FS.ReadRange(ctx, "/big.bin", block.Offset, block.Size)
+		if err != nil {
+			t.Fatalf("ReadRange failed: %v", err)
+		}
+		if err := dst.FS.WriteBlock(ctx, "/big.bin", block.Offset, chunk, block.SHA256); err != nil {
+			t.Fatalf("WriteBlock failed: %v", err)
+		}
+		transferred++
+	}
+	if transferred != 2 {
+		t.Errorf("transferred %d blocks, want 2", transferred)
+	}
+
+	got, err := dst.FS.ReadFile(ctx, "/big.bin")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("transferred file differs from source")
+	}
+}
+
+func TestWriteBlockChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	err := afs.FS.WriteBlock(ctx, "/f.bin", 0, []byte("corrupted"), "00")
+	var mismatch *ErrChecksumMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("WriteBlock err = %v, want *ErrChecksumMismatch", err)
+	}
+	if _, err := afs.FS.Stat(ctx, "/f.bin"); !IsNotExist(err) {
+		t.Errorf("Stat err = %v, want ENOENT (nothing written)", err)
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.FS.WriteFile(ctx, "/f.txt", []byte("0123456789"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	got, err := afs.FS.ReadRange(ctx, "/f.txt", 7, 10)
+	if err != nil {
+		t.Fatalf("ReadRange failed: %v", err)
+	}
+	if string(got) != "789" {
+		t.Errorf("ReadRange = %q, want %q", got, "789")
+	}
+
+	// A huge length is capped at the file size rather than allocated
+	got, err = afs.FS.ReadRange(ctx, "/f.txt", 2, 1<<62)
+	if err != nil || string(got) != "23456789" {
+		t.Errorf("ReadRange with a huge length = %q, %v", got, err)
+	}
+	if got, err := afs.FS.ReadRange(ctx, "/f.txt", 20, 5); err != nil || len(got) != 0 {
+		t.Errorf("ReadRange past the end = %q, %v", got, err)
+	}
+	if _, err := afs.FS.Checksums(ctx, "/f.txt", MaxTransferBlockSize+1); err == nil {
+		t.Error("Checksums with an oversized block succeeded")
+	}
+}
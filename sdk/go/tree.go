@@ -0,0 +1,409 @@
+package agentfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// errStopWalk ends a walk early without reporting an error.
+var errStopWalk = errors.New("stop walk")
+
+// walkFunc is called for every entry below the walk root. p is the absolute
+// path inside AgentFS and rel is relative to the walk root.
+type walkFunc func(p, rel string, stats *Stats) error
+
+// walk visits every entry below root in lexical order, skipping entries
+// matched by rules. Symlinks are reported but not followed.
+func (fs *Filesystem) walk(ctx context.Context, root string, rules *IgnoreRules, fn walkFunc) error {
+	return fs.walkDir(ctx, normalizePath(root), "", rules, fn)
+}
+
+func (fs *Filesystem) walkDir(ctx context.Context, dir, rel string, rules *IgnoreRules, fn walkFunc) error {
+	entries, err := fs.ReaddirPlus(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entryRel := path.Join(rel, entry.Name)
+		if rules.Match(entryRel, entry.Stats.IsDir()) {
+			continue
+		}
+
+		entryPath := path.Join(dir, entry.Name)
+		if err := fn(entryPath, entryRel, entry.Stats); err != nil {
+			return err
+		}
+		if entry.Stats.IsDir() {
+			if err := fs.walkDir(ctx, entryPath, entryRel, rules, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadIgnore merges explicit rules with an ignore file found at root.
+// A missing ignore file is not an error.
+func loadIgnore(rules *IgnoreRules, file string, read func(string) ([]byte, error)) (*IgnoreRules, error) {
+	if file == "" {
+		return rules, nil
+	}
+
+	data, err := read(file)
+	if err != nil {
+		if IsNotExist(err) || errors.Is(err, os.ErrNotExist) {
+			return rules, nil
+		}
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+
+	merged := &IgnoreRules{}
+	if rules != nil {
+		merged.patterns = append(merged.patterns, rules.patterns...)
+	}
+	merged.patterns = append(merged.patterns, ParseIgnore(data).patterns...)
+	return merged, nil
+}
+
+// fsIgnore loads the ignore rules for a walk rooted at root inside AgentFS.
+func (fs *Filesystem) fsIgnore(ctx context.Context, root string, rules *IgnoreRules, file string) (*IgnoreRules, error) {
+	return loadIgnore(rules, file, func(name string) ([]byte, error) {
+		return fs.ReadFile(ctx, path.Join(root, name))
+	})
+}
+
+// ExportDir copies the AgentFS tree at src to a host directory, creating
+// hostDir if needed. Regular files, directories, and symlinks are copied
+// along with their permission bits; other file types are skipped.
+func (fs *Filesystem) ExportDir(ctx context.Context, src, hostDir string, opts *ExportOptions) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	src, err = fs.cleanPath(ctx, "export", src)
+	if err != nil {
+		return err
+	}
+
+	rules, err := fs.fsIgnore(ctx, src, opts.Ignore, opts.IgnoreFile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(hostDir, 0o755); err != nil {
+		return err
+	}
+
+	absHost, err := filepath.Abs(hostDir)
+	if err != nil {
+		return err
+	}
+	tracker := newProgressTracker("export", opts.Progress)
+	st, err := loadResume(ctx, fs.db, fs.clock, opts.ResumeToken, "export", src+"\x00"+absHost, tracker)
+	if err != nil {
+		return err
+	}
+
+	// exported checkpoints an entry once it is on disk
+	exported := func(rel, p string, size int64) error {
+		next := tracker.p
+		next.Items++
+		next.Bytes += size
+		if err := st.save(ctx, fs.db, rel, next); err != nil {
+			return err
+		}
+		return tracker.add(ctx, 1, size, p)
+	}
+
+	err = fs.walk(ctx, src, rules, func(p, rel string, stats *Stats) error {
+		if st.skip(rel) {
+			return nil
+		}
+		if err := opts.Limiter.Wait(ctx, stats.Size); err != nil {
+			return err
+		}
+		target := filepath.Join(hostDir, filepath.FromSlash(rel))
+		perm := os.FileMode(stats.Permissions())
+
+		switch {
+		case stats.IsDir():
+			if err := os.Mkdir(target, perm|0o700); err != nil && !errors.Is(err, os.ErrExist) {
+				return err
+			}
+		case stats.IsSymlink():
+			link, err := fs.Readlink(ctx, p)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				// Created before an interrupted export was checkpointed
+				if existing, rerr := os.Readlink(target); rerr != nil || existing != link {
+					return err
+				}
+			}
+		case stats.IsRegularFile():
+			data, err := fs.ReadFile(ctx, p)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, data, perm); err != nil {
+				return err
+			}
+			return exported(rel, p, int64(len(data)))
+		default:
+			return nil
+		}
+		return exported(rel, p, 0)
+	})
+	if err != nil {
+		return err
+	}
+	return st.finish(ctx, fs.db)
+}
+
+// Find returns the paths below root whose base name matches opts.Name,
+// in lexical order.
+//
+// Example:
+//
+//	paths, err := afs.FS.Find(ctx, "/workspace", &agentfs.FindOptions{Name: "*.go"})
+func (fs *Filesystem) Find(ctx context.Context, root string, opts *FindOptions) ([]string, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+	if opts.Name != "" {
+		if _, err := path.Match(opts.Name, ""); err != nil {
+			return nil, ErrInval("find", root, "bad name pattern")
+		}
+	}
+	root, err = fs.cleanPath(ctx, "find", root)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := fs.fsIgnore(ctx, root, opts.Ignore, opts.IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	err = fs.walk(ctx, root, rules, func(p, rel string, stats *Stats) error {
+		if opts.Name != "" {
+			if ok, _ := path.Match(opts.Name, path.Base(p)); !ok {
+				return nil
+			}
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	return paths, err
+}
+
+// RemoveGlob removes every file, symlink, and special file whose path
+// matches pattern and returns how many entries it removed. The pattern uses
+// path.Match syntax against the whole absolute path, so "*" does not cross
+// "/". Directories are never removed.
+//
+// The removal is not a single SQL statement: the pattern is matched in Go
+// against the entries of one query, and the matches are removed with one
+// set-based statement per table they span (entries, inodes, chunks, and
+// metadata). All of it runs in one transaction, so no entry can change
+// between the match and the removal, and concurrent readers see either
+// none or all of the removals.
+//
+// Example:
+//
+//	n, err := afs.FS.RemoveGlob(ctx, "/tmp/*.log")
+func (fs *Filesystem) RemoveGlob(ctx context.Context, pattern string) (int64, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, ErrInval("removeglob", pattern, "bad pattern")
+	}
+	pattern, err = fs.cleanPath(ctx, "removeglob", pattern)
+	if err != nil {
+		return 0, err
+	}
+	root := globRoot(pattern)
+
+	var removed int64
+	err = fs.inTx(ctx, func(tfs *Filesystem) error {
+		rootIno, err := tfs.resolvePathFollow(ctx, root, true)
+		if IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rows, err := tfs.db.QueryContext(ctx, removeGlobCandidates, rootIno, root)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		ids, inos := []int64{}, []int64{}
+		var paths []string
+		for rows.Next() {
+			var id, ino int64
+			var p string
+			if err := rows.Scan(&id, &ino, &p); err != nil {
+				return err
+			}
+			if ok, _ := path.Match(pattern, p); !ok || tfs.checkWritable(ctx, "removeglob", p) != nil {
+				continue
+			}
+			ids, inos, paths = append(ids, id), append(inos, ino), append(paths, p)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		if len(ids) == 0 {
+			return nil
+		}
+
+		idsJSON, _ := json.Marshal(ids)
+		inosJSON, _ := json.Marshal(inos)
+		res, err := tfs.db.ExecContext(ctx, removeDentriesByID, string(idsJSON))
+		if err != nil {
+			return fmt.Errorf("failed to remove entries: %w", err)
+		}
+		if removed, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		stmts := []string{removeLinksByIno, removeUnlinkedData, removeUnlinkedSymlinks, removeUnlinkedMeta, removeUnlinkedPreviews}
+		if tfs.blobs.inUse() {
+			stmts = append(stmts, removeUnlinkedExtData)
+		}
+		for _, stmt := range append(stmts, removeUnlinkedInodes) {
+			if _, err := tfs.db.ExecContext(ctx, stmt, string(inosJSON)); err != nil {
+				return fmt.Errorf("failed to remove entries: %w", err)
+			}
+		}
+
+		if err := tfs.checkAll(ctx, "removeglob", pattern); err != nil {
+			return err
+		}
+		for _, p := range paths {
+			tfs.emit(EventFileRemoved, p, "")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// globRoot returns the longest directory prefix of pattern that contains
+// no pattern characters.
+func globRoot(pattern string) string {
+	root := "/"
+	for _, name := range splitPath(pattern) {
+		if strings.ContainsAny(name, `*?[\`) {
+			break
+		}
+		root = path.Join(root, name)
+	}
+	if root == pattern {
+		root = path.Dir(root)
+	}
+	return root
+}
+
+// Grep searches regular files below root for lines matching the regular
+// expression pattern. Matches are returned in path and line order. Binary
+// files are not split into lines; a match in one is reported once with
+// Binary set and no text, like grep's "Binary file matches".
+//
+// Example:
+//
+//	matches, err := afs.FS.Grep(ctx, "/workspace", `TODO\(`, nil)
+func (fs *Filesystem) Grep(ctx context.Context, root, pattern string, opts *GrepOptions) ([]GrepMatch, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if opts == nil {
+		opts = &GrepOptions{}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, ErrInval("grep", root, err.Error())
+	}
+	root, err = fs.cleanPath(ctx, "grep", root)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := fs.fsIgnore(ctx, root, opts.Ignore, opts.IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []GrepMatch
+	err = fs.walk(ctx, root, rules, func(p, rel string, stats *Stats) error {
+		if !stats.IsRegularFile() {
+			return nil
+		}
+		data, err := fs.readMaybeDecoded(ctx, p, opts.Decompress, MaxDecodedSize)
+		if err != nil {
+			return err
+		}
+		if looksBinary(data) {
+			if !re.Match(data) {
+				return nil
+			}
+			matches = append(matches, GrepMatch{Path: p, Binary: true})
+			if opts.MaxMatches > 0 && len(matches) >= opts.MaxMatches {
+				return errStopWalk
+			}
+			return nil
+		}
+		for i, line := range splitLines(data) {
+			if !re.Match(line) {
+				continue
+			}
+			matches = append(matches, GrepMatch{Path: p, Line: i + 1, Text: string(line)})
+			if opts.MaxMatches > 0 && len(matches) >= opts.MaxMatches {
+				return errStopWalk
+			}
+		}
+		return nil
+	})
+	if err == errStopWalk {
+		err = nil
+	}
+	return matches, err
+}
@@ -0,0 +1,124 @@
+package agentfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeHostTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+}
+
+func TestImportExportDir(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	src := t.TempDir()
+	writeHostTree(t, src, map[string]string{
+		".gitignore":              "*.log\n",
+		"main.go":                 "package main\n",
+		"pkg/util.go":             "package pkg\n",
+		"debug.log":               "noise",
+		"node_modules/x/index.js": "junk",
+	})
+
+	err := afs.FS.ImportDir(ctx, src, "/repo", &ImportOptions{
+		Ignore:     NewIgnoreRules("node_modules/"),
+		IgnoreFile: ".gitignore",
+	})
+	if err != nil {
+		t.Fatalf("ImportDir failed: %v", err)
+	}
+
+	paths, err := afs.FS.Find(ctx, "/repo", nil)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	want := []string{"/repo/.gitignore", "/repo/main.go", "/repo/pkg", "/repo/pkg/util.go"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("imported paths = %v, want %v", paths, want)
+	}
+
+	dst := t.TempDir()
+	if err := afs.FS.ExportDir(ctx, "/repo", dst, &ExportOptions{Ignore: NewIgnoreRules("pkg/")}); err != nil {
+		t.Fatalf("ExportDir failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "main.go"))
+	if err != nil || string(data) != "package main\n" {
+		t.Errorf("exported main.go = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "pkg")); !os.IsNotExist(err) {
+		t.Errorf("ignored pkg/ was exported: %v", err)
+	}
+}
+
+func TestFindAndGrep(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	files := map[string]string{
+		"/ws/.gitignore":        "vendor/\n",
+		"/ws/a.go":              "package a\n// TODO: fix\n",
+		"/ws/b.txt":             "TODO later\n",
+		"/ws/vendor/dep/dep.go": "// TODO: upstream\n",
+	}
+	for p, content := range files {
+		if err := afs.FS.WriteFile(ctx, p, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	t.Run("find by name", func(t *testing.T) {
+		paths, err := afs.FS.Find(ctx, "/ws", &FindOptions{Name: "*.go", IgnoreFile: ".gitignore"})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		if !reflect.DeepEqual(paths, []string{"/ws/a.go"}) {
+			t.Errorf("Find = %v", paths)
+		}
+	})
+
+	t.Run("grep", func(t *testing.T) {
+		matches, err := afs.FS.Grep(ctx, "/ws", "TODO", &GrepOptions{IgnoreFile: ".gitignore"})
+		if err != nil {
+			t.Fatalf("Grep failed: %v", err)
+		}
+		want := []GrepMatch{
+			{Path: "/ws/a.go", Line: 2, Text: "// TODO: fix"},
+			{Path: "/ws/b.txt", Line: 1, Text: "TODO later"},
+		}
+		if !reflect.DeepEqual(matches, want) {
+			t.Errorf("Grep = %+v, want %+v", matches, want)
+		}
+	})
+
+	t.Run("grep max matches", func(t *testing.T) {
+		matches, err := afs.FS.Grep(ctx, "/ws", "TODO", &GrepOptions{MaxMatches: 1})
+		if err != nil {
+			t.Fatalf("Grep failed: %v", err)
+		}
+		if len(matches) != 1 {
+			t.Errorf("len(matches) = %d, want 1", len(matches))
+		}
+	})
+
+	t.Run("bad pattern", func(t *testing.T) {
+		if _, err := afs.FS.Grep(ctx, "/ws", "(", nil); err == nil {
+			t.Error("Grep with invalid pattern should fail")
+		}
+	})
+}
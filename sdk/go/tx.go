@@ -0,0 +1,199 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// dbtx is the subset of *sql.DB and *sql.Tx used by the subsystems, so the
+// same query helpers can run inside or outside a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlConn is the pool (*sql.DB) or a connection pinned by a Session
+// (*sql.Conn).
+type sqlConn interface {
+	dbtx
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Compile-time interface checks
+var (
+	_ dbtx    = (*sql.DB)(nil)
+	_ dbtx    = (*sql.Tx)(nil)
+	_ sqlConn = (*sql.DB)(nil)
+	_ sqlConn = (*sql.Conn)(nil)
+)
+
+// inTx runs fn with a copy of the Filesystem bound to a transaction.
+// The transaction commits if fn returns nil and rolls back otherwise.
+// If fs is already bound to a transaction, fn runs within it.
+func (fs *Filesystem) inTx(ctx context.Context, fn func(tfs *Filesystem) error) error {
+	if fs.conn == nil {
+		return fn(fs)
+	}
+
+	release, err := fs.schedule(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tx, err := fs.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var pending []Event
+	tfs := *fs
+	tfs.db = tx
+	tfs.conn = nil
+	tfs.pending = &pending
+	tfs.coalesce = nil
+	tfs.optimizer = nil
+	if err := fn(&tfs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, e := range pending {
+		fs.events.publish(e)
+	}
+	return nil
+}
+
+// ErrTxDone is returned by Commit on a transaction that has already ended.
+var ErrTxDone = errors.New("agentfs: transaction has already been committed or rolled back")
+
+// Tx is a transaction spanning the filesystem, the KV store, and tool call
+// history, started with AgentFS.Begin. Its FS, KV, and Tools work like
+// those of the AgentFS, but nothing they write is visible to others, or
+// reported on the change feed, until Commit; Rollback, or a crash before
+// Commit, discards all of it.
+//
+// A Tx holds the database's write lock until it ends, so keep it short and
+// do not write through the AgentFS itself meanwhile. It is not safe for
+// concurrent use, and files opened through it must be closed before Commit.
+type Tx struct {
+	FS    *Filesystem
+	KV    *KVStore
+	Tools *ToolCalls
+
+	tx      *sql.Tx
+	kvCache *kvCache
+	events  *eventBus
+	pending *[]Event
+	end     func()
+	done    bool
+}
+
+// Begin starts a transaction across FS, KV, and Tools, so an agent step
+// can write files, update keys, and record its tool call atomically. The
+// caller must end it with Commit or Rollback; Close waits until it does.
+// ctx applies to the whole transaction.
+//
+// Example:
+//
+//	tx, err := afs.Begin(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer tx.Rollback() // No-op after Commit
+//	if err := tx.FS.WriteFile(ctx, "/out/plan.md", plan, 0o644); err != nil {
+//	    return err
+//	}
+//	if err := tx.KV.Set(ctx, "step", step+1); err != nil {
+//	    return err
+//	}
+//	if _, err := tx.Tools.Record(ctx, "plan", params, "ok", nil, start, end); err != nil {
+//	    return err
+//	}
+//	return tx.Commit()
+func (a *AgentFS) Begin(ctx context.Context) (*Tx, error) {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.FS.coalesce.sync(ctx, ""); err != nil {
+		done()
+		return nil, err
+	}
+	release, err := a.FS.schedule(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		release()
+		done()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	var pending []Event
+	fs := *a.FS
+	fs.db, fs.conn, fs.pending, fs.coalesce, fs.optimizer = tx, nil, &pending, nil, nil
+	kv := *a.KV
+	kv.db, kv.conn, kv.pending, kv.cache = tx, nil, &pending, nil
+	tools := &ToolCalls{
+		db:      tx,
+		life:    a.life,
+		events:  a.events,
+		pending: &pending,
+		clock:   a.Tools.clock,
+		fs:      &fs,
+		owner:   a.Tools.owner,
+		opts:    a.Tools.opts,
+		uids:    a.Tools.uids,
+	}
+	return &Tx{
+		FS:      &fs,
+		KV:      &kv,
+		Tools:   tools,
+		tx:      tx,
+		kvCache: a.KV.cache,
+		events:  a.events,
+		pending: &pending,
+		end: func() {
+			release()
+			done()
+		},
+	}, nil
+}
+
+// Commit makes the transaction's writes visible and reports them on the
+// change feed.
+func (t *Tx) Commit() error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	defer t.end()
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	t.kvCache.purge()
+	for _, e := range *t.pending {
+		t.events.publish(e)
+	}
+	return nil
+}
+
+// Rollback discards the transaction's writes. It returns nil after Commit,
+// so it can be deferred.
+func (t *Tx) Rollback() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	defer t.end()
+	return t.tx.Rollback()
+}
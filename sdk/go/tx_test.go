@@ -0,0 +1,99 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBegin_Commit(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	events, cancel := afs.Subscribe(EventFilter{}, 0)
+	defer cancel()
+
+	tx, err := afs.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer tx.Rollback()
+	if err := tx.FS.WriteFile(ctx, "/out/plan.md", []byte("plan"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := tx.KV.Set(ctx, "step", 2); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	err = tx.KV.Txn(ctx, func(kt KVTx) error { return kt.Set(ctx, "plan:ready", true) })
+	if err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+	call, err := tx.Tools.Record(ctx, "plan", nil, "ok", nil, 1, 2)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	// Reads within the transaction see its writes
+	if data, err := tx.FS.ReadFile(ctx, "/out/plan.md"); err != nil || string(data) != "plan" {
+		t.Errorf("tx ReadFile = %q, %v", data, err)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("event %s delivered before commit", e.Kind)
+	default:
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("second Commit = %v, want ErrTxDone", err)
+	}
+
+	if data, _ := afs.FS.ReadFile(ctx, "/out/plan.md"); string(data) != "plan" {
+		t.Errorf("ReadFile after commit = %q", data)
+	}
+	if step, _ := KVGet[int](ctx, afs.KV, "step"); step != 2 {
+		t.Errorf("step = %d, want 2", step)
+	}
+	if _, err := afs.Tools.Get(ctx, call.ID); err != nil {
+		t.Errorf("tool call not committed: %v", err)
+	}
+	if len(events) == 0 {
+		t.Error("no events delivered after commit")
+	}
+}
+
+func TestBegin_Rollback(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.KV.Set(ctx, "step", 1)
+	tx, err := afs.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	tx.FS.WriteFile(ctx, "/out/partial.md", []byte("half"), 0o644)
+	tx.KV.Set(ctx, "step", 2)
+	tx.Tools.Record(ctx, "write", nil, "ok", nil, 1, 2)
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if _, err := afs.FS.Stat(ctx, "/out/partial.md"); !IsNotExist(err) {
+		t.Errorf("file survived rollback: %v", err)
+	}
+	if step, _ := KVGet[int](ctx, afs.KV, "step"); step != 1 {
+		t.Errorf("step = %d, want 1", step)
+	}
+	if calls, _ := afs.Tools.GetByName(ctx, "write", 10); len(calls) != 0 {
+		t.Errorf("tool call survived rollback: %+v", calls)
+	}
+
+	// The write lock is released
+	if err := afs.FS.WriteFile(ctx, "/after.txt", []byte("ok"), 0o644); err != nil {
+		t.Errorf("WriteFile after rollback failed: %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package agentfs
 
 import (
+	"database/sql"
 	"encoding/json"
+	"io/fs"
 	"time"
 )
 
@@ -13,14 +15,279 @@ type AgentFSOptions struct {
 	ID string
 
 	// Path is an explicit database file path. Takes precedence over ID.
+	// A libsql://, https://, or wss:// URL opens a remote database, e.g.
+	// on Turso (see Remote).
 	Path string
 
-	// ChunkSize is the size of data chunks in bytes (default: 4096)
+	// Remote configures access to a remote database given as a URL in Path.
+	Remote RemoteOptions
+
+	// Sync opens the database file as an embedded replica of a remote
+	// libSQL primary, so the agent works at local speed while its state
+	// outlives the machine.
+	Sync SyncOptions
+
+	// ChunkSize is the size of data chunks in bytes (default: 4096, or
+	// DefaultRemoteChunkSize for remote databases)
 	// Only used when creating a new database; ignored for existing databases.
 	ChunkSize int
 
+	// ChunkSizeFor picks the chunk size of the file at a path each time its
+	// content is replaced by WriteFile, Create, OpenWriter, or Open with
+	// O_TRUNC, e.g. small chunks for source files edited in place and large
+	// ones for media. Returning 0 keeps the file's chunk size, which is
+	// ChunkSize for new files (see Filesystem.SetChunkSize). It needs
+	// FileChunkSizes.
+	ChunkSizeFor func(path string) int
+
+	// FileChunkSizes allows files to be stored in chunks of other than
+	// ChunkSize (see ChunkSizeFor and Filesystem.SetChunkSize). Per-file
+	// sizes are an extension of this SDK: the other SDKs and the agentfs
+	// CLI mount assume ChunkSize for every file and read such files wrong,
+	// so set it only for databases that no other SDK opens (default: false)
+	FileChunkSizes bool
+
 	// Pool configures the database connection pool.
 	Pool PoolOptions
+
+	// Checkpoint configures automatic WAL checkpointing.
+	Checkpoint CheckpointOptions
+
+	// Optimize configures when query planner statistics are refreshed
+	// (see AgentFS.Optimize).
+	Optimize OptimizeOptions
+
+	// KVCache caches the values of frequently read keys in memory.
+	KVCache KVCacheOptions
+
+	// Coalesce holds back rapid successive WriteFile calls on the same
+	// file, so an agent saving a file many times per second writes it to
+	// the database only once it goes quiet.
+	Coalesce CoalesceOptions
+
+	// Tools configures tool call tracking.
+	Tools ToolCallOptions
+
+	// VerifyOnOpen runs an integrity check before the database is used and
+	// fails with *ErrCorrupt if it finds problems.
+	// Default: VerifyNone.
+	VerifyOnOpen VerifyMode
+
+	// Strict checks the filesystem invariants after every change, to catch
+	// bugs that corrupt the database where they happen: after a file is
+	// written, truncated, linked, or unlinked, its size must cover its
+	// chunks and its link count match its entries; after bulk operations
+	// the whole filesystem is checked (see AgentFS.CheckInvariants). The
+	// checks scan tables, so use it in development and tests.
+	// Default: StrictOff.
+	Strict StrictMode
+
+	// External stores the data of large files outside the database.
+	External ExternalStorageOptions
+
+	// Clock supplies timestamps for files, KV entries, tool calls, and
+	// events. Use a ManualClock to make recorded histories reproducible.
+	// Default: the system clock.
+	Clock Clock
+
+	// IDGenerator supplies identifiers that are otherwise random.
+	// Default: random hex IDs.
+	IDGenerator IDGenerator
+
+	// IDStrategy gives tool calls and snapshots a global ID (ToolCall.UID,
+	// SnapshotInfo.ID) next to their local one, and sets the format of
+	// NewSessionID, so records merged across agents never collide and
+	// sort chronologically. Default: IDRowID (local IDs only).
+	IDStrategy IDStrategy
+
+	// Paths configures validation of caller-supplied paths.
+	Paths PathOptions
+
+	// Handles configures leak detection for open File handles.
+	Handles HandleOptions
+
+	// Mailbox configures message exchange with other agents.
+	Mailbox MailboxOptions
+
+	// ReadOnly opens an existing database without writing to it: the
+	// schema is not initialized, background workers do not run, and
+	// writes fail. Databases written by a newer SDK are always opened
+	// read-only (see Capabilities).
+	ReadOnly bool
+
+	// TablePrefix is added to the names of the tables, indexes, and
+	// triggers AgentFS creates, e.g. "agentfs_", so agent state can live in
+	// an application's own database without name collisions. The same
+	// prefix must be used every time the database is opened, and by agents
+	// exchanging mail through their own databases.
+	// Must match pattern: ^[A-Za-z_][A-Za-z0-9_]*$
+	// Default: no prefix.
+	TablePrefix string
+
+	// LookupFilter keeps an in-memory bloom filter of the directory
+	// entries, so looking up a path that does not exist usually needs no
+	// query. The filter is built from the database on open and updated by
+	// this AgentFS's own writes only: use it only when no other process or
+	// connection adds entries to the database while it is open.
+	// Default: false.
+	LookupFilter bool
+
+	// Template seeds a newly created workspace with files. Ignored when
+	// opening an existing database.
+	Template TemplateOptions
+
+	// SkipPragmas leaves the journal mode and foreign key enforcement of a
+	// database passed to New as the caller configured them. Open always
+	// sets them; OpenWith never does.
+	SkipPragmas bool
+}
+
+// RemoteOptions configures a remote libSQL database, such as one on Turso,
+// opened by giving its URL as AgentFSOptions.Path. The SDK does not bundle a
+// libSQL client: import a database/sql driver for it, e.g.
+//
+//	import _ "github.com/tursodatabase/libsql-client-go/libsql"
+//
+// Every query is a network round trip, so remote databases suit agents that
+// write files in large pieces; for local speed, use an embedded replica
+// through New.
+type RemoteOptions struct {
+	// AuthToken authenticates to the server, added to the URL as its
+	// authToken parameter (default: "", the URL's own, if any)
+	AuthToken string
+
+	// Driver is the name of the registered database/sql driver that opens
+	// the URL (default: "libsql")
+	Driver string
+}
+
+// SyncOptions configures an embedded replica (see AgentFSOptions.Sync):
+// a local database file that is synced with a remote libSQL primary when
+// opened, every Interval, on AgentFS.Sync, and on Close. Replication is
+// left to a libSQL client, plugged in through Connect.
+type SyncOptions struct {
+	// URL is the primary, e.g. "libsql://agents-acme.turso.io". A replica
+	// is opened only if it is set.
+	URL string
+
+	// AuthToken authenticates to the primary (default: "")
+	AuthToken string
+
+	// Interval syncs the replica in the background this often.
+	// Default: 0 (only on open, Sync, and Close).
+	Interval time.Duration
+
+	// OnError is called when a background sync fails; the next interval
+	// tries again. Sync and Close return their errors instead.
+	OnError func(err error)
+
+	// Connect opens the replica. Required.
+	Connect ReplicaConnector
+}
+
+// TemplateOptions configures the files a new workspace starts with (see
+// AgentFSOptions.Template). In text files, every {{name}} placeholder is
+// replaced with the parameter name; opening fails if one is not defined.
+// Binary files are copied as is.
+type TemplateOptions struct {
+	// FS holds the files, copied to the same paths below the workspace
+	// root, e.g. an embed.FS or os.DirFS (default: nil, none)
+	FS fs.FS
+	// Params are the values of the placeholders. TemplateAgentID is set
+	// to the agent's ID unless given here (default: nil)
+	Params map[string]string
+}
+
+// MailboxOptions configures AgentFS.Mailbox. By default every agent keeps
+// its inbox in its own database, and messages are delivered to the database
+// of the recipient in the same directory, named <agent id>.db as Open does
+// for AgentFSOptions.ID.
+type MailboxOptions struct {
+	// AgentID is the address other agents send to.
+	// Default: AgentFSOptions.ID, or the database file name without its
+	// extension.
+	AgentID string
+
+	// DB is a database shared by several agents. When set, all inboxes live
+	// in it instead of in the agents' own databases.
+	DB *sql.DB
+
+	// Dir holds the databases of the agents messages can be sent to.
+	// Default: the directory of this agent's database.
+	Dir string
+
+	// VisibilityTimeout is how long a received message stays hidden from
+	// Receive before it is redelivered, unless it is acknowledged.
+	// Default: 30s.
+	VisibilityTimeout time.Duration
+
+	// PollInterval is how often Receive checks an empty inbox.
+	// Default: 500ms.
+	PollInterval time.Duration
+}
+
+// HandleOptions configures tracking of open File handles. A handle leaks
+// when its File is garbage collected without Close, or is still open when
+// the AgentFS closes.
+type HandleOptions struct {
+	// OnLeak is called once for each leaked handle, e.g. to log a warning.
+	// It may run on the garbage collector's finalizer goroutine.
+	OnLeak func(OpenHandle)
+
+	// AutoClose removes handles from OpenHandles once their File has been
+	// garbage collected. Default: they stay listed with Leaked set.
+	AutoClose bool
+
+	// Stacks records where each handle was opened, for leak reports.
+	// Default: false, since it costs a stack capture per Open.
+	Stacks bool
+}
+
+// PathOptions configures validation of paths passed to the filesystem.
+// By default paths that escape the root with "..", contain NUL bytes, or
+// exceed the limits below are rejected with *PathError.
+type PathOptions struct {
+	// Lenient disables validation. Paths are only cleaned, so ".." above
+	// the root is dropped and overlong names fail when created.
+	Lenient bool
+
+	// MaxLength is the maximum path length in bytes.
+	// Default: DefaultMaxPathLength (4096).
+	MaxLength int
+
+	// MaxDepth is the maximum number of path components.
+	// Default: DefaultMaxPathDepth (256).
+	MaxDepth int
+}
+
+// ExternalStorageOptions configures storage of large file data outside the
+// database. Chunks of files above a size threshold go to a ChunkStore (a
+// content-addressed directory, a separate SQLite database, or S3/GCS) and
+// SQLite keeps only their hashes, so the database stays small while the FS
+// API is unchanged.
+//
+// Externalized chunks are written once per distinct content; a larger
+// ChunkSize (e.g. 1 MiB) keeps the number of stored objects manageable.
+type ExternalStorageOptions struct {
+	// Threshold externalizes files whose size is at least this many bytes
+	// to a directory store (tier "local"). Files are moved between tiers as
+	// their chunks are rewritten.
+	// Default: 0 (disabled).
+	Threshold int64
+
+	// Dir is the directory used with Threshold.
+	// Default: "<database path>.blobs". Required with OpenWith.
+	Dir string
+
+	// Tiers routes files to stores by size. Each file goes to the tier with
+	// the largest MinSize it reaches; smaller files stay in SQLite.
+	// Default: none.
+	Tiers []StorageTier
+
+	// CacheSize is the number of bytes of external chunks cached in memory
+	// after they are read.
+	// Default: 0 (no cache).
+	CacheSize int64
 }
 
 // PoolOptions configures the SQLite connection pool.
@@ -43,6 +310,106 @@ type PoolOptions struct {
 	ConnMaxIdleTime time.Duration
 }
 
+// CheckpointOptions configures the automatic WAL checkpoint policy.
+// Background checkpointing is disabled unless Interval or MaxWALSize is set.
+type CheckpointOptions struct {
+	// Interval checkpoints the WAL at least this often.
+	// Default: 0 (no time-based checkpoints).
+	Interval time.Duration
+
+	// MaxWALSize checkpoints the WAL once the -wal file reaches this many bytes.
+	// Default: 0 (no size-based checkpoints).
+	MaxWALSize int64
+
+	// Mode is the checkpoint mode used by the background worker.
+	// Default: CheckpointPassive. Use CheckpointTruncate to reclaim disk space.
+	Mode CheckpointMode
+
+	// PollInterval controls how often the WAL size is checked.
+	// Default: 10s (or Interval, if shorter).
+	PollInterval time.Duration
+
+	// OnClose runs a final checkpoint with Mode when the AgentFS is closed.
+	// This works independently of Interval and MaxWALSize.
+	OnClose bool
+}
+
+// OptimizeOptions configures the automatic runs of AgentFS.Optimize, which
+// also runs on Close and after bulk changes.
+type OptimizeOptions struct {
+	// Interval also runs Optimize this often in the background.
+	// Default: 0 (no periodic runs).
+	Interval time.Duration
+
+	// AnalysisLimit is the number of rows sampled per index, which bounds
+	// the time each run takes. Default: DefaultAnalysisLimit.
+	AnalysisLimit int
+
+	// SkipOnClose leaves out the run on Close, for processes that close
+	// often and briefly. Default: false.
+	SkipOnClose bool
+}
+
+// CoalesceOptions configures write coalescing (see AgentFSOptions.Coalesce).
+// Coalescing is disabled unless Interval is set.
+//
+// The first WriteFile of a file is written at once. Until the file has been
+// quiet for Interval, later WriteFile calls only replace the content held
+// in memory, which is written when the interval passes, at most MaxDelay
+// after the first held write. ReadFile returns the held content; any other
+// operation on the file, or on a directory above it, writes it first, as do
+// Filesystem.Sync, SyncAll, AgentFS.Begin, and Close. Operations on the
+// whole tree, such as snapshots, exports, and searches, and other processes
+// see only what has been written, so call SyncAll before them.
+type CoalesceOptions struct {
+	// Interval is how long a file must go without WriteFile calls before
+	// its held content is written.
+	// Default: 0 (no coalescing).
+	Interval time.Duration
+
+	// MaxDelay bounds how long content is held while the file keeps being
+	// rewritten.
+	// Default: 10 × Interval.
+	MaxDelay time.Duration
+
+	// OnError is called when writing held content fails in the background
+	// or before another operation; the content is dropped. Sync, SyncAll,
+	// and Close return their errors instead.
+	OnError func(path string, err error)
+}
+
+// KVCacheOptions configures the read-through cache of KV values used by
+// KVStore.Get and GetRaw (see AgentFSOptions.KVCache). The cache is
+// disabled unless MaxEntries is set. Writes through the AgentFS update it
+// at once. Writes by other processes and connections are detected by
+// checking PRAGMA data_version, on a connection of the pool the cache keeps
+// for itself, before reads; the whole cache is dropped when they touched
+// the KV store.
+type KVCacheOptions struct {
+	// MaxEntries bounds the number of cached keys; the least recently read
+	// are evicted.
+	// Default: 0 (no cache).
+	MaxEntries int
+
+	// MaxStaleness is how long cached values are served before checking
+	// for writes by others again.
+	// Default: 0 (checked before every read).
+	MaxStaleness time.Duration
+}
+
+// ToolCallOptions configures in-progress tool call tracking.
+type ToolCallOptions struct {
+	// HeartbeatInterval controls how often this process refreshes the
+	// heartbeat of its running tool calls.
+	// Default: 30s.
+	HeartbeatInterval time.Duration
+
+	// StaleAfter is how long a running tool call owned by another process may
+	// go without a heartbeat before it is marked interrupted.
+	// Default: 2m.
+	StaleAfter time.Duration
+}
+
 // Stats represents file/directory metadata (matches POSIX stat)
 type Stats struct {
 	Ino       int64 `json:"ino"`        // Inode number
@@ -170,9 +537,27 @@ type DirEntry struct {
 	Stats *Stats `json:"stats"`
 }
 
+// ReaddirOptions selects a page of entries for Filesystem.ReaddirPage.
+type ReaddirOptions struct {
+	// After continues from the Next of the previous page
+	// (default: "", the first page)
+	After string
+	// Limit is the page size (default: DefaultReaddirPageSize)
+	Limit int
+}
+
+// DirPage is a page of directory entries returned by ReaddirPage.
+type DirPage struct {
+	Entries []DirEntry `json:"entries"`
+	// Next fetches the next page as ReaddirOptions.After; empty on the
+	// last page
+	Next string `json:"next,omitempty"`
+}
+
 // ToolCall represents a recorded tool invocation
 type ToolCall struct {
 	ID          int64           `json:"id"`
+	UID         string          `json:"uid,omitempty"` // Global ID (see AgentFSOptions.IDStrategy)
 	Name        string          `json:"name"`
 	Parameters  json.RawMessage `json:"parameters,omitempty"`
 	Result      json.RawMessage `json:"result,omitempty"`
@@ -182,6 +567,122 @@ type ToolCall struct {
 	DurationMs  int64           `json:"duration_ms"`
 }
 
+// ToolCallNode is a tool call with the calls it made, as returned by
+// ToolCalls.Tree.
+type ToolCallNode struct {
+	ToolCall
+	// Children are the recorded child calls, in the order they started
+	Children []*ToolCallNode `json:"children,omitempty"`
+}
+
+// ToolCallFilter selects tool calls for ToolCalls.Find.
+type ToolCallFilter struct {
+	// Name matches the tool name exactly (default: "", any)
+	Name string
+	// Since and Until bound StartedAt to [Since, Until) (default: 0, unbounded)
+	Since int64
+	Until int64
+	// Annotations requires each key to be annotated with the given value,
+	// compared as JSON. A nil value matches any value (default: nil)
+	Annotations map[string]any
+	// Limit caps the number of results; negative means no limit
+	// (default: 100)
+	Limit int
+}
+
+// Completed tool call statuses, for ToolCallQuery.Status
+const (
+	ToolCallSucceeded = "success" // Recorded without an error
+	ToolCallFailed    = "error"   // Recorded with an error
+)
+
+// ToolCallQuery selects a page of tool calls for ToolCalls.Query.
+type ToolCallQuery struct {
+	// ToolName matches the tool name exactly (default: "", any)
+	ToolName string
+	// Status is ToolCallSucceeded or ToolCallFailed (default: "", any)
+	Status string
+	// Since and Until bound StartedAt to [Since, Until) (default: 0, unbounded)
+	Since int64
+	Until int64
+	// Limit is the page size (default: 100)
+	Limit int
+	// Cursor continues from the NextCursor of the previous page
+	// (default: "", the first page)
+	Cursor string
+	// CollapseRetries leaves out attempts superseded by a retry (see
+	// ToolCalls.RecordRetry), so each chain appears once, as its latest
+	// attempt (default: false, every attempt)
+	CollapseRetries bool
+}
+
+// ToolCallPage is a page of tool calls returned by ToolCalls.Query.
+type ToolCallPage struct {
+	Calls []ToolCall `json:"calls"`
+	// NextCursor fetches the next page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Usage is the model usage attributed to a tool call.
+type Usage struct {
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// PathCost is the usage spent producing a file or directory, as reported by
+// CostByPath. A tool call linked to several files below Path counts once.
+type PathCost struct {
+	Path  string `json:"path"`
+	Calls int64  `json:"calls"`
+	Usage
+}
+
+// DefaultGrowthTopN is the number of entries GrowthReport lists in each
+// category.
+const DefaultGrowthTopN = 10
+
+// Growth is what an agent database gained since a point in time, as
+// reported by GrowthReport. Each list holds the largest contributors,
+// largest first.
+type Growth struct {
+	Since time.Time `json:"since"`
+
+	FileBytes int64 `json:"file_bytes"` // Size of files written since
+	KVBytes   int64 `json:"kv_bytes"`   // Size of keys and values set since
+	ToolBytes int64 `json:"tool_bytes"` // Size of tool call parameters, results, and errors
+
+	Paths        []GrowthEntry `json:"paths"`         // Files
+	Subtrees     []GrowthEntry `json:"subtrees"`      // Top-level directories
+	KVNamespaces []GrowthEntry `json:"kv_namespaces"` // KV namespace, or key text before the first ':'
+	Tools        []GrowthEntry `json:"tools"`         // Tool names
+}
+
+// GrowthEntry is one contributor in a Growth report.
+type GrowthEntry struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	Count int64  `json:"count"` // Files, keys, or calls
+}
+
+// Pending tool call statuses
+const (
+	ToolCallRunning     = "running"     // Started and still heartbeating
+	ToolCallInterrupted = "interrupted" // Owner stopped heartbeating before completion
+)
+
+// PendingToolCall represents a tool call that was started but not completed.
+type PendingToolCall struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	StartedAt   int64           `json:"started_at"`
+	HeartbeatAt int64           `json:"heartbeat_at"` // Last sign of life of the owning process
+	LastSeen    int64           `json:"last_seen"`    // Last Heartbeat of the call itself, or its start
+	Status      string          `json:"status"`
+	ToolCallID  *int64          `json:"tool_call_id,omitempty"` // History record written on interruption
+}
+
 // ToolCallStats represents aggregated statistics for tool calls
 type ToolCallStats struct {
 	Name          string  `json:"name"`
@@ -191,9 +692,276 @@ type ToolCallStats struct {
 	AvgDurationMs float64 `json:"avg_duration_ms"`
 }
 
+// RetryStats summarizes the outcomes of the retry chains of a tool, as
+// returned by ToolCalls.RetryStats. A chain is an original call and its
+// retries; a call never retried is a chain of one attempt.
+type RetryStats struct {
+	Name   string `json:"name"`
+	Chains int64  `json:"chains"`
+	// SucceededAfter counts the chains that succeeded, by their number of
+	// attempts: SucceededAfter[1] succeeded first time, SucceededAfter[3]
+	// after two retries
+	SucceededAfter map[int]int64 `json:"succeeded_after"`
+	// Failed counts the chains whose latest attempt failed
+	Failed int64 `json:"failed"`
+}
+
 // KVEntry represents a key-value pair with metadata
 type KVEntry struct {
 	Key       string `json:"key"`
 	CreatedAt int64  `json:"created_at"`
 	UpdatedAt int64  `json:"updated_at"`
+	ExpiresAt int64  `json:"expires_at,omitempty"` // Unix milliseconds; 0 if the entry does not expire
+	Type      string `json:"type,omitempty"`       // Type tag written by KVSetAs; "" if untagged
+}
+
+// MetaQuery selects files by custom metadata for FindByMeta.
+type MetaQuery struct {
+	// Root limits the search to entries below this directory (default: "/")
+	Root string
+	// Key is the metadata field to match (required)
+	Key string
+	// Value is the value to match (default: any value)
+	Value string
+	// Missing inverts the match: return regular files that do not have
+	// Key set to Value (default: false)
+	Missing bool
+}
+
+// ImportOptions configures ImportDir.
+type ImportOptions struct {
+	// Ignore excludes matching paths from the import (default: nil)
+	Ignore *IgnoreRules
+	// IgnoreFile names a .gitignore-style file read from the root of the
+	// source directory, e.g. ".gitignore" (default: "", none)
+	IgnoreFile string
+	// Concurrency is the number of files read in parallel
+	// (default: runtime.NumCPU())
+	Concurrency int
+	// BatchSize is the number of entries written per transaction
+	// (default: DefaultImportBatchSize)
+	BatchSize int
+	// Progress is called after each batch is committed (default: nil)
+	Progress ProgressFunc
+	// ResumeToken names a checkpoint saved after each batch; an interrupted
+	// import restarted with the same token continues where it stopped
+	// (default: "", not resumable)
+	ResumeToken string
+	// Limiter throttles the files read (default: nil, unlimited)
+	Limiter *Limiter
+}
+
+// Progress reports how far a long-running operation has come.
+type Progress struct {
+	// Op names the operation, e.g. "import", "export", or "prune".
+	Op string
+	// Items is the number of files or other items processed so far.
+	Items int64
+	// Bytes is the number of bytes processed so far.
+	Bytes int64
+	// Path is the last item processed.
+	Path string
+}
+
+// ExportOptions configures ExportDir.
+type ExportOptions struct {
+	// Ignore excludes matching paths from the export (default: nil)
+	Ignore *IgnoreRules
+	// IgnoreFile names a .gitignore-style file read from the root of the
+	// exported directory (default: "", none)
+	IgnoreFile string
+	// Progress is called after each entry is written (default: nil)
+	Progress ProgressFunc
+	// ResumeToken names a checkpoint saved after each entry; an interrupted
+	// export restarted with the same token continues where it stopped
+	// (default: "", not resumable)
+	ResumeToken string
+	// Limiter throttles the files written (default: nil, unlimited)
+	Limiter *Limiter
+}
+
+// PruneOptions configures PruneBlobs.
+type PruneOptions struct {
+	// MinAge keeps unreferenced chunks modified more recently than this, to
+	// protect chunks written by operations that have not committed yet
+	// (default: 0, prune all unreferenced chunks)
+	MinAge time.Duration
+	// Progress is called after each chunk is removed (default: nil)
+	Progress ProgressFunc
+	// ResumeToken names a checkpoint saved after each chunk is removed; an
+	// interrupted prune restarted with the same token skips the chunks it
+	// already examined (default: "", not resumable)
+	ResumeToken string
+	// Limiter throttles the chunks removed (default: nil, unlimited)
+	Limiter *Limiter
+}
+
+// FindOptions configures Find.
+type FindOptions struct {
+	// Name is a path.Match pattern applied to base names (default: "", all)
+	Name string
+	// Ignore excludes matching paths from the search (default: nil)
+	Ignore *IgnoreRules
+	// IgnoreFile names a .gitignore-style file read from the search root
+	// (default: "", none)
+	IgnoreFile string
+}
+
+// GrepOptions configures Grep.
+type GrepOptions struct {
+	// MaxMatches stops the search after this many matches (default: 0, unlimited)
+	MaxMatches int
+	// Decompress searches compressed files through OpenDecoded, up to
+	// MaxDecodedSize bytes each (default: false)
+	Decompress bool
+	// Ignore excludes matching paths from the search (default: nil)
+	Ignore *IgnoreRules
+	// IgnoreFile names a .gitignore-style file read from the search root
+	// (default: "", none)
+	IgnoreFile string
+}
+
+// GrepMatch is a single line matched by Grep.
+type GrepMatch struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	// Binary is set when Path is a binary file; Line and Text are empty.
+	Binary bool `json:"binary,omitempty"`
+}
+
+// ZipOptions configures ReadZip.
+type ZipOptions struct {
+	// Include selects the entries to extract with .gitignore-style
+	// patterns, e.g. "data/*.csv" or "docs/" (default: nil, all entries)
+	Include []string
+	// MaxBytes stops the extraction with an error once more than this many
+	// uncompressed bytes were written (default: 0, unlimited)
+	MaxBytes int64
+	// Progress is called after each extracted file (default: nil)
+	Progress ProgressFunc
+}
+
+// CSVOptions configures ReadCSV.
+type CSVOptions struct {
+	// Comma is the field delimiter (default: ',')
+	Comma rune
+	// NoHeader treats the first record as data and names the columns
+	// "col1", "col2", ... (default: false, the first record is the header)
+	NoHeader bool
+	// InferRows is the number of rows sampled to infer column types
+	// (default: DefaultInferRows)
+	InferRows int
+	// MaxRows stops reading after this many rows (default: 0, unlimited)
+	MaxRows int
+}
+
+// JSONLOptions configures ReadJSONL.
+type JSONLOptions struct {
+	// MaxRows stops reading after this many records (default: 0, unlimited)
+	MaxRows int
+	// SkipInvalid skips lines that do not decode instead of stopping with
+	// an error (default: false)
+	SkipInvalid bool
+}
+
+// SearchOptions configures SearchAll.
+type SearchOptions struct {
+	// Concurrency is the number of agent databases searched at once
+	// (default: 4)
+	Concurrency int
+	// MaxResults caps the results of each agent and kind (default: 100)
+	MaxResults int
+	// Kinds restricts the search to these kinds of result (default: all)
+	Kinds []SearchKind
+}
+
+// ExcerptOptions configures Excerpt. If both MaxBytes and MaxTokens are
+// set, the smaller budget applies.
+type ExcerptOptions struct {
+	// MaxBytes is the size budget of the rendered excerpt
+	// (default: DefaultExcerptMaxBytes unless MaxTokens is set)
+	MaxBytes int
+	// MaxTokens is the budget in tokens, estimated at four bytes each
+	// (default: 0, unused)
+	MaxTokens int
+	// Around lists line ranges to keep before the head and tail
+	// (default: nil)
+	Around []LineRange
+	// Context is the number of lines kept on either side of each Around
+	// range (default: 0)
+	Context int
+	// Decompress reads compressed files through OpenDecoded, up to
+	// MaxDecodedSize bytes (default: false)
+	Decompress bool
+}
+
+// SummaryOptions configures Summary.
+type SummaryOptions struct {
+	// MaxEntries is the most entries listed (default: DefaultSummaryMaxEntries)
+	MaxEntries int
+	// MaxDepth is the deepest level listed below the root; deeper entries
+	// are only counted (default: DefaultSummaryMaxDepth)
+	MaxDepth int
+	// Ignore excludes matching paths from the summary (default: nil)
+	Ignore *IgnoreRules
+	// IgnoreFile names a .gitignore-style file read from the root
+	// (default: "", none)
+	IgnoreFile string
+}
+
+// LanguageStat summarizes the files of one language, as returned by
+// LanguageStats.
+type LanguageStat struct {
+	Language string `json:"language"`
+	Files    int64  `json:"files"`
+	Lines    int64  `json:"lines"`
+	Blank    int64  `json:"blank"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// TailOptions configures TailFollow.
+type TailOptions struct {
+	// FromStart delivers the lines already in the file before following
+	// (default: false, only lines appended after TailFollow is called)
+	FromStart bool
+	// PollInterval is how often the file is checked for appends made by
+	// other processes (default: 250ms)
+	PollInterval time.Duration
+}
+
+// SinkOptions configures a sink registered with OnFileFinalized.
+type SinkOptions struct {
+	// Name identifies the sink in the delivery log, so deliveries pending
+	// when the process stopped resume once a sink of that name is
+	// registered again (default: the glob)
+	Name string
+	// Settle is how long a file must go unchanged before it counts as
+	// finalized (default: DefaultSinkSettle)
+	Settle time.Duration
+	// MaxAttempts is how many times a delivery is tried before it is
+	// marked failed (default: DefaultSinkAttempts)
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry; it doubles with each
+	// attempt, up to an hour (default: DefaultSinkRetryDelay)
+	RetryDelay time.Duration
+}
+
+// Sink delivery statuses
+const (
+	SinkPending   = "pending"
+	SinkDelivered = "delivered"
+	SinkFailed    = "failed"
+)
+
+// SinkDelivery is an entry of the delivery log (see SinkDeliveries).
+type SinkDelivery struct {
+	ID          int64  `json:"id"`
+	Sink        string `json:"sink"`
+	Path        string `json:"path"`
+	Status      string `json:"status"` // SinkPending, SinkDelivered, or SinkFailed
+	Attempts    int64  `json:"attempts"`
+	LastError   string `json:"last_error,omitempty"`
+	CreatedAt   int64  `json:"created_at"`             // Unix milliseconds
+	DeliveredAt int64  `json:"delivered_at,omitempty"` // Unix milliseconds
 }
@@ -0,0 +1,126 @@
+package agentfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// VerifyMode selects the integrity check run when a database is opened.
+type VerifyMode int
+
+const (
+	// VerifyNone skips the check (default).
+	VerifyNone VerifyMode = iota
+	// VerifyQuick runs PRAGMA quick_check, which is fast but skips index
+	// consistency checks.
+	VerifyQuick
+	// VerifyFull runs PRAGMA integrity_check.
+	VerifyFull
+)
+
+// String returns the name of the mode.
+func (m VerifyMode) String() string {
+	switch m {
+	case VerifyQuick:
+		return "quick"
+	case VerifyFull:
+		return "full"
+	default:
+		return "none"
+	}
+}
+
+// maxCorruptProblems caps how many problems a check reports.
+const maxCorruptProblems = 100
+
+// ErrCorrupt is returned when an integrity check finds problems. Salvage can
+// often recover the readable data.
+type ErrCorrupt struct {
+	// Check is the pragma that reported the problems.
+	Check string
+	// Problems lists the reported problems (at most 100).
+	Problems []string
+}
+
+func (e *ErrCorrupt) Error() string {
+	msg := fmt.Sprintf("database is corrupt: %s reported %d problem(s)", e.Check, len(e.Problems))
+	if len(e.Problems) > 0 {
+		msg += ": " + e.Problems[0]
+	}
+	return msg
+}
+
+// Verify runs an integrity check plus a foreign key check and returns
+// *ErrCorrupt if either reports problems. VerifyNone is treated as VerifyQuick.
+func (a *AgentFS) Verify(ctx context.Context, mode VerifyMode) error {
+	ctx, done, err := a.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if mode == VerifyNone {
+		mode = VerifyQuick
+	}
+	return verifyDB(ctx, a.db, mode)
+}
+
+// verifyDB runs the checks for mode against db.
+func verifyDB(ctx context.Context, db *sql.DB, mode VerifyMode) error {
+	if mode == VerifyNone {
+		return nil
+	}
+
+	pragma := "quick_check"
+	if mode == VerifyFull {
+		pragma = "integrity_check"
+	}
+	problems, err := pragmaCheck(ctx, db, fmt.Sprintf("%s(%d)", pragma, maxCorruptProblems))
+	if err != nil {
+		if isCorruptError(err) {
+			return &ErrCorrupt{Check: pragma, Problems: []string{err.Error()}}
+		}
+		return err
+	}
+	if len(problems) > 0 {
+		return &ErrCorrupt{Check: pragma, Problems: problems}
+	}
+
+	problems, err = foreignKeyCheck(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &ErrCorrupt{Check: "foreign_key_check", Problems: problems}
+	}
+	return nil
+}
+
+// foreignKeyCheck runs PRAGMA foreign_key_check and formats its rows.
+func foreignKeyCheck(ctx context.Context, db dbtx) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run foreign_key_check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() && len(problems) < maxCorruptProblems {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int64
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return nil, fmt.Errorf("failed to run foreign_key_check: %w", err)
+		}
+		problems = append(problems, fmt.Sprintf("%s row %d violates foreign key %d to %s", table, rowid.Int64, fkid, parent))
+	}
+	return problems, rows.Err()
+}
+
+// isCorruptError reports whether err is SQLite's SQLITE_CORRUPT or SQLITE_NOTADB.
+func isCorruptError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "malformed") || strings.Contains(msg, "not a database")
+}
@@ -0,0 +1,56 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyOnOpen(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("healthy database", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "test.db")
+		for _, mode := range []VerifyMode{VerifyQuick, VerifyFull} {
+			afs, err := Open(ctx, AgentFSOptions{Path: dbPath, VerifyOnOpen: mode})
+			if err != nil {
+				t.Fatalf("Open with %s verify failed: %v", mode, err)
+			}
+			if err := afs.Verify(ctx, mode); err != nil {
+				t.Errorf("Verify(%s) failed: %v", mode, err)
+			}
+			afs.Close()
+		}
+	})
+
+	t.Run("not a database", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "garbage.db")
+		garbage := make([]byte, 8192)
+		for i := range garbage {
+			garbage[i] = byte(i * 7)
+		}
+		if err := os.WriteFile(dbPath, garbage, 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		_, err := Open(ctx, AgentFSOptions{Path: dbPath, VerifyOnOpen: VerifyQuick})
+		var corrupt *ErrCorrupt
+		if !errors.As(err, &corrupt) {
+			t.Fatalf("Open err = %v, want *ErrCorrupt", err)
+		}
+		if len(corrupt.Problems) == 0 {
+			t.Error("ErrCorrupt has no problems")
+		}
+	})
+}
+
+func TestVerifyMode_String(t *testing.T) {
+	tests := map[VerifyMode]string{VerifyNone: "none", VerifyQuick: "quick", VerifyFull: "full"}
+	for mode, want := range tests {
+		if got := mode.String(); got != want {
+			t.Errorf("VerifyMode(%d).String() = %q, want %q", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package agentfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrVersionNotFound is returned by ReadVersion and RevertTo for a version
+// the path does not have.
+var ErrVersionNotFound = errors.New("agentfs: version not found")
+
+// FileVersion describes an earlier content of a path, kept when the file
+// was overwritten.
+type FileVersion struct {
+	Version int64 `json:"version"`
+	Size    int64 `json:"size"`
+	Mode    int64 `json:"mode"`
+	Mtime   int64 `json:"mtime"` // Last modified while current, Unix seconds
+	// CreatedAt is when the content was replaced, Unix seconds.
+	CreatedAt int64 `json:"created_at"`
+}
+
+// chunkHash returns the key of data in the shared chunk store.
+func chunkHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// saveVersion keeps the content of the file ino at p before it is
+// overwritten, if Policy.KeepVersions enables versioning, and expires the
+// versions beyond the limit. Empty files are not kept.
+func (fs *Filesystem) saveVersion(ctx context.Context, p string, ino int64) error {
+	policy, err := fs.policy.get(ctx, fs.db)
+	if err != nil || policy.KeepVersions <= 0 {
+		return err
+	}
+	stats, err := fs.statInode(ctx, ino)
+	if err != nil || stats.Size == 0 {
+		return err
+	}
+
+	return fs.inTx(ctx, func(tfs *Filesystem) error {
+		chunkSize, err := tfs.chunkSizeOf(ctx, ino)
+		if err != nil {
+			return err
+		}
+		var version int64
+		err = tfs.db.QueryRowContext(ctx, versionInsert, p, stats.Size, stats.Mode, stats.Mtime, tfs.now().Unix(), chunkSize).Scan(&version)
+		if err != nil {
+			return fmt.Errorf("failed to save version: %w", err)
+		}
+		last := (stats.Size - 1) / chunkSize
+		for start := int64(0); start <= last; start += snapshotPageSize {
+			chunks, err := tfs.readChunks(ctx, ino, start, min(start+snapshotPageSize-1, last))
+			if err != nil {
+				return fmt.Errorf("failed to save version: %w", err)
+			}
+			for _, c := range chunks {
+				hash := chunkHash(c.data)
+				if _, err := tfs.db.ExecContext(ctx, snapshotChunkPut, hash, c.data); err != nil {
+					return fmt.Errorf("failed to save version: %w", err)
+				}
+				if _, err := tfs.db.ExecContext(ctx, versionDataPut, p, version, c.index, hash); err != nil {
+					return fmt.Errorf("failed to save version: %w", err)
+				}
+			}
+		}
+		return tfs.expireVersions(ctx, p, int64(policy.KeepVersions))
+	})
+}
+
+// expireVersions removes all but the newest keep versions of p, and the
+// chunk contents nothing else refers to.
+func (fs *Filesystem) expireVersions(ctx context.Context, p string, keep int64) error {
+	rows, err := fs.db.QueryContext(ctx, versionExpiredHashes, p, keep)
+	if err != nil {
+		return fmt.Errorf("failed to expire versions: %w", err)
+	}
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to expire versions: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to expire versions: %w", err)
+	}
+
+	for _, q := range []string{versionExpireData, versionExpire} {
+		if _, err := fs.db.ExecContext(ctx, q, p, keep); err != nil {
+			return fmt.Errorf("failed to expire versions: %w", err)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	list, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.db.ExecContext(ctx, versionChunksPrune, string(list)); err != nil {
+		return fmt.Errorf("failed to expire versions: %w", err)
+	}
+	return nil
+}
+
+// History lists the earlier versions of the file at p, newest first.
+// Versions are kept only while Policy.KeepVersions is set: each time
+// WriteFile, Create, OpenWriter, or Open with O_TRUNC replaces a non-empty
+// file, its previous content becomes a new version, and the oldest are
+// dropped beyond the limit. History belongs to the path, so it survives
+// the file being removed and recreated. Versions do not count towards
+// Policy.MaxBytes.
+//
+// Example:
+//
+//	versions, err := afs.FS.History(ctx, "/notes/plan.md")
+//	if err != nil {
+//	    return err
+//	}
+//	for _, v := range versions {
+//	    fmt.Printf("v%d  %d bytes  replaced %s\n", v.Version, v.Size, time.Unix(v.CreatedAt, 0))
+//	}
+func (fs *Filesystem) History(ctx context.Context, p string) ([]FileVersion, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "history", p)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := fs.db.QueryContext(ctx, versionList, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	defer rows.Close()
+	var versions []FileVersion
+	for rows.Next() {
+		var v FileVersion
+		if err := rows.Scan(&v.Version, &v.Size, &v.Mode, &v.Mtime, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to list versions: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// ReadVersion returns the content of version of the file at p, as listed
+// by History.
+func (fs *Filesystem) ReadVersion(ctx context.Context, p string, version int64) ([]byte, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "history", p)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := fs.readVersion(ctx, p, version)
+	return data, err
+}
+
+// readVersion returns the content and description of a version.
+func (fs *Filesystem) readVersion(ctx context.Context, p string, version int64) ([]byte, *FileVersion, error) {
+	v := &FileVersion{}
+	var chunkSize int64
+	err := fs.db.QueryRowContext(ctx, versionGet, p, version).Scan(&v.Version, &v.Size, &v.Mode, &v.Mtime, &v.CreatedAt, &chunkSize)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("%w: %s@%d", ErrVersionNotFound, p, version)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read version: %w", err)
+	}
+
+	rows, err := fs.db.QueryContext(ctx, versionChunks, p, version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read version: %w", err)
+	}
+	defer rows.Close()
+	data := make([]byte, v.Size) // Sparse regions read as zeros
+	if chunkSize <= 0 {
+		chunkSize = int64(fs.chunkSize) // Saved before chunk sizes were recorded
+	}
+	for rows.Next() {
+		var c fileChunk
+		if err := rows.Scan(&c.index, &c.data); err != nil {
+			return nil, nil, fmt.Errorf("failed to read version: %w", err)
+		}
+		if off := c.index * chunkSize; off < v.Size {
+			copy(data[off:], c.data)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read version: %w", err)
+	}
+	return data, v, nil
+}
+
+// RevertTo makes version the current content of the file at p, recreating
+// the file if it was removed. The content it replaces is kept as a new
+// version, so a revert can itself be undone.
+//
+// Example:
+//
+//	versions, _ := afs.FS.History(ctx, "/notes/plan.md")
+//	if len(versions) > 0 {
+//	    err = afs.FS.RevertTo(ctx, "/notes/plan.md", versions[0].Version)
+//	}
+func (fs *Filesystem) RevertTo(ctx context.Context, p string, version int64) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "revert", p)
+	if err != nil {
+		return err
+	}
+	data, v, err := fs.readVersion(ctx, p, version)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(ctx, p, data, v.Mode)
+}
@@ -0,0 +1,100 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFileVersions(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	// Without a policy, overwrites keep nothing
+	afs.FS.WriteFile(ctx, "/plan.md", []byte("draft 0"), 0o644)
+	afs.FS.WriteFile(ctx, "/plan.md", []byte("draft 1"), 0o644)
+	if versions, err := afs.FS.History(ctx, "/plan.md"); err != nil || len(versions) != 0 {
+		t.Fatalf("History without policy = %v, %v", versions, err)
+	}
+
+	if err := afs.SetPolicy(ctx, Policy{KeepVersions: 2}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	afs.FS.WriteFile(ctx, "/plan.md", []byte("draft 2"), 0o600)
+	afs.FS.WriteFile(ctx, "/plan.md", []byte("draft 3"), 0o600)
+	afs.FS.WriteFile(ctx, "/plan.md", []byte("draft 4"), 0o600)
+
+	versions, err := afs.FS.History(ctx, "/plan.md")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 3 || versions[1].Version != 2 {
+		t.Fatalf("History = %+v, want versions 3 and 2", versions)
+	}
+	data, err := afs.FS.ReadVersion(ctx, "/plan.md", 3)
+	if err != nil || string(data) != "draft 3" {
+		t.Errorf("ReadVersion(3) = %q, %v", data, err)
+	}
+	if _, err := afs.FS.ReadVersion(ctx, "/plan.md", 1); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("ReadVersion of expired version = %v, want ErrVersionNotFound", err)
+	}
+
+	if err := afs.FS.RevertTo(ctx, "/plan.md", 2); err != nil {
+		t.Fatalf("RevertTo failed: %v", err)
+	}
+	if data, _ := afs.FS.ReadFile(ctx, "/plan.md"); string(data) != "draft 2" {
+		t.Errorf("after revert = %q, want draft 2", data)
+	}
+	versions, _ = afs.FS.History(ctx, "/plan.md")
+	if len(versions) != 2 || versions[0].Version != 4 {
+		t.Fatalf("History after revert = %+v, want newest version 4", versions)
+	}
+	if data, _ := afs.FS.ReadVersion(ctx, "/plan.md", 4); string(data) != "draft 4" {
+		t.Errorf("reverted-over content = %q, want draft 4", data)
+	}
+
+	// History outlives the file
+	afs.FS.Unlink(ctx, "/plan.md")
+	if err := afs.FS.RevertTo(ctx, "/plan.md", 4); err != nil {
+		t.Fatalf("RevertTo removed file failed: %v", err)
+	}
+	if data, _ := afs.FS.ReadFile(ctx, "/plan.md"); string(data) != "draft 4" {
+		t.Errorf("recreated = %q, want draft 4", data)
+	}
+}
+
+func TestFileVersions_LargeAndTruncated(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	if err := afs.SetPolicy(ctx, Policy{KeepVersions: 5}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+	big := make([]byte, 3*afs.FS.chunkSize+17)
+	for i := range big {
+		big[i] = byte(i % 251)
+	}
+	afs.FS.WriteFile(ctx, "/data.bin", big, 0o644)
+
+	f, err := afs.FS.Open(ctx, "/data.bin", O_WRONLY|O_TRUNC)
+	if err != nil {
+		t.Fatalf("Open(O_TRUNC) failed: %v", err)
+	}
+	f.Close()
+
+	data, err := afs.FS.ReadVersion(ctx, "/data.bin", 1)
+	if err != nil {
+		t.Fatalf("ReadVersion failed: %v", err)
+	}
+	if string(data) != string(big) {
+		t.Errorf("ReadVersion returned %d bytes, want the %d truncated", len(data), len(big))
+	}
+
+	// Emptying an empty file keeps nothing
+	afs.FS.WriteFile(ctx, "/data.bin", nil, 0o644)
+	if versions, _ := afs.FS.History(ctx, "/data.bin"); len(versions) != 1 {
+		t.Errorf("History = %+v, want one version", versions)
+	}
+}
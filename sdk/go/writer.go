@@ -0,0 +1,294 @@
+package agentfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+)
+
+// ErrWriterClosed is returned by FileWriter.Write after Close.
+var ErrWriterClosed = errors.New("agentfs: write to closed writer")
+
+// FileWriter streams data into a file one chunk at a time, so a file of
+// any size can be written while holding at most one chunk in memory. Each
+// full chunk is stored, and the file size advanced, in its own transaction;
+// readers see the file grow chunk by chunk.
+//
+// A FileWriter is not safe for concurrent use.
+type FileWriter struct {
+	file    *File
+	ctx     context.Context
+	buf     []byte
+	index   int64 // Index of the chunk in buf
+	written int64
+	err     error // Sticky error from a failed flush
+	closed  bool
+	target  string      // Path Close moves a staged file to (see OpenStagedWriter)
+	fs      *Filesystem // Filesystem the target is written through
+}
+
+// Compile-time interface checks
+var (
+	_ io.WriteCloser = (*FileWriter)(nil)
+	_ io.ReaderFrom  = (*FileWriter)(nil)
+)
+
+// OpenWriter creates or truncates the file at p, creating missing parent
+// directories, and returns a writer that streams into it. Writes are
+// buffered up to the chunk size and stored as whole chunks, avoiding the
+// read-modify-write of File.Write. ctx applies to every write; Close stores
+// the final partial chunk and must be called for the file to be complete.
+//
+// Example:
+//
+//	w, err := afs.FS.OpenWriter(ctx, "/outputs/dataset.jsonl")
+//	if err != nil {
+//	    return err
+//	}
+//	if _, err := io.Copy(w, resp.Body); err != nil {
+//	    w.Close()
+//	    return err
+//	}
+//	return w.Close()
+func (fs *Filesystem) OpenWriter(ctx context.Context, p string) (*FileWriter, error) {
+	_, f, err := fs.Create(ctx, p, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f.flags = O_WRONLY
+	chunkSize, err := fs.chunkSizeOf(ctx, f.ino)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileWriter{file: f, ctx: ctx, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// Write appends p to the file. It implements io.Writer.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		k := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return n - k, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom copies r into the file until EOF, reading directly into the
+// chunk buffer. It implements io.ReaderFrom, so io.Copy avoids an extra
+// copy.
+func (w *FileWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	var total int64
+	for {
+		n, err := r.Read(w.buf[len(w.buf):cap(w.buf)])
+		w.buf = w.buf[:len(w.buf)+n]
+		total += int64(n)
+		if len(w.buf) == cap(w.buf) {
+			if ferr := w.flush(); ferr != nil {
+				return total, ferr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// stagingDir holds the files of OpenStagedWriter until they are complete.
+const stagingDir = ReservedDir + "/staging"
+
+// OpenStagedWriter is OpenWriter for a file that must never be seen half
+// written, such as an upload that may break off. The data is streamed
+// into a staging file in ReservedDir, and Close moves it over the file at
+// p in one transaction, keeping the mode of the file it replaces; until
+// then readers see the previous content. Abort discards the staging file
+// and leaves p untouched. A staging file left by a crashed process stays
+// in ReservedDir until removed with the SDK.
+//
+// Example:
+//
+//	w, err := afs.FS.OpenStagedWriter(ctx, "/uploads/report.pdf")
+//	if err != nil {
+//	    return err
+//	}
+//	if _, err := io.Copy(w, r.Body); err != nil {
+//	    w.Abort()
+//	    return err
+//	}
+//	return w.Close()
+func (fs *Filesystem) OpenStagedWriter(ctx context.Context, p string) (*FileWriter, error) {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p, err = fs.cleanPath(ctx, "write", p)
+	if err != nil {
+		return nil, err
+	}
+	// Writing through a symlink writes its target
+	target, err := fs.realPath(ctx, p, true)
+	if err != nil {
+		return nil, err
+	}
+	if target == "/" {
+		return nil, ErrIsDir("write", p)
+	}
+	if stats, err := fs.Stat(ctx, target); err == nil && stats.IsDir() {
+		return nil, ErrIsDir("write", p)
+	}
+
+	w, err := fs.systemFS().OpenWriter(ctx, path.Join(stagingDir, fs.ids.NewID()))
+	if err != nil {
+		return nil, err
+	}
+	w.target, w.fs = target, fs
+	return w, nil
+}
+
+// commit moves the staged file of w over its target.
+func (w *FileWriter) commit() error {
+	sfs := w.file.fs
+	ctx, done, err := sfs.life.begin(w.ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	err = sfs.inTx(ctx, func(tfs *Filesystem) error {
+		if stats, err := tfs.Stat(ctx, w.target); err == nil && stats.IsRegularFile() {
+			if err := tfs.Chmod(ctx, w.file.path, stats.Mode); err != nil {
+				return err
+			}
+		}
+		return tfs.Rename(ctx, w.file.path, w.target)
+	})
+	if err != nil {
+		sfs.Unlink(ctx, w.file.path)
+		return err
+	}
+	w.fs.emit(EventFileWritten, w.target, "")
+	return nil
+}
+
+// Abort ends a writer without completing the file. The staging file of
+// OpenStagedWriter is removed, leaving the target as it was; a file opened
+// with OpenWriter keeps the data stored so far. Aborting after Close is a
+// no-op.
+func (w *FileWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.file.Close()
+	if w.target == "" {
+		return nil
+	}
+	ctx, done, err := w.file.fs.life.begin(context.WithoutCancel(w.ctx))
+	if err != nil {
+		return err
+	}
+	defer done()
+	return w.file.fs.Unlink(ctx, w.file.path)
+}
+
+// Written returns the number of bytes stored so far, excluding data still
+// buffered.
+func (w *FileWriter) Written() int64 {
+	return w.written
+}
+
+// Path returns the path of the file being written.
+func (w *FileWriter) Path() string {
+	if w.target != "" {
+		return w.target
+	}
+	return w.file.path
+}
+
+// flush stores the buffered chunk and advances the file size. A full
+// buffer moves on to the next chunk; a partial one is rewritten in place
+// by the next flush.
+func (w *FileWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	fs := w.file.fs
+	ctx, done, err := fs.life.begin(w.ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	size := w.index*int64(cap(w.buf)) + int64(len(w.buf))
+	err = fs.inTx(ctx, func(tfs *Filesystem) error {
+		if err := tfs.checkQuota(ctx, "write", w.file.path, w.file.ino, size); err != nil {
+			return err
+		}
+		if err := tfs.writeChunk(ctx, w.file.ino, w.index, w.buf, size); err != nil {
+			return err
+		}
+		now := tfs.now()
+		_, err := tfs.db.ExecContext(ctx, updateInodeSize, size, now.Unix(), int64(now.Nanosecond()), w.file.ino)
+		return err
+	})
+	if err != nil {
+		w.err = err
+		return err
+	}
+
+	w.written = size
+	if len(w.buf) == cap(w.buf) {
+		w.index++
+		w.buf = w.buf[:0]
+	}
+	return nil
+}
+
+// Close stores any buffered data and releases the file handle. The file
+// is complete once Close returns nil; a staged file (see OpenStagedWriter)
+// is then in place, and one that failed is discarded. Closing more than
+// once is a no-op.
+func (w *FileWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	if w.err == nil {
+		w.err = w.flush()
+	}
+	if w.err != nil {
+		w.Abort()
+		return w.err
+	}
+	w.closed = true
+	w.file.Close()
+	if w.target != "" {
+		return w.commit()
+	}
+	w.file.fs.emit(EventFileWritten, w.file.path, "")
+	return nil
+}
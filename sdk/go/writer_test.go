@@ -0,0 +1,125 @@
+package agentfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestOpenWriter(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	chunk := afs.FS.ChunkSize()
+	data := bytes.Repeat([]byte("0123456789abcdef"), (chunk*5/2)/16+1)
+
+	w, err := afs.FS.OpenWriter(ctx, "/outputs/big.bin")
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	// Uneven writes straddle chunk boundaries
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), 1000)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if w.Written() != int64(2*chunk) {
+		t.Errorf("Written() = %d before Close, want %d", w.Written(), 2*chunk)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got, err := afs.FS.ReadFile(ctx, "/outputs/big.bin")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("content mismatch: got %d bytes, want %d", len(got), len(data))
+	}
+
+	if _, err := w.Write([]byte("x")); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("Write after Close = %v, want ErrWriterClosed", err)
+	}
+	if n := len(afs.FS.OpenHandles()); n != 0 {
+		t.Errorf("%d handles open after Close, want 0", n)
+	}
+}
+
+func TestOpenWriter_Copy(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/out.txt", []byte("old content that is longer"), 0o644)
+
+	w, err := afs.FS.OpenWriter(ctx, "/out.txt")
+	if err != nil {
+		t.Fatalf("OpenWriter failed: %v", err)
+	}
+	if _, err := io.Copy(w, bytes.NewReader([]byte("new"))); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got, _ := afs.FS.ReadFile(ctx, "/out.txt")
+	if string(got) != "new" {
+		t.Errorf("content = %q, want %q", got, "new")
+	}
+}
+
+func TestOpenStagedWriter(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+
+	afs.FS.WriteFile(ctx, "/report.txt", []byte("old"), 0o600)
+	data := bytes.Repeat([]byte("new "), afs.FS.ChunkSize())
+
+	// Until Close, and after Abort, readers see the old content
+	w, err := afs.FS.OpenStagedWriter(ctx, "/report.txt")
+	if err != nil {
+		t.Fatalf("OpenStagedWriter failed: %v", err)
+	}
+	w.Write(data)
+	if got, _ := afs.FS.ReadFile(ctx, "/report.txt"); string(got) != "old" {
+		t.Errorf("content while staged = %d bytes, want the old content", len(got))
+	}
+	if err := w.Abort(); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if got, _ := afs.FS.ReadFile(ctx, "/report.txt"); string(got) != "old" {
+		t.Errorf("content after Abort = %d bytes, want the old content", len(got))
+	}
+
+	w, err = afs.FS.OpenStagedWriter(ctx, "/report.txt")
+	if err != nil {
+		t.Fatalf("OpenStagedWriter failed: %v", err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got, _ := afs.FS.ReadFile(ctx, "/report.txt"); !bytes.Equal(got, data) {
+		t.Errorf("content after Close = %d bytes, want %d", len(got), len(data))
+	}
+	if stats, err := afs.FS.Stat(ctx, "/report.txt"); err != nil || stats.Mode&0o777 != 0o600 {
+		t.Errorf("Stat = %+v, %v; want the mode of the replaced file", stats, err)
+	}
+
+	// No staging files are left behind
+	entries, err := afs.FS.Readdir(ctx, stagingDir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("staging directory = %v, %v; want empty", entries, err)
+	}
+	if _, err := afs.FS.OpenStagedWriter(ctx, ReservedDir+"/x"); !errors.Is(err, ErrReserved) {
+		t.Errorf("OpenStagedWriter in the reserved directory = %v, want ErrReserved", err)
+	}
+}
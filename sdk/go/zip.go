@@ -0,0 +1,239 @@
+package agentfs
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ReadZip extracts the zip archive read from r below dest, creating dest
+// and any missing directories. Only entries matched by opts.Include are
+// extracted, so a large export can be searched for just the parts needed.
+// Entry names are confined to dest: "../" prefixes are dropped, and entries
+// reaching outside dest through a symlink, as well as symlinks with
+// absolute targets or targets outside dest, fail the extraction. Existing
+// files are overwritten. r is read directly when it is an io.ReaderAt with
+// a known size (e.g. *os.File, *bytes.Reader); otherwise the archive is
+// buffered in a temporary file first, since zip keeps its index at the end.
+//
+// Example:
+//
+//	f, _ := os.Open("export.zip")
+//	err := afs.FS.ReadZip(ctx, f, "/data", agentfs.ZipOptions{
+//	    Include: []string{"reports/*.csv"},
+//	})
+func (fs *Filesystem) ReadZip(ctx context.Context, r io.Reader, dest string, opts ZipOptions) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	dest, err = fs.cleanPath(ctx, "unzip", dest)
+	if err != nil {
+		return err
+	}
+
+	ra, size, cleanup, err := zipSource(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+
+	var include *IgnoreRules
+	if len(opts.Include) > 0 {
+		include = NewIgnoreRules(opts.Include...)
+	}
+	if err := fs.MkdirAll(ctx, dest, 0o755); err != nil {
+		return err
+	}
+
+	tracker := newProgressTracker("unzip", opts.Progress)
+	var written int64
+	buf := make([]byte, 64<<10)
+	for _, entry := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(path.Clean("/"+entry.Name), "/")
+		isDir := strings.HasSuffix(entry.Name, "/") || entry.Mode().IsDir()
+		if rel == "" || (include != nil && !include.Match(rel, isDir)) {
+			continue
+		}
+		target := path.Join(dest, rel)
+		if err := fs.confineEntry(ctx, "unzip", dest, target, ""); err != nil {
+			return err
+		}
+
+		if isDir {
+			if err := fs.MkdirAll(ctx, target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read zip entry %s: %w", entry.Name, err)
+		}
+		var src io.Reader = rc
+		if opts.MaxBytes > 0 {
+			src = io.LimitReader(rc, opts.MaxBytes-written+1)
+		}
+
+		var n int64
+		if entry.Mode()&os.ModeSymlink != 0 {
+			var link []byte
+			if link, err = io.ReadAll(src); err == nil {
+				n = int64(len(link))
+				err = fs.confineEntry(ctx, "unzip", dest, target, string(link))
+			}
+			if err == nil {
+				if err = fs.Unlink(ctx, target); err == nil || IsNotExist(err) {
+					err = fs.Symlink(ctx, string(link), target)
+				}
+			}
+		} else {
+			n, err = fs.copyIn(ctx, target, src, zipPerm(entry), buf)
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+
+		written += n
+		if opts.MaxBytes > 0 && written > opts.MaxBytes {
+			return fmt.Errorf("zip archive exceeds %d bytes", opts.MaxBytes)
+		}
+		if err := tracker.add(ctx, 1, n, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyIn streams src into the file at p, replacing its content.
+func (fs *Filesystem) copyIn(ctx context.Context, p string, src io.Reader, perm int64, buf []byte) (int64, error) {
+	_, f, err := fs.Create(ctx, p, perm)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.CopyBuffer(f.WithContext(ctx), src, buf)
+}
+
+// zipPerm returns the permission bits of a zip entry, or 0644 if the
+// archive does not record them.
+func zipPerm(entry *zip.File) int64 {
+	if perm := entry.Mode().Perm(); perm != 0 {
+		return int64(perm)
+	}
+	return 0o644
+}
+
+// zipSource returns r as an io.ReaderAt with its size, buffering it in a
+// temporary file if needed. cleanup removes the buffer.
+func zipSource(r io.Reader) (io.ReaderAt, int64, func(), error) {
+	type sizedReaderAt interface {
+		io.ReaderAt
+		Size() int64
+	}
+	switch v := r.(type) {
+	case sizedReaderAt:
+		return v, v.Size(), func() {}, nil
+	case *os.File:
+		if info, err := v.Stat(); err == nil && info.Mode().IsRegular() {
+			return v, info.Size(), func() {}, nil
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "agentfs-zip-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("failed to buffer zip archive: %w", err)
+	}
+	return tmp, size, cleanup, nil
+}
+
+// WriteZip streams the tree below root to w as a zip archive, with entry
+// names relative to root. Directories, including empty ones, and symlinks
+// are kept; file contents are compressed with Deflate.
+//
+// Example:
+//
+//	f, _ := os.Create("outputs.zip")
+//	defer f.Close()
+//	err := afs.FS.WriteZip(ctx, "/outputs", f)
+func (fs *Filesystem) WriteZip(ctx context.Context, root string, w io.Writer) error {
+	ctx, done, err := fs.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	root, err = fs.cleanPath(ctx, "export", root)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	buf := make([]byte, 64<<10)
+	err = fs.walk(ctx, root, nil, func(p, rel string, stats *Stats) error {
+		hdr := &zip.FileHeader{Name: rel, Method: zip.Deflate, Modified: stats.MtimeTime()}
+		switch {
+		case stats.IsDir():
+			hdr.Name += "/"
+			hdr.Method = zip.Store
+			hdr.SetMode(os.ModeDir | os.FileMode(stats.Permissions()))
+			_, err := zw.CreateHeader(hdr)
+			return err
+		case stats.IsSymlink():
+			link, err := fs.Readlink(ctx, p)
+			if err != nil {
+				return err
+			}
+			hdr.SetMode(os.ModeSymlink | 0o777)
+			ew, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(ew, link)
+			return err
+		case stats.IsRegularFile():
+			hdr.SetMode(os.FileMode(stats.Permissions()))
+			ew, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			f, err := fs.Open(ctx, p, O_RDONLY)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.CopyBuffer(ew, f.WithContext(ctx), buf)
+			return err
+		default:
+			return nil // Device nodes and FIFOs have no zip representation
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
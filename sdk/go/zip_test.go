@@ -0,0 +1,122 @@
+package agentfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestZip(t *testing.T) {
+	ctx := context.Background()
+	afs := setupTestDB(t)
+	defer afs.Close()
+	fs := afs.FS
+
+	fs.WriteFile(ctx, "/src/reports/q3.csv", []byte("a,b\n1,2\n"), 0o600)
+	fs.WriteFile(ctx, "/src/reports/notes.txt", []byte("notes"), 0o644)
+	fs.WriteFile(ctx, "/src/raw/dump.bin", bytes.Repeat([]byte{1}, 1000), 0o644)
+	fs.Mkdir(ctx, "/src/empty", 0o755)
+	fs.Symlink(ctx, "reports/q3.csv", "/src/latest")
+
+	var buf bytes.Buffer
+	if err := fs.WriteZip(ctx, "/src", &buf); err != nil {
+		t.Fatalf("WriteZip failed: %v", err)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		if err := fs.ReadZip(ctx, bytes.NewReader(buf.Bytes()), "/copy", ZipOptions{}); err != nil {
+			t.Fatalf("ReadZip failed: %v", err)
+		}
+		data, err := fs.ReadFile(ctx, "/copy/reports/q3.csv")
+		if err != nil || string(data) != "a,b\n1,2\n" {
+			t.Errorf("ReadFile = %q, %v", data, err)
+		}
+		if stats, _ := fs.Stat(ctx, "/copy/reports/q3.csv"); stats == nil || stats.Permissions() != 0o600 {
+			t.Errorf("permissions not kept: %+v", stats)
+		}
+		if stats, err := fs.Stat(ctx, "/copy/empty"); err != nil || !stats.IsDir() {
+			t.Errorf("empty directory not kept: %v", err)
+		}
+		if link, err := fs.Readlink(ctx, "/copy/latest"); err != nil || link != "reports/q3.csv" {
+			t.Errorf("Readlink = %q, %v", link, err)
+		}
+	})
+
+	t.Run("selective from a stream", func(t *testing.T) {
+		// A plain io.Reader is buffered before extraction
+		r := io.MultiReader(bytes.NewReader(buf.Bytes()))
+		if err := fs.ReadZip(ctx, r, "/part", ZipOptions{Include: []string{"reports/*.csv"}}); err != nil {
+			t.Fatalf("ReadZip failed: %v", err)
+		}
+		files, _ := fs.Find(ctx, "/part", nil)
+		if len(files) != 2 || files[1] != "/part/reports/q3.csv" {
+			t.Errorf("extracted %v", files)
+		}
+	})
+
+	t.Run("confined to dest", func(t *testing.T) {
+		var evil bytes.Buffer
+		zw := zip.NewWriter(&evil)
+		w, _ := zw.Create("../../escape.txt")
+		w.Write([]byte("x"))
+		zw.Close()
+
+		if err := fs.ReadZip(ctx, bytes.NewReader(evil.Bytes()), "/jail", ZipOptions{}); err != nil {
+			t.Fatalf("ReadZip failed: %v", err)
+		}
+		if _, err := fs.Stat(ctx, "/jail/escape.txt"); err != nil {
+			t.Errorf("entry not extracted inside dest: %v", err)
+		}
+		if _, err := fs.Stat(ctx, "/escape.txt"); !IsNotExist(err) {
+			t.Errorf("entry escaped dest: %v", err)
+		}
+	})
+
+	t.Run("symlink escape", func(t *testing.T) {
+		archive := func(link string) *bytes.Reader {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			hdr := &zip.FileHeader{Name: "l"}
+			hdr.SetMode(os.ModeSymlink | 0o777)
+			w, _ := zw.CreateHeader(hdr)
+			w.Write([]byte(link))
+			w, _ = zw.Create("l/escaped.txt")
+			w.Write([]byte("x"))
+			zw.Close()
+			return bytes.NewReader(buf.Bytes())
+		}
+		for _, link := range []string{"/", "../.."} {
+			if err := fs.ReadZip(ctx, archive(link), "/zjail", ZipOptions{}); err == nil {
+				t.Errorf("ReadZip of a symlink to %q succeeded", link)
+			}
+			if _, err := fs.Stat(ctx, "/escaped.txt"); !IsNotExist(err) {
+				t.Fatalf("entry escaped dest: %v", err)
+			}
+		}
+
+		if err := fs.Symlink(ctx, "/", "/zold/l"); err != nil {
+			t.Fatalf("Symlink failed: %v", err)
+		}
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create("l/escaped.txt")
+		w.Write([]byte("x"))
+		zw.Close()
+		if err := fs.ReadZip(ctx, bytes.NewReader(buf.Bytes()), "/zold", ZipOptions{}); err == nil {
+			t.Error("ReadZip through an existing symlink out of dest succeeded")
+		}
+		if _, err := fs.Stat(ctx, "/escaped.txt"); !IsNotExist(err) {
+			t.Fatalf("entry escaped dest: %v", err)
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		err := fs.ReadZip(ctx, bytes.NewReader(buf.Bytes()), "/small", ZipOptions{MaxBytes: 100})
+		if err == nil {
+			t.Error("ReadZip ignored MaxBytes")
+		}
+	})
+}